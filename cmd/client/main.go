@@ -8,6 +8,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"text/tabwriter"
 	"time"
@@ -67,11 +68,14 @@ func main() {
 
 	switch command {
 	case "list":
+		listFlags := flag.NewFlagSet("list", flag.ExitOnError)
+		query := listFlags.String("q", "", "Offer search query")
+		listFlags.Parse(args[1:])
 		provider := "all"
-		if len(args) > 1 {
-			provider = args[1]
+		if listFlags.NArg() > 0 {
+			provider = listFlags.Arg(0)
 		}
-		listInstances(provider)
+		listInstances(provider, *query)
 
 	case "create":
 		if len(args) < 3 {
@@ -96,7 +100,11 @@ func main() {
 		if len(args) > 1 {
 			subcommand = args[1]
 		}
-		showLoad(subcommand)
+		pool := ""
+		if len(args) > 2 {
+			pool = args[2]
+		}
+		showLoad(subcommand, pool)
 
 	case "reserve":
 		count := 1
@@ -129,23 +137,30 @@ func printUsage() {
 	fmt.Println("  -dv, -developer-mode    Enable developer mode")
 	fmt.Println("  -dm, -debug-mode        Enable debug mode")
 	fmt.Println("\nCommands:")
-	fmt.Println("  list [provider]                      List available GPU instances")
+	fmt.Println("  list [-q query] [provider]           List available GPU instances")
 	fmt.Println("                                       provider: all, vast.ai, io.net (default: all)")
+	fmt.Println("                                       query: e.g. 'vram>=80 gpu in (H100,A100) price<2.5")
+	fmt.Println("                                       region=us-* order by price_per_tflop limit 10'")
 	fmt.Println("  create <provider> <instance-id>      Create a GPU instance")
 	fmt.Println("  destroy <provider> <instance-id>     Destroy a GPU instance")
 	fmt.Println("  reserve <count>                      Reserve multiple GPUs (1-16, default: 1)")
 	fmt.Println("  proxy <protocol> <target-url>        Proxy a request through GPU")
 	fmt.Println("                                       protocol: http, https, mcp, openinference")
-	fmt.Println("  load [type]                          Show load information")
+	fmt.Println("  load [type] [pool]                   Show load information, optionally for a named pool")
 	fmt.Println("                                       type: all, server, provider (default: all)")
 	fmt.Println("  lb-strategy [strategy]               Get/set load balancing strategy")
 	fmt.Println("                                       strategies: round_robin, equal_weighted,")
 	fmt.Println("                                       weighted_round_robin, least_connections,")
-	fmt.Println("                                       least_response_time")
+	fmt.Println("                                       least_response_time, consistent_hash,")
+	fmt.Println("                                       sticky_session, p2c")
 }
 
-func listInstances(provider string) {
-	url := fmt.Sprintf("%s/api/v1/gpu/instances?provider=%s", apiURL, provider)
+func listInstances(provider, query string) {
+	params := url.Values{"provider": {provider}}
+	if query != "" {
+		params.Set("q", query)
+	}
+	url := fmt.Sprintf("%s/api/v1/gpu/instances?%s", apiURL, params.Encode())
 
 	if debugMode {
 		log.Printf("GET %s", url)
@@ -307,21 +322,25 @@ func reserveInstances(count int) {
 	}
 }
 
-func showLoad(loadType string) {
+func showLoad(loadType, pool string) {
 	switch loadType {
 	case "server":
-		showServerLoad()
+		showServerLoad(pool)
 	case "provider":
 		showProviderLoad()
 	default:
-		showServerLoad()
+		showServerLoad(pool)
 		fmt.Println()
 		showProviderLoad()
 	}
 }
 
-func showServerLoad() {
-	url := fmt.Sprintf("%s/api/v1/loadbalancer/loads", apiURL)
+func showServerLoad(pool string) {
+	query := ""
+	if pool != "" {
+		query = "?" + url.Values{"pool": {pool}}.Encode()
+	}
+	url := fmt.Sprintf("%s/api/v1/loadbalancer/loads%s", apiURL, query)
 
 	if debugMode {
 		log.Printf("GET %s", url)
@@ -337,6 +356,11 @@ func showServerLoad() {
 		log.Fatalf("Failed to parse response: %v", err)
 	}
 
+	if pool != "" {
+		fmt.Printf("Pool: %s\n", pool)
+	} else if pools, ok := result["pools"].([]interface{}); ok && len(pools) > 0 {
+		fmt.Printf("Pools: %v\n", pools)
+	}
 	fmt.Printf("Load Balancing Strategy: %v\n", result["strategy"])
 	fmt.Printf("Tracked Instances: %v\n\n", result["count"])
 
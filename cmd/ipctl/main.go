@@ -56,7 +56,7 @@ func main() {
 }
 
 func printUsage() {
-	fmt.Println(`ipctl - IP Access Control Management Tool
+	fmt.Print(`ipctl - IP Access Control Management Tool
 
 Usage:
   ipctl <command> [options]
@@ -334,10 +334,6 @@ func (s *MCPServer) callTool(ctx context.Context, name string, args map[string]i
 
 func (s *MCPServer) provisionGPU(args map[string]interface{}) (map[string]interface{}, error) {
 	gpuModel := args["gpu_model"].(string)
-	provider := "vastai"
-	if p, ok := args["provider"].(string); ok {
-		provider = p
-	}
 
 	body := map[string]interface{}{
 		"preferred_gpus": []map[string]interface{}{
@@ -56,7 +56,7 @@ func main() {
 	}
 
 	if dryRun {
-		log.Println("\n=== DRY RUN MODE - No changes will be made ===\n")
+		log.Print("\n=== DRY RUN MODE - No changes will be made ===\n\n")
 		printPlan(admins, clients)
 		return
 	}
@@ -143,7 +143,7 @@ func parseConfigFile(path string) (map[string]string, map[string]string, error)
 }
 
 func printPlan(admins, clients map[string]string) {
-	fmt.Println("Would create the following users:\n")
+	fmt.Print("Would create the following users:\n\n")
 
 	fmt.Println("Admins:")
 	for name := range admins {
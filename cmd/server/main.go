@@ -18,6 +18,7 @@ import (
 	"github.com/aiserve/gpuproxy/internal/api"
 	"github.com/aiserve/gpuproxy/internal/auth"
 	"github.com/aiserve/gpuproxy/internal/billing"
+	"github.com/aiserve/gpuproxy/internal/compute"
 	"github.com/aiserve/gpuproxy/internal/config"
 	"github.com/aiserve/gpuproxy/internal/database"
 	"github.com/aiserve/gpuproxy/internal/gpu"
@@ -33,13 +34,16 @@ import (
 	"github.com/aiserve/gpuproxy/internal/metrics"
 	"github.com/aiserve/gpuproxy/internal/middleware"
 	"github.com/aiserve/gpuproxy/internal/models"
+	aiproxy "github.com/aiserve/gpuproxy/internal/router"
 	grpcServer "github.com/aiserve/gpuproxy/internal/grpc"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
 var (
-	developerMode bool
-	debugMode     bool
+	developerMode     bool
+	debugMode         bool
+	aiproxyConfigPath string
 )
 
 func main() {
@@ -50,6 +54,7 @@ func main() {
 	flag.BoolVar(&developerMode, "developer-mode", false, "Enable developer mode")
 	flag.BoolVar(&debugMode, "dm", false, "Enable debug mode")
 	flag.BoolVar(&debugMode, "debug-mode", false, "Enable debug mode")
+	flag.StringVar(&aiproxyConfigPath, "aiproxy-config", os.Getenv("AIPROXY_CONFIG"), "Path to AIProxy YAML/JSON config (enables OpenAI-compatible /v1 endpoints)")
 	flag.Parse()
 
 	if debugMode {
@@ -105,6 +110,18 @@ func main() {
 	}
 	defer redis.Close()
 
+	gpuService := gpu.NewService(&cfg.GPU)
+
+	priceDB := db.SQLDB()
+	defer priceDB.Close()
+	priceStore, err := gpu.OpenPriceStore(cfg.GPU.PriceHistory.Store, priceDB)
+	if err != nil {
+		log.Fatalf("Failed to open price history store: %v", err)
+	}
+	defer priceStore.Close()
+	priceHistory := gpu.NewPriceHistory(priceStore, cfg.GPU.PriceHistory.RawRetention, cfg.GPU.PriceHistory.Retention)
+	gpuService.SetPriceHistory(priceHistory)
+
 	// Detect local GPU backends
 	log.Println("Detecting local GPU backends...")
 	backends := gpu.DetectBackends()
@@ -114,19 +131,64 @@ func main() {
 		log.Printf("Local GPU backend available: %s", gpu.GetBackendInfo(backends))
 		log.Printf("Using local backend: %s (preferred: %s)", availableBackend, cfg.GPU.PreferredBackend)
 	} else {
-		if cfg.GPU.VastAIAPIKey != "" || cfg.GPU.IONetAPIKey != "" {
-			log.Println("No local GPU backend detected. Using cloud providers only.")
+		if names := gpuService.Providers().Names(); len(names) > 0 {
+			log.Printf("No local GPU backend detected. Using GPU providers only: %v", names)
 		} else {
 			log.Println("WARNING: No local GPU backends and no cloud provider API keys configured.")
 			log.Println("Server will start but GPU operations will fail until providers are configured.")
 		}
 	}
 
+	// Local reservations and served models share one view of this host's GPUs
+	localDevices := gpu.DetectDevices()
+	if len(localDevices) > 0 {
+		log.Printf("Detected %d local GPU devices", len(localDevices))
+	}
+	deviceAllocator := gpu.NewDeviceAllocator(localDevices)
+
+	reservationDB := db.SQLDB()
+	defer reservationDB.Close()
+	reservationStore, err := compute.OpenReservationStore(cfg.GPU.Reservations.Store, reservationDB)
+	if err != nil {
+		log.Fatalf("Failed to open reservation store: %v", err)
+	}
+	reservationClient, err := compute.NewReservationClientWithStore(reservationStore, cfg.GPU.VastAIAPIKey, cfg.GPU.IONetAPIKey, "")
+	if err != nil {
+		log.Fatalf("Failed to load reservations: %v", err)
+	}
+	defer reservationClient.Close()
+	reservationClient.SetPendingTimeout(cfg.GPU.Reservations.PendingTimeout)
+	reservationClient.SetPreemptionGrace(cfg.GPU.Reservations.PreemptionGrace)
+	reservationClient.SetPriorityLimits(compute.PriorityLimits{
+		MaxPriority: cfg.GPU.Reservations.UserMaxPriority,
+		Preempt:     cfg.GPU.Reservations.UserPreempt,
+	})
+	reservationClient.SetDeviceAllocator(deviceAllocator)
+	if fake, ok := gpuService.Providers().Get(gpu.ProviderFake); ok {
+		reservationClient.RegisterMarketplace(compute.ProviderFake, fake)
+	}
 	authService := auth.NewService(db, redis, &cfg.Auth)
 	billingService := billing.NewService(db, &cfg.Billing)
-	gpuService := gpu.NewService(&cfg.GPU)
 	protocolHandler := gpu.NewProtocolHandler(cfg.GPU.Timeout)
 	lbService := loadbalancer.NewLoadBalancerService(loadbalancer.Strategy(cfg.LoadBalancer.Strategy))
+	affinitySource, err := loadbalancer.ParseAffinitySource(cfg.LoadBalancer.AffinityKey)
+	if err != nil {
+		log.Fatalf("Invalid LB_AFFINITY_KEY: %v", err)
+	}
+	lbService.SetAffinity(loadbalancer.AffinityConfig{
+		Source:     affinitySource,
+		LoadFactor: cfg.LoadBalancer.HashLoadFactor,
+		SessionTTL: cfg.LoadBalancer.StickySessionTTL,
+	})
+	lbService.SetEWMADecay(cfg.LoadBalancer.EWMADecay)
+	if cfg.LoadBalancer.StateBackend == "redis" {
+		// Each process is its own node, so a restarted replica never inherits stale counts
+		hostname, _ := os.Hostname()
+		nodeID := fmt.Sprintf("%s-%d", hostname, time.Now().UnixNano())
+		lbService.SetLoadStore(loadbalancer.NewRedisLoadStore(redis.Client, cfg.LoadBalancer.StateRedisPrefix, nodeID, cfg.LoadBalancer.StateTTL))
+		go lbService.RunLoadSync(context.Background(), cfg.LoadBalancer.StateSyncInterval)
+		log.Printf("Load balancer state shared through Redis (node: %s)", nodeID)
+	}
 
 	authMiddleware := middleware.NewAuthMiddleware(authService, cfg.Auth.JWTSecret)
 	rateLimiter := middleware.NewRateLimiter(redis)
@@ -144,13 +206,58 @@ func main() {
 	billingHandler := api.NewBillingHandler(billingService)
 	gpuHandler := api.NewGPUHandler(gpuService, protocolHandler, lbService)
 	gpuPrefsHandler := api.NewGPUPreferencesHandler(db)
+	priceHandler := api.NewPriceHandler(priceHistory)
 	lbHandler := api.NewLoadBalancerHandler(lbService)
 	userHandler := api.NewUserHandler(db)
 	wsHandler := api.NewWebSocketHandler()
 	guardRailsHandler := api.NewGuardRailsHandler(guardRails)
 	mcpHandler := api.NewMCPHandler(mcpServer)
 	agentHandler := api.NewAgentHandler(a2aServer, acpServer, cuicServer, fipaServer, kqmlServer, langchainServer)
-	ipAccessHandler := api.NewIPAccessHandler(db)
+	ipAccessHandler := api.NewIPAccessHandler(db.Pool)
+	reservationHandler := api.NewReservationHandler(reservationClient)
+	bookingCalendar := compute.NewBookingCalendar(reservationClient, compute.BookingConfig{
+		LeadTime:      cfg.GPU.Reservations.BookingLeadTime,
+		DefaultPolicy: cfg.GPU.Reservations.BookingPolicy,
+		Horizon:       cfg.GPU.Reservations.BookingHorizon,
+	})
+	bookingHandler := api.NewBookingHandler(bookingCalendar)
+	gpuHandler.SetReservationClient(reservationClient)
+
+	// Reservation owners hear about expiry over the websocket
+	// ("reservation:<id>" topics) and webhooks
+	reservationClient.AddNotifier(compute.NotifierFunc(func(ctx context.Context, event compute.ReservationEvent) error {
+		wsHandler.Publish("reservation:"+event.ReservationID, event)
+		return nil
+	}))
+	wsHandler.SetTopicAuthorizer(func(ctx context.Context, userID uuid.UUID, topic string) bool {
+		reservationID, ok := strings.CutPrefix(topic, "reservation:")
+		if !ok {
+			return false
+		}
+		reservation, err := reservationClient.GetReservation(reservationID)
+		return err == nil && reservation.UserID == userID.String()
+	})
+	var reservationWebhooks []string
+	if cfg.GPU.Reservations.WebhookURL != "" {
+		reservationWebhooks = append(reservationWebhooks, cfg.GPU.Reservations.WebhookURL)
+	}
+	webhookPolicy := compute.NewWebhookPolicy(cfg.GPU.Reservations.WebhookAllowlist...)
+	reservationClient.SetWebhookPolicy(webhookPolicy)
+	webhookNotifier := compute.NewWebhookNotifier(reservationWebhooks...)
+	webhookNotifier.SetPolicy(webhookPolicy)
+	reservationClient.AddNotifier(webhookNotifier)
+
+	reservationCtx, stopReservations := context.WithCancel(context.Background())
+	defer stopReservations()
+	go reservationClient.RunReconciler(reservationCtx, cfg.GPU.Reservations.ReconcileInterval)
+	go reservationClient.RunQueue(reservationCtx, cfg.GPU.Reservations.ReconcileInterval)
+	expiryScheduler := compute.NewExpiryScheduler(reservationClient, compute.ExpiryConfig{
+		WarnBefore:  cfg.GPU.Reservations.WarnBefore,
+		IdleTimeout: cfg.GPU.Reservations.IdleTimeout,
+	}, lbService)
+	go expiryScheduler.Run(reservationCtx, cfg.GPU.Reservations.ReconcileInterval)
+	go bookingCalendar.Run(reservationCtx, cfg.GPU.Reservations.ReconcileInterval)
+	go gpuService.RunPriceHistory(reservationCtx, cfg.GPU.PriceHistory.Interval)
 
 	// Initialize model serving if enabled
 	var modelServeHandler *api.ModelServeHandler
@@ -158,12 +265,91 @@ func main() {
 		// Configure model registry storage path
 		modelRegistry := models.GetModelRegistry()
 		modelRegistry.SetStorageRoot(cfg.ModelServing.StoragePath)
+		if len(localDevices) > 0 {
+			modelRegistry.SetDeviceAllocator(deviceAllocator)
+		}
 
 		// Create model serving handler
 		modelServeHandler = api.NewModelServeHandler()
 		log.Printf("Model serving enabled. Storage path: %s", cfg.ModelServing.StoragePath)
 	}
 
+	// Initialize AIProxy routing if configured
+	var aiproxyCfg *config.AIProxyConfig
+	var aiproxyHandler *api.AIProxyHandler
+	var aiproxyRouter *aiproxy.Router
+	if aiproxyConfigPath != "" {
+		aiproxyCfg, err = config.LoadAIProxyConfig(aiproxyConfigPath)
+		if err != nil {
+			log.Fatalf("Failed to load AIProxy config: %v", err)
+		}
+
+		aiproxyRouter, err = aiproxy.NewRouter(aiproxyCfg)
+		if err != nil {
+			log.Fatalf("Failed to initialize AIProxy router: %v", err)
+		}
+
+		go aiproxyRouter.MonitorHealth(context.Background(), 30*time.Second)
+
+		if meshNode := aiproxyRouter.Mesh(); meshNode != nil {
+			if err := meshNode.Start(); err != nil {
+				log.Fatalf("Failed to start AIProxy mesh: %v", err)
+			}
+			log.Printf("AIProxy mesh listening on %s (%d peers)", aiproxyCfg.Node.Mesh.ListenAddr, len(aiproxyCfg.Node.Mesh.Peers))
+		}
+
+		aiproxyHandler = api.NewAIProxyHandler(aiproxyRouter, aiproxyCfg)
+		log.Printf("AIProxy enabled (node: %s, strategy: %s, providers: %s)",
+			aiproxyCfg.Node.ID, aiproxyCfg.Routing.Strategy, strings.Join(aiproxyCfg.GetEnabledProviders(), ", "))
+	}
+
+	// Health-aware load balancing: turned on by LB_HEALTH_AWARE or the AIProxy config
+	healthCfg := loadbalancer.HealthConfig{
+		Enabled:            cfg.LoadBalancer.HealthAware,
+		UnhealthyThreshold: cfg.LoadBalancer.UnhealthyThreshold,
+		SlowResponse:       cfg.LoadBalancer.SlowResponse,
+		BaseEjection:       cfg.LoadBalancer.EjectionTime,
+		MaxEjection:        cfg.LoadBalancer.MaxEjectionTime,
+		ProbeInterval:      cfg.LoadBalancer.ProbeInterval,
+		ProbeTimeout:       cfg.LoadBalancer.ProbeTimeout,
+	}
+	if aiproxyCfg != nil && aiproxyCfg.Routing.LoadBalancing.HealthAware {
+		healthCfg.Enabled = true
+		if t := aiproxyCfg.Routing.LoadBalancing.UnhealthyThreshold; t > 0 {
+			healthCfg.UnhealthyThreshold = t
+		}
+	}
+	lbService.SetHealthConfig(healthCfg)
+	if healthCfg.Enabled {
+		log.Printf("Health-aware load balancing enabled (threshold: %.2f, probe interval: %s)",
+			healthCfg.UnhealthyThreshold, healthCfg.ProbeInterval)
+	}
+
+	// Named pools inherit the settings above unless they override them
+	if cfg.LoadBalancer.PoolsFile != "" {
+		pools, err := loadbalancer.LoadPools(cfg.LoadBalancer.PoolsFile)
+		if err != nil {
+			log.Fatalf("Failed to load load balancer pools: %v", err)
+		}
+		for _, pool := range pools {
+			if err := lbService.AddPool(pool); err != nil {
+				log.Fatalf("Failed to add load balancer pool: %v", err)
+			}
+			log.Printf("Load balancer pool %s: %s (selector: %q)",
+				pool.Name, lbService.Pool(pool.Name).GetStrategy(), pool.Selector)
+		}
+	}
+	go lbService.RunHealthChecks(reservationCtx)
+
+	// Reserved instances are probed where their reservation says they answer
+	probeCfg := compute.ProbeConfig{Path: cfg.GPU.Reservations.ProbePath, URLs: make(map[compute.ComputeProvider]string)}
+	for provider, url := range cfg.GPU.Reservations.ProbeURLTemplates() {
+		probeCfg.URLs[compute.ComputeProvider(provider)] = url
+	}
+	if healthCfg.Enabled {
+		go reservationClient.RunProbeSync(reservationCtx, healthCfg.ProbeInterval, probeCfg, lbService.Health())
+	}
+
 	// Initialize structured logger
 	logLevel := logging.INFO
 	if debugMode {
@@ -175,6 +361,13 @@ func main() {
 	m := metrics.GetMetrics()
 	m.StartCollection(context.Background())
 
+	// Sample local GPUs for /metrics and /monitor
+	if cfg.GPU.TelemetryInterval > 0 {
+		gpuTelemetry := gpu.NewTelemetryCollector(gpu.NewSMITelemetry())
+		m.SetGPUTelemetry(gpuTelemetry)
+		go gpuTelemetry.Run(context.Background(), cfg.GPU.TelemetryInterval)
+	}
+
 	router := mux.NewRouter()
 
 	router.Use(middleware.Recovery)
@@ -212,6 +405,21 @@ func main() {
 	protected.HandleFunc("/gpu/instances/{provider}/{instanceId}", gpuHandler.DestroyInstance).Methods("DELETE")
 	protected.HandleFunc("/gpu/proxy", gpuHandler.ProxyRequest).Methods("POST")
 
+	// Compute reservation endpoints
+	protected.HandleFunc("/compute/reservations", reservationHandler.Reserve).Methods("POST")
+	protected.HandleFunc("/compute/reservations", reservationHandler.ListReservations).Methods("GET")
+	protected.HandleFunc("/compute/reservations/queue", reservationHandler.Queue).Methods("GET")
+	protected.HandleFunc("/compute/reservations/{id}", reservationHandler.GetReservation).Methods("GET")
+	protected.HandleFunc("/compute/reservations/{id}", reservationHandler.ReleaseReservation).Methods("DELETE")
+	protected.HandleFunc("/compute/reservations/{id}/extend", reservationHandler.ExtendReservation).Methods("POST")
+
+	// Advance booking endpoints
+	protected.HandleFunc("/compute/bookings", bookingHandler.Book).Methods("POST")
+	protected.HandleFunc("/compute/bookings", bookingHandler.ListBookings).Methods("GET")
+	protected.HandleFunc("/compute/bookings/calendar", bookingHandler.Calendar).Methods("GET")
+	protected.HandleFunc("/compute/bookings/{id}", bookingHandler.GetBooking).Methods("GET")
+	protected.HandleFunc("/compute/bookings/{id}", bookingHandler.CancelBooking).Methods("DELETE")
+
 	// GPU Preferences endpoints
 	protected.HandleFunc("/gpu/preferences", gpuPrefsHandler.GetPreferences).Methods("GET")
 	protected.HandleFunc("/gpu/preferences", gpuPrefsHandler.SetPreferences).Methods("POST")
@@ -219,6 +427,8 @@ func main() {
 	protected.HandleFunc("/gpu/available", gpuPrefsHandler.GetAvailableGPUs).Methods("GET")
 	protected.HandleFunc("/gpu/groups", gpuPrefsHandler.GetGPUGroups).Methods("GET")
 	protected.HandleFunc("/gpu/classify", gpuPrefsHandler.ClassifyGPU).Methods("GET")
+	protected.HandleFunc("/gpu/prices/trends", priceHandler.Trends).Methods("GET")
+	protected.HandleFunc("/gpu/prices/estimate", priceHandler.Estimate).Methods("GET")
 
 	// Public GPU info endpoints (no auth required)
 	apiRouter.HandleFunc("/gpu/examples", gpuPrefsHandler.GetExamplePreferences).Methods("GET")
@@ -274,8 +484,26 @@ func main() {
 		apiRouter.HandleFunc("/models/formats", modelServeHandler.SupportedFormats).Methods("GET")
 	}
 
+	// AIProxy endpoints (OpenAI-compatible + native), authenticated by the AIProxy security config
+	if aiproxyHandler != nil {
+		for _, ep := range aiproxyCfg.Server.Endpoints {
+			if !ep.Enabled {
+				continue
+			}
+			handler, method, err := aiproxyHandler.HandlerFor(ep)
+			if err != nil {
+				log.Fatalf("Invalid AIProxy endpoint: %v", err)
+			}
+			router.Handle(ep.Path, aiproxyHandler.Authenticate(handler)).Methods(method)
+			log.Printf("AIProxy endpoint: %s %s (%s)", method, ep.Path, ep.Protocol)
+		}
+		router.Handle("/aiproxy/status", aiproxyHandler.Authenticate(http.HandlerFunc(aiproxyHandler.Status))).Methods("GET")
+		router.Handle("/aiproxy/stream", aiproxyHandler.Authenticate(http.HandlerFunc(aiproxyHandler.StreamWebSocket))).Methods("GET")
+		router.Handle("/aiproxy/mesh/status", aiproxyHandler.Authenticate(http.HandlerFunc(aiproxyHandler.MeshStatus))).Methods("GET")
+	}
+
 	router.HandleFunc("/agent/discover", agentHandler.HandleAgentDiscovery).Methods("GET")
-	router.HandleFunc("/ws", wsHandler.HandleConnection)
+	router.Handle("/ws", authMiddleware.RequireAuth(http.HandlerFunc(wsHandler.HandleConnection)))
 
 	// Serve admin dashboard at /admin
 	router.PathPrefix("/admin").Handler(http.StripPrefix("/admin", http.FileServer(http.Dir("./web/admin"))))
@@ -285,6 +513,11 @@ func main() {
 
 	// Initialize gRPC server with IP access control
 	grpcSrv := grpcServer.NewServer(authService, gpuService, protocolHandler, billingService, lbService, cuicServer, ipAccessControl)
+	if aiproxyRouter != nil {
+		grpcSrv.SetAIProxyRouter(aiproxyRouter)
+	}
+	grpcSrv.SetBookingCalendar(bookingCalendar)
+	grpcSrv.SetReservationClient(reservationClient)
 
 	// Format address properly for IPv6 (needs brackets)
 	grpcHost := cfg.Server.Host
@@ -345,6 +578,12 @@ func main() {
 		log.Fatalf("HTTP server forced to shutdown: %v", err)
 	}
 
+	if aiproxyRouter != nil {
+		if err := aiproxyRouter.Close(); err != nil {
+			log.Printf("Failed to close AIProxy router: %v", err)
+		}
+	}
+
 	log.Println("Servers exited gracefully")
 }
 
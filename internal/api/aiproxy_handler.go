@@ -0,0 +1,630 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/providers"
+	"github.com/aiserve/gpuproxy/internal/router"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// AIProxyHandler exposes the AIProxy router through OpenAI-compatible and native endpoints
+type AIProxyHandler struct {
+	router    *router.Router
+	config    *config.AIProxyConfig
+	startTime time.Time
+}
+
+type aiproxyContextKey string
+
+// aiproxyKeyContextKey holds the config.APIKey that authenticated the request
+const aiproxyKeyContextKey aiproxyContextKey = "aiproxy_api_key"
+
+// ChatMessage is an OpenAI chat message. Content may be a string or an array of content parts.
+type ChatMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+	Name    string      `json:"name,omitempty"`
+}
+
+// ChatCompletionRequest is the OpenAI /v1/chat/completions request body
+type ChatCompletionRequest struct {
+	Model               string        `json:"model"`
+	Messages            []ChatMessage `json:"messages"`
+	MaxTokens           int           `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int           `json:"max_completion_tokens,omitempty"`
+	Temperature         float64       `json:"temperature,omitempty"`
+	TopP                float64       `json:"top_p,omitempty"`
+	Stop                interface{}   `json:"stop,omitempty"` // string or []string
+	Stream              bool          `json:"stream,omitempty"`
+	User                string        `json:"user,omitempty"`
+}
+
+// ChatCompletionResponse is the OpenAI /v1/chat/completions response body
+type ChatCompletionResponse struct {
+	ID       string                 `json:"id"`
+	Object   string                 `json:"object"`
+	Created  int64                  `json:"created"`
+	Model    string                 `json:"model"`
+	Choices  []ChatCompletionChoice `json:"choices"`
+	Usage    OpenAIUsage            `json:"usage"`
+	XAIProxy *AIProxyMetadata       `json:"x_aiproxy,omitempty"`
+}
+
+// ChatCompletionChoice is a single completion choice
+type ChatCompletionChoice struct {
+	Index        int         `json:"index"`
+	Message      ChatMessage `json:"message"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+// OpenAIUsage reports token usage in OpenAI format
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens,omitempty"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// EmbeddingRequest is the OpenAI /v1/embeddings request body
+type EmbeddingRequest struct {
+	Model string      `json:"model"`
+	Input interface{} `json:"input"` // string or []string
+	User  string      `json:"user,omitempty"`
+}
+
+// EmbeddingResponse is the OpenAI /v1/embeddings response body
+type EmbeddingResponse struct {
+	Object   string           `json:"object"`
+	Data     []EmbeddingData  `json:"data"`
+	Model    string           `json:"model"`
+	Usage    OpenAIUsage      `json:"usage"`
+	XAIProxy *AIProxyMetadata `json:"x_aiproxy,omitempty"`
+}
+
+// EmbeddingData is a single embedding vector
+type EmbeddingData struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"`
+	Embedding []float64 `json:"embedding"`
+}
+
+// ModelObject is an entry in the OpenAI /v1/models listing
+type ModelObject struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// AIProxyMetadata is returned as the x_aiproxy extension field on OpenAI responses
+type AIProxyMetadata struct {
+	providers.ResponseMetadata
+	RoutingReason string `json:"routing_reason,omitempty"`
+}
+
+func NewAIProxyHandler(r *router.Router, cfg *config.AIProxyConfig) *AIProxyHandler {
+	return &AIProxyHandler{
+		router:    r,
+		config:    cfg,
+		startTime: time.Now(),
+	}
+}
+
+// HandlerFor returns the handler and HTTP method serving a configured endpoint
+func (h *AIProxyHandler) HandlerFor(ep config.EndpointConfig) (http.HandlerFunc, string, error) {
+	switch ep.Protocol {
+	case "openai":
+		switch {
+		case strings.HasSuffix(ep.Path, "/chat/completions"):
+			return h.ChatCompletions, http.MethodPost, nil
+		case strings.HasSuffix(ep.Path, "/embeddings"):
+			return h.Embeddings, http.MethodPost, nil
+		case strings.HasSuffix(ep.Path, "/models"):
+			return h.ListModels, http.MethodGet, nil
+		}
+		return nil, "", fmt.Errorf("unsupported openai endpoint: %s", ep.Path)
+	case "native":
+		return h.Predict, http.MethodPost, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported endpoint protocol: %s", ep.Protocol)
+	}
+}
+
+// Authenticate enforces the API keys from the AIProxy security config.
+// Keys are accepted as "Authorization: Bearer <key>" (as sent by OpenAI SDKs), X-API-Key,
+// or an api_key query parameter on WebSocket handshakes.
+func (h *AIProxyHandler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authCfg := h.config.Security.Auth
+		if !authCfg.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if key == "" && websocket.IsWebSocketUpgrade(r) {
+			// Browsers cannot set headers on WebSocket handshakes
+			key = r.URL.Query().Get("api_key")
+		}
+
+		if key == "" {
+			respondOpenAIError(w, http.StatusUnauthorized, "invalid_request_error", "missing_api_key", "Missing API key")
+			return
+		}
+
+		for _, configured := range authCfg.APIKeys {
+			if configured.Key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(configured.Key)) == 1 {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), aiproxyKeyContextKey, configured)))
+				return
+			}
+		}
+
+		respondOpenAIError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "Invalid API key")
+	})
+}
+
+// userTier returns the tier of the API key that authenticated the request
+func userTier(r *http.Request) string {
+	if key, ok := r.Context().Value(aiproxyKeyContextKey).(config.APIKey); ok {
+		return key.Tier
+	}
+	return ""
+}
+
+// ChatCompletions serves POST /v1/chat/completions
+func (h *AIProxyHandler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid_json", "Invalid request body")
+		return
+	}
+
+	if req.Model == "" {
+		respondOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "missing_model", "model is required")
+		return
+	}
+	if len(req.Messages) == 0 {
+		respondOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "missing_messages", "messages must not be empty")
+		return
+	}
+	stop, err := parseStop(req.Stop)
+	if err != nil {
+		respondOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid_stop", err.Error())
+		return
+	}
+
+	maxTokens := req.MaxTokens
+	if req.MaxCompletionTokens > 0 {
+		maxTokens = req.MaxCompletionTokens
+	}
+
+	messages := make([]interface{}, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		messages = append(messages, map[string]interface{}{
+			"role":    msg.Role,
+			"content": messageText(msg.Content),
+		})
+	}
+
+	predictReq := &providers.PredictRequest{
+		Model:       req.Model,
+		Input:       messages,
+		MaxTokens:   maxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stop:        stop,
+		Stream:      req.Stream,
+		Capability:  providers.CapabilityTextGeneration,
+		UserTier:    userTier(r),
+	}
+
+	if req.Stream {
+		h.streamChatCompletion(w, r, req.Model, predictReq)
+		return
+	}
+
+	resp, decision, err := h.router.Predict(r.Context(), predictReq)
+	if err != nil {
+		respondRouterError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, ChatCompletionResponse{
+		ID:      "chatcmpl-" + uuid.New().String(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: []ChatCompletionChoice{
+			{
+				Index: 0,
+				Message: ChatMessage{
+					Role:    "assistant",
+					Content: outputText(resp.Output),
+				},
+				FinishReason: openAIFinishReason(resp.Metadata.FinishReason),
+			},
+		},
+		Usage: OpenAIUsage{
+			PromptTokens:     resp.Metadata.InputTokens,
+			CompletionTokens: resp.Metadata.OutputTokens,
+			TotalTokens:      resp.Metadata.TotalTokens,
+		},
+		XAIProxy: newAIProxyMetadata(resp, decision),
+	})
+}
+
+// Embeddings serves POST /v1/embeddings
+func (h *AIProxyHandler) Embeddings(w http.ResponseWriter, r *http.Request) {
+	var req EmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid_json", "Invalid request body")
+		return
+	}
+
+	if req.Model == "" {
+		respondOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "missing_model", "model is required")
+		return
+	}
+
+	var input interface{}
+	switch v := req.Input.(type) {
+	case string:
+		input = v
+	case []interface{}:
+		for _, item := range v {
+			if _, ok := item.(string); !ok {
+				respondOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid_input", "input must be a string or an array of strings")
+				return
+			}
+		}
+		input = v
+	default:
+		respondOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid_input", "input must be a string or an array of strings")
+		return
+	}
+
+	resp, decision, err := h.router.Predict(r.Context(), &providers.PredictRequest{
+		Model:      req.Model,
+		Input:      input,
+		Capability: providers.CapabilityEmbeddings,
+		UserTier:   userTier(r),
+	})
+	if err != nil {
+		respondRouterError(w, err)
+		return
+	}
+
+	vectors, err := embeddingVectors(resp.Output)
+	if err != nil {
+		respondOpenAIError(w, http.StatusBadGateway, "server_error", "invalid_provider_output", err.Error())
+		return
+	}
+
+	data := make([]EmbeddingData, 0, len(vectors))
+	for i, vector := range vectors {
+		data = append(data, EmbeddingData{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: vector,
+		})
+	}
+
+	respondJSON(w, http.StatusOK, EmbeddingResponse{
+		Object: "list",
+		Data:   data,
+		Model:  req.Model,
+		Usage: OpenAIUsage{
+			PromptTokens: resp.Metadata.InputTokens,
+			TotalTokens:  resp.Metadata.TotalTokens,
+		},
+		XAIProxy: newAIProxyMetadata(resp, decision),
+	})
+}
+
+// ListModels serves GET /v1/models
+func (h *AIProxyHandler) ListModels(w http.ResponseWriter, r *http.Request) {
+	providerMap := h.router.GetProviders()
+
+	names := make([]string, 0, len(providerMap))
+	for name := range providerMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	created := h.startTime.Unix()
+	seen := make(map[string]bool)
+	data := []ModelObject{}
+	for _, name := range names {
+		for _, model := range providerMap[name].GetModels() {
+			if seen[model] {
+				continue
+			}
+			seen[model] = true
+			data = append(data, ModelObject{
+				ID:      model,
+				Object:  "model",
+				Created: created,
+				OwnedBy: name,
+			})
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data":   data,
+	})
+}
+
+// Predict serves the native AIProxy endpoint, accepting a providers.PredictRequest as-is
+func (h *AIProxyHandler) Predict(w http.ResponseWriter, r *http.Request) {
+	var req providers.PredictRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if req.Model == "" {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "model is required"})
+		return
+	}
+	req.UserTier = userTier(r)
+
+	if req.Stream {
+		h.streamPredict(w, r, &req)
+		return
+	}
+
+	resp, decision, err := h.router.Predict(r.Context(), &req)
+	if err != nil {
+		respondJSON(w, routerErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"output":   resp.Output,
+		"metadata": resp.Metadata,
+		"routing":  decision,
+	})
+}
+
+// Status serves GET /aiproxy/status with node, provider and routing statistics
+func (h *AIProxyHandler) Status(w http.ResponseWriter, r *http.Request) {
+	stats := h.router.GetStats()
+
+	providerStatus := make(map[string]interface{})
+	for name, provider := range h.router.GetProviders() {
+		health := provider.Health(r.Context())
+
+		status := "healthy"
+		if !health.Healthy {
+			status = "unhealthy"
+		}
+
+		avgLatency := 0
+		if latencies := stats.ProviderLatency[name]; len(latencies) > 0 {
+			sum := 0
+			for _, l := range latencies {
+				sum += l
+			}
+			avgLatency = sum / len(latencies)
+		}
+
+		providerStatus[name] = map[string]interface{}{
+			"status":         status,
+			"type":           provider.Type(),
+			"models":         len(provider.GetModels()),
+			"requests":       stats.ProviderRequests[name],
+			"errors":         stats.ProviderErrors[name],
+			"avg_latency_ms": avgLatency,
+			"message":        health.Message,
+		}
+	}
+
+	budgetStatus, err := h.router.BudgetStatus(r.Context())
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"node": map[string]interface{}{
+			"id":     h.config.Node.ID,
+			"name":   h.config.Node.Name,
+			"region": h.config.Node.Region,
+			"type":   h.config.Node.Type,
+			"uptime": int64(time.Since(h.startTime).Seconds()),
+		},
+		"providers": providerStatus,
+		"stats": map[string]interface{}{
+			"total_requests": stats.TotalRequests,
+			"total_cost":     stats.TotalCost,
+		},
+		"routing": map[string]interface{}{
+			"strategy": h.config.Routing.Strategy,
+			"failover": h.config.Routing.Failover.Enabled,
+		},
+		"budget": budgetStatus,
+	})
+}
+
+// MeshStatus serves GET /aiproxy/mesh/status with this node's view of its mesh peers
+func (h *AIProxyHandler) MeshStatus(w http.ResponseWriter, r *http.Request) {
+	node := h.router.Mesh()
+	if node == nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "mesh networking is not enabled"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, node.Status(r.Context()))
+}
+
+func newAIProxyMetadata(resp *providers.PredictResponse, decision *router.RoutingDecision) *AIProxyMetadata {
+	meta := &AIProxyMetadata{ResponseMetadata: resp.Metadata}
+	if decision != nil {
+		meta.RoutingReason = decision.Reason
+	}
+	return meta
+}
+
+// parseStop accepts the OpenAI "stop" field as either a string or an array of strings
+func parseStop(stop interface{}) ([]string, error) {
+	switch v := stop.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("stop must be a string or an array of strings")
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("stop must be a string or an array of strings")
+	}
+}
+
+// messageText flattens OpenAI message content (string or content parts) into plain text
+func messageText(content interface{}) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var parts []string
+		for _, item := range v {
+			if part, ok := item.(map[string]interface{}); ok {
+				if text, ok := part["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// outputText converts a provider output into assistant message text
+func outputText(output interface{}) string {
+	switch v := output.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+}
+
+// embeddingVectors normalizes provider embedding output into a list of vectors
+func embeddingVectors(output interface{}) ([][]float64, error) {
+	switch v := output.(type) {
+	case [][]float64:
+		return v, nil
+	case []float64:
+		return [][]float64{v}, nil
+	case []interface{}:
+		if len(v) == 0 {
+			return [][]float64{}, nil
+		}
+		if _, ok := v[0].([]interface{}); !ok {
+			vector, err := toFloatSlice(v)
+			if err != nil {
+				return nil, err
+			}
+			return [][]float64{vector}, nil
+		}
+		vectors := make([][]float64, 0, len(v))
+		for _, item := range v {
+			values, ok := item.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("provider returned a malformed embedding")
+			}
+			vector, err := toFloatSlice(values)
+			if err != nil {
+				return nil, err
+			}
+			vectors = append(vectors, vector)
+		}
+		return vectors, nil
+	default:
+		return nil, fmt.Errorf("provider did not return embeddings")
+	}
+}
+
+func toFloatSlice(values []interface{}) ([]float64, error) {
+	vector := make([]float64, 0, len(values))
+	for _, value := range values {
+		f, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("provider returned a non-numeric embedding value")
+		}
+		vector = append(vector, f)
+	}
+	return vector, nil
+}
+
+func routerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, router.ErrNoAvailableProvider):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, router.ErrAllProvidersFailed):
+		return http.StatusBadGateway
+	case errors.Is(err, router.ErrBudgetExceeded):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func respondRouterError(w http.ResponseWriter, err error) {
+	status := routerErrorStatus(err)
+
+	code := "internal_error"
+	switch status {
+	case http.StatusServiceUnavailable:
+		code = "model_unavailable"
+	case http.StatusBadGateway:
+		code = "upstream_error"
+	case http.StatusTooManyRequests:
+		// Matches the error OpenAI returns when an account is out of quota
+		respondOpenAIError(w, status, "insufficient_quota", "budget_exceeded", err.Error())
+		return
+	}
+
+	respondOpenAIError(w, status, "server_error", code, err.Error())
+}
+
+// respondOpenAIError writes an error in the OpenAI wire format
+func respondOpenAIError(w http.ResponseWriter, status int, errType, code, message string) {
+	respondJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    errType,
+			"code":    code,
+		},
+	})
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/providers"
+	"github.com/aiserve/gpuproxy/internal/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestAIProxyHandler serves the handler's endpoints in front of a router
+// whose only provider is OpenAI, itself pointed at a fake upstream
+func newTestAIProxyHandler(t *testing.T) http.Handler {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"choices": []map[string]interface{}{{"index": 0, "message": map[string]string{"role": "assistant", "content": "Hi there"}, "finish_reason": "length"}},
+				"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+			})
+		case "/v1/embeddings":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data":  []map[string]interface{}{{"index": 0, "embedding": []float64{0.1, 0.2}}, {"index": 1, "embedding": []float64{0.3, 0.4}}},
+				"usage": map[string]int{"prompt_tokens": 4, "total_tokens": 4},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	cfg := &config.AIProxyConfig{}
+	cfg.Routing.Strategy = "availability"
+	cfg.Routing.Failover.MaxRetries = 1
+	cfg.Providers.OpenAI = &config.OpenAIProviderConfig{
+		Enabled:     true,
+		Priority:    1,
+		Credentials: config.OpenAICredentials{APIKey: "sk-test"},
+		Endpoint:    upstream.URL + "/v1",
+		Models: []config.ModelConfig{
+			{Name: "gpt-4o-mini", Capabilities: []string{providers.CapabilityTextGeneration}, CostPer1kTokens: 1},
+			{Name: "text-embed", OpenAIModel: "text-embedding-3-small", Capabilities: []string{providers.CapabilityEmbeddings}},
+		},
+	}
+	cfg.Security.Auth = config.AIProxyAuthConfig{Enabled: true, APIKeys: []config.APIKey{{Key: "gp-test", Name: "test"}}}
+
+	r, err := router.NewRouter(cfg)
+	require.NoError(t, err)
+	h := NewAIProxyHandler(r, cfg)
+
+	mux := http.NewServeMux()
+	for _, ep := range []config.EndpointConfig{
+		{Path: "/v1/chat/completions", Protocol: "openai"},
+		{Path: "/v1/embeddings", Protocol: "openai"},
+		{Path: "/v1/models", Protocol: "openai"},
+		{Path: "/aiproxy/predict", Protocol: "native"},
+	} {
+		handler, method, err := h.HandlerFor(ep)
+		require.NoError(t, err)
+		mux.Handle(method+" "+ep.Path, h.Authenticate(handler))
+	}
+	return mux
+}
+
+func doAIProxy(t *testing.T, h http.Handler, method, path string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Authorization", "Bearer gp-test")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp), rec.Body.String())
+	return rec, resp
+}
+
+func TestAIProxyAuthenticate(t *testing.T) {
+	h := newTestAIProxyHandler(t)
+
+	for name, header := range map[string][2]string{
+		"missing": {"", ""},
+		"invalid": {"Authorization", "Bearer wrong"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		if header[0] != "" {
+			req.Header.Set(header[0], header[1])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, name)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.Header.Set("X-API-Key", "gp-test")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAIProxyChatCompletions(t *testing.T) {
+	h := newTestAIProxyHandler(t)
+
+	rec, resp := doAIProxy(t, h, http.MethodPost, "/v1/chat/completions", map[string]interface{}{
+		"model":    "gpt-4o-mini",
+		"messages": []map[string]string{{"role": "user", "content": "Hello"}},
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Equal(t, "chat.completion", resp["object"])
+	choice := resp["choices"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Hi there", choice["message"].(map[string]interface{})["content"])
+	assert.Equal(t, "length", choice["finish_reason"], "the provider's reason is passed on")
+	assert.Equal(t, 15.0, resp["usage"].(map[string]interface{})["total_tokens"])
+
+	meta := resp["x_aiproxy"].(map[string]interface{})
+	assert.Equal(t, "openai", meta["provider"])
+	assert.InDelta(t, 0.015, meta["cost"], 1e-9)
+}
+
+func TestAIProxyChatCompletionsValidation(t *testing.T) {
+	h := newTestAIProxyHandler(t)
+
+	for name, body := range map[string]map[string]interface{}{
+		"no model":    {"messages": []map[string]string{{"role": "user", "content": "Hi"}}},
+		"no messages": {"model": "gpt-4o-mini"},
+		"bad stop":    {"model": "gpt-4o-mini", "messages": []map[string]string{{"role": "user", "content": "Hi"}}, "stop": 3},
+	} {
+		rec, resp := doAIProxy(t, h, http.MethodPost, "/v1/chat/completions", body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, name)
+		assert.Equal(t, "invalid_request_error", resp["error"].(map[string]interface{})["type"], name)
+	}
+
+	rec, resp := doAIProxy(t, h, http.MethodPost, "/v1/chat/completions", map[string]interface{}{
+		"model":    "no-such-model",
+		"messages": []map[string]string{{"role": "user", "content": "Hi"}},
+	})
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "model_unavailable", resp["error"].(map[string]interface{})["code"])
+}
+
+func TestAIProxyEmbeddings(t *testing.T) {
+	h := newTestAIProxyHandler(t)
+
+	rec, resp := doAIProxy(t, h, http.MethodPost, "/v1/embeddings", map[string]interface{}{
+		"model": "text-embed",
+		"input": []string{"a", "b"},
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	data := resp["data"].([]interface{})
+	require.Len(t, data, 2)
+	assert.Equal(t, []interface{}{0.3, 0.4}, data[1].(map[string]interface{})["embedding"])
+	assert.Equal(t, 4.0, resp["usage"].(map[string]interface{})["prompt_tokens"])
+
+	rec, _ = doAIProxy(t, h, http.MethodPost, "/v1/embeddings", map[string]interface{}{"model": "text-embed", "input": []int{1}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAIProxyListModels(t *testing.T) {
+	h := newTestAIProxyHandler(t)
+
+	rec, resp := doAIProxy(t, h, http.MethodGet, "/v1/models", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "list", resp["object"])
+
+	var ids []string
+	for _, m := range resp["data"].([]interface{}) {
+		model := m.(map[string]interface{})
+		assert.Equal(t, "openai", model["owned_by"])
+		ids = append(ids, model["id"].(string))
+	}
+	assert.Equal(t, []string{"gpt-4o-mini", "text-embed"}, ids)
+}
+
+func TestAIProxyPredict(t *testing.T) {
+	h := newTestAIProxyHandler(t)
+
+	rec, resp := doAIProxy(t, h, http.MethodPost, "/aiproxy/predict", map[string]interface{}{
+		"model": "gpt-4o-mini",
+		"input": "Hello",
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "Hi there", resp["output"])
+	assert.Equal(t, "openai", resp["metadata"].(map[string]interface{})["provider"])
+	assert.NotNil(t, resp["routing"])
+
+	rec, _ = doAIProxy(t, h, http.MethodPost, "/aiproxy/predict", map[string]interface{}{"input": "Hello"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAIProxyHandlerFor(t *testing.T) {
+	h := NewAIProxyHandler(nil, &config.AIProxyConfig{})
+
+	_, method, err := h.HandlerFor(config.EndpointConfig{Path: "/v1/models", Protocol: "openai"})
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodGet, method)
+
+	_, _, err = h.HandlerFor(config.EndpointConfig{Path: "/v1/audio", Protocol: "openai"})
+	assert.Error(t, err)
+	_, _, err = h.HandlerFor(config.EndpointConfig{Path: "/x", Protocol: "grpc"})
+	assert.Error(t, err)
+}
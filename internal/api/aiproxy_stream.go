@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/providers"
+	"github.com/aiserve/gpuproxy/internal/router"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// ChatCompletionChunk is a streamed OpenAI chat.completion.chunk event. The
+// terminal chunk also carries usage and the x_aiproxy metadata.
+type ChatCompletionChunk struct {
+	ID       string                      `json:"id"`
+	Object   string                      `json:"object"`
+	Created  int64                       `json:"created"`
+	Model    string                      `json:"model"`
+	Choices  []ChatCompletionChunkChoice `json:"choices"`
+	Usage    *OpenAIUsage                `json:"usage,omitempty"`
+	XAIProxy *AIProxyMetadata            `json:"x_aiproxy,omitempty"`
+}
+
+// ChatCompletionChunkChoice is a single choice of a streamed chunk
+type ChatCompletionChunkChoice struct {
+	Index        int       `json:"index"`
+	Delta        ChatDelta `json:"delta"`
+	FinishReason *string   `json:"finish_reason"`
+}
+
+// ChatDelta is the incremental message content of a streamed chunk
+type ChatDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// sseWriter writes server-sent events and flushes after each one
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// newSSEWriter starts a text/event-stream response
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming is not supported by this connection")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &sseWriter{w: w, flusher: flusher}, nil
+}
+
+// send writes a JSON encoded event. An empty name writes an unnamed event.
+func (s *sseWriter) send(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.sendRaw(name, string(data))
+}
+
+// sendRaw writes an event with a preformatted data payload
+func (s *sseWriter) sendRaw(name, data string) error {
+	if name != "" {
+		if _, err := fmt.Fprintf(s.w, "event: %s\n", name); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// streamChatCompletion serves a stream=true chat completion as OpenAI-style SSE
+func (h *AIProxyHandler) streamChatCompletion(w http.ResponseWriter, r *http.Request, model string, predictReq *providers.PredictRequest) {
+	stream, decision, err := h.router.PredictStream(r.Context(), predictReq)
+	if err != nil {
+		respondRouterError(w, err)
+		return
+	}
+
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		respondOpenAIError(w, http.StatusInternalServerError, "server_error", "stream_unsupported", err.Error())
+		return
+	}
+
+	base := ChatCompletionChunk{
+		ID:      "chatcmpl-" + uuid.New().String(),
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   model,
+	}
+
+	role := "assistant"
+	for chunk := range stream {
+		if chunk.Err != nil {
+			sse.send("", map[string]interface{}{
+				"error": map[string]interface{}{
+					"message": chunk.Err.Error(),
+					"type":    "server_error",
+					"code":    "upstream_error",
+				},
+			})
+			return
+		}
+
+		event := base
+		if !chunk.Done {
+			event.Choices = []ChatCompletionChunkChoice{{Delta: ChatDelta{Role: role, Content: chunk.Delta}}}
+			role = ""
+			if err := sse.send("", event); err != nil {
+				return
+			}
+			continue
+		}
+
+		finishReason := openAIFinishReason(chunk.FinishReason)
+		event.Choices = []ChatCompletionChunkChoice{{Delta: ChatDelta{Role: role}, FinishReason: &finishReason}}
+		if chunk.Metadata != nil {
+			event.Usage = &OpenAIUsage{
+				PromptTokens:     chunk.Metadata.InputTokens,
+				CompletionTokens: chunk.Metadata.OutputTokens,
+				TotalTokens:      chunk.Metadata.TotalTokens,
+			}
+			event.XAIProxy = newAIProxyMetadata(&providers.PredictResponse{Metadata: *chunk.Metadata}, decision)
+		}
+		if err := sse.send("", event); err != nil {
+			return
+		}
+		sse.sendRaw("", "[DONE]")
+		return
+	}
+}
+
+// streamPredict serves a native stream=true prediction as SSE with delta, done and error events
+func (h *AIProxyHandler) streamPredict(w http.ResponseWriter, r *http.Request, req *providers.PredictRequest) {
+	stream, decision, err := h.router.PredictStream(r.Context(), req)
+	if err != nil {
+		respondJSON(w, routerErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	router.EmitStreamEvents(stream, decision, func(event router.StreamEvent) error {
+		return sse.send(event.Type, event)
+	})
+}
+
+// StreamWebSocket serves GET /aiproxy/stream. Each text frame from the client is a
+// providers.PredictRequest; the reply is a sequence of router.StreamEvent frames
+// ending with a done or error event.
+func (h *AIProxyHandler) StreamWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("AIProxy WebSocket upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	for {
+		var req providers.PredictRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("AIProxy WebSocket error: %v", err)
+			}
+			return
+		}
+
+		if req.Model == "" {
+			if err := conn.WriteJSON(router.StreamEvent{Type: "error", Error: "model is required"}); err != nil {
+				return
+			}
+			continue
+		}
+		req.UserTier = userTier(r)
+
+		stream, decision, err := h.router.PredictStream(ctx, &req)
+		if err != nil {
+			if err := conn.WriteJSON(router.StreamEvent{Type: "error", Error: err.Error(), Routing: decision}); err != nil {
+				return
+			}
+			continue
+		}
+
+		err = router.EmitStreamEvents(stream, decision, func(event router.StreamEvent) error {
+			return conn.WriteJSON(event)
+		})
+		if err != nil {
+			// The client went away; cancelling stops the upstream request
+			return
+		}
+	}
+}
+
+// openAIFinishReason maps provider stop reasons onto OpenAI finish_reason values
+func openAIFinishReason(reason string) string {
+	switch reason {
+	case "", "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return reason
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/compute"
+	"github.com/aiserve/gpuproxy/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+type BookingHandler struct {
+	calendar *compute.BookingCalendar
+}
+
+func NewBookingHandler(calendar *compute.BookingCalendar) *BookingHandler {
+	return &BookingHandler{calendar: calendar}
+}
+
+// Book sets capacity aside for the caller over a future window
+func (h *BookingHandler) Book(w http.ResponseWriter, r *http.Request) {
+	var req compute.BookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	userID := middleware.GetUserID(r.Context()).String()
+
+	if err := h.calendar.CheckPriority(&req, isAdmin(r)); err != nil {
+		respondError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	booking, err := h.calendar.Book(r.Context(), userID, &req)
+	if errors.Is(err, compute.ErrBookingConflict) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, booking)
+}
+
+// ListBookings returns the caller's bookings
+func (h *BookingHandler) ListBookings(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context()).String()
+
+	bookings, err := h.calendar.UserBookings(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"bookings": bookings,
+		"count":    len(bookings),
+	})
+}
+
+// GetBooking returns one of the caller's bookings
+func (h *BookingHandler) GetBooking(w http.ResponseWriter, r *http.Request) {
+	booking, ok := h.owned(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, booking)
+}
+
+// CancelBooking cancels one of the caller's bookings under its cancellation policy
+func (h *BookingHandler) CancelBooking(w http.ResponseWriter, r *http.Request) {
+	booking, ok := h.owned(w, r)
+	if !ok {
+		return
+	}
+
+	canceled, err := h.calendar.Cancel(r.Context(), booking.ID)
+	if err != nil && canceled == nil {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, canceled)
+}
+
+// Calendar returns booked and available capacity over a time range. The
+// range defaults to the next week; from and to are RFC 3339 timestamps.
+func (h *BookingHandler) Calendar(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	from := time.Now()
+	if v := query.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "from must be an RFC 3339 timestamp")
+			return
+		}
+		from = t
+	}
+	to := from.Add(7 * 24 * time.Hour)
+	if v := query.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "to must be an RFC 3339 timestamp")
+			return
+		}
+		to = t
+	}
+	userID := middleware.GetUserID(r.Context()).String()
+
+	view, err := h.calendar.Calendar(r.Context(), compute.ComputeType(query.Get("compute_type")), from, to, userID)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, view)
+}
+
+// owned loads the booking named in the path, answering 404 unless the
+// caller owns it
+func (h *BookingHandler) owned(w http.ResponseWriter, r *http.Request) (*compute.Booking, bool) {
+	bookingID := mux.Vars(r)["id"]
+
+	booking, err := h.calendar.Get(r.Context(), bookingID)
+	if errors.Is(err, compute.ErrBookingNotFound) {
+		respondError(w, http.StatusNotFound, "Booking not found")
+		return nil, false
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if booking.UserID != middleware.GetUserID(r.Context()).String() {
+		respondError(w, http.StatusNotFound, "Booking not found")
+		return nil, false
+	}
+	return booking, true
+}
@@ -1,12 +1,20 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
+	"strconv"
+	"time"
 
+	"github.com/aiserve/gpuproxy/internal/compute"
 	"github.com/aiserve/gpuproxy/internal/gpu"
 	"github.com/aiserve/gpuproxy/internal/loadbalancer"
+	"github.com/aiserve/gpuproxy/internal/middleware"
 	"github.com/aiserve/gpuproxy/internal/models"
 	"github.com/gorilla/mux"
 )
@@ -15,6 +23,7 @@ type GPUHandler struct {
 	gpuService      *gpu.Service
 	protocolHandler *gpu.ProtocolHandler
 	lbService       *loadbalancer.LoadBalancerService
+	reservations    *compute.ReservationClient
 }
 
 func NewGPUHandler(gpuService *gpu.Service, protocolHandler *gpu.ProtocolHandler, lbService *loadbalancer.LoadBalancerService) *GPUHandler {
@@ -25,6 +34,12 @@ func NewGPUHandler(gpuService *gpu.Service, protocolHandler *gpu.ProtocolHandler
 	}
 }
 
+// SetReservationClient lets proxied requests to a caller's reserved
+// instance count towards the reservation's idle expiry
+func (h *GPUHandler) SetReservationClient(c *compute.ReservationClient) {
+	h.reservations = c
+}
+
 func (h *GPUHandler) ListInstances(w http.ResponseWriter, r *http.Request) {
 	providerParam := r.URL.Query().Get("provider")
 	if providerParam == "" {
@@ -33,6 +48,12 @@ func (h *GPUHandler) ListInstances(w http.ResponseWriter, r *http.Request) {
 
 	provider := gpu.Provider(providerParam)
 
+	query, err := gpu.ParseOfferQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	instances, err := h.gpuService.ListInstances(r.Context(), provider)
 	if err != nil {
 		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -41,14 +62,12 @@ func (h *GPUHandler) ListInstances(w http.ResponseWriter, r *http.Request) {
 
 	filters := make(map[string]interface{})
 	if minVRAM := r.URL.Query().Get("min_vram"); minVRAM != "" {
-		var vram int
-		if _, err := json.Marshal(minVRAM); err == nil {
+		if vram, err := strconv.Atoi(minVRAM); err == nil {
 			filters["min_vram"] = vram
 		}
 	}
 	if maxPrice := r.URL.Query().Get("max_price"); maxPrice != "" {
-		var price float64
-		if _, err := json.Marshal(maxPrice); err == nil {
+		if price, err := strconv.ParseFloat(maxPrice, 64); err == nil {
 			filters["max_price"] = price
 		}
 	}
@@ -59,14 +78,16 @@ func (h *GPUHandler) ListInstances(w http.ResponseWriter, r *http.Request) {
 		filters["location"] = location
 	}
 
-	if len(filters) > 0 {
-		instances = h.gpuService.FilterInstances(instances, filters)
+	query = query.And(gpu.FiltersQuery(filters))
+	if !query.Empty() {
+		instances = query.Apply(instances)
 	}
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"instances": instances,
 		"count":     len(instances),
 		"provider":  provider,
+		"query":     query.String(),
 	})
 }
 
@@ -115,20 +136,44 @@ func (h *GPUHandler) ProxyRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The instance ID comes from the client, so only requests to the
+	// caller's own reservation are tracked, for idleness, latency and health
+	done := func(time.Duration, error) {}
+	if reserved := h.reservedInstance(r, proxyReq.InstanceID); reserved != "" {
+		done = h.lbService.TrackRequest(reserved)
+	}
+
+	start := time.Now()
 	resp, err := h.protocolHandler.ProxyRequest(r.Context(), &proxyReq)
 	if err != nil {
+		done(time.Since(start), err)
 		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	done(resp.Duration, resp.InstanceFailure())
 
 	respondJSON(w, http.StatusOK, resp)
 }
 
+// reservedInstance returns instanceID if it runs one of the caller's active
+// reservations, whose load the expiry scheduler watches for idleness
+func (h *GPUHandler) reservedInstance(r *http.Request, instanceID string) string {
+	if h.reservations == nil || h.lbService == nil || instanceID == "" {
+		return ""
+	}
+	reservation, err := h.reservations.UserInstance(r.Context(), middleware.GetUserID(r.Context()).String(), instanceID)
+	if err != nil {
+		return ""
+	}
+	return reservation.InstanceID
+}
+
 func (h *GPUHandler) BatchCreateInstances(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		VastAICount int                    `json:"vastai_count"`
 		IONetCount  int                    `json:"ionet_count"`
 		Config      map[string]interface{} `json:"config"`
+		gangOptions
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -148,6 +193,11 @@ func (h *GPUHandler) BatchCreateInstances(w http.ResponseWriter, r *http.Request
 		req.IONetCount = 1
 	}
 
+	if req.Gang {
+		h.batchCreateGang(w, r, req.VastAICount, req.IONetCount, req.Config, req.gangOptions)
+		return
+	}
+
 	vastInstances := []string{}
 	ionetInstances := []string{}
 	errors := []string{}
@@ -158,8 +208,7 @@ func (h *GPUHandler) BatchCreateInstances(w http.ResponseWriter, r *http.Request
 			errors = append(errors, fmt.Sprintf("vast.ai list error: %v", err))
 		} else {
 			for i := 0; i < req.VastAICount && i < len(instances); i++ {
-				instanceID := instances[i].ID[5:]
-				contractID, err := h.gpuService.CreateInstance(r.Context(), gpu.ProviderVastAI, instanceID, req.Config)
+				contractID, err := h.gpuService.CreateInstance(r.Context(), gpu.ProviderVastAI, instances[i].ID, req.Config)
 				if err != nil {
 					errors = append(errors, fmt.Sprintf("vast.ai create error: %v", err))
 				} else {
@@ -175,8 +224,7 @@ func (h *GPUHandler) BatchCreateInstances(w http.ResponseWriter, r *http.Request
 			errors = append(errors, fmt.Sprintf("io.net list error: %v", err))
 		} else {
 			for i := 0; i < req.IONetCount && i < len(instances); i++ {
-				instanceID := instances[i].ID[6:]
-				contractID, err := h.gpuService.CreateInstance(r.Context(), gpu.ProviderIONet, instanceID, req.Config)
+				contractID, err := h.gpuService.CreateInstance(r.Context(), gpu.ProviderIONet, instances[i].ID, req.Config)
 				if err != nil {
 					errors = append(errors, fmt.Sprintf("io.net create error: %v", err))
 				} else {
@@ -199,9 +247,12 @@ func (h *GPUHandler) ReserveInstances(w http.ResponseWriter, r *http.Request) {
 		Count   int                    `json:"count"`
 		Filters map[string]interface{} `json:"filters"`
 		Config  map[string]interface{} `json:"config"`
+		Pool    string                 `json:"pool"` // Optional: reserve from a named load balancer pool
+		gangOptions
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || json.Unmarshal(body, &req) != nil {
 		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 		return
 	}
@@ -211,6 +262,39 @@ func (h *GPUHandler) ReserveInstances(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Pool != "" && (req.Gang || h.lbService == nil) {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "pool requires load balancing and cannot be used with gang reservations"})
+		return
+	}
+
+	if req.Gang {
+		gangReq, err := req.gangRequest(req.Count, gpu.ProviderAll, req.Filters, req.Config)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		gang, ok := h.reserveGang(w, r, gangReq)
+		if !ok {
+			return
+		}
+
+		reserved := []map[string]interface{}{}
+		for _, m := range gang.Members {
+			if h.lbService != nil {
+				h.lbService.TrackConnection(m.OfferID)
+			}
+			reserved = append(reserved, gangMemberJSON(m))
+		}
+		respondJSON(w, http.StatusCreated, map[string]interface{}{
+			"reserved":       reserved,
+			"count":          len(reserved),
+			"requested":      req.Count,
+			"price_per_hour": gang.PricePerHour,
+			"errors":         []string{},
+		})
+		return
+	}
+
 	instances, err := h.gpuService.ListInstances(r.Context(), gpu.ProviderAll)
 	if err != nil {
 		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -221,6 +305,14 @@ func (h *GPUHandler) ReserveInstances(w http.ResponseWriter, r *http.Request) {
 		instances = h.gpuService.FilterInstances(instances, req.Filters)
 	}
 
+	if req.Pool != "" {
+		instances, err = h.lbService.PoolMembers(req.Pool, instances)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
 	if len(instances) < req.Count {
 		respondJSON(w, http.StatusBadRequest, map[string]string{
 			"error": fmt.Sprintf("Not enough instances available. Requested: %d, Available: %d", req.Count, len(instances)),
@@ -230,30 +322,32 @@ func (h *GPUHandler) ReserveInstances(w http.ResponseWriter, r *http.Request) {
 
 	reserved := []map[string]interface{}{}
 	errors := []string{}
+	selectCtx := r.Context()
+	if h.lbService != nil {
+		selectCtx = loadbalancer.WithPool(h.affinityContext(r, body, req.Pool), req.Pool)
+	}
 
-	for i := 0; i < req.Count && i < len(instances); i++ {
-		var selected *models.GPUInstance
+	remaining := append([]models.GPUInstance(nil), instances...)
+	for i := 0; i < req.Count && len(remaining) > 0; i++ {
+		selected := remaining[0]
 
+		// Only the first instance is pinned to the affinity key, so the
+		// rest spread out
 		if h.lbService != nil {
-			selected, err = h.lbService.SelectInstance(r.Context(), instances[i:])
-			if err != nil {
-				selected = &instances[i]
+			if picked, err := h.lbService.SelectInstance(selectCtx, remaining); err == nil {
+				selected = *picked
 			}
-		} else {
-			selected = &instances[i]
+			selectCtx = loadbalancer.WithoutAffinityKey(selectCtx)
 		}
+		remaining = slices.DeleteFunc(remaining, func(inst models.GPUInstance) bool { return inst.ID == selected.ID })
 
 		provider := gpu.Provider(selected.Provider)
-		instanceID := selected.ID
-		if provider == gpu.ProviderVastAI && len(instanceID) > 5 {
-			instanceID = instanceID[5:]
-		} else if provider == gpu.ProviderIONet && len(instanceID) > 6 {
-			instanceID = instanceID[6:]
-		}
-
-		contractID, err := h.gpuService.CreateInstance(r.Context(), provider, instanceID, req.Config)
+		contractID, err := h.gpuService.CreateInstance(r.Context(), provider, selected.ID, req.Config)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("%s: %v", selected.ID, err))
+			if h.lbService != nil {
+				h.lbService.TrackFailure(selected.ID, err)
+			}
 		} else {
 			if h.lbService != nil {
 				h.lbService.TrackConnection(selected.ID)
@@ -278,3 +372,143 @@ func (h *GPUHandler) ReserveInstances(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+
+// gangOptions are the request fields that make instance creation
+// all-or-nothing
+type gangOptions struct {
+	Gang           bool   `json:"gang"`
+	SameProvider   bool   `json:"same_provider"`
+	SameRegion     bool   `json:"same_region"`
+	SameDatacenter bool   `json:"same_datacenter"`
+	ReadyTimeout   string `json:"ready_timeout"` // e.g. "10m"; empty skips waiting for members to run
+}
+
+func (o gangOptions) gangRequest(count int, provider gpu.Provider, filters, config map[string]interface{}) (*gpu.GangRequest, error) {
+	req := &gpu.GangRequest{
+		Count:          count,
+		Provider:       provider,
+		Filters:        filters,
+		Config:         config,
+		SameProvider:   o.SameProvider,
+		SameRegion:     o.SameRegion,
+		SameDatacenter: o.SameDatacenter,
+	}
+	if o.ReadyTimeout != "" {
+		d, err := time.ParseDuration(o.ReadyTimeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("ready_timeout must be a positive duration such as 10m")
+		}
+		req.ReadyTimeout = d
+	}
+	return req, nil
+}
+
+// reserveGang provisions a gang, answering with the failure and what was
+// rolled back if it cannot be provisioned
+func (h *GPUHandler) reserveGang(w http.ResponseWriter, r *http.Request, req *gpu.GangRequest) (*gpu.GangResult, bool) {
+	gang, err := h.gpuService.ReserveGang(r.Context(), req)
+	if err != nil {
+		respondGangError(w, err)
+		return nil, false
+	}
+	return gang, true
+}
+
+// batchCreateGang creates the Vast.ai and IO.net instances of a batch as one
+// gang per provider, destroying both if either cannot be completed
+func (h *GPUHandler) batchCreateGang(w http.ResponseWriter, r *http.Request, vastAICount, ionetCount int, config map[string]interface{}, opts gangOptions) {
+	var gangs []*gpu.GangResult
+	instances := map[gpu.Provider][]string{gpu.ProviderVastAI: {}, gpu.ProviderIONet: {}}
+
+	for _, part := range []struct {
+		provider gpu.Provider
+		count    int
+	}{{gpu.ProviderVastAI, vastAICount}, {gpu.ProviderIONet, ionetCount}} {
+		if part.count == 0 {
+			continue
+		}
+
+		req, err := opts.gangRequest(part.count, part.provider, nil, config)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		gang, err := h.gpuService.ReserveGang(r.Context(), req)
+		if err != nil {
+			for _, created := range gangs {
+				if releaseErr := h.gpuService.ReleaseGang(context.WithoutCancel(r.Context()), created); releaseErr != nil {
+					respondGangError(w, releaseErr)
+					return
+				}
+			}
+			respondGangError(w, fmt.Errorf("%s: %w", part.provider, err))
+			return
+		}
+
+		gangs = append(gangs, gang)
+		for _, m := range gang.Members {
+			instances[part.provider] = append(instances[part.provider], m.InstanceID)
+		}
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"vastai_instances": instances[gpu.ProviderVastAI],
+		"ionet_instances":  instances[gpu.ProviderIONet],
+		"total_created":    len(instances[gpu.ProviderVastAI]) + len(instances[gpu.ProviderIONet]),
+		"errors":           []string{},
+	})
+}
+
+func gangMemberJSON(m gpu.GangMember) map[string]interface{} {
+	return map[string]interface{}{
+		"instance_id": m.OfferID,
+		"contract_id": m.InstanceID,
+		"provider":    m.Provider,
+		"gpu_model":   m.GPUName,
+		"vram":        m.VRAM,
+		"price":       m.PricePerHour,
+		"region":      m.Region,
+		"datacenter":  m.Datacenter,
+	}
+}
+
+// respondGangError reports a failed gang. Instances that could not be
+// destroyed make it a server error, since they are still running.
+func respondGangError(w http.ResponseWriter, err error) {
+	var gangErr *gpu.GangError
+	if !errors.As(err, &gangErr) {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusConflict
+	if len(gangErr.Leaked) > 0 {
+		status = http.StatusInternalServerError
+	}
+	respondJSON(w, status, map[string]interface{}{
+		"error":       err.Error(),
+		"rolled_back": gangErr.RolledBack,
+		"leaked":      gangErr.Leaked,
+	})
+}
+
+// affinityContext keys the request's instance selections for the
+// consistent-hash and sticky-session strategies, as the pool they are made
+// in, if any, is configured to
+func (h *GPUHandler) affinityContext(r *http.Request, body []byte, pool string) context.Context {
+	lb := h.lbService
+	if p := lb.Pool(pool); p != nil {
+		lb = p
+	}
+	source := lb.Affinity().Source
+
+	var fields map[string]interface{}
+	if source.Kind == loadbalancer.AffinityField {
+		json.Unmarshal(body, &fields)
+	}
+	userID := ""
+	if user := middleware.GetUser(r.Context()); user != nil {
+		userID = user.ID.String()
+	}
+	return loadbalancer.WithAffinityKey(r.Context(), source.Key(userID, r.Header, fields))
+}
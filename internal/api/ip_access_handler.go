@@ -215,7 +215,7 @@ func (h *IPAccessHandler) AddAllowlist(w http.ResponseWriter, r *http.Request) {
 
 	createdBy := "API:" + email
 	var entry models.IPAllowlistEntry
-	err := h.db.QueryRow(r.Context(), query,
+	err = h.db.QueryRow(r.Context(), query,
 		uuid.New().String(), userID, req.IPAddress, req.IPRange, req.Description,
 		time.Now(), time.Now(), createdBy,
 	).Scan(
@@ -328,7 +328,7 @@ func (h *IPAccessHandler) AddDenylist(w http.ResponseWriter, r *http.Request) {
 
 	createdBy := "API:" + email
 	var entry models.IPDenylistEntry
-	err := h.db.QueryRow(r.Context(), query,
+	err = h.db.QueryRow(r.Context(), query,
 		uuid.New().String(), userID, req.IPAddress, req.IPRange, req.Reason,
 		req.ExpiresAt, time.Now(), time.Now(), createdBy,
 	).Scan(
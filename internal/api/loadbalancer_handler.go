@@ -15,11 +15,32 @@ func NewLoadBalancerHandler(lbService *loadbalancer.LoadBalancerService) *LoadBa
 	return &LoadBalancerHandler{lbService: lbService}
 }
 
+// pool returns the balancer of the named pool, or the global one if name
+// is empty, responding with 404 if there is no such pool
+func (h *LoadBalancerHandler) pool(w http.ResponseWriter, name string) (*loadbalancer.LoadBalancerService, bool) {
+	if name == "" {
+		return h.lbService, true
+	}
+	service := h.lbService.Pool(name)
+	if service == nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Pool not found"})
+		return nil, false
+	}
+	return service, true
+}
+
 func (h *LoadBalancerHandler) GetLoads(w http.ResponseWriter, r *http.Request) {
-	loads := h.lbService.GetAllLoads()
+	name := r.URL.Query().Get("pool")
+	service, ok := h.pool(w, name)
+	if !ok {
+		return
+	}
+	loads := service.GetAllLoads()
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"strategy": h.lbService.GetStrategy(),
+		"pool":     name,
+		"pools":    h.lbService.PoolNames(),
+		"strategy": service.GetStrategy(),
 		"loads":    loads,
 		"count":    len(loads),
 	})
@@ -32,7 +53,12 @@ func (h *LoadBalancerHandler) GetInstanceLoad(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	load := h.lbService.GetInstanceLoad(instanceID)
+	service, ok := h.pool(w, r.URL.Query().Get("pool"))
+	if !ok {
+		return
+	}
+
+	load := service.GetInstanceLoad(instanceID)
 	if load == nil {
 		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Instance not found"})
 		return
@@ -43,7 +69,9 @@ func (h *LoadBalancerHandler) GetInstanceLoad(w http.ResponseWriter, r *http.Req
 
 func (h *LoadBalancerHandler) SetStrategy(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Strategy string `json:"strategy"`
+		Strategy    string `json:"strategy"`
+		AffinityKey string `json:"affinity_key"` // Optional: user, api_key, header:<name> or field:<name>
+		Pool        string `json:"pool"`         // Optional: change a named pool instead of the global strategy
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -51,29 +79,52 @@ func (h *LoadBalancerHandler) SetStrategy(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	validStrategies := map[string]bool{
-		"round_robin":           true,
-		"equal_weighted":        true,
-		"weighted_round_robin":  true,
-		"least_connections":     true,
-		"least_response_time":   true,
+	strategy, err := loadbalancer.ParseStrategy(req.Strategy)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid strategy"})
+		return
 	}
 
-	if !validStrategies[req.Strategy] {
-		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid strategy"})
+	service, ok := h.pool(w, req.Pool)
+	if !ok {
+		return
+	}
+	if err := service.CheckStrategy(strategy); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	h.lbService.SetStrategy(loadbalancer.Strategy(req.Strategy))
+	if req.AffinityKey != "" {
+		source, err := loadbalancer.ParseAffinitySource(req.AffinityKey)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		affinity := service.Affinity()
+		affinity.Source = source
+		service.SetAffinity(affinity)
+	}
+
+	service.SetStrategy(strategy)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"strategy": req.Strategy,
-		"message":  "Load balancing strategy updated",
+		"pool":         req.Pool,
+		"strategy":     req.Strategy,
+		"affinity_key": service.Affinity().Source.String(),
+		"message":      "Load balancing strategy updated",
 	})
 }
 
 func (h *LoadBalancerHandler) GetStrategy(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("pool")
+	service, ok := h.pool(w, name)
+	if !ok {
+		return
+	}
+
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"strategy": h.lbService.GetStrategy(),
+		"pool":         name,
+		"strategy":     service.GetStrategy(),
+		"affinity_key": service.Affinity().Source.String(),
 	})
 }
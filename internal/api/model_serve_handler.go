@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/aiserve/gpuproxy/internal/middleware"
@@ -88,6 +89,20 @@ func (h *ModelServeHandler) UploadModel(w http.ResponseWriter, r *http.Request)
 	gpuRequired := r.FormValue("gpu_required") == "true"
 	gpuType := r.FormValue("gpu_type")
 
+	// A VRAM requirement shares a local GPU with other models; without one
+	// the model gets a device to itself
+	var minVRAM int
+	if v := r.FormValue("min_vram"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": "min_vram must be a non-negative number of GB",
+			})
+			return
+		}
+		minVRAM = n
+	}
+
 	// Generate model ID
 	modelID := uuid.New().String()
 
@@ -131,6 +146,7 @@ func (h *ModelServeHandler) UploadModel(w http.ResponseWriter, r *http.Request)
 		Framework:   framework,
 		GPURequired: gpuRequired,
 		GPUType:     gpuType,
+		MinVRAM:     minVRAM,
 		UserID:      userID.String(),
 		Metadata: map[string]interface{}{
 			"filename": header.Filename,
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/gpu"
+)
+
+type PriceHandler struct {
+	history *gpu.PriceHistory
+}
+
+func NewPriceHandler(history *gpu.PriceHistory) *PriceHandler {
+	return &PriceHandler{history: history}
+}
+
+// Trends returns the price trend of each GPU class, or of the one named by
+// the class parameter, over the last window (default 7 days)
+func (h *PriceHandler) Trends(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	window, err := durationParam(q.Get("window"), 7*24*time.Hour)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid window: "+err.Error())
+		return
+	}
+	bucket, err := durationParam(q.Get("bucket"), time.Hour)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid bucket: "+err.Error())
+		return
+	}
+
+	query := gpu.PriceQuery{
+		From:     time.Now().Add(-window),
+		Provider: q.Get("provider"),
+		Region:   q.Get("region"),
+	}
+	if class := q.Get("class"); class != "" {
+		query.GPUClass = gpu.PriceClass(class)
+	}
+
+	trends, err := h.history.Trends(r.Context(), query, bucket)
+	if errors.Is(err, gpu.ErrNoPriceHistory) {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"trends": trends,
+		"count":  len(trends),
+	})
+}
+
+// Estimate prices a job of the given duration on count GPUs of a class and
+// suggests the cheapest recent window to run it in
+func (h *PriceHandler) Estimate(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	duration, err := durationParam(q.Get("duration"), 0)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid duration: "+err.Error())
+		return
+	}
+	lookback, err := durationParam(q.Get("lookback"), 0)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid lookback: "+err.Error())
+		return
+	}
+	count := 1
+	if c := q.Get("count"); c != "" {
+		count, err = strconv.Atoi(c)
+		if err != nil || count < 1 {
+			respondError(w, http.StatusBadRequest, "count must be a positive number")
+			return
+		}
+	}
+
+	estimate, err := h.history.EstimateCost(r.Context(), gpu.CostEstimateRequest{
+		GPUClass: q.Get("class"),
+		Count:    count,
+		Duration: duration,
+		Provider: q.Get("provider"),
+		Region:   q.Get("region"),
+		Lookback: lookback,
+	})
+	if errors.Is(err, gpu.ErrNoPriceHistory) {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, estimate)
+}
+
+// durationParam parses a Go duration such as "8h" or "168h", returning def
+// when it is empty
+func durationParam(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return d, nil
+}
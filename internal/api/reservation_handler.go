@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/compute"
+	"github.com/aiserve/gpuproxy/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+type ReservationHandler struct {
+	client *compute.ReservationClient
+}
+
+func NewReservationHandler(client *compute.ReservationClient) *ReservationHandler {
+	return &ReservationHandler{client: client}
+}
+
+// Reserve creates a compute reservation owned by the caller. A request that
+// asked to queue when capacity is exhausted is accepted with its place in
+// the queue.
+func (h *ReservationHandler) Reserve(w http.ResponseWriter, r *http.Request) {
+	var req compute.ReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.UserID = middleware.GetUserID(r.Context()).String()
+
+	if err := h.client.CheckPriority(&req, isAdmin(r)); err != nil {
+		respondError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	reservation, err := h.client.Reserve(r.Context(), &req)
+	if errors.Is(err, compute.ErrInvalidWebhook) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	if reservation.Status == compute.StatusQueued {
+		if err := h.client.FillQueueStatus(r.Context(), reservation); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusAccepted, reservation)
+		return
+	}
+	respondJSON(w, http.StatusCreated, reservation)
+}
+
+// isAdmin reports whether the caller is an administrator
+func isAdmin(r *http.Request) bool {
+	user := middleware.GetUser(r.Context())
+	return user != nil && user.IsAdmin
+}
+
+// ListReservations returns the caller's reservations that have not ended
+func (h *ReservationHandler) ListReservations(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context()).String()
+
+	reservations, err := h.client.ListUserReservations(r.Context(), userID)
+	if err == nil {
+		err = h.client.FillQueueStatus(r.Context(), reservations...)
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"reservations": reservations,
+		"count":        len(reservations),
+	})
+}
+
+// Queue returns the caller's queued reservations with their positions and
+// estimated start times, and how long each queue is
+func (h *ReservationHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context()).String()
+
+	reservations, err := h.client.ListUserReservations(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	queued := make([]*compute.Reservation, 0, len(reservations))
+	for _, reservation := range reservations {
+		if reservation.Status == compute.StatusQueued {
+			queued = append(queued, reservation)
+		}
+	}
+	if err := h.client.FillQueueStatus(r.Context(), queued...); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"reservations":  queued,
+		"count":         len(queued),
+		"queue_lengths": h.client.QueueLengths(),
+	})
+}
+
+// GetReservation returns one of the caller's reservations
+func (h *ReservationHandler) GetReservation(w http.ResponseWriter, r *http.Request) {
+	reservation, ok := h.owned(w, r)
+	if !ok {
+		return
+	}
+	if err := h.client.FillQueueStatus(r.Context(), reservation); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, reservation)
+}
+
+// ReleaseReservation releases one of the caller's reservations
+func (h *ReservationHandler) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	reservation, ok := h.owned(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.client.Release(r.Context(), reservation.ID); err != nil {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"reservation_id": reservation.ID,
+		"message":        "Reservation released",
+	})
+}
+
+// ExtendReservation pushes back the end time of one of the caller's reservations
+func (h *ReservationHandler) ExtendReservation(w http.ResponseWriter, r *http.Request) {
+	reservation, ok := h.owned(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Duration string `json:"duration"` // e.g. "2h"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 {
+		respondError(w, http.StatusBadRequest, "duration must be a positive duration such as 30m or 2h")
+		return
+	}
+
+	extended, err := h.client.Extend(r.Context(), reservation.ID, duration)
+	if err != nil {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, extended)
+}
+
+// owned loads the reservation named in the path, answering 404 unless the
+// caller owns it
+func (h *ReservationHandler) owned(w http.ResponseWriter, r *http.Request) (*compute.Reservation, bool) {
+	reservationID := mux.Vars(r)["id"]
+
+	reservation, err := h.client.GetReservation(reservationID)
+	if errors.Is(err, compute.ErrReservationNotFound) {
+		respondError(w, http.StatusNotFound, "Reservation not found")
+		return nil, false
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if reservation.UserID != middleware.GetUserID(r.Context()).String() {
+		respondError(w, http.StatusNotFound, "Reservation not found")
+		return nil, false
+	}
+	return reservation, true
+}
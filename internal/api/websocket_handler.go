@@ -1,11 +1,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
 	"sync"
 
+	"github.com/aiserve/gpuproxy/internal/middleware"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -17,19 +20,39 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// wsSendBuffer is how many messages may queue for a client before further
+// messages to it are dropped
+const wsSendBuffer = 64
+
 type WebSocketHandler struct {
-	clients   map[*websocket.Conn]bool
-	broadcast chan []byte
+	clients   map[*websocket.Conn]*wsClient
+	authorize TopicAuthorizer
 	mu        sync.RWMutex
 }
 
+// wsClient is a connection's subscriptions and outgoing queue. Only the
+// connection's writer goroutine writes to it, so a slow client never holds
+// up publishers or other clients.
+type wsClient struct {
+	topics map[string]bool
+	send   chan []byte
+}
+
+// TopicAuthorizer reports whether a user may subscribe to a topic
+type TopicAuthorizer func(ctx context.Context, userID uuid.UUID, topic string) bool
+
 func NewWebSocketHandler() *WebSocketHandler {
-	h := &WebSocketHandler{
-		clients:   make(map[*websocket.Conn]bool),
-		broadcast: make(chan []byte, 256),
+	return &WebSocketHandler{
+		clients: make(map[*websocket.Conn]*wsClient),
 	}
-	go h.handleBroadcasts()
-	return h
+}
+
+// SetTopicAuthorizer sets the check run before a client subscribes to a
+// topic. Without one, topic subscriptions are refused.
+func (h *WebSocketHandler) SetTopicAuthorizer(authorize TopicAuthorizer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.authorize = authorize
 }
 
 func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Request) {
@@ -40,14 +63,20 @@ func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Reque
 	}
 	defer conn.Close()
 
+	client := &wsClient{
+		topics: make(map[string]bool),
+		send:   make(chan []byte, wsSendBuffer),
+	}
 	h.mu.Lock()
-	h.clients[conn] = true
+	h.clients[conn] = client
 	h.mu.Unlock()
+	go h.writeMessages(conn, client.send)
 
 	defer func() {
 		h.mu.Lock()
 		delete(h.clients, conn)
 		h.mu.Unlock()
+		close(client.send)
 	}()
 
 	for {
@@ -60,67 +89,120 @@ func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Reque
 		}
 
 		if messageType == websocket.TextMessage {
-			h.handleMessage(conn, message)
+			h.handleMessage(r.Context(), client, message)
 		}
 	}
 }
 
-func (h *WebSocketHandler) handleMessage(conn *websocket.Conn, message []byte) {
+func (h *WebSocketHandler) handleMessage(ctx context.Context, client *wsClient, message []byte) {
 	var msg map[string]interface{}
 	if err := json.Unmarshal(message, &msg); err != nil {
-		h.sendError(conn, "Invalid JSON")
+		h.sendError(client, "Invalid JSON")
 		return
 	}
 
 	msgType, ok := msg["type"].(string)
 	if !ok {
-		h.sendError(conn, "Missing message type")
+		h.sendError(client, "Missing message type")
 		return
 	}
 
 	switch msgType {
 	case "ping":
-		h.sendResponse(conn, map[string]interface{}{
+		h.sendResponse(client, map[string]interface{}{
 			"type": "pong",
 		})
 	case "subscribe":
-		h.sendResponse(conn, map[string]interface{}{
+		// Optional topics, e.g. "reservation:<id>", opt in to published events
+		var topics, denied []string
+		if list, ok := msg["topics"].([]interface{}); ok {
+			h.mu.RLock()
+			authorize := h.authorize
+			h.mu.RUnlock()
+
+			userID := middleware.GetUserID(ctx)
+			for _, t := range list {
+				topic, ok := t.(string)
+				if !ok || topic == "" {
+					continue
+				}
+				if authorize == nil || userID == uuid.Nil || !authorize(ctx, userID, topic) {
+					denied = append(denied, topic)
+					continue
+				}
+				topics = append(topics, topic)
+			}
+
+			h.mu.Lock()
+			for _, topic := range topics {
+				client.topics[topic] = true
+			}
+			h.mu.Unlock()
+		}
+		if len(denied) > 0 {
+			h.sendResponse(client, map[string]interface{}{
+				"type":   "error",
+				"error":  "Not allowed to subscribe to topics",
+				"topics": denied,
+			})
+		}
+		h.sendResponse(client, map[string]interface{}{
 			"type":    "subscribed",
 			"message": "Successfully subscribed to GPU stream",
+			"topics":  topics,
 		})
 	default:
-		h.sendError(conn, "Unknown message type")
+		h.sendError(client, "Unknown message type")
 	}
 }
 
-func (h *WebSocketHandler) handleBroadcasts() {
-	for message := range h.broadcast {
-		h.mu.RLock()
-		for client := range h.clients {
-			if err := client.WriteMessage(websocket.TextMessage, message); err != nil {
-				log.Printf("WebSocket write error: %v", err)
-				client.Close()
-				delete(h.clients, client)
+// writeMessages is a connection's only writer. After a write error it
+// closes the connection, which ends HandleConnection's read loop.
+func (h *WebSocketHandler) writeMessages(conn *websocket.Conn, send <-chan []byte) {
+	for message := range send {
+		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			log.Printf("WebSocket write error: %v", err)
+			conn.Close()
+			for range send {
 			}
+			return
 		}
-		h.mu.RUnlock()
 	}
 }
 
-func (h *WebSocketHandler) sendResponse(conn *websocket.Conn, data interface{}) {
+// deliver sends a message to every client, or only to clients subscribed to
+// topic when one is set. Clients whose queue is full miss the message.
+func (h *WebSocketHandler) deliver(topic string, message []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, client := range h.clients {
+		if topic != "" && !client.topics[topic] {
+			continue
+		}
+		select {
+		case client.send <- message:
+		default:
+			log.Printf("WebSocket client too slow, dropping message")
+		}
+	}
+}
+
+func (h *WebSocketHandler) sendResponse(client *wsClient, data interface{}) {
 	response, err := json.Marshal(data)
 	if err != nil {
 		log.Printf("JSON marshal error: %v", err)
 		return
 	}
 
-	if err := conn.WriteMessage(websocket.TextMessage, response); err != nil {
-		log.Printf("WebSocket write error: %v", err)
+	select {
+	case client.send <- response:
+	default:
+		log.Printf("WebSocket client too slow, dropping response")
 	}
 }
 
-func (h *WebSocketHandler) sendError(conn *websocket.Conn, message string) {
-	h.sendResponse(conn, map[string]interface{}{
+func (h *WebSocketHandler) sendError(client *wsClient, message string) {
+	h.sendResponse(client, map[string]interface{}{
 		"type":  "error",
 		"error": message,
 	})
@@ -132,5 +214,16 @@ func (h *WebSocketHandler) Broadcast(data interface{}) {
 		log.Printf("Broadcast marshal error: %v", err)
 		return
 	}
-	h.broadcast <- message
+	h.deliver("", message)
+}
+
+// Publish sends data only to clients subscribed to topic. It never blocks:
+// clients that aren't keeping up miss the event.
+func (h *WebSocketHandler) Publish(topic string, data interface{}) {
+	message, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Publish marshal error: %v", err)
+		return
+	}
+	h.deliver(topic, message)
 }
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/middleware"
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dialWebSocket connects to h as the given user, as the auth middleware
+// would have identified them
+func dialWebSocket(t *testing.T, h *WebSocketHandler, userID uuid.UUID) *websocket.Conn {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), middleware.UserContextKey, &models.User{ID: userID})
+		h.HandleConnection(w, r.WithContext(ctx))
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readWebSocketJSON(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	t.Helper()
+	var msg map[string]interface{}
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestWebSocketSubscribeChecksTopicOwnership(t *testing.T) {
+	owner := uuid.New()
+	h := NewWebSocketHandler()
+	h.SetTopicAuthorizer(func(ctx context.Context, userID uuid.UUID, topic string) bool {
+		return userID == owner && topic == "reservation:mine"
+	})
+
+	conn := dialWebSocket(t, h, owner)
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"type":   "subscribe",
+		"topics": []string{"reservation:mine", "reservation:theirs"},
+	}))
+
+	msg := readWebSocketJSON(t, conn)
+	assert.Equal(t, "error", msg["type"])
+	assert.Equal(t, []interface{}{"reservation:theirs"}, msg["topics"])
+	msg = readWebSocketJSON(t, conn)
+	assert.Equal(t, "subscribed", msg["type"])
+	assert.Equal(t, []interface{}{"reservation:mine"}, msg["topics"])
+
+	h.Publish("reservation:theirs", map[string]string{"event": "expiring", "reservation_id": "theirs"})
+	h.Publish("reservation:mine", map[string]string{"event": "expiring", "reservation_id": "mine"})
+	msg = readWebSocketJSON(t, conn)
+	assert.Equal(t, "mine", msg["reservation_id"], "events for other users' reservations are not delivered")
+}
+
+func TestWebSocketSubscribeRefusesTopicsWithoutAuthorizer(t *testing.T) {
+	h := NewWebSocketHandler()
+
+	conn := dialWebSocket(t, h, uuid.New())
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"type":   "subscribe",
+		"topics": []string{"reservation:any"},
+	}))
+
+	msg := readWebSocketJSON(t, conn)
+	assert.Equal(t, "error", msg["type"])
+	msg = readWebSocketJSON(t, conn)
+	assert.Equal(t, "subscribed", msg["type"])
+	assert.Nil(t, msg["topics"])
+}
+
+func TestWebSocketPublishDoesNotBlockOnSlowClients(t *testing.T) {
+	owner := uuid.New()
+	h := NewWebSocketHandler()
+	h.SetTopicAuthorizer(func(ctx context.Context, userID uuid.UUID, topic string) bool { return true })
+
+	conn := dialWebSocket(t, h, owner)
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "subscribe", "topics": []string{"reservation:r1"}}))
+	require.Equal(t, "subscribed", readWebSocketJSON(t, conn)["type"])
+
+	// The client stops reading; publishing must not wait for it
+	done := make(chan struct{})
+	payload := strings.Repeat("x", 64*1024)
+	go func() {
+		defer close(done)
+		for i := 0; i < 8*wsSendBuffer; i++ {
+			h.Publish("reservation:r1", map[string]string{"payload": payload})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish blocked on a slow client")
+	}
+
+	msg := readWebSocketJSON(t, conn)
+	assert.Equal(t, payload, msg["payload"], "queued events are still delivered")
+}
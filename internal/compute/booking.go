@@ -0,0 +1,698 @@
+package compute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Booking statuses
+const (
+	BookingScheduled = "scheduled" // Waiting for its slot
+	BookingActive    = "active"    // The slot's reservation has been made
+	BookingCompleted = "completed" // The slot's reservation has ended
+	BookingCanceled  = "canceled"
+	BookingFailed    = "failed" // No reservation could be made, or it failed
+)
+
+// Cancellation policies
+const (
+	PolicyFlexible = "flexible"
+	PolicyModerate = "moderate"
+	PolicyStrict   = "strict"
+)
+
+// ReleaseBookingCanceled is recorded on reservations released because their
+// booking was canceled
+const ReleaseBookingCanceled = "booking_canceled"
+
+// maxCalendarRange bounds a single calendar query
+const maxCalendarRange = 92 * 24 * time.Hour
+
+// CancellationPolicy sets what canceling a booking costs
+type CancellationPolicy struct {
+	FreeNotice time.Duration `json:"free_notice"` // Canceling at least this long before the start is free
+	LateFee    float64       `json:"late_fee"`    // Fraction of the estimated cost charged otherwise
+}
+
+// CancellationPolicies are the policies a booking may choose from
+var CancellationPolicies = map[string]CancellationPolicy{
+	PolicyFlexible: {FreeNotice: time.Hour, LateFee: 0.1},
+	PolicyModerate: {FreeNotice: 24 * time.Hour, LateFee: 0.5},
+	PolicyStrict:   {FreeNotice: 7 * 24 * time.Hour, LateFee: 1},
+}
+
+// ErrBookingConflict is returned when a booking or reservation would need
+// more capacity than is left over the requested window
+var ErrBookingConflict = errors.New("capacity is already booked")
+
+// BookingRequest asks for compute over a future window
+type BookingRequest struct {
+	ReservationRequest
+
+	StartTime          time.Time `json:"start_time"`
+	EndTime            time.Time `json:"end_time"`
+	CancellationPolicy string    `json:"cancellation_policy,omitempty"` // flexible, moderate or strict
+}
+
+// Booking is capacity set aside for a future window. A reservation is made
+// for it a lead time before the window starts and ends with the window.
+type Booking struct {
+	ID                 string             `json:"id"`
+	UserID             string             `json:"user_id,omitempty"`
+	Request            ReservationRequest `json:"request"` // Reserved when the slot comes up
+	StartTime          time.Time          `json:"start_time"`
+	EndTime            time.Time          `json:"end_time"`
+	ProvisionAt        time.Time          `json:"provision_at"` // When the reservation is made
+	Status             string             `json:"status"`
+	CancellationPolicy string             `json:"cancellation_policy"`
+	EstimatedCost      float64            `json:"estimated_cost,omitempty"` // max_cost_per_hr over the window
+	CancellationFee    float64            `json:"cancellation_fee,omitempty"`
+	ReservationID      string             `json:"reservation_id,omitempty"`
+	FailureReason      string             `json:"failure_reason,omitempty"`
+	LastError          string             `json:"last_error,omitempty"` // Latest failed provisioning attempt
+	Version            int64              `json:"version"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+}
+
+// clone returns a copy of b that can be modified without affecting b
+func (b *Booking) clone() *Booking {
+	c := *b
+	c.Request.FallbackProviders = append([]ComputeProvider(nil), b.Request.FallbackProviders...)
+	if b.Request.Labels != nil {
+		c.Request.Labels = make(map[string]string, len(b.Request.Labels))
+		for k, v := range b.Request.Labels {
+			c.Request.Labels[k] = v
+		}
+	}
+	return &c
+}
+
+// BookingConfig controls how bookings are provisioned
+type BookingConfig struct {
+	LeadTime      time.Duration // Reserve this long before a slot starts
+	DefaultPolicy string        // Cancellation policy when a booking names none
+	Horizon       time.Duration // Bookings must end within this long from now; 0 means no limit
+}
+
+// BookingCalendar books capacity for future windows and provisions the
+// bookings as their windows come up. Bookings are checked against the
+// client's capacity limits, the other bookings and the live reservations.
+type BookingCalendar struct {
+	client *ReservationClient
+	store  BookingStore
+	cfg    BookingConfig
+
+	mu sync.Mutex // Serializes capacity checks with the bookings they admit
+}
+
+// BookingReport counts what one calendar pass did
+type BookingReport struct {
+	Provisioned int
+	Completed   int
+	Failed      int
+	Errors      []error
+}
+
+// CalendarSlot is a span of time over which booked capacity is constant
+type CalendarSlot struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Booked    int       `json:"booked"`
+	Available int       `json:"available"`
+}
+
+// CalendarEntry is a booking as shown on the calendar. Only the caller's own
+// bookings carry their ID.
+type CalendarEntry struct {
+	BookingID   string      `json:"booking_id,omitempty"`
+	Mine        bool        `json:"mine"`
+	ComputeType ComputeType `json:"compute_type"`
+	GPUModel    string      `json:"gpu_model,omitempty"`
+	Count       int         `json:"count"`
+	StartTime   time.Time   `json:"start_time"`
+	EndTime     time.Time   `json:"end_time"`
+	Status      string      `json:"status"`
+}
+
+// CalendarView is booked and available capacity over a time range
+type CalendarView struct {
+	ComputeType ComputeType     `json:"compute_type"`
+	From        time.Time       `json:"from"`
+	To          time.Time       `json:"to"`
+	Capacity    int             `json:"capacity"`
+	Slots       []CalendarSlot  `json:"slots"`
+	Bookings    []CalendarEntry `json:"bookings"`
+}
+
+// hold is capacity in use over [from, to). A zero from has always been held
+// and a zero to is held indefinitely.
+type hold struct {
+	from, to time.Time
+	count    int
+}
+
+func (h hold) covers(t time.Time) bool {
+	return (h.from.IsZero() || !h.from.After(t)) && (h.to.IsZero() || h.to.After(t))
+}
+
+// NewBookingCalendar creates a calendar for client's capacity. From then on
+// client also refuses reservations that would eat into booked capacity.
+// Call it before the client is used.
+func NewBookingCalendar(client *ReservationClient, cfg BookingConfig) *BookingCalendar {
+	if cfg.DefaultPolicy == "" {
+		cfg.DefaultPolicy = PolicyModerate
+	}
+
+	cal := &BookingCalendar{
+		client: client,
+		store:  client.store,
+		cfg:    cfg,
+	}
+	client.admit = cal.admit
+	return cal
+}
+
+// Book sets capacity aside for a user over the request's window
+func (cal *BookingCalendar) Book(ctx context.Context, userID string, req *BookingRequest) (*Booking, error) {
+	now := cal.client.now()
+
+	if req.ComputeType == "" {
+		req.ComputeType = ComputeGPU
+	}
+	if req.ComputeType != ComputeGPU && req.ComputeType != ComputeTPU {
+		return nil, fmt.Errorf("only gpu and tpu compute can be booked")
+	}
+	if req.Count < 1 {
+		return nil, fmt.Errorf("count must be at least 1")
+	}
+	if !req.StartTime.After(now) {
+		return nil, fmt.Errorf("start_time must be in the future")
+	}
+	if !req.EndTime.After(req.StartTime) {
+		return nil, fmt.Errorf("end_time must be after start_time")
+	}
+	if cal.cfg.Horizon > 0 && req.EndTime.After(now.Add(cal.cfg.Horizon)) {
+		return nil, fmt.Errorf("end_time must be within %s from now", cal.cfg.Horizon)
+	}
+	if req.CancellationPolicy == "" {
+		req.CancellationPolicy = cal.cfg.DefaultPolicy
+	}
+	if _, ok := CancellationPolicies[req.CancellationPolicy]; !ok {
+		return nil, fmt.Errorf("unknown cancellation policy %q (use %s, %s or %s)",
+			req.CancellationPolicy, PolicyFlexible, PolicyModerate, PolicyStrict)
+	}
+	if req.WebhookURL != "" {
+		if err := cal.client.webhooks.Validate(ctx, req.WebhookURL); err != nil {
+			return nil, err
+		}
+	}
+
+	provisionAt := req.StartTime.Add(-cal.cfg.LeadTime)
+	if provisionAt.Before(now) {
+		provisionAt = now
+	}
+
+	reservation := req.ReservationRequest
+	reservation.UserID = userID
+	reservation.BookingID = ""
+	reservation.Duration = 0
+
+	booking := &Booking{
+		ID:                 "bkg-" + uuid.NewString(),
+		UserID:             userID,
+		Request:            reservation,
+		StartTime:          req.StartTime,
+		EndTime:            req.EndTime,
+		ProvisionAt:        provisionAt,
+		Status:             BookingScheduled,
+		CancellationPolicy: req.CancellationPolicy,
+		EstimatedCost:      req.MaxCostPerHr * req.EndTime.Sub(req.StartTime).Hours(),
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+
+	cal.mu.Lock()
+	defer cal.mu.Unlock()
+
+	if err := cal.checkCapacity(ctx, req.ComputeType, req.Count, provisionAt, req.EndTime); err != nil {
+		return nil, err
+	}
+	if err := cal.store.CreateBooking(ctx, booking); err != nil {
+		return nil, fmt.Errorf("failed to record booking: %w", err)
+	}
+
+	return booking, nil
+}
+
+// CheckPriority vets the priority and preemption a booking asks for against
+// the caller's limits; see ReservationClient.CheckPriority
+func (cal *BookingCalendar) CheckPriority(req *BookingRequest, admin bool) error {
+	return cal.client.CheckPriority(&req.ReservationRequest, admin)
+}
+
+// Get returns a booking by ID
+func (cal *BookingCalendar) Get(ctx context.Context, id string) (*Booking, error) {
+	return cal.store.GetBooking(ctx, id)
+}
+
+// UserBookings returns every booking a user has made, by start time
+func (cal *BookingCalendar) UserBookings(ctx context.Context, userID string) ([]*Booking, error) {
+	bookings, err := cal.store.ListBookings(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bookings: %w", err)
+	}
+
+	owned := make([]*Booking, 0, len(bookings))
+	for _, b := range bookings {
+		if b.UserID == userID {
+			owned = append(owned, b)
+		}
+	}
+	return owned, nil
+}
+
+// Cancel cancels a booking, charging the fee its cancellation policy sets
+// for the notice given. An active booking's reservation is released.
+func (cal *BookingCalendar) Cancel(ctx context.Context, id string) (*Booking, error) {
+	var wasActive bool
+	booking, err := cal.update(ctx, id, func(next *Booking) error {
+		if next.Status != BookingScheduled && next.Status != BookingActive {
+			return fmt.Errorf("booking %s is already %s", id, next.Status)
+		}
+		wasActive = next.Status == BookingActive
+		next.Status = BookingCanceled
+		next.CancellationFee = cal.cancellationFee(next)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if wasActive {
+		if err := cal.client.release(ctx, booking.ReservationID, ReleaseBookingCanceled); err != nil {
+			return booking, fmt.Errorf("booking canceled but releasing reservation %s failed: %w", booking.ReservationID, err)
+		}
+	}
+	return booking, nil
+}
+
+// cancellationFee is what canceling b now costs under its policy
+func (cal *BookingCalendar) cancellationFee(b *Booking) float64 {
+	policy := CancellationPolicies[b.CancellationPolicy]
+	if b.StartTime.Sub(cal.client.now()) >= policy.FreeNotice {
+		return 0
+	}
+	return policy.LateFee * b.EstimatedCost
+}
+
+// Calendar returns booked and available capacity of a compute type over
+// [from, to), along with the bookings in that range
+func (cal *BookingCalendar) Calendar(ctx context.Context, computeType ComputeType, from, to time.Time, userID string) (*CalendarView, error) {
+	if computeType == "" {
+		computeType = ComputeGPU
+	}
+	if computeType != ComputeGPU && computeType != ComputeTPU {
+		return nil, fmt.Errorf("only gpu and tpu compute can be booked")
+	}
+	if !to.After(from) {
+		return nil, fmt.Errorf("to must be after from")
+	}
+	if to.Sub(from) > maxCalendarRange {
+		return nil, fmt.Errorf("calendar range is limited to %d days", int(maxCalendarRange.Hours()/24))
+	}
+
+	holds, err := cal.holds(ctx, computeType, from, to)
+	if err != nil {
+		return nil, err
+	}
+	capacity := cal.client.capacity(computeType)
+
+	view := &CalendarView{
+		ComputeType: computeType,
+		From:        from,
+		To:          to,
+		Capacity:    capacity,
+		Slots:       []CalendarSlot{},
+		Bookings:    []CalendarEntry{},
+	}
+
+	for _, slot := range slots(holds, from, to) {
+		slot.Available = capacity - slot.Booked
+		if slot.Available < 0 {
+			slot.Available = 0
+		}
+		view.Slots = append(view.Slots, slot)
+	}
+
+	bookings, err := cal.store.ListBookings(ctx, from, to, BookingScheduled, BookingActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bookings: %w", err)
+	}
+	for _, b := range bookings {
+		if b.Request.ComputeType != computeType {
+			continue
+		}
+		entry := CalendarEntry{
+			Mine:        userID != "" && b.UserID == userID,
+			ComputeType: b.Request.ComputeType,
+			GPUModel:    b.Request.GPUModel,
+			Count:       b.Request.Count,
+			StartTime:   b.StartTime,
+			EndTime:     b.EndTime,
+			Status:      b.Status,
+		}
+		if entry.Mine {
+			entry.BookingID = b.ID
+		}
+		view.Bookings = append(view.Bookings, entry)
+	}
+
+	return view, nil
+}
+
+// Check provisions bookings whose time has come and completes bookings whose
+// reservations have ended
+func (cal *BookingCalendar) Check(ctx context.Context) (*BookingReport, error) {
+	bookings, err := cal.store.ListBookings(ctx, time.Time{}, time.Time{}, BookingScheduled, BookingActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bookings: %w", err)
+	}
+
+	report := &BookingReport{}
+	now := cal.client.now()
+	for _, b := range bookings {
+		switch b.Status {
+		case BookingScheduled:
+			if !now.Before(b.ProvisionAt) {
+				cal.provision(ctx, b, now, report)
+			}
+		case BookingActive:
+			cal.track(ctx, b, report)
+		}
+	}
+
+	return report, nil
+}
+
+// provision makes the reservation for a booking's slot. Failed attempts are
+// retried on later passes until the slot is over.
+func (cal *BookingCalendar) provision(ctx context.Context, b *Booking, now time.Time, report *BookingReport) {
+	if !now.Before(b.EndTime) {
+		reason := "slot ended before a reservation could be made"
+		if b.LastError != "" {
+			reason += ": " + b.LastError
+		}
+		cal.finish(ctx, b.ID, BookingFailed, reason, report)
+		return
+	}
+
+	// A reservation made before a crash is adopted rather than duplicated
+	reservation, err := cal.bookedReservation(ctx, b.ID)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("booking %s: %w", b.ID, err))
+		return
+	}
+
+	if reservation == nil {
+		req := b.Request
+		req.UserID = b.UserID
+		req.BookingID = b.ID
+		req.Duration = b.EndTime.Sub(now)
+
+		reservation, err = cal.client.Reserve(ctx, &req)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("booking %s: %w", b.ID, err))
+			reserveErr := err
+			if _, err := cal.update(ctx, b.ID, func(next *Booking) error {
+				if next.Status != BookingScheduled {
+					return fmt.Errorf("booking %s is %s", b.ID, next.Status)
+				}
+				next.LastError = reserveErr.Error()
+				return nil
+			}); err != nil {
+				log.Printf("Failed to record provisioning error for booking %s: %v", b.ID, err)
+			}
+			return
+		}
+	}
+
+	_, err = cal.update(ctx, b.ID, func(next *Booking) error {
+		if next.Status != BookingScheduled {
+			return fmt.Errorf("booking %s is %s", b.ID, next.Status)
+		}
+		next.Status = BookingActive
+		next.ReservationID = reservation.ID
+		next.LastError = ""
+		return nil
+	})
+	if err != nil {
+		// Canceled while provisioning; don't leave the reservation running
+		report.Errors = append(report.Errors, err)
+		if releaseErr := cal.client.release(ctx, reservation.ID, ReleaseBookingCanceled); releaseErr != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("booking %s: %w", b.ID, releaseErr))
+		}
+		return
+	}
+	report.Provisioned++
+}
+
+// track completes an active booking once its reservation has ended
+func (cal *BookingCalendar) track(ctx context.Context, b *Booking, report *BookingReport) {
+	reservation, err := cal.client.store.Get(ctx, b.ReservationID)
+	if errors.Is(err, ErrReservationNotFound) {
+		cal.finish(ctx, b.ID, BookingFailed, "reservation no longer exists", report)
+		return
+	}
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("booking %s: %w", b.ID, err))
+		return
+	}
+
+	switch reservation.Status {
+	case StatusTerminated:
+		cal.finish(ctx, b.ID, BookingCompleted, "", report)
+	case StatusFailed:
+		cal.finish(ctx, b.ID, BookingFailed, "reservation failed: "+reservation.FailureReason, report)
+	}
+}
+
+// finish moves an open booking to a final status
+func (cal *BookingCalendar) finish(ctx context.Context, id, status, reason string, report *BookingReport) {
+	_, err := cal.update(ctx, id, func(next *Booking) error {
+		if next.Status != BookingScheduled && next.Status != BookingActive {
+			return fmt.Errorf("booking %s is already %s", id, next.Status)
+		}
+		next.Status = status
+		next.FailureReason = reason
+		return nil
+	})
+	if err != nil {
+		report.Errors = append(report.Errors, err)
+		return
+	}
+
+	if status == BookingCompleted {
+		report.Completed++
+	} else {
+		report.Failed++
+	}
+}
+
+// bookedReservation returns the live reservation made for a booking, if any
+func (cal *BookingCalendar) bookedReservation(ctx context.Context, bookingID string) (*Reservation, error) {
+	reservations, err := cal.client.store.List(ctx, liveStatuses...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reservations: %w", err)
+	}
+	for _, r := range reservations {
+		if r.BookingID == bookingID {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// update applies change to the stored booking and saves it, retrying when
+// another writer got there first. change may refuse by returning an error.
+func (cal *BookingCalendar) update(ctx context.Context, id string, change func(next *Booking) error) (*Booking, error) {
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		current, err := cal.store.GetBooking(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		next := current.clone()
+		if err := change(next); err != nil {
+			return nil, err
+		}
+		next.Version = current.Version + 1
+		next.UpdatedAt = cal.client.now()
+
+		ok, err := cal.store.CompareAndSwapBooking(ctx, current.Version, next)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save booking: %w", err)
+		}
+		if ok {
+			return next, nil
+		}
+	}
+	return nil, fmt.Errorf("booking %s changed while updating", id)
+}
+
+// admit vets a reservation that is not for a booking against booked
+// capacity. If it fits, the calendar stays locked until release is called,
+// which the caller does once the reservation is stored, so no booking or
+// other reservation can take the same capacity in between.
+func (cal *BookingCalendar) admit(ctx context.Context, computeType ComputeType, count int, from, to time.Time) (release func(), err error) {
+	cal.mu.Lock()
+	if err := cal.checkCapacity(ctx, computeType, count, from, to); err != nil {
+		cal.mu.Unlock()
+		return nil, err
+	}
+	return sync.OnceFunc(cal.mu.Unlock), nil
+}
+
+// checkCapacity reports ErrBookingConflict if count more resources cannot be
+// held over [from, to). Callers hold cal.mu.
+func (cal *BookingCalendar) checkCapacity(ctx context.Context, computeType ComputeType, count int, from, to time.Time) error {
+	limit := cal.client.capacity(computeType)
+	if limit == 0 {
+		return nil
+	}
+
+	holds, err := cal.holds(ctx, computeType, from, to)
+	if err != nil {
+		return err
+	}
+
+	used := 0
+	for _, slot := range slots(holds, from, to) {
+		if slot.Booked > used {
+			used = slot.Booked
+		}
+	}
+	if used+count > limit {
+		window := "from " + from.UTC().Format(time.RFC3339)
+		if !to.IsZero() {
+			window += " to " + to.UTC().Format(time.RFC3339)
+		}
+		return fmt.Errorf("%w: %d %s requested but only %d of %d free %s",
+			ErrBookingConflict, count, computeType, limit-used, limit, window)
+	}
+	return nil
+}
+
+// holds returns the capacity of a compute type held over [from, to) by live
+// reservations and by bookings not yet provisioned. A provisioned booking
+// holds capacity through its reservation.
+func (cal *BookingCalendar) holds(ctx context.Context, computeType ComputeType, from, to time.Time) ([]hold, error) {
+	reservations, err := cal.client.store.List(ctx, liveStatuses...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reservations: %w", err)
+	}
+
+	var holds []hold
+	for _, r := range reservations {
+		if r.ComputeType == computeType {
+			holds = append(holds, hold{to: r.EndTime, count: r.Count})
+		}
+	}
+
+	// Bookings hold capacity from ProvisionAt, before their window starts
+	listTo := to
+	if !listTo.IsZero() {
+		listTo = listTo.Add(cal.cfg.LeadTime)
+	}
+	bookings, err := cal.store.ListBookings(ctx, from, listTo, BookingScheduled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bookings: %w", err)
+	}
+	for _, b := range bookings {
+		if b.Request.ComputeType == computeType {
+			holds = append(holds, hold{from: b.ProvisionAt, to: b.EndTime, count: b.Request.Count})
+		}
+	}
+
+	return holds, nil
+}
+
+// slots splits [from, to) into spans over which the held capacity is
+// constant. A zero to leaves the last span open.
+func slots(holds []hold, from, to time.Time) []CalendarSlot {
+	inside := func(t time.Time) bool {
+		return !t.IsZero() && t.After(from) && (to.IsZero() || t.Before(to))
+	}
+
+	boundaries := []time.Time{from}
+	for _, h := range holds {
+		if inside(h.from) {
+			boundaries = append(boundaries, h.from)
+		}
+		if inside(h.to) {
+			boundaries = append(boundaries, h.to)
+		}
+	}
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i].Before(boundaries[j]) })
+
+	var result []CalendarSlot
+	for i, start := range boundaries {
+		if i > 0 && start.Equal(boundaries[i-1]) {
+			continue
+		}
+		end := to
+		for _, next := range boundaries[i+1:] {
+			if next.After(start) {
+				end = next
+				break
+			}
+		}
+
+		booked := 0
+		for _, h := range holds {
+			if h.covers(start) {
+				booked += h.count
+			}
+		}
+
+		if n := len(result); n > 0 && result[n-1].Booked == booked {
+			result[n-1].End = end
+			continue
+		}
+		result = append(result, CalendarSlot{Start: start, End: end, Booked: booked})
+	}
+	return result
+}
+
+// Run provisions and completes bookings every interval until ctx is canceled
+func (cal *BookingCalendar) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := cal.Check(ctx)
+			if err != nil {
+				log.Printf("Booking check failed: %v", err)
+				continue
+			}
+			if report.Provisioned+report.Completed+report.Failed > 0 {
+				log.Printf("Bookings: %d provisioned, %d completed, %d failed",
+					report.Provisioned, report.Completed, report.Failed)
+			}
+			for _, err := range report.Errors {
+				log.Printf("Bookings: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package compute
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBookingCalendar(t *testing.T, maxGPUs int) (*BookingCalendar, *ReservationClient, *lifecycleClock) {
+	t.Helper()
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{})
+	client := newLifecycleClient(t, NewMemoryReservationStore(), fake, clock)
+	client.maxGPUs = maxGPUs
+	return NewBookingCalendar(client, BookingConfig{LeadTime: 10 * time.Minute}), client, clock
+}
+
+func bookingRequest(model string, count int, start, end time.Time) *BookingRequest {
+	return &BookingRequest{
+		ReservationRequest: ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: model, Count: count, MaxCostPerHr: 20},
+		StartTime:          start,
+		EndTime:            end,
+	}
+}
+
+func TestBookingConflicts(t *testing.T) {
+	cal, client, clock := newBookingCalendar(t, 8)
+	ctx := context.Background()
+	start := clock.now().Add(24*time.Hour + 2*time.Hour)
+	end := start.Add(8 * time.Hour)
+
+	booking, err := cal.Book(ctx, "u1", bookingRequest("H100", 8, start, end))
+	require.NoError(t, err)
+	assert.Equal(t, BookingScheduled, booking.Status)
+	assert.Equal(t, start.Add(-10*time.Minute), booking.ProvisionAt)
+	assert.Equal(t, PolicyModerate, booking.CancellationPolicy)
+	assert.Equal(t, 160.0, booking.EstimatedCost)
+
+	_, err = cal.Book(ctx, "u2", bookingRequest("H100", 1, start.Add(7*time.Hour), end.Add(time.Hour)))
+	assert.ErrorIs(t, err, ErrBookingConflict)
+
+	// The lead time is booked too
+	_, err = cal.Book(ctx, "u2", bookingRequest("H100", 1, end.Add(5*time.Minute), end.Add(time.Hour)))
+	assert.ErrorIs(t, err, ErrBookingConflict)
+	_, err = cal.Book(ctx, "u2", bookingRequest("H100", 8, end.Add(10*time.Minute), end.Add(time.Hour)))
+	assert.NoError(t, err)
+
+	// On-demand reservations may not eat into booked capacity
+	res, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "A100", Count: 1, Duration: time.Hour})
+	require.NoError(t, err)
+	_, err = client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "A100", Count: 1})
+	assert.ErrorIs(t, err, ErrBookingConflict, "an open-ended reservation overlaps every booking")
+	_, err = client.Extend(ctx, res.ID, 26*time.Hour)
+	assert.ErrorIs(t, err, ErrBookingConflict)
+	_, err = client.Extend(ctx, res.ID, time.Hour)
+	assert.NoError(t, err)
+
+	// ... and bookings respect live reservations
+	_, err = cal.Book(ctx, "u2", bookingRequest("A100", 8, clock.now().Add(time.Hour), clock.now().Add(2*time.Hour)))
+	assert.ErrorIs(t, err, ErrBookingConflict)
+}
+
+func TestBookingValidation(t *testing.T) {
+	cal, _, clock := newBookingCalendar(t, 8)
+	ctx := context.Background()
+	now := clock.now()
+
+	_, err := cal.Book(ctx, "u1", bookingRequest("H100", 1, now.Add(-time.Hour), now.Add(time.Hour)))
+	assert.ErrorContains(t, err, "must be in the future")
+	_, err = cal.Book(ctx, "u1", bookingRequest("H100", 1, now.Add(2*time.Hour), now.Add(time.Hour)))
+	assert.ErrorContains(t, err, "must be after start_time")
+	_, err = cal.Book(ctx, "u1", bookingRequest("H100", 0, now.Add(time.Hour), now.Add(2*time.Hour)))
+	assert.ErrorContains(t, err, "at least 1")
+
+	req := bookingRequest("H100", 1, now.Add(time.Hour), now.Add(2*time.Hour))
+	req.CancellationPolicy = "lenient"
+	_, err = cal.Book(ctx, "u1", req)
+	assert.ErrorContains(t, err, "unknown cancellation policy")
+}
+
+func TestBookingHorizon(t *testing.T) {
+	cal, _, clock := newBookingCalendar(t, 8)
+	cal.cfg.Horizon = 30 * 24 * time.Hour
+	ctx := context.Background()
+	start := clock.now().Add(29 * 24 * time.Hour)
+
+	_, err := cal.Book(ctx, "u1", bookingRequest("H100", 1, start, start.Add(2*24*time.Hour)))
+	assert.ErrorContains(t, err, "end_time must be within 720h0m0s")
+	_, err = cal.Book(ctx, "u1", bookingRequest("H100", 1, start, start.Add(24*time.Hour)))
+	assert.NoError(t, err)
+}
+
+// slowCreateStore widens the gap between a reservation's capacity check and
+// its record being stored
+type slowCreateStore struct {
+	ReservationStore
+}
+
+func (s slowCreateStore) Create(ctx context.Context, r *Reservation) error {
+	time.Sleep(5 * time.Millisecond)
+	return s.ReservationStore.Create(ctx, r)
+}
+
+func TestBookingAndReserveDoNotOvercommit(t *testing.T) {
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{})
+	client := newLifecycleClient(t, slowCreateStore{NewMemoryReservationStore()}, fake, clock)
+	client.maxGPUs = 8
+	cal := NewBookingCalendar(client, BookingConfig{LeadTime: 10 * time.Minute})
+	ctx := context.Background()
+	start := clock.now().Add(time.Hour)
+
+	// Open-ended reservations overlap every booking, so bookings and
+	// reservations compete for the same 8 GPUs
+	var mu sync.Mutex
+	held := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := cal.Book(ctx, "u1", bookingRequest("H100", 1, start, start.Add(time.Hour))); err == nil {
+				mu.Lock()
+				held++
+				mu.Unlock()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "A100", Count: 1}); err == nil {
+				mu.Lock()
+				held++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// Reservations can still fail at the marketplace after claiming capacity,
+	// so fewer than 8 may be held, but never more
+	assert.LessOrEqual(t, held, 8)
+	assert.Positive(t, held)
+}
+
+func TestBookingProvisionsAndCompletes(t *testing.T) {
+	cal, client, clock := newBookingCalendar(t, 8)
+	scheduler := NewExpiryScheduler(client, ExpiryConfig{}, nil)
+	ctx := context.Background()
+	start := clock.now().Add(time.Hour)
+	end := start.Add(2 * time.Hour)
+
+	booking, err := cal.Book(ctx, "u1", bookingRequest("A100", 4, start, end))
+	require.NoError(t, err)
+
+	report, err := cal.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Provisioned)
+
+	clock.advance(50 * time.Minute)
+	report, err = cal.Check(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Provisioned)
+
+	booking, err = cal.Get(ctx, booking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, BookingActive, booking.Status)
+	res, err := client.GetReservation(booking.ReservationID)
+	require.NoError(t, err)
+	assert.Equal(t, booking.ID, res.BookingID)
+	assert.Equal(t, "u1", res.UserID)
+	assert.Equal(t, end, res.EndTime, "the reservation ends with the slot")
+	assert.Equal(t, 4, gpuCapacity(client))
+
+	report, err = cal.Check(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, report.Provisioned+report.Completed)
+
+	clock.advance(2*time.Hour + 10*time.Minute)
+	_, err = scheduler.Check(ctx)
+	require.NoError(t, err)
+	report, err = cal.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Completed)
+
+	booking, err = cal.Get(ctx, booking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, BookingCompleted, booking.Status)
+	assert.Equal(t, 0, gpuCapacity(client))
+}
+
+func TestBookingAdoptsReservationAfterCrash(t *testing.T) {
+	cal, client, clock := newBookingCalendar(t, 8)
+	ctx := context.Background()
+
+	booking, err := cal.Book(ctx, "u1", bookingRequest("A100", 1, clock.now().Add(5*time.Minute), clock.now().Add(time.Hour)))
+	require.NoError(t, err)
+
+	// The reservation was made but the booking never recorded it
+	req := booking.Request
+	req.BookingID = booking.ID
+	res, err := client.Reserve(ctx, &req)
+	require.NoError(t, err)
+
+	report, err := cal.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Provisioned)
+
+	booking, err = cal.Get(ctx, booking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, res.ID, booking.ReservationID)
+	assert.Equal(t, 1, gpuCapacity(client))
+}
+
+func TestBookingRetriesUntilSlotEnds(t *testing.T) {
+	cal, _, clock := newBookingCalendar(t, 8)
+	ctx := context.Background()
+
+	booking, err := cal.Book(ctx, "u1", bookingRequest("B200", 1, clock.now().Add(time.Minute), clock.now().Add(time.Hour)))
+	require.NoError(t, err)
+
+	report, err := cal.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Provisioned)
+	require.Len(t, report.Errors, 1)
+
+	booking, err = cal.Get(ctx, booking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, BookingScheduled, booking.Status)
+	assert.Contains(t, booking.LastError, "no fake offers match")
+
+	clock.advance(time.Hour)
+	report, err = cal.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Failed)
+
+	booking, err = cal.Get(ctx, booking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, BookingFailed, booking.Status)
+	assert.Contains(t, booking.FailureReason, "slot ended")
+}
+
+func TestBookingCancellation(t *testing.T) {
+	cal, client, clock := newBookingCalendar(t, 8)
+	ctx := context.Background()
+	now := clock.now()
+
+	early, err := cal.Book(ctx, "u1", bookingRequest("H100", 1, now.Add(48*time.Hour), now.Add(50*time.Hour)))
+	require.NoError(t, err)
+	canceled, err := cal.Cancel(ctx, early.ID)
+	require.NoError(t, err)
+	assert.Equal(t, BookingCanceled, canceled.Status)
+	assert.Zero(t, canceled.CancellationFee, "moderate bookings cancel free with a day's notice")
+
+	_, err = cal.Cancel(ctx, early.ID)
+	assert.ErrorContains(t, err, "already canceled")
+
+	req := bookingRequest("H100", 1, now.Add(48*time.Hour), now.Add(50*time.Hour))
+	req.CancellationPolicy = PolicyStrict
+	strict, err := cal.Book(ctx, "u1", req)
+	require.NoError(t, err)
+	canceled, err = cal.Cancel(ctx, strict.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 40.0, canceled.CancellationFee)
+
+	// Canceling during the slot releases its reservation
+	active, err := cal.Book(ctx, "u1", bookingRequest("A100", 2, now.Add(time.Minute), now.Add(time.Hour+time.Minute)))
+	require.NoError(t, err)
+	_, err = cal.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, gpuCapacity(client))
+
+	canceled, err = cal.Cancel(ctx, active.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, canceled.CancellationFee)
+	res, err := client.GetReservation(canceled.ReservationID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusTerminated, res.Status)
+	assert.Equal(t, ReleaseBookingCanceled, res.ReleaseReason)
+	assert.Equal(t, 0, gpuCapacity(client))
+}
+
+func TestBookingCalendarView(t *testing.T) {
+	cal, _, clock := newBookingCalendar(t, 8)
+	ctx := context.Background()
+	day := clock.now().Add(24 * time.Hour)
+
+	mine, err := cal.Book(ctx, "u1", bookingRequest("H100", 3, day.Add(2*time.Hour), day.Add(10*time.Hour)))
+	require.NoError(t, err)
+	_, err = cal.Book(ctx, "u2", bookingRequest("H100", 5, day.Add(4*time.Hour), day.Add(6*time.Hour)))
+	require.NoError(t, err)
+
+	view, err := cal.Calendar(ctx, ComputeGPU, day, day.Add(12*time.Hour), "u1")
+	require.NoError(t, err)
+	assert.Equal(t, 8, view.Capacity)
+
+	lead := 10 * time.Minute
+	want := []CalendarSlot{
+		{Start: day, End: day.Add(2*time.Hour - lead), Booked: 0, Available: 8},
+		{Start: day.Add(2*time.Hour - lead), End: day.Add(4*time.Hour - lead), Booked: 3, Available: 5},
+		{Start: day.Add(4*time.Hour - lead), End: day.Add(6 * time.Hour), Booked: 8, Available: 0},
+		{Start: day.Add(6 * time.Hour), End: day.Add(10 * time.Hour), Booked: 3, Available: 5},
+		{Start: day.Add(10 * time.Hour), End: day.Add(12 * time.Hour), Booked: 0, Available: 8},
+	}
+	assert.Equal(t, want, view.Slots)
+
+	require.Len(t, view.Bookings, 2)
+	assert.Equal(t, mine.ID, view.Bookings[0].BookingID)
+	assert.True(t, view.Bookings[0].Mine)
+	assert.Empty(t, view.Bookings[1].BookingID, "other users' bookings are anonymous")
+	assert.False(t, view.Bookings[1].Mine)
+
+	_, err = cal.Calendar(ctx, ComputeGPU, day, day.Add(365*24*time.Hour), "u1")
+	assert.ErrorContains(t, err, "limited to")
+}
@@ -0,0 +1,206 @@
+package compute
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/loadbalancer"
+)
+
+// ExpiryConfig controls when reservations are warned about, expired and reaped
+type ExpiryConfig struct {
+	WarnBefore  []time.Duration // Warn owners this long before EndTime
+	IdleTimeout time.Duration   // Release active reservations idle this long; 0 disables reaping
+}
+
+// LoadSource reports per-instance connection counts, e.g. the load balancer.
+// The proxies record requests to a reserved instance under its InstanceID.
+type LoadSource interface {
+	GetAllLoads() map[string]*loadbalancer.InstanceLoad
+}
+
+// ExpiryScheduler ends reservations that outlive their EndTime or sit idle.
+// Owners are warned before expiry; a reservation extended in the meantime
+// is warned again before its new end time.
+type ExpiryScheduler struct {
+	client *ReservationClient
+	cfg    ExpiryConfig
+	loads  LoadSource
+
+	mu        sync.Mutex
+	idleSince map[string]time.Time // reservation ID -> first check that saw no connections
+}
+
+// ExpiryReport counts what one scheduler pass did
+type ExpiryReport struct {
+	Warned  int
+	Expired int
+	Reaped  int
+	Errors  []error
+}
+
+// NewExpiryScheduler creates a scheduler for client's reservations. loads may
+// be nil, which disables idle reaping.
+func NewExpiryScheduler(client *ReservationClient, cfg ExpiryConfig, loads LoadSource) *ExpiryScheduler {
+	warnBefore := append([]time.Duration(nil), cfg.WarnBefore...)
+	sort.Slice(warnBefore, func(i, j int) bool { return warnBefore[i] > warnBefore[j] })
+	cfg.WarnBefore = warnBefore
+
+	return &ExpiryScheduler{
+		client:    client,
+		cfg:       cfg,
+		loads:     loads,
+		idleSince: make(map[string]time.Time),
+	}
+}
+
+// Check warns, expires and reaps reservations once
+func (s *ExpiryScheduler) Check(ctx context.Context) (*ExpiryReport, error) {
+	reservations, err := s.client.store.List(ctx, StatusProvisioning, StatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reservations: %w", err)
+	}
+
+	var loads map[string]*loadbalancer.InstanceLoad
+	if s.loads != nil && s.cfg.IdleTimeout > 0 {
+		loads = s.loads.GetAllLoads()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &ExpiryReport{}
+	now := s.client.now()
+	live := make(map[string]bool, len(reservations))
+	for _, r := range reservations {
+		live[r.ID] = true
+
+		if !r.EndTime.IsZero() && !now.Before(r.EndTime) {
+			if s.end(ctx, r, ReleaseExpired, EventExpired, report) {
+				report.Expired++
+			}
+			continue
+		}
+		if !r.EndTime.IsZero() {
+			s.warn(ctx, r, now, report)
+		}
+		if loads != nil && r.Status == StatusActive && s.idle(r, loads, now) {
+			if s.end(ctx, r, ReleaseIdle, EventIdleReleased, report) {
+				report.Reaped++
+			}
+		}
+	}
+
+	for id := range s.idleSince {
+		if !live[id] {
+			delete(s.idleSince, id)
+		}
+	}
+
+	return report, nil
+}
+
+// warn sends one warning for the expiry thresholds r has crossed since the
+// last warning. The thresholds are recorded before the owner is notified,
+// so a restart never repeats a warning.
+func (s *ExpiryScheduler) warn(ctx context.Context, r *Reservation, now time.Time, report *ExpiryReport) {
+	remaining := r.EndTime.Sub(now)
+
+	var crossed []time.Duration
+	for _, before := range s.cfg.WarnBefore {
+		if remaining <= before && !containsDuration(r.WarningsSent, before) {
+			crossed = append(crossed, before)
+		}
+	}
+	if len(crossed) == 0 {
+		return
+	}
+
+	ok, err := s.client.save(ctx, r, func(next *Reservation) {
+		next.WarningsSent = append(next.WarningsSent, crossed...)
+	})
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("reservation %s: %w", r.ID, err))
+		return
+	}
+	if !ok {
+		return // changed underneath us; the next pass sees the new state
+	}
+
+	s.client.notify(ctx, newReservationEvent(EventExpiryWarning, r, now))
+	report.Warned++
+}
+
+// idle reports whether r's instance has had no connections for the idle
+// timeout. Instances the load source does not track are never idle.
+func (s *ExpiryScheduler) idle(r *Reservation, loads map[string]*loadbalancer.InstanceLoad, now time.Time) bool {
+	load, ok := loads[r.InstanceID]
+	if !ok || load.ActiveConnections > 0 {
+		delete(s.idleSince, r.ID)
+		return false
+	}
+
+	since, ok := s.idleSince[r.ID]
+	if !ok {
+		s.idleSince[r.ID] = now
+		return false
+	}
+	return now.Sub(since) >= s.cfg.IdleTimeout
+}
+
+// end releases r and tells its owner why. It reports whether r was ended.
+func (s *ExpiryScheduler) end(ctx context.Context, r *Reservation, reason, eventType string, report *ExpiryReport) bool {
+	err := s.client.release(ctx, r.ID, reason)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("reservation %s: %w", r.ID, err))
+	}
+
+	// A failed provider call leaves the reservation draining for the
+	// reconciler; it has still ended as far as the owner is concerned
+	current, getErr := s.client.store.Get(ctx, r.ID)
+	if getErr != nil || current.ReleaseReason != reason {
+		return false
+	}
+
+	delete(s.idleSince, r.ID)
+	s.client.notify(ctx, newReservationEvent(eventType, current, s.client.now()))
+	return true
+}
+
+// Run checks every interval until ctx is canceled
+func (s *ExpiryScheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := s.Check(ctx)
+			if err != nil {
+				log.Printf("Reservation expiry check failed: %v", err)
+				continue
+			}
+			if report.Expired+report.Reaped > 0 {
+				log.Printf("Reservation expiry: %d expired, %d idle reservations released", report.Expired, report.Reaped)
+			}
+			for _, err := range report.Errors {
+				log.Printf("Reservation expiry: %v", err)
+			}
+		}
+	}
+}
+
+func containsDuration(list []time.Duration, d time.Duration) bool {
+	for _, v := range list {
+		if v == d {
+			return true
+		}
+	}
+	return false
+}
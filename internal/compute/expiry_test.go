@@ -0,0 +1,219 @@
+package compute
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/loadbalancer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventRecorder collects notified events
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []ReservationEvent
+}
+
+func (r *eventRecorder) Notify(ctx context.Context, event ReservationEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *eventRecorder) types() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var types []string
+	for _, e := range r.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestExpirySchedulerWarnsAndExpires(t *testing.T) {
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{})
+	client := newLifecycleClient(t, NewMemoryReservationStore(), fake, clock)
+	events := &eventRecorder{}
+	client.AddNotifier(events)
+	scheduler := NewExpiryScheduler(client, ExpiryConfig{WarnBefore: []time.Duration{5 * time.Minute, 15 * time.Minute}}, nil)
+	ctx := context.Background()
+
+	res, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "A100", Count: 1, Duration: time.Hour, UserID: "u1"})
+	require.NoError(t, err)
+	assert.Equal(t, clock.now().Add(time.Hour), res.EndTime)
+
+	report, err := scheduler.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Warned)
+
+	clock.advance(46 * time.Minute)
+	report, err = scheduler.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Warned)
+	require.Equal(t, []string{EventExpiryWarning}, events.types())
+	assert.Equal(t, "u1", events.events[0].UserID)
+	assert.Equal(t, int64(14*60), events.events[0].ExpiresIn)
+
+	// Each threshold warns once
+	report, err = scheduler.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Warned)
+
+	// Extending re-arms the warnings
+	extended, err := client.Extend(ctx, res.ID, 30*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, res.EndTime.Add(30*time.Minute), extended.EndTime)
+	assert.Empty(t, extended.WarningsSent)
+
+	clock.advance(40 * time.Minute) // 4 minutes left: both thresholds crossed, one warning
+	report, err = scheduler.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Warned)
+
+	clock.advance(4 * time.Minute)
+	report, err = scheduler.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Expired)
+	assert.Equal(t, []string{EventExpiryWarning, EventExtended, EventExpiryWarning, EventExpired}, events.types())
+
+	got, err := client.GetReservation(res.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusTerminated, got.Status)
+	assert.Equal(t, ReleaseExpired, got.ReleaseReason)
+	assert.Empty(t, fake.Instances())
+	assert.Equal(t, 0, gpuCapacity(client))
+
+	_, err = client.Extend(ctx, res.ID, time.Hour)
+	assert.ErrorContains(t, err, "cannot be extended")
+}
+
+func TestExtendRequiresEndTime(t *testing.T) {
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{})
+	client := newLifecycleClient(t, NewMemoryReservationStore(), fake, clock)
+
+	res, err := client.Reserve(context.Background(), &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "A100"})
+	require.NoError(t, err)
+
+	_, err = client.Extend(context.Background(), res.ID, time.Hour)
+	assert.ErrorContains(t, err, "has no end time")
+	_, err = client.Extend(context.Background(), res.ID, -time.Hour)
+	assert.ErrorContains(t, err, "must be positive")
+}
+
+func TestExpirySchedulerReapsIdleReservations(t *testing.T) {
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{})
+	client := newLifecycleClient(t, NewMemoryReservationStore(), fake, clock)
+	events := &eventRecorder{}
+	client.AddNotifier(events)
+	ctx := context.Background()
+
+	busy, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "A100", UserID: "u1"})
+	require.NoError(t, err)
+	idle, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "T4", UserID: "u1"})
+	require.NoError(t, err)
+	untracked, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "H100", UserID: "u1"})
+	require.NoError(t, err)
+	_, err = client.Reconcile(ctx)
+	require.NoError(t, err)
+
+	// Proxies look up the caller's reservation and count its requests
+	// under the reservation's instance ID
+	idle, err = client.UserInstance(ctx, "u1", idle.InstanceID)
+	require.NoError(t, err)
+	_, err = client.UserInstance(ctx, "u2", idle.InstanceID)
+	assert.ErrorIs(t, err, ErrReservationNotFound, "other users' instances are not matched")
+
+	lb := loadbalancer.NewLoadBalancerService(loadbalancer.StrategyLeastConnections)
+	lb.TrackConnection(busy.InstanceID)
+	lb.TrackConnection(busy.InstanceID)
+	lb.TrackConnection(idle.InstanceID)
+	lb.TrackDisconnection(idle.InstanceID)
+	scheduler := NewExpiryScheduler(client, ExpiryConfig{IdleTimeout: 30 * time.Minute}, lb)
+
+	report, err := scheduler.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Reaped, "the idle window starts now")
+
+	// A connection resets the idle window
+	clock.advance(20 * time.Minute)
+	lb.TrackConnection(idle.InstanceID)
+	_, err = scheduler.Check(ctx)
+	require.NoError(t, err)
+	lb.TrackDisconnection(idle.InstanceID)
+	_, err = scheduler.Check(ctx)
+	require.NoError(t, err)
+
+	clock.advance(29 * time.Minute)
+	report, err = scheduler.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Reaped)
+
+	clock.advance(time.Minute)
+	report, err = scheduler.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Reaped)
+	assert.Equal(t, []string{EventIdleReleased}, events.types())
+
+	got, err := client.GetReservation(idle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusTerminated, got.Status)
+	assert.Equal(t, ReleaseIdle, got.ReleaseReason)
+
+	for _, id := range []string{busy.ID, untracked.ID} {
+		got, err := client.GetReservation(id)
+		require.NoError(t, err)
+		assert.Equal(t, StatusActive, got.Status)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]ReservationEvent)
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var event ReservationEvent
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+			mu.Lock()
+			received[name] = event
+			mu.Unlock()
+		}
+	}
+	owner := httptest.NewServer(handler("owner"))
+	defer owner.Close()
+	ops := httptest.NewServer(handler("ops"))
+	defer ops.Close()
+
+	notifier := NewWebhookNotifier(ops.URL)
+	notifier.SetPolicy(NewWebhookPolicy("127.0.0.1"))
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := &Reservation{ID: "res-1", Status: StatusActive, EndTime: now.Add(10 * time.Minute), WebhookURL: owner.URL}
+	require.NoError(t, notifier.Notify(context.Background(), newReservationEvent(EventExpiryWarning, r, now)))
+
+	require.Len(t, received, 2)
+	assert.Equal(t, "res-1", received["owner"].ReservationID)
+	assert.Equal(t, int64(600), received["ops"].ExpiresIn)
+
+	// Without the allowlist an owner webhook on loopback is never called, but
+	// the operator's still is
+	received = make(map[string]ReservationEvent)
+	err := NewWebhookNotifier(ops.URL).Notify(context.Background(), newReservationEvent(EventExpired, r, now))
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+	assert.Len(t, received, 1)
+	assert.Contains(t, received, "ops")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	r.WebhookURL = ""
+	err = NewWebhookNotifier(failing.URL).Notify(context.Background(), newReservationEvent(EventExpired, r, now))
+	assert.ErrorContains(t, err, "status 500")
+}
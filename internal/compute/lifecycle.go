@@ -0,0 +1,380 @@
+package compute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/gpu"
+)
+
+// Reservation statuses. A reservation moves
+// pending → provisioning → active → draining → terminated, and may fail from
+// any non-final status. Terminated and failed are final. A request made when
+// capacity is exhausted may start out queued.
+const (
+	StatusQueued       = "queued"       // Waiting for capacity; holds none
+	StatusPending      = "pending"      // Recorded, provider not yet called
+	StatusProvisioning = "provisioning" // Instance created, not yet running
+	StatusActive       = "active"       // Instance running
+	StatusDraining     = "draining"     // Being released; instance may still exist
+	StatusTerminated   = "terminated"   // Released
+	StatusFailed       = "failed"       // Ended without a normal release
+)
+
+// transitions lists the statuses each status may move to
+var transitions = map[string][]string{
+	StatusQueued:       {StatusPending, StatusTerminated, StatusFailed},
+	StatusPending:      {StatusProvisioning, StatusActive, StatusDraining, StatusFailed},
+	StatusProvisioning: {StatusActive, StatusDraining, StatusFailed},
+	StatusActive:       {StatusDraining, StatusFailed},
+	StatusDraining:     {StatusTerminated, StatusFailed},
+}
+
+// liveStatuses are the statuses that hold capacity and a port
+var liveStatuses = []string{StatusPending, StatusProvisioning, StatusActive, StatusDraining}
+
+// isLive reports whether a status holds capacity and a port
+func isLive(status string) bool {
+	return containsStatus(liveStatuses, status)
+}
+
+// DefaultPendingTimeout is how long a reservation may stay pending before
+// the reconciler fails it
+const DefaultPendingTimeout = 10 * time.Minute
+
+// canTransition reports whether a reservation may move from one status to another
+func canTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// isFinal reports whether a status is terminated or failed
+func isFinal(status string) bool {
+	return status == StatusTerminated || status == StatusFailed
+}
+
+// transition moves r to status to, applying update to the new copy first.
+// Like every save it is a compare-and-swap on r's version, so when several
+// callers race only one wins. The winner of a move into a final status
+// releases the capacity and port the reservation held; since final statuses
+// have no way out, this happens exactly once per reservation. On success r
+// is updated in place.
+func (c *ReservationClient) transition(ctx context.Context, r *Reservation, to string, update func(*Reservation)) (bool, error) {
+	if !canTransition(r.Status, to) {
+		return false, fmt.Errorf("reservation %s cannot move from %s to %s", r.ID, r.Status, to)
+	}
+
+	return c.save(ctx, r, func(next *Reservation) {
+		next.Status = to
+		if isFinal(to) {
+			next.EndTime = next.UpdatedAt
+		}
+		if update != nil {
+			update(next)
+		}
+	})
+}
+
+// save writes a copy of r changed by update if nobody saved r since it was
+// read. On success r is updated in place.
+func (c *ReservationClient) save(ctx context.Context, r *Reservation, update func(*Reservation)) (bool, error) {
+	next := r.clone()
+	next.Version = r.Version + 1
+	next.UpdatedAt = c.now()
+	update(next)
+
+	ok, err := c.store.CompareAndSwap(ctx, r.Version, next)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if isFinal(next.Status) && isLive(r.Status) {
+		c.releaseCapacity(next)
+	}
+	if r.Status == StatusQueued && next.Status != StatusQueued {
+		c.mu.Lock()
+		c.queued[next.ComputeType]--
+		c.mu.Unlock()
+	}
+	*r = *next
+	return true, nil
+}
+
+// fail moves r to failed, draining and destroying its instance first if it has one
+func (c *ReservationClient) fail(ctx context.Context, r *Reservation, reason string) error {
+	setReason := func(next *Reservation) { next.FailureReason = reason }
+
+	if r.InstanceID == "" {
+		_, err := c.transition(ctx, r, StatusFailed, setReason)
+		return err
+	}
+
+	ok, err := c.transition(ctx, r, StatusDraining, setReason)
+	if err != nil || !ok {
+		return err
+	}
+	return c.finishDrain(ctx, r)
+}
+
+// finishDrain destroys a draining reservation's instance and moves it to
+// terminated, or to failed if it was draining because of a failure. If the
+// provider call fails the reservation stays draining for the reconciler to retry.
+func (c *ReservationClient) finishDrain(ctx context.Context, r *Reservation) error {
+	if err := c.destroy(ctx, r); err != nil {
+		return fmt.Errorf("failed to release reservation: %w", err)
+	}
+
+	final := StatusTerminated
+	if r.FailureReason != "" {
+		final = StatusFailed
+	}
+	_, err := c.transition(ctx, r, final, nil)
+	return err
+}
+
+// destroy releases r's provider instance. An instance the provider no longer
+// knows about counts as destroyed.
+func (c *ReservationClient) destroy(ctx context.Context, r *Reservation) error {
+	if r.InstanceID == "" {
+		return nil
+	}
+
+	var err error
+	switch r.Provider {
+	case ProviderOpenRouter:
+		// OpenRouter doesn't need instance cleanup
+		err = nil
+	case ProviderLocal:
+		err = c.releaseLocal(ctx, r.InstanceID)
+	default:
+		if marketplace, ok := c.marketplace(r.Provider); ok {
+			err = marketplace.DestroyInstance(ctx, r.InstanceID)
+		}
+	}
+	if err == nil || errors.Is(err, gpu.ErrInstanceNotFound) {
+		return nil
+	}
+
+	// Destroying an instance that is already gone is not an error
+	if status, statusErr := c.instanceStatus(ctx, r); statusErr == nil && status == "terminated" {
+		return nil
+	}
+	return err
+}
+
+// instanceStatus asks r's provider for the status of its instance
+func (c *ReservationClient) instanceStatus(ctx context.Context, r *Reservation) (string, error) {
+	if marketplace, ok := c.marketplace(r.Provider); ok {
+		return marketplace.InstanceStatus(ctx, r.InstanceID)
+	}
+	return "", gpu.ErrStatusNotSupported
+}
+
+// ReconcileReport counts the repairs made by one reconcile pass
+type ReconcileReport struct {
+	Activated  int // provisioning reservations whose instance came up
+	Failed     int // reservations failed because their instance died or never appeared
+	Terminated int // draining reservations whose release was retried successfully
+	Orphans    int // provider instances no reservation owns, destroyed
+	Errors     []error
+}
+
+// Changed reports whether the pass repaired anything
+func (r *ReconcileReport) Changed() bool {
+	return r.Activated+r.Failed+r.Terminated+r.Orphans > 0
+}
+
+// Reconcile compares stored reservations with provider status once and
+// repairs drift: provisioning instances that came up are activated, dead
+// instances are released and failed, reservations stuck in pending are
+// failed, interrupted releases are retried, and instances no reservation
+// owns are destroyed.
+func (c *ReservationClient) Reconcile(ctx context.Context) (*ReconcileReport, error) {
+	report := &ReconcileReport{}
+
+	// Snapshot provider instances before reading the store. The snapshot is
+	// only used if no reserve was in flight or started while it was taken, so
+	// every snapshotted instance created by this client was already recorded
+	// when the store is read.
+	snapshot := c.snapshotInstances(ctx, report)
+
+	reservations, err := c.store.List(ctx, liveStatuses...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reservations: %w", err)
+	}
+
+	owned := make(map[string]bool)
+	for _, r := range reservations {
+		if r.InstanceID != "" {
+			owned[string(r.Provider)+"/"+r.InstanceID] = true
+		}
+		if err := c.reconcileOne(ctx, r, report); err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("reservation %s: %w", r.ID, err))
+		}
+	}
+
+	for name, instances := range snapshot {
+		marketplace, _ := c.marketplace(name)
+		for _, id := range instances {
+			if owned[string(name)+"/"+id] {
+				continue
+			}
+			if err := marketplace.DestroyInstance(ctx, id); err != nil {
+				if errors.Is(err, gpu.ErrInstanceNotFound) {
+					continue
+				}
+				if status, statusErr := marketplace.InstanceStatus(ctx, id); statusErr == nil && status == "terminated" {
+					continue // released since the snapshot
+				}
+				report.Errors = append(report.Errors, fmt.Errorf("orphaned %s instance %s: %w", name, id, err))
+				continue
+			}
+			report.Orphans++
+		}
+	}
+
+	return report, nil
+}
+
+// snapshotInstances lists the instances rented from every marketplace that
+// supports it, or returns nil if a reserve overlapped the listing
+func (c *ReservationClient) snapshotInstances(ctx context.Context, report *ReconcileReport) map[ComputeProvider][]string {
+	c.mu.RLock()
+	idle, started := c.reserving == 0, c.reserveStarts
+	listers := make(map[ComputeProvider]gpu.InstanceLister)
+	for name, marketplace := range c.marketplaces {
+		if lister, ok := marketplace.(gpu.InstanceLister); ok {
+			listers[name] = lister
+		}
+	}
+	c.mu.RUnlock()
+	if c.vastAIClient != nil && c.vastAIClient.Configured() {
+		listers[ProviderVastAI] = c.vastAIClient
+	}
+	if c.ionetClient != nil && c.ionetClient.Configured() {
+		listers[ProviderIONet] = c.ionetClient
+	}
+	if !idle {
+		return nil
+	}
+
+	snapshot := make(map[ComputeProvider][]string, len(listers))
+	for name, lister := range listers {
+		ids, err := lister.ListRentedInstances(ctx)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("failed to list %s instances: %w", name, err))
+			continue
+		}
+		snapshot[name] = ids
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.reserving != 0 || c.reserveStarts != started {
+		return nil
+	}
+	return snapshot
+}
+
+func (c *ReservationClient) reconcileOne(ctx context.Context, r *Reservation, report *ReconcileReport) error {
+	switch r.Status {
+	case StatusPending:
+		// Queued reservations become pending when dispatched, so time it
+		// from the last save rather than creation
+		since := r.CreatedAt
+		if r.UpdatedAt.After(since) {
+			since = r.UpdatedAt
+		}
+		if c.now().Sub(since) < c.pendingTimeout {
+			return nil
+		}
+		ok, err := c.transition(ctx, r, StatusFailed, func(next *Reservation) {
+			next.FailureReason = "stuck in pending"
+		})
+		if ok {
+			report.Failed++
+		}
+		return err
+
+	case StatusDraining:
+		wasFailure := r.FailureReason != ""
+		if err := c.finishDrain(ctx, r); err != nil {
+			return err
+		}
+		if wasFailure {
+			report.Failed++
+		} else {
+			report.Terminated++
+		}
+		return nil
+
+	case StatusProvisioning, StatusActive:
+		status, err := c.instanceStatus(ctx, r)
+		if errors.Is(err, gpu.ErrStatusNotSupported) {
+			return nil
+		}
+		if errors.Is(err, gpu.ErrInstanceNotFound) {
+			ok, err := c.transition(ctx, r, StatusFailed, func(next *Reservation) {
+				next.FailureReason = "instance no longer exists at provider"
+			})
+			if ok {
+				report.Failed++
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		switch status {
+		case "running":
+			if r.Status != StatusProvisioning {
+				return nil
+			}
+			ok, err := c.transition(ctx, r, StatusActive, nil)
+			if ok {
+				report.Activated++
+			}
+			return err
+		case "failed", "preempted", "terminated", "exited":
+			if err := c.fail(ctx, r, "instance "+status); err != nil {
+				return err
+			}
+			report.Failed++
+		}
+	}
+	return nil
+}
+
+// RunReconciler reconciles every interval until ctx is canceled
+func (c *ReservationClient) RunReconciler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := c.Reconcile(ctx)
+			if err != nil {
+				log.Printf("Reservation reconcile failed: %v", err)
+				continue
+			}
+			if report.Changed() {
+				log.Printf("Reservation reconcile: %d activated, %d failed, %d terminated, %d orphans destroyed",
+					report.Activated, report.Failed, report.Terminated, report.Orphans)
+			}
+			for _, err := range report.Errors {
+				log.Printf("Reservation reconcile: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package compute
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/gpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lifecycleClock is a manually advanced clock shared by the client and the fake
+type lifecycleClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *lifecycleClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *lifecycleClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newLifecycleClient(t *testing.T, store ReservationStore, fake *gpu.FakeMarketplace, clock *lifecycleClock) *ReservationClient {
+	t.Helper()
+	client, err := NewReservationClientWithStore(store, "", "", "")
+	require.NoError(t, err)
+	client.SetClock(clock.now)
+	client.RegisterMarketplace(ProviderFake, fake)
+	return client
+}
+
+func newLifecycleFake(t *testing.T, cfg config.FakeMarketplaceConfig) (*gpu.FakeMarketplace, *lifecycleClock) {
+	t.Helper()
+	fake, err := gpu.NewFakeMarketplace(cfg)
+	require.NoError(t, err)
+	clock := &lifecycleClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	fake.SetClock(clock.now)
+	return fake, clock
+}
+
+func gpuCapacity(c *ReservationClient) int {
+	return c.GetCapacity()["gpus"].(map[string]int)["active"]
+}
+
+func TestReservationLifecycle(t *testing.T) {
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{BootDelay: time.Minute})
+	client := newLifecycleClient(t, NewMemoryReservationStore(), fake, clock)
+	ctx := context.Background()
+
+	res, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "A100", Count: 1})
+	require.NoError(t, err)
+	assert.Equal(t, StatusProvisioning, res.Status)
+	assert.Equal(t, 1, gpuCapacity(client))
+	assert.Empty(t, client.ListReservations(), "only active reservations are listed")
+
+	// Still booting: nothing to repair
+	report, err := client.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.False(t, report.Changed())
+
+	clock.advance(time.Minute)
+	report, err = client.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Activated)
+	require.Len(t, client.ListReservations(), 1)
+
+	require.NoError(t, client.Release(ctx, res.ID))
+	got, err := client.GetReservation(res.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusTerminated, got.Status)
+	assert.Equal(t, 0, gpuCapacity(client))
+	assert.False(t, client.portAllocator.IsAllocated(res.Port))
+	assert.Empty(t, fake.Instances())
+
+	assert.ErrorContains(t, client.Release(ctx, res.ID), "already terminated")
+	assert.Equal(t, 0, gpuCapacity(client))
+}
+
+func TestConcurrentReleaseFreesCapacityOnce(t *testing.T) {
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{})
+	client := newLifecycleClient(t, NewMemoryReservationStore(), fake, clock)
+	ctx := context.Background()
+
+	res, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "H100", Count: 8})
+	require.NoError(t, err)
+	other, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "T4", Count: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 9, gpuCapacity(client))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Release(ctx, res.ID)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, gpuCapacity(client), "the 8 GPUs are returned exactly once")
+	assert.True(t, client.portAllocator.IsAllocated(other.Port))
+	assert.Equal(t, []string{other.InstanceID}, fake.Instances())
+}
+
+func TestReconcileFailsDeadInstances(t *testing.T) {
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{})
+	client := newLifecycleClient(t, NewMemoryReservationStore(), fake, clock)
+	ctx := context.Background()
+
+	res, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "A100", Count: 1})
+	require.NoError(t, err)
+	require.NoError(t, fake.Preempt(res.InstanceID))
+
+	report, err := client.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Failed)
+
+	got, err := client.GetReservation(res.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, got.Status)
+	assert.Equal(t, "instance preempted", got.FailureReason)
+	assert.Empty(t, fake.Instances(), "the preempted instance is destroyed")
+	assert.Equal(t, 0, gpuCapacity(client))
+
+	// A second pass finds nothing left to do
+	report, err = client.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.False(t, report.Changed())
+	assert.Equal(t, 0, gpuCapacity(client))
+}
+
+func TestReconcileRepairsDriftAfterRestart(t *testing.T) {
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{})
+	path := filepath.Join(t.TempDir(), "reservations.db")
+	ctx := context.Background()
+
+	store := openSQLiteStore(t, path)
+	client := newLifecycleClient(t, store, fake, clock)
+
+	kept, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "A100", Count: 1})
+	require.NoError(t, err)
+
+	// A crash mid-reserve leaves a pending record, and an instance created
+	// before the crash that nothing recorded
+	stuck := &Reservation{ID: "res-stuck", Provider: ProviderFake, ComputeType: ComputeGPU, Status: StatusPending, Count: 4, Port: 9000, CreatedAt: clock.now()}
+	require.NoError(t, store.Create(ctx, stuck))
+	offers, err := fake.ListOffers(ctx)
+	require.NoError(t, err)
+	orphan, err := fake.CreateInstance(ctx, offers[0].ID, nil)
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+
+	// The restarted client restores counters and ports from the store
+	store = openSQLiteStore(t, path)
+	client = newLifecycleClient(t, store, fake, clock)
+	defer client.Close()
+	assert.Equal(t, 5, gpuCapacity(client))
+	assert.True(t, client.portAllocator.IsAllocated(kept.Port))
+	assert.True(t, client.portAllocator.IsAllocated(9000))
+
+	report, err := client.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Orphans)
+	assert.Equal(t, 1, report.Activated)
+	assert.Equal(t, 0, report.Failed, "pending reservations get time to finish")
+	assert.Equal(t, []string{kept.InstanceID}, fake.Instances())
+
+	clock.advance(DefaultPendingTimeout)
+	report, err = client.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Failed)
+	assert.NotContains(t, fake.Instances(), orphan)
+
+	got, err := client.GetReservation(stuck.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, got.Status)
+	assert.Equal(t, "stuck in pending", got.FailureReason)
+	assert.Equal(t, 1, gpuCapacity(client))
+	assert.False(t, client.portAllocator.IsAllocated(9000))
+}
+
+func TestReleaseRetriedByReconciler(t *testing.T) {
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{})
+	store := NewMemoryReservationStore()
+	client := newLifecycleClient(t, store, fake, clock)
+	ctx := context.Background()
+
+	res, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "A100", Count: 1})
+	require.NoError(t, err)
+
+	// Simulate a release interrupted after draining began
+	draining := res.clone()
+	ok, err := client.transition(ctx, draining, StatusDraining, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 1, gpuCapacity(client), "draining reservations still hold capacity")
+
+	report, err := client.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Terminated)
+	assert.Empty(t, fake.Instances())
+	assert.Equal(t, 0, gpuCapacity(client))
+}
+
+func TestReserveFailureIsRecorded(t *testing.T) {
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{FailureRate: 1})
+	store := NewMemoryReservationStore()
+	client := newLifecycleClient(t, store, fake, clock)
+	ctx := context.Background()
+
+	_, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "A100", Count: 1})
+	require.Error(t, err)
+
+	failed, err := store.List(ctx, StatusFailed)
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Contains(t, failed[0].FailureReason, "failed to create fake instance")
+	assert.Equal(t, 0, gpuCapacity(client))
+	assert.Equal(t, 0, client.portAllocator.GetAllocatedCount())
+}
+
+func TestTransitionsAreValidated(t *testing.T) {
+	assert.True(t, canTransition(StatusPending, StatusProvisioning))
+	assert.True(t, canTransition(StatusActive, StatusDraining))
+	assert.False(t, canTransition(StatusActive, StatusPending))
+	assert.False(t, canTransition(StatusTerminated, StatusFailed), "final statuses are final")
+	assert.False(t, canTransition(StatusFailed, StatusDraining))
+}
@@ -0,0 +1,109 @@
+package compute
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aiserve/gpuproxy/internal/gpu"
+)
+
+// detectDevices enumerates the GPUs on this host; tests replace it
+var detectDevices = gpu.DetectDevices
+
+// localDevices holds the allocator local reservations take devices from.
+// Devices are detected on first use, since detection shells out to vendor
+// tools, unless an allocator is set first.
+type localDevices struct {
+	mu        sync.Mutex
+	detect    func() []gpu.Device
+	allocator *gpu.DeviceAllocator
+	restored  map[string]string // Allocation ID -> owner, replayed into the allocator
+}
+
+func newLocalDevices(detect func() []gpu.Device) *localDevices {
+	return &localDevices{
+		detect:   detect,
+		restored: make(map[string]string),
+	}
+}
+
+// get returns the allocator, detecting devices if none is set yet
+func (l *localDevices) get() *gpu.DeviceAllocator {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.allocator == nil {
+		l.setLocked(gpu.NewDeviceAllocator(l.detect()))
+	}
+	return l.allocator
+}
+
+// set replaces the allocator, e.g. with one shared with served models
+func (l *localDevices) set(a *gpu.DeviceAllocator) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.setLocked(a)
+}
+
+// setLocked installs a and restores the allocations of live reservations in
+// it. Callers hold l.mu.
+func (l *localDevices) setLocked(a *gpu.DeviceAllocator) {
+	for id, owner := range l.restored {
+		a.Restore(id, owner) // IDs were validated when claimed
+	}
+	l.allocator = a
+}
+
+// claim records the devices of a reservation restored from the store
+func (l *localDevices) claim(owner, instanceID string) error {
+	id, err := localAllocationID(instanceID)
+	if err != nil {
+		return err
+	}
+	if _, err := gpu.ParseDeviceAllocationID(id); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.restored[id] = owner
+	if l.allocator != nil {
+		if _, err := l.allocator.Restore(id, owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// free returns a reservation's devices. Freeing devices that are not in use
+// is not an error.
+func (l *localDevices) free(instanceID string) error {
+	id, err := localAllocationID(instanceID)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	delete(l.restored, id)
+	l.mu.Unlock()
+
+	return l.get().Release(id)
+}
+
+// localInstanceID names a local reservation by its device allocation, e.g.
+// "local-cuda-0,1-3f2a9c1e"
+func localInstanceID(alloc *gpu.DeviceAllocation) string {
+	return "local-" + alloc.ID
+}
+
+// localAllocationID is the inverse of localInstanceID
+func localAllocationID(instanceID string) (string, error) {
+	id, ok := strings.CutPrefix(instanceID, "local-")
+	if !ok || id == "" {
+		return "", fmt.Errorf("invalid local instance ID %q", instanceID)
+	}
+	return id, nil
+}
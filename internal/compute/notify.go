@@ -0,0 +1,154 @@
+package compute
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Reservation event types
+const (
+	EventExpiryWarning = "reservation.expiry_warning"
+	EventExtended      = "reservation.extended"
+	EventExpired       = "reservation.expired"
+	EventIdleReleased  = "reservation.idle_released"
+
+	EventPreemptionWarning = "reservation.preemption_warning"
+	EventPreempted         = "reservation.preempted"
+	EventDispatched        = "reservation.dispatched"
+)
+
+// Release reasons recorded on reservations ended by the expiry scheduler
+// and the queue
+const (
+	ReleaseExpired   = "expired"
+	ReleaseIdle      = "idle"
+	ReleasePreempted = "preempted"
+	ReleaseCanceled  = "canceled"
+)
+
+// ReservationEvent tells a reservation's owner about its lifetime
+type ReservationEvent struct {
+	Type          string          `json:"type"`
+	ReservationID string          `json:"reservation_id"`
+	UserID        string          `json:"user_id,omitempty"`
+	Provider      ComputeProvider `json:"provider"`
+	InstanceID    string          `json:"instance_id,omitempty"`
+	Status        string          `json:"status"`
+	EndTime       time.Time       `json:"end_time,omitempty"`
+	ExpiresIn     int64           `json:"expires_in_seconds,omitempty"`
+	PreemptAt     time.Time       `json:"preempt_at,omitempty"`
+	Timestamp     time.Time       `json:"timestamp"`
+
+	// WebhookURL is the owner's webhook for this reservation, if any
+	WebhookURL string `json:"-"`
+}
+
+func newReservationEvent(eventType string, r *Reservation, now time.Time) ReservationEvent {
+	event := ReservationEvent{
+		Type:          eventType,
+		ReservationID: r.ID,
+		UserID:        r.UserID,
+		Provider:      r.Provider,
+		InstanceID:    r.InstanceID,
+		Status:        r.Status,
+		EndTime:       r.EndTime,
+		PreemptAt:     r.PreemptAt,
+		Timestamp:     now.UTC(),
+		WebhookURL:    r.WebhookURL,
+	}
+	if !r.EndTime.IsZero() && r.EndTime.After(now) {
+		event.ExpiresIn = int64(r.EndTime.Sub(now).Seconds())
+	}
+	return event
+}
+
+// Notifier delivers reservation events to owners
+type Notifier interface {
+	Notify(ctx context.Context, event ReservationEvent) error
+}
+
+// NotifierFunc adapts a function to a Notifier
+type NotifierFunc func(ctx context.Context, event ReservationEvent) error
+
+// Notify calls f
+func (f NotifierFunc) Notify(ctx context.Context, event ReservationEvent) error {
+	return f(ctx, event)
+}
+
+// webhookTimeout bounds each webhook delivery
+const webhookTimeout = 10 * time.Second
+
+// WebhookNotifier posts events as JSON to the reservation's own webhook and
+// to every configured URL. Reservation webhooks are owner-supplied, so they
+// are only called through the webhook policy.
+type WebhookNotifier struct {
+	urls        []string
+	httpClient  *http.Client
+	policy      *WebhookPolicy
+	ownerClient *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to urls in addition to each
+// reservation's webhook
+func NewWebhookNotifier(urls ...string) *WebhookNotifier {
+	n := &WebhookNotifier{
+		urls:       urls,
+		httpClient: &http.Client{Timeout: webhookTimeout},
+	}
+	n.SetPolicy(NewWebhookPolicy())
+	return n
+}
+
+// SetPolicy sets the policy reservation webhooks are called under
+func (n *WebhookNotifier) SetPolicy(policy *WebhookPolicy) {
+	n.policy = policy
+	n.ownerClient = policy.client(webhookTimeout)
+}
+
+// Notify posts the event, returning the first delivery error
+func (n *WebhookNotifier) Notify(ctx context.Context, event ReservationEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	if event.WebhookURL != "" {
+		client := n.ownerClient
+		if n.policy.allows(event.WebhookURL) {
+			client = n.httpClient
+		}
+		if err := n.post(ctx, client, event.WebhookURL, body); err != nil {
+			firstErr = fmt.Errorf("webhook %s: %w", event.WebhookURL, err)
+		}
+	}
+	for _, url := range n.urls {
+		if err := n.post(ctx, n.httpClient, url, body); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("webhook %s: %w", url, err)
+		}
+	}
+	return firstErr
+}
+
+func (n *WebhookNotifier) post(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
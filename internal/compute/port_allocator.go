@@ -83,6 +83,30 @@ func (pa *PortAllocator) Free(port int) {
 	pa.portQueue = append(pa.portQueue, port)
 }
 
+// Claim marks a specific port as allocated, e.g. when restoring reservations
+// that already hold it
+func (pa *PortAllocator) Claim(port int) error {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	if port < pa.minPort || port > pa.maxPort {
+		return fmt.Errorf("port %d outside range %d-%d", port, pa.minPort, pa.maxPort)
+	}
+	if pa.allocatedPorts[port] {
+		return fmt.Errorf("port %d already allocated", port)
+	}
+
+	for i, p := range pa.portQueue {
+		if p == port {
+			pa.portQueue = append(pa.portQueue[:i], pa.portQueue[i+1:]...)
+			break
+		}
+	}
+	pa.allocatedPorts[port] = true
+
+	return nil
+}
+
 // IsAllocated checks if a port is allocated
 func (pa *PortAllocator) IsAllocated(port int) bool {
 	pa.mu.Lock()
@@ -0,0 +1,100 @@
+package compute
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/loadbalancer"
+)
+
+// ProbeConfig says where the instances of active GPU reservations answer
+// load balancer health probes
+type ProbeConfig struct {
+	Path string // Appended to reservation endpoints, e.g. "/health"
+
+	// URLs maps a provider to a probe URL for its instances, which
+	// marketplaces don't report endpoints for. {instance_id}, {endpoint} and
+	// {port} are filled in from the reservation.
+	URLs map[ComputeProvider]string
+}
+
+// ProbeSink takes probe targets, e.g. the load balancer's health checker
+type ProbeSink interface {
+	SetProbe(instanceID string, target loadbalancer.ProbeTarget)
+}
+
+// ProbeTargets returns where each active GPU reservation's instance is
+// probed, keyed by InstanceID as the proxies track it. Reservations with
+// neither an endpoint nor a provider URL are left out.
+func (c *ReservationClient) ProbeTargets(ctx context.Context, cfg ProbeConfig) (map[string]loadbalancer.ProbeTarget, error) {
+	reservations, err := c.store.List(ctx, StatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reservations: %w", err)
+	}
+
+	targets := make(map[string]loadbalancer.ProbeTarget)
+	for _, r := range reservations {
+		if r.ComputeType != ComputeGPU || r.InstanceID == "" {
+			continue
+		}
+		if url := probeURL(r, cfg); url != "" {
+			targets[r.InstanceID] = loadbalancer.ProbeTarget{Kind: loadbalancer.ProbeHTTP, Address: url}
+		}
+	}
+	return targets, nil
+}
+
+func probeURL(r *Reservation, cfg ProbeConfig) string {
+	port := ""
+	if r.Port > 0 {
+		port = strconv.Itoa(r.Port)
+	}
+	if tmpl := cfg.URLs[r.Provider]; tmpl != "" {
+		return strings.NewReplacer("{instance_id}", r.InstanceID, "{endpoint}", r.Endpoint, "{port}", port).Replace(tmpl)
+	}
+
+	switch {
+	case r.Endpoint == "":
+		return ""
+	case strings.Contains(r.Endpoint, "://"):
+		return strings.TrimSuffix(r.Endpoint, "/") + cfg.Path
+	}
+	host := r.Endpoint
+	if _, _, err := net.SplitHostPort(host); err != nil && port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	return "http://" + host + cfg.Path
+}
+
+// RunProbeSync hands sink the probe targets of active reservations every
+// interval until ctx is cancelled. Targets of ended reservations are not
+// refreshed, so the health checker forgets them.
+func (c *ReservationClient) RunProbeSync(ctx context.Context, interval time.Duration, cfg ProbeConfig, sink ProbeSink) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		targets, err := c.ProbeTargets(ctx, cfg)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Reservation probe sync failed: %v", err)
+		}
+		for id, target := range targets {
+			sink.SetProbe(id, target)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
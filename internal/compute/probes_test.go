@@ -0,0 +1,37 @@
+package compute
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aiserve/gpuproxy/internal/loadbalancer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeTargets(t *testing.T) {
+	store := NewMemoryReservationStore()
+	ctx := context.Background()
+	for _, r := range []*Reservation{
+		{ID: "r1", InstanceID: "local-1", Provider: ProviderFake, ComputeType: ComputeGPU, Status: StatusActive, Endpoint: "localhost", Port: 2001},
+		{ID: "r2", InstanceID: "url-1", Provider: ProviderFake, ComputeType: ComputeGPU, Status: StatusActive, Endpoint: "https://gpu.example.com/"},
+		{ID: "r3", InstanceID: "123", Provider: ProviderVastAI, ComputeType: ComputeGPU, Status: StatusActive},
+		{ID: "r4", InstanceID: "io-1", Provider: ProviderIONet, ComputeType: ComputeGPU, Status: StatusActive}, // no endpoint or URL
+		{ID: "r5", InstanceID: "ended", Provider: ProviderFake, ComputeType: ComputeGPU, Status: StatusTerminated, Endpoint: "localhost:9000"},
+	} {
+		require.NoError(t, store.Create(ctx, r))
+	}
+	client, err := NewReservationClientWithStore(store, "", "", "")
+	require.NoError(t, err)
+
+	targets, err := client.ProbeTargets(ctx, ProbeConfig{
+		Path: "/health",
+		URLs: map[ComputeProvider]string{ProviderVastAI: "https://{instance_id}.proxy.example.com/health"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]loadbalancer.ProbeTarget{
+		"local-1": {Kind: loadbalancer.ProbeHTTP, Address: "http://localhost:2001/health"},
+		"url-1":   {Kind: loadbalancer.ProbeHTTP, Address: "https://gpu.example.com/health"},
+		"123":     {Kind: loadbalancer.ProbeHTTP, Address: "https://123.proxy.example.com/health"},
+	}, targets)
+}
@@ -3,68 +3,123 @@ package compute
 import (
 	"context"
 	"fmt"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/gpu"
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/aiserve/gpuproxy/pkg/ionet"
+	"github.com/aiserve/gpuproxy/pkg/vastai"
 )
 
-// VastAIClient handles Vast.ai API interactions
+// providerTimeout bounds each call to a provider API
+const providerTimeout = 30 * time.Second
+
+// VastAIClient handles Vast.ai API interactions. It is a gpu.GPUProvider, so
+// reservations rent offers from it like any other marketplace.
 type VastAIClient struct {
-	apiKey string
-	// TODO: Add HTTP client and other fields
+	apiKey      string
+	marketplace gpu.GPUProvider
 }
 
 // NewVastAIClient creates a new Vast.ai client
 func NewVastAIClient(apiKey string) *VastAIClient {
+	return newVastAIClient(apiKey, vastai.NewClient(apiKey, providerTimeout))
+}
+
+func newVastAIClient(apiKey string, client *vastai.Client) *VastAIClient {
 	return &VastAIClient{
-		apiKey: apiKey,
+		apiKey:      apiKey,
+		marketplace: gpu.NewVastAIProvider(client),
 	}
 }
 
-// CreateInstance provisions a GPU instance on Vast.ai
-func (c *VastAIClient) CreateInstance(ctx context.Context, req *ReservationRequest) (string, error) {
-	// TODO: Implement Vast.ai API call
-	return "", fmt.Errorf("Vast.ai CreateInstance not implemented")
+// Configured reports whether an API key was provided
+func (c *VastAIClient) Configured() bool {
+	return c.apiKey != ""
+}
+
+// Name returns the provider name offers are listed under
+func (c *VastAIClient) Name() gpu.Provider {
+	return c.marketplace.Name()
+}
+
+// ListOffers returns the rentable Vast.ai offers
+func (c *VastAIClient) ListOffers(ctx context.Context) ([]models.GPUInstance, error) {
+	return c.marketplace.ListOffers(ctx)
+}
+
+// CreateInstance rents a Vast.ai offer and returns the contract ID
+func (c *VastAIClient) CreateInstance(ctx context.Context, offerID string, config map[string]interface{}) (string, error) {
+	return c.marketplace.CreateInstance(ctx, offerID, config)
 }
 
 // DestroyInstance terminates a Vast.ai instance
 func (c *VastAIClient) DestroyInstance(ctx context.Context, instanceID string) error {
-	// TODO: Implement Vast.ai API call
-	return fmt.Errorf("Vast.ai DestroyInstance not implemented")
+	return c.marketplace.DestroyInstance(ctx, instanceID)
+}
+
+// InstanceStatus returns the status Vast.ai reports for an instance
+func (c *VastAIClient) InstanceStatus(ctx context.Context, instanceID string) (string, error) {
+	return c.marketplace.InstanceStatus(ctx, instanceID)
 }
 
-// ListInstances retrieves all active Vast.ai instances
-func (c *VastAIClient) ListInstances(ctx context.Context) ([]string, error) {
-	// TODO: Implement Vast.ai API call
-	return nil, fmt.Errorf("Vast.ai ListInstances not implemented")
+// ListRentedInstances returns the contract IDs of the instances rented on the account
+func (c *VastAIClient) ListRentedInstances(ctx context.Context) ([]string, error) {
+	return c.marketplace.(gpu.InstanceLister).ListRentedInstances(ctx)
 }
 
-// IONetClient handles IO.net API interactions
+// IONetClient handles IO.net API interactions. It is a gpu.GPUProvider, so
+// reservations rent devices from it like any other marketplace.
 type IONetClient struct {
-	apiKey string
-	// TODO: Add HTTP client and other fields
+	apiKey      string
+	marketplace gpu.GPUProvider
 }
 
 // NewIONetClient creates a new IO.net client
 func NewIONetClient(apiKey string) *IONetClient {
+	return newIONetClient(apiKey, ionet.NewClient(apiKey, providerTimeout))
+}
+
+func newIONetClient(apiKey string, client *ionet.Client) *IONetClient {
 	return &IONetClient{
-		apiKey: apiKey,
+		apiKey:      apiKey,
+		marketplace: gpu.NewIONetProvider(client),
 	}
 }
 
-// CreateInstance provisions a GPU instance on IO.net
-func (c *IONetClient) CreateInstance(ctx context.Context, req *ReservationRequest) (string, error) {
-	// TODO: Implement IO.net API call
-	return "", fmt.Errorf("IO.net CreateInstance not implemented")
+// Configured reports whether an API key was provided
+func (c *IONetClient) Configured() bool {
+	return c.apiKey != ""
+}
+
+// Name returns the provider name devices are listed under
+func (c *IONetClient) Name() gpu.Provider {
+	return c.marketplace.Name()
+}
+
+// ListOffers returns the available IO.net devices
+func (c *IONetClient) ListOffers(ctx context.Context) ([]models.GPUInstance, error) {
+	return c.marketplace.ListOffers(ctx)
+}
+
+// CreateInstance deploys an instance on an IO.net device and returns its ID
+func (c *IONetClient) CreateInstance(ctx context.Context, offerID string, config map[string]interface{}) (string, error) {
+	return c.marketplace.CreateInstance(ctx, offerID, config)
+}
+
+// DestroyInstance terminates an IO.net instance
+func (c *IONetClient) DestroyInstance(ctx context.Context, instanceID string) error {
+	return c.marketplace.DestroyInstance(ctx, instanceID)
 }
 
-// TerminateInstance terminates an IO.net instance
-func (c *IONetClient) TerminateInstance(ctx context.Context, instanceID string) error {
-	// TODO: Implement IO.net API call
-	return fmt.Errorf("IO.net TerminateInstance not implemented")
+// InstanceStatus returns the status IO.net reports for an instance
+func (c *IONetClient) InstanceStatus(ctx context.Context, instanceID string) (string, error) {
+	return c.marketplace.InstanceStatus(ctx, instanceID)
 }
 
-// ListInstances retrieves all active IO.net instances
-func (c *IONetClient) ListInstances(ctx context.Context) ([]string, error) {
-	// TODO: Implement IO.net API call
-	return nil, fmt.Errorf("IO.net ListInstances not implemented")
+// ListRentedInstances returns the IDs of the instances rented on the account
+func (c *IONetClient) ListRentedInstances(ctx context.Context) ([]string, error) {
+	return c.marketplace.(gpu.InstanceLister).ListRentedInstances(ctx)
 }
 
 // OpenRouterClient handles OpenRouter API interactions
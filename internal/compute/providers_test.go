@@ -0,0 +1,410 @@
+package compute
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/gpu"
+	"github.com/aiserve/gpuproxy/pkg/ionet"
+	"github.com/aiserve/gpuproxy/pkg/vastai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// providerAPI records the calls made to a fake provider API and the instances
+// rented from it
+type providerAPI struct {
+	mu     sync.Mutex
+	calls  []string
+	rented []string
+}
+
+func (a *providerAPI) record(r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls = append(a.calls, r.Method+" "+r.URL.Path)
+}
+
+func (a *providerAPI) rent(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rented = append(a.rented, id)
+}
+
+func (a *providerAPI) release(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, rented := range a.rented {
+		if rented == id {
+			a.rented = append(a.rented[:i], a.rented[i+1:]...)
+			return
+		}
+	}
+}
+
+// has reports whether id is still rented
+func (a *providerAPI) has(id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, rented := range a.rented {
+		if rented == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *providerAPI) instances() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.rented...)
+}
+
+func (a *providerAPI) called() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.calls...)
+}
+
+// newVastAIServer fakes the Vast.ai API. Asks for offers in failAsks fail.
+func newVastAIServer(t *testing.T, failAsks ...string) (*httptest.Server, *providerAPI) {
+	t.Helper()
+	api := &providerAPI{}
+	fail := make(map[string]bool)
+	for _, id := range failAsks {
+		fail[id] = true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /bundles", func(w http.ResponseWriter, r *http.Request) {
+		api.record(r)
+		assert.Equal(t, "Bearer vast-key", r.Header.Get("Authorization"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"offers": []map[string]interface{}{
+			{"id": 1, "gpu_name": "A100", "num_gpus": 1, "gpu_ram": 81920, "dph_total": 1.80, "geolocation": "us-east", "rentable": true},
+			{"id": 2, "gpu_name": "A100", "num_gpus": 2, "gpu_ram": 81920, "dph_total": 1.20, "geolocation": "us-west", "rentable": true},
+			{"id": 3, "gpu_name": "A100", "num_gpus": 1, "gpu_ram": 40960, "dph_total": 0.90, "geolocation": "us-east", "rentable": true},  // too little VRAM
+			{"id": 4, "gpu_name": "A100", "num_gpus": 1, "gpu_ram": 81920, "dph_total": 0.80, "geolocation": "eu-west", "rentable": true},  // wrong region
+			{"id": 5, "gpu_name": "A100", "num_gpus": 1, "gpu_ram": 81920, "dph_total": 0.70, "geolocation": "us-east", "rentable": false}, // taken
+			{"id": 6, "gpu_name": "A100", "num_gpus": 1, "gpu_ram": 81920, "dph_total": 3.50, "geolocation": "us-east", "rentable": true},  // too expensive
+			{"id": 7, "gpu_name": "H100", "num_gpus": 1, "gpu_ram": 81920, "dph_total": 1.00, "geolocation": "us-east", "rentable": true},  // wrong model
+		}})
+	})
+	mux.HandleFunc("POST /asks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		api.record(r)
+		if fail[r.PathValue("id")] {
+			http.Error(w, `{"error": "offer no longer available"}`, http.StatusGone)
+			return
+		}
+		id, err := strconv.Atoi(r.PathValue("id"))
+		require.NoError(t, err)
+		api.rent(strconv.Itoa(9000 + id))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "new_contract": 9000 + id})
+	})
+	mux.HandleFunc("GET /instances", func(w http.ResponseWriter, r *http.Request) {
+		api.record(r)
+		assert.Equal(t, "me", r.URL.Query().Get("owner"))
+		var instances []map[string]interface{}
+		for _, id := range api.instances() {
+			n, _ := strconv.Atoi(id)
+			instances = append(instances, map[string]interface{}{"id": n, "actual_status": "running"})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"instances": instances})
+	})
+	mux.HandleFunc("GET /instances/{id}", func(w http.ResponseWriter, r *http.Request) {
+		api.record(r)
+		if !api.has(r.PathValue("id")) {
+			w.Write([]byte(`{"instances": null}`)) // Vast.ai's answer for unknown contracts
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"instances": map[string]interface{}{"actual_status": "running"}})
+	})
+	mux.HandleFunc("DELETE /instances/{id}", func(w http.ResponseWriter, r *http.Request) {
+		api.record(r)
+		if !api.has(r.PathValue("id")) {
+			http.Error(w, `{"error": "no_such_instance"}`, http.StatusNotFound)
+			return
+		}
+		api.release(r.PathValue("id"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, api
+}
+
+// newIONetServer fakes the IO.net API
+func newIONetServer(t *testing.T) (*httptest.Server, *providerAPI) {
+	t.Helper()
+	api := &providerAPI{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /devices", func(w http.ResponseWriter, r *http.Request) {
+		api.record(r)
+		assert.Equal(t, "Bearer ionet-key", r.Header.Get("Authorization"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"devices": []map[string]interface{}{
+			{"id": "dev-a", "gpu": map[string]interface{}{"model": "H100", "count": 4, "vram_gb": 80}, "price_per_hour": 9.0, "location": "us-central", "status": "available"},
+			{"id": "dev-b", "gpu": map[string]interface{}{"model": "H100", "count": 8, "vram_gb": 80}, "price_per_hour": 7.5, "location": "us-central", "status": "available"},
+			{"id": "dev-c", "gpu": map[string]interface{}{"model": "H100", "count": 8, "vram_gb": 80}, "price_per_hour": 5.0, "location": "us-central", "status": "busy"},
+			{"id": "dev-d", "gpu": map[string]interface{}{"model": "H100", "count": 2, "vram_gb": 80}, "price_per_hour": 3.0, "location": "us-central", "status": "available"}, // too few GPUs
+		}, "total": 4})
+	})
+	mux.HandleFunc("POST /instances", func(w http.ResponseWriter, r *http.Request) {
+		api.record(r)
+		var body struct {
+			DeviceID string `json:"device_id"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		api.rent("inst-" + body.DeviceID)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"instance_id": "inst-" + body.DeviceID})
+	})
+	mux.HandleFunc("GET /instances", func(w http.ResponseWriter, r *http.Request) {
+		api.record(r)
+		var instances []map[string]interface{}
+		for _, id := range api.instances() {
+			instances = append(instances, map[string]interface{}{"instance_id": id, "status": "running"})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"instances": instances})
+	})
+	mux.HandleFunc("GET /instances/{id}", func(w http.ResponseWriter, r *http.Request) {
+		api.record(r)
+		if !api.has(r.PathValue("id")) {
+			http.Error(w, `{"error": "instance not found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "running"})
+	})
+	mux.HandleFunc("DELETE /instances/{id}", func(w http.ResponseWriter, r *http.Request) {
+		api.record(r)
+		if !api.has(r.PathValue("id")) {
+			http.Error(w, `{"error": "instance not found"}`, http.StatusNotFound)
+			return
+		}
+		api.release(r.PathValue("id"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, api
+}
+
+func newProviderClient(t *testing.T, store ReservationStore) *ReservationClient {
+	t.Helper()
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{})
+	return newLifecycleClient(t, store, fake, clock)
+}
+
+func TestReserveVastAI(t *testing.T) {
+	srv, api := newVastAIServer(t, "2")
+	client := newProviderClient(t, NewMemoryReservationStore())
+	vast := vastai.NewClient("vast-key", time.Second)
+	vast.SetBaseURL(srv.URL)
+	client.vastAIClient = newVastAIClient("vast-key", vast)
+	ctx := context.Background()
+
+	res, err := client.Reserve(ctx, &ReservationRequest{
+		Provider: ProviderVastAI, ComputeType: ComputeGPU, GPUModel: "a100",
+		Count: 1, MinVRAM: 80, Region: "us", MaxCostPerHr: 2, Duration: time.Hour,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StatusProvisioning, res.Status)
+	assert.Equal(t, "9001", res.InstanceID)
+	assert.Equal(t, 1.80, res.CostPerHr, "the cheapest offer could not be rented, so the next is")
+	assert.Equal(t, "vast-1", res.Metadata["offer_id"])
+	assert.Equal(t, []string{"GET /bundles", "POST /asks/2", "POST /asks/1"}, api.called())
+
+	report, err := client.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Activated)
+
+	require.NoError(t, client.Release(ctx, res.ID))
+	assert.Contains(t, api.called(), "DELETE /instances/9001")
+	got, err := client.GetReservation(res.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusTerminated, got.Status)
+}
+
+func TestReserveIONet(t *testing.T) {
+	srv, api := newIONetServer(t)
+	client := newProviderClient(t, NewMemoryReservationStore())
+	io := ionet.NewClient("ionet-key", time.Second)
+	io.SetBaseURL(srv.URL)
+	client.ionetClient = newIONetClient("ionet-key", io)
+	ctx := context.Background()
+
+	res, err := client.Reserve(ctx, &ReservationRequest{
+		Provider: ProviderIONet, ComputeType: ComputeGPU, GPUModel: "H100", Count: 4, MaxCostPerHr: 10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "inst-dev-b", res.InstanceID)
+	assert.Equal(t, 7.5, res.CostPerHr)
+	assert.Equal(t, 4, gpuCapacity(client))
+
+	report, err := client.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Activated)
+
+	require.NoError(t, client.Release(ctx, res.ID))
+	assert.Equal(t, []string{"GET /devices", "POST /instances", "GET /instances", "GET /instances/inst-dev-b", "DELETE /instances/inst-dev-b"}, api.called())
+	assert.Equal(t, 0, gpuCapacity(client))
+}
+
+func TestReconcileProviderOrphans(t *testing.T) {
+	vastSrv, vastAPI := newVastAIServer(t)
+	ioSrv, ioAPI := newIONetServer(t)
+	client := newProviderClient(t, NewMemoryReservationStore())
+	vast := vastai.NewClient("vast-key", time.Second)
+	vast.SetBaseURL(vastSrv.URL)
+	client.vastAIClient = newVastAIClient("vast-key", vast)
+	io := ionet.NewClient("ionet-key", time.Second)
+	io.SetBaseURL(ioSrv.URL)
+	client.ionetClient = newIONetClient("ionet-key", io)
+	ctx := context.Background()
+
+	res, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderVastAI, ComputeType: ComputeGPU, GPUModel: "A100", Count: 1})
+	require.NoError(t, err)
+
+	// Instances rented on the accounts that no reservation recorded
+	vastAPI.rent("9999")
+	ioAPI.rent("inst-stray")
+
+	report, err := client.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, 2, report.Orphans)
+	assert.Equal(t, []string{res.InstanceID}, vastAPI.instances())
+	assert.Empty(t, ioAPI.instances())
+	assert.Contains(t, vastAPI.called(), "DELETE /instances/9999")
+	assert.Contains(t, ioAPI.called(), "DELETE /instances/inst-stray")
+}
+
+func TestProviderInstancesVanish(t *testing.T) {
+	vastSrv, vastAPI := newVastAIServer(t)
+	ioSrv, ioAPI := newIONetServer(t)
+	client := newProviderClient(t, NewMemoryReservationStore())
+	vast := vastai.NewClient("vast-key", time.Second)
+	vast.SetBaseURL(vastSrv.URL)
+	client.vastAIClient = newVastAIClient("vast-key", vast)
+	io := ionet.NewClient("ionet-key", time.Second)
+	io.SetBaseURL(ioSrv.URL)
+	client.ionetClient = newIONetClient("ionet-key", io)
+	ctx := context.Background()
+
+	for _, marketplace := range []gpu.GPUProvider{client.vastAIClient, client.ionetClient} {
+		_, err := marketplace.InstanceStatus(ctx, "404")
+		assert.ErrorIs(t, err, gpu.ErrInstanceNotFound, "%s status", marketplace.Name())
+		err = marketplace.DestroyInstance(ctx, "404")
+		assert.ErrorIs(t, err, gpu.ErrInstanceNotFound, "%s destroy", marketplace.Name())
+	}
+
+	onVast, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderVastAI, ComputeType: ComputeGPU, GPUModel: "A100", Count: 1})
+	require.NoError(t, err)
+	onIONet, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderIONet, ComputeType: ComputeGPU, GPUModel: "H100", Count: 4})
+	require.NoError(t, err)
+
+	// Both instances are terminated behind our back
+	vastAPI.release(onVast.InstanceID)
+	ioAPI.release(onIONet.InstanceID)
+
+	report, err := client.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, 2, report.Failed)
+	for _, id := range []string{onVast.ID, onIONet.ID} {
+		got, err := client.GetReservation(id)
+		require.NoError(t, err)
+		assert.Equal(t, StatusFailed, got.Status)
+		assert.Equal(t, "instance no longer exists at provider", got.FailureReason)
+	}
+	assert.Equal(t, 0, gpuCapacity(client))
+}
+
+func TestReserveProviderWithoutKey(t *testing.T) {
+	client := newProviderClient(t, NewMemoryReservationStore())
+	ctx := context.Background()
+
+	_, err := client.Reserve(ctx, &ReservationRequest{Provider: ProviderVastAI, ComputeType: ComputeGPU, Count: 1})
+	assert.ErrorContains(t, err, "Vast.ai API key not configured")
+	_, err = client.Reserve(ctx, &ReservationRequest{Provider: ProviderIONet, ComputeType: ComputeGPU, Count: 1})
+	assert.ErrorContains(t, err, "IO.net API key not configured")
+	assert.Equal(t, 0, gpuCapacity(client))
+}
+
+func TestReserveLocal(t *testing.T) {
+	detectDevices = func() []gpu.Device {
+		return []gpu.Device{
+			{Backend: gpu.BackendCUDA, Index: 0, UUID: "GPU-0", VRAMMB: 81920},
+			{Backend: gpu.BackendCUDA, Index: 1, UUID: "GPU-1", VRAMMB: 81920},
+			{Backend: gpu.BackendROCm, Index: 0, UUID: "0x5d8f", VRAMMB: 65520},
+		}
+	}
+	t.Cleanup(func() { detectDevices = gpu.DetectDevices })
+	path := filepath.Join(t.TempDir(), "reservations.db")
+	ctx := context.Background()
+
+	store := openSQLiteStore(t, path)
+	client := newProviderClient(t, store)
+
+	local := &ReservationRequest{Provider: ProviderLocal, ComputeType: ComputeGPU, Count: 1}
+	first, err := client.Reserve(ctx, local)
+	require.NoError(t, err)
+	assert.Equal(t, StatusActive, first.Status)
+	assert.Regexp(t, `^local-cuda-0-[0-9a-f]{8}$`, first.InstanceID)
+	assert.Equal(t, map[string]string{"CUDA_VISIBLE_DEVICES": "0"}, first.Metadata["env"])
+
+	local.Count = 2
+	_, err = client.Reserve(ctx, local)
+	assert.ErrorContains(t, err, "not enough free local GPUs: requested=2, free=2", "devices never span backends")
+
+	local.Count = 1
+	second, err := client.Reserve(ctx, local)
+	require.NoError(t, err)
+	assert.Regexp(t, `^local-cuda-1-`, second.InstanceID)
+	third, err := client.Reserve(ctx, local)
+	require.NoError(t, err)
+	assert.Regexp(t, `^local-rocm-0-`, third.InstanceID)
+	assert.Equal(t, "rocm", third.Metadata["backend"])
+	assert.Equal(t, map[string]string{"HIP_VISIBLE_DEVICES": "0"}, third.Metadata["env"])
+
+	_, err = client.Reserve(ctx, local)
+	assert.ErrorContains(t, err, "free=0")
+	_, err = client.Reserve(ctx, &ReservationRequest{Provider: ProviderLocal, ComputeType: ComputeTPU, Count: 1})
+	assert.ErrorContains(t, err, "TPU")
+
+	require.NoError(t, client.Release(ctx, first.ID))
+	shared := &ReservationRequest{Provider: ProviderLocal, ComputeType: ComputeGPU, Count: 1, SharedVRAM: 40}
+	slice, err := client.Reserve(ctx, shared)
+	require.NoError(t, err)
+	assert.Regexp(t, `^local-cuda-0@40960-`, slice.InstanceID)
+	assert.Equal(t, 40960, slice.Metadata["vram_mb"])
+	require.NoError(t, client.Close())
+
+	// A restarted client knows which devices are still taken, even when
+	// handed an allocator shared with served models
+	store = openSQLiteStore(t, path)
+	client = newProviderClient(t, store)
+	defer client.Close()
+	allocator := gpu.NewDeviceAllocator(detectDevices())
+	client.SetDeviceAllocator(allocator)
+
+	_, err = client.Reserve(ctx, shared)
+	require.NoError(t, err, "half of device 0 is still free")
+	_, err = client.Reserve(ctx, shared)
+	assert.ErrorContains(t, err, "free=0")
+	_, _, err = allocator.AllocateModelDevices("model-1", 1)
+	assert.Error(t, err, "the reservations hold every device")
+
+	require.NoError(t, client.Release(ctx, second.ID))
+	_, _, err = allocator.AllocateModelDevices("model-1", 1)
+	assert.NoError(t, err)
+}
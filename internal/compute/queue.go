@@ -0,0 +1,561 @@
+package compute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultPreemptionGrace is how long a preempted reservation keeps running
+// after its owner is warned
+const DefaultPreemptionGrace = 5 * time.Minute
+
+// ErrPriorityNotAllowed is returned for requests above the caller's
+// priority ceiling, or asking to preempt without the right to
+var ErrPriorityNotAllowed = errors.New("priority not allowed")
+
+// PriorityLimits is what non-admin users may ask for. Admins may use any
+// priority and preempt.
+type PriorityLimits struct {
+	MaxPriority int  // Highest priority users may request
+	Preempt     bool // Whether users may preempt lower-priority reservations
+}
+
+// maxConcurrentDispatches bounds how many queued reservations one dispatch
+// pass provisions at once
+const maxConcurrentDispatches = 8
+
+// errQueueBlocked means a queued reservation does not fit yet
+var errQueueBlocked = errors.New("does not fit in the remaining capacity")
+
+// QueueStatus is where a queued reservation stands
+type QueueStatus struct {
+	Position int        `json:"position"`      // 1 is next to be dispatched
+	Length   int        `json:"length"`        // Reservations queued for the same compute type
+	ETA      *time.Time `json:"eta,omitempty"` // Estimated dispatch time; unset if unknown
+}
+
+// QueueReport summarizes one dispatch pass
+type QueueReport struct {
+	Dispatched           int // queued reservations started
+	PreemptionsScheduled int // reservations warned they will be preempted
+	Preempted            int // reservations released for higher-priority requests
+	Errors               []error
+}
+
+// Changed reports whether the pass did anything
+func (r *QueueReport) Changed() bool {
+	return r.Dispatched+r.PreemptionsScheduled+r.Preempted > 0
+}
+
+// CheckPriority vets the priority and preemption a request asks for against
+// the caller's limits
+func (c *ReservationClient) CheckPriority(req *ReservationRequest, admin bool) error {
+	if admin {
+		return nil
+	}
+	priority := req.Priority
+	if priority == 0 {
+		priority = DefaultPriority
+	}
+	if priority > c.priorityLimits.MaxPriority {
+		return fmt.Errorf("%w: priority %d is above your maximum of %d", ErrPriorityNotAllowed, priority, c.priorityLimits.MaxPriority)
+	}
+	if req.Preempt && !c.priorityLimits.Preempt {
+		return fmt.Errorf("%w: you may not preempt other reservations", ErrPriorityNotAllowed)
+	}
+	return nil
+}
+
+// enqueue records a request that does not fit yet as queued
+func (c *ReservationClient) enqueue(ctx context.Context, req *ReservationRequest) (*Reservation, error) {
+	if limit := c.capacity(req.ComputeType); limit > 0 && req.Count > limit {
+		return nil, fmt.Errorf("%s %w: requested=%d, max=%d", req.ComputeType, ErrCapacityExceeded, req.Count, limit)
+	}
+
+	queued := *req
+	now := c.now()
+	reservation := &Reservation{
+		ID:          "res-" + uuid.NewString(),
+		Provider:    req.Provider,
+		ComputeType: req.ComputeType,
+		Status:      StatusQueued,
+		Count:       req.Count,
+		UserID:      req.UserID,
+		WebhookURL:  req.WebhookURL,
+		Priority:    req.Priority,
+		Preemptible: req.Preemptible,
+		Request:     &queued,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	c.mu.Lock()
+	c.queued[req.ComputeType]++
+	c.mu.Unlock()
+
+	if err := c.store.Create(ctx, reservation); err != nil {
+		c.mu.Lock()
+		c.queued[req.ComputeType]--
+		c.mu.Unlock()
+		return nil, fmt.Errorf("failed to record reservation: %w", err)
+	}
+
+	c.wakeQueue()
+	return reservation.clone(), nil
+}
+
+// wakeQueue asks RunQueue for a dispatch pass without waiting for its ticker
+func (c *ReservationClient) wakeQueue() {
+	select {
+	case c.queueWake <- struct{}{}:
+	default:
+	}
+}
+
+// DispatchQueue runs one pass over the queue. Reservations whose preemption
+// grace period has passed are released, then queued reservations are started
+// in fair order while they fit. Each compute type is served strictly in
+// order: a reservation that does not fit holds back those behind it, and if
+// it may preempt, enough lower-priority preemptible reservations to make room
+// are warned and released once the grace period ends.
+func (c *ReservationClient) DispatchQueue(ctx context.Context) (*QueueReport, error) {
+	report := &QueueReport{}
+	now := c.now()
+
+	live, err := c.store.List(ctx, StatusProvisioning, StatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reservations: %w", err)
+	}
+	for _, r := range live {
+		if r.PreemptAt.IsZero() || now.Before(r.PreemptAt) {
+			continue
+		}
+		if err := c.release(ctx, r.ID, ReleasePreempted); err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("reservation %s: %w", r.ID, err))
+		}
+		// A failed provider call leaves the reservation draining for the
+		// reconciler; its capacity is on its way back either way
+		current, err := c.store.Get(ctx, r.ID)
+		if err != nil || current.ReleaseReason != ReleasePreempted {
+			continue
+		}
+		report.Preempted++
+		c.notify(ctx, newReservationEvent(EventPreempted, current, now))
+	}
+
+	queued, err := c.store.List(ctx, StatusQueued)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queued reservations: %w", err)
+	}
+
+	// Reservations claim their capacity in order, then are provisioned
+	// concurrently so that a slow provider holds up no one else
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		slots = make(chan struct{}, maxConcurrentDispatches)
+	)
+
+	blocked := make(map[ComputeType]bool)
+	for _, r := range fairOrder(queued) {
+		if blocked[r.ComputeType] {
+			continue
+		}
+
+		slots <- struct{}{}
+		provision, err := c.dispatch(ctx, r)
+		if err == nil {
+			if provision == nil {
+				<-slots
+				continue
+			}
+			wg.Add(1)
+			go func(r *Reservation) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				dispatched, err := provision()
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					report.Errors = append(report.Errors, fmt.Errorf("reservation %s: %w", r.ID, err))
+					return
+				}
+				if dispatched != nil {
+					report.Dispatched++
+					c.notify(ctx, newReservationEvent(EventDispatched, dispatched, c.now()))
+				}
+			}(r)
+			continue
+		}
+		<-slots
+
+		blocked[r.ComputeType] = true
+		if !errors.Is(err, errQueueBlocked) {
+			report.Errors = append(report.Errors, fmt.Errorf("reservation %s: %w", r.ID, err))
+			continue
+		}
+		if r.Request != nil && r.Request.Preempt {
+			scheduled, err := c.preemptFor(ctx, r)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Errorf("reservation %s: %w", r.ID, err))
+			}
+			report.PreemptionsScheduled += scheduled
+		}
+	}
+
+	wg.Wait()
+	return report, nil
+}
+
+// dispatch claims capacity for a queued reservation if it fits, returning a
+// function that provisions it. It returns errQueueBlocked if it does not
+// fit, and a nil function without error if the reservation left the queue
+// some other way.
+func (c *ReservationClient) dispatch(ctx context.Context, r *Reservation) (func() (*Reservation, error), error) {
+	if r.Request == nil {
+		ok, err := c.transition(ctx, r, StatusFailed, func(next *Reservation) {
+			next.FailureReason = "queued without a request"
+		})
+		if err != nil || !ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("queued without a request")
+	}
+	req := *r.Request
+
+	release := func() {}
+	if c.admit != nil {
+		now := c.now()
+		var end time.Time
+		if req.Duration > 0 {
+			end = now.Add(req.Duration)
+		}
+		admitted, err := c.admit(ctx, req.ComputeType, req.Count, now, end)
+		if err != nil {
+			if errors.Is(err, ErrBookingConflict) {
+				return nil, errQueueBlocked
+			}
+			return nil, err
+		}
+		release = admitted
+	}
+	defer release()
+
+	c.mu.Lock()
+	if err := c.checkCapacity(r.ComputeType, r.Count); err != nil {
+		c.mu.Unlock()
+		return nil, errQueueBlocked
+	}
+	port, err := c.portAllocator.Allocate()
+	if err != nil {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("failed to allocate port: %w", err)
+	}
+	held := &Reservation{ComputeType: r.ComputeType, Count: r.Count, Port: port}
+	c.holdCapacity(held)
+	c.reserving++
+	c.reserveStarts++
+	c.mu.Unlock()
+
+	done := func() {
+		c.mu.Lock()
+		c.reserving--
+		c.mu.Unlock()
+	}
+
+	ok, err := c.transition(ctx, r, StatusPending, func(next *Reservation) {
+		next.Port = port
+		next.Request = nil
+	})
+	release()
+	if err != nil || !ok {
+		// Canceled while we were looking
+		c.releaseCapacity(held)
+		done()
+		return nil, err
+	}
+
+	return func() (*Reservation, error) {
+		defer done()
+		return c.provisionReservation(ctx, r, &req)
+	}, nil
+}
+
+// preemptFor schedules the preemption of enough lower-priority preemptible
+// reservations for r to fit, counting capacity already on its way back. It
+// preempts nothing unless that is enough. It returns how many reservations
+// were warned.
+func (c *ReservationClient) preemptFor(ctx context.Context, r *Reservation) (int, error) {
+	limit := c.capacity(r.ComputeType)
+	if limit == 0 {
+		return 0, nil // Held back by bookings, which cannot be preempted
+	}
+
+	live, err := c.store.List(ctx, liveStatuses...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list reservations: %w", err)
+	}
+
+	c.mu.RLock()
+	deficit := r.Count - (limit - c.activeCount(r.ComputeType))
+	c.mu.RUnlock()
+
+	var candidates []*Reservation
+	for _, l := range live {
+		if l.ComputeType != r.ComputeType {
+			continue
+		}
+		if l.Status == StatusDraining || !l.PreemptAt.IsZero() {
+			deficit -= l.Count
+			continue
+		}
+		if l.Preemptible && l.Priority < r.Priority && (l.Status == StatusProvisioning || l.Status == StatusActive) {
+			candidates = append(candidates, l)
+		}
+	}
+	if deficit <= 0 {
+		return 0, nil
+	}
+
+	// Lowest priority first, and among equals the newest, which has lost
+	// the least work
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Priority != candidates[j].Priority {
+			return candidates[i].Priority < candidates[j].Priority
+		}
+		return candidates[i].CreatedAt.After(candidates[j].CreatedAt)
+	})
+
+	var victims []*Reservation
+	for _, l := range candidates {
+		if deficit <= 0 {
+			break
+		}
+		victims = append(victims, l)
+		deficit -= l.Count
+	}
+	if deficit > 0 {
+		return 0, nil
+	}
+
+	now := c.now()
+	scheduled := 0
+	for _, l := range victims {
+		ok, err := c.save(ctx, l, func(next *Reservation) {
+			next.PreemptAt = now.Add(c.preemptionGrace)
+		})
+		if err != nil {
+			return scheduled, fmt.Errorf("failed to schedule preemption of %s: %w", l.ID, err)
+		}
+		if !ok {
+			continue // Changed since listed; reconsidered next pass
+		}
+		scheduled++
+		c.notify(ctx, newReservationEvent(EventPreemptionWarning, l, now))
+	}
+	return scheduled, nil
+}
+
+// activeCount returns the capacity in use for a compute type. Callers hold c.mu.
+func (c *ReservationClient) activeCount(computeType ComputeType) int {
+	switch computeType {
+	case ComputeGPU:
+		return c.activeGPUs
+	case ComputeTPU:
+		return c.activeTPUs
+	}
+	return 0
+}
+
+// fairOrder sorts queued reservations by priority, highest first. Within a
+// priority users take turns, so one user's burst of requests does not hold
+// everyone else back; each user's own requests keep their order.
+func fairOrder(queued []*Reservation) []*Reservation {
+	type turnKey struct {
+		priority int
+		userID   string
+	}
+
+	ordered := append([]*Reservation(nil), queued...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if !ordered[i].CreatedAt.Equal(ordered[j].CreatedAt) {
+			return ordered[i].CreatedAt.Before(ordered[j].CreatedAt)
+		}
+		return ordered[i].ID < ordered[j].ID
+	})
+
+	turn := make(map[*Reservation]int, len(ordered))
+	seen := make(map[turnKey]int)
+	for _, r := range ordered {
+		key := turnKey{r.Priority, r.UserID}
+		turn[r] = seen[key]
+		seen[key]++
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Priority != ordered[j].Priority {
+			return ordered[i].Priority > ordered[j].Priority
+		}
+		return turn[ordered[i]] < turn[ordered[j]]
+	})
+	return ordered
+}
+
+// QueueLengths returns how many reservations are queued per compute type
+func (c *ReservationClient) QueueLengths() map[ComputeType]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	lengths := make(map[ComputeType]int, len(c.queued))
+	for computeType, n := range c.queued {
+		if n > 0 {
+			lengths[computeType] = n
+		}
+	}
+	return lengths
+}
+
+// FillQueueStatus sets Queue on each of the given reservations that is
+// queued. The ETA assumes live reservations run to their end time or
+// scheduled preemption and that queued ones run for the duration they asked
+// for; it is left unset when that never frees enough capacity.
+func (c *ReservationClient) FillQueueStatus(ctx context.Context, reservations ...*Reservation) error {
+	wanted := make(map[string]*Reservation)
+	for _, r := range reservations {
+		if r.Status == StatusQueued {
+			wanted[r.ID] = r
+		}
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+
+	queued, err := c.store.List(ctx, StatusQueued)
+	if err != nil {
+		return fmt.Errorf("failed to list queued reservations: %w", err)
+	}
+	live, err := c.store.List(ctx, liveStatuses...)
+	if err != nil {
+		return fmt.Errorf("failed to list reservations: %w", err)
+	}
+
+	now := c.now()
+	byType := make(map[ComputeType][]*Reservation)
+	for _, r := range fairOrder(queued) {
+		byType[r.ComputeType] = append(byType[r.ComputeType], r)
+	}
+
+	for computeType, line := range byType {
+		etas := c.estimateDispatch(computeType, line, live, now)
+		for i, q := range line {
+			r, ok := wanted[q.ID]
+			if !ok {
+				continue
+			}
+			r.Queue = &QueueStatus{Position: i + 1, Length: len(line), ETA: etas[i]}
+		}
+	}
+	return nil
+}
+
+// capacityRelease is capacity expected back at a point in time
+type capacityRelease struct {
+	at    time.Time
+	count int
+}
+
+// estimateDispatch estimates when each reservation in line will be
+// dispatched by replaying expected releases against it in order
+func (c *ReservationClient) estimateDispatch(computeType ComputeType, line, live []*Reservation, now time.Time) []*time.Time {
+	etas := make([]*time.Time, len(line))
+	limit := c.capacity(computeType)
+	if limit == 0 {
+		return etas // Unlimited; only bookings hold the line back
+	}
+
+	c.mu.RLock()
+	free := limit - c.activeCount(computeType)
+	c.mu.RUnlock()
+
+	var releases []capacityRelease
+	for _, r := range live {
+		if r.ComputeType != computeType {
+			continue
+		}
+		at := r.EndTime
+		if !r.PreemptAt.IsZero() && (at.IsZero() || r.PreemptAt.Before(at)) {
+			at = r.PreemptAt
+		}
+		if r.Status == StatusDraining {
+			at = now
+		}
+		if at.IsZero() {
+			continue // Runs until released
+		}
+		releases = append(releases, capacityRelease{at: at, count: r.Count})
+	}
+	sort.Slice(releases, func(i, j int) bool { return releases[i].at.Before(releases[j].at) })
+
+	at := now
+	for i, r := range line {
+		for free < r.Count && len(releases) > 0 {
+			if releases[0].at.After(at) {
+				at = releases[0].at
+			}
+			free += releases[0].count
+			releases = releases[1:]
+		}
+		if free < r.Count {
+			break
+		}
+
+		eta := at
+		etas[i] = &eta
+		free -= r.Count
+		if r.Request != nil && r.Request.Duration > 0 {
+			release := capacityRelease{at: at.Add(r.Request.Duration), count: r.Count}
+			n := sort.Search(len(releases), func(k int) bool { return releases[k].at.After(release.at) })
+			releases = append(releases, capacityRelease{})
+			copy(releases[n+1:], releases[n:])
+			releases[n] = release
+		}
+	}
+	return etas
+}
+
+// RunQueue dispatches the queue every interval, and whenever capacity frees
+// up or a request is queued, until ctx is canceled
+func (c *ReservationClient) RunQueue(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.queueWake:
+		}
+
+		report, err := c.DispatchQueue(ctx)
+		if err != nil {
+			log.Printf("Reservation queue dispatch failed: %v", err)
+			continue
+		}
+		if report.Changed() {
+			log.Printf("Reservation queue: %d dispatched, %d preemptions scheduled, %d preempted",
+				report.Dispatched, report.PreemptionsScheduled, report.Preempted)
+		}
+		for _, err := range report.Errors {
+			log.Printf("Reservation queue: %v", err)
+		}
+	}
+}
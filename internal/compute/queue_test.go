@@ -0,0 +1,365 @@
+package compute
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/gpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newQueueClient(t *testing.T, maxGPUs int) (*ReservationClient, *lifecycleClock, *[]ReservationEvent) {
+	t.Helper()
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{})
+	client := newLifecycleClient(t, NewMemoryReservationStore(), fake, clock)
+	client.maxGPUs = maxGPUs
+
+	var mu sync.Mutex
+	events := &[]ReservationEvent{}
+	client.AddNotifier(NotifierFunc(func(ctx context.Context, event ReservationEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		*events = append(*events, event)
+		return nil
+	}))
+	return client, clock, events
+}
+
+func queueRequest(user string, count, priority int) *ReservationRequest {
+	return &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, Count: count, Priority: priority, UserID: user, Queue: true}
+}
+
+func eventTypes(events []ReservationEvent) []string {
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestReserveQueuesOverCapacity(t *testing.T) {
+	client, _, _ := newQueueClient(t, 2)
+	ctx := context.Background()
+
+	_, err := client.Reserve(ctx, queueRequest("u1", 2, 0))
+	require.NoError(t, err)
+
+	req := queueRequest("u1", 1, 0)
+	req.Queue = false
+	_, err = client.Reserve(ctx, req)
+	assert.ErrorIs(t, err, ErrCapacityExceeded)
+	assert.ErrorContains(t, err, "GPU capacity exceeded")
+
+	queued, err := client.Reserve(ctx, queueRequest("u2", 1, 0))
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, queued.Status)
+	assert.Equal(t, DefaultPriority, queued.Priority)
+	assert.Zero(t, queued.Port, "queued reservations hold no port")
+	assert.Equal(t, 2, gpuCapacity(client))
+
+	_, err = client.Reserve(ctx, queueRequest("u2", 3, 0))
+	assert.ErrorContains(t, err, "max=2", "requests larger than capacity are never queued")
+	_, err = client.Reserve(ctx, queueRequest("u2", 1, 11))
+	assert.ErrorContains(t, err, "between 1 and 10")
+
+	owned, err := client.ListUserReservations(ctx, "u2")
+	require.NoError(t, err)
+	require.Len(t, owned, 1)
+	assert.Equal(t, queued.ID, owned[0].ID)
+}
+
+func TestQueueFairOrder(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	queued := []*Reservation{
+		{ID: "a1", UserID: "a", Priority: 5, CreatedAt: base},
+		{ID: "a2", UserID: "a", Priority: 5, CreatedAt: base.Add(time.Second)},
+		{ID: "a3", UserID: "a", Priority: 5, CreatedAt: base.Add(2 * time.Second)},
+		{ID: "b1", UserID: "b", Priority: 5, CreatedAt: base.Add(3 * time.Second)},
+		{ID: "c1", UserID: "c", Priority: 8, CreatedAt: base.Add(4 * time.Second)},
+		{ID: "b2", UserID: "b", Priority: 5, CreatedAt: base.Add(5 * time.Second)},
+	}
+
+	var ids []string
+	for _, r := range fairOrder(queued) {
+		ids = append(ids, r.ID)
+	}
+	assert.Equal(t, []string{"c1", "a1", "b1", "a2", "b2", "a3"}, ids)
+}
+
+func TestQueueDispatchesWhenCapacityFrees(t *testing.T) {
+	client, _, events := newQueueClient(t, 3)
+	ctx := context.Background()
+
+	first, err := client.Reserve(ctx, queueRequest("u1", 2, 0))
+	require.NoError(t, err)
+	queued, err := client.Reserve(ctx, queueRequest("u2", 2, 0))
+	require.NoError(t, err)
+
+	// On-demand requests may not jump the queue
+	_, err = client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "A100", Count: 1})
+	assert.ErrorContains(t, err, "queued ahead")
+
+	report, err := client.DispatchQueue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, report.Dispatched)
+
+	require.NoError(t, client.Release(ctx, first.ID))
+	report, err = client.DispatchQueue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Dispatched)
+
+	got, err := client.GetReservation(queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusProvisioning, got.Status)
+	assert.NotZero(t, got.Port)
+	assert.Nil(t, got.Request)
+	assert.Equal(t, 2, gpuCapacity(client))
+	assert.Equal(t, []string{EventDispatched}, eventTypes(*events))
+	assert.Empty(t, client.QueueLengths())
+}
+
+// stallingMarketplace blocks the first instance created after stall is set
+// until it is closed
+type stallingMarketplace struct {
+	gpu.GPUProvider
+	mu      sync.Mutex
+	stall   chan struct{}
+	created chan string
+}
+
+func (m *stallingMarketplace) CreateInstance(ctx context.Context, offerID string, config map[string]interface{}) (string, error) {
+	m.mu.Lock()
+	stall := m.stall
+	m.stall = nil
+	m.mu.Unlock()
+	if stall != nil {
+		<-stall
+	}
+
+	id, err := m.GPUProvider.CreateInstance(ctx, offerID, config)
+	if m.created != nil {
+		m.created <- id
+	}
+	return id, err
+}
+
+func TestQueueDispatchesConcurrently(t *testing.T) {
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{})
+	market := &stallingMarketplace{GPUProvider: fake}
+	client := newLifecycleClient(t, NewMemoryReservationStore(), fake, clock)
+	client.RegisterMarketplace(ProviderFake, market)
+	client.maxGPUs = 2
+	ctx := context.Background()
+
+	held, err := client.Reserve(ctx, queueRequest("u1", 2, 0))
+	require.NoError(t, err)
+	_, err = client.Reserve(ctx, queueRequest("u2", 1, 0))
+	require.NoError(t, err)
+	_, err = client.Reserve(ctx, queueRequest("u3", 1, 0))
+	require.NoError(t, err)
+	require.NoError(t, client.Release(ctx, held.ID))
+
+	stall := make(chan struct{})
+	market.stall = stall
+	market.created = make(chan string, 2)
+	done := make(chan *QueueReport)
+	go func() {
+		report, err := client.DispatchQueue(ctx)
+		assert.NoError(t, err)
+		done <- report
+	}()
+
+	// The first reservation's provider call hangs; the second still goes ahead
+	select {
+	case <-market.created:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow provider call held up the queue")
+	}
+	close(stall)
+	report := <-done
+	assert.Equal(t, 2, report.Dispatched)
+	assert.Equal(t, 2, gpuCapacity(client))
+}
+
+func TestQueuePreemption(t *testing.T) {
+	client, clock, events := newQueueClient(t, 4)
+	client.SetPreemptionGrace(time.Minute)
+	ctx := context.Background()
+
+	low := queueRequest("u1", 2, 2)
+	low.Preemptible = true
+	older, err := client.Reserve(ctx, low)
+	require.NoError(t, err)
+	clock.advance(time.Second)
+	newer, err := client.Reserve(ctx, low)
+	require.NoError(t, err)
+
+	high := queueRequest("u2", 2, 8)
+	high.Preempt = true
+	queued, err := client.Reserve(ctx, high)
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, queued.Status)
+
+	report, err := client.DispatchQueue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.PreemptionsScheduled)
+	assert.Zero(t, report.Dispatched)
+
+	got, err := client.GetReservation(newer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, clock.now().Add(time.Minute), got.PreemptAt, "the newest reservation loses the least work")
+	got, err = client.GetReservation(older.ID)
+	require.NoError(t, err)
+	assert.True(t, got.PreemptAt.IsZero())
+	require.Len(t, *events, 1)
+	assert.Equal(t, EventPreemptionWarning, (*events)[0].Type)
+	assert.Equal(t, newer.ID, (*events)[0].ReservationID)
+
+	// Capacity already being freed is not preempted twice
+	report, err = client.DispatchQueue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, report.PreemptionsScheduled)
+
+	clock.advance(time.Minute)
+	report, err = client.DispatchQueue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Preempted)
+	assert.Equal(t, 1, report.Dispatched)
+
+	got, err = client.GetReservation(newer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusTerminated, got.Status)
+	assert.Equal(t, ReleasePreempted, got.ReleaseReason)
+	got, err = client.GetReservation(queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusProvisioning, got.Status)
+	assert.Equal(t, 4, gpuCapacity(client))
+	assert.Equal(t, []string{EventPreemptionWarning, EventPreempted, EventDispatched}, eventTypes(*events))
+}
+
+func TestQueuePreemptsOnlyWhenEnough(t *testing.T) {
+	client, _, _ := newQueueClient(t, 4)
+	ctx := context.Background()
+
+	low := queueRequest("u1", 2, 2)
+	low.Preemptible = true
+	_, err := client.Reserve(ctx, low)
+	require.NoError(t, err)
+	_, err = client.Reserve(ctx, queueRequest("u1", 2, 2))
+	require.NoError(t, err)
+
+	high := queueRequest("u2", 4, 8)
+	high.Preempt = true
+	_, err = client.Reserve(ctx, high)
+	require.NoError(t, err)
+
+	report, err := client.DispatchQueue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, report.PreemptionsScheduled, "half the room is no use")
+}
+
+func TestCheckPriority(t *testing.T) {
+	client, _, _ := newQueueClient(t, 8)
+
+	assert.NoError(t, client.CheckPriority(queueRequest("u1", 1, 0), false), "the default priority is allowed")
+	assert.NoError(t, client.CheckPriority(queueRequest("u1", 1, DefaultPriority), false))
+	assert.ErrorIs(t, client.CheckPriority(queueRequest("u1", 1, 10), false), ErrPriorityNotAllowed)
+	assert.NoError(t, client.CheckPriority(queueRequest("u1", 1, 10), true), "admins are not limited")
+
+	preempt := queueRequest("u1", 1, 3)
+	preempt.Preempt = true
+	assert.ErrorIs(t, client.CheckPriority(preempt, false), ErrPriorityNotAllowed)
+	assert.NoError(t, client.CheckPriority(preempt, true))
+
+	client.SetPriorityLimits(PriorityLimits{MaxPriority: 8, Preempt: true})
+	preempt.Priority = 8
+	assert.NoError(t, client.CheckPriority(preempt, false))
+	preempt.Priority = 9
+	assert.ErrorIs(t, client.CheckPriority(preempt, false), ErrPriorityNotAllowed)
+}
+
+func TestQueueStatusETA(t *testing.T) {
+	client, clock, _ := newQueueClient(t, 2)
+	ctx := context.Background()
+	now := clock.now()
+
+	running := queueRequest("u1", 2, 0)
+	running.Duration = time.Hour
+	_, err := client.Reserve(ctx, running)
+	require.NoError(t, err)
+
+	next := queueRequest("u2", 1, 0)
+	next.Duration = 30 * time.Minute
+	first, err := client.Reserve(ctx, next)
+	require.NoError(t, err)
+	clock.advance(time.Second)
+	second, err := client.Reserve(ctx, queueRequest("u3", 2, 0))
+	require.NoError(t, err)
+	clock.advance(time.Second)
+	third, err := client.Reserve(ctx, queueRequest("u4", 1, 0))
+	require.NoError(t, err)
+
+	require.NoError(t, client.FillQueueStatus(ctx, first, second, third))
+	require.NotNil(t, first.Queue)
+	assert.Equal(t, 1, first.Queue.Position)
+	assert.Equal(t, 3, first.Queue.Length)
+	require.NotNil(t, first.Queue.ETA)
+	assert.Equal(t, now.Add(time.Hour), *first.Queue.ETA)
+
+	assert.Equal(t, 2, second.Queue.Position)
+	require.NotNil(t, second.Queue.ETA)
+	assert.Equal(t, now.Add(90*time.Minute), *second.Queue.ETA, "waits for the first queued request to finish too")
+
+	assert.Equal(t, 3, third.Queue.Position)
+	assert.Nil(t, third.Queue.ETA, "the second request never ends")
+}
+
+func TestReleaseQueuedReservation(t *testing.T) {
+	client, _, _ := newQueueClient(t, 1)
+	ctx := context.Background()
+
+	_, err := client.Reserve(ctx, queueRequest("u1", 1, 0))
+	require.NoError(t, err)
+	queued, err := client.Reserve(ctx, queueRequest("u2", 1, 0))
+	require.NoError(t, err)
+
+	require.NoError(t, client.Release(ctx, queued.ID))
+	got, err := client.GetReservation(queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusTerminated, got.Status)
+	assert.Equal(t, ReleaseCanceled, got.ReleaseReason)
+	assert.Equal(t, 1, gpuCapacity(client), "capacity it never held is not returned")
+	assert.Empty(t, client.QueueLengths())
+}
+
+func TestQueueSurvivesRestart(t *testing.T) {
+	fake, clock := newLifecycleFake(t, config.FakeMarketplaceConfig{})
+	path := filepath.Join(t.TempDir(), "reservations.db")
+	ctx := context.Background()
+
+	store := openSQLiteStore(t, path)
+	client := newLifecycleClient(t, store, fake, clock)
+	client.maxGPUs = 1
+	_, err := client.Reserve(ctx, queueRequest("u1", 1, 0))
+	require.NoError(t, err)
+	queued, err := client.Reserve(ctx, queueRequest("u2", 1, 7))
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+
+	store = openSQLiteStore(t, path)
+	client = newLifecycleClient(t, store, fake, clock)
+	defer client.Close()
+	client.maxGPUs = 1
+	assert.Equal(t, map[ComputeType]int{ComputeGPU: 1}, client.QueueLengths())
+
+	got, err := client.GetReservation(queued.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.Request)
+	assert.Equal(t, 7, got.Request.Priority)
+	assert.Equal(t, "u2", got.Request.UserID)
+}
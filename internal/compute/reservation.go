@@ -2,9 +2,17 @@ package compute
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/aiserve/gpuproxy/internal/gpu"
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/google/uuid"
 )
 
 // ComputeProvider represents different compute providers
@@ -15,6 +23,7 @@ const (
 	ProviderIONet      ComputeProvider = "ionet"
 	ProviderOpenRouter ComputeProvider = "openrouter"
 	ProviderLocal      ComputeProvider = "local"
+	ProviderFake       ComputeProvider = "fake"
 )
 
 // ComputeType represents the type of compute resource
@@ -36,9 +45,16 @@ type ReservationRequest struct {
 	Duration     time.Duration   `json:"duration"`                // How long to reserve
 	Region       string          `json:"region,omitempty"`        // Preferred region
 	MinVRAM      int             `json:"min_vram,omitempty"`      // Minimum VRAM in GB
+	SharedVRAM   int             `json:"shared_vram,omitempty"`   // Local only: share devices, taking this many GB of each
 	MaxCostPerHr float64         `json:"max_cost_per_hr"`         // Maximum cost per hour
 	Priority     int             `json:"priority"`                // 1-10, higher = more important
 	Labels       map[string]string `json:"labels,omitempty"`      // Custom labels
+	UserID       string          `json:"user_id,omitempty"`       // Owner, notified before expiry
+	WebhookURL   string          `json:"webhook_url,omitempty"`   // Receives the owner's reservation events
+	BookingID    string          `json:"-"`                       // Set when a booking provisions its slot
+	Preemptible  bool            `json:"preemptible"`             // Higher-priority requests may preempt it
+	Queue        bool            `json:"queue"`                   // Wait in the queue if capacity is exhausted
+	Preempt      bool            `json:"preempt"`                 // Preempt lower-priority preemptible reservations if needed; implies queue
 
 	// Hybrid mode options
 	EnableHybrid     bool              `json:"enable_hybrid"`      // Use multiple providers
@@ -51,7 +67,20 @@ type Reservation struct {
 	Provider     ComputeProvider `json:"provider"`
 	ComputeType  ComputeType     `json:"compute_type"`
 	InstanceID   string          `json:"instance_id"`    // Provider's instance ID
-	Status       string          `json:"status"`         // pending, active, terminated
+	Status       string          `json:"status"`         // See StatusPending etc.
+	Count        int             `json:"count"`          // GPUs or TPUs held against capacity
+	FailureReason string         `json:"failure_reason,omitempty"`
+	ReleaseReason string         `json:"release_reason,omitempty"` // Why it was released: expired, idle, ...
+	Version      int64           `json:"version"`        // Bumped on every save
+	UserID       string          `json:"user_id,omitempty"`
+	WebhookURL   string          `json:"webhook_url,omitempty"`
+	BookingID    string          `json:"booking_id,omitempty"` // Booking this reservation fills, if any
+	Priority     int             `json:"priority"`
+	Preemptible  bool            `json:"preemptible,omitempty"`
+	PreemptAt    time.Time       `json:"preempt_at,omitempty"`  // Released for a higher-priority request at this time
+	Request      *ReservationRequest `json:"request,omitempty"` // Kept while queued
+	Queue        *QueueStatus    `json:"queue,omitempty"`      // Position while queued; filled in when read
+	WarningsSent []time.Duration `json:"warnings_sent,omitempty"` // Expiry warnings already delivered
 	StartTime    time.Time       `json:"start_time"`
 	EndTime      time.Time       `json:"end_time"`
 	CostPerHr    float64         `json:"cost_per_hr"`
@@ -68,6 +97,23 @@ type Reservation struct {
 	UpdatedAt    time.Time       `json:"updated_at"`
 }
 
+// clone returns a copy of r that can be modified without affecting r
+func (r *Reservation) clone() *Reservation {
+	c := *r
+	c.WarningsSent = append([]time.Duration(nil), r.WarningsSent...)
+	if r.Request != nil {
+		req := *r.Request
+		c.Request = &req
+	}
+	if r.Metadata != nil {
+		c.Metadata = make(map[string]interface{}, len(r.Metadata))
+		for k, v := range r.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	return &c
+}
+
 // ReservationClient manages compute reservations across providers
 type ReservationClient struct {
 	mu sync.RWMutex
@@ -75,12 +121,23 @@ type ReservationClient struct {
 	// Provider clients
 	vastAIClient     *VastAIClient
 	ionetClient      *IONetClient
+	localDevices     *localDevices
 	openRouterClient *OpenRouterClient
 
+	// GPU marketplaces reserved through the gpu.GPUProvider interface
+	marketplaces     map[ComputeProvider]gpu.GPUProvider
+
+	// Reservations are persisted here; the counters below are derived from
+	// the live reservations in it
+	store            ReservationStore
+	reserving        int                      // Reserve calls in flight
+	reserveStarts    uint64                   // Reserve calls ever started
+
 	// Resource tracking
-	reservations     map[string]*Reservation  // reservation_id -> Reservation
 	activeGPUs       int                      // Current active GPUs
 	activeTPUs       int                      // Current active TPUs
+	queued           map[ComputeType]int      // Queued reservations
+	queueWake        chan struct{}            // Nudges the queue when capacity frees up
 
 	// Limits
 	maxGPUs          int                      // Max concurrent GPUs (1000 default)
@@ -90,135 +147,629 @@ type ReservationClient struct {
 
 	// Port management (2000-15000)
 	portAllocator    *PortAllocator
+
+	pendingTimeout   time.Duration            // Reconciler fails reservations pending longer
+	preemptionGrace  time.Duration            // Notice given before a preempted reservation is released
+	priorityLimits   PriorityLimits           // What non-admin users may ask for
+	now              func() time.Time
+
+	notifiers        []Notifier               // Receive reservation events
+	webhooks         *WebhookPolicy           // Vets owner webhook URLs
+
+	// admit, when set, vets holding count resources over [from, to) against
+	// capacity booked for the future; a zero to means open-ended. Once
+	// admitted, the caller calls release after recording the reservation.
+	admit            func(ctx context.Context, computeType ComputeType, count int, from, to time.Time) (release func(), err error)
 }
 
-// NewReservationClient creates a new reservation client
+// ErrCapacityExceeded is returned when a request does not fit in the
+// remaining capacity and was not queued
+var ErrCapacityExceeded = errors.New("capacity exceeded")
+
+// DefaultPriority is the priority of requests that do not set one
+const DefaultPriority = 5
+
+// maxSaveAttempts bounds how often an update is retried after losing a race
+// with another writer
+const maxSaveAttempts = 5
+
+// NewReservationClient creates a new reservation client that keeps
+// reservations in memory
 func NewReservationClient(vastAIKey, ionetKey, openRouterKey string) *ReservationClient {
+	return newReservationClient(NewMemoryReservationStore(), vastAIKey, ionetKey, openRouterKey)
+}
+
+// NewReservationClientWithStore creates a reservation client backed by store.
+// Capacity counters and ports are restored from the live reservations in it.
+func NewReservationClientWithStore(store ReservationStore, vastAIKey, ionetKey, openRouterKey string) (*ReservationClient, error) {
+	c := newReservationClient(store, vastAIKey, ionetKey, openRouterKey)
+
+	reservations, err := store.List(context.Background(), liveStatuses...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reservations: %w", err)
+	}
+	for _, r := range reservations {
+		if r.Port != 0 {
+			if err := c.portAllocator.Claim(r.Port); err != nil {
+				return nil, fmt.Errorf("failed to restore reservation %s: %w", r.ID, err)
+			}
+		}
+		if r.Provider == ProviderLocal && r.InstanceID != "" {
+			if err := c.localDevices.claim(r.UserID, r.InstanceID); err != nil {
+				return nil, fmt.Errorf("failed to restore reservation %s: %w", r.ID, err)
+			}
+		}
+		c.holdCapacity(r)
+	}
+
+	queued, err := store.List(context.Background(), StatusQueued)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load queued reservations: %w", err)
+	}
+	for _, r := range queued {
+		c.queued[r.ComputeType]++
+	}
+
+	return c, nil
+}
+
+func newReservationClient(store ReservationStore, vastAIKey, ionetKey, openRouterKey string) *ReservationClient {
 	return &ReservationClient{
 		vastAIClient:     NewVastAIClient(vastAIKey),
 		ionetClient:      NewIONetClient(ionetKey),
+		localDevices:     newLocalDevices(detectDevices),
 		openRouterClient: NewOpenRouterClient(openRouterKey),
-		reservations:     make(map[string]*Reservation),
+		marketplaces:     make(map[ComputeProvider]gpu.GPUProvider),
+		store:            store,
+		queued:           make(map[ComputeType]int),
+		queueWake:        make(chan struct{}, 1),
 		maxGPUs:          1000,
 		maxTPUs:          200,
 		maxVastAIGPUs:    500,
 		maxIONetGPUs:     500,
 		portAllocator:    NewPortAllocator(2000, 15000),
+		pendingTimeout:   DefaultPendingTimeout,
+		preemptionGrace:  DefaultPreemptionGrace,
+		priorityLimits:   PriorityLimits{MaxPriority: DefaultPriority},
+		now:              time.Now,
+		webhooks:         NewWebhookPolicy(),
 	}
 }
 
-// Reserve requests compute resources with intelligent provider selection
+// SetPendingTimeout sets how long a reservation may stay pending before the
+// reconciler fails it. Call it before the client is used.
+func (c *ReservationClient) SetPendingTimeout(d time.Duration) {
+	c.pendingTimeout = d
+}
+
+// SetPreemptionGrace sets how long a preempted reservation keeps running
+// after its owner is warned. Call it before the client is used.
+func (c *ReservationClient) SetPreemptionGrace(d time.Duration) {
+	c.preemptionGrace = d
+}
+
+// SetPriorityLimits sets the priority ceiling and preemption right of
+// non-admin users. Call it before the client is used.
+func (c *ReservationClient) SetPriorityLimits(limits PriorityLimits) {
+	c.priorityLimits = limits
+}
+
+// SetClock replaces the client clock, letting tests age reservations. Call
+// it before the client is used.
+func (c *ReservationClient) SetClock(now func() time.Time) {
+	c.now = now
+}
+
+// SetWebhookPolicy sets the policy owner webhook URLs are vetted against.
+// Call it before the client is used.
+func (c *ReservationClient) SetWebhookPolicy(policy *WebhookPolicy) {
+	c.webhooks = policy
+}
+
+// AddNotifier registers a receiver for reservation events such as expiry
+// warnings. Call it before the client is used.
+func (c *ReservationClient) AddNotifier(n Notifier) {
+	c.notifiers = append(c.notifiers, n)
+}
+
+// notify delivers an event to every notifier, logging failures
+func (c *ReservationClient) notify(ctx context.Context, event ReservationEvent) {
+	for _, n := range c.notifiers {
+		if err := n.Notify(ctx, event); err != nil {
+			log.Printf("Warning: reservation %s %s notification failed: %v", event.ReservationID, event.Type, err)
+		}
+	}
+}
+
+// Close closes the reservation store
+func (c *ReservationClient) Close() error {
+	return c.store.Close()
+}
+
+// Reserve requests compute resources with intelligent provider selection.
+// The reservation is recorded as pending before any provider is called, so a
+// crash mid-reserve leaves a record for the reconciler to clean up. When
+// capacity is exhausted a request that asks to queue is recorded as queued
+// and returned without error; it is started once capacity frees up.
 func (c *ReservationClient) Reserve(ctx context.Context, req *ReservationRequest) (*Reservation, error) {
+	normalized := *req
+	req = &normalized
+	if req.Priority == 0 {
+		req.Priority = DefaultPriority
+	}
+	if req.Priority < 1 || req.Priority > 10 {
+		return nil, fmt.Errorf("priority must be between 1 and 10")
+	}
+	if req.WebhookURL != "" && req.BookingID == "" {
+		if err := c.webhooks.Validate(ctx, req.WebhookURL); err != nil {
+			return nil, err
+		}
+	}
+
+	// Bookings had their capacity vetted when they were made
+	release := func() {}
+	if c.admit != nil && req.BookingID == "" {
+		now := c.now()
+		var end time.Time
+		if req.Duration > 0 {
+			end = now.Add(req.Duration)
+		}
+		admitted, err := c.admit(ctx, req.ComputeType, req.Count, now, end)
+		if err != nil {
+			if errors.Is(err, ErrBookingConflict) && (req.Queue || req.Preempt) {
+				return c.enqueue(ctx, req)
+			}
+			return nil, err
+		}
+		release = admitted
+	}
+	defer release()
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
 
-	// Check capacity limits
-	if req.ComputeType == ComputeGPU && c.activeGPUs+req.Count > c.maxGPUs {
-		return nil, fmt.Errorf("GPU capacity exceeded: current=%d, requested=%d, max=%d",
-			c.activeGPUs, req.Count, c.maxGPUs)
+	// Check capacity limits. Requests don't overtake queued ones, except
+	// for bookings, whose capacity is already set aside.
+	if err := c.checkCapacity(req.ComputeType, req.Count); err != nil || (req.BookingID == "" && c.queued[req.ComputeType] > 0) {
+		if err == nil {
+			err = fmt.Errorf("%s %w: %d requests are queued ahead", strings.ToUpper(string(req.ComputeType)), ErrCapacityExceeded, c.queued[req.ComputeType])
+		}
+		c.mu.Unlock()
+		release()
+		if req.Queue || req.Preempt {
+			return c.enqueue(ctx, req)
+		}
+		return nil, err
+	}
+
+	// Allocate port
+	port, err := c.portAllocator.Allocate()
+	if err != nil {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("failed to allocate port: %w", err)
+	}
+
+	now := c.now()
+	reservation := &Reservation{
+		ID:          "res-" + uuid.NewString(),
+		Provider:    req.Provider,
+		ComputeType: req.ComputeType,
+		Status:      StatusPending,
+		Count:       req.Count,
+		Port:        port,
+		UserID:      req.UserID,
+		WebhookURL:  req.WebhookURL,
+		BookingID:   req.BookingID,
+		Priority:    req.Priority,
+		Preemptible: req.Preemptible,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	c.holdCapacity(reservation)
+	c.reserving++
+	c.reserveStarts++
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.reserving--
+		c.mu.Unlock()
+	}()
+
+	err = c.store.Create(ctx, reservation)
+	release()
+	if err != nil {
+		c.releaseCapacity(reservation)
+		return nil, fmt.Errorf("failed to record reservation: %w", err)
+	}
+
+	return c.provisionReservation(ctx, reservation, req)
+}
+
+// checkCapacity reports ErrCapacityExceeded if count more resources of a
+// compute type would exceed its limit. Callers hold c.mu.
+func (c *ReservationClient) checkCapacity(computeType ComputeType, count int) error {
+	if computeType == ComputeGPU && c.activeGPUs+count > c.maxGPUs {
+		return fmt.Errorf("GPU %w: current=%d, requested=%d, max=%d",
+			ErrCapacityExceeded, c.activeGPUs, count, c.maxGPUs)
+	}
+	if computeType == ComputeTPU && c.activeTPUs+count > c.maxTPUs {
+		return fmt.Errorf("TPU %w: current=%d, requested=%d, max=%d",
+			ErrCapacityExceeded, c.activeTPUs, count, c.maxTPUs)
+	}
+	return nil
+}
+
+// provisionReservation provisions a pending reservation, moving it on to the
+// status the provider reports or to failed
+func (c *ReservationClient) provisionReservation(ctx context.Context, reservation *Reservation, req *ReservationRequest) (*Reservation, error) {
+	// Provider calls can outlive the caller's context; bookkeeping must not
+	record := context.WithoutCancel(ctx)
+
+	provisioned, err := c.provision(ctx, req)
+	if err != nil {
+		if _, failErr := c.transition(record, reservation, StatusFailed, func(next *Reservation) {
+			next.FailureReason = err.Error()
+		}); failErr != nil {
+			log.Printf("Failed to record failed reservation %s: %v", reservation.ID, failErr)
+		}
+		return nil, fmt.Errorf("failed to reserve compute: %w", err)
+	}
+
+	ok, err := c.transition(record, reservation, provisioned.Status, func(next *Reservation) {
+		next.Provider = provisioned.Provider
+		next.InstanceID = provisioned.InstanceID
+		next.StartTime = provisioned.StartTime
+		next.EndTime = provisioned.EndTime
+		if next.EndTime.IsZero() && req.Duration > 0 {
+			next.EndTime = c.now().Add(req.Duration)
+		}
+		next.CostPerHr = provisioned.CostPerHr
+		next.Endpoint = provisioned.Endpoint
+		next.Protocol = provisioned.Protocol
+		next.Metadata = provisioned.Metadata
+	})
+	if err != nil || !ok {
+		// Released (or failed by the reconciler) while provisioning; don't
+		// leave the instance running
+		if destroyErr := c.destroy(record, provisioned); destroyErr != nil {
+			log.Printf("Failed to destroy instance %s of reservation %s: %v", provisioned.InstanceID, reservation.ID, destroyErr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to record reservation: %w", err)
+		}
+		return nil, fmt.Errorf("reservation %s was released while provisioning", reservation.ID)
 	}
-	if req.ComputeType == ComputeTPU && c.activeTPUs+req.Count > c.maxTPUs {
-		return nil, fmt.Errorf("TPU capacity exceeded: current=%d, requested=%d, max=%d",
-			c.activeTPUs, req.Count, c.maxTPUs)
+
+	return reservation.clone(), nil
+}
+
+// provision calls the requested provider and, in hybrid mode, each fallback
+// provider in turn until one succeeds
+func (c *ReservationClient) provision(ctx context.Context, req *ReservationRequest) (*Reservation, error) {
+	providers := []ComputeProvider{req.Provider}
+	if req.EnableHybrid {
+		providers = append(providers, req.FallbackProviders...)
 	}
 
-	// Select provider based on request
-	var reservation *Reservation
 	var err error
+	for _, provider := range providers {
+		var reservation *Reservation
+		reservation, err = c.provisionWith(ctx, provider, req)
+		if err == nil {
+			return reservation, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
 
-	switch req.Provider {
+// provisionWith creates the instance for a request on one provider. The
+// returned reservation carries the provider's details and the status the
+// reservation should move to.
+func (c *ReservationClient) provisionWith(ctx context.Context, provider ComputeProvider, req *ReservationRequest) (*Reservation, error) {
+	switch provider {
 	case ProviderVastAI:
-		reservation, err = c.reserveVastAI(ctx, req)
+		return c.reserveVastAI(ctx, req)
 	case ProviderIONet:
-		reservation, err = c.reserveIONet(ctx, req)
+		return c.reserveIONet(ctx, req)
 	case ProviderOpenRouter:
-		reservation, err = c.reserveOpenRouter(ctx, req)
+		return c.reserveOpenRouter(ctx, req)
 	case ProviderLocal:
-		reservation, err = c.reserveLocal(ctx, req)
+		return c.reserveLocal(ctx, req)
 	default:
+		if marketplace, ok := c.marketplace(provider); ok {
+			return c.reserveMarketplace(ctx, provider, marketplace, req)
+		}
 		// Auto-select best provider
-		reservation, err = c.autoSelectProvider(ctx, req)
+		return c.autoSelectProvider(ctx, req)
 	}
+}
 
-	if err != nil {
-		// Try fallback providers if hybrid mode enabled
-		if req.EnableHybrid && len(req.FallbackProviders) > 0 {
-			for _, fallbackProvider := range req.FallbackProviders {
-				req.Provider = fallbackProvider
-				reservation, err = c.Reserve(ctx, req)
-				if err == nil {
-					break
+// Release terminates a reservation. The reservation drains while its
+// instance is destroyed; if that fails it stays draining and the reconciler
+// retries. Capacity and the port are returned exactly once, however many
+// callers race to release the same reservation.
+func (c *ReservationClient) Release(ctx context.Context, reservationID string) error {
+	return c.release(ctx, reservationID, "")
+}
+
+// release drains and releases a reservation, recording why
+func (c *ReservationClient) release(ctx context.Context, reservationID, reason string) error {
+	for attempt := 1; ; attempt++ {
+		reservation, err := c.store.Get(ctx, reservationID)
+		if err != nil {
+			return err
+		}
+		if isFinal(reservation.Status) {
+			return fmt.Errorf("reservation %s is already %s", reservationID, reservation.Status)
+		}
+
+		// A queued reservation has nothing to drain
+		if reservation.Status == StatusQueued {
+			ok, err := c.transition(ctx, reservation, StatusTerminated, func(next *Reservation) {
+				next.ReleaseReason = reason
+				if next.ReleaseReason == "" {
+					next.ReleaseReason = ReleaseCanceled
+				}
+			})
+			if err != nil {
+				return fmt.Errorf("failed to release reservation: %w", err)
+			}
+			if ok {
+				return nil
+			}
+			if attempt < maxSaveAttempts {
+				continue
+			}
+			return fmt.Errorf("reservation %s changed while releasing", reservationID)
+		}
+
+		if reservation.Status != StatusDraining {
+			ok, err := c.transition(ctx, reservation, StatusDraining, func(next *Reservation) {
+				next.ReleaseReason = reason
+			})
+			if err != nil {
+				return fmt.Errorf("failed to release reservation: %w", err)
+			}
+			if !ok {
+				if attempt < maxSaveAttempts {
+					continue
 				}
+				return fmt.Errorf("reservation %s changed while releasing", reservationID)
 			}
 		}
 
+		return c.finishDrain(ctx, reservation)
+	}
+}
+
+// Extend pushes a reservation's end time back by d, counted from now if it
+// has already passed, and re-arms its expiry warnings
+func (c *ReservationClient) Extend(ctx context.Context, reservationID string, d time.Duration) (*Reservation, error) {
+	if d <= 0 {
+		return nil, fmt.Errorf("extension must be positive")
+	}
+
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		reservation, err := c.store.Get(ctx, reservationID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to reserve compute: %w", err)
+			return nil, err
+		}
+		if reservation.Status != StatusProvisioning && reservation.Status != StatusActive {
+			return nil, fmt.Errorf("reservation %s is %s and cannot be extended", reservationID, reservation.Status)
+		}
+		if reservation.EndTime.IsZero() {
+			return nil, fmt.Errorf("reservation %s has no end time", reservationID)
+		}
+		release := func() {}
+		if c.admit != nil {
+			end := reservation.EndTime
+			if now := c.now(); now.After(end) {
+				end = now
+			}
+			admitted, err := c.admit(ctx, reservation.ComputeType, reservation.Count, end, end.Add(d))
+			if err != nil {
+				return nil, err
+			}
+			release = admitted
+		}
+
+		ok, err := c.save(ctx, reservation, func(next *Reservation) {
+			end := next.EndTime
+			if next.UpdatedAt.After(end) {
+				end = next.UpdatedAt
+			}
+			next.EndTime = end.Add(d)
+			next.WarningsSent = nil
+		})
+		release()
+		if err != nil {
+			return nil, fmt.Errorf("failed to extend reservation: %w", err)
+		}
+		if ok {
+			c.notify(ctx, newReservationEvent(EventExtended, reservation, c.now()))
+			return reservation.clone(), nil
 		}
 	}
 
-	// Allocate port
-	port, err := c.portAllocator.Allocate()
+	return nil, fmt.Errorf("reservation %s changed while extending", reservationID)
+}
+
+// ListUserReservations returns the reservations a user owns that have not
+// ended, including queued ones
+func (c *ReservationClient) ListUserReservations(ctx context.Context, userID string) ([]*Reservation, error) {
+	reservations, err := c.store.List(ctx, append([]string{StatusQueued}, liveStatuses...)...)
 	if err != nil {
-		// Cleanup reservation
-		c.Release(ctx, reservation.ID)
-		return nil, fmt.Errorf("failed to allocate port: %w", err)
+		return nil, fmt.Errorf("failed to list reservations: %w", err)
 	}
-	reservation.Port = port
 
-	// Track reservation
-	c.reservations[reservation.ID] = reservation
-	if req.ComputeType == ComputeGPU {
-		c.activeGPUs += req.Count
-	} else if req.ComputeType == ComputeTPU {
-		c.activeTPUs += req.Count
+	owned := make([]*Reservation, 0, len(reservations))
+	for _, r := range reservations {
+		if r.UserID == userID {
+			owned = append(owned, r)
+		}
 	}
+	return owned, nil
+}
 
-	return reservation, nil
+// UserInstance returns the user's active reservation running instanceID.
+// Proxies use it to count traffic to the reservation for idle expiry.
+func (c *ReservationClient) UserInstance(ctx context.Context, userID, instanceID string) (*Reservation, error) {
+	if userID == "" || instanceID == "" {
+		return nil, ErrReservationNotFound
+	}
+	reservations, err := c.store.List(ctx, StatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reservations: %w", err)
+	}
+	for _, r := range reservations {
+		if r.UserID == userID && r.InstanceID == instanceID {
+			return r, nil
+		}
+	}
+	return nil, ErrReservationNotFound
 }
 
-// Release terminates a reservation
-func (c *ReservationClient) Release(ctx context.Context, reservationID string) error {
+// holdCapacity counts r against the capacity limits. Callers hold c.mu.
+func (c *ReservationClient) holdCapacity(r *Reservation) {
+	if r.ComputeType == ComputeGPU {
+		c.activeGPUs += r.Count
+	} else if r.ComputeType == ComputeTPU {
+		c.activeTPUs += r.Count
+	}
+}
+
+// capacity returns the concurrent limit for a compute type; 0 means unlimited
+func (c *ReservationClient) capacity(computeType ComputeType) int {
+	switch computeType {
+	case ComputeGPU:
+		return c.maxGPUs
+	case ComputeTPU:
+		return c.maxTPUs
+	}
+	return 0
+}
+
+// releaseCapacity returns r's capacity and port
+func (c *ReservationClient) releaseCapacity(r *Reservation) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	reservation, exists := c.reservations[reservationID]
-	if !exists {
-		return fmt.Errorf("reservation not found: %s", reservationID)
+	if r.ComputeType == ComputeGPU {
+		c.activeGPUs -= r.Count
+	} else if r.ComputeType == ComputeTPU {
+		c.activeTPUs -= r.Count
 	}
+	c.portAllocator.Free(r.Port)
+	c.wakeQueue()
+}
 
-	// Release with appropriate provider
-	var err error
-	switch reservation.Provider {
+// RegisterMarketplace makes a GPU marketplace reservable under the given provider name
+func (c *ReservationClient) RegisterMarketplace(name ComputeProvider, marketplace gpu.GPUProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.marketplaces[name] = marketplace
+}
+
+// marketplace returns the marketplace registered under name. Vast.ai and
+// IO.net are built in.
+func (c *ReservationClient) marketplace(name ComputeProvider) (gpu.GPUProvider, bool) {
+	switch name {
 	case ProviderVastAI:
-		err = c.vastAIClient.DestroyInstance(ctx, reservation.InstanceID)
+		return c.vastAIClient, true
 	case ProviderIONet:
-		err = c.ionetClient.TerminateInstance(ctx, reservation.InstanceID)
-	case ProviderOpenRouter:
-		// OpenRouter doesn't need instance cleanup
-		err = nil
-	case ProviderLocal:
-		err = c.releaseLocal(ctx, reservation.InstanceID)
+		return c.ionetClient, true
 	}
 
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	marketplace, ok := c.marketplaces[name]
+	return marketplace, ok
+}
+
+// reserveMarketplace rents the cheapest marketplace offer matching the request,
+// moving on to the next offer if creating an instance fails
+func (c *ReservationClient) reserveMarketplace(ctx context.Context, name ComputeProvider, marketplace gpu.GPUProvider, req *ReservationRequest) (*Reservation, error) {
+	offers, err := marketplace.ListOffers(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to release reservation: %w", err)
+		return nil, fmt.Errorf("failed to list %s offers: %w", name, err)
+	}
+
+	var candidates []models.GPUInstance
+	for _, offer := range offers {
+		if offerMatches(offer, req) {
+			candidates = append(candidates, offer)
+		}
 	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no %s offers match the request", name)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].PricePerHour < candidates[j].PricePerHour
+	})
 
-	// Free port
-	c.portAllocator.Free(reservation.Port)
+	var lastErr error
+	for _, offer := range candidates {
+		instanceID, err := marketplace.CreateInstance(ctx, offer.ID, map[string]interface{}{})
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
 
-	// Update tracking
-	if reservation.ComputeType == ComputeGPU {
-		c.activeGPUs--
-	} else if reservation.ComputeType == ComputeTPU {
-		c.activeTPUs--
+		now := c.now()
+		reservation := &Reservation{
+			Provider:    name,
+			ComputeType: ComputeGPU,
+			InstanceID:  instanceID,
+			Status:      StatusProvisioning,
+			StartTime:   now,
+			CostPerHr:   offer.PricePerHour,
+			Protocol:    "http",
+			Metadata: map[string]interface{}{
+				"offer_id":  offer.ID,
+				"gpu_model": offer.GPUName,
+				"gpu_count": offer.GPUCount,
+				"vram_gb":   offer.VRAM,
+				"location":  offer.Location,
+			},
+		}
+		if req.Duration > 0 {
+			reservation.EndTime = now.Add(req.Duration)
+		}
+		return reservation, nil
 	}
 
-	reservation.Status = "terminated"
-	reservation.EndTime = time.Now()
+	return nil, fmt.Errorf("failed to create %s instance: %w", name, lastErr)
+}
 
-	return nil
+// offerMatches reports whether a marketplace offer satisfies a reservation request
+func offerMatches(offer models.GPUInstance, req *ReservationRequest) bool {
+	if !offer.Available {
+		return false
+	}
+	if req.GPUModel != "" && !strings.EqualFold(offer.GPUName, req.GPUModel) {
+		return false
+	}
+	if req.MinVRAM > 0 && offer.VRAM < req.MinVRAM {
+		return false
+	}
+	if req.Region != "" && !strings.HasPrefix(strings.ToLower(offer.Location), strings.ToLower(req.Region)) {
+		return false
+	}
+	if req.MaxCostPerHr > 0 && offer.PricePerHour > req.MaxCostPerHr {
+		return false
+	}
+	if req.Count > 0 && offer.GPUCount < req.Count {
+		return false
+	}
+	return true
 }
 
 // autoSelectProvider intelligently selects the best provider
@@ -239,8 +790,10 @@ func (c *ReservationClient) autoSelectProvider(ctx context.Context, req *Reserva
 	}
 
 	// For general GPU requests, balance between Vast.ai and IO.net
+	c.mu.RLock()
 	vastAILoad := float64(c.activeGPUs) / float64(c.maxVastAIGPUs)
 	ionetLoad := float64(c.activeGPUs) / float64(c.maxIONetGPUs)
+	c.mu.RUnlock()
 
 	if vastAILoad < ionetLoad {
 		return c.reserveVastAI(ctx, req)
@@ -248,16 +801,20 @@ func (c *ReservationClient) autoSelectProvider(ctx context.Context, req *Reserva
 	return c.reserveIONet(ctx, req)
 }
 
-// reserveVastAI reserves compute from Vast.ai
+// reserveVastAI rents the cheapest Vast.ai offer matching the request
 func (c *ReservationClient) reserveVastAI(ctx context.Context, req *ReservationRequest) (*Reservation, error) {
-	// TODO: Implement Vast.ai specific reservation logic
-	return nil, fmt.Errorf("Vast.ai reservation not yet implemented")
+	if !c.vastAIClient.Configured() {
+		return nil, fmt.Errorf("Vast.ai API key not configured")
+	}
+	return c.reserveMarketplace(ctx, ProviderVastAI, c.vastAIClient, req)
 }
 
-// reserveIONet reserves compute from IO.net
+// reserveIONet rents the cheapest IO.net device matching the request
 func (c *ReservationClient) reserveIONet(ctx context.Context, req *ReservationRequest) (*Reservation, error) {
-	// TODO: Implement IO.net specific reservation logic
-	return nil, fmt.Errorf("IO.net reservation not yet implemented")
+	if !c.ionetClient.Configured() {
+		return nil, fmt.Errorf("IO.net API key not configured")
+	}
+	return c.reserveMarketplace(ctx, ProviderIONet, c.ionetClient, req)
 }
 
 // reserveOpenRouter reserves compute from OpenRouter
@@ -266,59 +823,83 @@ func (c *ReservationClient) reserveOpenRouter(ctx context.Context, req *Reservat
 	instanceID := fmt.Sprintf("openrouter-%d", time.Now().Unix())
 
 	reservation := &Reservation{
-		ID:          fmt.Sprintf("res-%d", time.Now().UnixNano()),
 		Provider:    ProviderOpenRouter,
 		ComputeType: ComputeGPU, // OpenRouter uses GPUs behind the scenes
 		InstanceID:  instanceID,
-		Status:      "active",
+		Status:      StatusActive,
 		StartTime:   time.Now(),
 		Endpoint:    "https://openrouter.ai/api/v1",
 		Protocol:    "http",
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
 		Metadata:    make(map[string]interface{}),
 	}
 
 	return reservation, nil
 }
 
-// reserveLocal reserves local compute resources
+// reserveLocal reserves GPUs on this host. Devices are held whole unless
+// SharedVRAM asks for a slice of each.
 func (c *ReservationClient) reserveLocal(ctx context.Context, req *ReservationRequest) (*Reservation, error) {
-	// TODO: Implement local GPU/TPU reservation
-	return nil, fmt.Errorf("local reservation not yet implemented")
+	if req.ComputeType == ComputeTPU {
+		return nil, fmt.Errorf("local TPU reservations are not supported")
+	}
+
+	alloc, err := c.localDevices.get().Allocate(gpu.DeviceRequest{
+		Owner:  req.UserID,
+		Count:  req.Count,
+		VRAMMB: req.SharedVRAM * 1024,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.now()
+	reservation := &Reservation{
+		Provider:    ProviderLocal,
+		ComputeType: ComputeGPU,
+		InstanceID:  localInstanceID(alloc),
+		Status:      StatusActive,
+		StartTime:   now,
+		Endpoint:    "localhost",
+		Protocol:    "http",
+		Metadata: map[string]interface{}{
+			"backend": string(alloc.Backend),
+			"devices": alloc.Devices,
+			"uuids":   alloc.UUIDs,
+			"env":     alloc.Env(),
+		},
+	}
+	if alloc.VRAMMB > 0 {
+		reservation.Metadata["vram_mb"] = alloc.VRAMMB
+	}
+	if req.Duration > 0 {
+		reservation.EndTime = now.Add(req.Duration)
+	}
+	return reservation, nil
 }
 
-// releaseLocal releases local compute resources
+// releaseLocal returns a local reservation's devices
 func (c *ReservationClient) releaseLocal(ctx context.Context, instanceID string) error {
-	// TODO: Implement local resource cleanup
-	return nil
+	return c.localDevices.free(instanceID)
+}
+
+// SetDeviceAllocator makes local reservations take devices from a, so they
+// share this host's GPUs with anything else allocating from it
+func (c *ReservationClient) SetDeviceAllocator(a *gpu.DeviceAllocator) {
+	c.localDevices.set(a)
 }
 
 // GetReservation retrieves a reservation by ID
 func (c *ReservationClient) GetReservation(reservationID string) (*Reservation, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	reservation, exists := c.reservations[reservationID]
-	if !exists {
-		return nil, fmt.Errorf("reservation not found: %s", reservationID)
-	}
-
-	return reservation, nil
+	return c.store.Get(context.Background(), reservationID)
 }
 
 // ListReservations returns all active reservations
 func (c *ReservationClient) ListReservations() []*Reservation {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	reservations := make([]*Reservation, 0, len(c.reservations))
-	for _, res := range c.reservations {
-		if res.Status == "active" {
-			reservations = append(reservations, res)
-		}
+	reservations, err := c.store.List(context.Background(), StatusActive)
+	if err != nil {
+		log.Printf("Failed to list reservations: %v", err)
+		return []*Reservation{}
 	}
-
 	return reservations
 }
 
@@ -332,11 +913,13 @@ func (c *ReservationClient) GetCapacity() map[string]interface{} {
 			"active":  c.activeGPUs,
 			"max":     c.maxGPUs,
 			"available": c.maxGPUs - c.activeGPUs,
+			"queued_requests": c.queued[ComputeGPU],
 		},
 		"tpus": map[string]int{
 			"active":  c.activeTPUs,
 			"max":     c.maxTPUs,
 			"available": c.maxTPUs - c.activeTPUs,
+			"queued_requests": c.queued[ComputeTPU],
 		},
 		"providers": map[string]interface{}{
 			"vastai": map[string]int{
@@ -0,0 +1,56 @@
+package compute
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/gpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeReservationClient(t *testing.T, cfg config.FakeMarketplaceConfig) (*ReservationClient, *gpu.FakeMarketplace) {
+	t.Helper()
+	fake, err := gpu.NewFakeMarketplace(cfg)
+	require.NoError(t, err)
+
+	client := NewReservationClient("", "", "")
+	client.RegisterMarketplace(ProviderFake, fake)
+	return client, fake
+}
+
+func TestReserveFromMarketplace(t *testing.T) {
+	client, fake := newFakeReservationClient(t, config.FakeMarketplaceConfig{})
+	ctx := context.Background()
+
+	res, err := client.Reserve(ctx, &ReservationRequest{
+		Provider:     ProviderFake,
+		ComputeType:  ComputeGPU,
+		GPUModel:     "a100",
+		Count:        1,
+		MaxCostPerHr: 5,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ProviderFake, res.Provider)
+	assert.Equal(t, 1.10, res.CostPerHr, "the cheapest matching offer is rented")
+	assert.Equal(t, "A100", res.Metadata["gpu_model"])
+	assert.Equal(t, []string{res.InstanceID}, fake.Instances())
+
+	_, err = client.Reserve(ctx, &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, MinVRAM: 1000})
+	assert.ErrorContains(t, err, "no fake offers match")
+
+	require.NoError(t, client.Release(ctx, res.ID))
+	assert.Empty(t, fake.Instances())
+}
+
+func TestReserveFromMarketplaceSkipsFailedOffers(t *testing.T) {
+	// Seed 6 fails the first create at this rate, so the next cheapest offer is used
+	client, fake := newFakeReservationClient(t, config.FakeMarketplaceConfig{Seed: 6, FailureRate: 0.5})
+
+	res, err := client.Reserve(context.Background(), &ReservationRequest{Provider: ProviderFake, ComputeType: ComputeGPU, GPUModel: "H100"})
+	require.NoError(t, err)
+	assert.Len(t, fake.Instances(), 1)
+	assert.Equal(t, "H100", res.Metadata["gpu_model"])
+	assert.Equal(t, 19.92, res.CostPerHr)
+}
@@ -0,0 +1,461 @@
+package compute
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrReservationNotFound is returned when a store has no reservation with the given ID
+var ErrReservationNotFound = errors.New("reservation not found")
+
+// ErrBookingNotFound is returned when a store has no booking with the given ID
+var ErrBookingNotFound = errors.New("booking not found")
+
+// ReservationStore persists reservations and bookings so they survive restarts
+type ReservationStore interface {
+	BookingStore
+
+	// Create saves a new reservation. It fails if the ID is already taken.
+	Create(ctx context.Context, r *Reservation) error
+	// Get returns a reservation by ID
+	Get(ctx context.Context, id string) (*Reservation, error)
+	// List returns reservations in any of the given statuses, or all
+	// reservations when none are given, oldest first
+	List(ctx context.Context, statuses ...string) ([]*Reservation, error)
+	// CompareAndSwap saves r only if the stored reservation is still at the
+	// given version. It returns false if another writer saved it first.
+	CompareAndSwap(ctx context.Context, version int64, r *Reservation) (bool, error)
+	Close() error
+}
+
+// BookingStore persists advance bookings
+type BookingStore interface {
+	// CreateBooking saves a new booking
+	CreateBooking(ctx context.Context, b *Booking) error
+	// GetBooking returns a booking by ID
+	GetBooking(ctx context.Context, id string) (*Booking, error)
+	// ListBookings returns bookings whose window overlaps [from, to) in any of
+	// the given statuses, by start time. A zero from or to leaves that side open.
+	ListBookings(ctx context.Context, from, to time.Time, statuses ...string) ([]*Booking, error)
+	// CompareAndSwapBooking saves b only if the stored booking is still at
+	// the given version
+	CompareAndSwapBooking(ctx context.Context, version int64, b *Booking) (bool, error)
+}
+
+// OpenReservationStore returns the store for a GPU_RESERVATION_STORE setting:
+// "memory" (or empty) keeps reservations in process memory only, and
+// "database" keeps them in db, the server's database.
+func OpenReservationStore(kind string, db *sql.DB) (ReservationStore, error) {
+	switch kind {
+	case "", "memory":
+		return NewMemoryReservationStore(), nil
+	case "database":
+		if db == nil {
+			return nil, fmt.Errorf("reservation store %q needs a database", kind)
+		}
+		return NewSQLReservationStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown reservation store %q", kind)
+	}
+}
+
+// MemoryReservationStore keeps reservations in process memory
+type MemoryReservationStore struct {
+	mu           sync.Mutex
+	reservations map[string]*Reservation
+	bookings     map[string]*Booking
+}
+
+// NewMemoryReservationStore creates an empty in-memory store
+func NewMemoryReservationStore() *MemoryReservationStore {
+	return &MemoryReservationStore{
+		reservations: make(map[string]*Reservation),
+		bookings:     make(map[string]*Booking),
+	}
+}
+
+// Create saves a new reservation
+func (s *MemoryReservationStore) Create(ctx context.Context, r *Reservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.reservations[r.ID]; exists {
+		return fmt.Errorf("reservation %s already exists", r.ID)
+	}
+	s.reservations[r.ID] = r.clone()
+	return nil
+}
+
+// Get returns a reservation by ID
+func (s *MemoryReservationStore) Get(ctx context.Context, id string) (*Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.reservations[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrReservationNotFound, id)
+	}
+	return r.clone(), nil
+}
+
+// List returns reservations in any of the given statuses
+func (s *MemoryReservationStore) List(ctx context.Context, statuses ...string) ([]*Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []*Reservation
+	for _, r := range s.reservations {
+		if len(statuses) == 0 || containsStatus(statuses, r.Status) {
+			list = append(list, r.clone())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+// CompareAndSwap saves r if the stored reservation is still at version
+func (s *MemoryReservationStore) CompareAndSwap(ctx context.Context, version int64, r *Reservation) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.reservations[r.ID]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrReservationNotFound, r.ID)
+	}
+	if current.Version != version {
+		return false, nil
+	}
+	s.reservations[r.ID] = r.clone()
+	return true, nil
+}
+
+// CreateBooking saves a new booking
+func (s *MemoryReservationStore) CreateBooking(ctx context.Context, b *Booking) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.bookings[b.ID]; exists {
+		return fmt.Errorf("booking %s already exists", b.ID)
+	}
+	s.bookings[b.ID] = b.clone()
+	return nil
+}
+
+// GetBooking returns a booking by ID
+func (s *MemoryReservationStore) GetBooking(ctx context.Context, id string) (*Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.bookings[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrBookingNotFound, id)
+	}
+	return b.clone(), nil
+}
+
+// ListBookings returns bookings overlapping [from, to) in any of the given statuses
+func (s *MemoryReservationStore) ListBookings(ctx context.Context, from, to time.Time, statuses ...string) ([]*Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []*Booking
+	for _, b := range s.bookings {
+		if !to.IsZero() && !b.StartTime.Before(to) {
+			continue
+		}
+		if !from.IsZero() && !b.EndTime.After(from) {
+			continue
+		}
+		if len(statuses) == 0 || containsStatus(statuses, b.Status) {
+			list = append(list, b.clone())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].StartTime.Equal(list[j].StartTime) {
+			return list[i].StartTime.Before(list[j].StartTime)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+// CompareAndSwapBooking saves b if the stored booking is still at version
+func (s *MemoryReservationStore) CompareAndSwapBooking(ctx context.Context, version int64, b *Booking) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.bookings[b.ID]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrBookingNotFound, b.ID)
+	}
+	if current.Version != version {
+		return false, nil
+	}
+	s.bookings[b.ID] = b.clone()
+	return true, nil
+}
+
+// Close is a no-op
+func (s *MemoryReservationStore) Close() error {
+	return nil
+}
+
+// SQLReservationStore is a ReservationStore backed by Postgres or SQLite. The
+// version and status are kept in their own columns for compare-and-swap
+// updates and queries; the rest of the reservation is stored as JSON.
+type SQLReservationStore struct {
+	db *sql.DB
+}
+
+// NewSQLReservationStore keeps reservations in db, which needs the tables
+// from the database package's migrations. The store doesn't close db.
+func NewSQLReservationStore(db *sql.DB) *SQLReservationStore {
+	return &SQLReservationStore{db: db}
+}
+
+// Create saves a new reservation
+func (s *SQLReservationStore) Create(ctx context.Context, r *Reservation) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode reservation: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO compute_reservations (id, version, status, provider, instance_id, created_at, updated_at, data)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		r.ID, r.Version, r.Status, string(r.Provider), r.InstanceID, r.CreatedAt.UnixNano(), r.UpdatedAt.UnixNano(), string(data))
+	if err != nil {
+		return fmt.Errorf("failed to create reservation: %w", err)
+	}
+	return nil
+}
+
+// Get returns a reservation by ID
+func (s *SQLReservationStore) Get(ctx context.Context, id string) (*Reservation, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM compute_reservations WHERE id = $1`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrReservationNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reservation: %w", err)
+	}
+	return decodeReservation(data)
+}
+
+// List returns reservations in any of the given statuses
+func (s *SQLReservationStore) List(ctx context.Context, statuses ...string) ([]*Reservation, error) {
+	query := `SELECT data FROM compute_reservations`
+	args := make([]interface{}, len(statuses))
+	if len(statuses) > 0 {
+		placeholders := make([]string, len(statuses))
+		for i, status := range statuses {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+			args[i] = status
+		}
+		query += ` WHERE status IN (` + strings.Join(placeholders, ", ") + `)`
+	}
+	query += ` ORDER BY created_at, id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reservations: %w", err)
+	}
+	defer rows.Close()
+
+	var list []*Reservation
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read reservation: %w", err)
+		}
+		r, err := decodeReservation(data)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// CompareAndSwap saves r if the stored reservation is still at version
+func (s *SQLReservationStore) CompareAndSwap(ctx context.Context, version int64, r *Reservation) (bool, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode reservation: %w", err)
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE compute_reservations
+		 SET version = $1, status = $2, provider = $3, instance_id = $4, updated_at = $5, data = $6
+		 WHERE id = $7 AND version = $8`,
+		r.Version, r.Status, string(r.Provider), r.InstanceID, r.UpdatedAt.UnixNano(), string(data), r.ID, version)
+	if err != nil {
+		return false, fmt.Errorf("failed to update reservation: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n == 1 {
+		return true, nil
+	}
+
+	// Tell a lost race apart from a missing reservation
+	if _, err := s.Get(ctx, r.ID); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// CreateBooking saves a new booking
+func (s *SQLReservationStore) CreateBooking(ctx context.Context, b *Booking) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to encode booking: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO compute_bookings (id, version, status, user_id, start_at, end_at, created_at, updated_at, data)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		b.ID, b.Version, b.Status, b.UserID, b.StartTime.UnixNano(), b.EndTime.UnixNano(),
+		b.CreatedAt.UnixNano(), b.UpdatedAt.UnixNano(), string(data))
+	if err != nil {
+		return fmt.Errorf("failed to create booking: %w", err)
+	}
+	return nil
+}
+
+// GetBooking returns a booking by ID
+func (s *SQLReservationStore) GetBooking(ctx context.Context, id string) (*Booking, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM compute_bookings WHERE id = $1`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrBookingNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read booking: %w", err)
+	}
+	return decodeBooking(data)
+}
+
+// ListBookings returns bookings overlapping [from, to) in any of the given statuses
+func (s *SQLReservationStore) ListBookings(ctx context.Context, from, to time.Time, statuses ...string) ([]*Booking, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if !to.IsZero() {
+		conditions = append(conditions, "start_at < "+arg(to.UnixNano()))
+	}
+	if !from.IsZero() {
+		conditions = append(conditions, "end_at > "+arg(from.UnixNano()))
+	}
+	if len(statuses) > 0 {
+		placeholders := make([]string, len(statuses))
+		for i, status := range statuses {
+			placeholders[i] = arg(status)
+		}
+		conditions = append(conditions, "status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	query := `SELECT data FROM compute_bookings`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY start_at, id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bookings: %w", err)
+	}
+	defer rows.Close()
+
+	var list []*Booking
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read booking: %w", err)
+		}
+		b, err := decodeBooking(data)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, b)
+	}
+	return list, rows.Err()
+}
+
+// CompareAndSwapBooking saves b if the stored booking is still at version
+func (s *SQLReservationStore) CompareAndSwapBooking(ctx context.Context, version int64, b *Booking) (bool, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode booking: %w", err)
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE compute_bookings
+		 SET version = $1, status = $2, start_at = $3, end_at = $4, updated_at = $5, data = $6
+		 WHERE id = $7 AND version = $8`,
+		b.Version, b.Status, b.StartTime.UnixNano(), b.EndTime.UnixNano(), b.UpdatedAt.UnixNano(), string(data), b.ID, version)
+	if err != nil {
+		return false, fmt.Errorf("failed to update booking: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n == 1 {
+		return true, nil
+	}
+
+	if _, err := s.GetBooking(ctx, b.ID); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// Close is a no-op; the database belongs to the caller
+func (s *SQLReservationStore) Close() error {
+	return nil
+}
+
+func decodeReservation(data string) (*Reservation, error) {
+	var r Reservation
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		return nil, fmt.Errorf("failed to decode reservation: %w", err)
+	}
+	return &r, nil
+}
+
+func decodeBooking(data string) (*Booking, error) {
+	var b Booking
+	if err := json.Unmarshal([]byte(data), &b); err != nil {
+		return nil, fmt.Errorf("failed to decode booking: %w", err)
+	}
+	return &b, nil
+}
+
+func containsStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package compute
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/database"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openSQLiteStore opens a migrated SQLite database at path and keeps
+// reservations in it until the test ends
+func openSQLiteStore(t *testing.T, path string) ReservationStore {
+	t.Helper()
+	db, err := database.NewSQLiteDB(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, db.Migrate())
+	return NewSQLReservationStore(db.SQLDB())
+}
+
+// testReservationStores returns every store implementation available here.
+// Postgres runs when GPU_RESERVATION_TEST_POSTGRES holds a DSN.
+func testReservationStores(t *testing.T) map[string]func() ReservationStore {
+	stores := map[string]func() ReservationStore{
+		"memory": func() ReservationStore { return NewMemoryReservationStore() },
+		"sqlite": func() ReservationStore {
+			return openSQLiteStore(t, filepath.Join(t.TempDir(), "reservations.db"))
+		},
+	}
+	if dsn := os.Getenv("GPU_RESERVATION_TEST_POSTGRES"); dsn != "" {
+		stores["postgres"] = func() ReservationStore {
+			pool, err := pgxpool.New(context.Background(), dsn)
+			require.NoError(t, err)
+			t.Cleanup(pool.Close)
+			db := &database.PostgresDB{Pool: pool}
+			require.NoError(t, db.Migrate())
+			store := NewSQLReservationStore(db.SQLDB())
+			_, err = store.db.Exec(`DELETE FROM compute_reservations`)
+			require.NoError(t, err)
+			_, err = store.db.Exec(`DELETE FROM compute_bookings`)
+			require.NoError(t, err)
+			return store
+		}
+	}
+	return stores
+}
+
+func TestReservationStoreConformance(t *testing.T) {
+	for name, open := range testReservationStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := open()
+			defer store.Close()
+			ctx := context.Background()
+
+			created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			a := &Reservation{ID: "a", Provider: ProviderFake, ComputeType: ComputeGPU, Status: StatusPending, Count: 2, Port: 2000, CreatedAt: created}
+			b := &Reservation{ID: "b", Provider: ProviderFake, ComputeType: ComputeGPU, Status: StatusActive, Count: 1, CreatedAt: created.Add(time.Second)}
+			require.NoError(t, store.Create(ctx, a))
+			require.NoError(t, store.Create(ctx, b))
+			assert.Error(t, store.Create(ctx, a), "IDs are unique")
+
+			got, err := store.Get(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, StatusPending, got.Status)
+			assert.Equal(t, 2, got.Count)
+			assert.Equal(t, 2000, got.Port)
+			assert.True(t, created.Equal(got.CreatedAt))
+
+			_, err = store.Get(ctx, "missing")
+			assert.ErrorIs(t, err, ErrReservationNotFound)
+
+			// Only a writer that saw the current version wins
+			next := got.clone()
+			next.Version = 1
+			next.Status = StatusProvisioning
+			next.InstanceID = "i-1"
+			ok, err := store.CompareAndSwap(ctx, 0, next)
+			require.NoError(t, err)
+			assert.True(t, ok)
+
+			stale := got.clone()
+			stale.Version = 1
+			stale.Status = StatusFailed
+			ok, err = store.CompareAndSwap(ctx, 0, stale)
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			got, err = store.Get(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), got.Version)
+			assert.Equal(t, StatusProvisioning, got.Status)
+			assert.Equal(t, "i-1", got.InstanceID)
+
+			_, err = store.CompareAndSwap(ctx, 0, &Reservation{ID: "missing"})
+			assert.ErrorIs(t, err, ErrReservationNotFound)
+
+			all, err := store.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, all, 2)
+			assert.Equal(t, "a", all[0].ID, "oldest first")
+
+			active, err := store.List(ctx, StatusActive, StatusDraining)
+			require.NoError(t, err)
+			require.Len(t, active, 1)
+			assert.Equal(t, "b", active[0].ID)
+		})
+	}
+}
+
+func TestOpenReservationStore(t *testing.T) {
+	store, err := OpenReservationStore("", nil)
+	require.NoError(t, err)
+	assert.IsType(t, &MemoryReservationStore{}, store)
+
+	store, err = OpenReservationStore("memory", nil)
+	require.NoError(t, err)
+	assert.IsType(t, &MemoryReservationStore{}, store)
+
+	db := openSQLiteStore(t, filepath.Join(t.TempDir(), "reservations.db")).(*SQLReservationStore).db
+	store, err = OpenReservationStore("database", db)
+	require.NoError(t, err)
+	assert.IsType(t, &SQLReservationStore{}, store)
+
+	_, err = OpenReservationStore("database", nil)
+	assert.Error(t, err)
+	_, err = OpenReservationStore("postgres://localhost/reservations", db)
+	assert.Error(t, err, "stores share the server's database")
+}
+
+func TestBookingStoreConformance(t *testing.T) {
+	for name, open := range testReservationStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := open()
+			defer store.Close()
+			ctx := context.Background()
+
+			day := time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)
+			a := &Booking{ID: "a", UserID: "u1", Status: BookingScheduled, StartTime: day.Add(2 * time.Hour), EndTime: day.Add(10 * time.Hour),
+				Request: ReservationRequest{ComputeType: ComputeGPU, GPUModel: "H100", Count: 8, Labels: map[string]string{"team": "ml"}}}
+			b := &Booking{ID: "b", UserID: "u2", Status: BookingCanceled, StartTime: day.Add(12 * time.Hour), EndTime: day.Add(14 * time.Hour)}
+			require.NoError(t, store.CreateBooking(ctx, a))
+			require.NoError(t, store.CreateBooking(ctx, b))
+			assert.Error(t, store.CreateBooking(ctx, a), "IDs are unique")
+
+			got, err := store.GetBooking(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, 8, got.Request.Count)
+			assert.Equal(t, "ml", got.Request.Labels["team"])
+			assert.True(t, a.StartTime.Equal(got.StartTime))
+
+			_, err = store.GetBooking(ctx, "missing")
+			assert.ErrorIs(t, err, ErrBookingNotFound)
+
+			next := got.clone()
+			next.Version = 1
+			next.Status = BookingActive
+			ok, err := store.CompareAndSwapBooking(ctx, 0, next)
+			require.NoError(t, err)
+			assert.True(t, ok)
+			ok, err = store.CompareAndSwapBooking(ctx, 0, next)
+			require.NoError(t, err)
+			assert.False(t, ok)
+			_, err = store.CompareAndSwapBooking(ctx, 0, &Booking{ID: "missing"})
+			assert.ErrorIs(t, err, ErrBookingNotFound)
+
+			all, err := store.ListBookings(ctx, time.Time{}, time.Time{})
+			require.NoError(t, err)
+			require.Len(t, all, 2)
+			assert.Equal(t, "a", all[0].ID, "earliest start first")
+
+			// Windows overlap when each starts before the other ends
+			window, err := store.ListBookings(ctx, day.Add(10*time.Hour), day.Add(12*time.Hour))
+			require.NoError(t, err)
+			assert.Empty(t, window)
+			window, err = store.ListBookings(ctx, day.Add(9*time.Hour), day.Add(13*time.Hour), BookingActive)
+			require.NoError(t, err)
+			require.Len(t, window, 1)
+			assert.Equal(t, "a", window[0].ID)
+		})
+	}
+}
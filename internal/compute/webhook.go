@@ -0,0 +1,112 @@
+package compute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrInvalidWebhook is returned for owner webhooks that may not be called
+var ErrInvalidWebhook = errors.New("invalid webhook_url")
+
+// WebhookPolicy decides which owner-supplied webhook URLs the server will
+// call. Owners may only use public https endpoints, so a reservation can't be
+// used to reach loopback, private or cloud metadata addresses. Hosts on the
+// operator's allowlist skip these checks.
+type WebhookPolicy struct {
+	allowed map[string]bool
+	lookup  func(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// NewWebhookPolicy creates a policy trusting the given hosts
+func NewWebhookPolicy(allowedHosts ...string) *WebhookPolicy {
+	p := &WebhookPolicy{
+		allowed: make(map[string]bool, len(allowedHosts)),
+		lookup:  net.DefaultResolver.LookupIPAddr,
+	}
+	for _, host := range allowedHosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			p.allowed[host] = true
+		}
+	}
+	return p
+}
+
+// Validate checks an owner webhook URL, resolving its host
+func (p *WebhookPolicy) Validate(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: must be an absolute URL", ErrInvalidWebhook)
+	}
+	host := strings.ToLower(u.Hostname())
+	if p.allowed[host] {
+		return nil
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("%w: must use https", ErrInvalidWebhook)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: must not contain credentials", ErrInvalidWebhook)
+	}
+
+	addrs, err := p.lookup(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: failed to resolve %s: %v", ErrInvalidWebhook, host, err)
+	}
+	for _, addr := range addrs {
+		if blockedWebhookIP(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to a non-public address", ErrInvalidWebhook, host)
+		}
+	}
+	return nil
+}
+
+// client returns an HTTP client for owner webhooks. Addresses are checked
+// again when connecting, so a host can't pass validation and later resolve
+// somewhere internal.
+func (p *WebhookPolicy) client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || blockedWebhookIP(ip) {
+				return fmt.Errorf("%w: refusing to connect to %s", ErrInvalidWebhook, host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// allows reports whether a URL's host is on the operator's allowlist
+func (p *WebhookPolicy) allows(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && p.allowed[strings.ToLower(u.Hostname())]
+}
+
+// blockedWebhookIP reports whether an address is loopback, private,
+// link-local (including cloud metadata endpoints) or otherwise not public
+func blockedWebhookIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, 100.64.0.0/10
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
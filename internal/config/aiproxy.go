@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aiserve/gpuproxy/internal/router/policy"
 	"gopkg.in/yaml.v3"
 )
 
@@ -39,6 +40,8 @@ type MeshConfig struct {
 	ShareLoad         bool          `yaml:"share_load" json:"share_load"`
 	PeerTimeout       time.Duration `yaml:"peer_timeout" json:"peer_timeout"`
 	HeartbeatInterval time.Duration `yaml:"heartbeat_interval" json:"heartbeat_interval"`
+	SharedSecret      string        `yaml:"shared_secret" json:"-"`                        // HMAC key every mesh node must share
+	MaxHops           int           `yaml:"max_hops,omitempty" json:"max_hops,omitempty"` // default 2
 }
 
 // PeerConfig defines a mesh peer node
@@ -79,6 +82,9 @@ type LocalProviderConfig struct {
 	Priority int                  `yaml:"priority" json:"priority"`
 	Runtimes []string             `yaml:"runtimes" json:"runtimes"`
 	Models   []ModelConfig        `yaml:"models" json:"models"`
+	UseGPU   bool                 `yaml:"use_gpu" json:"use_gpu"`
+	PythonBridgeURL string        `yaml:"python_bridge_url,omitempty" json:"python_bridge_url,omitempty"`
+	MaxConcurrency  int           `yaml:"max_concurrency,omitempty" json:"max_concurrency,omitempty"` // 0 = unlimited
 }
 
 // CloudflareProviderConfig defines Cloudflare Workers AI settings
@@ -198,18 +204,37 @@ type BudgetConfig struct {
 // BudgetAlert defines a budget alert
 type BudgetAlert struct {
 	Threshold int    `yaml:"threshold" json:"threshold"` // percentage
-	Action    string `yaml:"action" json:"action"`
+	Action    string `yaml:"action" json:"action"`       // log, webhook, switch_to_cheapest, block
+	Webhook   string `yaml:"webhook,omitempty" json:"webhook,omitempty"`
+}
+
+// Budget alert actions
+const (
+	BudgetActionLog              = "log"
+	BudgetActionWebhook          = "webhook"
+	BudgetActionSwitchToCheapest = "switch_to_cheapest"
+	BudgetActionBlock            = "block"
+)
+
+// NormalizedAction returns the alert action in canonical form. Dashes are
+// accepted in place of underscores, and "disable_paid_providers" is an alias for block.
+func (a BudgetAlert) NormalizedAction() string {
+	action := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(a.Action)), "-", "_")
+	if action == "disable_paid_providers" {
+		return BudgetActionBlock
+	}
+	return action
 }
 
 // ObservabilityConfig defines observability settings
 type ObservabilityConfig struct {
-	Logging LoggingConfig `yaml:"logging" json:"logging"`
+	Logging AIProxyLoggingConfig `yaml:"logging" json:"logging"`
 	Metrics MetricsConfig `yaml:"metrics" json:"metrics"`
 	Tracing TracingConfig `yaml:"tracing" json:"tracing"`
 }
 
-// LoggingConfig defines logging settings
-type LoggingConfig struct {
+// AIProxyLoggingConfig defines logging settings specific to AIProxy
+type AIProxyLoggingConfig struct {
 	Level  string `yaml:"level" json:"level"`
 	Format string `yaml:"format" json:"format"`
 	Output string `yaml:"output" json:"output"`
@@ -231,13 +256,13 @@ type TracingConfig struct {
 
 // SecurityConfig defines security settings
 type SecurityConfig struct {
-	Auth         AuthConfig         `yaml:"auth" json:"auth"`
+	Auth         AIProxyAuthConfig  `yaml:"auth" json:"auth"`
 	RateLimiting RateLimitingConfig `yaml:"rate_limiting" json:"rate_limiting"`
 	CORS         CORSConfig         `yaml:"cors" json:"cors"`
 }
 
-// AuthConfig defines authentication settings
-type AuthConfig struct {
+// AIProxyAuthConfig defines authentication settings specific to AIProxy
+type AIProxyAuthConfig struct {
 	Enabled bool       `yaml:"enabled" json:"enabled"`
 	Type    string     `yaml:"type" json:"type"` // api_key, jwt, oauth2
 	APIKeys []APIKey   `yaml:"api_keys" json:"api_keys"`
@@ -248,6 +273,7 @@ type APIKey struct {
 	Key       string `yaml:"key" json:"key"`
 	Name      string `yaml:"name" json:"name"`
 	RateLimit int    `yaml:"rate_limit" json:"rate_limit"`
+	Tier      string `yaml:"tier,omitempty" json:"tier,omitempty"` // request.user_tier in routing policies
 }
 
 // RateLimitingConfig defines rate limiting settings
@@ -374,6 +400,28 @@ func (c *AIProxyConfig) Validate() error {
 		return fmt.Errorf("node.id is required")
 	}
 
+	if t := c.Routing.LoadBalancing.UnhealthyThreshold; t < 0 || t > 1 {
+		return fmt.Errorf("routing.load_balancing.unhealthy_threshold must be between 0 and 1, got %v", t)
+	}
+
+	// Validate mesh
+	if c.Node.Mesh.Enabled {
+		if c.Node.Mesh.ListenAddr == "" {
+			return fmt.Errorf("node.mesh.listen_addr is required when mesh is enabled")
+		}
+		if c.Node.Mesh.SharedSecret == "" {
+			return fmt.Errorf("node.mesh.shared_secret is required when mesh is enabled")
+		}
+		for i, peer := range c.Node.Mesh.Peers {
+			if peer.ID == "" || peer.Addr == "" {
+				return fmt.Errorf("node.mesh.peers[%d] requires id and addr", i)
+			}
+			if peer.ID == c.Node.ID {
+				return fmt.Errorf("node.mesh.peers[%d] has this node's id", i)
+			}
+		}
+	}
+
 	// Validate at least one provider is enabled
 	hasProvider := false
 	if c.Providers.Local != nil && c.Providers.Local.Enabled {
@@ -406,6 +454,39 @@ func (c *AIProxyConfig) Validate() error {
 		return fmt.Errorf("at least one provider must be enabled")
 	}
 
+	// Validate budget
+	if c.Budget.Enabled {
+		if c.Budget.DailyLimit < 0 || c.Budget.MonthlyLimit < 0 {
+			return fmt.Errorf("budget limits must not be negative")
+		}
+		for i, alert := range c.Budget.Alerts {
+			if alert.Threshold <= 0 {
+				return fmt.Errorf("budget.alerts[%d].threshold must be a positive percentage", i)
+			}
+			switch alert.NormalizedAction() {
+			case BudgetActionLog, BudgetActionSwitchToCheapest, BudgetActionBlock:
+			case BudgetActionWebhook:
+				if alert.Webhook == "" {
+					return fmt.Errorf("budget.alerts[%d].webhook is required for the webhook action", i)
+				}
+			default:
+				return fmt.Errorf("budget.alerts[%d].action %q must be one of log, webhook, switch_to_cheapest or block", i, alert.Action)
+			}
+		}
+	}
+
+	// Validate routing policy rules
+	for i, p := range c.Routing.Policies {
+		if p.Name == "" {
+			return fmt.Errorf("routing.policies[%d].name is required", i)
+		}
+		for j, rule := range p.Rules {
+			if _, err := policy.CompileRule(rule.If, rule.Then, rule.Else); err != nil {
+				return fmt.Errorf("routing.policies[%d].rules[%d] (%s): %w", i, j, p.Name, err)
+			}
+		}
+	}
+
 	return nil
 }
 
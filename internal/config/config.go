@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -95,11 +96,102 @@ type GPUConfig struct {
 	Timeout           time.Duration
 	AllowStartWithout bool   // Allow starting without external GPU providers
 	PreferredBackend  string // cuda, rocm, oneapi, or auto
+	TelemetryInterval time.Duration // How often local GPUs are sampled; 0 disables
+	AWS               AWSConfig
+	Oracle            OracleConfig
+	Fake              FakeMarketplaceConfig
+	Reservations      ReservationConfig
+	PriceHistory      PriceHistoryConfig
+}
+
+// PriceHistoryConfig configures the recorded history of marketplace prices
+type PriceHistoryConfig struct {
+	Store        string        // "memory" or "database" (the server's database)
+	Interval     time.Duration // How often every provider's offers are listed and recorded
+	RawRetention time.Duration // Every listing is kept this long, then rolled up hourly
+	Retention    time.Duration // Hourly prices are kept this long
+}
+
+// ReservationConfig configures persistence and reconciliation of compute reservations
+type ReservationConfig struct {
+	Store             string          // "memory" or "database" (the server's database)
+	ReconcileInterval time.Duration   // How often stored reservations are checked against providers
+	PendingTimeout    time.Duration   // Reservations pending longer than this are failed
+	WarnBefore        []time.Duration // Owners are warned this long before a reservation expires
+	IdleTimeout       time.Duration   // Release reservations without connections this long; 0 disables
+	WebhookURL        string          // Also receives every reservation event
+	WebhookAllowlist  []string        // Owner webhooks on these hosts skip the public https checks
+	BookingLeadTime   time.Duration   // Booked slots are provisioned this long before they start
+	BookingPolicy     string          // Default cancellation policy: flexible, moderate or strict
+	BookingHorizon    time.Duration   // Bookings must end within this long from now; 0 means no limit
+	PreemptionGrace   time.Duration   // Preempted reservations keep running this long after the warning
+	UserMaxPriority   int             // Highest priority non-admin users may request
+	UserPreempt       bool            // Whether non-admin users may preempt lower-priority reservations
+	ProbePath         string          // Appended to reservation endpoints for load balancer health probes
+	ProbeURLs         []string        // provider=URL probe templates for instances without an endpoint
+}
+
+// ProbeURLTemplates returns ProbeURLs keyed by provider
+func (c ReservationConfig) ProbeURLTemplates() map[string]string {
+	urls := make(map[string]string, len(c.ProbeURLs))
+	for _, entry := range c.ProbeURLs {
+		if provider, url, ok := strings.Cut(entry, "="); ok {
+			urls[strings.TrimSpace(provider)] = strings.TrimSpace(url)
+		}
+	}
+	return urls
+}
+
+// FakeMarketplaceConfig configures the in-process fake GPU marketplace used
+// for development and offline integration tests
+type FakeMarketplaceConfig struct {
+	Enabled         bool
+	Seed            int64         // Seeds failures and preemptions; equal seeds replay identically
+	CatalogPath     string        // JSON offer catalog; empty uses the built-in catalog
+	BootDelay       time.Duration // Time an instance spends provisioning before it runs
+	FailureRate     float64       // Probability that creating an instance fails
+	BootFailureRate float64       // Probability that an instance fails while booting
+	PreemptionRate  float64       // Probability that a running instance is preempted
+	PreemptWithin   time.Duration // Preemptions happen within this long after boot
+}
+
+// AWSConfig holds credentials for renting GPU instances on AWS
+type AWSConfig struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// OracleConfig holds credentials for renting GPU instances on Oracle Cloud
+type OracleConfig struct {
+	TenancyOCID     string
+	UserOCID        string
+	Fingerprint     string
+	PrivateKey      string
+	Region          string
+	CompartmentOCID string
 }
 
 type LoadBalancerConfig struct {
-	Strategy string
-	Enabled  bool
+	Strategy           string
+	Enabled            bool
+	HealthAware        bool          // Skip instances that fail probes or too many requests
+	UnhealthyThreshold float64       // Error rate that ejects an instance
+	ProbeInterval      time.Duration // How often instances are actively probed; 0 disables
+	ProbeTimeout       time.Duration
+	EjectionTime       time.Duration // First ejection; doubles with each repeat up to MaxEjectionTime
+	MaxEjectionTime    time.Duration
+	SlowResponse       time.Duration // Responses at least this slow count as failures; 0 disables
+	AffinityKey        string        // What consistent_hash and sticky_session key on: user, api_key, header:<name> or field:<name>
+	HashLoadFactor     float64       // consistent_hash sends no instance more than this times the average load
+	StickySessionTTL   time.Duration // Idle sticky sessions are forgotten after this
+	StateBackend       string        // "local" keeps loads per replica; "redis" shares them across replicas
+	StateTTL           time.Duration // Replicas not heard from for this long drop out of shared loads
+	StateSyncInterval  time.Duration // How often shared loads are pulled into the local cache
+	StateRedisPrefix   string
+	EWMADecay          time.Duration // How quickly p2c forgets an instance's latency
+	PoolsFile          string        // YAML file of named pools, each with its own selector, strategy, weights and health settings
 }
 
 type GuardRailsConfig struct {
@@ -204,10 +296,74 @@ func Load() (*Config, error) {
 			Timeout:           getEnvAsDuration("GPU_API_TIMEOUT", 30*time.Second),
 			AllowStartWithout: getEnvAsBool("GPU_ALLOW_START_WITHOUT_PROVIDERS", true),
 			PreferredBackend:  getEnv("GPU_PREFERRED_BACKEND", "auto"),
+			TelemetryInterval: getEnvAsDuration("GPU_TELEMETRY_INTERVAL", 15*time.Second),
+			AWS: AWSConfig{
+				Region:          getEnv("AWS_REGION", "us-east-1"),
+				AccessKeyID:     getEnv("AWS_ACCESS_KEY_ID", ""),
+				SecretAccessKey: getEnv("AWS_SECRET_ACCESS_KEY", ""),
+				SessionToken:    getEnv("AWS_SESSION_TOKEN", ""),
+			},
+			Oracle: OracleConfig{
+				TenancyOCID:     getEnv("OCI_TENANCY_OCID", ""),
+				UserOCID:        getEnv("OCI_USER_OCID", ""),
+				Fingerprint:     getEnv("OCI_FINGERPRINT", ""),
+				PrivateKey:      getEnv("OCI_PRIVATE_KEY", ""),
+				Region:          getEnv("OCI_REGION", "us-ashburn-1"),
+				CompartmentOCID: getEnv("OCI_COMPARTMENT_OCID", ""),
+			},
+			Fake: FakeMarketplaceConfig{
+				Enabled:         getEnvAsBool("GPU_FAKE_MARKETPLACE", false),
+				Seed:            getEnvAsInt64("GPU_FAKE_SEED", 1),
+				CatalogPath:     getEnv("GPU_FAKE_CATALOG", ""),
+				BootDelay:       getEnvAsDuration("GPU_FAKE_BOOT_DELAY", 5*time.Second),
+				FailureRate:     getEnvAsFloat("GPU_FAKE_FAILURE_RATE", 0),
+				BootFailureRate: getEnvAsFloat("GPU_FAKE_BOOT_FAILURE_RATE", 0),
+				PreemptionRate:  getEnvAsFloat("GPU_FAKE_PREEMPTION_RATE", 0),
+				PreemptWithin:   getEnvAsDuration("GPU_FAKE_PREEMPT_WITHIN", time.Hour),
+			},
+			Reservations: ReservationConfig{
+				Store:             getEnv("GPU_RESERVATION_STORE", "memory"),
+				ReconcileInterval: getEnvAsDuration("GPU_RESERVATION_RECONCILE_INTERVAL", 30*time.Second),
+				PendingTimeout:    getEnvAsDuration("GPU_RESERVATION_PENDING_TIMEOUT", 10*time.Minute),
+				WarnBefore:        getEnvAsDurations("GPU_RESERVATION_WARN_BEFORE", []time.Duration{15 * time.Minute, 5 * time.Minute}),
+				IdleTimeout:       getEnvAsDuration("GPU_RESERVATION_IDLE_TIMEOUT", 0),
+				WebhookURL:        getEnv("GPU_RESERVATION_WEBHOOK_URL", ""),
+				WebhookAllowlist:  getEnvAsList("GPU_RESERVATION_WEBHOOK_ALLOWLIST"),
+				BookingLeadTime:   getEnvAsDuration("GPU_BOOKING_LEAD_TIME", 10*time.Minute),
+				BookingPolicy:     getEnv("GPU_BOOKING_CANCELLATION_POLICY", "moderate"),
+				BookingHorizon:    getEnvAsDuration("GPU_BOOKING_HORIZON", 90*24*time.Hour),
+				PreemptionGrace:   getEnvAsDuration("GPU_RESERVATION_PREEMPTION_GRACE", 5*time.Minute),
+				UserMaxPriority:   getEnvAsInt("GPU_RESERVATION_USER_MAX_PRIORITY", 5),
+				UserPreempt:       getEnvAsBool("GPU_RESERVATION_USER_PREEMPT", false),
+				ProbePath:         getEnv("GPU_RESERVATION_PROBE_PATH", "/health"),
+				ProbeURLs:         getEnvAsList("GPU_RESERVATION_PROBE_URLS"),
+			},
+			PriceHistory: PriceHistoryConfig{
+				Store:        getEnv("GPU_PRICE_HISTORY_STORE", "memory"),
+				Interval:     getEnvAsDuration("GPU_PRICE_HISTORY_INTERVAL", 15*time.Minute),
+				RawRetention: getEnvAsDuration("GPU_PRICE_HISTORY_RAW_RETENTION", 48*time.Hour),
+				Retention:    getEnvAsDuration("GPU_PRICE_HISTORY_RETENTION", 90*24*time.Hour),
+			},
 		},
 		LoadBalancer: LoadBalancerConfig{
-			Strategy: getEnv("LB_STRATEGY", "round_robin"),
-			Enabled:  getEnvAsBool("LB_ENABLED", true),
+			Strategy:           getEnv("LB_STRATEGY", "round_robin"),
+			Enabled:            getEnvAsBool("LB_ENABLED", true),
+			HealthAware:        getEnvAsBool("LB_HEALTH_AWARE", false),
+			UnhealthyThreshold: getEnvAsFloat("LB_UNHEALTHY_THRESHOLD", 0.5),
+			ProbeInterval:      getEnvAsDuration("LB_HEALTH_PROBE_INTERVAL", 10*time.Second),
+			ProbeTimeout:       getEnvAsDuration("LB_HEALTH_PROBE_TIMEOUT", 2*time.Second),
+			EjectionTime:       getEnvAsDuration("LB_EJECTION_TIME", 30*time.Second),
+			MaxEjectionTime:    getEnvAsDuration("LB_MAX_EJECTION_TIME", 5*time.Minute),
+			SlowResponse:       getEnvAsDuration("LB_SLOW_RESPONSE", 0),
+			AffinityKey:        getEnv("LB_AFFINITY_KEY", "user"),
+			HashLoadFactor:     getEnvAsFloat("LB_HASH_LOAD_FACTOR", 1.25),
+			StickySessionTTL:   getEnvAsDuration("LB_STICKY_SESSION_TTL", 30*time.Minute),
+			StateBackend:       getEnv("LB_STATE_BACKEND", "local"),
+			StateTTL:           getEnvAsDuration("LB_STATE_TTL", 15*time.Second),
+			StateSyncInterval:  getEnvAsDuration("LB_STATE_SYNC_INTERVAL", time.Second),
+			StateRedisPrefix:   getEnv("LB_STATE_REDIS_PREFIX", "gpuproxy:lb"),
+			EWMADecay:          getEnvAsDuration("LB_EWMA_DECAY", 10*time.Second),
+			PoolsFile:          getEnv("LB_POOLS_FILE", ""),
 		},
 		GuardRails: GuardRailsConfig{
 			Enabled:         getEnvAsBool("GUARDRAILS_ENABLED", false),
@@ -263,13 +419,101 @@ func (c *Config) Validate() error {
 	}
 
 	// Check GPU providers - warn but allow startup if GPU_ALLOW_START_WITHOUT_PROVIDERS=true
-	if c.GPU.VastAIAPIKey == "" && c.GPU.IONetAPIKey == "" {
+	if c.GPU.VastAIAPIKey == "" && c.GPU.IONetAPIKey == "" && c.GPU.AWS.AccessKeyID == "" &&
+		c.GPU.Oracle.TenancyOCID == "" && !c.GPU.Fake.Enabled {
 		if !c.GPU.AllowStartWithout {
 			return fmt.Errorf("at least one GPU provider API key must be configured (set GPU_ALLOW_START_WITHOUT_PROVIDERS=true to override)")
 		}
 		// Will detect local GPU backends at startup
 	}
 
+	if c.GPU.Fake.Enabled {
+		for name, rate := range map[string]float64{
+			"GPU_FAKE_FAILURE_RATE":      c.GPU.Fake.FailureRate,
+			"GPU_FAKE_BOOT_FAILURE_RATE": c.GPU.Fake.BootFailureRate,
+			"GPU_FAKE_PREEMPTION_RATE":   c.GPU.Fake.PreemptionRate,
+		} {
+			if rate < 0 || rate > 1 {
+				return fmt.Errorf("%s must be between 0 and 1, got %v", name, rate)
+			}
+		}
+	}
+
+	if c.LoadBalancer.UnhealthyThreshold <= 0 || c.LoadBalancer.UnhealthyThreshold > 1 {
+		return fmt.Errorf("LB_UNHEALTHY_THRESHOLD must be greater than 0 and at most 1, got %v", c.LoadBalancer.UnhealthyThreshold)
+	}
+	if c.LoadBalancer.ProbeInterval < 0 || c.LoadBalancer.SlowResponse < 0 {
+		return fmt.Errorf("LB_HEALTH_PROBE_INTERVAL and LB_SLOW_RESPONSE must not be negative")
+	}
+	if c.LoadBalancer.EjectionTime <= 0 || c.LoadBalancer.MaxEjectionTime < c.LoadBalancer.EjectionTime {
+		return fmt.Errorf("LB_EJECTION_TIME must be positive and no more than LB_MAX_EJECTION_TIME")
+	}
+	if c.LoadBalancer.HashLoadFactor <= 1 {
+		return fmt.Errorf("LB_HASH_LOAD_FACTOR must be greater than 1, got %v", c.LoadBalancer.HashLoadFactor)
+	}
+	if c.LoadBalancer.StickySessionTTL <= 0 {
+		return fmt.Errorf("LB_STICKY_SESSION_TTL must be positive")
+	}
+	if c.LoadBalancer.StateBackend != "local" && c.LoadBalancer.StateBackend != "redis" {
+		return fmt.Errorf("LB_STATE_BACKEND must be local or redis, got %q", c.LoadBalancer.StateBackend)
+	}
+	if c.LoadBalancer.StateSyncInterval <= 0 || c.LoadBalancer.StateSyncInterval >= c.LoadBalancer.StateTTL {
+		return fmt.Errorf("LB_STATE_SYNC_INTERVAL must be positive and less than LB_STATE_TTL")
+	}
+	if c.LoadBalancer.EWMADecay <= 0 {
+		return fmt.Errorf("LB_EWMA_DECAY must be positive")
+	}
+
+	if c.GPU.TelemetryInterval < 0 {
+		return fmt.Errorf("GPU_TELEMETRY_INTERVAL must not be negative")
+	}
+
+	if c.GPU.PriceHistory.Interval <= 0 {
+		return fmt.Errorf("GPU_PRICE_HISTORY_INTERVAL must be positive")
+	}
+	if c.GPU.PriceHistory.RawRetention <= 0 {
+		return fmt.Errorf("GPU_PRICE_HISTORY_RAW_RETENTION must be positive")
+	}
+	if c.GPU.PriceHistory.Retention < c.GPU.PriceHistory.RawRetention {
+		return fmt.Errorf("GPU_PRICE_HISTORY_RETENTION must be at least GPU_PRICE_HISTORY_RAW_RETENTION")
+	}
+
+	if c.GPU.Reservations.ReconcileInterval <= 0 {
+		return fmt.Errorf("GPU_RESERVATION_RECONCILE_INTERVAL must be positive")
+	}
+	if c.GPU.Reservations.BookingLeadTime < 0 {
+		return fmt.Errorf("GPU_BOOKING_LEAD_TIME must not be negative")
+	}
+	if c.GPU.Reservations.BookingHorizon < 0 {
+		return fmt.Errorf("GPU_BOOKING_HORIZON must not be negative")
+	}
+	if c.GPU.Reservations.PreemptionGrace < 0 {
+		return fmt.Errorf("GPU_RESERVATION_PREEMPTION_GRACE must not be negative")
+	}
+	if c.GPU.Reservations.UserMaxPriority < 1 || c.GPU.Reservations.UserMaxPriority > 10 {
+		return fmt.Errorf("GPU_RESERVATION_USER_MAX_PRIORITY must be between 1 and 10")
+	}
+	for _, entry := range c.GPU.Reservations.ProbeURLs {
+		if provider, url, ok := strings.Cut(entry, "="); !ok || strings.TrimSpace(provider) == "" || strings.TrimSpace(url) == "" {
+			return fmt.Errorf("GPU_RESERVATION_PROBE_URLS entries must be provider=URL, got %q", entry)
+		}
+	}
+	switch c.GPU.Reservations.Store {
+	case "memory", "database":
+	default:
+		return fmt.Errorf("GPU_RESERVATION_STORE must be memory or database")
+	}
+	switch c.GPU.PriceHistory.Store {
+	case "memory", "database":
+	default:
+		return fmt.Errorf("GPU_PRICE_HISTORY_STORE must be memory or database")
+	}
+	switch c.GPU.Reservations.BookingPolicy {
+	case "flexible", "moderate", "strict":
+	default:
+		return fmt.Errorf("GPU_BOOKING_CANCELLATION_POLICY must be flexible, moderate or strict")
+	}
+
 	validSessionModes := map[SessionMode]bool{
 		SessionModeSQL:      true,
 		SessionModeRedis:    true,
@@ -319,6 +563,34 @@ func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	return duration
 }
 
+// getEnvAsDurations parses a comma-separated list of durations such as "15m,5m"
+func getEnvAsDurations(key string, defaultValue []time.Duration) []time.Duration {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+	var durations []time.Duration
+	for _, part := range strings.Split(valueStr, ",") {
+		duration, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil {
+			return defaultValue
+		}
+		durations = append(durations, duration)
+	}
+	return durations
+}
+
+// getEnvAsList parses a comma-separated list, skipping empty entries
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, part := range strings.Split(getEnv(key, ""), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
 func getEnvAsFloat(key string, defaultValue float64) float64 {
 	valueStr := getEnv(key, "")
 	if valueStr == "" {
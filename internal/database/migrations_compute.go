@@ -0,0 +1,36 @@
+package database
+
+// Compute Reservation Migrations
+// Reservations and bookings are stored as JSON, next to the columns that
+// compare-and-swap updates and queries need. The schema is shared by
+// PostgreSQL and SQLite.
+
+var computeReservationMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS compute_reservations (
+		id TEXT PRIMARY KEY,
+		version BIGINT NOT NULL DEFAULT 0,
+		status TEXT NOT NULL,
+		provider TEXT NOT NULL,
+		instance_id TEXT NOT NULL DEFAULT '',
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		data TEXT NOT NULL
+	)`,
+
+	`CREATE INDEX IF NOT EXISTS idx_compute_reservations_status ON compute_reservations(status)`,
+
+	// Advance bookings, queried by overlapping window
+	`CREATE TABLE IF NOT EXISTS compute_bookings (
+		id TEXT PRIMARY KEY,
+		version BIGINT NOT NULL DEFAULT 0,
+		status TEXT NOT NULL,
+		user_id TEXT NOT NULL DEFAULT '',
+		start_at BIGINT NOT NULL,
+		end_at BIGINT NOT NULL,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		data TEXT NOT NULL
+	)`,
+
+	`CREATE INDEX IF NOT EXISTS idx_compute_bookings_window ON compute_bookings(start_at, end_at)`,
+}
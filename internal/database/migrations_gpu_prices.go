@@ -0,0 +1,24 @@
+package database
+
+// GPU Price History Migrations
+// Marketplace price points, raw (resolution 0) and rolled up into hourly
+// buckets. The schema is shared by PostgreSQL and SQLite.
+
+var gpuPriceHistoryMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS gpu_price_points (
+		time BIGINT NOT NULL,
+		resolution BIGINT NOT NULL DEFAULT 0,
+		provider TEXT NOT NULL,
+		gpu_model TEXT NOT NULL,
+		gpu_class TEXT NOT NULL,
+		region TEXT NOT NULL DEFAULT '',
+		available INTEGER NOT NULL,
+		min_price DOUBLE PRECISION NOT NULL,
+		avg_price DOUBLE PRECISION NOT NULL,
+		max_price DOUBLE PRECISION NOT NULL,
+		samples INTEGER NOT NULL
+	)`,
+
+	`CREATE INDEX IF NOT EXISTS idx_gpu_price_points_class_time ON gpu_price_points(gpu_class, time)`,
+	`CREATE INDEX IF NOT EXISTS idx_gpu_price_points_resolution_time ON gpu_price_points(resolution, time)`,
+}
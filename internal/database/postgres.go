@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"github.com/aiserve/gpuproxy/internal/config"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
 type PostgresDB struct {
@@ -86,6 +88,12 @@ func (db *PostgresDB) Close() error {
 	return nil
 }
 
+// SQLDB returns a database/sql handle that shares the pool's connections,
+// for stores written against database/sql. Closing it leaves the pool open.
+func (db *PostgresDB) SQLDB() *sql.DB {
+	return stdlib.OpenDBFromPool(db.Pool)
+}
+
 func (db *PostgresDB) Migrate() error {
 	ctx := context.Background()
 
@@ -246,6 +254,12 @@ func (db *PostgresDB) Migrate() error {
 	// Append IP access control migrations
 	queries = append(queries, ipAccessControlMigrations...)
 
+	// Append compute reservation migrations
+	queries = append(queries, computeReservationMigrations...)
+
+	// Append GPU price history migrations
+	queries = append(queries, gpuPriceHistoryMigrations...)
+
 	for _, query := range queries {
 		if _, err := db.Pool.Exec(ctx, query); err != nil {
 			return fmt.Errorf("migration failed: %w", err)
@@ -32,6 +32,12 @@ func (db *SQLiteDB) Close() error {
 	return db.db.Close()
 }
 
+// SQLDB returns the underlying database/sql handle, for stores written
+// against database/sql
+func (db *SQLiteDB) SQLDB() *sql.DB {
+	return db.db
+}
+
 func (db *SQLiteDB) Exec(ctx context.Context, query string, args ...interface{}) error {
 	_, err := db.db.ExecContext(ctx, query, args...)
 	return err
@@ -166,6 +172,8 @@ func (db *SQLiteDB) Migrate() error {
 		`CREATE INDEX IF NOT EXISTS idx_billing_transactions_status ON billing_transactions(status)`,
 		`CREATE INDEX IF NOT EXISTS idx_billing_transactions_external_id ON billing_transactions(external_id)`,
 	}
+	queries = append(queries, computeReservationMigrations...)
+	queries = append(queries, gpuPriceHistoryMigrations...)
 
 	ctx := context.Background()
 	for _, query := range queries {
@@ -0,0 +1,166 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aiserve/gpuproxy/helpers/aws"
+	"github.com/aiserve/gpuproxy/helpers/common"
+	"github.com/aiserve/gpuproxy/helpers/ionet"
+	"github.com/aiserve/gpuproxy/helpers/oracle"
+	"github.com/aiserve/gpuproxy/helpers/vastai"
+	"github.com/aiserve/gpuproxy/internal/models"
+)
+
+// CSPClient is the interface shared by the cloud clients in helpers/
+type CSPClient interface {
+	List(ctx context.Context, opts common.ListOptions) ([]common.GPUInstance, error)
+	Reserve(ctx context.Context, req common.ReservationRequest) (*common.GPUInstance, error)
+	Release(ctx context.Context, instanceID string) error
+	Status(ctx context.Context, instanceID string) (*common.GPUInstance, error)
+}
+
+var (
+	_ CSPClient = (*aws.Client)(nil)
+	_ CSPClient = (*oracle.Client)(nil)
+	_ CSPClient = (*vastai.Client)(nil)
+	_ CSPClient = (*ionet.Client)(nil)
+)
+
+// cspProvider adapts a helpers/ cloud client to GPUProvider
+type cspProvider struct {
+	name   Provider
+	client CSPClient
+}
+
+// NewCSPProvider creates a GPUProvider backed by a helpers/ cloud client
+func NewCSPProvider(name Provider, client CSPClient) GPUProvider {
+	return &cspProvider{name: name, client: client}
+}
+
+func (p *cspProvider) Name() Provider {
+	return p.name
+}
+
+func (p *cspProvider) ListOffers(ctx context.Context) ([]models.GPUInstance, error) {
+	instances, err := p.client.List(ctx, common.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	offers := make([]models.GPUInstance, 0, len(instances))
+	for _, inst := range instances {
+		offers = append(offers, p.toModel(inst))
+	}
+	return offers, nil
+}
+
+// CreateInstance reserves an instance matching the offer. CSPs provision by
+// shape rather than by offer, so the offer's GPU model, count and region become
+// the reservation requirements unless config overrides them.
+func (p *cspProvider) CreateInstance(ctx context.Context, offerID string, config map[string]interface{}) (string, error) {
+	req := reservationRequest(config)
+
+	if offerID != "" && req.GPUModel == "" {
+		offers, err := p.client.List(ctx, common.ListOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to look up offer: %w", err)
+		}
+		found := false
+		for _, offer := range offers {
+			if offer.ID == offerID {
+				req.GPUModel = offer.GPUModel
+				req.GPUCount = offer.GPUCount
+				req.PreferredRegion = offer.Region
+				req.RequireRegion = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("offer %s not found", offerID)
+		}
+	}
+
+	instance, err := p.client.Reserve(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return instance.ID, nil
+}
+
+func (p *cspProvider) DestroyInstance(ctx context.Context, instanceID string) error {
+	return p.client.Release(ctx, instanceID)
+}
+
+func (p *cspProvider) InstanceStatus(ctx context.Context, instanceID string) (string, error) {
+	instance, err := p.client.Status(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	return instance.Status, nil
+}
+
+// toModel converts a helpers/ instance to the service's instance model
+func (p *cspProvider) toModel(inst common.GPUInstance) models.GPUInstance {
+	specs := make(map[string]interface{}, len(inst.ProviderData)+2)
+	for k, v := range inst.ProviderData {
+		specs[k] = v
+	}
+	specs["status"] = inst.Status
+	if inst.Datacenter != "" {
+		specs["datacenter"] = inst.Datacenter
+	}
+
+	return models.GPUInstance{
+		ID:             inst.ID,
+		Provider:       string(p.name),
+		GPUName:        inst.GPUModel,
+		GPUCount:       inst.GPUCount,
+		VRAM:           inst.VRAM,
+		CPUCores:       inst.CPUCores,
+		RAM:            inst.RAM,
+		Storage:        inst.Disk,
+		PricePerHour:   inst.CostPerHour,
+		Location:       inst.Region,
+		Available:      inst.Status == "" || inst.Status == "available" || inst.Status == "running",
+		Specifications: specs,
+	}
+}
+
+// reservationRequest maps the service's free-form instance config onto a
+// helpers/ reservation request
+func reservationRequest(config map[string]interface{}) common.ReservationRequest {
+	req := common.ReservationRequest{GPUCount: 1}
+
+	if v, ok := config["image"].(string); ok {
+		req.Image = v
+	}
+	if v, ok := config["gpu_model"].(string); ok {
+		req.GPUModel = v
+	}
+	if v, ok := config["gpu_count"].(float64); ok && v > 0 {
+		req.GPUCount = int(v)
+	}
+	if v, ok := config["region"].(string); ok {
+		req.PreferredRegion = v
+	}
+	if v, ok := config["max_cost_per_hour"].(float64); ok {
+		req.MaxCostPerHour = v
+	}
+	if v, ok := config["spot"].(bool); ok {
+		req.SpotInstance = v
+	}
+	if v, ok := config["duration_hours"].(float64); ok && v > 0 {
+		req.Duration = time.Duration(v * float64(time.Hour))
+	}
+	if env, ok := config["env"].(map[string]interface{}); ok {
+		req.Env = make(map[string]string, len(env))
+		for k, v := range env {
+			req.Env[k] = fmt.Sprint(v)
+		}
+	}
+
+	return req
+}
@@ -0,0 +1,450 @@
+package gpu
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Device is one local GPU
+type Device struct {
+	Backend BackendType `json:"backend"`
+	Index   int         `json:"index"` // As numbered by the vendor tool and *_VISIBLE_DEVICES
+	UUID    string      `json:"uuid,omitempty"`
+	Name    string      `json:"name"`
+	VRAMMB  int         `json:"vram_mb"` // 0 if unknown; such devices cannot be shared
+}
+
+// nvidiaSMIArgs queries one CSV line per device: index, uuid, name, memory.total (MiB)
+var nvidiaSMIArgs = []string{"--query-gpu=index,uuid,name,memory.total", "--format=csv,noheader,nounits"}
+
+// rocmSMIArgs reports product name, unique ID and VRAM per card as JSON
+var rocmSMIArgs = []string{"--showproductname", "--showuniqueid", "--showmeminfo", "vram", "--json"}
+
+// runTool runs a vendor tool and returns its output; tests replace it
+var runTool = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// DetectDevices enumerates local NVIDIA and AMD GPUs with nvidia-smi and
+// rocm-smi. Missing tools mean no devices of that vendor.
+func DetectDevices() []Device {
+	var devices []Device
+
+	if out, err := runTool(context.Background(), "nvidia-smi", nvidiaSMIArgs...); err == nil {
+		found, err := ParseNvidiaSMI(out)
+		if err != nil {
+			log.Printf("Failed to parse nvidia-smi output: %v", err)
+		}
+		devices = append(devices, found...)
+	}
+
+	if out, err := runTool(context.Background(), "rocm-smi", rocmSMIArgs...); err == nil {
+		found, err := ParseROCmSMI(out)
+		if err != nil {
+			log.Printf("Failed to parse rocm-smi output: %v", err)
+		}
+		devices = append(devices, found...)
+	}
+
+	return devices
+}
+
+// ParseNvidiaSMI parses the output of
+// nvidia-smi --query-gpu=index,uuid,name,memory.total --format=csv,noheader,nounits
+func ParseNvidiaSMI(out []byte) ([]Device, error) {
+	r := csv.NewReader(strings.NewReader(string(out)))
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = 4
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid nvidia-smi output: %w", err)
+	}
+
+	devices := make([]Device, 0, len(records))
+	for _, rec := range records {
+		index, err := strconv.Atoi(strings.TrimSpace(rec[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid nvidia-smi device index %q", rec[0])
+		}
+		devices = append(devices, Device{
+			Backend: BackendCUDA,
+			Index:   index,
+			UUID:    strings.TrimSpace(rec[1]),
+			Name:    strings.TrimSpace(rec[2]),
+			VRAMMB:  atoiOrZero(rec[3]), // "[N/A]" on some virtual GPUs
+		})
+	}
+	return devices, nil
+}
+
+// ParseROCmSMI parses the output of
+// rocm-smi --showproductname --showuniqueid --showmeminfo vram --json
+func ParseROCmSMI(out []byte) ([]Device, error) {
+	var cards map[string]map[string]string
+	if err := json.Unmarshal(out, &cards); err != nil {
+		return nil, fmt.Errorf("invalid rocm-smi output: %w", err)
+	}
+
+	var devices []Device
+	for key, fields := range cards {
+		if !strings.HasPrefix(key, "card") {
+			continue // e.g. "system"
+		}
+		index, err := strconv.Atoi(strings.TrimPrefix(key, "card"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rocm-smi card %q", key)
+		}
+
+		name := fields["Card series"]
+		if name == "" {
+			name = fields["Card model"]
+		}
+		vramBytes, _ := strconv.ParseInt(fields["VRAM Total Memory (B)"], 10, 64)
+		devices = append(devices, Device{
+			Backend: BackendROCm,
+			Index:   index,
+			UUID:    fields["Unique ID"],
+			Name:    name,
+			VRAMMB:  int(vramBytes / (1024 * 1024)),
+		})
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Index < devices[j].Index })
+	return devices, nil
+}
+
+func atoiOrZero(s string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// DeviceRequest asks for local GPUs
+type DeviceRequest struct {
+	Owner   string      // Reservation or model the devices are for
+	Count   int         // Devices wanted; 0 means 1
+	VRAMMB  int         // Slice of each device's VRAM to share it; 0 takes whole devices
+	Backend BackendType // "" for any backend
+}
+
+// DeviceAllocation is a set of devices, all on one backend, handed to an owner
+type DeviceAllocation struct {
+	ID      string      `json:"id"` // Encodes the allocation; see ParseDeviceAllocationID
+	Owner   string      `json:"owner,omitempty"`
+	Backend BackendType `json:"backend"`
+	Devices []int       `json:"devices"`
+	UUIDs   []string    `json:"uuids,omitempty"`
+	VRAMMB  int         `json:"vram_mb,omitempty"` // Per device; 0 for exclusive use
+}
+
+// Exclusive reports whether the allocation holds its devices whole
+func (a *DeviceAllocation) Exclusive() bool {
+	return a.VRAMMB == 0
+}
+
+// VisibleDevices returns the device list in the form the backend's
+// *_VISIBLE_DEVICES variable takes
+func (a *DeviceAllocation) VisibleDevices() string {
+	ids := make([]string, len(a.Devices))
+	for i, d := range a.Devices {
+		ids[i] = strconv.Itoa(d)
+	}
+	return strings.Join(ids, ",")
+}
+
+// Env returns the environment that restricts a process to the allocation's
+// devices
+func (a *DeviceAllocation) Env() map[string]string {
+	switch a.Backend {
+	case BackendCUDA:
+		return map[string]string{"CUDA_VISIBLE_DEVICES": a.VisibleDevices()}
+	case BackendROCm:
+		return map[string]string{"HIP_VISIBLE_DEVICES": a.VisibleDevices()}
+	case BackendOneAPI:
+		return map[string]string{"ONEAPI_DEVICE_SELECTOR": "level_zero:" + a.VisibleDevices()}
+	}
+	return map[string]string{}
+}
+
+// ParseDeviceAllocationID recovers the backend, devices and VRAM slice from an
+// allocation ID such as "cuda-0,1-3f2a9c1e" or "cuda-2@20480-3f2a9c1e"
+func ParseDeviceAllocationID(id string) (*DeviceAllocation, error) {
+	parts := strings.Split(id, "-")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("invalid device allocation ID %q", id)
+	}
+
+	alloc := &DeviceAllocation{ID: id, Backend: BackendType(parts[0])}
+	devices := parts[1]
+	if i := strings.IndexByte(devices, '@'); i >= 0 {
+		vram, err := strconv.Atoi(devices[i+1:])
+		if err != nil || vram <= 0 {
+			return nil, fmt.Errorf("invalid device allocation ID %q", id)
+		}
+		alloc.VRAMMB = vram
+		devices = devices[:i]
+	}
+	for _, s := range strings.Split(devices, ",") {
+		d, err := strconv.Atoi(s)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid device allocation ID %q", id)
+		}
+		alloc.Devices = append(alloc.Devices, d)
+	}
+	return alloc, nil
+}
+
+// DeviceUsage is a device with what is allocated on it
+type DeviceUsage struct {
+	Device
+	UsedVRAMMB  int      `json:"used_vram_mb"` // The whole device when held exclusively
+	Exclusive   bool     `json:"exclusive"`
+	Allocations []string `json:"allocations,omitempty"`
+}
+
+// DeviceAllocator hands out local GPUs, either whole or as VRAM slices that
+// several owners share. A device held whole is not shared, and a device with
+// slices handed out cannot be held whole.
+type DeviceAllocator struct {
+	mu          sync.Mutex
+	devices     []Device
+	allocations map[string]*DeviceAllocation
+}
+
+// NewDeviceAllocator creates an allocator over the given devices
+func NewDeviceAllocator(devices []Device) *DeviceAllocator {
+	return &DeviceAllocator{
+		devices:     append([]Device(nil), devices...),
+		allocations: make(map[string]*DeviceAllocation),
+	}
+}
+
+// Allocate hands out devices for req from a single backend. Whole devices are
+// taken lowest index first; slices go to the device with the least VRAM left
+// that fits, to keep large slices available.
+func (a *DeviceAllocator) Allocate(req DeviceRequest) (*DeviceAllocation, error) {
+	count := req.Count
+	if count < 1 {
+		count = 1
+	}
+	if req.VRAMMB < 0 {
+		return nil, fmt.Errorf("VRAM slice must not be negative")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	usage := a.usageLocked()
+	total, free := 0, 0
+	for _, backend := range a.backendsLocked() {
+		if req.Backend != "" && backend != req.Backend {
+			continue
+		}
+
+		var fits []DeviceUsage
+		for _, u := range usage {
+			if u.Backend != backend {
+				continue
+			}
+			total++
+			if req.VRAMMB == 0 && len(u.Allocations) == 0 {
+				fits = append(fits, u)
+			} else if req.VRAMMB > 0 && !u.Exclusive && u.VRAMMB-u.UsedVRAMMB >= req.VRAMMB {
+				fits = append(fits, u)
+			}
+		}
+		free += len(fits)
+		if len(fits) < count {
+			continue
+		}
+
+		if req.VRAMMB > 0 {
+			sort.SliceStable(fits, func(i, j int) bool {
+				return fits[i].VRAMMB-fits[i].UsedVRAMMB < fits[j].VRAMMB-fits[j].UsedVRAMMB
+			})
+		}
+		fits = fits[:count]
+		sort.Slice(fits, func(i, j int) bool { return fits[i].Index < fits[j].Index })
+
+		alloc := &DeviceAllocation{Owner: req.Owner, Backend: backend, VRAMMB: req.VRAMMB}
+		for _, u := range fits {
+			alloc.Devices = append(alloc.Devices, u.Index)
+			alloc.UUIDs = append(alloc.UUIDs, u.UUID)
+		}
+		alloc.ID = allocationID(alloc)
+		a.allocations[alloc.ID] = alloc
+		return alloc.clone(), nil
+	}
+
+	if total == 0 {
+		return nil, fmt.Errorf("no local GPU devices detected")
+	}
+	if req.VRAMMB > 0 {
+		return nil, fmt.Errorf("not enough local GPUs with %d MB of VRAM free: requested=%d, free=%d", req.VRAMMB, count, free)
+	}
+	return nil, fmt.Errorf("not enough free local GPUs: requested=%d, free=%d", count, free)
+}
+
+// Restore records an allocation handed out before a restart, given its ID.
+// Devices that have since disappeared are still recorded, so nothing else is
+// given them if they come back.
+func (a *DeviceAllocator) Restore(id, owner string) (*DeviceAllocation, error) {
+	alloc, err := ParseDeviceAllocationID(id)
+	if err != nil {
+		return nil, err
+	}
+	alloc.Owner = owner
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, d := range alloc.Devices {
+		uuid := ""
+		if dev, ok := a.deviceLocked(alloc.Backend, d); ok {
+			uuid = dev.UUID
+		}
+		alloc.UUIDs = append(alloc.UUIDs, uuid)
+	}
+	a.allocations[id] = alloc
+	return alloc.clone(), nil
+}
+
+// Release returns an allocation's devices. Releasing an unknown allocation
+// is not an error.
+func (a *DeviceAllocator) Release(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.allocations, id)
+	return nil
+}
+
+// Allocation returns the allocation with the given ID
+func (a *DeviceAllocator) Allocation(id string) (*DeviceAllocation, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	alloc, ok := a.allocations[id]
+	if !ok {
+		return nil, false
+	}
+	return alloc.clone(), true
+}
+
+// Usage returns every device with what is allocated on it
+func (a *DeviceAllocator) Usage() []DeviceUsage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.usageLocked()
+}
+
+// AllocateModelDevices hands a served model one device, or a slice of one
+// when vramGB is set, and returns the allocation ID and its environment. On a
+// host without GPUs nothing is allocated and the model runs as before.
+func (a *DeviceAllocator) AllocateModelDevices(modelID string, vramGB int) (string, map[string]string, error) {
+	a.mu.Lock()
+	none := len(a.devices) == 0
+	a.mu.Unlock()
+	if none {
+		return "", nil, nil
+	}
+
+	alloc, err := a.Allocate(DeviceRequest{Owner: modelID, Count: 1, VRAMMB: vramGB * 1024})
+	if err != nil {
+		return "", nil, err
+	}
+	return alloc.ID, alloc.Env(), nil
+}
+
+// usageLocked computes per-device usage. Callers hold a.mu.
+func (a *DeviceAllocator) usageLocked() []DeviceUsage {
+	usage := make([]DeviceUsage, len(a.devices))
+	index := make(map[string]int, len(a.devices))
+	for i, d := range a.devices {
+		usage[i] = DeviceUsage{Device: d}
+		index[deviceKey(d.Backend, d.Index)] = i
+	}
+
+	ids := make([]string, 0, len(a.allocations))
+	for id := range a.allocations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		alloc := a.allocations[id]
+		for _, d := range alloc.Devices {
+			i, ok := index[deviceKey(alloc.Backend, d)]
+			if !ok {
+				continue
+			}
+			u := &usage[i]
+			u.Allocations = append(u.Allocations, id)
+			if alloc.Exclusive() {
+				u.Exclusive = true
+				u.UsedVRAMMB = u.VRAMMB
+			} else {
+				u.UsedVRAMMB += alloc.VRAMMB
+			}
+		}
+	}
+	return usage
+}
+
+// backendsLocked returns the backends with devices in the order they were
+// found. Callers hold a.mu.
+func (a *DeviceAllocator) backendsLocked() []BackendType {
+	var backends []BackendType
+	seen := make(map[BackendType]bool)
+	for _, d := range a.devices {
+		if !seen[d.Backend] {
+			seen[d.Backend] = true
+			backends = append(backends, d.Backend)
+		}
+	}
+	return backends
+}
+
+// deviceLocked looks a device up. Callers hold a.mu.
+func (a *DeviceAllocator) deviceLocked(backend BackendType, index int) (Device, bool) {
+	for _, d := range a.devices {
+		if d.Backend == backend && d.Index == index {
+			return d, true
+		}
+	}
+	return Device{}, false
+}
+
+func (alloc *DeviceAllocation) clone() *DeviceAllocation {
+	c := *alloc
+	c.Devices = append([]int(nil), alloc.Devices...)
+	c.UUIDs = append([]string(nil), alloc.UUIDs...)
+	return &c
+}
+
+func deviceKey(backend BackendType, index int) string {
+	return string(backend) + ":" + strconv.Itoa(index)
+}
+
+// allocationID encodes an allocation as "<backend>-<devices>[@<vram>]-<nonce>"
+func allocationID(alloc *DeviceAllocation) string {
+	devices := alloc.VisibleDevices()
+	if alloc.VRAMMB > 0 {
+		devices += "@" + strconv.Itoa(alloc.VRAMMB)
+	}
+	return fmt.Sprintf("%s-%s-%s", alloc.Backend, devices, strings.ReplaceAll(uuid.NewString(), "-", "")[:8])
+}
@@ -0,0 +1,225 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return data
+}
+
+func TestParseNvidiaSMI(t *testing.T) {
+	devices, err := ParseNvidiaSMI(readFixture(t, "nvidia-smi.csv"))
+	require.NoError(t, err)
+	require.Len(t, devices, 4)
+
+	assert.Equal(t, Device{
+		Backend: BackendCUDA,
+		Index:   0,
+		UUID:    "GPU-5f2b1a6e-7c3d-4e8f-9a0b-1c2d3e4f5a6b",
+		Name:    "NVIDIA A100-SXM4-80GB",
+		VRAMMB:  81920,
+	}, devices[0])
+	assert.Equal(t, 40960, devices[2].VRAMMB)
+	assert.Equal(t, 3, devices[3].Index)
+	assert.Zero(t, devices[3].VRAMMB, "[N/A] memory is unknown")
+
+	_, err = ParseNvidiaSMI([]byte("0, GPU-1, A100\n"))
+	assert.Error(t, err)
+	_, err = ParseNvidiaSMI([]byte("x, GPU-1, A100, 81920\n"))
+	assert.ErrorContains(t, err, "invalid nvidia-smi device index")
+
+	devices, err = ParseNvidiaSMI(nil)
+	require.NoError(t, err)
+	assert.Empty(t, devices)
+}
+
+func TestParseROCmSMI(t *testing.T) {
+	devices, err := ParseROCmSMI(readFixture(t, "rocm-smi.json"))
+	require.NoError(t, err)
+	require.Len(t, devices, 3, "the system entry is not a device")
+
+	assert.Equal(t, Device{
+		Backend: BackendROCm,
+		Index:   0,
+		UUID:    "0x5d8f3c2a1b4e6f70",
+		Name:    "AMD Instinct MI250X",
+		VRAMMB:  65520,
+	}, devices[0])
+	assert.Equal(t, 1, devices[1].Index)
+	assert.Equal(t, "0x73bf", devices[2].Name, "falls back to the card model")
+	assert.Equal(t, 16368, devices[2].VRAMMB)
+
+	_, err = ParseROCmSMI([]byte("WARNING: No AMD GPUs specified"))
+	assert.ErrorContains(t, err, "invalid rocm-smi output")
+}
+
+func TestDetectDevices(t *testing.T) {
+	orig := runTool
+	t.Cleanup(func() { runTool = orig })
+
+	runTool = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		switch name {
+		case "nvidia-smi":
+			return readFixture(t, "nvidia-smi.csv"), nil
+		case "rocm-smi":
+			return readFixture(t, "rocm-smi.json"), nil
+		}
+		return nil, fmt.Errorf("%s not found", name)
+	}
+	devices := DetectDevices()
+	require.Len(t, devices, 7)
+	assert.Equal(t, BackendCUDA, devices[0].Backend)
+	assert.Equal(t, BackendROCm, devices[6].Backend)
+
+	runTool = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return nil, fmt.Errorf("%s not found", name)
+	}
+	assert.Empty(t, DetectDevices())
+}
+
+func testDevices() []Device {
+	return []Device{
+		{Backend: BackendCUDA, Index: 0, UUID: "GPU-0", VRAMMB: 81920},
+		{Backend: BackendCUDA, Index: 1, UUID: "GPU-1", VRAMMB: 81920},
+		{Backend: BackendCUDA, Index: 2, UUID: "GPU-2", VRAMMB: 40960},
+		{Backend: BackendROCm, Index: 0, UUID: "0x5d8f", VRAMMB: 65520},
+	}
+}
+
+func TestDeviceAllocatorExclusive(t *testing.T) {
+	a := NewDeviceAllocator(testDevices())
+
+	first, err := a.Allocate(DeviceRequest{Owner: "r1", Count: 2})
+	require.NoError(t, err)
+	assert.Equal(t, BackendCUDA, first.Backend)
+	assert.Equal(t, []int{0, 1}, first.Devices)
+	assert.Equal(t, []string{"GPU-0", "GPU-1"}, first.UUIDs)
+	assert.True(t, first.Exclusive())
+	assert.Equal(t, map[string]string{"CUDA_VISIBLE_DEVICES": "0,1"}, first.Env())
+
+	_, err = a.Allocate(DeviceRequest{Owner: "r2", Count: 2})
+	assert.EqualError(t, err, "not enough free local GPUs: requested=2, free=2", "devices never span backends")
+
+	second, err := a.Allocate(DeviceRequest{Owner: "r2", Backend: BackendROCm})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, second.Devices)
+	assert.Equal(t, map[string]string{"HIP_VISIBLE_DEVICES": "0"}, second.Env())
+
+	require.NoError(t, a.Release(first.ID))
+	require.NoError(t, a.Release(first.ID), "releasing twice is harmless")
+	third, err := a.Allocate(DeviceRequest{Owner: "r3", Count: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2}, third.Devices)
+
+	_, err = NewDeviceAllocator(nil).Allocate(DeviceRequest{})
+	assert.EqualError(t, err, "no local GPU devices detected")
+}
+
+func TestDeviceAllocatorFractional(t *testing.T) {
+	a := NewDeviceAllocator(testDevices())
+
+	// Best fit puts the slice on the smallest device it fits
+	small, err := a.Allocate(DeviceRequest{Owner: "m1", VRAMMB: 20480})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, small.Devices)
+	assert.False(t, small.Exclusive())
+
+	shared, err := a.Allocate(DeviceRequest{Owner: "m2", VRAMMB: 20480})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, shared.Devices, "slices share a device")
+
+	large, err := a.Allocate(DeviceRequest{Owner: "m3", VRAMMB: 40960})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, large.Devices)
+
+	// Devices with slices on them cannot be taken whole
+	whole, err := a.Allocate(DeviceRequest{Owner: "r1", Count: 1, Backend: BackendCUDA})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, whole.Devices)
+	_, err = a.Allocate(DeviceRequest{Owner: "r2", Count: 1, Backend: BackendCUDA})
+	assert.ErrorContains(t, err, "free=0")
+
+	// Nor can slices go on a device held whole
+	_, err = a.Allocate(DeviceRequest{Owner: "m4", VRAMMB: 50000, Backend: BackendCUDA})
+	assert.EqualError(t, err, "not enough local GPUs with 50000 MB of VRAM free: requested=1, free=0")
+
+	usage := a.Usage()
+	require.Len(t, usage, 4)
+	assert.Equal(t, 40960, usage[0].UsedVRAMMB)
+	assert.True(t, usage[1].Exclusive)
+	assert.Equal(t, 81920, usage[1].UsedVRAMMB)
+	assert.Equal(t, 40960, usage[2].UsedVRAMMB)
+	assert.Len(t, usage[2].Allocations, 2)
+	assert.Zero(t, usage[3].UsedVRAMMB)
+
+	_, err = a.Allocate(DeviceRequest{VRAMMB: -1})
+	assert.Error(t, err)
+}
+
+func TestDeviceAllocatorRestore(t *testing.T) {
+	a := NewDeviceAllocator(testDevices())
+	whole, err := a.Allocate(DeviceRequest{Owner: "r1", Count: 2})
+	require.NoError(t, err)
+	slice, err := a.Allocate(DeviceRequest{Owner: "m1", VRAMMB: 40960})
+	require.NoError(t, err)
+
+	parsed, err := ParseDeviceAllocationID(slice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, BackendCUDA, parsed.Backend)
+	assert.Equal(t, []int{2}, parsed.Devices)
+	assert.Equal(t, 40960, parsed.VRAMMB)
+
+	// A restarted allocator is told what was handed out
+	restarted := NewDeviceAllocator(testDevices())
+	restored, err := restarted.Restore(whole.ID, "r1")
+	require.NoError(t, err)
+	assert.Equal(t, whole, restored)
+	_, err = restarted.Restore(slice.ID, "m1")
+	require.NoError(t, err)
+
+	_, err = restarted.Allocate(DeviceRequest{Count: 1, Backend: BackendCUDA})
+	assert.ErrorContains(t, err, "free=0")
+	got, ok := restarted.Allocation(slice.ID)
+	require.True(t, ok)
+	assert.Equal(t, "m1", got.Owner)
+
+	for _, id := range []string{"", "cuda", "cuda-x-1234", "cuda-0@0-1234", "cuda-0-"} {
+		_, err := ParseDeviceAllocationID(id)
+		assert.Error(t, err, id)
+	}
+}
+
+func TestAllocateModelDevices(t *testing.T) {
+	a := NewDeviceAllocator([]Device{{Backend: BackendOneAPI, Index: 0, VRAMMB: 16384}})
+
+	id, env, err := a.AllocateModelDevices("model-1", 8)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ONEAPI_DEVICE_SELECTOR": "level_zero:0"}, env)
+	alloc, ok := a.Allocation(id)
+	require.True(t, ok)
+	assert.Equal(t, 8192, alloc.VRAMMB)
+
+	_, _, err = a.AllocateModelDevices("model-2", 0)
+	assert.ErrorContains(t, err, "free=0", "a shared device cannot be taken whole")
+}
+
+func TestAllocateModelDevicesWithoutGPUs(t *testing.T) {
+	a := NewDeviceAllocator(nil)
+
+	id, env, err := a.AllocateModelDevices("model-1", 8)
+	require.NoError(t, err, "models still load on hosts without GPUs")
+	assert.Empty(t, id)
+	assert.Nil(t, env)
+	assert.Empty(t, a.Usage())
+}
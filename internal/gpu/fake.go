@@ -0,0 +1,328 @@
+package gpu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/models"
+)
+
+// ProviderFake is the in-process fake marketplace
+const ProviderFake Provider = "fake"
+
+// Fake instance statuses
+const (
+	FakeStatusProvisioning = "provisioning"
+	FakeStatusRunning      = "running"
+	FakeStatusFailed       = "failed"
+	FakeStatusPreempted    = "preempted"
+	FakeStatusTerminated   = "terminated"
+)
+
+// FakeOffer is an entry in the fake marketplace catalog. Each offer is one
+// machine: it is unlisted while rented and listed again once destroyed.
+type FakeOffer struct {
+	ID           string            `json:"id"`
+	GPUName      string            `json:"gpu_name"`
+	GPUCount     int               `json:"gpu_count"`
+	VRAM         int               `json:"vram_gb"`
+	CPUCores     int               `json:"cpu_cores"`
+	RAM          int               `json:"ram_gb"`
+	Storage      int               `json:"storage_gb"`
+	PricePerHour float64           `json:"price_per_hour"`
+	Location     string            `json:"location"`
+	Datacenter   string            `json:"datacenter,omitempty"`
+	Reliability  float64           `json:"reliability"`
+	Labels       map[string]string `json:"labels,omitempty"`
+
+	// Where the load balancer probes the offer's health, if anywhere
+	HealthURL      string `json:"health_url,omitempty"`
+	GRPCHealthAddr string `json:"grpc_health_addr,omitempty"`
+}
+
+// DefaultFakeCatalog is the catalog used when no catalog file is configured
+var DefaultFakeCatalog = []FakeOffer{
+	{ID: "h100-8x-use1", GPUName: "H100", GPUCount: 8, VRAM: 80, CPUCores: 192, RAM: 2048, Storage: 8000, PricePerHour: 19.92, Location: "us-east-1", Reliability: 0.995},
+	{ID: "h100-1x-usw2", GPUName: "H100", GPUCount: 1, VRAM: 80, CPUCores: 24, RAM: 256, Storage: 1000, PricePerHour: 2.49, Location: "us-west-2", Reliability: 0.98},
+	{ID: "a100-4x-euw1", GPUName: "A100", GPUCount: 4, VRAM: 80, CPUCores: 64, RAM: 512, Storage: 2000, PricePerHour: 6.40, Location: "eu-west-1", Reliability: 0.99},
+	{ID: "a100-1x-use1", GPUName: "A100", GPUCount: 1, VRAM: 40, CPUCores: 16, RAM: 128, Storage: 500, PricePerHour: 1.10, Location: "us-east-1", Reliability: 0.97},
+	{ID: "l40s-2x-usc1", GPUName: "L40S", GPUCount: 2, VRAM: 48, CPUCores: 32, RAM: 256, Storage: 1000, PricePerHour: 1.60, Location: "us-central-1", Reliability: 0.96},
+	{ID: "rtx4090-1x-euc1", GPUName: "RTX 4090", GPUCount: 1, VRAM: 24, CPUCores: 16, RAM: 64, Storage: 500, PricePerHour: 0.39, Location: "eu-central-1", Reliability: 0.92},
+	{ID: "a10g-1x-use1", GPUName: "A10G", GPUCount: 1, VRAM: 24, CPUCores: 8, RAM: 32, Storage: 250, PricePerHour: 0.75, Location: "us-east-1", Reliability: 0.99},
+	{ID: "t4-1x-usw2", GPUName: "T4", GPUCount: 1, VRAM: 16, CPUCores: 4, RAM: 16, Storage: 125, PricePerHour: 0.18, Location: "us-west-2", Reliability: 0.985},
+	{ID: "mi300x-8x-use1", GPUName: "MI300X", GPUCount: 8, VRAM: 192, CPUCores: 192, RAM: 2048, Storage: 8000, PricePerHour: 15.60, Location: "us-east-1", Reliability: 0.97},
+}
+
+// fakeInstance is a rented instance and its predetermined lifecycle
+type fakeInstance struct {
+	seq       int
+	id        string
+	offerID   string
+	createdAt time.Time
+	readyAt   time.Time
+	bootFails bool
+	preemptAt time.Time // zero when the instance is never preempted
+	preempted bool
+	destroyed bool
+}
+
+// FakeMarketplace is a deterministic in-process GPU marketplace. Boot delays,
+// failures and preemptions are drawn from a seeded source when an instance is
+// created, so the same seed and call sequence always replays identically.
+type FakeMarketplace struct {
+	mu        sync.Mutex
+	cfg       config.FakeMarketplaceConfig
+	rng       *rand.Rand
+	now       func() time.Time
+	offers    []FakeOffer
+	rented    map[string]string // offer ID -> instance ID
+	instances map[string]*fakeInstance
+	nextID    int
+}
+
+// NewFakeMarketplace creates a fake marketplace from config, loading the
+// offer catalog from cfg.CatalogPath when set
+func NewFakeMarketplace(cfg config.FakeMarketplaceConfig) (*FakeMarketplace, error) {
+	catalog := DefaultFakeCatalog
+	if cfg.CatalogPath != "" {
+		data, err := os.ReadFile(cfg.CatalogPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fake catalog: %w", err)
+		}
+		catalog = nil
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			return nil, fmt.Errorf("failed to parse fake catalog: %w", err)
+		}
+	}
+
+	return NewFakeMarketplaceWithCatalog(cfg, catalog)
+}
+
+// NewFakeMarketplaceWithCatalog creates a fake marketplace serving the given offers
+func NewFakeMarketplaceWithCatalog(cfg config.FakeMarketplaceConfig, catalog []FakeOffer) (*FakeMarketplace, error) {
+	seen := make(map[string]bool, len(catalog))
+	for _, offer := range catalog {
+		if offer.ID == "" {
+			return nil, fmt.Errorf("fake catalog offer without an id")
+		}
+		if seen[offer.ID] {
+			return nil, fmt.Errorf("duplicate fake catalog offer %s", offer.ID)
+		}
+		seen[offer.ID] = true
+	}
+
+	return &FakeMarketplace{
+		cfg:       cfg,
+		rng:       rand.New(rand.NewSource(cfg.Seed)),
+		now:       time.Now,
+		offers:    append([]FakeOffer(nil), catalog...),
+		rented:    make(map[string]string),
+		instances: make(map[string]*fakeInstance),
+	}, nil
+}
+
+// SetClock replaces the marketplace clock, letting tests step through boot
+// delays and preemptions without sleeping
+func (m *FakeMarketplace) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+func (m *FakeMarketplace) Name() Provider {
+	return ProviderFake
+}
+
+// ListOffers returns the catalog offers that are not currently rented
+func (m *FakeMarketplace) ListOffers(ctx context.Context) ([]models.GPUInstance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	offers := make([]models.GPUInstance, 0, len(m.offers))
+	for _, offer := range m.offers {
+		if _, rented := m.rented[offer.ID]; rented {
+			continue
+		}
+		offers = append(offers, models.GPUInstance{
+			ID:           "fake-" + offer.ID,
+			Provider:     string(ProviderFake),
+			GPUName:      offer.GPUName,
+			GPUCount:     offer.GPUCount,
+			VRAM:         offer.VRAM,
+			CPUCores:     offer.CPUCores,
+			RAM:          offer.RAM,
+			Storage:      offer.Storage,
+			PricePerHour: offer.PricePerHour,
+			Location:     offer.Location,
+			Available:    true,
+			Specifications: map[string]interface{}{
+				"reliability": offer.Reliability,
+			},
+			Labels: offer.Labels,
+		})
+		specs := offers[len(offers)-1].Specifications
+		if offer.Datacenter != "" {
+			specs["datacenter"] = offer.Datacenter
+		}
+		if offer.HealthURL != "" {
+			specs["health_url"] = offer.HealthURL
+		}
+		if offer.GRPCHealthAddr != "" {
+			specs["grpc_health_addr"] = offer.GRPCHealthAddr
+		}
+	}
+	return offers, nil
+}
+
+// CreateInstance rents an offer. It fails with probability FailureRate; a
+// created instance provisions for BootDelay and then runs, fails or is later
+// preempted according to the configured rates.
+func (m *FakeMarketplace) CreateInstance(ctx context.Context, offerID string, config map[string]interface{}) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	offerID = strings.TrimPrefix(offerID, "fake-")
+	if !m.hasOffer(offerID) {
+		return "", fmt.Errorf("offer %s not found", offerID)
+	}
+	if instanceID, rented := m.rented[offerID]; rented {
+		return "", fmt.Errorf("offer %s is already rented by %s", offerID, instanceID)
+	}
+
+	if m.roll(m.cfg.FailureRate) {
+		return "", fmt.Errorf("fake marketplace: failed to create instance from offer %s", offerID)
+	}
+
+	m.nextID++
+	now := m.now()
+	inst := &fakeInstance{
+		seq:       m.nextID,
+		id:        fmt.Sprintf("fake-i-%d", m.nextID),
+		offerID:   offerID,
+		createdAt: now,
+		readyAt:   now.Add(m.cfg.BootDelay),
+		bootFails: m.roll(m.cfg.BootFailureRate),
+	}
+	if !inst.bootFails && m.roll(m.cfg.PreemptionRate) {
+		inst.preemptAt = inst.readyAt
+		if m.cfg.PreemptWithin > 0 {
+			inst.preemptAt = inst.preemptAt.Add(time.Duration(m.rng.Int63n(int64(m.cfg.PreemptWithin) + 1)))
+		}
+	}
+
+	m.instances[inst.id] = inst
+	m.rented[offerID] = inst.id
+	return inst.id, nil
+}
+
+// DestroyInstance terminates an instance and lists its offer again
+func (m *FakeMarketplace) DestroyInstance(ctx context.Context, instanceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inst, ok := m.instances[instanceID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
+	if inst.destroyed {
+		return fmt.Errorf("instance %s already terminated", instanceID)
+	}
+
+	inst.destroyed = true
+	delete(m.rented, inst.offerID)
+	return nil
+}
+
+// InstanceStatus reports an instance's status at the current clock time
+func (m *FakeMarketplace) InstanceStatus(ctx context.Context, instanceID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inst, ok := m.instances[instanceID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
+	return m.statusLocked(inst), nil
+}
+
+// Preempt immediately preempts a running or provisioning instance
+func (m *FakeMarketplace) Preempt(instanceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inst, ok := m.instances[instanceID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
+	switch m.statusLocked(inst) {
+	case FakeStatusRunning, FakeStatusProvisioning:
+		inst.preempted = true
+		return nil
+	default:
+		return fmt.Errorf("instance %s is not running", instanceID)
+	}
+}
+
+// Instances returns the IDs of instances that have not been destroyed, in
+// creation order
+func (m *FakeMarketplace) Instances() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ids []string
+	for id, inst := range m.instances {
+		if !inst.destroyed {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return m.instances[ids[i]].seq < m.instances[ids[j]].seq
+	})
+	return ids
+}
+
+// ListRentedInstances implements InstanceLister
+func (m *FakeMarketplace) ListRentedInstances(ctx context.Context) ([]string, error) {
+	return m.Instances(), nil
+}
+
+func (m *FakeMarketplace) statusLocked(inst *fakeInstance) string {
+	now := m.now()
+	switch {
+	case inst.destroyed:
+		return FakeStatusTerminated
+	case inst.preempted:
+		return FakeStatusPreempted
+	case now.Before(inst.readyAt):
+		return FakeStatusProvisioning
+	case inst.bootFails:
+		return FakeStatusFailed
+	case !inst.preemptAt.IsZero() && !now.Before(inst.preemptAt):
+		return FakeStatusPreempted
+	default:
+		return FakeStatusRunning
+	}
+}
+
+func (m *FakeMarketplace) hasOffer(id string) bool {
+	for _, offer := range m.offers {
+		if offer.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// roll draws from the seeded source. Every call consumes exactly one draw so
+// outcomes depend only on the seed and call sequence.
+func (m *FakeMarketplace) roll(rate float64) bool {
+	return m.rng.Float64() < rate
+}
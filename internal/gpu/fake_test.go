@@ -0,0 +1,147 @@
+package gpu
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced clock
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestFake(t *testing.T, cfg config.FakeMarketplaceConfig) (*FakeMarketplace, *fakeClock) {
+	t.Helper()
+	m, err := NewFakeMarketplace(cfg)
+	require.NoError(t, err)
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m.SetClock(clock.now)
+	return m, clock
+}
+
+func TestFakeMarketplaceLifecycle(t *testing.T) {
+	m, clock := newTestFake(t, config.FakeMarketplaceConfig{BootDelay: time.Minute})
+	ctx := context.Background()
+
+	offers, err := m.ListOffers(ctx)
+	require.NoError(t, err)
+	require.Len(t, offers, len(DefaultFakeCatalog))
+	assert.Equal(t, "fake", offers[0].Provider)
+
+	id, err := m.CreateInstance(ctx, offers[0].ID, nil)
+	require.NoError(t, err)
+
+	status, err := m.InstanceStatus(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, FakeStatusProvisioning, status)
+
+	// A rented offer is unlisted and cannot be rented twice
+	offers, _ = m.ListOffers(ctx)
+	assert.Len(t, offers, len(DefaultFakeCatalog)-1)
+	_, err = m.CreateInstance(ctx, "fake-"+DefaultFakeCatalog[0].ID, nil)
+	assert.ErrorContains(t, err, "already rented")
+
+	clock.advance(time.Minute)
+	status, _ = m.InstanceStatus(ctx, id)
+	assert.Equal(t, FakeStatusRunning, status)
+
+	require.NoError(t, m.Preempt(id))
+	status, _ = m.InstanceStatus(ctx, id)
+	assert.Equal(t, FakeStatusPreempted, status)
+
+	require.NoError(t, m.DestroyInstance(ctx, id))
+	status, _ = m.InstanceStatus(ctx, id)
+	assert.Equal(t, FakeStatusTerminated, status)
+	assert.Error(t, m.DestroyInstance(ctx, id))
+
+	offers, _ = m.ListOffers(ctx)
+	assert.Len(t, offers, len(DefaultFakeCatalog))
+	assert.Empty(t, m.Instances())
+}
+
+// outcomes records what happens to one instance per catalog offer
+func outcomes(t *testing.T, seed int64) []string {
+	m, clock := newTestFake(t, config.FakeMarketplaceConfig{
+		Seed:            seed,
+		BootDelay:       time.Minute,
+		FailureRate:     0.3,
+		BootFailureRate: 0.3,
+		PreemptionRate:  0.5,
+		PreemptWithin:   time.Hour,
+	})
+	ctx := context.Background()
+
+	var ids []string
+	var results []string
+	for _, offer := range DefaultFakeCatalog {
+		id, err := m.CreateInstance(ctx, offer.ID, nil)
+		if err != nil {
+			results = append(results, "create-failed")
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	clock.advance(2 * time.Hour)
+	for _, id := range ids {
+		status, err := m.InstanceStatus(ctx, id)
+		require.NoError(t, err)
+		results = append(results, status)
+	}
+	return results
+}
+
+func TestFakeMarketplaceIsDeterministic(t *testing.T) {
+	first := outcomes(t, 42)
+	assert.Equal(t, first, outcomes(t, 42), "the same seed replays identically")
+
+	seen := make(map[string]bool)
+	for _, seed := range []int64{1, 2, 3, 4, 5, 42} {
+		for _, status := range outcomes(t, seed) {
+			seen[status] = true
+		}
+	}
+	for _, status := range []string{"create-failed", FakeStatusFailed, FakeStatusPreempted, FakeStatusRunning} {
+		assert.True(t, seen[status], "expected some instance to end up %s", status)
+	}
+}
+
+func TestFakeMarketplaceCatalogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	data, _ := json.Marshal([]FakeOffer{{ID: "tiny", GPUName: "T4", GPUCount: 1, VRAM: 16, PricePerHour: 0.1, HealthURL: "http://127.0.0.1:8081/healthz"}})
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	m, err := NewFakeMarketplace(config.FakeMarketplaceConfig{CatalogPath: path})
+	require.NoError(t, err)
+	offers, err := m.ListOffers(context.Background())
+	require.NoError(t, err)
+	require.Len(t, offers, 1)
+	assert.Equal(t, "fake-tiny", offers[0].ID)
+	assert.Equal(t, "http://127.0.0.1:8081/healthz", offers[0].Specifications["health_url"], "catalog offers can be health probed")
+	assert.NotContains(t, offers[0].Specifications, "grpc_health_addr")
+
+	_, err = NewFakeMarketplaceWithCatalog(config.FakeMarketplaceConfig{}, []FakeOffer{{ID: "a"}, {ID: "a"}})
+	assert.ErrorContains(t, err, "duplicate")
+}
+
+func TestServiceRegistersFakeMarketplace(t *testing.T) {
+	s := NewService(&config.GPUConfig{Fake: config.FakeMarketplaceConfig{Enabled: true}})
+	assert.Equal(t, []Provider{ProviderFake}, s.Providers().Names())
+
+	instances, err := s.ListInstances(context.Background(), ProviderAll)
+	require.NoError(t, err)
+	require.NotEmpty(t, instances)
+
+	id, err := s.CreateInstance(context.Background(), ProviderFake, instances[0].ID, nil)
+	require.NoError(t, err)
+	require.NoError(t, s.DestroyInstance(context.Background(), ProviderFake, id))
+}
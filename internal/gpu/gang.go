@@ -0,0 +1,309 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+)
+
+// DefaultGangPollInterval is how often member status is checked while a
+// gang waits to become ready
+const DefaultGangPollInterval = 5 * time.Second
+
+// GangRequest asks for a set of instances that is provisioned all-or-nothing.
+// If any member cannot be created, fails while booting or is not running by
+// the readiness deadline, every member already created is destroyed.
+type GangRequest struct {
+	Count          int                    `json:"count"`
+	Provider       Provider               `json:"provider,omitempty"` // "" or "all" for any provider
+	Filters        map[string]interface{} `json:"filters,omitempty"`  // As for FilterInstances
+	Config         map[string]interface{} `json:"config,omitempty"`   // Passed to CreateInstance
+	SameProvider   bool                   `json:"same_provider"`
+	SameRegion     bool                   `json:"same_region"`
+	SameDatacenter bool                   `json:"same_datacenter"`
+	ReadyTimeout   time.Duration          `json:"ready_timeout"` // 0 skips waiting for members to run
+	PollInterval   time.Duration          `json:"poll_interval,omitempty"`
+}
+
+// GangMember is one instance of a provisioned gang
+type GangMember struct {
+	OfferID      string   `json:"offer_id"`
+	InstanceID   string   `json:"instance_id"` // As returned by CreateInstance
+	Provider     Provider `json:"provider"`
+	GPUName      string   `json:"gpu_name"`
+	GPUCount     int      `json:"gpu_count"`
+	VRAM         int      `json:"vram_gb"`
+	PricePerHour float64  `json:"price_per_hour"`
+	Region       string   `json:"region"`
+	Datacenter   string   `json:"datacenter,omitempty"`
+	Status       string   `json:"status,omitempty"`
+}
+
+// GangResult is a fully provisioned gang
+type GangResult struct {
+	Members      []GangMember `json:"members"`
+	PricePerHour float64      `json:"price_per_hour"` // Whole gang
+}
+
+// GangError explains why a gang was not provisioned and what was torn down
+type GangError struct {
+	Reason     string   // What went wrong
+	RolledBack []string // Instances destroyed during rollback
+	Leaked     []string // Instances that could not be destroyed
+}
+
+func (e *GangError) Error() string {
+	msg := "gang reservation failed: " + e.Reason
+	if len(e.RolledBack) > 0 {
+		msg += fmt.Sprintf(" (rolled back %d instances)", len(e.RolledBack))
+	}
+	if len(e.Leaked) > 0 {
+		msg += fmt.Sprintf(" (failed to destroy %s)", strings.Join(e.Leaked, ", "))
+	}
+	return msg
+}
+
+// gangPlacement is a set of offers members may be drawn from together
+type gangPlacement struct {
+	key    string
+	offers []models.GPUInstance // Cheapest first
+}
+
+// ReserveGang provisions req.Count instances atomically. Offers are grouped
+// by the placement constraints and the group whose cheapest members cost
+// least is tried first; spare offers in a group stand in for offers that
+// cannot be created. A gang that cannot be completed in one group is rolled
+// back rather than spread over several.
+func (s *Service) ReserveGang(ctx context.Context, req *GangRequest) (*GangResult, error) {
+	if req.Count < 1 {
+		return nil, fmt.Errorf("gang count must be at least 1")
+	}
+	provider := req.Provider
+	if provider == "" {
+		provider = ProviderAll
+	}
+
+	offers, err := s.ListInstances(ctx, provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+	if len(req.Filters) > 0 {
+		offers = s.FilterInstances(offers, req.Filters)
+	}
+
+	placements := gangPlacements(offers, req)
+	if len(placements) == 0 {
+		return nil, &GangError{Reason: fmt.Sprintf("no placement has %d matching offers", req.Count)}
+	}
+
+	// Rollback must finish even if the caller gives up
+	cleanup := context.WithoutCancel(ctx)
+
+	var lastErr *GangError
+	for _, placement := range placements {
+		members, reason := s.createGang(ctx, placement, req)
+		if reason == "" && req.ReadyTimeout > 0 {
+			reason = s.awaitGang(ctx, members, req)
+		}
+		if reason == "" {
+			result := &GangResult{Members: members}
+			for _, m := range members {
+				result.PricePerHour += m.PricePerHour
+			}
+			return result, nil
+		}
+
+		lastErr = s.rollbackGang(cleanup, members, reason)
+		if ctx.Err() != nil || len(lastErr.Leaked) > 0 {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// ReleaseGang destroys every member of a provisioned gang
+func (s *Service) ReleaseGang(ctx context.Context, gang *GangResult) error {
+	if err := s.rollbackGang(ctx, gang.Members, "released"); len(err.Leaked) > 0 {
+		return err
+	}
+	return nil
+}
+
+// createGang creates members from a placement's offers until the gang is
+// complete. It returns the members created and, if the gang could not be
+// completed, why.
+func (s *Service) createGang(ctx context.Context, placement gangPlacement, req *GangRequest) ([]GangMember, string) {
+	var members []GangMember
+	var errs []string
+
+	for _, offer := range placement.offers {
+		if len(members) == req.Count {
+			break
+		}
+		if ctx.Err() != nil {
+			return members, ctx.Err().Error()
+		}
+
+		instanceID, err := s.CreateInstance(ctx, Provider(offer.Provider), offer.ID, req.Config)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", offer.ID, err))
+			continue
+		}
+		members = append(members, GangMember{
+			OfferID:      offer.ID,
+			InstanceID:   instanceID,
+			Provider:     Provider(offer.Provider),
+			GPUName:      offer.GPUName,
+			GPUCount:     offer.GPUCount,
+			VRAM:         offer.VRAM,
+			PricePerHour: offer.PricePerHour,
+			Region:       offer.Location,
+			Datacenter:   offerDatacenter(offer),
+		})
+	}
+
+	if len(members) < req.Count {
+		return members, fmt.Sprintf("created %d of %d members in %s: %s",
+			len(members), req.Count, placement.key, strings.Join(errs, "; "))
+	}
+	return members, ""
+}
+
+// awaitGang waits until every member is running. It returns why the gang is
+// not ready if a member fails or the readiness deadline passes. Providers
+// that cannot report status are taken to be ready.
+func (s *Service) awaitGang(ctx context.Context, members []GangMember, req *GangRequest) string {
+	interval := req.PollInterval
+	if interval <= 0 {
+		interval = DefaultGangPollInterval
+	}
+	deadline := time.NewTimer(req.ReadyTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ready := 0
+		for i := range members {
+			m := &members[i]
+			if m.Status == "running" {
+				ready++
+				continue
+			}
+
+			status, err := s.GetInstanceStatus(ctx, m.Provider, m.InstanceID)
+			if errors.Is(err, ErrStatusNotSupported) {
+				ready++
+				continue
+			}
+			if err != nil {
+				return fmt.Sprintf("failed to check member %s: %v", m.InstanceID, err)
+			}
+
+			m.Status = strings.ToLower(status)
+			switch m.Status {
+			case "running", "active", "ready":
+				m.Status = "running"
+				ready++
+			case "failed", "error", "preempted", "terminated", "exited", "destroyed":
+				return fmt.Sprintf("member %s is %s", m.InstanceID, m.Status)
+			}
+		}
+		if ready == len(members) {
+			return ""
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err().Error()
+		case <-deadline.C:
+			return fmt.Sprintf("%d of %d members ready after %s", ready, len(members), req.ReadyTimeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// rollbackGang destroys the given members, reporting which could not be
+func (s *Service) rollbackGang(ctx context.Context, members []GangMember, reason string) *GangError {
+	gangErr := &GangError{Reason: reason}
+	for _, m := range members {
+		if err := s.DestroyInstance(ctx, m.Provider, m.InstanceID); err != nil {
+			log.Printf("Failed to destroy gang member %s on %s: %v", m.InstanceID, m.Provider, err)
+			gangErr.Leaked = append(gangErr.Leaked, m.InstanceID)
+			continue
+		}
+		gangErr.RolledBack = append(gangErr.RolledBack, m.InstanceID)
+	}
+	return gangErr
+}
+
+// gangPlacements groups offers by the request's placement constraints and
+// returns the groups large enough for the gang, cheapest gang first
+func gangPlacements(offers []models.GPUInstance, req *GangRequest) []gangPlacement {
+	groups := make(map[string]*gangPlacement)
+	var order []string
+	for _, offer := range offers {
+		if !offer.Available {
+			continue
+		}
+
+		var key []string
+		if req.SameProvider || req.SameRegion || req.SameDatacenter {
+			key = append(key, offer.Provider)
+		}
+		if req.SameRegion || req.SameDatacenter {
+			key = append(key, offer.Location)
+		}
+		if req.SameDatacenter {
+			dc := offerDatacenter(offer)
+			if dc == "" {
+				continue // Co-location cannot be confirmed
+			}
+			key = append(key, dc)
+		}
+
+		k := strings.Join(key, "/")
+		if k == "" {
+			k = "any placement"
+		}
+		group, ok := groups[k]
+		if !ok {
+			group = &gangPlacement{key: k}
+			groups[k] = group
+			order = append(order, k)
+		}
+		group.offers = append(group.offers, offer)
+	}
+
+	var placements []gangPlacement
+	cost := make(map[string]float64)
+	for _, k := range order {
+		group := groups[k]
+		if len(group.offers) < req.Count {
+			continue
+		}
+		sort.SliceStable(group.offers, func(i, j int) bool {
+			return group.offers[i].PricePerHour < group.offers[j].PricePerHour
+		})
+		for _, offer := range group.offers[:req.Count] {
+			cost[k] += offer.PricePerHour
+		}
+		placements = append(placements, *group)
+	}
+	sort.SliceStable(placements, func(i, j int) bool {
+		return cost[placements[i].key] < cost[placements[j].key]
+	})
+	return placements
+}
+
+// offerDatacenter returns the datacenter a provider reports for an offer, if any
+func offerDatacenter(offer models.GPUInstance) string {
+	dc, _ := offer.Specifications["datacenter"].(string)
+	return dc
+}
@@ -0,0 +1,150 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gangCatalog has a cheap but scattered set of H100s and a pricier set that
+// shares a datacenter
+var gangCatalog = []FakeOffer{
+	{ID: "east-a-1", GPUName: "H100", GPUCount: 1, VRAM: 80, PricePerHour: 2.0, Location: "us-east-1", Datacenter: "use1-a"},
+	{ID: "east-a-2", GPUName: "H100", GPUCount: 1, VRAM: 80, PricePerHour: 2.0, Location: "us-east-1", Datacenter: "use1-a"},
+	{ID: "east-a-3", GPUName: "H100", GPUCount: 1, VRAM: 80, PricePerHour: 2.1, Location: "us-east-1", Datacenter: "use1-a"},
+	{ID: "east-b-1", GPUName: "H100", GPUCount: 1, VRAM: 80, PricePerHour: 1.0, Location: "us-east-1", Datacenter: "use1-b"},
+	{ID: "west-1", GPUName: "H100", GPUCount: 1, VRAM: 80, PricePerHour: 1.0, Location: "us-west-2"},
+	{ID: "west-2", GPUName: "H100", GPUCount: 1, VRAM: 80, PricePerHour: 1.0, Location: "us-west-2"},
+}
+
+// flakyProvider fails to create instances from the named offers
+type flakyProvider struct {
+	*FakeMarketplace
+	fail map[string]bool
+}
+
+func (p *flakyProvider) CreateInstance(ctx context.Context, offerID string, config map[string]interface{}) (string, error) {
+	if p.fail[offerID] {
+		return "", errors.New("offer was taken")
+	}
+	return p.FakeMarketplace.CreateInstance(ctx, offerID, config)
+}
+
+func newGangService(t *testing.T, cfg config.FakeMarketplaceConfig, fail ...string) (*Service, *FakeMarketplace, *fakeClock) {
+	t.Helper()
+	fake, err := NewFakeMarketplaceWithCatalog(cfg, gangCatalog)
+	require.NoError(t, err)
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	fake.SetClock(clock.now)
+
+	provider := &flakyProvider{FakeMarketplace: fake, fail: make(map[string]bool)}
+	for _, id := range fail {
+		provider.fail["fake-"+id] = true
+	}
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(provider))
+	return NewServiceWithRegistry(&config.GPUConfig{}, registry), fake, clock
+}
+
+func gangOffers(result *GangResult) []string {
+	var ids []string
+	for _, m := range result.Members {
+		ids = append(ids, strings.TrimPrefix(m.OfferID, "fake-"))
+	}
+	return ids
+}
+
+func TestReserveGangPlacement(t *testing.T) {
+	ctx := context.Background()
+
+	s, _, _ := newGangService(t, config.FakeMarketplaceConfig{})
+	result, err := s.ReserveGang(ctx, &GangRequest{Count: 3})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"east-b-1", "west-1", "west-2"}, gangOffers(result), "cheapest offers anywhere")
+	assert.Equal(t, 3.0, result.PricePerHour)
+
+	s, _, _ = newGangService(t, config.FakeMarketplaceConfig{})
+	result, err = s.ReserveGang(ctx, &GangRequest{Count: 2, SameRegion: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"west-1", "west-2"}, gangOffers(result))
+
+	s, _, _ = newGangService(t, config.FakeMarketplaceConfig{})
+	result, err = s.ReserveGang(ctx, &GangRequest{Count: 3, SameRegion: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"east-b-1", "east-a-1", "east-a-2"}, gangOffers(result))
+
+	s, _, _ = newGangService(t, config.FakeMarketplaceConfig{})
+	result, err = s.ReserveGang(ctx, &GangRequest{Count: 2, SameDatacenter: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"east-a-1", "east-a-2"}, gangOffers(result), "offers without a datacenter never qualify")
+	for _, m := range result.Members {
+		assert.Equal(t, "use1-a", m.Datacenter)
+	}
+
+	_, err = s.ReserveGang(ctx, &GangRequest{Count: 4, SameDatacenter: true})
+	var gangErr *GangError
+	require.ErrorAs(t, err, &gangErr)
+	assert.Contains(t, gangErr.Reason, "no placement has 4")
+}
+
+func TestReserveGangUsesSpareOffers(t *testing.T) {
+	s, fake, _ := newGangService(t, config.FakeMarketplaceConfig{}, "east-a-1")
+
+	result, err := s.ReserveGang(context.Background(), &GangRequest{Count: 2, SameDatacenter: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"east-a-2", "east-a-3"}, gangOffers(result))
+	assert.Len(t, fake.Instances(), 2)
+
+	require.NoError(t, s.ReleaseGang(context.Background(), result))
+	assert.Empty(t, fake.Instances())
+}
+
+func TestReserveGangRollsBackOnCreateFailure(t *testing.T) {
+	s, fake, _ := newGangService(t, config.FakeMarketplaceConfig{}, "east-a-2", "east-a-3")
+
+	_, err := s.ReserveGang(context.Background(), &GangRequest{Count: 2, SameDatacenter: true})
+	var gangErr *GangError
+	require.ErrorAs(t, err, &gangErr)
+	assert.Contains(t, gangErr.Reason, "created 1 of 2 members")
+	assert.Len(t, gangErr.RolledBack, 1)
+	assert.Empty(t, gangErr.Leaked)
+	assert.Empty(t, fake.Instances(), "nothing is left running")
+}
+
+func TestReserveGangReadiness(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ready", func(t *testing.T) {
+		s, _, _ := newGangService(t, config.FakeMarketplaceConfig{})
+		result, err := s.ReserveGang(ctx, &GangRequest{Count: 2, SameRegion: true, ReadyTimeout: time.Second, PollInterval: time.Millisecond})
+		require.NoError(t, err)
+		for _, m := range result.Members {
+			assert.Equal(t, "running", m.Status)
+		}
+	})
+
+	t.Run("member fails to boot", func(t *testing.T) {
+		s, fake, _ := newGangService(t, config.FakeMarketplaceConfig{BootFailureRate: 1})
+		_, err := s.ReserveGang(ctx, &GangRequest{Count: 2, SameRegion: true, ReadyTimeout: time.Second, PollInterval: time.Millisecond})
+		var gangErr *GangError
+		require.ErrorAs(t, err, &gangErr)
+		assert.Contains(t, gangErr.Reason, "is failed")
+		assert.Empty(t, fake.Instances())
+	})
+
+	t.Run("deadline missed", func(t *testing.T) {
+		s, fake, _ := newGangService(t, config.FakeMarketplaceConfig{BootDelay: time.Hour})
+		_, err := s.ReserveGang(ctx, &GangRequest{Count: 2, SameDatacenter: true, ReadyTimeout: 20 * time.Millisecond, PollInterval: time.Millisecond})
+		var gangErr *GangError
+		require.ErrorAs(t, err, &gangErr)
+		assert.Contains(t, gangErr.Reason, "0 of 2 members ready")
+		assert.Len(t, gangErr.RolledBack, 2)
+		assert.Empty(t, fake.Instances())
+	})
+}
@@ -33,6 +33,7 @@ type GPUGroup struct {
 	VRAM        int     // GB
 	ComputeCaps string  // CUDA compute capability or equivalent
 	PricePerHr  float64 // USD per hour (estimated)
+	TFLOPS      float64 // Peak dense FP16 TFLOPS (approximate)
 }
 
 // GPU group definitions
@@ -45,6 +46,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        80,
 		ComputeCaps: "9.0",
 		PricePerHr:  3.00,
+		TFLOPS:      989,
 	},
 	"H200": {
 		Vendor:      VendorNVIDIA,
@@ -53,6 +55,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        141,
 		ComputeCaps: "9.0",
 		PricePerHr:  3.50,
+		TFLOPS:      989,
 	},
 	"A100": {
 		Vendor:      VendorNVIDIA,
@@ -61,6 +64,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        80,
 		ComputeCaps: "8.0",
 		PricePerHr:  2.00,
+		TFLOPS:      312,
 	},
 	"A100-40GB": {
 		Vendor:      VendorNVIDIA,
@@ -69,6 +73,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        40,
 		ComputeCaps: "8.0",
 		PricePerHr:  1.50,
+		TFLOPS:      312,
 	},
 
 	// NVIDIA High-End
@@ -79,6 +84,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        32,
 		ComputeCaps: "7.0",
 		PricePerHr:  1.20,
+		TFLOPS:      125,
 	},
 	"Tesla V100": {
 		Vendor:      VendorNVIDIA,
@@ -87,6 +93,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        32,
 		ComputeCaps: "7.0",
 		PricePerHr:  1.20,
+		TFLOPS:      125,
 	},
 	"P100": {
 		Vendor:      VendorNVIDIA,
@@ -95,6 +102,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        16,
 		ComputeCaps: "6.0",
 		PricePerHr:  0.80,
+		TFLOPS:      18.7,
 	},
 
 	// NVIDIA Mid-Range
@@ -105,6 +113,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        24,
 		ComputeCaps: "8.9",
 		PricePerHr:  0.80,
+		TFLOPS:      165,
 	},
 	"RTX 3090": {
 		Vendor:      VendorNVIDIA,
@@ -113,6 +122,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        24,
 		ComputeCaps: "8.6",
 		PricePerHr:  0.60,
+		TFLOPS:      71,
 	},
 	"RTX 3080": {
 		Vendor:      VendorNVIDIA,
@@ -121,6 +131,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        10,
 		ComputeCaps: "8.6",
 		PricePerHr:  0.40,
+		TFLOPS:      59.5,
 	},
 
 	// NVIDIA Budget
@@ -131,6 +142,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        12,
 		ComputeCaps: "8.6",
 		PricePerHr:  0.25,
+		TFLOPS:      25.6,
 	},
 	"GTX 1080 Ti": {
 		Vendor:      VendorNVIDIA,
@@ -139,6 +151,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        11,
 		ComputeCaps: "6.1",
 		PricePerHr:  0.20,
+		TFLOPS:      11.3,
 	},
 
 	// AMD Enterprise
@@ -149,6 +162,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        192,
 		ComputeCaps: "gfx942",
 		PricePerHr:  3.20,
+		TFLOPS:      1307,
 	},
 	"MI250X": {
 		Vendor:      VendorAMD,
@@ -157,6 +171,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        128,
 		ComputeCaps: "gfx90a",
 		PricePerHr:  2.50,
+		TFLOPS:      383,
 	},
 	"MI210": {
 		Vendor:      VendorAMD,
@@ -165,6 +180,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        64,
 		ComputeCaps: "gfx90a",
 		PricePerHr:  1.80,
+		TFLOPS:      181,
 	},
 
 	// AMD Consumer
@@ -175,6 +191,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        24,
 		ComputeCaps: "gfx1100",
 		PricePerHr:  0.50,
+		TFLOPS:      123,
 	},
 	"RX 6900 XT": {
 		Vendor:      VendorAMD,
@@ -183,6 +200,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        16,
 		ComputeCaps: "gfx1030",
 		PricePerHr:  0.40,
+		TFLOPS:      46,
 	},
 	"RX 6600": {
 		Vendor:      VendorAMD,
@@ -191,6 +209,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        8,
 		ComputeCaps: "gfx1032",
 		PricePerHr:  0.20,
+		TFLOPS:      17.9,
 	},
 
 	// Intel
@@ -201,6 +220,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        128,
 		ComputeCaps: "PVC",
 		PricePerHr:  2.00,
+		TFLOPS:      839,
 	},
 	"Arc A770": {
 		Vendor:      VendorIntel,
@@ -209,6 +229,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        16,
 		ComputeCaps: "DG2",
 		PricePerHr:  0.30,
+		TFLOPS:      138,
 	},
 
 	// Apple Silicon
@@ -219,6 +240,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        128,
 		ComputeCaps: "Metal 3",
 		PricePerHr:  1.00,
+		TFLOPS:      21,
 	},
 	"M2 Ultra": {
 		Vendor:      VendorApple,
@@ -227,6 +249,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        192,
 		ComputeCaps: "Metal 3",
 		PricePerHr:  1.20,
+		TFLOPS:      27,
 	},
 	"M3 Max": {
 		Vendor:      VendorApple,
@@ -235,6 +258,7 @@ var GPUGroups = map[string]GPUGroup{
 		VRAM:        128,
 		ComputeCaps: "Metal 3",
 		PricePerHr:  0.80,
+		TFLOPS:      28,
 	},
 }
 
@@ -255,12 +279,17 @@ func ClassifyGPU(name string) (GPUGroup, bool) {
 		}
 	}
 
-	// Try partial match (contains)
-	for key, group := range GPUGroups {
-		if strings.Contains(nameLower, strings.ToLower(key)) {
-			return group, true
+	// Try partial match (contains), preferring the longest key so that
+	// e.g. "Tesla V100-SXM2" is always "Tesla V100" rather than "V100"
+	best := ""
+	for key := range GPUGroups {
+		if strings.Contains(nameLower, strings.ToLower(key)) && (len(key) > len(best) || len(key) == len(best) && key < best) {
+			best = key
 		}
 	}
+	if best != "" {
+		return GPUGroups[best], true
+	}
 
 	// Return unknown GPU
 	return GPUGroup{
@@ -0,0 +1,97 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/aiserve/gpuproxy/pkg/ionet"
+	"github.com/aiserve/gpuproxy/pkg/vastai"
+)
+
+const defaultVastAIImage = "nvidia/cuda:12.0.0-base-ubuntu22.04"
+
+// vastAIProvider adapts the vast.ai marketplace client to GPUProvider
+type vastAIProvider struct {
+	client *vastai.Client
+}
+
+// NewVastAIProvider creates a GPUProvider backed by vast.ai
+func NewVastAIProvider(client *vastai.Client) GPUProvider {
+	return &vastAIProvider{client: client}
+}
+
+func (p *vastAIProvider) Name() Provider {
+	return ProviderVastAI
+}
+
+func (p *vastAIProvider) ListOffers(ctx context.Context) ([]models.GPUInstance, error) {
+	return p.client.ListInstances(ctx)
+}
+
+func (p *vastAIProvider) CreateInstance(ctx context.Context, offerID string, config map[string]interface{}) (string, error) {
+	imageURL, _ := config["image"].(string)
+	if imageURL == "" {
+		imageURL = defaultVastAIImage
+	}
+	return p.client.CreateInstance(ctx, strings.TrimPrefix(offerID, "vast-"), imageURL)
+}
+
+func (p *vastAIProvider) DestroyInstance(ctx context.Context, instanceID string) error {
+	return instanceNotFound(p.client.DestroyInstance(ctx, instanceID), vastai.ErrInstanceNotFound, instanceID)
+}
+
+func (p *vastAIProvider) InstanceStatus(ctx context.Context, instanceID string) (string, error) {
+	status, err := p.client.GetInstanceStatus(ctx, instanceID)
+	return status, instanceNotFound(err, vastai.ErrInstanceNotFound, instanceID)
+}
+
+func (p *vastAIProvider) ListRentedInstances(ctx context.Context) ([]string, error) {
+	return p.client.ListRentedInstances(ctx)
+}
+
+// ioNetProvider adapts the io.net client to GPUProvider
+type ioNetProvider struct {
+	client *ionet.Client
+}
+
+// NewIONetProvider creates a GPUProvider backed by io.net
+func NewIONetProvider(client *ionet.Client) GPUProvider {
+	return &ioNetProvider{client: client}
+}
+
+func (p *ioNetProvider) Name() Provider {
+	return ProviderIONet
+}
+
+func (p *ioNetProvider) ListOffers(ctx context.Context) ([]models.GPUInstance, error) {
+	return p.client.ListInstances(ctx)
+}
+
+func (p *ioNetProvider) CreateInstance(ctx context.Context, offerID string, config map[string]interface{}) (string, error) {
+	return p.client.CreateInstance(ctx, strings.TrimPrefix(offerID, "ionet-"), config)
+}
+
+func (p *ioNetProvider) DestroyInstance(ctx context.Context, instanceID string) error {
+	return instanceNotFound(p.client.DestroyInstance(ctx, instanceID), ionet.ErrInstanceNotFound, instanceID)
+}
+
+func (p *ioNetProvider) InstanceStatus(ctx context.Context, instanceID string) (string, error) {
+	status, err := p.client.GetInstanceStatus(ctx, instanceID)
+	return status, instanceNotFound(err, ionet.ErrInstanceNotFound, instanceID)
+}
+
+func (p *ioNetProvider) ListRentedInstances(ctx context.Context) ([]string, error) {
+	return p.client.ListRentedInstances(ctx)
+}
+
+// instanceNotFound reports a marketplace client's notFound error as
+// ErrInstanceNotFound, so callers can tell a vanished instance from a failure
+func instanceNotFound(err, notFound error, instanceID string) error {
+	if errors.Is(err, notFound) {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
+	return err
+}
@@ -0,0 +1,229 @@
+package gpu
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PriceStore persists marketplace price points
+type PriceStore interface {
+	// Add saves points
+	Add(ctx context.Context, points []PricePoint) error
+	// List returns the points matching q, oldest first
+	List(ctx context.Context, q PriceQuery) ([]PricePoint, error)
+	// Replace deletes the points at resolution older than before and adds
+	// points, atomically
+	Replace(ctx context.Context, resolution time.Duration, before time.Time, points []PricePoint) error
+	Close() error
+}
+
+// OpenPriceStore returns the store for a GPU_PRICE_HISTORY_STORE setting:
+// "memory" (or empty) keeps prices in process memory only, and "database"
+// keeps them in db, the server's database.
+func OpenPriceStore(kind string, db *sql.DB) (PriceStore, error) {
+	switch kind {
+	case "", "memory":
+		return NewMemoryPriceStore(), nil
+	case "database":
+		if db == nil {
+			return nil, fmt.Errorf("price store %q needs a database", kind)
+		}
+		return NewSQLPriceStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown price store %q", kind)
+	}
+}
+
+// MemoryPriceStore keeps price points in process memory
+type MemoryPriceStore struct {
+	mu     sync.Mutex
+	points []PricePoint
+}
+
+// NewMemoryPriceStore creates an empty in-memory price store
+func NewMemoryPriceStore() *MemoryPriceStore {
+	return &MemoryPriceStore{}
+}
+
+// Add saves points
+func (s *MemoryPriceStore) Add(ctx context.Context, points []PricePoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.points = append(s.points, points...)
+	return nil
+}
+
+// List returns the points matching q, oldest first
+func (s *MemoryPriceStore) List(ctx context.Context, q PriceQuery) ([]PricePoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []PricePoint
+	for _, p := range s.points {
+		if q.matches(p) {
+			list = append(list, p)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Time.Before(list[j].Time) })
+	return list, nil
+}
+
+// Replace deletes the points at resolution older than before and adds points
+func (s *MemoryPriceStore) Replace(ctx context.Context, resolution time.Duration, before time.Time, points []PricePoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.points[:0]
+	for _, p := range s.points {
+		if p.Resolution != resolution || !p.Time.Before(before) {
+			kept = append(kept, p)
+		}
+	}
+	s.points = append(kept, points...)
+	return nil
+}
+
+// Close is a no-op for the in-memory store
+func (s *MemoryPriceStore) Close() error {
+	return nil
+}
+
+// SQLPriceStore is a PriceStore backed by Postgres or SQLite
+type SQLPriceStore struct {
+	db *sql.DB
+}
+
+// NewSQLPriceStore keeps price points in db, which needs the table from the
+// database package's migrations. The store doesn't close db.
+func NewSQLPriceStore(db *sql.DB) *SQLPriceStore {
+	return &SQLPriceStore{db: db}
+}
+
+// Add saves points
+func (s *SQLPriceStore) Add(ctx context.Context, points []PricePoint) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save prices: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertPrices(ctx, tx, points); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save prices: %w", err)
+	}
+	return nil
+}
+
+// List returns the points matching q, oldest first
+func (s *SQLPriceStore) List(ctx context.Context, q PriceQuery) ([]PricePoint, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if !q.From.IsZero() {
+		conditions = append(conditions, "time >= "+arg(q.From.UnixNano()))
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, "time < "+arg(q.To.UnixNano()))
+	}
+	if q.GPUClass != "" {
+		conditions = append(conditions, "gpu_class = "+arg(q.GPUClass))
+	}
+	if q.Provider != "" {
+		conditions = append(conditions, "provider = "+arg(q.Provider))
+	}
+	if q.Region != "" {
+		conditions = append(conditions, "region = "+arg(q.Region))
+	}
+
+	query := `SELECT time, resolution, provider, gpu_model, gpu_class, region, available, min_price, avg_price, max_price, samples FROM gpu_price_points`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY time`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prices: %w", err)
+	}
+	defer rows.Close()
+
+	var list []PricePoint
+	for rows.Next() {
+		var p PricePoint
+		var at, resolution int64
+		var available int
+		if err := rows.Scan(&at, &resolution, &p.Provider, &p.GPUModel, &p.GPUClass, &p.Region, &available,
+			&p.MinPrice, &p.AvgPrice, &p.MaxPrice, &p.Samples); err != nil {
+			return nil, fmt.Errorf("failed to read price: %w", err)
+		}
+		p.Time = time.Unix(0, at).UTC()
+		p.Resolution = time.Duration(resolution)
+		p.Available = available != 0
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// Replace deletes the points at resolution older than before and adds points
+func (s *SQLPriceStore) Replace(ctx context.Context, resolution time.Duration, before time.Time, points []PricePoint) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to replace prices: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM gpu_price_points WHERE resolution = $1 AND time < $2`,
+		int64(resolution), before.UnixNano()); err != nil {
+		return fmt.Errorf("failed to delete prices: %w", err)
+	}
+	if err := insertPrices(ctx, tx, points); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to replace prices: %w", err)
+	}
+	return nil
+}
+
+// Close is a no-op; the database belongs to the caller
+func (s *SQLPriceStore) Close() error {
+	return nil
+}
+
+func insertPrices(ctx context.Context, tx *sql.Tx, points []PricePoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO gpu_price_points (time, resolution, provider, gpu_model, gpu_class, region, available, min_price, avg_price, max_price, samples)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`)
+	if err != nil {
+		return fmt.Errorf("failed to save prices: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, p := range points {
+		available := 0
+		if p.Available {
+			available = 1
+		}
+		if _, err := stmt.ExecContext(ctx, p.Time.UnixNano(), int64(p.Resolution), p.Provider, p.GPUModel, p.GPUClass,
+			p.Region, available, p.MinPrice, p.AvgPrice, p.MaxPrice, p.Samples); err != nil {
+			return fmt.Errorf("failed to save price: %w", err)
+		}
+	}
+	return nil
+}
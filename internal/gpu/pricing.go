@@ -0,0 +1,441 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+)
+
+// ErrNoPriceHistory is returned when no prices were recorded for a query
+var ErrNoPriceHistory = errors.New("no price history")
+
+// Default price history settings
+const (
+	DefaultPriceRawRetention = 48 * time.Hour
+	DefaultPriceRetention    = 90 * 24 * time.Hour
+	priceRollup              = time.Hour
+)
+
+// PricePoint is the price of offers of one GPU model from one provider and
+// region. A raw point is one offer in one listing; older points are rolled up
+// into hourly buckets. Prices are per GPU-hour so offers with different GPU
+// counts compare.
+type PricePoint struct {
+	Time       time.Time     `json:"time"`       // Listing time, or start of the bucket
+	Resolution time.Duration `json:"resolution"` // 0 for a raw point, else the bucket width
+	Provider   string        `json:"provider"`
+	GPUModel   string        `json:"gpu_model"`
+	GPUClass   string        `json:"gpu_class"` // From ClassifyGPU
+	Region     string        `json:"region"`
+	Available  bool          `json:"available"`
+	MinPrice   float64       `json:"min_price"`
+	AvgPrice   float64       `json:"avg_price"`
+	MaxPrice   float64       `json:"max_price"`
+	Samples    int           `json:"samples"` // Offers listed
+}
+
+// PriceQuery selects price points. Empty fields match everything; a zero
+// From or To leaves that side open.
+type PriceQuery struct {
+	From     time.Time
+	To       time.Time
+	GPUClass string
+	Provider string
+	Region   string
+}
+
+func (q PriceQuery) matches(p PricePoint) bool {
+	return (q.From.IsZero() || !p.Time.Before(q.From)) &&
+		(q.To.IsZero() || p.Time.Before(q.To)) &&
+		(q.GPUClass == "" || p.GPUClass == q.GPUClass) &&
+		(q.Provider == "" || p.Provider == q.Provider) &&
+		(q.Region == "" || p.Region == q.Region)
+}
+
+// PriceClass returns the class prices of a GPU are grouped under, e.g.
+// "A100" for "NVIDIA A100-SXM4-80GB"
+func PriceClass(gpuName string) string {
+	group, _ := ClassifyGPU(gpuName)
+	return group.Model
+}
+
+// PriceHistory records marketplace listings and answers trend and cost
+// questions from them. Raw listings are kept for rawRetention and then rolled
+// up into hourly buckets, which are kept for retention.
+type PriceHistory struct {
+	store        PriceStore
+	rawRetention time.Duration
+	retention    time.Duration
+	now          func() time.Time
+}
+
+// NewPriceHistory creates a price history over store
+func NewPriceHistory(store PriceStore, rawRetention, retention time.Duration) *PriceHistory {
+	if rawRetention <= 0 {
+		rawRetention = DefaultPriceRawRetention
+	}
+	if retention < rawRetention {
+		retention = rawRetention
+	}
+	return &PriceHistory{
+		store:        store,
+		rawRetention: rawRetention,
+		retention:    retention,
+		now:          time.Now,
+	}
+}
+
+// Record saves one listing of offers
+func (h *PriceHistory) Record(ctx context.Context, offers []models.GPUInstance) error {
+	if len(offers) == 0 {
+		return nil
+	}
+
+	now := h.now()
+	points := make([]PricePoint, 0, len(offers))
+	for _, offer := range offers {
+		price := offer.PricePerHour
+		if offer.GPUCount > 1 {
+			price /= float64(offer.GPUCount)
+		}
+		points = append(points, PricePoint{
+			Time:      now,
+			Provider:  offer.Provider,
+			GPUModel:  offer.GPUName,
+			GPUClass:  PriceClass(offer.GPUName),
+			Region:    offer.Location,
+			Available: offer.Available,
+			MinPrice:  price,
+			AvgPrice:  price,
+			MaxPrice:  price,
+			Samples:   1,
+		})
+	}
+
+	if err := h.store.Add(ctx, points); err != nil {
+		return fmt.Errorf("failed to record prices: %w", err)
+	}
+	return nil
+}
+
+// CompactReport summarizes a compaction
+type CompactReport struct {
+	RolledUp int `json:"rolled_up"` // Raw points folded into hourly buckets
+	Buckets  int `json:"buckets"`   // Hourly buckets written
+}
+
+// Compact rolls raw points older than the raw retention into hourly buckets
+// and drops buckets older than the retention
+func (h *PriceHistory) Compact(ctx context.Context) (CompactReport, error) {
+	var report CompactReport
+	now := h.now()
+
+	cutoff := now.Add(-h.rawRetention).Truncate(priceRollup)
+	points, err := h.store.List(ctx, PriceQuery{To: cutoff})
+	if err != nil {
+		return report, fmt.Errorf("failed to list prices: %w", err)
+	}
+
+	var raw []PricePoint
+	for _, p := range points {
+		if p.Resolution == 0 {
+			raw = append(raw, p)
+		}
+	}
+	if len(raw) > 0 {
+		buckets := rollUp(raw, priceRollup)
+		if err := h.store.Replace(ctx, 0, cutoff, buckets); err != nil {
+			return report, fmt.Errorf("failed to roll up prices: %w", err)
+		}
+		report.RolledUp = len(raw)
+		report.Buckets = len(buckets)
+	}
+
+	if err := h.store.Replace(ctx, priceRollup, now.Add(-h.retention), nil); err != nil {
+		return report, fmt.Errorf("failed to expire prices: %w", err)
+	}
+	return report, nil
+}
+
+// rollUp merges points into buckets of the given width, keeping provider,
+// model, class, region and availability apart
+func rollUp(points []PricePoint, width time.Duration) []PricePoint {
+	type key struct {
+		time                           time.Time
+		provider, model, class, region string
+		available                      bool
+	}
+
+	merged := make(map[key]*PricePoint)
+	var order []key
+	for _, p := range points {
+		k := key{p.Time.Truncate(width), p.Provider, p.GPUModel, p.GPUClass, p.Region, p.Available}
+		m, ok := merged[k]
+		if !ok {
+			bucket := p
+			bucket.Time = k.time
+			bucket.Resolution = width
+			merged[k] = &bucket
+			order = append(order, k)
+			continue
+		}
+		mergePrices(m, p)
+	}
+
+	buckets := make([]PricePoint, len(order))
+	for i, k := range order {
+		buckets[i] = *merged[k]
+	}
+	return buckets
+}
+
+// mergePrices folds p's prices into m
+func mergePrices(m *PricePoint, p PricePoint) {
+	m.MinPrice = math.Min(m.MinPrice, p.MinPrice)
+	m.MaxPrice = math.Max(m.MaxPrice, p.MaxPrice)
+	m.AvgPrice = (m.AvgPrice*float64(m.Samples) + p.AvgPrice*float64(p.Samples)) / float64(m.Samples+p.Samples)
+	m.Samples += p.Samples
+}
+
+// TrendPoint is the price of a GPU class over one bucket of a trend
+type TrendPoint struct {
+	Time         time.Time `json:"time"`
+	MinPrice     float64   `json:"min_price"`
+	AvgPrice     float64   `json:"avg_price"`
+	MaxPrice     float64   `json:"max_price"`
+	Offers       int       `json:"offers"`
+	Availability float64   `json:"availability"` // Share of offers that were rentable
+}
+
+// PriceTrend is the price of one GPU class over time, per GPU-hour
+type PriceTrend struct {
+	GPUClass    string        `json:"gpu_class"`
+	Bucket      time.Duration `json:"bucket"`
+	Points      []TrendPoint  `json:"points"`
+	LatestPrice float64       `json:"latest_price"` // Average in the last bucket
+	ChangePct   float64       `json:"change_pct"`   // From the first bucket's average to the last
+}
+
+// Trends returns the price trend of each GPU class matching q, bucketed by
+// bucket (an hour if zero)
+func (h *PriceHistory) Trends(ctx context.Context, q PriceQuery, bucket time.Duration) ([]PriceTrend, error) {
+	if bucket <= 0 {
+		bucket = priceRollup
+	}
+
+	points, err := h.store.List(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prices: %w", err)
+	}
+	if len(points) == 0 {
+		return nil, ErrNoPriceHistory
+	}
+
+	byClass := make(map[string][]PricePoint)
+	for _, p := range points {
+		byClass[p.GPUClass] = append(byClass[p.GPUClass], p)
+	}
+
+	trends := make([]PriceTrend, 0, len(byClass))
+	for class, classPoints := range byClass {
+		trends = append(trends, buildTrend(class, classPoints, bucket))
+	}
+	sort.Slice(trends, func(i, j int) bool { return trends[i].GPUClass < trends[j].GPUClass })
+	return trends, nil
+}
+
+func buildTrend(class string, points []PricePoint, bucket time.Duration) PriceTrend {
+	type acc struct {
+		merged    PricePoint
+		available int
+	}
+	buckets := make(map[time.Time]*acc)
+	for _, p := range points {
+		t := p.Time.Truncate(bucket)
+		a, ok := buckets[t]
+		if !ok {
+			a = &acc{merged: p}
+			buckets[t] = a
+		} else {
+			mergePrices(&a.merged, p)
+		}
+		if p.Available {
+			a.available += p.Samples
+		}
+	}
+
+	trend := PriceTrend{GPUClass: class, Bucket: bucket}
+	for t, a := range buckets {
+		trend.Points = append(trend.Points, TrendPoint{
+			Time:         t,
+			MinPrice:     a.merged.MinPrice,
+			AvgPrice:     a.merged.AvgPrice,
+			MaxPrice:     a.merged.MaxPrice,
+			Offers:       a.merged.Samples,
+			Availability: float64(a.available) / float64(a.merged.Samples),
+		})
+	}
+	sort.Slice(trend.Points, func(i, j int) bool { return trend.Points[i].Time.Before(trend.Points[j].Time) })
+
+	first, last := trend.Points[0], trend.Points[len(trend.Points)-1]
+	trend.LatestPrice = last.AvgPrice
+	if first.AvgPrice > 0 {
+		trend.ChangePct = (last.AvgPrice - first.AvgPrice) / first.AvgPrice * 100
+	}
+	return trend
+}
+
+// CostEstimateRequest describes a job to price
+type CostEstimateRequest struct {
+	GPUClass string        `json:"gpu_class"` // Any GPU name; classified with ClassifyGPU
+	Count    int           `json:"count"`     // GPUs; 0 means 1
+	Duration time.Duration `json:"duration"`
+	Provider string        `json:"provider,omitempty"`
+	Region   string        `json:"region,omitempty"`
+	Lookback time.Duration `json:"lookback,omitempty"` // History considered; a week if zero
+}
+
+// PriceWindow is a stretch of recent history during which a job would have
+// been cheapest
+type PriceWindow struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	PricePerGPUHour float64   `json:"price_per_gpu_hour"`
+	EstimatedCost   float64   `json:"estimated_cost"`
+	Savings         float64   `json:"savings"`    // Against running at the current price
+	NextStart       time.Time `json:"next_start"` // The same time of day, next
+}
+
+// CostEstimate prices a job at the cheapest rentable offers
+type CostEstimate struct {
+	GPUClass        string        `json:"gpu_class"`
+	Count           int           `json:"count"`
+	Duration        time.Duration `json:"duration"`
+	PricePerGPUHour float64       `json:"price_per_gpu_hour"` // Cheapest rentable offer in the latest hour with any
+	PricedAt        time.Time     `json:"priced_at"`
+	EstimatedCost   float64       `json:"estimated_cost"`
+	LowCost         float64       `json:"low_cost"`  // At the lowest hourly price over the lookback
+	HighCost        float64       `json:"high_cost"` // At the highest hourly price over the lookback
+	// CheapestWindow is set for jobs that fit in the lookback; flexible jobs
+	// can be scheduled for the same time of day
+	CheapestWindow *PriceWindow `json:"cheapest_window,omitempty"`
+}
+
+// EstimateCost prices a job from the cheapest rentable offer in each hour of
+// the lookback
+func (h *PriceHistory) EstimateCost(ctx context.Context, req CostEstimateRequest) (*CostEstimate, error) {
+	if req.GPUClass == "" {
+		return nil, fmt.Errorf("gpu_class is required")
+	}
+	if req.Duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive")
+	}
+	count := req.Count
+	if count < 1 {
+		count = 1
+	}
+	lookback := req.Lookback
+	if lookback <= 0 {
+		lookback = 7 * 24 * time.Hour
+	}
+
+	now := h.now()
+	class := PriceClass(req.GPUClass)
+	points, err := h.store.List(ctx, PriceQuery{
+		From:     now.Add(-lookback),
+		GPUClass: class,
+		Provider: req.Provider,
+		Region:   req.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prices: %w", err)
+	}
+
+	// Cheapest rentable price per hour
+	cheapest := make(map[time.Time]float64)
+	for _, p := range points {
+		if !p.Available {
+			continue
+		}
+		t := p.Time.Truncate(priceRollup)
+		if price, ok := cheapest[t]; !ok || p.MinPrice < price {
+			cheapest[t] = p.MinPrice
+		}
+	}
+	if len(cheapest) == 0 {
+		return nil, fmt.Errorf("%w for %s", ErrNoPriceHistory, class)
+	}
+
+	hours := make([]time.Time, 0, len(cheapest))
+	for t := range cheapest {
+		hours = append(hours, t)
+	}
+	sort.Slice(hours, func(i, j int) bool { return hours[i].Before(hours[j]) })
+
+	jobHours := req.Duration.Hours()
+	cost := func(price float64) float64 { return price * float64(count) * jobHours }
+
+	latest := hours[len(hours)-1]
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, price := range cheapest {
+		low, high = math.Min(low, price), math.Max(high, price)
+	}
+
+	estimate := &CostEstimate{
+		GPUClass:        class,
+		Count:           count,
+		Duration:        req.Duration,
+		PricePerGPUHour: cheapest[latest],
+		PricedAt:        latest,
+		EstimatedCost:   cost(cheapest[latest]),
+		LowCost:         cost(low),
+		HighCost:        cost(high),
+	}
+
+	if window := cheapestWindow(hours, cheapest, req.Duration); window != nil {
+		window.EstimatedCost = cost(window.PricePerGPUHour)
+		window.Savings = estimate.EstimatedCost - window.EstimatedCost
+		window.NextStart = nextTimeOfDay(window.Start, now)
+		estimate.CheapestWindow = window
+	}
+	return estimate, nil
+}
+
+// cheapestWindow finds the run of consecutive recorded hours long enough for
+// the job with the lowest average price. Runs with unrecorded hours are
+// skipped rather than guessed at.
+func cheapestWindow(hours []time.Time, prices map[time.Time]float64, duration time.Duration) *PriceWindow {
+	n := int(math.Ceil(duration.Hours()))
+	var best *PriceWindow
+
+	for i := 0; i+n <= len(hours); i++ {
+		start, end := hours[i], hours[i+n-1]
+		if end.Sub(start) != time.Duration(n-1)*priceRollup {
+			continue // Gap in the history
+		}
+		sum := 0.0
+		for _, t := range hours[i : i+n] {
+			sum += prices[t]
+		}
+		avg := sum / float64(n)
+		if best == nil || avg < best.PricePerGPUHour {
+			best = &PriceWindow{Start: start, End: start.Add(duration), PricePerGPUHour: avg}
+		}
+	}
+	return best
+}
+
+// nextTimeOfDay returns the first time after now at t's time of day (UTC)
+func nextTimeOfDay(t, now time.Time) time.Time {
+	t, now = t.UTC(), now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	for !next.After(now) {
+		next = next.Add(24 * time.Hour)
+	}
+	return next
+}
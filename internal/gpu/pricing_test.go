@@ -0,0 +1,243 @@
+package gpu
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/database"
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var priceEpoch = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+func newTestPriceHistory(t *testing.T, store PriceStore) (*PriceHistory, *fakeClock) {
+	t.Helper()
+	h := NewPriceHistory(store, 48*time.Hour, 30*24*time.Hour)
+	clock := &fakeClock{t: priceEpoch}
+	h.now = clock.now
+	return h, clock
+}
+
+func offer(provider, name string, gpus int, price float64, region string, available bool) models.GPUInstance {
+	return models.GPUInstance{Provider: provider, GPUName: name, GPUCount: gpus, PricePerHour: price, Location: region, Available: available}
+}
+
+func TestClassifyGPUPrefersLongestMatch(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		group, ok := ClassifyGPU("Tesla V100-SXM2-32GB")
+		require.True(t, ok)
+		assert.Equal(t, "Tesla V100", group.Model)
+	}
+	assert.Equal(t, "A100", PriceClass("NVIDIA A100-SXM4-80GB"))
+	assert.Equal(t, "Mystery GPU", PriceClass("Mystery GPU"))
+}
+
+func TestPriceHistoryRecord(t *testing.T) {
+	store := NewMemoryPriceStore()
+	h, _ := newTestPriceHistory(t, store)
+	ctx := context.Background()
+
+	require.NoError(t, h.Record(ctx, []models.GPUInstance{
+		offer("vast.ai", "NVIDIA A100-SXM4-80GB", 4, 8.0, "us-east", true),
+		offer("io.net", "RTX 4090", 1, 0.4, "eu-west", false),
+	}))
+	require.NoError(t, h.Record(ctx, nil))
+
+	points, err := store.List(ctx, PriceQuery{})
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, PricePoint{
+		Time: priceEpoch, Provider: "vast.ai", GPUModel: "NVIDIA A100-SXM4-80GB", GPUClass: "A100", Region: "us-east",
+		Available: true, MinPrice: 2.0, AvgPrice: 2.0, MaxPrice: 2.0, Samples: 1,
+	}, points[0], "prices are per GPU-hour")
+	assert.False(t, points[1].Available)
+}
+
+func testCompaction(t *testing.T, store PriceStore) {
+	h, clock := newTestPriceHistory(t, store)
+	ctx := context.Background()
+
+	// Two listings in one hour, one in the next
+	require.NoError(t, h.Record(ctx, []models.GPUInstance{offer("vast.ai", "A100", 1, 1.0, "us", true), offer("vast.ai", "A100", 1, 3.0, "us", true)}))
+	clock.advance(30 * time.Minute)
+	require.NoError(t, h.Record(ctx, []models.GPUInstance{offer("vast.ai", "A100", 1, 2.0, "us", true), offer("vast.ai", "A100", 1, 9.0, "us", false)}))
+	clock.advance(time.Hour)
+	require.NoError(t, h.Record(ctx, []models.GPUInstance{offer("vast.ai", "A100", 1, 4.0, "us", true)}))
+
+	report, err := h.Compact(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, report.RolledUp, "nothing is older than the raw retention yet")
+
+	clock.advance(48 * time.Hour)
+	report, err = h.Compact(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, CompactReport{RolledUp: 4, Buckets: 2}, report, "the last hour is not complete past the cutoff")
+
+	points, err := store.List(ctx, PriceQuery{})
+	require.NoError(t, err)
+	require.Len(t, points, 3)
+	assert.Equal(t, time.Hour, points[0].Resolution)
+	assert.True(t, points[0].Time.Equal(priceEpoch))
+	var rentable PricePoint
+	for _, p := range points[:2] {
+		if p.Available {
+			rentable = p
+		}
+	}
+	assert.Equal(t, 1.0, rentable.MinPrice)
+	assert.Equal(t, 2.0, rentable.AvgPrice)
+	assert.Equal(t, 3.0, rentable.MaxPrice)
+	assert.Equal(t, 3, rentable.Samples)
+	assert.Equal(t, time.Duration(0), points[2].Resolution)
+
+	// Hourly prices are dropped after the retention
+	clock.advance(30*24*time.Hour - 49*time.Hour)
+	_, err = h.Compact(ctx)
+	require.NoError(t, err)
+	points, err = store.List(ctx, PriceQuery{})
+	require.NoError(t, err)
+	require.Len(t, points, 1, "only the bucket rolled up from the last listing is left")
+	assert.True(t, points[0].Time.Equal(priceEpoch.Add(time.Hour)))
+}
+
+func TestPriceHistoryCompactMemory(t *testing.T) {
+	testCompaction(t, NewMemoryPriceStore())
+}
+
+func TestPriceHistoryCompactSQLite(t *testing.T) {
+	db, err := database.NewSQLiteDB(filepath.Join(t.TempDir(), "prices.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Migrate())
+	testCompaction(t, NewSQLPriceStore(db.SQLDB()))
+}
+
+func TestOpenPriceStore(t *testing.T) {
+	store, err := OpenPriceStore("memory", nil)
+	require.NoError(t, err)
+	assert.IsType(t, &MemoryPriceStore{}, store)
+
+	_, err = OpenPriceStore("database", nil)
+	assert.Error(t, err)
+	_, err = OpenPriceStore("sqlite:///tmp/prices.db", nil)
+	assert.Error(t, err)
+}
+
+func TestPriceTrends(t *testing.T) {
+	h, clock := newTestPriceHistory(t, NewMemoryPriceStore())
+	ctx := context.Background()
+
+	for _, prices := range [][]float64{{2.0, 4.0}, {2.5, 3.5}, {1.5, 2.5}} {
+		require.NoError(t, h.Record(ctx, []models.GPUInstance{
+			offer("vast.ai", "A100", 1, prices[0], "us", true),
+			offer("io.net", "A100 80GB", 1, prices[1], "eu", false),
+			offer("io.net", "H100", 2, 6.0, "eu", true),
+		}))
+		clock.advance(time.Hour)
+	}
+
+	trends, err := h.Trends(ctx, PriceQuery{}, 0)
+	require.NoError(t, err)
+	require.Len(t, trends, 2)
+	a100 := trends[0]
+	assert.Equal(t, "A100", a100.GPUClass)
+	require.Len(t, a100.Points, 3)
+	assert.Equal(t, TrendPoint{Time: priceEpoch, MinPrice: 2.0, AvgPrice: 3.0, MaxPrice: 4.0, Offers: 2, Availability: 0.5}, a100.Points[0])
+	assert.Equal(t, 2.0, a100.LatestPrice)
+	assert.InDelta(t, -33.33, a100.ChangePct, 0.01)
+	assert.Equal(t, "H100", trends[1].GPUClass)
+	assert.Equal(t, 3.0, trends[1].LatestPrice)
+
+	trends, err = h.Trends(ctx, PriceQuery{GPUClass: "A100", Provider: "vast.ai"}, 2*time.Hour)
+	require.NoError(t, err)
+	require.Len(t, trends, 1)
+	require.Len(t, trends[0].Points, 2)
+	assert.Equal(t, 2.25, trends[0].Points[0].AvgPrice)
+
+	_, err = h.Trends(ctx, PriceQuery{GPUClass: "T4"}, 0)
+	assert.ErrorIs(t, err, ErrNoPriceHistory)
+}
+
+func TestEstimateCost(t *testing.T) {
+	h, clock := newTestPriceHistory(t, NewMemoryPriceStore())
+	ctx := context.Background()
+
+	// A day of hourly listings: A100s are cheap from 02:00 to 05:00
+	for hour := 0; hour < 24; hour++ {
+		price := 2.0
+		if hour >= 2 && hour < 5 {
+			price = 1.0
+		}
+		if hour != 12 { // Nobody listed at noon
+			require.NoError(t, h.Record(ctx, []models.GPUInstance{
+				offer("vast.ai", "A100", 1, price, "us", true),
+				offer("vast.ai", "A100", 1, price/2, "us", false), // Cheaper, but taken
+			}))
+		}
+		clock.advance(time.Hour)
+	}
+	require.NoError(t, h.Record(ctx, []models.GPUInstance{offer("vast.ai", "A100", 2, 5.0, "us", true)}))
+	clock.advance(10 * time.Minute)
+
+	estimate, err := h.EstimateCost(ctx, CostEstimateRequest{GPUClass: "nvidia a100", Count: 2, Duration: 3 * time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, "A100", estimate.GPUClass)
+	assert.Equal(t, 2.5, estimate.PricePerGPUHour)
+	assert.True(t, estimate.PricedAt.Equal(priceEpoch.Add(24*time.Hour)))
+	assert.Equal(t, 15.0, estimate.EstimatedCost)
+	assert.Equal(t, 6.0, estimate.LowCost)
+	assert.Equal(t, 15.0, estimate.HighCost)
+
+	window := estimate.CheapestWindow
+	require.NotNil(t, window)
+	assert.True(t, window.Start.Equal(priceEpoch.Add(2*time.Hour)))
+	assert.True(t, window.End.Equal(priceEpoch.Add(5*time.Hour)))
+	assert.Equal(t, 1.0, window.PricePerGPUHour)
+	assert.Equal(t, 6.0, window.EstimatedCost)
+	assert.Equal(t, 9.0, window.Savings)
+	assert.True(t, window.NextStart.Equal(priceEpoch.Add(26*time.Hour)), "02:00 tomorrow")
+
+	// Windows spanning unrecorded hours are not suggested
+	estimate, err = h.EstimateCost(ctx, CostEstimateRequest{GPUClass: "A100", Duration: 10 * time.Hour})
+	require.NoError(t, err)
+	require.NotNil(t, estimate.CheapestWindow)
+	assert.True(t, estimate.CheapestWindow.Start.Equal(priceEpoch))
+	estimate, err = h.EstimateCost(ctx, CostEstimateRequest{GPUClass: "A100", Duration: 13 * time.Hour})
+	require.NoError(t, err)
+	assert.Nil(t, estimate.CheapestWindow, "no 13 recorded hours in a row")
+
+	_, err = h.EstimateCost(ctx, CostEstimateRequest{GPUClass: "H100", Duration: time.Hour})
+	assert.ErrorIs(t, err, ErrNoPriceHistory)
+	_, err = h.EstimateCost(ctx, CostEstimateRequest{GPUClass: "A100"})
+	assert.ErrorContains(t, err, "duration must be positive")
+	_, err = h.EstimateCost(ctx, CostEstimateRequest{GPUClass: "A100", Duration: time.Hour, Lookback: time.Minute})
+	assert.ErrorIs(t, err, ErrNoPriceHistory, "nothing listed in the last minute")
+}
+
+func TestServiceSamplesPrices(t *testing.T) {
+	fake, err := NewFakeMarketplace(config.FakeMarketplaceConfig{})
+	require.NoError(t, err)
+	registry := NewRegistry()
+	registry.Register(fake)
+	s := NewServiceWithRegistry(&config.GPUConfig{}, registry)
+	h, _ := newTestPriceHistory(t, NewMemoryPriceStore())
+	s.SetPriceHistory(h)
+	ctx := context.Background()
+
+	_, err = s.ListInstances(ctx, ProviderAll)
+	require.NoError(t, err)
+	points, err := h.store.List(ctx, PriceQuery{GPUClass: "H100"})
+	require.NoError(t, err)
+	assert.Empty(t, points, "listings are not recorded")
+
+	require.NoError(t, s.samplePrices(ctx))
+	points, err = h.store.List(ctx, PriceQuery{GPUClass: "H100"})
+	require.NoError(t, err)
+	assert.Len(t, points, 2)
+	assert.Equal(t, "fake", points[0].Provider)
+}
@@ -27,6 +27,7 @@ type ProxyRequest struct {
 	Body           interface{}            `json:"body"`
 	Timeout        time.Duration          `json:"timeout"`
 	StreamResponse bool                   `json:"stream_response"`
+	InstanceID     string                 `json:"instance_id,omitempty"` // Reserved instance behind TargetURL, for idleness and health tracking
 }
 
 type ProxyResponse struct {
@@ -37,6 +38,18 @@ type ProxyResponse struct {
 	Error      string                 `json:"error,omitempty"`
 }
 
+// InstanceFailure returns why the instance behind a proxied request failed
+// it, or nil if the instance answered, even with a client error
+func (r *ProxyResponse) InstanceFailure() error {
+	if r.StatusCode < 500 {
+		return nil
+	}
+	if r.Error != "" {
+		return fmt.Errorf("%s", r.Error)
+	}
+	return fmt.Errorf("status %d", r.StatusCode)
+}
+
 type MCPRequest struct {
 	Method string                 `json:"method"`
 	Params map[string]interface{} `json:"params"`
@@ -111,8 +124,13 @@ func (h *ProtocolHandler) handleHTTPRequest(ctx context.Context, proxyReq *Proxy
 	start := time.Now()
 
 	var bodyReader io.Reader
-	if proxyReq.Body != nil {
-		bodyBytes, err := json.Marshal(proxyReq.Body)
+	switch body := proxyReq.Body.(type) {
+	case nil:
+	case []byte:
+		// Raw bodies (e.g. from gRPC) are sent as-is
+		bodyReader = bytes.NewReader(body)
+	default:
+		bodyBytes, err := json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal body: %w", err)
 		}
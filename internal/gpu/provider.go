@@ -0,0 +1,116 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+)
+
+// ErrStatusNotSupported is returned by providers that cannot report instance status
+var ErrStatusNotSupported = errors.New("status check not supported")
+
+// ErrInstanceNotFound is returned when a provider has no record of an instance
+var ErrInstanceNotFound = errors.New("instance not found")
+
+// GPUProvider is a marketplace or cloud that GPU instances can be rented from
+type GPUProvider interface {
+	// Name identifies the provider, e.g. "vast.ai". Listed offers carry it as
+	// their Provider.
+	Name() Provider
+
+	// ListOffers returns the instances currently available to rent
+	ListOffers(ctx context.Context) ([]models.GPUInstance, error)
+
+	// CreateInstance rents the offer with the given ID and returns the ID of
+	// the created instance. Offer IDs are accepted as returned by ListOffers.
+	CreateInstance(ctx context.Context, offerID string, config map[string]interface{}) (string, error)
+
+	// DestroyInstance releases a rented instance
+	DestroyInstance(ctx context.Context, instanceID string) error
+
+	// InstanceStatus returns the provider's status for a rented instance
+	InstanceStatus(ctx context.Context, instanceID string) (string, error)
+}
+
+// InstanceLister is implemented by providers that can enumerate the instances
+// currently rented on our account, so reconciliation can find orphans
+type InstanceLister interface {
+	ListRentedInstances(ctx context.Context) ([]string, error)
+}
+
+// Registry holds the GPU providers available to the service
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[Provider]GPUProvider
+}
+
+// NewRegistry creates an empty provider registry
+func NewRegistry() *Registry {
+	return &Registry{providers: make(map[Provider]GPUProvider)}
+}
+
+// Register adds a provider. Names must be unique and "all" is reserved.
+func (r *Registry) Register(p GPUProvider) error {
+	name := p.Name()
+	if name == "" || name == ProviderAll {
+		return fmt.Errorf("invalid provider name %q", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.providers[name]; exists {
+		return fmt.Errorf("provider %s already registered", name)
+	}
+	r.providers[name] = p
+	return nil
+}
+
+// Unregister removes a provider, reporting whether it was registered
+func (r *Registry) Unregister(name Provider) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, exists := r.providers[name]
+	delete(r.providers, name)
+	return exists
+}
+
+// Get returns the provider registered under name
+func (r *Registry) Get(name Provider) (GPUProvider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// Names returns the registered provider names in sorted order
+func (r *Registry) Names() []Provider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]Provider, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// All returns the registered providers ordered by name
+func (r *Registry) All() []GPUProvider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]GPUProvider, 0, len(r.providers))
+	for _, p := range r.providers {
+		all = append(all, p)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name() < all[j].Name() })
+	return all
+}
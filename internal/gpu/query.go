@@ -0,0 +1,745 @@
+package gpu
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+)
+
+// OfferQuery is a parsed offer search such as
+//
+//	vram>=80 gpu in (H100, A100) price<2.5 region=us-* reliability>0.95 order by price_per_tflop
+//
+// Conditions are ANDed together (an explicit "and" is allowed). Text is
+// compared case-insensitively and may use * as a wildcard. An offer missing
+// a value a condition needs, such as reliability on a provider that does not
+// report it, never matches, and sorts last.
+type OfferQuery struct {
+	conditions []queryCondition
+	order      []queryOrder
+	limit      int
+}
+
+type queryCondition struct {
+	field   string
+	op      string
+	values  []string  // As written, lowercased for text fields
+	numbers []float64 // Parsed values of number and boolean fields
+}
+
+type queryOrder struct {
+	field string
+	desc  bool
+}
+
+// QueryError reports where and why a query failed to parse
+type QueryError struct {
+	Query  string
+	Column int // 1-based
+	Msg    string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query at column %d: %s", e.Column, e.Msg)
+}
+
+type queryFieldKind int
+
+const (
+	textField queryFieldKind = iota
+	numberField
+	boolField
+)
+
+type queryField struct {
+	kind   queryFieldKind
+	text   func(models.GPUInstance) []string
+	number func(models.GPUInstance) (float64, bool) // Booleans are 1 or 0
+}
+
+var queryFields = map[string]queryField{
+	"id":       {kind: textField, text: func(i models.GPUInstance) []string { return []string{i.ID} }},
+	"provider": {kind: textField, text: func(i models.GPUInstance) []string { return []string{i.Provider} }},
+	"gpu": {kind: textField, text: func(i models.GPUInstance) []string {
+		// The listed name or its class, so "gpu=A100" matches "NVIDIA A100-SXM4-80GB"
+		names := []string{i.GPUName}
+		if group, ok := ClassifyGPU(i.GPUName); ok {
+			names = append(names, group.Model)
+		}
+		return names
+	}},
+	"vendor": {kind: textField, text: func(i models.GPUInstance) []string {
+		group, _ := ClassifyGPU(i.GPUName)
+		return []string{string(group.Vendor)}
+	}},
+	"tier": {kind: textField, text: func(i models.GPUInstance) []string {
+		group, _ := ClassifyGPU(i.GPUName)
+		return []string{string(group.Tier)}
+	}},
+	"region": {kind: textField, text: func(i models.GPUInstance) []string { return []string{i.Location} }},
+	"datacenter": {kind: textField, text: func(i models.GPUInstance) []string {
+		if dc, _ := i.Specifications["datacenter"].(string); dc != "" {
+			return []string{dc}
+		}
+		return nil
+	}},
+	"gpus":    {kind: numberField, number: func(i models.GPUInstance) (float64, bool) { return float64(i.GPUCount), true }},
+	"vram":    {kind: numberField, number: func(i models.GPUInstance) (float64, bool) { return float64(i.VRAM), true }},
+	"cpus":    {kind: numberField, number: func(i models.GPUInstance) (float64, bool) { return float64(i.CPUCores), true }},
+	"ram":     {kind: numberField, number: func(i models.GPUInstance) (float64, bool) { return float64(i.RAM), true }},
+	"storage": {kind: numberField, number: func(i models.GPUInstance) (float64, bool) { return float64(i.Storage), true }},
+	"price":   {kind: numberField, number: func(i models.GPUInstance) (float64, bool) { return i.PricePerHour, true }},
+	"price_per_gpu": {kind: numberField, number: func(i models.GPUInstance) (float64, bool) {
+		return i.PricePerHour / float64(max(i.GPUCount, 1)), true
+	}},
+	"reliability": {kind: numberField, number: func(i models.GPUInstance) (float64, bool) {
+		return specNumber(i.Specifications["reliability"])
+	}},
+	"tflops": {kind: numberField, number: offerTFLOPS},
+	"price_per_tflop": {kind: numberField, number: func(i models.GPUInstance) (float64, bool) {
+		tflops, ok := offerTFLOPS(i)
+		if !ok {
+			return 0, false
+		}
+		return i.PricePerHour / tflops, true
+	}},
+	"available": {kind: boolField, number: func(i models.GPUInstance) (float64, bool) {
+		if i.Available {
+			return 1, true
+		}
+		return 0, true
+	}},
+}
+
+// queryAliases maps the GPUInstance JSON names and older filter names onto fields
+var queryAliases = map[string]string{
+	"gpu_name":       "gpu",
+	"gpu_model":      "gpu",
+	"model":          "gpu",
+	"location":       "region",
+	"gpu_count":      "gpus",
+	"vram_gb":        "vram",
+	"cpu_cores":      "cpus",
+	"ram_gb":         "ram",
+	"storage_gb":     "storage",
+	"disk":           "storage",
+	"price_per_hour": "price",
+}
+
+// OfferQueryFields returns the names of the fields a query can use
+func OfferQueryFields() []string {
+	names := make([]string, 0, len(queryFields))
+	for name := range queryFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// offerTFLOPS is the offer's total FP16 TFLOPS, from the GPU catalog
+func offerTFLOPS(i models.GPUInstance) (float64, bool) {
+	group, ok := ClassifyGPU(i.GPUName)
+	if !ok || group.TFLOPS <= 0 {
+		return 0, false
+	}
+	return group.TFLOPS * float64(max(i.GPUCount, 1)), true
+}
+
+func specNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// ParseOfferQuery parses and validates an offer search. An empty query
+// matches every offer.
+func ParseOfferQuery(s string) (*OfferQuery, error) {
+	tokens, err := lexQuery(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{src: s, tokens: tokens}
+	q := &OfferQuery{}
+	for p.peek().kind != tokEOF {
+		tok := p.peek()
+		switch {
+		case p.keyword(tok, "and"):
+			p.next()
+			if next := p.peek(); next.kind == tokEOF || p.keyword(next, "and") || p.keyword(next, "order") || p.keyword(next, "limit") {
+				return nil, p.errorf(next, "expected a condition after \"and\", got %s", describeToken(next))
+			}
+		case p.keyword(tok, "order"):
+			if err := p.parseOrder(q); err != nil {
+				return nil, err
+			}
+		case p.keyword(tok, "limit"):
+			if err := p.parseLimit(q); err != nil {
+				return nil, err
+			}
+		default:
+			if len(q.order) > 0 || q.limit > 0 {
+				return nil, p.errorf(tok, "conditions must come before order by and limit")
+			}
+			cond, err := p.parseCondition()
+			if err != nil {
+				return nil, err
+			}
+			q.conditions = append(q.conditions, cond)
+		}
+	}
+	return q, nil
+}
+
+// FiltersQuery converts the min_vram, max_price, gpu_model and location
+// filters of the older list and reserve APIs into a query. Filters of the
+// wrong type are ignored, as they always were.
+func FiltersQuery(filters map[string]interface{}) *OfferQuery {
+	q := &OfferQuery{}
+	if v, ok := specNumber(filters["min_vram"]); ok {
+		q.conditions = append(q.conditions, numberCondition("vram", ">=", v))
+	}
+	if v, ok := specNumber(filters["max_price"]); ok {
+		q.conditions = append(q.conditions, numberCondition("price", "<=", v))
+	}
+	if v, ok := filters["gpu_model"].(string); ok && v != "" {
+		q.conditions = append(q.conditions, queryCondition{field: "gpu", op: "=", values: []string{strings.ToLower(v)}})
+	}
+	if v, ok := filters["location"].(string); ok && v != "" {
+		q.conditions = append(q.conditions, queryCondition{field: "region", op: "=", values: []string{strings.ToLower(v)}})
+	}
+	return q
+}
+
+func numberCondition(field, op string, v float64) queryCondition {
+	return queryCondition{field: field, op: op, values: []string{strconv.FormatFloat(v, 'f', -1, 64)}, numbers: []float64{v}}
+}
+
+// And returns a query matching the offers both q and other match. It is
+// ordered and limited as q, or as other where q is not.
+func (q *OfferQuery) And(other *OfferQuery) *OfferQuery {
+	if q == nil {
+		return other
+	}
+	if other == nil {
+		return q
+	}
+
+	combined := &OfferQuery{order: q.order, limit: q.limit}
+	combined.conditions = append(append(combined.conditions, q.conditions...), other.conditions...)
+	if len(combined.order) == 0 {
+		combined.order = other.order
+	}
+	if combined.limit == 0 {
+		combined.limit = other.limit
+	}
+	return combined
+}
+
+// Empty reports whether q matches every offer in listing order
+func (q *OfferQuery) Empty() bool {
+	return q == nil || len(q.conditions) == 0 && len(q.order) == 0 && q.limit == 0
+}
+
+// Matches reports whether an offer meets every condition of q
+func (q *OfferQuery) Matches(inst models.GPUInstance) bool {
+	if q == nil {
+		return true
+	}
+	for _, c := range q.conditions {
+		if !c.matches(inst) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the matching offers, sorted and limited as q says
+func (q *OfferQuery) Apply(instances []models.GPUInstance) []models.GPUInstance {
+	var list []models.GPUInstance
+	for _, inst := range instances {
+		if q.Matches(inst) {
+			list = append(list, inst)
+		}
+	}
+	if q == nil {
+		return list
+	}
+
+	if len(q.order) > 0 {
+		sort.SliceStable(list, func(i, j int) bool { return q.less(list[i], list[j]) })
+	}
+	if q.limit > 0 && len(list) > q.limit {
+		list = list[:q.limit]
+	}
+	return list
+}
+
+// String returns q in canonical form, which parses back to the same query
+func (q *OfferQuery) String() string {
+	if q == nil {
+		return ""
+	}
+
+	var parts []string
+	for _, c := range q.conditions {
+		values := make([]string, len(c.values))
+		for i, v := range c.values {
+			values[i] = quoteQueryValue(v)
+		}
+		if c.op == "in" || c.op == "not in" {
+			parts = append(parts, fmt.Sprintf("%s %s (%s)", c.field, c.op, strings.Join(values, ", ")))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s %s %s", c.field, c.op, values[0]))
+		}
+	}
+	if len(q.order) > 0 {
+		keys := make([]string, len(q.order))
+		for i, o := range q.order {
+			keys[i] = o.field
+			if o.desc {
+				keys[i] += " desc"
+			}
+		}
+		parts = append(parts, "order by "+strings.Join(keys, ", "))
+	}
+	if q.limit > 0 {
+		parts = append(parts, "limit "+strconv.Itoa(q.limit))
+	}
+	return strings.Join(parts, " ")
+}
+
+func quoteQueryValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\n()=,!<>\"'") {
+		return v
+	}
+	if strings.Contains(v, `"`) {
+		return "'" + v + "'"
+	}
+	return `"` + v + `"`
+}
+
+func (c queryCondition) matches(inst models.GPUInstance) bool {
+	f := queryFields[c.field]
+	if f.kind == textField {
+		texts := f.text(inst)
+		if len(texts) == 0 {
+			return false
+		}
+		found := false
+		for _, t := range texts {
+			for _, v := range c.values {
+				if globMatch(v, strings.ToLower(t)) {
+					found = true
+				}
+			}
+		}
+		if c.op == "=" || c.op == "in" {
+			return found
+		}
+		return !found
+	}
+
+	v, ok := f.number(inst)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "=":
+		return v == c.numbers[0]
+	case "!=":
+		return v != c.numbers[0]
+	case "<":
+		return v < c.numbers[0]
+	case "<=":
+		return v <= c.numbers[0]
+	case ">":
+		return v > c.numbers[0]
+	case ">=":
+		return v >= c.numbers[0]
+	}
+	found := false
+	for _, n := range c.numbers {
+		if v == n {
+			found = true
+		}
+	}
+	return found == (c.op == "in")
+}
+
+// less orders offers by q's sort keys, with missing values last
+func (q *OfferQuery) less(a, b models.GPUInstance) bool {
+	for _, o := range q.order {
+		f := queryFields[o.field]
+		if f.kind == textField {
+			at, bt := f.text(a), f.text(b)
+			if (len(at) > 0) != (len(bt) > 0) {
+				return len(at) > 0
+			}
+			if len(at) == 0 {
+				continue
+			}
+			x, y := strings.ToLower(at[0]), strings.ToLower(bt[0])
+			if x != y {
+				return (x < y) != o.desc
+			}
+			continue
+		}
+
+		x, xok := f.number(a)
+		y, yok := f.number(b)
+		if xok != yok {
+			return xok
+		}
+		if xok && x != y {
+			return (x < y) != o.desc
+		}
+	}
+	return false
+}
+
+// globMatch matches s against a pattern in which * matches any run of
+// characters
+func globMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+type queryTokenKind int
+
+const (
+	tokWord queryTokenKind = iota
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+	tokEOF
+)
+
+type queryToken struct {
+	kind queryTokenKind
+	text string
+	pos  int // Byte offset in the query
+}
+
+func lexQuery(s string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, queryToken{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, queryToken{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, queryToken{kind: tokComma, text: ",", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, &QueryError{Query: s, Column: i + 1, Msg: fmt.Sprintf("unterminated string; add a closing %c", c)}
+			}
+			tokens = append(tokens, queryToken{kind: tokString, text: s[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case c == '=' || c == '<' || c == '>' || c == '!':
+			op := string(c)
+			if i+1 < len(s) && s[i+1] == '=' {
+				op += "="
+			}
+			width := len(op)
+			switch op {
+			case "!":
+				return nil, &QueryError{Query: s, Column: i + 1, Msg: "expected \"!=\""}
+			case "==":
+				op = "="
+			}
+			tokens = append(tokens, queryToken{kind: tokOp, text: op, pos: i})
+			i += width
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n\r()=,!<>\"'", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, queryToken{kind: tokWord, text: s[start:i], pos: start})
+		}
+	}
+	return append(tokens, queryToken{kind: tokEOF, pos: len(s)}), nil
+}
+
+type queryParser struct {
+	src    string
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *queryParser) keyword(tok queryToken, kw string) bool {
+	return tok.kind == tokWord && strings.EqualFold(tok.text, kw)
+}
+
+func (p *queryParser) errorf(tok queryToken, format string, args ...interface{}) *QueryError {
+	return &QueryError{Query: p.src, Column: tok.pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func describeToken(tok queryToken) string {
+	switch tok.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return strconv.Quote(tok.text)
+	}
+	return fmt.Sprintf("%q", tok.text)
+}
+
+// field resolves a field name or alias
+func (p *queryParser) field(tok queryToken) (string, queryField, error) {
+	if tok.kind != tokWord {
+		return "", queryField{}, p.errorf(tok, "expected a field name, got %s", describeToken(tok))
+	}
+
+	name := strings.ToLower(tok.text)
+	if alias, ok := queryAliases[name]; ok {
+		name = alias
+	}
+	if f, ok := queryFields[name]; ok {
+		return name, f, nil
+	}
+
+	if suggestion := suggestQueryField(name); suggestion != "" {
+		return "", queryField{}, p.errorf(tok, "unknown field %q; did you mean %q?", tok.text, suggestion)
+	}
+	return "", queryField{}, p.errorf(tok, "unknown field %q; fields are %s", tok.text, strings.Join(OfferQueryFields(), ", "))
+}
+
+func (p *queryParser) parseCondition() (queryCondition, error) {
+	nameTok := p.next()
+	name, f, err := p.field(nameTok)
+	if err != nil {
+		return queryCondition{}, err
+	}
+
+	opTok := p.next()
+	var op string
+	switch {
+	case opTok.kind == tokOp:
+		op = opTok.text
+	case p.keyword(opTok, "in"):
+		op = "in"
+	case p.keyword(opTok, "not"):
+		if inTok := p.next(); !p.keyword(inTok, "in") {
+			return queryCondition{}, p.errorf(inTok, "expected \"in\" after \"not\", got %s", describeToken(inTok))
+		}
+		op = "not in"
+	default:
+		return queryCondition{}, p.errorf(opTok, "expected an operator after %q (=, !=, <, <=, >, >=, in or not in), got %s",
+			nameTok.text, describeToken(opTok))
+	}
+
+	var values []queryToken
+	if op == "in" || op == "not in" {
+		values, err = p.parseList(op)
+		if err != nil {
+			return queryCondition{}, err
+		}
+	} else {
+		v := p.next()
+		if v.kind != tokWord && v.kind != tokString {
+			return queryCondition{}, p.errorf(v, "expected a value after %q, got %s", op, describeToken(v))
+		}
+		values = []queryToken{v}
+	}
+
+	cond := queryCondition{field: name, op: op}
+	switch f.kind {
+	case textField:
+		if op != "=" && op != "!=" && op != "in" && op != "not in" {
+			return queryCondition{}, p.errorf(opTok, "%s is text and can only be compared with =, !=, in or not in", name)
+		}
+		for _, v := range values {
+			cond.values = append(cond.values, strings.ToLower(v.text))
+		}
+	case numberField:
+		for _, v := range values {
+			n, err := strconv.ParseFloat(v.text, 64)
+			if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+				return queryCondition{}, p.errorf(v, "%s is a number, got %s", name, describeToken(v))
+			}
+			cond.values = append(cond.values, v.text)
+			cond.numbers = append(cond.numbers, n)
+		}
+	case boolField:
+		if op != "=" && op != "!=" {
+			return queryCondition{}, p.errorf(opTok, "%s is true or false and can only be compared with = or !=", name)
+		}
+		b, err := strconv.ParseBool(values[0].text)
+		if err != nil {
+			return queryCondition{}, p.errorf(values[0], "%s is true or false, got %s", name, describeToken(values[0]))
+		}
+		cond.values = []string{strconv.FormatBool(b)}
+		cond.numbers = []float64{0}
+		if b {
+			cond.numbers[0] = 1
+		}
+	}
+	return cond, nil
+}
+
+func (p *queryParser) parseList(op string) ([]queryToken, error) {
+	if open := p.next(); open.kind != tokLParen {
+		return nil, p.errorf(open, "expected a list after %q, e.g. gpu %s (H100, A100)", op, op)
+	}
+
+	var values []queryToken
+	for {
+		v := p.next()
+		if v.kind == tokRParen && len(values) == 0 {
+			return nil, p.errorf(v, "empty list")
+		}
+		if v.kind != tokWord && v.kind != tokString {
+			return nil, p.errorf(v, "expected a list value, got %s", describeToken(v))
+		}
+		values = append(values, v)
+
+		switch sep := p.next(); sep.kind {
+		case tokComma:
+		case tokRParen:
+			return values, nil
+		case tokEOF:
+			return nil, p.errorf(sep, "missing \")\" to close the list")
+		default:
+			return nil, p.errorf(sep, "expected \",\" or \")\" in list, got %s", describeToken(sep))
+		}
+	}
+}
+
+func (p *queryParser) parseOrder(q *OfferQuery) error {
+	orderTok := p.next()
+	if len(q.order) > 0 {
+		return p.errorf(orderTok, "order by given twice")
+	}
+	if by := p.next(); !p.keyword(by, "by") {
+		return p.errorf(by, "expected \"by\" after \"order\", got %s", describeToken(by))
+	}
+
+	for {
+		name, _, err := p.field(p.next())
+		if err != nil {
+			return err
+		}
+		o := queryOrder{field: name}
+		if tok := p.peek(); p.keyword(tok, "desc") {
+			o.desc = true
+			p.next()
+		} else if p.keyword(tok, "asc") {
+			p.next()
+		}
+		q.order = append(q.order, o)
+
+		if p.peek().kind != tokComma {
+			return nil
+		}
+		p.next()
+	}
+}
+
+func (p *queryParser) parseLimit(q *OfferQuery) error {
+	limitTok := p.next()
+	if q.limit > 0 {
+		return p.errorf(limitTok, "limit given twice")
+	}
+	v := p.next()
+	n, err := strconv.Atoi(v.text)
+	if v.kind != tokWord || err != nil || n < 1 {
+		return p.errorf(v, "limit must be a positive whole number, got %s", describeToken(v))
+	}
+	q.limit = n
+	return nil
+}
+
+// suggestQueryField returns the field closest to a misspelt name, if any is
+// close enough to be a likely typo
+func suggestQueryField(name string) string {
+	best, bestDistance := "", 3
+	candidates := OfferQueryFields()
+	for alias := range queryAliases {
+		candidates = append(candidates, alias)
+	}
+	sort.Strings(candidates)
+	for _, candidate := range candidates {
+		if d := editDistance(name, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	if target, ok := queryAliases[best]; ok {
+		return target
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,124 @@
+package gpu
+
+import (
+	"testing"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var queryOffers = []models.GPUInstance{
+	{ID: "a", Provider: "vast.ai", GPUName: "NVIDIA H100 80GB HBM3", GPUCount: 1, VRAM: 80, PricePerHour: 2.4, Location: "us-east", Available: true,
+		Specifications: map[string]interface{}{"reliability": 0.99}},
+	{ID: "b", Provider: "io.net", GPUName: "A100-SXM4-80GB", GPUCount: 2, VRAM: 80, PricePerHour: 2.0, Location: "us-west", Available: true},
+	{ID: "c", Provider: "vast.ai", GPUName: "RTX 4090", GPUCount: 4, VRAM: 24, PricePerHour: 1.6, Location: "eu-west", Available: true,
+		Specifications: map[string]interface{}{"reliability": 0.9}},
+	{ID: "d", Provider: "fake", GPUName: "Mystery GPU", GPUCount: 1, VRAM: 96, PricePerHour: 0.5, Location: "us-east", Available: false,
+		Specifications: map[string]interface{}{"reliability": 0.97}},
+}
+
+func queryIDs(t *testing.T, query string) []string {
+	t.Helper()
+	q, err := ParseOfferQuery(query)
+	require.NoError(t, err, query)
+	var ids []string
+	for _, inst := range q.Apply(queryOffers) {
+		ids = append(ids, inst.ID)
+	}
+	return ids
+}
+
+func TestOfferQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"a", "b", "c", "d"}},
+		{"vram>=80", []string{"a", "b", "d"}},
+		{"vram >= 80 and price < 2.1", []string{"b", "d"}},
+		{"gpu in (H100, A100)", []string{"a", "b"}},
+		{`gpu = "rtx 4090"`, []string{"c"}},
+		{"gpu not in (h100) provider != fake", []string{"b", "c"}},
+		{"region=us-*", []string{"a", "b", "d"}},
+		{"region=*-west", []string{"b", "c"}},
+		{"reliability>0.95", []string{"a", "d"}},
+		{"reliability<=0.95", []string{"c"}},
+		{"available=false", []string{"d"}},
+		{"gpus in (2, 4)", []string{"b", "c"}},
+		{"price_per_gpu<0.5", []string{"c"}},
+		{"vendor=nvidia tier=enterprise", []string{"a"}},
+		{"order by price", []string{"d", "c", "b", "a"}},
+		{"order by vram desc, price", []string{"d", "b", "a", "c"}},
+		{"order by price_per_tflop", []string{"c", "a", "b", "d"}}, // Unknown TFLOPS sorts last
+		{"order by price_per_tflop desc", []string{"b", "a", "c", "d"}},
+		{"order by reliability desc limit 2", []string{"a", "d"}},
+		{"ORDER BY Price LIMIT 1", []string{"d"}},
+		{"vram>=80 gpu in (H100,A100) price<2.5 region=us-* reliability>0.95 order by price_per_tflop", []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, tt.want, queryIDs(t, tt.query))
+		})
+	}
+}
+
+func TestOfferQueryErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		column int
+		msg    string
+	}{
+		{"prise<2", 1, `unknown field "prise"; did you mean "price"?`},
+		{"locaton=us", 1, `unknown field "locaton"; did you mean "region"?`},
+		{"colour=red", 1, "unknown field \"colour\"; fields are available, cpus"},
+		{"vram", 5, `expected an operator after "vram"`},
+		{"vram>=lots", 7, `vram is a number, got "lots"`},
+		{"gpu<H100", 4, "gpu is text and can only be compared with =, !=, in or not in"},
+		{"available=maybe", 11, "available is true or false"},
+		{"gpu in H100", 8, `expected a list after "in", e.g. gpu in (H100, A100)`},
+		{"gpu in (H100, A100", 19, `missing ")" to close the list`},
+		{"gpu in ()", 9, "empty list"},
+		{"gpu not (H100)", 9, `expected "in" after "not"`},
+		{`gpu="RTX 4090`, 5, "unterminated string"},
+		{"price!2", 6, `expected "!="`},
+		{"vram>=80 and", 13, `expected a condition after "and", got end of query`},
+		{"order price", 7, `expected "by" after "order"`},
+		{"order by price vram>=80", 16, "conditions must come before order by and limit"},
+		{"limit 0", 7, "limit must be a positive whole number"},
+		{"price>", 7, `expected a value after ">", got end of query`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseOfferQuery(tt.query)
+			var qerr *QueryError
+			require.ErrorAs(t, err, &qerr)
+			assert.Equal(t, tt.column, qerr.Column)
+			assert.Contains(t, qerr.Msg, tt.msg)
+		})
+	}
+}
+
+func TestOfferQueryString(t *testing.T) {
+	q, err := ParseOfferQuery(`VRAM>=80 gpu in (H100,"RTX 4090") and region==us-* order by Price desc limit 5`)
+	require.NoError(t, err)
+	assert.Equal(t, `vram >= 80 gpu in (h100, "rtx 4090") region = us-* order by price desc limit 5`, q.String())
+
+	again, err := ParseOfferQuery(q.String())
+	require.NoError(t, err)
+	assert.Equal(t, q, again)
+}
+
+func TestFiltersQuery(t *testing.T) {
+	q := FiltersQuery(map[string]interface{}{"min_vram": 80.0, "max_price": 2.2, "location": "US-EAST"})
+	assert.Equal(t, "vram >= 80 price <= 2.2 region = us-east", q.String())
+	assert.Len(t, q.Apply(queryOffers), 1)
+
+	q = FiltersQuery(map[string]interface{}{"min_vram": 24, "gpu_model": "A100"})
+	assert.Len(t, q.Apply(queryOffers), 1, "an int and the GPU class both work")
+
+	assert.True(t, FiltersQuery(map[string]interface{}{"min_vram": true}).Empty())
+
+	combined := FiltersQuery(map[string]interface{}{"min_vram": 80}).And(&OfferQuery{limit: 1})
+	assert.Equal(t, "vram >= 80 limit 1", combined.String())
+}
@@ -3,9 +3,13 @@ package gpu
 import (
 	"context"
 	"fmt"
+	"log"
 	"sort"
 	"sync"
+	"time"
 
+	"github.com/aiserve/gpuproxy/helpers/aws"
+	"github.com/aiserve/gpuproxy/helpers/oracle"
 	"github.com/aiserve/gpuproxy/internal/config"
 	"github.com/aiserve/gpuproxy/internal/models"
 	"github.com/aiserve/gpuproxy/pkg/ionet"
@@ -17,90 +21,161 @@ type Provider string
 const (
 	ProviderVastAI Provider = "vast.ai"
 	ProviderIONet  Provider = "io.net"
+	ProviderAWS    Provider = "aws"
+	ProviderOracle Provider = "oracle"
 	ProviderAll    Provider = "all"
 )
 
 type Service struct {
-	vastClient  *vastai.Client
-	ionetClient *ionet.Client
-	config      *config.GPUConfig
+	providers *Registry
+	config    *config.GPUConfig
+	history   *PriceHistory // Sampled by RunPriceHistory when set
 }
 
+// NewService creates the GPU service with a provider registered for every
+// marketplace and cloud that has credentials configured
 func NewService(cfg *config.GPUConfig) *Service {
-	var vastClient *vastai.Client
-	var ionetClient *ionet.Client
+	registry := NewRegistry()
 
 	if cfg.VastAIAPIKey != "" {
-		vastClient = vastai.NewClient(cfg.VastAIAPIKey, cfg.Timeout)
+		registry.Register(NewVastAIProvider(vastai.NewClient(cfg.VastAIAPIKey, cfg.Timeout)))
 	}
 
 	if cfg.IONetAPIKey != "" {
-		ionetClient = ionet.NewClient(cfg.IONetAPIKey, cfg.Timeout)
+		registry.Register(NewIONetProvider(ionet.NewClient(cfg.IONetAPIKey, cfg.Timeout)))
 	}
 
+	if cfg.AWS.AccessKeyID != "" {
+		client := aws.NewClient(cfg.AWS.Region, cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey, cfg.AWS.SessionToken)
+		registry.Register(NewCSPProvider(ProviderAWS, client))
+	}
+
+	if cfg.Oracle.TenancyOCID != "" {
+		client := oracle.NewClient(cfg.Oracle.TenancyOCID, cfg.Oracle.UserOCID, cfg.Oracle.Fingerprint,
+			cfg.Oracle.PrivateKey, cfg.Oracle.Region, cfg.Oracle.CompartmentOCID)
+		registry.Register(NewCSPProvider(ProviderOracle, client))
+	}
+
+	if cfg.Fake.Enabled {
+		fake, err := NewFakeMarketplace(cfg.Fake)
+		if err != nil {
+			log.Printf("WARNING: fake GPU marketplace disabled: %v", err)
+		} else {
+			registry.Register(fake)
+		}
+	}
+
+	return NewServiceWithRegistry(cfg, registry)
+}
+
+// NewServiceWithRegistry creates the GPU service over an existing provider registry
+func NewServiceWithRegistry(cfg *config.GPUConfig, registry *Registry) *Service {
 	return &Service{
-		vastClient:  vastClient,
-		ionetClient: ionetClient,
-		config:      cfg,
+		providers: registry,
+		config:    cfg,
 	}
 }
 
+// Providers returns the provider registry
+func (s *Service) Providers() *Registry {
+	return s.providers
+}
+
+// provider looks up a registered provider by name
+func (s *Service) provider(name Provider) (GPUProvider, error) {
+	p, ok := s.providers.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", name)
+	}
+	return p, nil
+}
+
+// SetPriceHistory makes RunPriceHistory record offers in h
+func (s *Service) SetPriceHistory(h *PriceHistory) {
+	s.history = h
+}
+
+// PriceHistory returns the price history offers are recorded in, if any
+func (s *Service) PriceHistory() *PriceHistory {
+	return s.history
+}
+
 func (s *Service) ListInstances(ctx context.Context, provider Provider) ([]models.GPUInstance, error) {
-	switch provider {
-	case ProviderVastAI:
-		if s.vastClient == nil {
-			return nil, fmt.Errorf("vast.ai client not configured")
+	var instances []models.GPUInstance
+	var err error
+	if provider == ProviderAll {
+		instances, err = s.listAllInstances(ctx)
+	} else {
+		var p GPUProvider
+		p, err = s.provider(provider)
+		if err != nil {
+			return nil, err
 		}
-		return s.vastClient.ListInstances(ctx)
+		instances, err = p.ListOffers(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
 
-	case ProviderIONet:
-		if s.ionetClient == nil {
-			return nil, fmt.Errorf("io.net client not configured")
+// RunPriceHistory records every provider's offers in the price history each
+// interval and compacts it. Sampling on a fixed interval keeps user listings
+// off the history store. It returns when ctx is cancelled.
+func (s *Service) RunPriceHistory(ctx context.Context, interval time.Duration) {
+	if s.history == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.samplePrices(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Price history sampling failed: %v", err)
+		}
+		if _, err := s.history.Compact(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Price history compaction failed: %v", err)
 		}
-		return s.ionetClient.ListInstances(ctx)
 
-	case ProviderAll:
-		return s.listAllInstances(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
 
-	default:
-		return nil, fmt.Errorf("unknown provider: %s", provider)
+// samplePrices records the current offers of every provider
+func (s *Service) samplePrices(ctx context.Context) error {
+	instances, err := s.listAllInstances(ctx)
+	if err != nil {
+		return err
 	}
+	return s.history.Record(ctx, instances)
 }
 
+// listAllInstances fans out over every registered provider. Failing providers
+// are skipped as long as at least one returns offers.
 func (s *Service) listAllInstances(ctx context.Context) ([]models.GPUInstance, error) {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var allInstances []models.GPUInstance
 	var errors []error
 
-	if s.vastClient != nil {
+	for _, p := range s.providers.All() {
 		wg.Add(1)
-		go func() {
+		go func(p GPUProvider) {
 			defer wg.Done()
-			instances, err := s.vastClient.ListInstances(ctx)
+			instances, err := p.ListOffers(ctx)
 			mu.Lock()
 			defer mu.Unlock()
 			if err != nil {
-				errors = append(errors, fmt.Errorf("vast.ai: %w", err))
+				errors = append(errors, fmt.Errorf("%s: %w", p.Name(), err))
 			} else {
 				allInstances = append(allInstances, instances...)
 			}
-		}()
-	}
-
-	if s.ionetClient != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			instances, err := s.ionetClient.ListInstances(ctx)
-			mu.Lock()
-			defer mu.Unlock()
-			if err != nil {
-				errors = append(errors, fmt.Errorf("io.net: %w", err))
-			} else {
-				allInstances = append(allInstances, instances...)
-			}
-		}()
+		}(p)
 	}
 
 	wg.Wait()
@@ -109,7 +184,7 @@ func (s *Service) listAllInstances(ctx context.Context) ([]models.GPUInstance, e
 		return nil, fmt.Errorf("all providers failed: %v", errors)
 	}
 
-	sort.Slice(allInstances, func(i, j int) bool {
+	sort.SliceStable(allInstances, func(i, j int) bool {
 		return allInstances[i].PricePerHour < allInstances[j].PricePerHour
 	})
 
@@ -117,96 +192,30 @@ func (s *Service) listAllInstances(ctx context.Context) ([]models.GPUInstance, e
 }
 
 func (s *Service) CreateInstance(ctx context.Context, provider Provider, instanceID string, config map[string]interface{}) (string, error) {
-	switch provider {
-	case ProviderVastAI:
-		if s.vastClient == nil {
-			return "", fmt.Errorf("vast.ai client not configured")
-		}
-		imageURL, _ := config["image"].(string)
-		if imageURL == "" {
-			imageURL = "nvidia/cuda:12.0.0-base-ubuntu22.04"
-		}
-		return s.vastClient.CreateInstance(ctx, instanceID, imageURL)
-
-	case ProviderIONet:
-		if s.ionetClient == nil {
-			return "", fmt.Errorf("io.net client not configured")
-		}
-		return s.ionetClient.CreateInstance(ctx, instanceID, config)
-
-	default:
-		return "", fmt.Errorf("unknown provider: %s", provider)
+	p, err := s.provider(provider)
+	if err != nil {
+		return "", err
 	}
+	return p.CreateInstance(ctx, instanceID, config)
 }
 
 func (s *Service) DestroyInstance(ctx context.Context, provider Provider, instanceID string) error {
-	switch provider {
-	case ProviderVastAI:
-		if s.vastClient == nil {
-			return fmt.Errorf("vast.ai client not configured")
-		}
-		return s.vastClient.DestroyInstance(ctx, instanceID)
-
-	case ProviderIONet:
-		if s.ionetClient == nil {
-			return fmt.Errorf("io.net client not configured")
-		}
-		return s.ionetClient.DestroyInstance(ctx, instanceID)
-
-	default:
-		return fmt.Errorf("unknown provider: %s", provider)
+	p, err := s.provider(provider)
+	if err != nil {
+		return err
 	}
+	return p.DestroyInstance(ctx, instanceID)
 }
 
 func (s *Service) GetInstanceStatus(ctx context.Context, provider Provider, instanceID string) (string, error) {
-	switch provider {
-	case ProviderIONet:
-		if s.ionetClient == nil {
-			return "", fmt.Errorf("io.net client not configured")
-		}
-		return s.ionetClient.GetInstanceStatus(ctx, instanceID)
-
-	default:
-		return "", fmt.Errorf("status check not supported for provider: %s", provider)
+	p, err := s.provider(provider)
+	if err != nil {
+		return "", err
 	}
+	return p.InstanceStatus(ctx, instanceID)
 }
 
+// FilterInstances applies the older map filters; see FiltersQuery
 func (s *Service) FilterInstances(instances []models.GPUInstance, filters map[string]interface{}) []models.GPUInstance {
-	var filtered []models.GPUInstance
-
-	for _, instance := range instances {
-		if s.matchesFilters(instance, filters) {
-			filtered = append(filtered, instance)
-		}
-	}
-
-	return filtered
-}
-
-func (s *Service) matchesFilters(instance models.GPUInstance, filters map[string]interface{}) bool {
-	if minVRAM, ok := filters["min_vram"].(int); ok {
-		if instance.VRAM < minVRAM {
-			return false
-		}
-	}
-
-	if maxPrice, ok := filters["max_price"].(float64); ok {
-		if instance.PricePerHour > maxPrice {
-			return false
-		}
-	}
-
-	if gpuModel, ok := filters["gpu_model"].(string); ok {
-		if instance.GPUName != gpuModel {
-			return false
-		}
-	}
-
-	if location, ok := filters["location"].(string); ok {
-		if instance.Location != location {
-			return false
-		}
-	}
-
-	return true
+	return FiltersQuery(filters).Apply(instances)
 }
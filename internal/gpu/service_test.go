@@ -0,0 +1,118 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aiserve/gpuproxy/helpers/common"
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubProvider struct {
+	name    Provider
+	offers  []models.GPUInstance
+	listErr error
+	created []string
+}
+
+func (p *stubProvider) Name() Provider { return p.name }
+
+func (p *stubProvider) ListOffers(ctx context.Context) ([]models.GPUInstance, error) {
+	return p.offers, p.listErr
+}
+
+func (p *stubProvider) CreateInstance(ctx context.Context, offerID string, config map[string]interface{}) (string, error) {
+	p.created = append(p.created, offerID)
+	return "contract-" + offerID, nil
+}
+
+func (p *stubProvider) DestroyInstance(ctx context.Context, instanceID string) error { return nil }
+
+func (p *stubProvider) InstanceStatus(ctx context.Context, instanceID string) (string, error) {
+	return "running", nil
+}
+
+func TestListInstancesFansOutOverRegistry(t *testing.T) {
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(&stubProvider{name: "a", offers: []models.GPUInstance{{ID: "a1", PricePerHour: 3}}}))
+	require.NoError(t, registry.Register(&stubProvider{name: "b", offers: []models.GPUInstance{{ID: "b1", PricePerHour: 1}}}))
+	require.NoError(t, registry.Register(&stubProvider{name: "broken", listErr: errors.New("down")}))
+
+	assert.Error(t, registry.Register(&stubProvider{name: "a"}), "duplicate names are rejected")
+	assert.Error(t, registry.Register(&stubProvider{name: ProviderAll}), "all is reserved")
+
+	s := NewServiceWithRegistry(&config.GPUConfig{}, registry)
+	instances, err := s.ListInstances(context.Background(), ProviderAll)
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+	assert.Equal(t, "b1", instances[0].ID, "offers are sorted by price")
+
+	_, err = s.ListInstances(context.Background(), "broken")
+	assert.ErrorContains(t, err, "down")
+
+	_, err = s.ListInstances(context.Background(), "missing")
+	assert.ErrorContains(t, err, "unknown provider: missing")
+
+	status, err := s.GetInstanceStatus(context.Background(), "a", "x")
+	require.NoError(t, err)
+	assert.Equal(t, "running", status)
+
+	registry.Unregister("a")
+	registry.Unregister("b")
+	_, err = s.ListInstances(context.Background(), ProviderAll)
+	assert.ErrorContains(t, err, "all providers failed")
+}
+
+type stubCSPClient struct {
+	instances []common.GPUInstance
+	reserved  common.ReservationRequest
+}
+
+func (c *stubCSPClient) List(ctx context.Context, opts common.ListOptions) ([]common.GPUInstance, error) {
+	return c.instances, nil
+}
+
+func (c *stubCSPClient) Reserve(ctx context.Context, req common.ReservationRequest) (*common.GPUInstance, error) {
+	c.reserved = req
+	return &common.GPUInstance{ID: "i-new"}, nil
+}
+
+func (c *stubCSPClient) Release(ctx context.Context, instanceID string) error { return nil }
+
+func (c *stubCSPClient) Status(ctx context.Context, instanceID string) (*common.GPUInstance, error) {
+	return &common.GPUInstance{ID: instanceID, Status: "running"}, nil
+}
+
+func TestCSPProvider(t *testing.T) {
+	client := &stubCSPClient{instances: []common.GPUInstance{{
+		ID: "p5", GPUModel: "H100", GPUCount: 8, VRAM: 640, Region: "us-east-1", CostPerHour: 98.32, Status: "available",
+	}}}
+	p := NewCSPProvider(ProviderAWS, client)
+
+	offers, err := p.ListOffers(context.Background())
+	require.NoError(t, err)
+	require.Len(t, offers, 1)
+	assert.Equal(t, "aws", offers[0].Provider)
+	assert.Equal(t, "H100", offers[0].GPUName)
+	assert.Equal(t, "us-east-1", offers[0].Location)
+	assert.True(t, offers[0].Available)
+
+	id, err := p.CreateInstance(context.Background(), "p5", map[string]interface{}{"image": "pytorch/pytorch"})
+	require.NoError(t, err)
+	assert.Equal(t, "i-new", id)
+	assert.Equal(t, "H100", client.reserved.GPUModel)
+	assert.Equal(t, 8, client.reserved.GPUCount)
+	assert.Equal(t, "us-east-1", client.reserved.PreferredRegion)
+	assert.Equal(t, "pytorch/pytorch", client.reserved.Image)
+
+	_, err = p.CreateInstance(context.Background(), "missing", nil)
+	assert.ErrorContains(t, err, "offer missing not found")
+
+	status, err := p.InstanceStatus(context.Background(), "i-new")
+	require.NoError(t, err)
+	assert.Equal(t, "running", status)
+}
@@ -0,0 +1,295 @@
+package gpu
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeviceStats is a sample of one local GPU's health and load. Readings a
+// device does not report, such as ECC counts on consumer cards, are nil.
+type DeviceStats struct {
+	Device
+	UtilizationPct float64  `json:"utilization_pct"`
+	MemoryUsedMB   int      `json:"memory_used_mb"`
+	MemoryTotalMB  int      `json:"memory_total_mb"`
+	TemperatureC   *float64 `json:"temperature_c,omitempty"`
+	PowerW         *float64 `json:"power_w,omitempty"`
+	ECCCorrected   *int64   `json:"ecc_corrected,omitempty"`   // Since the driver loaded
+	ECCUncorrected *int64   `json:"ecc_uncorrected,omitempty"` // Since the driver loaded
+}
+
+// TelemetrySource samples local GPUs
+type TelemetrySource interface {
+	Collect(ctx context.Context) ([]DeviceStats, error)
+}
+
+// nvidiaSMITelemetryArgs queries one CSV line of readings per device
+var nvidiaSMITelemetryArgs = []string{
+	"--query-gpu=index,uuid,name,utilization.gpu,memory.used,memory.total,temperature.gpu,power.draw,ecc.errors.corrected.volatile.total,ecc.errors.uncorrected.volatile.total",
+	"--format=csv,noheader,nounits",
+}
+
+// rocmSMITelemetryArgs reports the readings of every card as JSON
+var rocmSMITelemetryArgs = []string{
+	"--showproductname", "--showuniqueid", "--showuse", "--showtemp", "--showpower", "--showmeminfo", "vram", "--json",
+}
+
+// SMITelemetry samples NVIDIA and AMD GPUs with nvidia-smi and rocm-smi
+type SMITelemetry struct{}
+
+// NewSMITelemetry creates a telemetry source backed by the vendor tools
+func NewSMITelemetry() *SMITelemetry {
+	return &SMITelemetry{}
+}
+
+// Collect runs each vendor tool that is installed. A host without GPUs has
+// neither and yields no devices and no error; a tool that is installed but
+// fails is reported alongside the devices the other one found.
+func (s *SMITelemetry) Collect(ctx context.Context) ([]DeviceStats, error) {
+	var stats []DeviceStats
+	var errs []error
+
+	for _, tool := range []struct {
+		name  string
+		args  []string
+		parse func([]byte) ([]DeviceStats, error)
+	}{
+		{"nvidia-smi", nvidiaSMITelemetryArgs, ParseNvidiaSMITelemetry},
+		{"rocm-smi", rocmSMITelemetryArgs, ParseROCmSMITelemetry},
+	} {
+		out, err := runTool(ctx, tool.name, tool.args...)
+		if errors.Is(err, exec.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s failed: %w", tool.name, err))
+			continue
+		}
+		found, err := tool.parse(out)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		stats = append(stats, found...)
+	}
+
+	return stats, errors.Join(errs...)
+}
+
+// ParseNvidiaSMITelemetry parses the output of nvidia-smi --query-gpu with
+// nvidiaSMITelemetryArgs
+func ParseNvidiaSMITelemetry(out []byte) ([]DeviceStats, error) {
+	r := csv.NewReader(strings.NewReader(string(out)))
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = 10
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid nvidia-smi output: %w", err)
+	}
+
+	stats := make([]DeviceStats, 0, len(records))
+	for _, rec := range records {
+		index, err := strconv.Atoi(strings.TrimSpace(rec[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid nvidia-smi device index %q", rec[0])
+		}
+		s := DeviceStats{
+			Device: Device{
+				Backend: BackendCUDA,
+				Index:   index,
+				UUID:    strings.TrimSpace(rec[1]),
+				Name:    strings.TrimSpace(rec[2]),
+				VRAMMB:  atoiOrZero(rec[5]),
+			},
+			MemoryUsedMB:   atoiOrZero(rec[4]),
+			MemoryTotalMB:  atoiOrZero(rec[5]),
+			TemperatureC:   parseReading(rec[6]),
+			PowerW:         parseReading(rec[7]),
+			ECCCorrected:   parseCount(rec[8]),
+			ECCUncorrected: parseCount(rec[9]),
+		}
+		if util := parseReading(rec[3]); util != nil {
+			s.UtilizationPct = *util
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// ParseROCmSMITelemetry parses the output of rocm-smi with
+// rocmSMITelemetryArgs. rocm-smi has no ECC counts in its JSON output, so
+// those are left unset.
+func ParseROCmSMITelemetry(out []byte) ([]DeviceStats, error) {
+	var cards map[string]map[string]string
+	if err := json.Unmarshal(out, &cards); err != nil {
+		return nil, fmt.Errorf("invalid rocm-smi output: %w", err)
+	}
+
+	var stats []DeviceStats
+	for key, fields := range cards {
+		if !strings.HasPrefix(key, "card") {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimPrefix(key, "card"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rocm-smi card %q", key)
+		}
+
+		name := fields["Card series"]
+		if name == "" {
+			name = fields["Card model"]
+		}
+		total := bytesToMB(fields["VRAM Total Memory (B)"])
+		s := DeviceStats{
+			Device: Device{
+				Backend: BackendROCm,
+				Index:   index,
+				UUID:    fields["Unique ID"],
+				Name:    name,
+				VRAMMB:  total,
+			},
+			MemoryUsedMB:  bytesToMB(fields["VRAM Total Used Memory (B)"]),
+			MemoryTotalMB: total,
+			TemperatureC:  firstReading(fields, "Temperature (Sensor edge) (C)", "Temperature (Sensor junction) (C)"),
+			PowerW:        firstReading(fields, "Average Graphics Package Power (W)", "Current Socket Graphics Package Power (W)"),
+		}
+		if util := parseReading(fields["GPU use (%)"]); util != nil {
+			s.UtilizationPct = *util
+		}
+		stats = append(stats, s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Index < stats[j].Index })
+	return stats, nil
+}
+
+// parseReading parses a reading, returning nil for "[N/A]", "N/A" and the like
+func parseReading(s string) *float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+func parseCount(s string) *int64 {
+	v, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+func firstReading(fields map[string]string, keys ...string) *float64 {
+	for _, key := range keys {
+		if v := parseReading(fields[key]); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func bytesToMB(s string) int {
+	b, _ := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	return int(b / (1024 * 1024))
+}
+
+// TelemetryStatus describes the collector's last sample
+type TelemetryStatus struct {
+	Devices     int       `json:"devices"`
+	CollectedAt time.Time `json:"collected_at,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// TelemetryCollector samples local GPUs periodically and keeps the latest
+// sample for metrics and monitoring
+type TelemetryCollector struct {
+	source TelemetrySource
+	now    func() time.Time
+
+	mu          sync.RWMutex
+	stats       []DeviceStats
+	collectedAt time.Time
+	err         error
+}
+
+// NewTelemetryCollector creates a collector over source
+func NewTelemetryCollector(source TelemetrySource) *TelemetryCollector {
+	return &TelemetryCollector{
+		source: source,
+		now:    time.Now,
+	}
+}
+
+// Collect takes a sample. Devices that could be read replace the previous
+// sample even if some could not.
+func (c *TelemetryCollector) Collect(ctx context.Context) error {
+	stats, err := c.source.Collect(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats = stats
+	c.collectedAt = c.now()
+	c.err = err
+	if err != nil {
+		return fmt.Errorf("failed to collect GPU telemetry: %w", err)
+	}
+	return nil
+}
+
+// Snapshot returns the latest sample
+func (c *TelemetryCollector) Snapshot() []DeviceStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return append([]DeviceStats(nil), c.stats...)
+}
+
+// Status describes the latest sample
+func (c *TelemetryCollector) Status() TelemetryStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status := TelemetryStatus{Devices: len(c.stats), CollectedAt: c.collectedAt}
+	if c.err != nil {
+		status.Error = c.err.Error()
+	}
+	return status
+}
+
+// Run samples immediately and then every interval until ctx is cancelled.
+// Failures are logged once until a sample succeeds again.
+func (c *TelemetryCollector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		if err := c.Collect(ctx); err != nil {
+			if !failing && ctx.Err() == nil {
+				log.Printf("GPU telemetry: %v", err)
+			}
+			failing = true
+		} else {
+			failing = false
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
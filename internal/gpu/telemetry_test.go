@@ -0,0 +1,134 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNvidiaSMITelemetry(t *testing.T) {
+	stats, err := ParseNvidiaSMITelemetry(readFixture(t, "nvidia-smi-telemetry.csv"))
+	require.NoError(t, err)
+	require.Len(t, stats, 3)
+
+	busy := stats[0]
+	assert.Equal(t, BackendCUDA, busy.Backend)
+	assert.Equal(t, "GPU-5f2b1a6e-7c3d-4e8f-9a0b-1c2d3e4f5a6b", busy.UUID)
+	assert.Equal(t, 87.0, busy.UtilizationPct)
+	assert.Equal(t, 61440, busy.MemoryUsedMB)
+	assert.Equal(t, 81920, busy.MemoryTotalMB)
+	require.NotNil(t, busy.TemperatureC)
+	assert.Equal(t, 64.0, *busy.TemperatureC)
+	require.NotNil(t, busy.PowerW)
+	assert.Equal(t, 312.45, *busy.PowerW)
+	require.NotNil(t, busy.ECCUncorrected)
+	assert.Zero(t, *busy.ECCUncorrected)
+
+	assert.Equal(t, int64(12), *stats[1].ECCCorrected)
+	assert.Equal(t, int64(1), *stats[1].ECCUncorrected)
+
+	consumer := stats[2]
+	assert.Equal(t, "NVIDIA GeForce RTX 4090", consumer.Name)
+	assert.Nil(t, consumer.PowerW)
+	assert.Nil(t, consumer.ECCCorrected, "consumer cards have no ECC")
+	assert.Nil(t, consumer.ECCUncorrected)
+
+	_, err = ParseNvidiaSMITelemetry([]byte("0, GPU-1, A100, 87\n"))
+	assert.ErrorContains(t, err, "invalid nvidia-smi output")
+}
+
+func TestParseROCmSMITelemetry(t *testing.T) {
+	stats, err := ParseROCmSMITelemetry(readFixture(t, "rocm-smi-telemetry.json"))
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+
+	first := stats[0]
+	assert.Equal(t, BackendROCm, first.Backend)
+	assert.Equal(t, "AMD Instinct MI250X", first.Name)
+	assert.Equal(t, 73.0, first.UtilizationPct)
+	assert.Equal(t, 32760, first.MemoryUsedMB)
+	assert.Equal(t, 65520, first.MemoryTotalMB)
+	assert.Equal(t, 52.0, *first.TemperatureC, "edge temperature is preferred")
+	assert.Equal(t, 287.0, *first.PowerW)
+	assert.Nil(t, first.ECCUncorrected)
+
+	second := stats[1]
+	assert.Equal(t, 196592, second.MemoryTotalMB)
+	assert.Equal(t, 44.0, *second.TemperatureC, "falls back to junction temperature")
+	assert.Equal(t, 141.0, *second.PowerW, "newer rocm-smi reports socket power")
+
+	_, err = ParseROCmSMITelemetry([]byte("not json"))
+	assert.ErrorContains(t, err, "invalid rocm-smi output")
+}
+
+func fakeTools(t *testing.T, tools map[string]func() ([]byte, error)) {
+	orig := runTool
+	t.Cleanup(func() { runTool = orig })
+	runTool = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		if run, ok := tools[name]; ok {
+			return run()
+		}
+		return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+}
+
+func TestSMITelemetry(t *testing.T) {
+	ctx := context.Background()
+
+	fakeTools(t, map[string]func() ([]byte, error){
+		"nvidia-smi": func() ([]byte, error) { return readFixture(t, "nvidia-smi-telemetry.csv"), nil },
+		"rocm-smi":   func() ([]byte, error) { return readFixture(t, "rocm-smi-telemetry.json"), nil },
+	})
+	stats, err := NewSMITelemetry().Collect(ctx)
+	require.NoError(t, err)
+	assert.Len(t, stats, 5)
+
+	// A host without GPUs has neither tool
+	fakeTools(t, nil)
+	stats, err = NewSMITelemetry().Collect(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, stats)
+
+	// A broken tool does not hide the other vendor's devices
+	fakeTools(t, map[string]func() ([]byte, error){
+		"nvidia-smi": func() ([]byte, error) { return nil, errors.New("exit status 9") },
+		"rocm-smi":   func() ([]byte, error) { return readFixture(t, "rocm-smi-telemetry.json"), nil },
+	})
+	stats, err = NewSMITelemetry().Collect(ctx)
+	assert.ErrorContains(t, err, "nvidia-smi failed: exit status 9")
+	assert.Len(t, stats, 2)
+}
+
+// telemetryFunc adapts a function to TelemetrySource
+type telemetryFunc func(ctx context.Context) ([]DeviceStats, error)
+
+func (f telemetryFunc) Collect(ctx context.Context) ([]DeviceStats, error) { return f(ctx) }
+
+func TestTelemetryCollector(t *testing.T) {
+	ctx := context.Background()
+	sample := []DeviceStats{{Device: Device{Backend: BackendCUDA, Index: 0}, UtilizationPct: 50}}
+	var fail error
+	collector := NewTelemetryCollector(telemetryFunc(func(ctx context.Context) ([]DeviceStats, error) {
+		return sample, fail
+	}))
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	collector.now = func() time.Time { return at }
+
+	assert.Empty(t, collector.Snapshot())
+	assert.Equal(t, TelemetryStatus{}, collector.Status())
+
+	require.NoError(t, collector.Collect(ctx))
+	assert.Equal(t, sample, collector.Snapshot())
+	assert.Equal(t, TelemetryStatus{Devices: 1, CollectedAt: at}, collector.Status())
+
+	sample, fail = nil, fmt.Errorf("nvidia-smi failed: exit status 9")
+	assert.ErrorContains(t, collector.Collect(ctx), "failed to collect GPU telemetry")
+	assert.Empty(t, collector.Snapshot(), "stale readings are not kept")
+	assert.Equal(t, "nvidia-smi failed: exit status 9", collector.Status().Error)
+}
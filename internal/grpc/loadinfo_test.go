@@ -0,0 +1,144 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/gpu"
+	"github.com/aiserve/gpuproxy/internal/loadbalancer"
+	"github.com/aiserve/gpuproxy/internal/models"
+	pb "github.com/aiserve/gpuproxy/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newLoadInfoTestServer(t *testing.T) *Server {
+	t.Helper()
+	lb := loadbalancer.NewLoadBalancerService(loadbalancer.StrategyLeastConnections)
+	require.NoError(t, lb.AddPool(loadbalancer.PoolConfig{Name: "h100", Selector: "gpu=H100", Strategy: loadbalancer.StrategyP2C}))
+
+	instances := []models.GPUInstance{
+		{ID: "h100-0", Provider: "vast.ai", GPUName: "H100"},
+		{ID: "a100-0", Provider: "io.net", GPUName: "A100"},
+		{ID: "a100-1", Provider: "vast.ai", GPUName: "A100"},
+	}
+	ctx := context.Background()
+	_, err := lb.SelectInstance(ctx, instances)
+	require.NoError(t, err)
+	_, err = lb.SelectInstance(loadbalancer.WithPool(ctx, "h100"), instances)
+	require.NoError(t, err)
+
+	lb.TrackConnection("h100-0")
+	lb.TrackConnection("h100-0")
+	lb.TrackConnection("a100-0")
+	lb.TrackRequest("a100-1")(40*time.Millisecond, nil)
+	lb.TrackConnection("a100-1")
+	return &Server{lbService: lb}
+}
+
+func TestGetLoadInfo(t *testing.T) {
+	s := newLoadInfoTestServer(t)
+
+	resp, err := s.GetLoadInfo(context.Background(), &pb.GetLoadInfoRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "least_connections", resp.CurrentStrategy)
+
+	require.Len(t, resp.ServerLoad, 3)
+	assert.Equal(t, "a100-0", resp.ServerLoad[0].InstanceId)
+	assert.Equal(t, "a100-1", resp.ServerLoad[1].InstanceId)
+	assert.Equal(t, 40.0, resp.ServerLoad[1].AvgResponseTimeMs)
+	assert.Equal(t, "h100-0", resp.ServerLoad[2].InstanceId)
+	assert.Equal(t, int32(2), resp.ServerLoad[2].Connections)
+	assert.Equal(t, 0.5, resp.ServerLoad[2].LoadScore)
+
+	require.Len(t, resp.ProviderLoad, 2)
+	assert.Equal(t, "io.net", resp.ProviderLoad[0].Provider)
+	assert.Equal(t, 0.25, resp.ProviderLoad[0].LoadScore)
+	assert.Equal(t, "vast.ai", resp.ProviderLoad[1].Provider)
+	assert.Equal(t, int32(3), resp.ProviderLoad[1].Connections)
+	assert.Equal(t, 40.0, resp.ProviderLoad[1].AvgResponseTimeMs, "instances without a response time don't count")
+
+	resp, err = s.GetLoadInfo(context.Background(), &pb.GetLoadInfoRequest{Type: "provider"})
+	require.NoError(t, err)
+	assert.Empty(t, resp.ServerLoad)
+	assert.Len(t, resp.ProviderLoad, 2)
+}
+
+func TestGetLoadInfoPool(t *testing.T) {
+	s := newLoadInfoTestServer(t)
+
+	resp, err := s.GetLoadInfo(context.Background(), &pb.GetLoadInfoRequest{Type: "server", Pool: "h100"})
+	require.NoError(t, err)
+	assert.Equal(t, "p2c", resp.CurrentStrategy)
+	require.Len(t, resp.ServerLoad, 1)
+	assert.Equal(t, "h100-0", resp.ServerLoad[0].InstanceId)
+	assert.Equal(t, 1.0, resp.ServerLoad[0].LoadScore)
+	assert.Empty(t, resp.ProviderLoad)
+
+	_, err = s.GetLoadInfo(context.Background(), &pb.GetLoadInfoRequest{Pool: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = s.GetLoadInfo(context.Background(), &pb.GetLoadInfoRequest{Type: "cluster"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSetLoadBalancerStrategyPool(t *testing.T) {
+	s := newLoadInfoTestServer(t)
+
+	_, err := s.SetLoadBalancerStrategy(context.Background(), &pb.SetLoadBalancerStrategyRequest{Strategy: "round_robin", Pool: "h100"})
+	require.NoError(t, err)
+	assert.Equal(t, loadbalancer.StrategyRoundRobin, s.lbService.Pool("h100").GetStrategy())
+	assert.Equal(t, loadbalancer.StrategyLeastConnections, s.lbService.GetStrategy())
+
+	_, err = s.SetLoadBalancerStrategy(context.Background(), &pb.SetLoadBalancerStrategyRequest{Strategy: "round_robin", Pool: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	require.NoError(t, s.lbService.AddPool(loadbalancer.PoolConfig{Name: "weighted", Strategy: loadbalancer.StrategyP2C, Weights: map[string]float64{"provider=vast.ai": 2}}))
+	_, err = s.SetLoadBalancerStrategy(context.Background(), &pb.SetLoadBalancerStrategyRequest{Strategy: "round_robin", Pool: "weighted"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "round_robin would ignore the pool's weights")
+	assert.Equal(t, loadbalancer.StrategyP2C, s.lbService.Pool("weighted").GetStrategy())
+}
+
+func TestReserveGPUsSpreadsKeyedRequests(t *testing.T) {
+	fake, err := gpu.NewFakeMarketplace(config.FakeMarketplaceConfig{})
+	require.NoError(t, err)
+	registry := gpu.NewRegistry()
+	registry.Register(fake)
+	lb := loadbalancer.NewLoadBalancerService(loadbalancer.StrategyStickySession)
+	s := &Server{gpuService: gpu.NewServiceWithRegistry(&config.GPUConfig{}, registry), lbService: lb}
+	ctx := authedContext()
+
+	offers, err := fake.ListOffers(ctx)
+	require.NoError(t, err)
+	pinned, err := lb.SelectInstance(s.affinityContext(ctx, "", ""), offers)
+	require.NoError(t, err)
+
+	resp, err := s.ReserveGPUs(ctx, &pb.ReserveGPUsRequest{Count: 3})
+	require.NoError(t, err)
+	require.Len(t, resp.ReservedInstances, 3)
+	assert.Equal(t, pinned.ID, resp.ReservedInstances[0].Id, "the first instance follows the key")
+	ids := map[string]bool{}
+	for _, inst := range resp.ReservedInstances {
+		ids[inst.Id] = true
+	}
+	assert.Len(t, ids, 3, "each instance is reserved once")
+}
+
+func TestAffinityContextUsesPoolAffinity(t *testing.T) {
+	s := newLoadInfoTestServer(t)
+	source, err := loadbalancer.ParseAffinitySource("field:conversation_id")
+	require.NoError(t, err)
+	s.lbService.Pool("h100").SetAffinity(loadbalancer.AffinityConfig{Source: source})
+	ctx := authedContext()
+	userID, _ := getUserID(ctx)
+
+	pooled := loadbalancer.AffinityKeyFromContext(s.affinityContext(ctx, "conv-1", "h100"))
+	assert.Equal(t, source.Key("", nil, map[string]interface{}{"conversation_id": "conv-1"}), pooled)
+
+	global := loadbalancer.AffinityKeyFromContext(s.affinityContext(ctx, "conv-1", ""))
+	assert.Equal(t, s.lbService.Affinity().Source.Key(userID.String(), nil, nil), global, "other selections keep the global source")
+	assert.NotEqual(t, pooled, global)
+}
@@ -0,0 +1,530 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/gpu"
+	"github.com/aiserve/gpuproxy/internal/providers"
+	"github.com/aiserve/gpuproxy/internal/router"
+	pb "github.com/aiserve/gpuproxy/proto"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxStreamInFlight bounds the concurrent requests on one StreamProxyRequest
+	// stream. Once reached the server stops reading until a request completes.
+	maxStreamInFlight = 32
+
+	// streamSendBuffer is the number of responses queued for the stream's sender
+	streamSendBuffer = 64
+
+	// statusClientClosedRequest reports a request canceled by the client
+	statusClientClosedRequest = 499
+
+	// headerProxyDuration carries the upstream round trip time
+	headerProxyDuration = "x-proxy-duration-ms"
+)
+
+// ProxyRequest proxies a request to a GPU instance
+func (s *Server) ProxyRequest(ctx context.Context, req *pb.ProxyRequestMessage) (*pb.ProxyResponse, error) {
+	_, err := getUserID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if req.Protocol == "aiproxy" {
+		return nil, status.Errorf(codes.InvalidArgument, "aiproxy requests must use StreamProxyRequest")
+	}
+
+	return s.proxy(ctx, req)
+}
+
+// proxy sends a single request through the protocol handler
+func (s *Server) proxy(ctx context.Context, req *pb.ProxyRequestMessage) (*pb.ProxyResponse, error) {
+	if s.protocolHandler == nil {
+		return nil, status.Errorf(codes.Unavailable, "protocol handler not configured")
+	}
+
+	proxyReq, err := toGPUProxyRequest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if proxyReq.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, proxyReq.Timeout)
+		defer cancel()
+	}
+
+	// The instance ID comes from the client, so only requests to the
+	// caller's own reservation are tracked, for idleness, latency and health
+	done := func(time.Duration, error) {}
+	if reserved := s.reservedInstance(ctx, proxyReq.InstanceID); reserved != "" {
+		done = s.lbService.TrackRequest(reserved)
+	}
+
+	start := time.Now()
+	resp, err := s.protocolHandler.ProxyRequest(ctx, proxyReq)
+	if err != nil {
+		done(time.Since(start), err)
+		return nil, proxyError(ctx, err)
+	}
+	done(resp.Duration, resp.InstanceFailure())
+
+	// The protocol handler reports transport failures as 502 responses; a
+	// timeout is surfaced as such so clients can tell it apart
+	if resp.StatusCode == http.StatusBadGateway && ctx.Err() != nil {
+		return nil, proxyError(ctx, errors.New(resp.Error))
+	}
+
+	out, err := fromGPUProxyResponse(resp)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode response: %v", err)
+	}
+	return out, nil
+}
+
+// reservedInstance returns instanceID if it runs one of the caller's active
+// reservations, whose load the expiry scheduler watches for idleness
+func (s *Server) reservedInstance(ctx context.Context, instanceID string) string {
+	if s.reservations == nil || s.lbService == nil || instanceID == "" {
+		return ""
+	}
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ""
+	}
+	reservation, err := s.reservations.UserInstance(ctx, userID.String(), instanceID)
+	if err != nil {
+		return ""
+	}
+	return reservation.InstanceID
+}
+
+// proxyError maps a failed upstream call onto a gRPC status. Unlike request
+// validation errors these are worth retrying.
+func proxyError(ctx context.Context, err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return status.Errorf(codes.Canceled, "proxy request canceled: %v", err)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded), errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return status.Errorf(codes.DeadlineExceeded, "proxy request timed out: %v", err)
+	default:
+		return status.Errorf(codes.Unavailable, "proxy request failed: %v", err)
+	}
+}
+
+// toGPUProxyRequest maps a gRPC proxy message onto the protocol handler's request.
+// MCP and Open Inference bodies are decoded into their typed requests; HTTP
+// bodies are passed through unchanged.
+func toGPUProxyRequest(req *pb.ProxyRequestMessage) (*gpu.ProxyRequest, error) {
+	if req.Protocol == "" {
+		return nil, fmt.Errorf("protocol is required")
+	}
+	if req.TargetUrl == "" {
+		return nil, fmt.Errorf("target_url is required")
+	}
+	if target, err := url.Parse(req.TargetUrl); err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("target_url must be an absolute http(s) URL")
+	}
+	if req.Timeout < 0 {
+		return nil, fmt.Errorf("timeout must not be negative")
+	}
+
+	proxyReq := &gpu.ProxyRequest{
+		Protocol:   gpu.Protocol(strings.ToLower(req.Protocol)),
+		TargetURL:  req.TargetUrl,
+		Method:     strings.ToUpper(req.Method),
+		Headers:    req.Headers,
+		Timeout:    time.Duration(req.Timeout) * time.Second,
+		InstanceID: req.InstanceId,
+	}
+
+	switch proxyReq.Protocol {
+	case gpu.ProtocolMCP:
+		var mcpReq gpu.MCPRequest
+		if err := json.Unmarshal(req.Body, &mcpReq); err != nil {
+			return nil, fmt.Errorf("invalid MCP request body: %w", err)
+		}
+		proxyReq.Body = &mcpReq
+	case gpu.ProtocolOpenInference:
+		var oiReq gpu.OpenInferenceRequest
+		if err := json.Unmarshal(req.Body, &oiReq); err != nil {
+			return nil, fmt.Errorf("invalid Open Inference request body: %w", err)
+		}
+		proxyReq.Body = &oiReq
+	default:
+		if len(req.Body) > 0 {
+			proxyReq.Body = req.Body
+		}
+		if proxyReq.Method == "" {
+			proxyReq.Method = http.MethodGet
+			if len(req.Body) > 0 {
+				proxyReq.Method = http.MethodPost
+			}
+		}
+	}
+
+	if _, err := http.NewRequest(proxyReq.Method, proxyReq.TargetURL, nil); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+
+	return proxyReq, nil
+}
+
+// fromGPUProxyResponse maps a protocol handler response onto the gRPC message.
+// Text bodies are returned as-is and everything else is encoded as JSON.
+func fromGPUProxyResponse(resp *gpu.ProxyResponse) (*pb.ProxyResponse, error) {
+	var body []byte
+	switch b := resp.Body.(type) {
+	case nil:
+	case string:
+		body = []byte(b)
+	case []byte:
+		body = b
+	default:
+		var err error
+		if body, err = json.Marshal(b); err != nil {
+			return nil, err
+		}
+	}
+
+	headers := make(map[string]string, len(resp.Headers)+1)
+	for key, value := range resp.Headers {
+		headers[key] = value
+	}
+	headers[headerProxyDuration] = strconv.FormatInt(resp.Duration.Milliseconds(), 10)
+
+	return &pb.ProxyResponse{
+		StatusCode: int32(resp.StatusCode),
+		Headers:    headers,
+		Body:       body,
+		Error:      resp.Error,
+	}, nil
+}
+
+// StreamProxyRequest multiplexes proxy requests over a bidirectional stream.
+// Requests run concurrently and are matched to their responses by
+// correlation_id; the last response for a request has done set. Sending a
+// message with cancel set cancels the in-flight request with that
+// correlation_id, which then completes with status 499. At most
+// maxStreamInFlight requests run at once; further messages are not read until
+// one completes.
+//
+// For the "aiproxy" protocol each request body is a JSON providers.PredictRequest
+// and the reply is a sequence of responses whose bodies are JSON router.StreamEvent
+// values, ending with a "done" or "error" event.
+func (s *Server) StreamProxyRequest(stream pb.GPUProxyService_StreamProxyRequestServer) error {
+	_, err := getUserID(stream.Context())
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	mux := newProxyMux(s, stream)
+
+	sendErr := make(chan error, 1)
+	go func() { sendErr <- mux.sendLoop() }()
+
+	recvErr := mux.recvLoop()
+	if recvErr != nil {
+		mux.cancel()
+	}
+
+	// Let in-flight requests finish before closing the stream
+	mux.wg.Wait()
+	close(mux.out)
+
+	if err := <-sendErr; err != nil && recvErr == nil {
+		return err
+	}
+	return recvErr
+}
+
+// proxyMux tracks the in-flight requests of one StreamProxyRequest stream
+type proxyMux struct {
+	server *Server
+	stream pb.GPUProxyService_StreamProxyRequestServer
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// out is drained by a single sender since stream.Send is not safe for
+	// concurrent use
+	out   chan *pb.ProxyResponse
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	mu       sync.Mutex
+	inflight map[string]context.CancelFunc
+}
+
+func newProxyMux(s *Server, stream pb.GPUProxyService_StreamProxyRequestServer) *proxyMux {
+	ctx, cancel := context.WithCancel(stream.Context())
+	return &proxyMux{
+		server:   s,
+		stream:   stream,
+		ctx:      ctx,
+		cancel:   cancel,
+		out:      make(chan *pb.ProxyResponse, streamSendBuffer),
+		slots:    make(chan struct{}, maxStreamInFlight),
+		inflight: make(map[string]context.CancelFunc),
+	}
+}
+
+// recvLoop reads requests until the client closes its side of the stream
+func (m *proxyMux) recvLoop() error {
+	for {
+		req, err := m.stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if m.ctx.Err() != nil {
+				return status.FromContextError(m.ctx.Err()).Err()
+			}
+			return status.Errorf(codes.Internal, "stream receive error: %v", err)
+		}
+
+		if req.Cancel {
+			m.cancelRequest(req.CorrelationId)
+			continue
+		}
+
+		id := req.CorrelationId
+		if id == "" {
+			id = uuid.New().String()
+		}
+
+		// Backpressure: wait for a free slot before reading the next message
+		select {
+		case m.slots <- struct{}{}:
+		case <-m.ctx.Done():
+			return status.FromContextError(m.ctx.Err()).Err()
+		}
+
+		ctx, cancel := context.WithCancel(m.ctx)
+		if !m.register(id, cancel) {
+			cancel()
+			<-m.slots
+			m.emit(&pb.ProxyResponse{
+				StatusCode:    http.StatusConflict,
+				Error:         fmt.Sprintf("correlation_id %s is already in flight", id),
+				CorrelationId: id,
+				Done:          true,
+			})
+			continue
+		}
+
+		m.wg.Add(1)
+		go m.handle(ctx, cancel, id, req)
+	}
+}
+
+// sendLoop writes queued responses to the stream
+func (m *proxyMux) sendLoop() error {
+	for resp := range m.out {
+		if err := m.stream.Send(resp); err != nil {
+			// Stop in-flight requests; their responses can no longer be delivered
+			m.cancel()
+			for range m.out {
+			}
+			return status.Errorf(codes.Internal, "stream send error: %v", err)
+		}
+	}
+	return nil
+}
+
+// handle runs one request and emits its responses
+func (m *proxyMux) handle(ctx context.Context, cancel context.CancelFunc, id string, req *pb.ProxyRequestMessage) {
+	defer m.wg.Done()
+	defer func() { <-m.slots }()
+	defer cancel()
+
+	// canceled reports whether the client canceled this request, as opposed to
+	// the whole stream ending
+	canceled := func() bool {
+		return errors.Is(ctx.Err(), context.Canceled) && m.ctx.Err() == nil
+	}
+
+	if req.Protocol == "aiproxy" {
+		done := false
+		m.server.streamAIProxy(ctx, req, func(resp *pb.ProxyResponse) error {
+			if resp.Done {
+				done = true
+				m.unregister(id)
+				if canceled() {
+					resp = &pb.ProxyResponse{StatusCode: statusClientClosedRequest, Error: "request canceled", Done: true}
+				}
+			}
+			resp.CorrelationId = id
+			return m.emit(resp)
+		})
+		if !done {
+			// The provider stream ended without a terminal event
+			m.unregister(id)
+			resp := &pb.ProxyResponse{StatusCode: http.StatusBadGateway, Error: "stream ended unexpectedly", CorrelationId: id, Done: true}
+			if canceled() {
+				resp.StatusCode, resp.Error = statusClientClosedRequest, "request canceled"
+			}
+			m.emit(resp)
+		}
+		return
+	}
+
+	resp, err := m.server.proxy(ctx, req)
+	m.unregister(id)
+
+	switch {
+	case canceled():
+		resp = &pb.ProxyResponse{StatusCode: statusClientClosedRequest, Error: "request canceled"}
+	case err != nil:
+		resp = &pb.ProxyResponse{StatusCode: grpcStatusToHTTP(err), Error: status.Convert(err).Message()}
+	}
+	resp.CorrelationId = id
+	resp.Done = true
+	m.emit(resp)
+}
+
+// emit queues a response for the sender, giving up once the stream is gone
+func (m *proxyMux) emit(resp *pb.ProxyResponse) error {
+	select {
+	case m.out <- resp:
+		return nil
+	case <-m.ctx.Done():
+		return m.ctx.Err()
+	}
+}
+
+// register records an in-flight request, reporting false if the ID is taken
+func (m *proxyMux) register(id string, cancel context.CancelFunc) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.inflight[id]; exists {
+		return false
+	}
+	m.inflight[id] = cancel
+	return true
+}
+
+func (m *proxyMux) unregister(id string) {
+	m.mu.Lock()
+	delete(m.inflight, id)
+	m.mu.Unlock()
+}
+
+// cancelRequest cancels an in-flight request. Unknown IDs are ignored since the
+// request may already have completed.
+func (m *proxyMux) cancelRequest(id string) {
+	m.mu.Lock()
+	cancel, ok := m.inflight[id]
+	m.mu.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
+
+// grpcStatusToHTTP maps a gRPC error onto the status code reported in a
+// stream response
+func grpcStatusToHTTP(err error) int32 {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// streamAIProxy streams a single AIProxy prediction through send. Every
+// outcome, including errors, is reported as a response so other requests on
+// the stream are unaffected.
+func (s *Server) streamAIProxy(ctx context.Context, req *pb.ProxyRequestMessage, send func(*pb.ProxyResponse) error) {
+	emit := func(statusCode int32, event router.StreamEvent) error {
+		body, err := json.Marshal(event)
+		if err != nil {
+			statusCode = http.StatusInternalServerError
+			event = router.StreamEvent{Type: "error", Error: fmt.Sprintf("failed to marshal event: %v", err)}
+			body, _ = json.Marshal(event)
+		}
+		return send(&pb.ProxyResponse{
+			StatusCode: statusCode,
+			Headers: map[string]string{
+				"content-type":    "application/json",
+				"x-aiproxy-event": event.Type,
+			},
+			Body:  body,
+			Error: event.Error,
+			Done:  event.Type == "done" || event.Type == "error",
+		})
+	}
+
+	if s.aiproxyRouter == nil {
+		emit(http.StatusNotImplemented, router.StreamEvent{Type: "error", Error: "aiproxy is not enabled on this server"})
+		return
+	}
+
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	var predictReq providers.PredictRequest
+	if err := json.Unmarshal(req.Body, &predictReq); err != nil {
+		emit(http.StatusBadRequest, router.StreamEvent{Type: "error", Error: "invalid request body"})
+		return
+	}
+	if predictReq.Model == "" {
+		emit(http.StatusBadRequest, router.StreamEvent{Type: "error", Error: "model is required"})
+		return
+	}
+
+	chunks, decision, err := s.aiproxyRouter.PredictStream(ctx, &predictReq)
+	if err != nil {
+		statusCode := int32(http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, router.ErrNoAvailableProvider):
+			statusCode = http.StatusServiceUnavailable
+		case errors.Is(err, router.ErrAllProvidersFailed):
+			statusCode = http.StatusBadGateway
+		case errors.Is(err, router.ErrBudgetExceeded):
+			statusCode = http.StatusTooManyRequests
+		}
+		emit(statusCode, router.StreamEvent{Type: "error", Error: err.Error(), Routing: decision})
+		return
+	}
+
+	err = router.EmitStreamEvents(chunks, decision, func(event router.StreamEvent) error {
+		statusCode := int32(http.StatusOK)
+		if event.Type == "error" {
+			statusCode = http.StatusBadGateway
+		}
+		return emit(statusCode, event)
+	})
+	if err != nil {
+		// The stream is gone; drain the provider so it can exit
+		for range chunks {
+		}
+	}
+}
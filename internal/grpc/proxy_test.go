@@ -0,0 +1,324 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/compute"
+	"github.com/aiserve/gpuproxy/internal/gpu"
+	"github.com/aiserve/gpuproxy/internal/loadbalancer"
+	pb "github.com/aiserve/gpuproxy/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeProxyStream drives StreamProxyRequest from channels
+type fakeProxyStream struct {
+	grpclib.ServerStream
+	ctx context.Context
+	in  chan *pb.ProxyRequestMessage
+	out chan *pb.ProxyResponse
+}
+
+func newFakeProxyStream(ctx context.Context) *fakeProxyStream {
+	return &fakeProxyStream{
+		ctx: ctx,
+		in:  make(chan *pb.ProxyRequestMessage),
+		out: make(chan *pb.ProxyResponse, 16),
+	}
+}
+
+func (f *fakeProxyStream) Context() context.Context { return f.ctx }
+
+func (f *fakeProxyStream) Recv() (*pb.ProxyRequestMessage, error) {
+	select {
+	case msg, ok := <-f.in:
+		if !ok {
+			return nil, io.EOF
+		}
+		return msg, nil
+	case <-f.ctx.Done():
+		return nil, f.ctx.Err()
+	}
+}
+
+func (f *fakeProxyStream) Send(resp *pb.ProxyResponse) error {
+	f.out <- resp
+	return nil
+}
+
+func (f *fakeProxyStream) next(t *testing.T) *pb.ProxyResponse {
+	t.Helper()
+	select {
+	case resp := <-f.out:
+		return resp
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a response")
+		return nil
+	}
+}
+
+func authedContext() context.Context {
+	return context.WithValue(context.Background(), "user_id", uuid.New())
+}
+
+func newProxyTestServer() *Server {
+	return &Server{protocolHandler: gpu.NewProtocolHandler(10 * time.Second)}
+}
+
+func TestProxyRequestHTTP(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Write(append([]byte("echo:"), body...))
+	}))
+	defer target.Close()
+
+	s := newProxyTestServer()
+	resp, err := s.ProxyRequest(authedContext(), &pb.ProxyRequestMessage{
+		Protocol:  "http",
+		TargetUrl: target.URL,
+		Body:      []byte("raw payload"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(200), resp.StatusCode)
+	assert.Equal(t, "echo:raw payload", string(resp.Body))
+	assert.Equal(t, "POST", resp.Headers["X-Method"])
+	assert.Contains(t, resp.Headers, headerProxyDuration)
+
+	_, err = s.ProxyRequest(context.Background(), &pb.ProxyRequestMessage{Protocol: "http", TargetUrl: target.URL})
+	assert.Error(t, err, "unauthenticated requests are rejected")
+
+	_, err = s.ProxyRequest(authedContext(), &pb.ProxyRequestMessage{Protocol: "http"})
+	assert.ErrorContains(t, err, "target_url is required")
+}
+
+func TestProxyRequestCountsReservedInstanceLoad(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	ctx := authedContext()
+	userID, _ := getUserID(ctx)
+	store := compute.NewMemoryReservationStore()
+	require.NoError(t, store.Create(ctx, &compute.Reservation{ID: "res-1", InstanceID: "contract-1", UserID: userID.String(), Status: compute.StatusActive}))
+	reservations, err := compute.NewReservationClientWithStore(store, "", "", "")
+	require.NoError(t, err)
+
+	s := newProxyTestServer()
+	s.lbService = loadbalancer.NewLoadBalancerService(loadbalancer.StrategyLeastConnections)
+	s.SetReservationClient(reservations)
+
+	_, err = s.ProxyRequest(ctx, &pb.ProxyRequestMessage{Protocol: "http", TargetUrl: target.URL, InstanceId: "contract-1"})
+	require.NoError(t, err)
+
+	load := s.lbService.GetAllLoads()["contract-1"]
+	require.NotNil(t, load, "the reservation's instance is tracked for idle expiry")
+	assert.Equal(t, 0, load.ActiveConnections)
+	assert.Equal(t, int64(1), load.TotalConnections)
+}
+
+func TestProxyRequestTracksReservedInstanceHealth(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer target.Close()
+
+	ctx := authedContext()
+	userID, _ := getUserID(ctx)
+	store := compute.NewMemoryReservationStore()
+	require.NoError(t, store.Create(ctx, &compute.Reservation{ID: "res-1", InstanceID: "contract-1", UserID: userID.String(), Status: compute.StatusActive}))
+	reservations, err := compute.NewReservationClientWithStore(store, "", "", "")
+	require.NoError(t, err)
+
+	s := newProxyTestServer()
+	s.lbService = loadbalancer.NewLoadBalancerService(loadbalancer.StrategyP2C)
+	s.lbService.SetHealthConfig(loadbalancer.HealthConfig{Enabled: true, ConsecutiveFailures: 2})
+	s.SetReservationClient(reservations)
+
+	for i := 0; i < 2; i++ {
+		_, err = s.ProxyRequest(ctx, &pb.ProxyRequestMessage{Protocol: "http", TargetUrl: target.URL, InstanceId: "contract-1"})
+		require.NoError(t, err)
+	}
+	health := s.lbService.Health().Status("contract-1")
+	assert.False(t, health.Healthy, "5xx responses count against the reserved instance")
+	assert.Equal(t, "status 503", health.LastError)
+	assert.Equal(t, 0, s.lbService.GetInstanceLoad("contract-1").ActiveConnections)
+}
+
+func TestProxyRequestIgnoresClientInstanceHealth(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer target.Close()
+
+	s := newProxyTestServer()
+	s.lbService = loadbalancer.NewLoadBalancerService(loadbalancer.StrategyLeastConnections)
+	s.lbService.SetHealthConfig(loadbalancer.HealthConfig{Enabled: true, ConsecutiveFailures: 1})
+
+	// A client naming someone else's instance must not get it ejected
+	for i := 0; i < 3; i++ {
+		_, err := s.ProxyRequest(authedContext(), &pb.ProxyRequestMessage{Protocol: "http", TargetUrl: target.URL, InstanceId: "a100-1"})
+		require.NoError(t, err)
+	}
+	assert.True(t, s.lbService.Health().Healthy("a100-1"))
+	assert.Nil(t, s.lbService.GetInstanceLoad("a100-1"))
+}
+
+func TestProxyRequestErrorCodes(t *testing.T) {
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer target.Close()
+	defer close(release)
+
+	s := newProxyTestServer()
+	for name, msg := range map[string]*pb.ProxyRequestMessage{
+		"relative url": {Protocol: "http", TargetUrl: "/v1/models"},
+		"bad scheme":   {Protocol: "http", TargetUrl: "ftp://example.com"},
+		"bad method":   {Protocol: "http", TargetUrl: target.URL, Method: "GET X"},
+	} {
+		_, err := s.ProxyRequest(authedContext(), msg)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), name)
+	}
+
+	_, err := s.ProxyRequest(authedContext(), &pb.ProxyRequestMessage{Protocol: "http", TargetUrl: target.URL, Timeout: 1})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+	assert.Equal(t, codes.Unavailable, status.Code(proxyError(context.Background(), errors.New("connection refused"))))
+}
+
+func TestProxyRequestMCP(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req gpu.MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(gpu.MCPResponse{Result: req.Method})
+	}))
+	defer target.Close()
+
+	resp, err := newProxyTestServer().ProxyRequest(authedContext(), &pb.ProxyRequestMessage{
+		Protocol:  "mcp",
+		TargetUrl: target.URL,
+		Body:      []byte(`{"method":"tools/list","params":{}}`),
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"result":"tools/list"}`, string(resp.Body))
+
+	_, err = newProxyTestServer().ProxyRequest(authedContext(), &pb.ProxyRequestMessage{
+		Protocol:  "mcp",
+		TargetUrl: target.URL,
+		Body:      []byte(`not json`),
+	})
+	assert.ErrorContains(t, err, "invalid MCP request body")
+}
+
+func TestStreamProxyRequestMultiplexing(t *testing.T) {
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer target.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(authedContext())
+	defer cancel()
+	stream := newFakeProxyStream(ctx)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- newProxyTestServer().StreamProxyRequest(stream) }()
+
+	stream.in <- &pb.ProxyRequestMessage{Protocol: "http", TargetUrl: target.URL + "/slow", CorrelationId: "slow"}
+	stream.in <- &pb.ProxyRequestMessage{Protocol: "http", TargetUrl: target.URL + "/fast", CorrelationId: "fast"}
+
+	// The fast request completes while the slow one is still in flight
+	resp := stream.next(t)
+	assert.Equal(t, "fast", resp.CorrelationId)
+	assert.Equal(t, "/fast", string(resp.Body))
+	assert.True(t, resp.Done)
+
+	// Reusing an in-flight correlation ID is rejected
+	stream.in <- &pb.ProxyRequestMessage{Protocol: "http", TargetUrl: target.URL + "/fast", CorrelationId: "slow"}
+	resp = stream.next(t)
+	assert.Equal(t, "slow", resp.CorrelationId)
+	assert.Equal(t, int32(http.StatusConflict), resp.StatusCode)
+
+	// Canceling only affects the named request
+	stream.in <- &pb.ProxyRequestMessage{CorrelationId: "slow", Cancel: true}
+	resp = stream.next(t)
+	assert.Equal(t, "slow", resp.CorrelationId)
+	assert.Equal(t, int32(statusClientClosedRequest), resp.StatusCode)
+	assert.True(t, resp.Done)
+
+	// Requests without a correlation ID get one assigned
+	stream.in <- &pb.ProxyRequestMessage{Protocol: "http", TargetUrl: target.URL + "/fast"}
+	resp = stream.next(t)
+	assert.NotEmpty(t, resp.CorrelationId)
+	assert.Equal(t, "/fast", string(resp.Body))
+
+	close(stream.in)
+	require.NoError(t, <-errCh)
+}
+
+func TestStreamProxyRequestWaitsForInFlight(t *testing.T) {
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	stream := newFakeProxyStream(authedContext())
+	errCh := make(chan error, 1)
+	go func() { errCh <- newProxyTestServer().StreamProxyRequest(stream) }()
+
+	stream.in <- &pb.ProxyRequestMessage{Protocol: "http", TargetUrl: target.URL, CorrelationId: "a"}
+	close(stream.in)
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("stream returned before the request completed: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	resp := stream.next(t)
+	assert.Equal(t, "a", resp.CorrelationId)
+	assert.Equal(t, "ok", string(resp.Body))
+	require.NoError(t, <-errCh)
+}
+
+func TestStreamProxyRequestAIProxyDisabled(t *testing.T) {
+	stream := newFakeProxyStream(authedContext())
+	errCh := make(chan error, 1)
+	go func() { errCh <- newProxyTestServer().StreamProxyRequest(stream) }()
+
+	stream.in <- &pb.ProxyRequestMessage{Protocol: "aiproxy", CorrelationId: "ai", Body: []byte(`{"model":"m"}`)}
+	resp := stream.next(t)
+	assert.Equal(t, "ai", resp.CorrelationId)
+	assert.Equal(t, int32(http.StatusNotImplemented), resp.StatusCode)
+	assert.Equal(t, "error", resp.Headers["x-aiproxy-event"])
+	assert.True(t, resp.Done)
+
+	close(stream.in)
+	require.NoError(t, <-errCh)
+}
@@ -4,19 +4,25 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"net/http"
+	"slices"
+	"sort"
 	"time"
 
 	"github.com/aiserve/gpuproxy/internal/auth"
 	"github.com/aiserve/gpuproxy/internal/billing"
+	"github.com/aiserve/gpuproxy/internal/compute"
 	"github.com/aiserve/gpuproxy/internal/cuic"
 	"github.com/aiserve/gpuproxy/internal/gpu"
 	"github.com/aiserve/gpuproxy/internal/loadbalancer"
 	"github.com/aiserve/gpuproxy/internal/middleware"
 	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/aiserve/gpuproxy/internal/router"
 	pb "github.com/aiserve/gpuproxy/proto"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
@@ -38,6 +44,9 @@ type Server struct {
 	cuicServer      *cuic.CUICServer
 	grpcServer      *grpc.Server
 	ipAccessControl *middleware.IPAccessControl
+	aiproxyRouter   *router.Router
+	bookingCalendar *compute.BookingCalendar
+	reservations    *compute.ReservationClient
 }
 
 // NewServer creates a new gRPC server instance
@@ -61,6 +70,22 @@ func NewServer(
 	}
 }
 
+// SetAIProxyRouter enables the "aiproxy" protocol on the proxy RPCs
+func (s *Server) SetAIProxyRouter(r *router.Router) {
+	s.aiproxyRouter = r
+}
+
+// SetBookingCalendar enables the advance booking RPCs
+func (s *Server) SetBookingCalendar(c *compute.BookingCalendar) {
+	s.bookingCalendar = c
+}
+
+// SetReservationClient lets proxied requests to a caller's reserved
+// instance count towards the reservation's idle expiry
+func (s *Server) SetReservationClient(c *compute.ReservationClient) {
+	s.reservations = c
+}
+
 // Start starts the gRPC server on the specified address
 func (s *Server) Start(address string, certFile, keyFile string) error {
 	lis, err := net.Listen("tcp", address)
@@ -146,7 +171,7 @@ func (s *Server) authInterceptor(ctx context.Context, req interface{}, info *grp
 		return nil, status.Errorf(codes.Unauthenticated, "missing metadata")
 	}
 
-	var userID string
+	var userID uuid.UUID
 
 	// Check for API key
 	apiKeys := md.Get("x-api-key")
@@ -159,7 +184,7 @@ func (s *Server) authInterceptor(ctx context.Context, req interface{}, info *grp
 	}
 
 	// Check for JWT token if no API key
-	if userID == "" {
+	if userID == uuid.Nil {
 		tokens := md.Get("authorization")
 		if len(tokens) == 0 {
 			return nil, status.Errorf(codes.Unauthenticated, "missing authorization token")
@@ -181,10 +206,10 @@ func (s *Server) authInterceptor(ctx context.Context, req interface{}, info *grp
 	}
 
 	// Check IP access control
-	if s.ipAccessControl != nil && userID != "" {
+	if s.ipAccessControl != nil && userID != uuid.Nil {
 		clientIP := extractGRPCClientIP(ctx)
 		if clientIP != "" {
-			result, err := s.ipAccessControl.CheckAccess(ctx, userID, clientIP)
+			result, err := s.ipAccessControl.CheckAccess(ctx, userID.String(), clientIP)
 			if err != nil {
 				log.Printf("IP access check error for user %s from %s: %v", userID, clientIP, err)
 				return nil, status.Errorf(codes.Internal, "IP access check failed")
@@ -210,9 +235,9 @@ func (s *Server) streamAuthInterceptor(srv interface{}, ss grpc.ServerStream, in
 	// Check for API key
 	apiKeys := md.Get("x-api-key")
 	if len(apiKeys) > 0 {
-		_, err := s.authService.ValidateAPIKey(ss.Context(), apiKeys[0])
+		user, err := s.authService.ValidateAPIKey(ss.Context(), apiKeys[0])
 		if err == nil {
-			return handler(srv, ss)
+			return handler(srv, &authenticatedStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), "user_id", user.ID)})
 		}
 	}
 
@@ -227,12 +252,24 @@ func (s *Server) streamAuthInterceptor(srv interface{}, ss grpc.ServerStream, in
 		token = token[7:]
 	}
 
-	_, err := auth.ValidateToken(token, s.authService.GetJWTSecret())
+	claims, err := auth.ValidateToken(token, s.authService.GetJWTSecret())
 	if err != nil {
 		return status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
 	}
 
-	return handler(srv, ss)
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), "user_id", claims.UserID)})
+}
+
+// authenticatedStream carries the authenticated user ID in the stream context,
+// the same way authInterceptor does for unary RPCs
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the stream context with the user ID attached
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
 }
 
 // extractGRPCClientIP extracts the client IP from gRPC context
@@ -291,7 +328,7 @@ func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResp
 func (s *Server) CreateAPIKey(ctx context.Context, req *pb.CreateAPIKeyRequest) (*pb.CreateAPIKeyResponse, error) {
 	userID, err := getUserID(ctx)
 	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, err.Error())
+		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
 
 	apiKey, err := s.authService.CreateAPIKey(ctx, userID, req.Name, nil)
@@ -313,6 +350,11 @@ func (s *Server) ListGPUInstances(ctx context.Context, req *pb.ListGPUInstancesR
 		provider = "all"
 	}
 
+	query, err := gpu.ParseOfferQuery(req.Query)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
 	instances, err := s.gpuService.ListInstances(ctx, provider)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to list instances: %v", err)
@@ -330,8 +372,9 @@ func (s *Server) ListGPUInstances(ctx context.Context, req *pb.ListGPUInstancesR
 		filters["gpu_model"] = req.GpuModel
 	}
 
-	if len(filters) > 0 {
-		instances = s.gpuService.FilterInstances(instances, filters)
+	query = query.And(gpu.FiltersQuery(filters))
+	if !query.Empty() {
+		instances = query.Apply(instances)
 	}
 
 	// Convert to protobuf format
@@ -456,35 +499,11 @@ func (s *Server) GetGPUInstance(ctx context.Context, req *pb.GetGPUInstanceReque
 	return nil, status.Errorf(codes.NotFound, "instance not found")
 }
 
-// ProxyRequest proxies a request to a GPU instance
-func (s *Server) ProxyRequest(ctx context.Context, req *pb.ProxyRequestMessage) (*pb.ProxyResponse, error) {
-	_, err := getUserID(ctx)
-	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, err.Error())
-	}
-
-	// TODO: Implement proxy request handling
-	// For now return not implemented
-	return nil, status.Errorf(codes.Unimplemented, "proxy requests not yet implemented via gRPC")
-}
-
-// StreamProxyRequest handles streaming proxy requests
-func (s *Server) StreamProxyRequest(stream pb.GPUProxyService_StreamProxyRequestServer) error {
-	ctx := stream.Context()
-	_, err := getUserID(ctx)
-	if err != nil {
-		return status.Errorf(codes.Unauthenticated, err.Error())
-	}
-
-	// TODO: Implement streaming proxy requests
-	return status.Errorf(codes.Unimplemented, "streaming proxy requests not yet implemented via gRPC")
-}
-
 // CreatePayment creates a new payment
 func (s *Server) CreatePayment(ctx context.Context, req *pb.CreatePaymentRequest) (*pb.CreatePaymentResponse, error) {
 	_, err := getUserID(ctx)
 	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, err.Error())
+		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
 
 	// TODO: Implement payment creation via gRPC
@@ -495,7 +514,7 @@ func (s *Server) CreatePayment(ctx context.Context, req *pb.CreatePaymentRequest
 func (s *Server) GetTransactions(ctx context.Context, req *pb.GetTransactionsRequest) (*pb.GetTransactionsResponse, error) {
 	userID, err := getUserID(ctx)
 	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, err.Error())
+		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
 
 	transactions, err := s.billingService.GetTransactionsByUser(ctx, userID)
@@ -543,7 +562,7 @@ func (s *Server) GetTransactions(ctx context.Context, req *pb.GetTransactionsReq
 func (s *Server) GetSpendingInfo(ctx context.Context, req *pb.GetSpendingInfoRequest) (*pb.GetSpendingInfoResponse, error) {
 	_, err := getUserID(ctx)
 	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, err.Error())
+		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
 
 	// TODO: Implement spending info via gRPC (requires access to guard rails middleware)
@@ -554,7 +573,7 @@ func (s *Server) GetSpendingInfo(ctx context.Context, req *pb.GetSpendingInfoReq
 func (s *Server) CheckSpendingLimit(ctx context.Context, req *pb.CheckSpendingLimitRequest) (*pb.CheckSpendingLimitResponse, error) {
 	_, err := getUserID(ctx)
 	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, err.Error())
+		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
 
 	// TODO: Implement spending limit checks via gRPC (requires access to guard rails middleware)
@@ -563,23 +582,151 @@ func (s *Server) CheckSpendingLimit(ctx context.Context, req *pb.CheckSpendingLi
 
 // SetLoadBalancerStrategy sets the load balancing strategy
 func (s *Server) SetLoadBalancerStrategy(ctx context.Context, req *pb.SetLoadBalancerStrategyRequest) (*pb.SetLoadBalancerStrategyResponse, error) {
-	strategy := loadbalancer.Strategy(req.Strategy)
-	s.lbService.SetStrategy(strategy)
+	strategy, err := loadbalancer.ParseStrategy(req.Strategy)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	lb, err := s.pool(req.Pool)
+	if err != nil {
+		return nil, err
+	}
+	if err := lb.CheckStrategy(strategy); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if req.AffinityKey != "" {
+		source, err := loadbalancer.ParseAffinitySource(req.AffinityKey)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		affinity := lb.Affinity()
+		affinity.Source = source
+		lb.SetAffinity(affinity)
+	}
+	lb.SetStrategy(strategy)
 
 	return &pb.SetLoadBalancerStrategyResponse{
-		Strategy: req.Strategy,
-		Success:  true,
+		Strategy:    req.Strategy,
+		Success:     true,
+		AffinityKey: lb.Affinity().Source.String(),
 	}, nil
 }
 
-// GetLoadInfo retrieves load balancing information
+// pool returns the balancer of the named pool, or the global one if name
+// is empty
+func (s *Server) pool(name string) (*loadbalancer.LoadBalancerService, error) {
+	if name == "" {
+		return s.lbService, nil
+	}
+	lb := s.lbService.Pool(name)
+	if lb == nil {
+		return nil, status.Errorf(codes.NotFound, "load balancer pool %q not found", name)
+	}
+	return lb, nil
+}
+
+// affinityContext keys instance selections for the consistent-hash and
+// sticky-session strategies, as the pool they are made in, if any, is
+// configured to. Metadata stands in for headers, and fieldValue for the
+// request field.
+func (s *Server) affinityContext(ctx context.Context, fieldValue, pool string) context.Context {
+	lb := s.lbService
+	if p := lb.Pool(pool); p != nil {
+		lb = p
+	}
+	source := lb.Affinity().Source
+
+	header := http.Header{}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for k, v := range md {
+			header[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+	userID := ""
+	if id, err := getUserID(ctx); err == nil {
+		userID = id.String()
+	}
+	fields := map[string]interface{}{source.Name: fieldValue}
+	return loadbalancer.WithAffinityKey(ctx, source.Key(userID, header, fields))
+}
+
+// GetLoadInfo retrieves load balancing information, per instance and
+// totalled per provider
 func (s *Server) GetLoadInfo(ctx context.Context, req *pb.GetLoadInfoRequest) (*pb.GetLoadInfoResponse, error) {
-	// TODO: Implement load info via gRPC
-	return &pb.GetLoadInfoResponse{
+	loadType := req.Type
+	if loadType == "" {
+		loadType = "all"
+	}
+	if loadType != "server" && loadType != "provider" && loadType != "all" {
+		return nil, status.Errorf(codes.InvalidArgument, "type must be server, provider or all, got %q", req.Type)
+	}
+	lb, err := s.pool(req.Pool)
+	if err != nil {
+		return nil, err
+	}
+
+	loads := lb.GetAllLoads()
+	total := 0
+	for _, load := range loads {
+		total += load.ActiveConnections
+	}
+	share := func(connections int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(connections) / float64(total)
+	}
+
+	resp := &pb.GetLoadInfoResponse{
 		ServerLoad:      make([]*pb.LoadInfo, 0),
 		ProviderLoad:    make([]*pb.LoadInfo, 0),
-		CurrentStrategy: string(s.lbService.GetStrategy()),
-	}, nil
+		CurrentStrategy: string(lb.GetStrategy()),
+	}
+
+	if loadType != "provider" {
+		for _, load := range loads {
+			resp.ServerLoad = append(resp.ServerLoad, &pb.LoadInfo{
+				InstanceId:        load.InstanceID,
+				Provider:          load.Provider,
+				Connections:       int32(load.ActiveConnections),
+				AvgResponseTimeMs: float64(load.AvgResponseTime) / float64(time.Millisecond),
+				LoadScore:         share(load.ActiveConnections),
+			})
+		}
+		sort.Slice(resp.ServerLoad, func(i, j int) bool {
+			return resp.ServerLoad[i].InstanceId < resp.ServerLoad[j].InstanceId
+		})
+	}
+
+	if loadType != "server" {
+		// A provider's response time is the mean over its instances that have one
+		providers := make(map[string]*pb.LoadInfo)
+		timed := make(map[string]int)
+		for _, load := range loads {
+			p, ok := providers[load.Provider]
+			if !ok {
+				p = &pb.LoadInfo{Provider: load.Provider}
+				providers[load.Provider] = p
+			}
+			p.Connections += int32(load.ActiveConnections)
+			if load.AvgResponseTime > 0 {
+				p.AvgResponseTimeMs += float64(load.AvgResponseTime) / float64(time.Millisecond)
+				timed[load.Provider]++
+			}
+		}
+		for name, p := range providers {
+			if timed[name] > 0 {
+				p.AvgResponseTimeMs /= float64(timed[name])
+			}
+			p.LoadScore = share(int(p.Connections))
+			resp.ProviderLoad = append(resp.ProviderLoad, p)
+		}
+		sort.Slice(resp.ProviderLoad, func(i, j int) bool {
+			return resp.ProviderLoad[i].Provider < resp.ProviderLoad[j].Provider
+		})
+	}
+
+	return resp, nil
 }
 
 // ReserveGPUs reserves multiple GPUs
@@ -595,6 +742,14 @@ func (s *Server) ReserveGPUs(ctx context.Context, req *pb.ReserveGPUsRequest) (*
 		provider = gpu.ProviderAll
 	}
 
+	if req.Pool != "" && (req.Gang || s.lbService == nil) {
+		return nil, status.Errorf(codes.InvalidArgument, "pool requires load balancing and cannot be used with gang reservations")
+	}
+
+	if req.Gang {
+		return s.reserveGang(ctx, req, provider)
+	}
+
 	// List all instances
 	instances, err := s.gpuService.ListInstances(ctx, provider)
 	if err != nil {
@@ -614,6 +769,12 @@ func (s *Server) ReserveGPUs(ctx context.Context, req *pb.ReserveGPUsRequest) (*
 		instances = s.gpuService.FilterInstances(instances, filters)
 	}
 
+	if req.Pool != "" {
+		if instances, err = s.lbService.PoolMembers(req.Pool, instances); err != nil {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+	}
+
 	// Check if enough instances available
 	if len(instances) < count {
 		return nil, status.Errorf(codes.FailedPrecondition,
@@ -623,34 +784,34 @@ func (s *Server) ReserveGPUs(ctx context.Context, req *pb.ReserveGPUsRequest) (*
 	// Reserve instances
 	reserved := make([]*pb.GPUInstance, 0, count)
 	errorMessages := make([]string, 0)
+	selectCtx := ctx
+	if s.lbService != nil {
+		selectCtx = loadbalancer.WithPool(s.affinityContext(ctx, req.AffinityKey, req.Pool), req.Pool)
+	}
 
-	for i := 0; i < count && i < len(instances); i++ {
-		var selected *models.GPUInstance
+	remaining := append([]models.GPUInstance(nil), instances...)
+	for i := 0; i < count && len(remaining) > 0; i++ {
+		selected := remaining[0]
 
-		// Use load balancer to select instance if available
+		// Use load balancer to select instance if available. Only the first
+		// instance is pinned to the affinity key, so the rest spread out.
 		if s.lbService != nil {
-			selected, err = s.lbService.SelectInstance(ctx, instances[i:])
-			if err != nil {
-				selected = &instances[i]
+			if picked, err := s.lbService.SelectInstance(selectCtx, remaining); err == nil {
+				selected = *picked
 			}
-		} else {
-			selected = &instances[i]
+			selectCtx = loadbalancer.WithoutAffinityKey(selectCtx)
 		}
+		remaining = slices.DeleteFunc(remaining, func(inst models.GPUInstance) bool { return inst.ID == selected.ID })
 
-		// Clean up instance ID for provider
-		instanceID := selected.ID
+		// Create the instance; providers accept offer IDs as listed
 		providerType := gpu.Provider(selected.Provider)
-		if providerType == gpu.ProviderVastAI && len(instanceID) > 5 {
-			instanceID = instanceID[5:]
-		} else if providerType == gpu.ProviderIONet && len(instanceID) > 6 {
-			instanceID = instanceID[6:]
-		}
-
-		// Create the instance
 		config := make(map[string]interface{})
-		contractID, err := s.gpuService.CreateInstance(ctx, providerType, instanceID, config)
+		contractID, err := s.gpuService.CreateInstance(ctx, providerType, selected.ID, config)
 		if err != nil {
 			errorMessages = append(errorMessages, fmt.Sprintf("%s: %v", selected.ID, err))
+			if s.lbService != nil {
+				s.lbService.TrackFailure(selected.ID, err)
+			}
 			continue
 		}
 
@@ -703,11 +864,223 @@ func (s *Server) ReserveGPUs(ctx context.Context, req *pb.ReserveGPUsRequest) (*
 	}, nil
 }
 
+// reserveGang serves ReserveGPUs in gang mode: every instance is created or
+// none is
+func (s *Server) reserveGang(ctx context.Context, req *pb.ReserveGPUsRequest, provider gpu.Provider) (*pb.ReserveGPUsResponse, error) {
+	filters := make(map[string]interface{})
+	if req.MinVram > 0 {
+		filters["min_vram"] = int(req.MinVram)
+	}
+	if req.MaxPrice > 0 {
+		filters["max_price"] = req.MaxPrice
+	}
+
+	gang, err := s.gpuService.ReserveGang(ctx, &gpu.GangRequest{
+		Count:          int(req.Count),
+		Provider:       provider,
+		Filters:        filters,
+		Config:         make(map[string]interface{}),
+		SameProvider:   req.SameProvider,
+		SameRegion:     req.SameRegion,
+		SameDatacenter: req.SameDatacenter,
+		ReadyTimeout:   time.Duration(req.ReadyTimeoutSeconds) * time.Second,
+	})
+	if err != nil {
+		var gangErr *gpu.GangError
+		if errors.As(err, &gangErr) && len(gangErr.Leaked) == 0 {
+			return nil, status.Error(codes.Aborted, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	reserved := make([]*pb.GPUInstance, 0, len(gang.Members))
+	for _, m := range gang.Members {
+		if s.lbService != nil {
+			s.lbService.TrackConnection(m.OfferID)
+		}
+
+		metadata := map[string]string{"contract_id": m.InstanceID}
+		if m.Datacenter != "" {
+			metadata["datacenter"] = m.Datacenter
+		}
+		reserved = append(reserved, &pb.GPUInstance{
+			Id:           m.OfferID,
+			Provider:     string(m.Provider),
+			Status:       "reserved",
+			PricePerHour: m.PricePerHour,
+			VramGb:       int32(m.VRAM),
+			GpuModel:     m.GPUName,
+			NumGpus:      int32(m.GPUCount),
+			Location:     m.Region,
+			Metadata:     metadata,
+		})
+	}
+
+	return &pb.ReserveGPUsResponse{
+		ReservedInstances: reserved,
+		ReservedCount:     int32(len(reserved)),
+		Message:           fmt.Sprintf("Reserved a gang of %d GPU instance(s)", len(reserved)),
+	}, nil
+}
+
+// CreateBooking books compute for a future window
+func (s *Server) CreateBooking(ctx context.Context, req *pb.CreateBookingRequest) (*pb.CreateBookingResponse, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if s.bookingCalendar == nil {
+		return nil, status.Error(codes.Unavailable, "bookings are not enabled")
+	}
+
+	booking, err := s.bookingCalendar.Book(ctx, userID.String(), &compute.BookingRequest{
+		ReservationRequest: compute.ReservationRequest{
+			Provider:     compute.ComputeProvider(req.Provider),
+			ComputeType:  compute.ComputeType(req.ComputeType),
+			GPUModel:     req.GpuModel,
+			TPUVersion:   req.TpuVersion,
+			Count:        int(req.Count),
+			Region:       req.Region,
+			MinVRAM:      int(req.MinVram),
+			MaxCostPerHr: req.MaxCostPerHr,
+			WebhookURL:   req.WebhookUrl,
+		},
+		StartTime:          time.Unix(req.StartTime, 0),
+		EndTime:            time.Unix(req.EndTime, 0),
+		CancellationPolicy: req.CancellationPolicy,
+	})
+	if errors.Is(err, compute.ErrBookingConflict) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.CreateBookingResponse{Booking: bookingToProto(booking)}, nil
+}
+
+// ListBookings returns the caller's bookings
+func (s *Server) ListBookings(ctx context.Context, req *pb.ListBookingsRequest) (*pb.ListBookingsResponse, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if s.bookingCalendar == nil {
+		return nil, status.Error(codes.Unavailable, "bookings are not enabled")
+	}
+
+	bookings, err := s.bookingCalendar.UserBookings(ctx, userID.String())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list bookings: %v", err)
+	}
+
+	resp := &pb.ListBookingsResponse{Bookings: make([]*pb.Booking, len(bookings))}
+	for i, b := range bookings {
+		resp.Bookings[i] = bookingToProto(b)
+	}
+	return resp, nil
+}
+
+// CancelBooking cancels one of the caller's bookings under its cancellation policy
+func (s *Server) CancelBooking(ctx context.Context, req *pb.CancelBookingRequest) (*pb.CancelBookingResponse, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if s.bookingCalendar == nil {
+		return nil, status.Error(codes.Unavailable, "bookings are not enabled")
+	}
+
+	booking, err := s.bookingCalendar.Get(ctx, req.BookingId)
+	if errors.Is(err, compute.ErrBookingNotFound) || (err == nil && booking.UserID != userID.String()) {
+		return nil, status.Error(codes.NotFound, "booking not found")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get booking: %v", err)
+	}
+
+	canceled, err := s.bookingCalendar.Cancel(ctx, booking.ID)
+	if err != nil && canceled == nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.CancelBookingResponse{Booking: bookingToProto(canceled)}, nil
+}
+
+// GetBookingCalendar returns booked and available capacity over a time range
+func (s *Server) GetBookingCalendar(ctx context.Context, req *pb.GetBookingCalendarRequest) (*pb.GetBookingCalendarResponse, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if s.bookingCalendar == nil {
+		return nil, status.Error(codes.Unavailable, "bookings are not enabled")
+	}
+
+	from := time.Now()
+	if req.From != 0 {
+		from = time.Unix(req.From, 0)
+	}
+	to := from.Add(7 * 24 * time.Hour)
+	if req.To != 0 {
+		to = time.Unix(req.To, 0)
+	}
+
+	view, err := s.bookingCalendar.Calendar(ctx, compute.ComputeType(req.ComputeType), from, to, userID.String())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp := &pb.GetBookingCalendarResponse{Capacity: int32(view.Capacity)}
+	for _, slot := range view.Slots {
+		resp.Slots = append(resp.Slots, &pb.CalendarSlot{
+			Start:     slot.Start.Unix(),
+			End:       slot.End.Unix(),
+			Booked:    int32(slot.Booked),
+			Available: int32(slot.Available),
+		})
+	}
+	for _, entry := range view.Bookings {
+		resp.Bookings = append(resp.Bookings, &pb.CalendarEntry{
+			BookingId: entry.BookingID,
+			Mine:      entry.Mine,
+			GpuModel:  entry.GPUModel,
+			Count:     int32(entry.Count),
+			StartTime: entry.StartTime.Unix(),
+			EndTime:   entry.EndTime.Unix(),
+			Status:    entry.Status,
+		})
+	}
+	return resp, nil
+}
+
+func bookingToProto(b *compute.Booking) *pb.Booking {
+	return &pb.Booking{
+		Id:                 b.ID,
+		Status:             b.Status,
+		Provider:           string(b.Request.Provider),
+		ComputeType:        string(b.Request.ComputeType),
+		GpuModel:           b.Request.GPUModel,
+		Count:              int32(b.Request.Count),
+		StartTime:          b.StartTime.Unix(),
+		EndTime:            b.EndTime.Unix(),
+		ProvisionAt:        b.ProvisionAt.Unix(),
+		CancellationPolicy: b.CancellationPolicy,
+		EstimatedCost:      b.EstimatedCost,
+		CancellationFee:    b.CancellationFee,
+		ReservationId:      b.ReservationID,
+		FailureReason:      b.FailureReason,
+	}
+}
+
 // SendCUICMessage handles CUIC protocol messages
 func (s *Server) SendCUICMessage(ctx context.Context, req *pb.CUICMessageRequest) (*pb.CUICMessageResponse, error) {
 	userID, err := getUserID(ctx)
 	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, err.Error())
+		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
 
 	// Add user ID to context for CUIC server (using the same pattern as GetUserID expects)
@@ -773,7 +1146,7 @@ func (s *Server) StreamCUICMessage(stream pb.GPUProxyService_StreamCUICMessageSe
 	ctx := stream.Context()
 	userID, err := getUserID(ctx)
 	if err != nil {
-		return status.Errorf(codes.Unauthenticated, err.Error())
+		return status.Error(codes.Unauthenticated, err.Error())
 	}
 
 	// Add user ID to context (using the same pattern as GetUserID expects)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aiserve/gpuproxy/internal/auth"
@@ -105,6 +106,10 @@ func (s *LangChainServer) GetTools() []Tool {
 						"type":        "number",
 						"description": "Maximum price per hour in USD",
 					},
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Offer search, e.g. 'vram>=80 gpu in (H100, A100) price<2.5 region=us-* order by price_per_tflop limit 10'. Fields: " + strings.Join(gpu.OfferQueryFields(), ", "),
+					},
 				},
 			},
 		},
@@ -321,19 +326,21 @@ func (s *LangChainServer) toolListGPUInstances(ctx context.Context, inputs map[s
 		provider = "all"
 	}
 
+	queryText, _ := inputs["query"].(string)
+	query, err := gpu.ParseOfferQuery(queryText)
+	if err != nil {
+		return nil, err
+	}
+
 	instances, err := s.gpuService.ListInstances(ctx, gpu.Provider(provider))
 	if err != nil {
 		return nil, err
 	}
 
 	// Apply filters
-	if minVRAM, ok := inputs["min_vram"].(float64); ok {
-		filters := map[string]interface{}{"min_vram": int(minVRAM)}
-		instances = s.gpuService.FilterInstances(instances, filters)
-	}
-	if maxPrice, ok := inputs["max_price"].(float64); ok {
-		filters := map[string]interface{}{"max_price": maxPrice}
-		instances = s.gpuService.FilterInstances(instances, filters)
+	query = query.And(gpu.FiltersQuery(inputs))
+	if !query.Empty() {
+		instances = query.Apply(instances)
 	}
 
 	return map[string]interface{}{
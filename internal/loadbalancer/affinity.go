@@ -0,0 +1,359 @@
+package loadbalancer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+)
+
+// KeyedLoadBalancer is a load balancer that can keep requests sharing an
+// affinity key, such as a user or conversation, on the same instance
+type KeyedLoadBalancer interface {
+	LoadBalancer
+	SelectInstanceForKey(key string, instances []models.GPUInstance) (*models.GPUInstance, error)
+}
+
+type affinityKeyContextKey struct{}
+
+// WithAffinityKey returns a context whose instance selections are keyed on
+// key. An empty key leaves ctx unchanged.
+func WithAffinityKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, affinityKeyContextKey{}, key)
+}
+
+// WithoutAffinityKey returns a context whose instance selections are not
+// keyed, e.g. for the instances after the first of a multi-instance request
+func WithoutAffinityKey(ctx context.Context) context.Context {
+	if AffinityKeyFromContext(ctx) == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, affinityKeyContextKey{}, "")
+}
+
+// AffinityKeyFromContext returns the affinity key set with WithAffinityKey
+func AffinityKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(affinityKeyContextKey{}).(string)
+	return key
+}
+
+// Affinity key sources
+const (
+	AffinityUser   = "user"
+	AffinityAPIKey = "api_key"
+	AffinityHeader = "header"
+	AffinityField  = "field"
+)
+
+// AffinitySource says which part of a request its affinity key comes from:
+// the user, the API key, a header ("header:X-Session-ID") or a top-level
+// request field ("field:conversation_id")
+type AffinitySource struct {
+	Kind string
+	Name string // Header or field name
+}
+
+// ParseAffinitySource parses "user", "api_key", "header:<name>" or
+// "field:<name>"
+func ParseAffinitySource(s string) (AffinitySource, error) {
+	kind, name, _ := strings.Cut(strings.TrimSpace(s), ":")
+	src := AffinitySource{Kind: strings.ToLower(kind), Name: strings.TrimSpace(name)}
+	switch src.Kind {
+	case AffinityUser, AffinityAPIKey:
+		if src.Name != "" {
+			return AffinitySource{}, fmt.Errorf("affinity key %q takes no name", src.Kind)
+		}
+	case AffinityHeader, AffinityField:
+		if src.Name == "" {
+			return AffinitySource{}, fmt.Errorf("affinity key %q needs a name, e.g. %s:session_id", src.Kind, src.Kind)
+		}
+	default:
+		return AffinitySource{}, fmt.Errorf("unknown affinity key %q; use user, api_key, header:<name> or field:<name>", s)
+	}
+	return src, nil
+}
+
+func (s AffinitySource) String() string {
+	if s.Name == "" {
+		return s.Kind
+	}
+	return s.Kind + ":" + s.Name
+}
+
+// Key returns the affinity key for a request, or "" if it has none. API
+// keys are hashed so they are never held in balancer state.
+func (s AffinitySource) Key(userID string, header http.Header, fields map[string]interface{}) string {
+	var key string
+	switch s.Kind {
+	case AffinityUser:
+		key = userID
+	case AffinityAPIKey:
+		if apiKey := header.Get("X-API-Key"); apiKey != "" {
+			sum := sha256.Sum256([]byte(apiKey))
+			key = hex.EncodeToString(sum[:8])
+		}
+	case AffinityHeader:
+		key = header.Get(s.Name)
+	case AffinityField:
+		switch v := fields[s.Name].(type) {
+		case string:
+			key = v
+		case float64:
+			key = strconv.FormatFloat(v, 'f', -1, 64)
+		case nil:
+		default:
+			key = fmt.Sprint(v)
+		}
+	}
+	if key == "" {
+		return ""
+	}
+	return s.Kind + ":" + key
+}
+
+// AffinityConfig tunes the consistent-hash and sticky-session strategies
+type AffinityConfig struct {
+	Source     AffinitySource
+	LoadFactor float64       // An instance takes at most this times the average active connections
+	SessionTTL time.Duration // Sticky sessions idle this long are forgotten
+}
+
+// DefaultAffinityConfig returns the settings used for anything left zero
+func DefaultAffinityConfig() AffinityConfig {
+	return AffinityConfig{
+		Source:     AffinitySource{Kind: AffinityUser},
+		LoadFactor: 1.25,
+		SessionTTL: 30 * time.Minute,
+	}
+}
+
+func (c AffinityConfig) withDefaults() AffinityConfig {
+	d := DefaultAffinityConfig()
+	if c.Source.Kind == "" {
+		c.Source = d.Source
+	}
+	if c.LoadFactor <= 1 {
+		c.LoadFactor = d.LoadFactor
+	}
+	if c.SessionTTL <= 0 {
+		c.SessionTTL = d.SessionTTL
+	}
+	return c
+}
+
+// ringReplicas is how many points each instance has on the hash ring
+const ringReplicas = 128
+
+type ringPoint struct {
+	hash uint64
+	id   string
+}
+
+// ConsistentHashLB maps affinity keys onto a hash ring of instances, so only
+// about 1/n of keys move when an instance joins or leaves. Loads are bounded:
+// an instance already carrying LoadFactor times the average active
+// connections passes the key on to the next one round the ring. Requests
+// without a key go to the least connected instance.
+type ConsistentHashLB struct {
+	*BaseLoadBalancer
+	loadFactor float64
+	ring       []ringPoint
+	ringIDs    string // Instance IDs the ring was built from
+}
+
+func newConsistentHash(strategy Strategy, cfg AffinityConfig) *ConsistentHashLB {
+	return &ConsistentHashLB{BaseLoadBalancer: newBase(strategy), loadFactor: cfg.LoadFactor}
+}
+
+func (lb *ConsistentHashLB) SelectInstance(instances []models.GPUInstance) (*models.GPUInstance, error) {
+	return lb.SelectInstanceForKey("", instances)
+}
+
+func (lb *ConsistentHashLB) SelectInstanceForKey(key string, instances []models.GPUInstance) (*models.GPUInstance, error) {
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("no instances available")
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	return lb.selectUnsafe(key, instances), nil
+}
+
+// selectUnsafe must be called while holding the lock
+func (lb *ConsistentHashLB) selectUnsafe(key string, instances []models.GPUInstance) *models.GPUInstance {
+	byID := make(map[string]int, len(instances))
+	total := 0
+	for i := range instances {
+		byID[instances[i].ID] = i
+		total += lb.ensureLoadUnsafe(instances[i].ID, instances[i].Provider).ActiveConnections
+	}
+
+	if key == "" {
+		return leastConnectionsUnsafe(lb.BaseLoadBalancer, instances)
+	}
+
+	lb.buildRingUnsafe(instances)
+	capacity := int(math.Ceil(lb.loadFactor * float64(total+1) / float64(len(byID))))
+
+	h := hashKey(key)
+	start := sort.Search(len(lb.ring), func(i int) bool { return lb.ring[i].hash >= h })
+	for i := 0; i < len(lb.ring); i++ {
+		point := lb.ring[(start+i)%len(lb.ring)]
+		if lb.loads[point.id].ActiveConnections < capacity {
+			return &instances[byID[point.id]]
+		}
+	}
+	// Unreachable while capacity exceeds the average load
+	return &instances[byID[lb.ring[start%len(lb.ring)].id]]
+}
+
+// buildRingUnsafe rebuilds the ring if the instances have changed
+func (lb *ConsistentHashLB) buildRingUnsafe(instances []models.GPUInstance) {
+	ids := make([]string, 0, len(instances))
+	for _, inst := range instances {
+		ids = append(ids, inst.ID)
+	}
+	sort.Strings(ids)
+	signature := strings.Join(ids, "\x00")
+	if signature == lb.ringIDs {
+		return
+	}
+
+	lb.ring = lb.ring[:0]
+	for i, id := range ids {
+		if i > 0 && id == ids[i-1] {
+			continue
+		}
+		for r := 0; r < ringReplicas; r++ {
+			lb.ring = append(lb.ring, ringPoint{hash: hashKey(id + "#" + strconv.Itoa(r)), id: id})
+		}
+	}
+	sort.Slice(lb.ring, func(i, j int) bool { return lb.ring[i].hash < lb.ring[j].hash })
+	lb.ringIDs = signature
+}
+
+// hashKey is FNV-1a with a final mix, which spreads the short, similar
+// strings ring points are made from
+func hashKey(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// leastConnectionsUnsafe must be called while holding the lock
+func leastConnectionsUnsafe(lb *BaseLoadBalancer, instances []models.GPUInstance) *models.GPUInstance {
+	selected := &instances[0]
+	minConnections := math.MaxInt
+	for i := range instances {
+		load := lb.ensureLoadUnsafe(instances[i].ID, instances[i].Provider)
+		if load.ActiveConnections < minConnections {
+			minConnections = load.ActiveConnections
+			selected = &instances[i]
+		}
+	}
+	return selected
+}
+
+// StickySessionLB pins each affinity key to the instance it was first sent
+// to for as long as that instance is offered and the session stays in use,
+// even as other instances join. New and orphaned keys are placed by
+// consistent hashing.
+type StickySessionLB struct {
+	*ConsistentHashLB
+	ttl       time.Duration
+	sessions  map[string]*stickySession
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type stickySession struct {
+	instanceID string
+	lastUsed   time.Time
+}
+
+func newStickySession(strategy Strategy, cfg AffinityConfig) *StickySessionLB {
+	return &StickySessionLB{
+		ConsistentHashLB: newConsistentHash(strategy, cfg),
+		ttl:              cfg.SessionTTL,
+		sessions:         make(map[string]*stickySession),
+		now:              time.Now,
+	}
+}
+
+func (lb *StickySessionLB) SelectInstance(instances []models.GPUInstance) (*models.GPUInstance, error) {
+	return lb.SelectInstanceForKey("", instances)
+}
+
+func (lb *StickySessionLB) SelectInstanceForKey(key string, instances []models.GPUInstance) (*models.GPUInstance, error) {
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("no instances available")
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	now := lb.now()
+	lb.sweepUnsafe(now)
+
+	if key == "" {
+		return lb.selectUnsafe("", instances), nil
+	}
+
+	if session, ok := lb.sessions[key]; ok && now.Sub(session.lastUsed) < lb.ttl {
+		for i := range instances {
+			if instances[i].ID == session.instanceID {
+				session.lastUsed = now
+				lb.ensureLoadUnsafe(instances[i].ID, instances[i].Provider)
+				return &instances[i], nil
+			}
+		}
+	}
+
+	selected := lb.selectUnsafe(key, instances)
+	lb.sessions[key] = &stickySession{instanceID: selected.ID, lastUsed: now}
+	return selected, nil
+}
+
+// Sessions returns how many sticky sessions are held
+func (lb *StickySessionLB) Sessions() int {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	return len(lb.sessions)
+}
+
+// sweepUnsafe forgets idle sessions, at most once per TTL
+func (lb *StickySessionLB) sweepUnsafe(now time.Time) {
+	if now.Sub(lb.lastSweep) < lb.ttl {
+		return
+	}
+	for key, session := range lb.sessions {
+		if now.Sub(session.lastUsed) >= lb.ttl {
+			delete(lb.sessions, key)
+		}
+	}
+	lb.lastSweep = now
+}
+
+var (
+	_ KeyedLoadBalancer = (*ConsistentHashLB)(nil)
+	_ KeyedLoadBalancer = (*StickySessionLB)(nil)
+)
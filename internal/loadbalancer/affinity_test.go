@@ -0,0 +1,206 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testInstances(n int) []models.GPUInstance {
+	instances := make([]models.GPUInstance, n)
+	for i := range instances {
+		instances[i] = models.GPUInstance{ID: fmt.Sprintf("gpu-%d", i), Provider: "vast.ai"}
+	}
+	return instances
+}
+
+func assignKeys(t *testing.T, lb KeyedLoadBalancer, keys int, instances []models.GPUInstance) map[string]string {
+	t.Helper()
+	assigned := make(map[string]string, keys)
+	for k := 0; k < keys; k++ {
+		key := fmt.Sprintf("user-%d", k)
+		selected, err := lb.SelectInstanceForKey(key, instances)
+		require.NoError(t, err)
+		assigned[key] = selected.ID
+	}
+	return assigned
+}
+
+func TestConsistentHashIsStable(t *testing.T) {
+	lb := newConsistentHash(StrategyConsistentHash, DefaultAffinityConfig())
+	instances := testInstances(5)
+
+	first := assignKeys(t, lb, 200, instances)
+	assert.Equal(t, first, assignKeys(t, lb, 200, instances))
+
+	// Order doesn't matter, only membership
+	reversed := make([]models.GPUInstance, len(instances))
+	for i := range instances {
+		reversed[len(instances)-1-i] = instances[i]
+	}
+	assert.Equal(t, first, assignKeys(t, lb, 200, reversed))
+
+	counts := map[string]int{}
+	for _, id := range first {
+		counts[id]++
+	}
+	assert.Len(t, counts, 5, "keys spread over every instance")
+}
+
+func TestConsistentHashRemapsMinimally(t *testing.T) {
+	lb := newConsistentHash(StrategyConsistentHash, DefaultAffinityConfig())
+	const keys = 2000
+	instances := testInstances(10)
+	before := assignKeys(t, lb, keys, instances)
+
+	// An instance joins: only keys moving to it change
+	grown := testInstances(11)
+	after := assignKeys(t, lb, keys, grown)
+	moved := 0
+	for key, id := range after {
+		if id != before[key] {
+			moved++
+			assert.Equal(t, "gpu-10", id)
+		}
+	}
+	assert.Less(t, moved, keys*2/11, "about 1/11 of keys move")
+	assert.Greater(t, moved, 0)
+
+	// An instance leaves: only its keys change
+	shrunk := append(testInstances(10)[:3:3], testInstances(10)[4:]...)
+	after = assignKeys(t, lb, keys, shrunk)
+	for key, id := range after {
+		if before[key] != "gpu-3" {
+			assert.Equal(t, before[key], id, key)
+		}
+	}
+}
+
+func TestConsistentHashBoundsLoad(t *testing.T) {
+	lb := newConsistentHash(StrategyConsistentHash, AffinityConfig{LoadFactor: 1.25})
+	instances := testInstances(4)
+
+	// Every request carries the same key, so without bounds one instance
+	// would take them all
+	for i := 0; i < 40; i++ {
+		selected, err := lb.SelectInstanceForKey("hot-key", instances)
+		require.NoError(t, err)
+		lb.RecordConnection(selected.ID)
+	}
+
+	for _, load := range lb.GetAllLoads() {
+		assert.LessOrEqual(t, load.ActiveConnections, 13, load.InstanceID)
+	}
+}
+
+func TestConsistentHashWithoutKey(t *testing.T) {
+	lb := newConsistentHash(StrategyConsistentHash, DefaultAffinityConfig())
+	instances := testInstances(2)
+
+	selected, err := lb.SelectInstance(instances)
+	require.NoError(t, err)
+	lb.RecordConnection(selected.ID)
+
+	next, err := lb.SelectInstance(instances)
+	require.NoError(t, err)
+	assert.NotEqual(t, selected.ID, next.ID, "falls back to least connections")
+
+	_, err = lb.SelectInstanceForKey("k", nil)
+	assert.Error(t, err)
+}
+
+func TestStickySession(t *testing.T) {
+	lb := newStickySession(StrategyStickySession, AffinityConfig{SessionTTL: time.Minute})
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	lb.now = clock.now
+
+	instances := testInstances(10)
+	before := assignKeys(t, lb, 500, instances)
+	assert.Equal(t, 500, lb.Sessions())
+
+	// Unlike consistent hashing, no session moves when instances join
+	assert.Equal(t, before, assignKeys(t, lb, 500, testInstances(12)))
+
+	// A session whose instance has gone is placed again
+	selected, err := lb.SelectInstanceForKey("user-0", instances[1:2])
+	require.NoError(t, err)
+	assert.Equal(t, "gpu-1", selected.ID)
+	selected, err = lb.SelectInstanceForKey("user-0", instances)
+	require.NoError(t, err)
+	assert.Equal(t, "gpu-1", selected.ID, "and sticks to its new instance")
+
+	clock.advance(time.Minute)
+	_, err = lb.SelectInstanceForKey("user-0", instances)
+	require.NoError(t, err)
+	assert.Equal(t, 1, lb.Sessions(), "idle sessions are forgotten")
+}
+
+func TestAffinitySource(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Session-ID", "s-1")
+	header.Set("X-API-Key", "secret")
+	fields := map[string]interface{}{"conversation_id": "c-9", "seed": 42.0}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"user", "user:u-1"},
+		{"header:X-Session-ID", "header:s-1"},
+		{"header:x-session-id", "header:s-1"},
+		{"field:conversation_id", "field:c-9"},
+		{"field:seed", "field:42"},
+		{"field:missing", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			src, err := ParseAffinitySource(tt.source)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, src.Key("u-1", header, fields))
+		})
+	}
+
+	src, err := ParseAffinitySource("api_key")
+	require.NoError(t, err)
+	key := src.Key("", header, nil)
+	assert.NotContains(t, key, "secret", "API keys are hashed")
+	assert.Equal(t, key, src.Key("other-user", header, nil))
+
+	for _, bad := range []string{"", "cookie", "header", "field:", "user:x"} {
+		_, err := ParseAffinitySource(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestLoadBalancerServiceAffinity(t *testing.T) {
+	s := NewLoadBalancerService(StrategyRoundRobin)
+	instances := testInstances(5)
+
+	_, err := ParseStrategy("random")
+	assert.Error(t, err)
+	strategy, err := ParseStrategy("sticky_session")
+	require.NoError(t, err)
+	s.SetStrategy(strategy)
+
+	ctx := WithAffinityKey(context.Background(), "user:alice")
+	first, err := s.SelectInstance(ctx, instances)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		selected, err := s.SelectInstance(ctx, instances)
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, selected.ID)
+	}
+
+	// Health still comes first: an ejected instance loses its sessions
+	s.SetHealthConfig(HealthConfig{Enabled: true, ConsecutiveFailures: 1})
+	s.TrackFailure(first.ID, errBoom)
+	selected, err := s.SelectInstance(ctx, instances)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, selected.ID)
+}
@@ -0,0 +1,166 @@
+package loadbalancer
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// simWorkload is a simulated fleet: most instances are healthy, a few are
+// degraded, and every instance slows down as requests queue on it
+type simWorkload struct {
+	instances int
+	degraded  int           // The first degraded instances are this much slower
+	slowdown  float64       // How much slower degraded instances are
+	latency   time.Duration // Healthy latency with nothing else in flight
+	queueing  float64       // Extra latency per request already in flight, as a fraction
+	interval  time.Duration // Mean time between requests
+	seed      uint64
+}
+
+var defaultWorkload = simWorkload{
+	instances: 20,
+	degraded:  2,
+	slowdown:  4,
+	latency:   50 * time.Millisecond,
+	queueing:  0.2,
+	interval:  5 * time.Millisecond,
+	seed:      1,
+}
+
+type simCompletion struct {
+	at       time.Time
+	instance int
+	latency  time.Duration
+}
+
+type completionHeap []simCompletion
+
+func (h completionHeap) Len() int           { return len(h) }
+func (h completionHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h completionHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *completionHeap) Push(x any)        { *h = append(*h, x.(simCompletion)) }
+func (h *completionHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// simulate sends requests through a fresh balancer for strategy in virtual
+// time and returns their latencies
+func (w simWorkload) simulate(strategy Strategy, requests int) []time.Duration {
+	rng := rand.New(rand.NewPCG(w.seed, 0))
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	lb := NewLoadBalancer(strategy)
+	if p2c, ok := lb.(*P2CLB); ok {
+		p2c.now = clock.now
+		p2c.intn = rng.IntN
+	}
+
+	instances := make([]models.GPUInstance, w.instances)
+	index := make(map[string]int, w.instances)
+	for i := range instances {
+		instances[i] = models.GPUInstance{ID: fmt.Sprintf("gpu-%d", i), Provider: "sim"}
+		index[instances[i].ID] = i
+	}
+	inFlight := make([]int, w.instances)
+
+	pending := &completionHeap{}
+	complete := func(until time.Time) {
+		for pending.Len() > 0 && !(*pending)[0].at.After(until) {
+			c := heap.Pop(pending).(simCompletion)
+			clock.t = c.at
+			inFlight[c.instance]--
+			id := instances[c.instance].ID
+			lb.RecordDisconnection(id)
+			lb.RecordResponseTime(id, c.latency)
+		}
+	}
+
+	latencies := make([]time.Duration, 0, requests)
+	now := clock.now()
+	for r := 0; r < requests; r++ {
+		now = now.Add(time.Duration(rng.ExpFloat64() * float64(w.interval)))
+		complete(now)
+		clock.t = now
+
+		selected, err := lb.SelectInstance(instances)
+		if err != nil {
+			panic(err)
+		}
+		i := index[selected.ID]
+		lb.RecordConnection(selected.ID)
+
+		latency := float64(w.latency) * (1 + w.queueing*float64(inFlight[i])) * math.Exp(rng.NormFloat64()*0.2)
+		if i < w.degraded {
+			latency *= w.slowdown
+		}
+		inFlight[i]++
+		heap.Push(pending, simCompletion{at: now.Add(time.Duration(latency)), instance: i, latency: time.Duration(latency)})
+		latencies = append(latencies, time.Duration(latency))
+	}
+	return latencies
+}
+
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	return sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+}
+
+func TestP2CTailLatency(t *testing.T) {
+	const requests = 20000
+	p99 := make(map[Strategy]time.Duration)
+	for _, strategy := range Strategies() {
+		p99[strategy] = percentile(defaultWorkload.simulate(strategy, requests), 0.99)
+		t.Logf("%-22s p99 %v", strategy, p99[strategy])
+	}
+
+	for _, strategy := range Strategies() {
+		if strategy != StrategyP2C {
+			assert.Less(t, p99[StrategyP2C], p99[strategy], "p2c should beat %s", strategy)
+		}
+	}
+}
+
+// BenchmarkSelectInstance measures selection alone as the fleet grows
+func BenchmarkSelectInstance(b *testing.B) {
+	for _, strategy := range Strategies() {
+		for _, n := range []int{10, 1000, 10000} {
+			b.Run(fmt.Sprintf("%s/%d", strategy, n), func(b *testing.B) {
+				lb := NewLoadBalancer(strategy)
+				instances := testInstances(n)
+				for i := range instances {
+					lb.SelectInstance(instances[i : i+1])
+					lb.RecordResponseTime(instances[i].ID, time.Duration(i%50+1)*time.Millisecond)
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					lb.SelectInstance(instances)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkSimulatedWorkload reports the latency each strategy gives the
+// simulated workload, in virtual milliseconds
+func BenchmarkSimulatedWorkload(b *testing.B) {
+	for _, strategy := range Strategies() {
+		b.Run(string(strategy), func(b *testing.B) {
+			latencies := defaultWorkload.simulate(strategy, max(b.N, 1000))
+			b.ReportMetric(float64(percentile(latencies, 0.5))/float64(time.Millisecond), "p50-ms")
+			b.ReportMetric(float64(percentile(latencies, 0.99))/float64(time.Millisecond), "p99-ms")
+			b.ReportMetric(float64(percentile(latencies, 0.999))/float64(time.Millisecond), "p999-ms")
+		})
+	}
+}
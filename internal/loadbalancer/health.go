@@ -0,0 +1,503 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// HealthConfig controls passive outlier ejection and active health probes
+type HealthConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	UnhealthyThreshold  float64       `yaml:"unhealthy_threshold"`  // Error rate over the window that ejects an instance
+	Window              int           `yaml:"window"`               // Recent results the error rate is taken over
+	MinRequests         int           `yaml:"min_requests"`         // Results needed before the error rate counts
+	ConsecutiveFailures int           `yaml:"consecutive_failures"` // Failures in a row that eject regardless of the rate
+	SlowResponse        time.Duration `yaml:"slow_response"`        // Responses at least this slow count as failures; 0 disables
+	BaseEjection        time.Duration `yaml:"base_ejection"`        // First ejection; doubles with each repeat
+	MaxEjection         time.Duration `yaml:"max_ejection"`
+	ProbeInterval       time.Duration `yaml:"probe_interval"` // 0 disables active probes
+	ProbeTimeout        time.Duration `yaml:"probe_timeout"`
+}
+
+// DefaultHealthConfig returns the settings used for anything left zero
+func DefaultHealthConfig() HealthConfig {
+	return HealthConfig{
+		UnhealthyThreshold:  0.5,
+		Window:              20,
+		MinRequests:         5,
+		ConsecutiveFailures: 5,
+		BaseEjection:        30 * time.Second,
+		MaxEjection:         5 * time.Minute,
+		ProbeInterval:       10 * time.Second,
+		ProbeTimeout:        2 * time.Second,
+	}
+}
+
+func (c HealthConfig) withDefaults() HealthConfig {
+	d := DefaultHealthConfig()
+	if c.UnhealthyThreshold <= 0 {
+		c.UnhealthyThreshold = d.UnhealthyThreshold
+	}
+	if c.Window <= 0 {
+		c.Window = d.Window
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = d.MinRequests
+	}
+	if c.ConsecutiveFailures <= 0 {
+		c.ConsecutiveFailures = d.ConsecutiveFailures
+	}
+	if c.BaseEjection <= 0 {
+		c.BaseEjection = d.BaseEjection
+	}
+	if c.MaxEjection < c.BaseEjection {
+		c.MaxEjection = max(d.MaxEjection, c.BaseEjection)
+	}
+	if c.ProbeTimeout <= 0 {
+		c.ProbeTimeout = d.ProbeTimeout
+	}
+	return c
+}
+
+// Probe kinds
+const (
+	ProbeHTTP = "http"
+	ProbeGRPC = "grpc"
+)
+
+// ProbeTarget is where an instance answers active health probes. Instances
+// listing a "health_url" or "grpc_health_addr" in their specifications are
+// probed there automatically; marketplaces don't report these, but fake
+// marketplace catalog offers can set them. Other targets, such as reserved
+// instances, are set with HealthChecker.SetProbe.
+type ProbeTarget struct {
+	Kind    string `json:"kind"`              // ProbeHTTP or ProbeGRPC
+	Address string `json:"address"`           // URL for HTTP, host:port for gRPC
+	Service string `json:"service,omitempty"` // gRPC health service name; "" for the server
+}
+
+// Prober runs a single active health probe
+type Prober interface {
+	Probe(ctx context.Context, target ProbeTarget) error
+}
+
+// InstanceHealth is the health of one instance as seen by the balancer
+type InstanceHealth struct {
+	Healthy             bool       `json:"healthy"`
+	ErrorRate           float64    `json:"error_rate"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Ejections           int        `json:"ejections"`
+	EjectedUntil        *time.Time `json:"ejected_until,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// HealthChecker ejects instances that fail too often, from request outcomes
+// and active probes, and lets them back after a backoff that doubles each
+// time they are ejected again. Instances that are not listed for
+// MaxEjection are forgotten.
+type HealthChecker struct {
+	mu     sync.Mutex
+	cfg    HealthConfig
+	states map[string]*instanceHealth
+	probes map[string]ProbeTarget
+	seen   map[string]time.Time // When each instance was last listed
+	prober Prober
+	now    func() time.Time
+}
+
+type instanceHealth struct {
+	results      []bool // Ring of recent outcomes, true for a failure
+	next         int
+	count        int
+	failures     int
+	consecutive  int
+	successes    int // In a row since the last ejection ended
+	ejections    int
+	ejectedUntil time.Time
+	lastError    string
+}
+
+// NewHealthChecker creates a health checker; zero settings take their defaults
+func NewHealthChecker(cfg HealthConfig) *HealthChecker {
+	return &HealthChecker{
+		cfg:    cfg.withDefaults(),
+		states: make(map[string]*instanceHealth),
+		probes: make(map[string]ProbeTarget),
+		seen:   make(map[string]time.Time),
+		prober: NewProber(),
+		now:    time.Now,
+	}
+}
+
+// Config returns the checker's settings
+func (h *HealthChecker) Config() HealthConfig {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.cfg
+}
+
+// SetConfig replaces the checker's settings, keeping what it has observed
+func (h *HealthChecker) SetConfig(cfg HealthConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cfg = cfg.withDefaults()
+}
+
+// SetProber replaces the prober, e.g. in tests
+func (h *HealthChecker) SetProber(p Prober) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.prober = p
+}
+
+// SetProbe sets where an instance is actively probed
+func (h *HealthChecker) SetProbe(instanceID string, target ProbeTarget) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.probes[instanceID] = target
+	h.seen[instanceID] = h.now()
+}
+
+// Forget drops everything known about an instance, e.g. once it is destroyed
+func (h *HealthChecker) Forget(instanceID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.states, instanceID)
+	delete(h.probes, instanceID)
+	delete(h.seen, instanceID)
+}
+
+// Observe picks up the probe targets instances advertise and forgets
+// instances that have not been listed for a while
+func (h *HealthChecker) Observe(instances []models.GPUInstance) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.cfg.Enabled {
+		return
+	}
+	now := h.now()
+	for _, inst := range instances {
+		h.observeLocked(inst, now)
+	}
+	h.pruneLocked(now)
+}
+
+// check observes one instance and reports whether it may be selected. It
+// leaves forgetting unlisted instances to Observe and ProbeAll, so that it
+// takes constant time.
+func (h *HealthChecker) check(inst models.GPUInstance) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.cfg.Enabled {
+		return true
+	}
+	now := h.now()
+	h.observeLocked(inst, now)
+	return h.healthyLocked(inst.ID, now)
+}
+
+func (h *HealthChecker) observeLocked(inst models.GPUInstance, now time.Time) {
+	h.seen[inst.ID] = now
+	if target, ok := probeTargetFor(inst); ok {
+		h.probes[inst.ID] = target
+	} else {
+		delete(h.probes, inst.ID)
+	}
+}
+
+// pruneLocked forgets instances not listed for MaxEjection, by when any
+// ejection of theirs has ended. Callers hold h.mu.
+func (h *HealthChecker) pruneLocked(now time.Time) {
+	for id, seen := range h.seen {
+		if now.Sub(seen) > h.cfg.MaxEjection {
+			delete(h.states, id)
+			delete(h.probes, id)
+			delete(h.seen, id)
+		}
+	}
+}
+
+func probeTargetFor(inst models.GPUInstance) (ProbeTarget, bool) {
+	if addr, _ := inst.Specifications["grpc_health_addr"].(string); addr != "" {
+		service, _ := inst.Specifications["grpc_health_service"].(string)
+		return ProbeTarget{Kind: ProbeGRPC, Address: addr, Service: service}, true
+	}
+	if url, _ := inst.Specifications["health_url"].(string); url != "" {
+		return ProbeTarget{Kind: ProbeHTTP, Address: url}, true
+	}
+	return ProbeTarget{}, false
+}
+
+// RecordSuccess records a response; one at least SlowResponse long counts
+// as a failure
+func (h *HealthChecker) RecordSuccess(instanceID string, duration time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cfg.SlowResponse > 0 && duration >= h.cfg.SlowResponse {
+		h.recordLocked(instanceID, fmt.Errorf("slow response: %v", duration))
+		return
+	}
+	h.recordLocked(instanceID, nil)
+}
+
+// RecordFailure records a failed request, such as an error, a timeout or a
+// 5xx response
+func (h *HealthChecker) RecordFailure(instanceID string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err == nil {
+		err = fmt.Errorf("request failed")
+	}
+	h.recordLocked(instanceID, err)
+}
+
+func (h *HealthChecker) recordLocked(instanceID string, err error) {
+	if !h.cfg.Enabled {
+		return
+	}
+
+	s := h.stateLocked(instanceID)
+	now := h.now()
+	if now.Before(s.ejectedUntil) {
+		// Stragglers from before the ejection
+		return
+	}
+
+	failed := err != nil
+	if s.count == len(s.results) {
+		if s.results[s.next] {
+			s.failures--
+		}
+	} else {
+		s.count++
+	}
+	s.results[s.next] = failed
+	s.next = (s.next + 1) % len(s.results)
+
+	if !failed {
+		s.consecutive = 0
+		s.successes++
+		if s.successes >= h.cfg.MinRequests {
+			s.ejections = 0
+		}
+		return
+	}
+
+	s.failures++
+	s.consecutive++
+	s.successes = 0
+	s.lastError = err.Error()
+
+	rate := float64(s.failures) / float64(s.count)
+	if s.consecutive >= h.cfg.ConsecutiveFailures || s.count >= h.cfg.MinRequests && rate > h.cfg.UnhealthyThreshold {
+		h.ejectLocked(instanceID, s, now)
+	}
+}
+
+func (h *HealthChecker) ejectLocked(instanceID string, s *instanceHealth, now time.Time) {
+	backoff := h.cfg.BaseEjection
+	for i := 0; i < s.ejections && backoff < h.cfg.MaxEjection; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, h.cfg.MaxEjection)
+
+	s.ejections++
+	s.ejectedUntil = now.Add(backoff)
+	log.Printf("Load balancer: ejecting instance %s for %v (%s)", instanceID, backoff, s.lastError)
+
+	// Start afresh when it comes back
+	s.count, s.next, s.failures, s.consecutive = 0, 0, 0, 0
+}
+
+func (h *HealthChecker) stateLocked(instanceID string) *instanceHealth {
+	s, ok := h.states[instanceID]
+	if !ok {
+		s = &instanceHealth{results: make([]bool, h.cfg.Window)}
+		h.states[instanceID] = s
+		if _, listed := h.seen[instanceID]; !listed {
+			h.seen[instanceID] = h.now()
+		}
+	} else if len(s.results) != h.cfg.Window {
+		// The window was resized; start it afresh
+		s.results = make([]bool, h.cfg.Window)
+		s.count, s.next, s.failures = 0, 0, 0
+	}
+	return s
+}
+
+// Healthy reports whether an instance may be selected
+func (h *HealthChecker) Healthy(instanceID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.healthyLocked(instanceID, h.now())
+}
+
+func (h *HealthChecker) healthyLocked(instanceID string, now time.Time) bool {
+	if !h.cfg.Enabled {
+		return true
+	}
+	s, ok := h.states[instanceID]
+	return !ok || !now.Before(s.ejectedUntil)
+}
+
+// Filter returns the instances that are not ejected. If every instance is
+// ejected it returns them all, since a possibly unhealthy instance beats
+// none at all.
+func (h *HealthChecker) Filter(instances []models.GPUInstance) []models.GPUInstance {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.cfg.Enabled {
+		return instances
+	}
+
+	now := h.now()
+	healthy := make([]models.GPUInstance, 0, len(instances))
+	for _, inst := range instances {
+		if h.healthyLocked(inst.ID, now) {
+			healthy = append(healthy, inst)
+		}
+	}
+	if len(healthy) == 0 {
+		return instances
+	}
+	return healthy
+}
+
+// Status returns an instance's health
+func (h *HealthChecker) Status(instanceID string) InstanceHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	status := InstanceHealth{Healthy: h.healthyLocked(instanceID, now)}
+	s, ok := h.states[instanceID]
+	if !ok {
+		return status
+	}
+	if s.count > 0 {
+		status.ErrorRate = float64(s.failures) / float64(s.count)
+	}
+	status.ConsecutiveFailures = s.consecutive
+	status.Ejections = s.ejections
+	status.LastError = s.lastError
+	if now.Before(s.ejectedUntil) {
+		until := s.ejectedUntil
+		status.EjectedUntil = &until
+	}
+	return status
+}
+
+// ProbeAll probes every instance with a probe target once, concurrently
+func (h *HealthChecker) ProbeAll(ctx context.Context) {
+	h.mu.Lock()
+	if !h.cfg.Enabled {
+		h.mu.Unlock()
+		return
+	}
+	h.pruneLocked(h.now())
+	targets := make(map[string]ProbeTarget, len(h.probes))
+	for id, target := range h.probes {
+		targets[id] = target
+	}
+	prober, timeout := h.prober, h.cfg.ProbeTimeout
+	h.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for id, target := range targets {
+		wg.Add(1)
+		go func(id string, target ProbeTarget) {
+			defer wg.Done()
+
+			probeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			if err := prober.Probe(probeCtx, target); err != nil {
+				h.RecordFailure(id, fmt.Errorf("health probe: %w", err))
+				return
+			}
+			h.RecordSuccess(id, time.Since(start))
+		}(id, target)
+	}
+	wg.Wait()
+}
+
+// Run probes instances every ProbeInterval until ctx is cancelled
+func (h *HealthChecker) Run(ctx context.Context) {
+	interval := h.Config().ProbeInterval
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.ProbeAll(ctx)
+		}
+	}
+}
+
+// defaultProber probes HTTP endpoints with GET and gRPC servers with the
+// standard health checking protocol
+type defaultProber struct {
+	httpClient *http.Client
+}
+
+// NewProber creates the HTTP and gRPC prober health checkers use by default
+func NewProber() Prober {
+	return &defaultProber{httpClient: &http.Client{}}
+}
+
+func (p *defaultProber) Probe(ctx context.Context, target ProbeTarget) error {
+	switch target.Kind {
+	case ProbeHTTP:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.Address, nil)
+		if err != nil {
+			return fmt.Errorf("invalid health URL: %w", err)
+		}
+		resp, err := p.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("health check returned %d", resp.StatusCode)
+		}
+		return nil
+
+	case ProbeGRPC:
+		conn, err := grpc.NewClient(target.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return fmt.Errorf("invalid health address: %w", err)
+		}
+		defer conn.Close()
+
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: target.Service})
+		if err != nil {
+			return err
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("health check returned %s", resp.Status)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown probe kind %q", target.Kind)
+}
@@ -0,0 +1,216 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// fakeClock is a manually advanced clock
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+var errBoom = errors.New("boom")
+
+func newTestChecker(cfg HealthConfig) (*HealthChecker, *fakeClock) {
+	cfg.Enabled = true
+	h := NewHealthChecker(cfg)
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	h.now = clock.now
+	return h, clock
+}
+
+func TestHealthCheckerErrorRate(t *testing.T) {
+	h, _ := newTestChecker(HealthConfig{UnhealthyThreshold: 0.5, MinRequests: 4, ConsecutiveFailures: 10})
+
+	h.RecordFailure("a", errBoom)
+	h.RecordSuccess("a", time.Millisecond)
+	h.RecordFailure("a", errBoom)
+	assert.True(t, h.Healthy("a"), "too few requests to judge")
+
+	h.RecordSuccess("a", time.Millisecond)
+	assert.True(t, h.Healthy("a"), "50% is not over the threshold")
+
+	h.RecordFailure("a", errBoom)
+	assert.False(t, h.Healthy("a"))
+
+	status := h.Status("a")
+	assert.Equal(t, 1, status.Ejections)
+	assert.Equal(t, "boom", status.LastError)
+	require.NotNil(t, status.EjectedUntil)
+}
+
+func TestHealthCheckerConsecutiveFailuresAndBackoff(t *testing.T) {
+	h, clock := newTestChecker(HealthConfig{ConsecutiveFailures: 3, BaseEjection: 10 * time.Second, MaxEjection: 30 * time.Second})
+
+	eject := func() {
+		for i := 0; i < 3; i++ {
+			h.RecordFailure("a", errBoom)
+		}
+		require.False(t, h.Healthy("a"))
+	}
+
+	eject()
+	clock.advance(9 * time.Second)
+	assert.False(t, h.Healthy("a"))
+	clock.advance(time.Second)
+	assert.True(t, h.Healthy("a"), "back after the first ejection")
+
+	eject()
+	clock.advance(19 * time.Second)
+	assert.False(t, h.Healthy("a"), "the second ejection is twice as long")
+	clock.advance(time.Second)
+	assert.True(t, h.Healthy("a"))
+
+	eject()
+	status := h.Status("a")
+	assert.Equal(t, 3, status.Ejections)
+	assert.Equal(t, clock.now().Add(30*time.Second), *status.EjectedUntil, "capped at MaxEjection")
+}
+
+func TestHealthCheckerRecoveryResetsBackoff(t *testing.T) {
+	h, clock := newTestChecker(HealthConfig{ConsecutiveFailures: 2, MinRequests: 3, BaseEjection: 10 * time.Second})
+
+	h.RecordFailure("a", errBoom)
+	h.RecordFailure("a", errBoom)
+	h.RecordFailure("a", errBoom) // A straggler while ejected is ignored
+	assert.Equal(t, 1, h.Status("a").Ejections)
+
+	clock.advance(10 * time.Second)
+	for i := 0; i < 3; i++ {
+		h.RecordSuccess("a", time.Millisecond)
+	}
+	assert.Equal(t, 0, h.Status("a").Ejections)
+}
+
+func TestHealthCheckerSlowResponse(t *testing.T) {
+	h, _ := newTestChecker(HealthConfig{ConsecutiveFailures: 2, SlowResponse: time.Second})
+
+	h.RecordSuccess("a", 2*time.Second)
+	h.RecordSuccess("a", time.Second)
+	assert.False(t, h.Healthy("a"))
+	assert.Contains(t, h.Status("a").LastError, "slow response")
+}
+
+func TestHealthCheckerFilter(t *testing.T) {
+	h, _ := newTestChecker(HealthConfig{ConsecutiveFailures: 1})
+	instances := []models.GPUInstance{{ID: "a"}, {ID: "b"}}
+
+	h.RecordFailure("a", errBoom)
+	assert.Equal(t, []models.GPUInstance{{ID: "b"}}, h.Filter(instances))
+
+	h.RecordFailure("b", errBoom)
+	assert.Equal(t, instances, h.Filter(instances), "falls back to every instance when none are healthy")
+}
+
+func TestHealthCheckerDisabled(t *testing.T) {
+	h := NewHealthChecker(HealthConfig{ConsecutiveFailures: 1})
+
+	h.RecordFailure("a", errBoom)
+	assert.True(t, h.Healthy("a"))
+	assert.Equal(t, 0, h.Status("a").Ejections)
+}
+
+func TestHealthCheckerHTTPProbe(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	h, _ := newTestChecker(HealthConfig{ConsecutiveFailures: 2})
+	h.Observe([]models.GPUInstance{{ID: "a", Specifications: map[string]interface{}{"health_url": srv.URL}}})
+
+	h.ProbeAll(context.Background())
+	assert.True(t, h.Healthy("a"))
+
+	healthy.Store(false)
+	h.ProbeAll(context.Background())
+	h.ProbeAll(context.Background())
+	assert.False(t, h.Healthy("a"))
+	assert.Contains(t, h.Status("a").LastError, "health check returned 503")
+}
+
+func TestHealthCheckerGRPCProbe(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	healthSrv := health.NewServer()
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	h, _ := newTestChecker(HealthConfig{ConsecutiveFailures: 1})
+	h.SetProbe("a", ProbeTarget{Kind: ProbeGRPC, Address: lis.Addr().String(), Service: "inference"})
+
+	healthSrv.SetServingStatus("inference", healthpb.HealthCheckResponse_SERVING)
+	h.ProbeAll(context.Background())
+	assert.True(t, h.Healthy("a"))
+
+	healthSrv.SetServingStatus("inference", healthpb.HealthCheckResponse_NOT_SERVING)
+	h.ProbeAll(context.Background())
+	assert.False(t, h.Healthy("a"))
+	assert.Contains(t, h.Status("a").LastError, "NOT_SERVING")
+}
+
+func TestHealthCheckerForgetsUnlistedInstances(t *testing.T) {
+	h, clock := newTestChecker(HealthConfig{ConsecutiveFailures: 1, BaseEjection: time.Minute, MaxEjection: 5 * time.Minute})
+	spec := func(url string) map[string]interface{} { return map[string]interface{}{"health_url": url} }
+
+	h.Observe([]models.GPUInstance{{ID: "a", Specifications: spec("http://a/health")}, {ID: "b", Specifications: spec("http://b/health")}})
+	h.RecordFailure("a", errBoom)
+	require.False(t, h.Healthy("a"))
+
+	// b keeps being listed, without its health URL
+	clock.advance(4 * time.Minute)
+	h.Observe([]models.GPUInstance{{ID: "b"}})
+	assert.Len(t, h.probes, 1, "b is no longer probed")
+	assert.Len(t, h.states, 1)
+
+	clock.advance(2 * time.Minute)
+	h.ProbeAll(context.Background())
+	assert.Empty(t, h.probes, "a is gone")
+	assert.Empty(t, h.states)
+	assert.Len(t, h.seen, 1)
+	assert.Contains(t, h.seen, "b")
+}
+
+func TestLoadBalancerServiceSkipsEjected(t *testing.T) {
+	s := NewLoadBalancerService(StrategyRoundRobin)
+	s.SetHealthConfig(HealthConfig{Enabled: true, ConsecutiveFailures: 1})
+	instances := []models.GPUInstance{{ID: "a"}, {ID: "b"}}
+
+	s.TrackResult("a", time.Second, errBoom)
+	for i := 0; i < 4; i++ {
+		selected, err := s.SelectInstance(context.Background(), instances)
+		require.NoError(t, err)
+		assert.Equal(t, "b", selected.ID)
+	}
+
+	loads := s.GetAllLoads()
+	require.Contains(t, loads, "b")
+	require.NotNil(t, loads["b"].Health)
+	assert.True(t, loads["b"].Health.Healthy)
+
+	s.SetStrategy(StrategyLeastConnections)
+	selected, err := s.SelectInstance(context.Background(), instances)
+	require.NoError(t, err)
+	assert.Equal(t, "b", selected.ID, "health survives a strategy change")
+}
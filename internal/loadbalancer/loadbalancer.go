@@ -3,6 +3,7 @@ package loadbalancer
 import (
 	"context"
 	"fmt"
+	"log"
 	"sync"
 	"time"
 
@@ -17,8 +18,35 @@ const (
 	StrategyWeightedRoundRobin Strategy = "weighted_round_robin"
 	StrategyLeastConnections   Strategy = "least_connections"
 	StrategyLeastResponseTime  Strategy = "least_response_time"
+	StrategyConsistentHash     Strategy = "consistent_hash"
+	StrategyStickySession      Strategy = "sticky_session"
+	StrategyP2C                Strategy = "p2c"
 )
 
+// Strategies lists every load balancing strategy
+func Strategies() []Strategy {
+	return []Strategy{
+		StrategyRoundRobin,
+		StrategyEqualWeighted,
+		StrategyWeightedRoundRobin,
+		StrategyLeastConnections,
+		StrategyLeastResponseTime,
+		StrategyConsistentHash,
+		StrategyStickySession,
+		StrategyP2C,
+	}
+}
+
+// ParseStrategy checks a strategy name
+func ParseStrategy(name string) (Strategy, error) {
+	for _, strategy := range Strategies() {
+		if string(strategy) == name {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown load balancing strategy %q", name)
+}
+
 type LoadBalancer interface {
 	SelectInstance(instances []models.GPUInstance) (*models.GPUInstance, error)
 	RecordConnection(instanceID string)
@@ -37,6 +65,7 @@ type InstanceLoad struct {
 	LastResponseTime time.Duration `json:"last_response_time"`
 	Weight           float64       `json:"weight"`
 	LastUsed         time.Time     `json:"last_used"`
+	Health           *InstanceHealth `json:"health,omitempty"` // Set when health-aware balancing is on
 }
 
 type BaseLoadBalancer struct {
@@ -44,9 +73,23 @@ type BaseLoadBalancer struct {
 	loads     map[string]*InstanceLoad
 	mu        sync.RWMutex
 	roundRobinIndex int
+	store     LoadStore // Shares loads with other replicas; nil keeps them local
+	weigh     func(instance *models.GPUInstance) float64 // Pool weights; nil leaves weighting to the strategy
+}
+
+// weight returns a pool's weight for an instance, or 1 outside pools
+func (lb *BaseLoadBalancer) weight(instance *models.GPUInstance) float64 {
+	if lb.weigh == nil {
+		return 1
+	}
+	return lb.weigh(instance)
 }
 
 func NewLoadBalancer(strategy Strategy) LoadBalancer {
+	return newLoadBalancer(strategy, DefaultAffinityConfig(), DefaultEWMADecay)
+}
+
+func newLoadBalancer(strategy Strategy, affinity AffinityConfig, ewmaDecay time.Duration) LoadBalancer {
 	switch strategy {
 	case StrategyRoundRobin:
 		return &RoundRobinLB{BaseLoadBalancer: newBase(strategy)}
@@ -58,6 +101,12 @@ func NewLoadBalancer(strategy Strategy) LoadBalancer {
 		return &LeastConnectionsLB{BaseLoadBalancer: newBase(strategy)}
 	case StrategyLeastResponseTime:
 		return &LeastResponseTimeLB{BaseLoadBalancer: newBase(strategy)}
+	case StrategyConsistentHash:
+		return newConsistentHash(strategy, affinity)
+	case StrategyStickySession:
+		return newStickySession(strategy, affinity)
+	case StrategyP2C:
+		return newP2C(strategy, ewmaDecay)
 	default:
 		return &RoundRobinLB{BaseLoadBalancer: newBase(StrategyRoundRobin)}
 	}
@@ -97,38 +146,128 @@ func (lb *BaseLoadBalancer) ensureLoadUnsafe(instanceID, provider string) *Insta
 
 func (lb *BaseLoadBalancer) RecordConnection(instanceID string) {
 	lb.mu.Lock()
-	defer lb.mu.Unlock()
-
+	provider := ""
 	if load, exists := lb.loads[instanceID]; exists {
 		load.ActiveConnections++
 		load.TotalConnections++
 		load.LastUsed = time.Now()
+		provider = load.Provider
 	}
+	lb.mu.Unlock()
+
+	lb.share(func(ctx context.Context, store LoadStore) error {
+		return store.AddConnections(ctx, instanceID, provider, 1)
+	})
 }
 
 func (lb *BaseLoadBalancer) RecordDisconnection(instanceID string) {
 	lb.mu.Lock()
-	defer lb.mu.Unlock()
-
 	if load, exists := lb.loads[instanceID]; exists {
 		if load.ActiveConnections > 0 {
 			load.ActiveConnections--
 		}
 	}
+	lb.mu.Unlock()
+
+	lb.share(func(ctx context.Context, store LoadStore) error {
+		return store.AddConnections(ctx, instanceID, "", -1)
+	})
 }
 
 func (lb *BaseLoadBalancer) RecordResponseTime(instanceID string, duration time.Duration) {
 	lb.mu.Lock()
-	defer lb.mu.Unlock()
-
 	if load, exists := lb.loads[instanceID]; exists {
 		if load.AvgResponseTime == 0 {
 			load.AvgResponseTime = duration
 		} else {
-			load.AvgResponseTime = (load.AvgResponseTime + duration) / 2
+			load.AvgResponseTime = time.Duration(ewmaAlpha*float64(duration) + (1-ewmaAlpha)*float64(load.AvgResponseTime))
 		}
 		load.LastResponseTime = duration
 	}
+	lb.mu.Unlock()
+
+	lb.share(func(ctx context.Context, store LoadStore) error {
+		return store.RecordLatency(ctx, instanceID, duration)
+	})
+}
+
+// shareTimeout bounds each write to the shared load store
+const shareTimeout = time.Second
+
+// share writes a change through to the shared load store, if there is one.
+// The local loads are already updated, so a failed write only leaves other
+// replicas out of date until the next one.
+func (lb *BaseLoadBalancer) share(write func(ctx context.Context, store LoadStore) error) {
+	lb.mu.RLock()
+	store := lb.store
+	lb.mu.RUnlock()
+	if store == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shareTimeout)
+	defer cancel()
+	if err := write(ctx, store); err != nil {
+		log.Printf("Load balancer: %v", err)
+	}
+}
+
+// healthSampler is implemented by strategies that only check the health of
+// the instances they sample, rather than having every instance filtered
+type healthSampler interface {
+	selectHealthy(instances []models.GPUInstance, health *HealthChecker) (*models.GPUInstance, error)
+}
+
+// baseBalancer is implemented by every strategy through BaseLoadBalancer
+type baseBalancer interface {
+	base() *BaseLoadBalancer
+}
+
+func (lb *BaseLoadBalancer) base() *BaseLoadBalancer {
+	return lb
+}
+
+// applyShared replaces local connection counts and latencies with those
+// merged across replicas. Instances no live replica has connections to
+// drop to zero active connections. With trackedOnly, instances the balancer
+// doesn't track yet are skipped, as pools only track their members.
+func (lb *BaseLoadBalancer) applyShared(shared map[string]*InstanceLoad, trackedOnly bool) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	for id, load := range lb.loads {
+		if _, ok := shared[id]; !ok {
+			load.ActiveConnections = 0
+		}
+	}
+	for id, s := range shared {
+		if _, tracked := lb.loads[id]; trackedOnly && !tracked {
+			continue
+		}
+		load := lb.ensureLoadUnsafe(id, s.Provider)
+		if load.Provider == "" {
+			load.Provider = s.Provider
+		}
+		load.ActiveConnections = s.ActiveConnections
+		load.TotalConnections = s.TotalConnections
+		if s.AvgResponseTime > 0 {
+			load.AvgResponseTime = s.AvgResponseTime
+		}
+		if s.LastUsed.After(load.LastUsed) {
+			load.LastUsed = s.LastUsed
+		}
+	}
+}
+
+// adopt takes over another balancer's loads, e.g. when the strategy changes
+func (lb *BaseLoadBalancer) adopt(from *BaseLoadBalancer) {
+	loads := from.GetAllLoads()
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	for id, load := range loads {
+		lb.loads[id] = load
+	}
 }
 
 func (lb *BaseLoadBalancer) GetLoad(instanceID string) *InstanceLoad {
@@ -203,9 +342,12 @@ func (lb *EqualWeightedLB) SelectInstance(instances []models.GPUInstance) (*mode
 	return selected, nil
 }
 
+// WeightedRoundRobinLB spreads requests in proportion to instance weights
+// with smooth weighted round robin, so a 3:1 pair goes a, a, b, a rather
+// than a, a, a, b
 type WeightedRoundRobinLB struct {
 	*BaseLoadBalancer
-	currentWeight float64
+	currentWeights map[string]float64
 }
 
 func (lb *WeightedRoundRobinLB) SelectInstance(instances []models.GPUInstance) (*models.GPUInstance, error) {
@@ -216,8 +358,12 @@ func (lb *WeightedRoundRobinLB) SelectInstance(instances []models.GPUInstance) (
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
 
-	var selected *models.GPUInstance
-	maxWeight := -1.0
+	if lb.currentWeights == nil {
+		lb.currentWeights = make(map[string]float64)
+	}
+
+	selected := &instances[0]
+	total := 0.0
 
 	for i := range instances {
 		load := lb.ensureLoadUnsafe(instances[i].ID, instances[i].Provider)
@@ -225,20 +371,23 @@ func (lb *WeightedRoundRobinLB) SelectInstance(instances []models.GPUInstance) (
 		weight := lb.calculateWeight(&instances[i])
 		load.Weight = weight
 
-		if weight > maxWeight {
-			maxWeight = weight
+		total += weight
+		lb.currentWeights[instances[i].ID] += weight
+		if lb.currentWeights[instances[i].ID] > lb.currentWeights[selected.ID] {
 			selected = &instances[i]
 		}
 	}
 
-	if selected == nil {
-		selected = &instances[0]
-	}
+	lb.currentWeights[selected.ID] -= total
 
 	return selected, nil
 }
 
 func (lb *WeightedRoundRobinLB) calculateWeight(instance *models.GPUInstance) float64 {
+	if lb.weigh != nil {
+		return lb.weigh(instance)
+	}
+
 	baseWeight := 1.0
 
 	if instance.VRAM >= 80 {
@@ -323,46 +472,333 @@ func (lb *LeastResponseTimeLB) SelectInstance(instances []models.GPUInstance) (*
 }
 
 type LoadBalancerService struct {
-	balancer LoadBalancer
-	strategy Strategy
+	mu        sync.RWMutex // Guards the strategy, its settings and the pools
+	balancer  LoadBalancer
+	strategy  Strategy
+	health    *HealthChecker
+	affinity  AffinityConfig
+	store     LoadStore
+	ewmaDecay time.Duration
+	weights   []weightRule
+	pools     map[string]*pool
+	poolNames []string
 }
 
 func NewLoadBalancerService(strategy Strategy) *LoadBalancerService {
 	return &LoadBalancerService{
-		balancer: NewLoadBalancer(strategy),
-		strategy: strategy,
+		balancer:  NewLoadBalancer(strategy),
+		strategy:  strategy,
+		health:    NewHealthChecker(HealthConfig{}),
+		affinity:  DefaultAffinityConfig(),
+		ewmaDecay: DefaultEWMADecay,
 	}
 }
 
+// SelectInstance picks an instance with the current strategy, skipping any
+// the health checker has ejected. Strategies with affinity keep requests
+// whose ctx carries the same affinity key (see WithAffinityKey) together,
+// and a ctx naming a pool (see WithPool) picks among the pool's members
+// with its settings.
 func (s *LoadBalancerService) SelectInstance(ctx context.Context, instances []models.GPUInstance) (*models.GPUInstance, error) {
+	if name := PoolFromContext(ctx); name != "" {
+		p, err := s.pool(name)
+		if err != nil {
+			return nil, err
+		}
+		members := p.members(instances)
+		if len(members) == 0 {
+			return nil, fmt.Errorf("no instances in pool %s", name)
+		}
+		return p.service.selectInstance(ctx, members)
+	}
+	return s.selectInstance(ctx, instances)
+}
+
+func (s *LoadBalancerService) selectInstance(ctx context.Context, instances []models.GPUInstance) (*models.GPUInstance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if sampler, ok := s.balancer.(healthSampler); ok {
+		return sampler.selectHealthy(instances, s.health)
+	}
+	s.health.Observe(instances)
+	instances = s.health.Filter(instances)
+	if keyed, ok := s.balancer.(KeyedLoadBalancer); ok {
+		return keyed.SelectInstanceForKey(AffinityKeyFromContext(ctx), instances)
+	}
 	return s.balancer.SelectInstance(instances)
 }
 
+// Tracking is recorded by the service and each of its pools, so that an
+// instance in several pools looks equally busy to all of them. Each holds
+// the read lock while recording, so a strategy change can't lose it.
+
+// TrackConnection starts tracking instances the balancer has not selected,
+// such as reserved instances whose idleness the expiry scheduler watches.
+// Pools only count connections to their own members.
 func (s *LoadBalancerService) TrackConnection(instanceID string) {
+	s.mu.RLock()
+	if b, ok := s.balancer.(baseBalancer); ok {
+		b.base().ensureLoad(instanceID, "")
+	}
+	s.balancer.RecordConnection(instanceID)
+	s.mu.RUnlock()
+
+	for _, p := range s.poolServices() {
+		p.recordConnection(instanceID)
+	}
+}
+
+// recordConnection counts a connection to an instance the balancer already
+// tracks, as pools do for their members
+func (s *LoadBalancerService) recordConnection(instanceID string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	s.balancer.RecordConnection(instanceID)
 }
 
 func (s *LoadBalancerService) TrackDisconnection(instanceID string) {
+	s.mu.RLock()
 	s.balancer.RecordDisconnection(instanceID)
+	s.mu.RUnlock()
+
+	for _, p := range s.poolServices() {
+		p.TrackDisconnection(instanceID)
+	}
 }
 
 func (s *LoadBalancerService) TrackResponseTime(instanceID string, duration time.Duration) {
+	s.mu.RLock()
 	s.balancer.RecordResponseTime(instanceID, duration)
+	s.mu.RUnlock()
+
+	s.health.RecordSuccess(instanceID, duration)
+	for _, p := range s.poolServices() {
+		p.TrackResponseTime(instanceID, duration)
+	}
+}
+
+// TrackResult records the outcome of a request to an instance: its response
+// time if err is nil, otherwise a failure
+func (s *LoadBalancerService) TrackResult(instanceID string, duration time.Duration, err error) {
+	if err != nil {
+		s.TrackFailure(instanceID, err)
+		return
+	}
+	s.TrackResponseTime(instanceID, duration)
+}
+
+// TrackRequest counts a request to an instance as in flight until the
+// returned function is called with its outcome
+func (s *LoadBalancerService) TrackRequest(instanceID string) func(duration time.Duration, err error) {
+	s.TrackConnection(instanceID)
+	return func(duration time.Duration, err error) {
+		s.TrackDisconnection(instanceID)
+		s.TrackResult(instanceID, duration, err)
+	}
+}
+
+// TrackFailure records a failed request to an instance, counting towards
+// its ejection
+func (s *LoadBalancerService) TrackFailure(instanceID string, err error) {
+	s.health.RecordFailure(instanceID, err)
+	for _, p := range s.poolServices() {
+		p.TrackFailure(instanceID, err)
+	}
 }
 
 func (s *LoadBalancerService) GetInstanceLoad(instanceID string) *InstanceLoad {
-	return s.balancer.GetLoad(instanceID)
+	load := s.current().GetLoad(instanceID)
+	if load == nil {
+		return nil
+	}
+	loadCopy := *load
+	s.withHealth(&loadCopy)
+	return &loadCopy
 }
 
 func (s *LoadBalancerService) GetAllLoads() map[string]*InstanceLoad {
-	return s.balancer.GetAllLoads()
+	loads := s.current().GetAllLoads()
+	for _, load := range loads {
+		s.withHealth(load)
+	}
+	return loads
+}
+
+func (s *LoadBalancerService) withHealth(load *InstanceLoad) {
+	if s.health.Config().Enabled {
+		status := s.health.Status(load.InstanceID)
+		load.Health = &status
+	}
+}
+
+// current returns the balancer of the current strategy
+func (s *LoadBalancerService) current() LoadBalancer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.balancer
 }
 
 func (s *LoadBalancerService) GetStrategy() Strategy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.strategy
 }
 
+// SetStrategy switches strategies, keeping the loads tracked so far
 func (s *LoadBalancerService) SetStrategy(strategy Strategy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.strategy = strategy
-	s.balancer = NewLoadBalancer(strategy)
+	s.restartLocked()
+}
+
+// restartLocked replaces the balancer after a settings change, carrying
+// over its loads so that connections in flight are still counted. It must
+// be called while holding the lock.
+func (s *LoadBalancerService) restartLocked() {
+	balancer := s.newBalancer(s.strategy)
+	if from, ok := s.balancer.(baseBalancer); ok {
+		if to, ok := balancer.(baseBalancer); ok {
+			to.base().adopt(from.base())
+		}
+	}
+	s.balancer = balancer
+}
+
+// newBalancer must be called while holding the lock, or before the service
+// is shared
+func (s *LoadBalancerService) newBalancer(strategy Strategy) LoadBalancer {
+	balancer := newLoadBalancer(strategy, s.affinity, s.ewmaDecay)
+	if b, ok := balancer.(baseBalancer); ok {
+		b.base().store = s.store
+		if len(s.weights) > 0 {
+			weights := s.weights
+			b.base().weigh = func(instance *models.GPUInstance) float64 {
+				return weigh(weights, instance)
+			}
+		}
+	}
+	return balancer
+}
+
+// SetEWMADecay sets how quickly the p2c strategy forgets latency; it
+// restarts the current strategy
+func (s *LoadBalancerService) SetEWMADecay(decay time.Duration) {
+	if decay <= 0 {
+		decay = DefaultEWMADecay
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ewmaDecay = decay
+	s.restartLocked()
+}
+
+// SetLoadStore shares loads with other replicas through store, so that
+// strategies such as least_connections see every replica's connections.
+// Call it at startup; it restarts the current strategy.
+func (s *LoadBalancerService) SetLoadStore(store LoadStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = store
+	s.restartLocked()
+}
+
+// SyncLoads pulls the loads merged across replicas into the local caches
+// that the service's and its pools' strategies select from
+func (s *LoadBalancerService) SyncLoads(ctx context.Context) error {
+	s.mu.RLock()
+	store := s.store
+	s.mu.RUnlock()
+	if store == nil {
+		return nil
+	}
+	if err := store.Heartbeat(ctx); err != nil {
+		return err
+	}
+	shared, err := store.Loads(ctx)
+	if err != nil {
+		return err
+	}
+	s.applyShared(shared, false)
+	for _, p := range s.poolServices() {
+		p.applyShared(shared, true)
+	}
+	return nil
+}
+
+// applyShared hands shared loads to the current strategy, holding the read
+// lock so that a strategy change doesn't drop them
+func (s *LoadBalancerService) applyShared(shared map[string]*InstanceLoad, trackedOnly bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if b, ok := s.balancer.(baseBalancer); ok {
+		b.base().applyShared(shared, trackedOnly)
+	}
+}
+
+// RunLoadSync syncs loads every interval until ctx is cancelled. The
+// interval should be well under the store's TTL, which the sync's
+// heartbeat keeps this replica alive for.
+func (s *LoadBalancerService) RunLoadSync(ctx context.Context, interval time.Duration) {
+	s.mu.RLock()
+	store := s.store
+	s.mu.RUnlock()
+	if store == nil || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.SyncLoads(ctx); err != nil {
+				log.Printf("Load balancer: failed to sync shared loads: %v", err)
+			}
+		}
+	}
+}
+
+// Affinity returns how requests are keyed for the consistent-hash and
+// sticky-session strategies
+func (s *LoadBalancerService) Affinity() AffinityConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.affinity
+}
+
+// SetAffinity changes how requests are keyed; it restarts the current
+// strategy, forgetting sticky sessions
+func (s *LoadBalancerService) SetAffinity(cfg AffinityConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.affinity = cfg.withDefaults()
+	s.restartLocked()
+}
+
+// Health returns the service's health checker
+func (s *LoadBalancerService) Health() *HealthChecker {
+	return s.health
+}
+
+// SetHealthConfig turns health-aware balancing on or off and tunes it
+func (s *LoadBalancerService) SetHealthConfig(cfg HealthConfig) {
+	s.health.SetConfig(cfg)
+}
+
+// RunHealthChecks actively probes instances until ctx is cancelled, for
+// the service and for each pool with health checks enabled
+func (s *LoadBalancerService) RunHealthChecks(ctx context.Context) {
+	for _, p := range s.poolServices() {
+		if p.health.Config().Enabled {
+			go p.health.Run(ctx)
+		}
+	}
+	if s.health.Config().Enabled {
+		s.health.Run(ctx)
+	}
 }
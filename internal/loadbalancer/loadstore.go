@@ -0,0 +1,184 @@
+package loadbalancer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LoadStore shares instance loads between load balancer replicas. Each
+// replica (node) writes only its own counts; reads merge every node that
+// has been heard from within the store's TTL, so the connections of a
+// replica that dies are dropped once it stops heartbeating.
+type LoadStore interface {
+	// AddConnections adjusts this node's active connections to an instance
+	// by delta, never below zero; a positive delta also counts towards the
+	// instance's total connections
+	AddConnections(ctx context.Context, instanceID, provider string, delta int) error
+	// RecordLatency folds a response time into this node's EWMA latency for
+	// an instance
+	RecordLatency(ctx context.Context, instanceID string, duration time.Duration) error
+	// Heartbeat keeps this node alive while it has nothing to record
+	Heartbeat(ctx context.Context) error
+	// Loads returns every instance's load merged across live nodes
+	Loads(ctx context.Context) (map[string]*InstanceLoad, error)
+}
+
+const (
+	// ewmaAlpha weights each new latency sample
+	ewmaAlpha = 0.2
+	// ewmaMaxWeight caps how many samples a node's EWMA counts for when
+	// nodes are merged, so a busy node can't drown out a recent change
+	ewmaMaxWeight = 20
+)
+
+// nodeLoad is one node's view of one instance
+type nodeLoad struct {
+	provider string
+	active   int
+	total    int64
+	ewma     float64 // Nanoseconds
+	samples  int
+	lastUsed time.Time
+}
+
+func (l *nodeLoad) observe(duration time.Duration) {
+	if l.samples == 0 {
+		l.ewma = float64(duration)
+	} else {
+		l.ewma = ewmaAlpha*float64(duration) + (1-ewmaAlpha)*l.ewma
+	}
+	l.samples = min(l.samples+1, ewmaMaxWeight)
+}
+
+// mergeLoads sums node loads per instance, weighting each node's EWMA
+// latency by its samples
+func mergeLoads(nodes []map[string]*nodeLoad) map[string]*InstanceLoad {
+	loads := make(map[string]*InstanceLoad)
+	weights := make(map[string]float64)
+	for _, node := range nodes {
+		for id, l := range node {
+			load, ok := loads[id]
+			if !ok {
+				load = &InstanceLoad{InstanceID: id, Weight: 1.0}
+				loads[id] = load
+			}
+			if load.Provider == "" {
+				load.Provider = l.provider
+			}
+			load.ActiveConnections += l.active
+			load.TotalConnections += l.total
+			if l.lastUsed.After(load.LastUsed) {
+				load.LastUsed = l.lastUsed
+			}
+			if l.samples > 0 {
+				w := weights[id]
+				avg := (float64(load.AvgResponseTime)*w + l.ewma*float64(l.samples)) / (w + float64(l.samples))
+				load.AvgResponseTime = time.Duration(avg)
+				weights[id] = w + float64(l.samples)
+			}
+		}
+	}
+	return loads
+}
+
+// memoryCluster is the state MemoryLoadStore nodes share
+type memoryCluster struct {
+	mu       sync.Mutex
+	nodes    map[string]map[string]*nodeLoad
+	lastSeen map[string]time.Time
+}
+
+// MemoryLoadStore keeps loads in process memory. On its own it is a single
+// node; Join adds nodes sharing its state, which stand in for replicas in
+// tests.
+type MemoryLoadStore struct {
+	cluster *memoryCluster
+	nodeID  string
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// NewMemoryLoadStore creates an in-memory load store for one node
+func NewMemoryLoadStore(nodeID string, ttl time.Duration) *MemoryLoadStore {
+	cluster := &memoryCluster{
+		nodes:    make(map[string]map[string]*nodeLoad),
+		lastSeen: make(map[string]time.Time),
+	}
+	return &MemoryLoadStore{cluster: cluster, nodeID: nodeID, ttl: ttl, now: time.Now}
+}
+
+// Join returns another node sharing this store's state
+func (s *MemoryLoadStore) Join(nodeID string) *MemoryLoadStore {
+	return &MemoryLoadStore{cluster: s.cluster, nodeID: nodeID, ttl: s.ttl, now: s.now}
+}
+
+// nodeLocked returns this node's loads, marking it alive
+func (s *MemoryLoadStore) nodeLocked() map[string]*nodeLoad {
+	node, ok := s.cluster.nodes[s.nodeID]
+	if !ok {
+		node = make(map[string]*nodeLoad)
+		s.cluster.nodes[s.nodeID] = node
+	}
+	s.cluster.lastSeen[s.nodeID] = s.now()
+	return node
+}
+
+func (s *MemoryLoadStore) instanceLocked(instanceID, provider string) *nodeLoad {
+	node := s.nodeLocked()
+	l, ok := node[instanceID]
+	if !ok {
+		l = &nodeLoad{}
+		node[instanceID] = l
+	}
+	if provider != "" {
+		l.provider = provider
+	}
+	return l
+}
+
+func (s *MemoryLoadStore) AddConnections(ctx context.Context, instanceID, provider string, delta int) error {
+	s.cluster.mu.Lock()
+	defer s.cluster.mu.Unlock()
+
+	l := s.instanceLocked(instanceID, provider)
+	l.active = max(l.active+delta, 0)
+	if delta > 0 {
+		l.total += int64(delta)
+		l.lastUsed = s.now()
+	}
+	return nil
+}
+
+func (s *MemoryLoadStore) RecordLatency(ctx context.Context, instanceID string, duration time.Duration) error {
+	s.cluster.mu.Lock()
+	defer s.cluster.mu.Unlock()
+
+	s.instanceLocked(instanceID, "").observe(duration)
+	return nil
+}
+
+func (s *MemoryLoadStore) Heartbeat(ctx context.Context) error {
+	s.cluster.mu.Lock()
+	defer s.cluster.mu.Unlock()
+
+	s.nodeLocked()
+	return nil
+}
+
+func (s *MemoryLoadStore) Loads(ctx context.Context) (map[string]*InstanceLoad, error) {
+	s.cluster.mu.Lock()
+	defer s.cluster.mu.Unlock()
+
+	cutoff := s.now().Add(-s.ttl)
+	var live []map[string]*nodeLoad
+	for nodeID, node := range s.cluster.nodes {
+		if s.cluster.lastSeen[nodeID].Before(cutoff) {
+			delete(s.cluster.nodes, nodeID)
+			delete(s.cluster.lastSeen, nodeID)
+			continue
+		}
+		live = append(live, node)
+	}
+	return mergeLoads(live), nil
+}
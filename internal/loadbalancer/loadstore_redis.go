@@ -0,0 +1,176 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisLoadStore shares loads between replicas through Redis. Each node
+// keeps its counts in a hash of its own, updated atomically by Lua scripts
+// and expiring with the node, and registers in a sorted set scored by its
+// last heartbeat.
+//
+// Keys, under prefix:
+//
+//	<prefix>:nodes          sorted set of node IDs by last heartbeat (ms)
+//	<prefix>:node:<nodeID>  hash of active:<id>, total:<id>, ewma:<id>,
+//	                        n:<id>, provider:<id> and used:<id>
+type RedisLoadStore struct {
+	client *redis.Client
+	prefix string
+	nodeID string
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewRedisLoadStore creates a Redis-backed load store for one node. Nodes
+// not heard from within ttl are left out of loads and their hashes expire.
+func NewRedisLoadStore(client *redis.Client, prefix, nodeID string, ttl time.Duration) *RedisLoadStore {
+	return &RedisLoadStore{client: client, prefix: prefix, nodeID: nodeID, ttl: ttl, now: time.Now}
+}
+
+func (s *RedisLoadStore) nodesKey() string {
+	return s.prefix + ":nodes"
+}
+
+func (s *RedisLoadStore) nodeKey(nodeID string) string {
+	return s.prefix + ":node:" + nodeID
+}
+
+// addConnectionsScript adjusts a node's active connections, flooring them
+// at zero, and refreshes the node
+var addConnectionsScript = redis.NewScript(`
+local active = redis.call('HINCRBY', KEYS[1], 'active:' .. ARGV[1], ARGV[2])
+if active < 0 then
+	redis.call('HSET', KEYS[1], 'active:' .. ARGV[1], 0)
+	active = 0
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call('HINCRBY', KEYS[1], 'total:' .. ARGV[1], ARGV[2])
+	redis.call('HSET', KEYS[1], 'used:' .. ARGV[1], ARGV[4])
+end
+if ARGV[3] ~= '' then
+	redis.call('HSET', KEYS[1], 'provider:' .. ARGV[1], ARGV[3])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+redis.call('ZADD', KEYS[2], ARGV[4], ARGV[6])
+return active
+`)
+
+// recordLatencyScript folds a sample (ns) into a node's EWMA latency and
+// refreshes the node
+var recordLatencyScript = redis.NewScript(`
+local sample = tonumber(ARGV[2])
+local ewma = tonumber(redis.call('HGET', KEYS[1], 'ewma:' .. ARGV[1]))
+local n = tonumber(redis.call('HGET', KEYS[1], 'n:' .. ARGV[1])) or 0
+if ewma == nil or n == 0 then
+	ewma = sample
+else
+	ewma = ARGV[3] * sample + (1 - ARGV[3]) * ewma
+end
+n = math.min(n + 1, tonumber(ARGV[4]))
+redis.call('HSET', KEYS[1], 'ewma:' .. ARGV[1], string.format('%d', math.floor(ewma + 0.5)), 'n:' .. ARGV[1], n)
+redis.call('PEXPIRE', KEYS[1], ARGV[6])
+redis.call('ZADD', KEYS[2], ARGV[5], ARGV[7])
+return n
+`)
+
+func (s *RedisLoadStore) AddConnections(ctx context.Context, instanceID, provider string, delta int) error {
+	err := addConnectionsScript.Run(ctx, s.client,
+		[]string{s.nodeKey(s.nodeID), s.nodesKey()},
+		instanceID, delta, provider, s.now().UnixMilli(), s.ttl.Milliseconds(), s.nodeID,
+	).Err()
+	if err != nil {
+		return fmt.Errorf("failed to record connections: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisLoadStore) RecordLatency(ctx context.Context, instanceID string, duration time.Duration) error {
+	err := recordLatencyScript.Run(ctx, s.client,
+		[]string{s.nodeKey(s.nodeID), s.nodesKey()},
+		instanceID, duration.Nanoseconds(), ewmaAlpha, ewmaMaxWeight, s.now().UnixMilli(), s.ttl.Milliseconds(), s.nodeID,
+	).Err()
+	if err != nil {
+		return fmt.Errorf("failed to record latency: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisLoadStore) Heartbeat(ctx context.Context) error {
+	pipe := s.client.TxPipeline()
+	pipe.ZAdd(ctx, s.nodesKey(), redis.Z{Score: float64(s.now().UnixMilli()), Member: s.nodeID})
+	pipe.PExpire(ctx, s.nodeKey(s.nodeID), s.ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisLoadStore) Loads(ctx context.Context) (map[string]*InstanceLoad, error) {
+	cutoff := strconv.FormatInt(s.now().Add(-s.ttl).UnixMilli(), 10)
+
+	// Forget dead nodes; their hashes expire on their own
+	if err := s.client.ZRemRangeByScore(ctx, s.nodesKey(), "-inf", "("+cutoff).Err(); err != nil {
+		return nil, fmt.Errorf("failed to prune nodes: %w", err)
+	}
+	nodeIDs, err := s.client.ZRangeByScore(ctx, s.nodesKey(), &redis.ZRangeBy{Min: cutoff, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		cmds[i] = pipe.HGetAll(ctx, s.nodeKey(nodeID))
+	}
+	if len(cmds) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to read node loads: %w", err)
+		}
+	}
+
+	nodes := make([]map[string]*nodeLoad, 0, len(cmds))
+	for _, cmd := range cmds {
+		nodes = append(nodes, parseNodeLoads(cmd.Val()))
+	}
+	return mergeLoads(nodes), nil
+}
+
+// parseNodeLoads decodes a node hash
+func parseNodeLoads(fields map[string]string) map[string]*nodeLoad {
+	node := make(map[string]*nodeLoad)
+	for field, value := range fields {
+		name, instanceID, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		l, ok := node[instanceID]
+		if !ok {
+			l = &nodeLoad{}
+			node[instanceID] = l
+		}
+		switch name {
+		case "active":
+			l.active, _ = strconv.Atoi(value)
+		case "total":
+			l.total, _ = strconv.ParseInt(value, 10, 64)
+		case "ewma":
+			l.ewma, _ = strconv.ParseFloat(value, 64)
+		case "n":
+			l.samples, _ = strconv.Atoi(value)
+		case "provider":
+			l.provider = value
+		case "used":
+			if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+				l.lastUsed = time.UnixMilli(ms)
+			}
+		}
+	}
+	return node
+}
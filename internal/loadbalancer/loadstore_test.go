@@ -0,0 +1,254 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testStoreTTL = 10 * time.Second
+
+// newNodeFunc returns a node of one shared store, on the test's clock
+type newNodeFunc func(nodeID string) LoadStore
+
+// testLoadStore is the conformance suite every LoadStore must pass
+func testLoadStore(t *testing.T, newStore func(t *testing.T, clock *fakeClock) newNodeFunc) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (newNodeFunc, *fakeClock) {
+		clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+		return newStore(t, clock), clock
+	}
+
+	t.Run("ConnectionsMergeAcrossNodes", func(t *testing.T) {
+		newNode, _ := setup(t)
+		a, b := newNode("a"), newNode("b")
+
+		require.NoError(t, a.AddConnections(ctx, "gpu-1", "vast.ai", 1))
+		require.NoError(t, a.AddConnections(ctx, "gpu-1", "", 1))
+		require.NoError(t, b.AddConnections(ctx, "gpu-1", "vast.ai", 1))
+		require.NoError(t, b.AddConnections(ctx, "gpu-2", "io.net", 1))
+		require.NoError(t, a.AddConnections(ctx, "gpu-1", "", -1))
+
+		for _, node := range []LoadStore{a, b} {
+			loads, err := node.Loads(ctx)
+			require.NoError(t, err)
+			require.Len(t, loads, 2)
+			assert.Equal(t, 2, loads["gpu-1"].ActiveConnections)
+			assert.Equal(t, int64(3), loads["gpu-1"].TotalConnections)
+			assert.Equal(t, "vast.ai", loads["gpu-1"].Provider)
+			assert.Equal(t, 1, loads["gpu-2"].ActiveConnections)
+			assert.Equal(t, "io.net", loads["gpu-2"].Provider)
+		}
+	})
+
+	t.Run("ActiveNeverNegative", func(t *testing.T) {
+		newNode, _ := setup(t)
+		a := newNode("a")
+
+		require.NoError(t, a.AddConnections(ctx, "gpu-1", "", -1))
+		require.NoError(t, a.AddConnections(ctx, "gpu-1", "", 1))
+
+		loads, err := a.Loads(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, loads["gpu-1"].ActiveConnections)
+	})
+
+	t.Run("AtomicConnections", func(t *testing.T) {
+		newNode, _ := setup(t)
+		a, b := newNode("a"), newNode("b")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(node LoadStore) {
+				defer wg.Done()
+				assert.NoError(t, node.AddConnections(ctx, "gpu-1", "", 1))
+			}([]LoadStore{a, b}[i%2])
+		}
+		wg.Wait()
+
+		loads, err := a.Loads(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 50, loads["gpu-1"].ActiveConnections)
+		assert.Equal(t, int64(50), loads["gpu-1"].TotalConnections)
+	})
+
+	t.Run("LatencyEWMA", func(t *testing.T) {
+		newNode, _ := setup(t)
+		a, b := newNode("a"), newNode("b")
+
+		require.NoError(t, a.RecordLatency(ctx, "gpu-1", 100*time.Millisecond))
+		loads, err := a.Loads(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 100*time.Millisecond, loads["gpu-1"].AvgResponseTime, "the first sample is the average")
+
+		require.NoError(t, a.RecordLatency(ctx, "gpu-1", 200*time.Millisecond))
+		loads, err = a.Loads(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 120*time.Millisecond, loads["gpu-1"].AvgResponseTime)
+
+		// b's single sample counts half as much as a's two
+		require.NoError(t, b.RecordLatency(ctx, "gpu-1", 30*time.Millisecond))
+		loads, err = b.Loads(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 90*time.Millisecond, loads["gpu-1"].AvgResponseTime)
+	})
+
+	t.Run("DeadNodesExpire", func(t *testing.T) {
+		newNode, clock := setup(t)
+		a, b := newNode("a"), newNode("b")
+
+		require.NoError(t, a.AddConnections(ctx, "gpu-1", "", 1))
+		require.NoError(t, b.AddConnections(ctx, "gpu-1", "", 2))
+
+		// a keeps heartbeating; b has died
+		clock.advance(testStoreTTL / 2)
+		require.NoError(t, a.Heartbeat(ctx))
+		clock.advance(testStoreTTL/2 + time.Second)
+
+		loads, err := a.Loads(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, loads["gpu-1"].ActiveConnections)
+		assert.Equal(t, int64(1), loads["gpu-1"].TotalConnections)
+	})
+}
+
+func TestMemoryLoadStore(t *testing.T) {
+	testLoadStore(t, func(t *testing.T, clock *fakeClock) newNodeFunc {
+		cluster := NewMemoryLoadStore("", testStoreTTL)
+		return func(nodeID string) LoadStore {
+			node := cluster.Join(nodeID)
+			node.now = clock.now
+			return node
+		}
+	})
+}
+
+// TestRedisLoadStore runs against the Redis at LB_TEST_REDIS_ADDR, e.g.
+// localhost:6379
+func TestRedisLoadStore(t *testing.T) {
+	addr := os.Getenv("LB_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("LB_TEST_REDIS_ADDR not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	require.NoError(t, client.Ping(context.Background()).Err())
+
+	testLoadStore(t, func(t *testing.T, clock *fakeClock) newNodeFunc {
+		prefix := fmt.Sprintf("gpuproxy-test:%d", time.Now().UnixNano())
+		t.Cleanup(func() {
+			ctx := context.Background()
+			keys, _ := client.Keys(ctx, prefix+":*").Result()
+			if len(keys) > 0 {
+				client.Del(ctx, keys...)
+			}
+		})
+		return func(nodeID string) LoadStore {
+			node := NewRedisLoadStore(client, prefix, nodeID, testStoreTTL)
+			node.now = clock.now
+			return node
+		}
+	})
+}
+
+func TestLoadBalancerServiceSharedLoads(t *testing.T) {
+	cluster := NewMemoryLoadStore("replica-1", testStoreTTL)
+	one := NewLoadBalancerService(StrategyLeastConnections)
+	one.SetLoadStore(cluster)
+	two := NewLoadBalancerService(StrategyLeastConnections)
+	two.SetLoadStore(cluster.Join("replica-2"))
+	ctx := context.Background()
+	instances := testInstances(2)
+
+	// Replica one sends a long-running request to whichever it picks
+	busy, err := one.SelectInstance(ctx, instances)
+	require.NoError(t, err)
+	one.TrackConnection(busy.ID)
+
+	// Replica two sees that connection once it syncs
+	require.NoError(t, two.SyncLoads(ctx))
+	selected, err := two.SelectInstance(ctx, instances)
+	require.NoError(t, err)
+	assert.NotEqual(t, busy.ID, selected.ID)
+	assert.Equal(t, 1, two.GetInstanceLoad(busy.ID).ActiveConnections)
+
+	// Once it finishes, the connection disappears everywhere
+	one.TrackDisconnection(busy.ID)
+	require.NoError(t, two.SyncLoads(ctx))
+	assert.Equal(t, 0, two.GetInstanceLoad(busy.ID).ActiveConnections)
+	assert.Equal(t, int64(1), two.GetInstanceLoad(busy.ID).TotalConnections)
+
+	// The store survives a strategy change
+	two.SetStrategy(StrategyRoundRobin)
+	require.NoError(t, two.SyncLoads(ctx))
+	assert.Equal(t, int64(1), two.GetInstanceLoad(busy.ID).TotalConnections)
+}
+
+func TestLoadBalancerServiceSharedLoadsPools(t *testing.T) {
+	cluster := NewMemoryLoadStore("replica-1", testStoreTTL)
+	one := NewLoadBalancerService(StrategyLeastConnections)
+	one.SetLoadStore(cluster)
+	two := NewLoadBalancerService(StrategyLeastConnections)
+	two.SetLoadStore(cluster.Join("replica-2"))
+	require.NoError(t, two.AddPool(PoolConfig{Name: "h100", Selector: "gpu=H100"}))
+	ctx := context.Background()
+	instances := poolInstances()
+
+	_, err := two.SelectInstance(WithPool(ctx, "h100"), instances)
+	require.NoError(t, err)
+	for _, inst := range instances {
+		one.TrackConnection(inst.ID)
+	}
+	require.NoError(t, two.SyncLoads(ctx))
+
+	loads := two.Pool("h100").GetAllLoads()
+	assert.Len(t, loads, 2, "pools only take the loads of their members")
+	for id, load := range loads {
+		assert.Contains(t, id, "h100")
+		assert.Equal(t, 1, load.ActiveConnections)
+	}
+	assert.Len(t, two.GetAllLoads(), len(instances))
+}
+
+func TestSyncLoadsDuringStrategyChanges(t *testing.T) {
+	cluster := NewMemoryLoadStore("replica-1", testStoreTTL)
+	one := NewLoadBalancerService(StrategyLeastConnections)
+	one.SetLoadStore(cluster)
+	two := NewLoadBalancerService(StrategyLeastConnections)
+	two.SetLoadStore(cluster.Join("replica-2"))
+	require.NoError(t, two.AddPool(PoolConfig{Name: "h100", Selector: "gpu=H100"}))
+	ctx := context.Background()
+	instances := poolInstances()
+
+	_, err := two.SelectInstance(WithPool(ctx, "h100"), instances)
+	require.NoError(t, err)
+	one.TrackConnection("h100-0")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			assert.NoError(t, two.SyncLoads(ctx))
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if i%2 == 0 {
+			two.Pool("h100").SetStrategy(StrategyRoundRobin)
+		} else {
+			two.Pool("h100").SetStrategy(StrategyLeastConnections)
+		}
+	}
+	wg.Wait()
+
+	require.NoError(t, two.SyncLoads(ctx))
+	assert.Equal(t, 1, two.Pool("h100").GetInstanceLoad("h100-0").ActiveConnections)
+}
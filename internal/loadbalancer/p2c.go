@@ -0,0 +1,181 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+)
+
+// DefaultEWMADecay is how long it takes the P2C latency estimate of an idle
+// instance to fall to 1/e of its value
+const DefaultEWMADecay = 10 * time.Second
+
+// unknownPenalty is the cost of an instance with requests in flight but no
+// latency samples yet, so new instances are tried but not swamped
+const unknownPenalty = math.MaxFloat64 / 2
+
+// P2CLB picks two instances at random and sends the request to the cheaper,
+// which takes constant time however many instances there are. An instance's
+// cost is its peak-EWMA latency times its in-flight requests plus one: a
+// response slower than the estimate replaces it outright, faster ones pull
+// it down gradually, and the estimate decays while the instance is idle so
+// that a once-slow instance is eventually tried again. In a pool with
+// weights the cost is divided by the instance's weight; a weight of 0 drains
+// an instance, and only when both choices are drained are the rest scanned.
+type P2CLB struct {
+	*BaseLoadBalancer
+	decay time.Duration
+	peaks map[string]*peakEWMA
+	now   func() time.Time
+	intn  func(n int) int
+}
+
+type peakEWMA struct {
+	ns    float64
+	stamp time.Time
+}
+
+func newP2C(strategy Strategy, decay time.Duration) *P2CLB {
+	if decay <= 0 {
+		decay = DefaultEWMADecay
+	}
+	return &P2CLB{
+		BaseLoadBalancer: newBase(strategy),
+		decay:            decay,
+		peaks:            make(map[string]*peakEWMA),
+		now:              time.Now,
+		intn:             rand.IntN,
+	}
+}
+
+func (lb *P2CLB) SelectInstance(instances []models.GPUInstance) (*models.GPUInstance, error) {
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("no instances available")
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if len(instances) == 1 {
+		lb.ensureLoadUnsafe(instances[0].ID, instances[0].Provider)
+		return &instances[0], nil
+	}
+
+	i := lb.intn(len(instances))
+	j := lb.intn(len(instances) - 1)
+	if j >= i {
+		j++
+	}
+	return lb.pickUnsafe(instances, i, j, nil), nil
+}
+
+// maxHealthSamples bounds how many instances selectHealthy draws to find
+// two that are not ejected before it filters the whole list
+const maxHealthSamples = 8
+
+// selectHealthy selects like SelectInstance, but only checks the health of
+// the instances it draws so that selection stays constant-time; an ejected
+// choice is replaced by another draw. If too many draws are ejected, it
+// filters every instance as the other strategies do.
+func (lb *P2CLB) selectHealthy(instances []models.GPUInstance, health *HealthChecker) (*models.GPUInstance, error) {
+	if len(instances) > 2 {
+		lb.mu.Lock()
+		i, j := -1, -1
+		for n := 0; n < maxHealthSamples && j < 0; n++ {
+			k := lb.intn(len(instances))
+			if k == i || !health.check(instances[k]) {
+				continue
+			}
+			if i < 0 {
+				i = k
+			} else {
+				j = k
+			}
+		}
+		if j >= 0 {
+			defer lb.mu.Unlock()
+			return lb.pickUnsafe(instances, i, j, health), nil
+		}
+		lb.mu.Unlock()
+	}
+
+	health.Observe(instances)
+	return lb.SelectInstance(health.Filter(instances))
+}
+
+// pickUnsafe returns the cheaper of instances i and j. If both are drained
+// it falls back to the cheapest of the rest that health, if set, hasn't
+// ejected. It must be called while holding the lock.
+func (lb *P2CLB) pickUnsafe(instances []models.GPUInstance, i, j int, health *HealthChecker) *models.GPUInstance {
+	now := lb.now()
+	cost := lb.costUnsafe(&instances[i], now)
+	if costJ := lb.costUnsafe(&instances[j], now); costJ < cost {
+		i, cost = j, costJ
+	}
+	if math.IsInf(cost, 1) {
+		// Both choices are drained; fall back to the cheapest of the rest
+		for k := range instances {
+			if health != nil && !health.Healthy(instances[k].ID) {
+				continue
+			}
+			if costK := lb.costUnsafe(&instances[k], now); costK < cost {
+				i, cost = k, costK
+			}
+		}
+	}
+	return &instances[i]
+}
+
+// costUnsafe must be called while holding the lock
+func (lb *P2CLB) costUnsafe(instance *models.GPUInstance, now time.Time) float64 {
+	load := lb.ensureLoadUnsafe(instance.ID, instance.Provider)
+
+	// Heavier instances in a pool look proportionally cheaper, and a weight
+	// of 0 drains an instance even before it has latency samples
+	weight := lb.weight(instance)
+	if weight <= 0 {
+		return math.Inf(1)
+	}
+
+	latency := 0.0
+	if peak, ok := lb.peaks[instance.ID]; ok {
+		latency = peak.ns * math.Exp(-float64(now.Sub(peak.stamp))/float64(lb.decay))
+	} else if load.AvgResponseTime > 0 {
+		// Seen only by other replicas so far
+		latency = float64(load.AvgResponseTime)
+	}
+
+	if latency == 0 {
+		if load.ActiveConnections > 0 {
+			return unknownPenalty / weight
+		}
+		return 0
+	}
+	return latency * float64(load.ActiveConnections+1) / weight
+}
+
+func (lb *P2CLB) RecordResponseTime(instanceID string, duration time.Duration) {
+	lb.BaseLoadBalancer.RecordResponseTime(instanceID, duration)
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	now := lb.now()
+	sample := float64(duration)
+	peak, ok := lb.peaks[instanceID]
+	if !ok {
+		lb.peaks[instanceID] = &peakEWMA{ns: sample, stamp: now}
+		return
+	}
+
+	if sample > peak.ns {
+		peak.ns = sample
+	} else {
+		w := math.Exp(-float64(now.Sub(peak.stamp)) / float64(lb.decay))
+		peak.ns = peak.ns*w + sample*(1-w)
+	}
+	peak.stamp = now
+}
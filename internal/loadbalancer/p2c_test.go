@@ -0,0 +1,144 @@
+package loadbalancer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestP2C returns a P2C balancer on a fake clock whose two random picks
+// are always the first two instances
+func newTestP2C() (*P2CLB, *fakeClock) {
+	lb := newP2C(StrategyP2C, 10*time.Second)
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	lb.now = clock.now
+	lb.intn = func(n int) int { return 0 }
+	return lb, clock
+}
+
+func selectID(t *testing.T, lb LoadBalancer, instances []models.GPUInstance) string {
+	t.Helper()
+	selected, err := lb.SelectInstance(instances)
+	require.NoError(t, err)
+	return selected.ID
+}
+
+func TestP2CPrefersLowerLatency(t *testing.T) {
+	lb, _ := newTestP2C()
+	instances := testInstances(2)
+	selectID(t, lb, instances)
+
+	lb.RecordResponseTime("gpu-0", 200*time.Millisecond)
+	lb.RecordResponseTime("gpu-1", 50*time.Millisecond)
+	assert.Equal(t, "gpu-1", selectID(t, lb, instances))
+
+	// In-flight requests multiply the cost: 50ms x 5 > 200ms x 1
+	for i := 0; i < 4; i++ {
+		lb.RecordConnection("gpu-1")
+	}
+	assert.Equal(t, "gpu-0", selectID(t, lb, instances))
+}
+
+func TestP2CPeakEWMA(t *testing.T) {
+	lb, clock := newTestP2C()
+	selectID(t, lb, testInstances(1))
+
+	lb.RecordResponseTime("gpu-0", 100*time.Millisecond)
+	lb.RecordResponseTime("gpu-0", 400*time.Millisecond)
+	assert.InDelta(t, float64(400*time.Millisecond), lb.peaks["gpu-0"].ns, 1, "a slower response replaces the estimate")
+
+	// One decay period later a fast response pulls it most of the way down
+	clock.advance(10 * time.Second)
+	lb.RecordResponseTime("gpu-0", 100*time.Millisecond)
+	assert.InDelta(t, float64(210*time.Millisecond), lb.peaks["gpu-0"].ns, float64(time.Millisecond))
+
+	// While it is idle its cost decays too
+	clock.advance(10 * time.Second)
+	now := clock.now()
+	lb.mu.Lock()
+	cost := lb.costUnsafe(&models.GPUInstance{ID: "gpu-0"}, now)
+	lb.mu.Unlock()
+	assert.InDelta(t, float64(77*time.Millisecond), cost, float64(time.Millisecond))
+}
+
+func TestP2CUnknownLatency(t *testing.T) {
+	lb, _ := newTestP2C()
+	instances := testInstances(2)
+	selectID(t, lb, instances)
+	lb.RecordResponseTime("gpu-0", time.Second)
+
+	assert.Equal(t, "gpu-1", selectID(t, lb, instances), "new instances are tried")
+
+	lb.RecordConnection("gpu-1")
+	assert.Equal(t, "gpu-0", selectID(t, lb, instances), "but not swamped before their first response")
+}
+
+func TestP2CPicksTwoDistinct(t *testing.T) {
+	lb := newP2C(StrategyP2C, 0)
+	assert.Equal(t, DefaultEWMADecay, lb.decay)
+
+	instances := testInstances(3)
+	seen := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		seen[selectID(t, lb, instances)] = true
+	}
+	assert.Len(t, seen, 3)
+
+	assert.Equal(t, "gpu-0", selectID(t, lb, instances[:1]))
+	_, err := lb.SelectInstance(nil)
+	assert.Error(t, err)
+}
+
+func TestRecordResponseTimeEWMA(t *testing.T) {
+	lb := NewLoadBalancer(StrategyLeastResponseTime)
+	selectID(t, lb, testInstances(1))
+
+	lb.RecordResponseTime("gpu-0", 100*time.Millisecond)
+	lb.RecordResponseTime("gpu-0", 100*time.Millisecond)
+	lb.RecordResponseTime("gpu-0", 600*time.Millisecond)
+	assert.Equal(t, 200*time.Millisecond, lb.GetLoad("gpu-0").AvgResponseTime, "one slow response no longer dominates")
+}
+
+// drawn replaces a P2C balancer's random picks with draws, in order
+func drawn(lb *P2CLB, draws ...int) {
+	lb.intn = func(n int) int {
+		d := draws[0]
+		draws = draws[1:]
+		return d
+	}
+}
+
+func TestP2CChecksOnlyDrawnHealth(t *testing.T) {
+	s := NewLoadBalancerService(StrategyP2C)
+	s.SetHealthConfig(HealthConfig{Enabled: true, ConsecutiveFailures: 1})
+	instances := testInstances(100)
+	s.TrackFailure("gpu-3", errBoom)
+
+	// gpu-3 is ejected and drawn again in place of the first choice, and a
+	// repeat of the first choice doesn't count as the second
+	drawn(s.balancer.(*P2CLB), 3, 7, 7, 3, 9)
+	selected, err := s.SelectInstance(context.Background(), instances)
+	require.NoError(t, err)
+	assert.Equal(t, "gpu-7", selected.ID)
+	assert.Len(t, s.health.seen, 3, "only the drawn instances are checked")
+}
+
+func TestP2CFiltersWhenDrawsAreEjected(t *testing.T) {
+	s := NewLoadBalancerService(StrategyP2C)
+	s.SetHealthConfig(HealthConfig{Enabled: true, ConsecutiveFailures: 1})
+	instances := testInstances(10)
+	for _, inst := range instances {
+		if inst.ID != "gpu-5" {
+			s.TrackFailure(inst.ID, errBoom)
+		}
+	}
+
+	s.balancer.(*P2CLB).intn = func(n int) int { return 0 }
+	selected, err := s.SelectInstance(context.Background(), instances)
+	require.NoError(t, err)
+	assert.Equal(t, "gpu-5", selected.ID)
+}
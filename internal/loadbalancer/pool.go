@@ -0,0 +1,225 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// PoolConfig defines a named pool of instances, such as all H100s serving
+// one model, balanced separately from the rest
+type PoolConfig struct {
+	Name     string             `yaml:"name"`
+	Selector string             `yaml:"selector"` // Label selector for members; see Selector
+	Strategy Strategy           `yaml:"strategy"` // Defaults to the service's strategy
+	Weights  map[string]float64 `yaml:"weights"`  // Label selector -> weight of the members it matches
+	Health   *HealthConfig      `yaml:"health"`   // Defaults to the service's health settings
+}
+
+// LoadPools reads pool definitions from a YAML (or JSON) file of the form
+// {"pools": [...]}
+func LoadPools(path string) ([]PoolConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pools: %w", err)
+	}
+	var file struct {
+		Pools []PoolConfig `yaml:"pools"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse pools: %w", err)
+	}
+	return file.Pools, nil
+}
+
+type pool struct {
+	name     string
+	selector Selector
+	service  *LoadBalancerService
+}
+
+// members returns the instances the pool's selector matches
+func (p *pool) members(instances []models.GPUInstance) []models.GPUInstance {
+	if p.selector.Empty() {
+		return instances
+	}
+	members := make([]models.GPUInstance, 0, len(instances))
+	for i := range instances {
+		if p.selector.Matches(&instances[i]) {
+			members = append(members, instances[i])
+		}
+	}
+	return members
+}
+
+// weightRule gives the instances a selector matches a weight
+type weightRule struct {
+	selector Selector
+	weight   float64
+}
+
+// parseWeights parses pool weights; an instance's weight is the product of
+// the weights of every rule it matches, or 1 if none do
+func parseWeights(weights map[string]float64) ([]weightRule, error) {
+	selectors := make([]string, 0, len(weights))
+	for s := range weights {
+		selectors = append(selectors, s)
+	}
+	sort.Strings(selectors)
+
+	rules := make([]weightRule, 0, len(weights))
+	for _, s := range selectors {
+		if weights[s] < 0 {
+			return nil, fmt.Errorf("weight for %q must not be negative", s)
+		}
+		sel, err := ParseSelector(s)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, weightRule{selector: sel, weight: weights[s]})
+	}
+	return rules, nil
+}
+
+// usesWeights reports whether a strategy honours pool weights; the others
+// would silently ignore them
+func usesWeights(strategy Strategy) bool {
+	return strategy == StrategyWeightedRoundRobin || strategy == StrategyP2C
+}
+
+func weigh(rules []weightRule, inst *models.GPUInstance) float64 {
+	weight := 1.0
+	for _, rule := range rules {
+		if rule.selector.Matches(inst) {
+			weight *= rule.weight
+		}
+	}
+	return weight
+}
+
+type poolContextKey struct{}
+
+// WithPool returns a context whose instance selections are made within the
+// named pool. An empty name leaves ctx unchanged.
+func WithPool(ctx context.Context, name string) context.Context {
+	if name == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, poolContextKey{}, name)
+}
+
+// PoolFromContext returns the pool set with WithPool
+func PoolFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(poolContextKey{}).(string)
+	return name
+}
+
+// AddPool adds a named pool with its own strategy, weights and health
+// settings. Unset settings are copied from the service as it is now, so
+// add pools once the service is configured.
+func (s *LoadBalancerService) AddPool(cfg PoolConfig) error {
+	if cfg.Name == "" {
+		return fmt.Errorf("pool name is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.pools[cfg.Name]; exists {
+		return fmt.Errorf("duplicate pool %q", cfg.Name)
+	}
+	selector, err := ParseSelector(cfg.Selector)
+	if err != nil {
+		return fmt.Errorf("pool %s: %w", cfg.Name, err)
+	}
+	strategy := s.strategy
+	if cfg.Strategy != "" {
+		if strategy, err = ParseStrategy(string(cfg.Strategy)); err != nil {
+			return fmt.Errorf("pool %s: %w", cfg.Name, err)
+		}
+	}
+	weights, err := parseWeights(cfg.Weights)
+	if err != nil {
+		return fmt.Errorf("pool %s: %w", cfg.Name, err)
+	}
+	if len(weights) > 0 && !usesWeights(strategy) {
+		return fmt.Errorf("pool %s: weights require the %s or %s strategy, not %s", cfg.Name, StrategyWeightedRoundRobin, StrategyP2C, strategy)
+	}
+
+	service := NewLoadBalancerService(strategy)
+	service.affinity = s.affinity
+	service.ewmaDecay = s.ewmaDecay
+	service.weights = weights
+	service.balancer = service.newBalancer(strategy)
+	if cfg.Health != nil {
+		service.SetHealthConfig(*cfg.Health)
+	} else {
+		service.SetHealthConfig(s.health.Config())
+	}
+
+	if s.pools == nil {
+		s.pools = make(map[string]*pool)
+	}
+	s.pools[cfg.Name] = &pool{name: cfg.Name, selector: selector, service: service}
+	s.poolNames = append(s.poolNames, cfg.Name)
+	return nil
+}
+
+// CheckStrategy returns why the service can't switch to strategy: a pool
+// with weights only takes strategies that honour them
+func (s *LoadBalancerService) CheckStrategy(strategy Strategy) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.weights) > 0 && !usesWeights(strategy) {
+		return fmt.Errorf("pool weights require the %s or %s strategy, not %s", StrategyWeightedRoundRobin, StrategyP2C, strategy)
+	}
+	return nil
+}
+
+// Pool returns the balancer of a named pool, or nil if there is none
+func (s *LoadBalancerService) Pool(name string) *LoadBalancerService {
+	if p, err := s.pool(name); err == nil {
+		return p.service
+	}
+	return nil
+}
+
+func (s *LoadBalancerService) pool(name string) (*pool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.pools[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown load balancer pool %q", name)
+	}
+	return p, nil
+}
+
+// poolServices returns the balancers of every pool
+func (s *LoadBalancerService) poolServices() []*LoadBalancerService {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	services := make([]*LoadBalancerService, 0, len(s.poolNames))
+	for _, name := range s.poolNames {
+		services = append(services, s.pools[name].service)
+	}
+	return services
+}
+
+// PoolNames lists the pools in the order they were added
+func (s *LoadBalancerService) PoolNames() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.poolNames...)
+}
+
+// PoolMembers returns the instances in a named pool
+func (s *LoadBalancerService) PoolMembers(name string, instances []models.GPUInstance) ([]models.GPUInstance, error) {
+	p, err := s.pool(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.members(instances), nil
+}
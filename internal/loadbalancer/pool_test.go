@@ -0,0 +1,248 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// poolInstances returns two H100s and two A100s
+func poolInstances() []models.GPUInstance {
+	return []models.GPUInstance{
+		{ID: "h100-0", Provider: "vast.ai", GPUName: "H100", Labels: map[string]string{"tier": "spot"}},
+		{ID: "a100-0", Provider: "io.net", GPUName: "A100"},
+		{ID: "h100-1", Provider: "io.net", GPUName: "H100"},
+		{ID: "a100-1", Provider: "vast.ai", GPUName: "A100"},
+	}
+}
+
+func selectIn(t *testing.T, s *LoadBalancerService, pool string, instances []models.GPUInstance) string {
+	t.Helper()
+	selected, err := s.SelectInstance(WithPool(context.Background(), pool), instances)
+	require.NoError(t, err)
+	return selected.ID
+}
+
+func TestLoadPools(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pools.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+pools:
+  - name: llama-h100
+    selector: "gpu=H100, model=llama"
+    strategy: p2c
+    weights:
+      "tier=spot": 0.5
+    health:
+      enabled: true
+      slow_response: 5s
+  - name: batch
+    selector: "!interactive"
+`), 0o644))
+
+	pools, err := LoadPools(path)
+	require.NoError(t, err)
+	require.Len(t, pools, 2)
+	assert.Equal(t, "llama-h100", pools[0].Name)
+	assert.Equal(t, StrategyP2C, pools[0].Strategy)
+	assert.Equal(t, map[string]float64{"tier=spot": 0.5}, pools[0].Weights)
+	require.NotNil(t, pools[0].Health)
+	assert.True(t, pools[0].Health.Enabled)
+	assert.Equal(t, 5*time.Second, pools[0].Health.SlowResponse)
+	assert.Nil(t, pools[1].Health)
+
+	_, err = LoadPools(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestAddPoolErrors(t *testing.T) {
+	s := NewLoadBalancerService(StrategyRoundRobin)
+	require.NoError(t, s.AddPool(PoolConfig{Name: "h100", Selector: "gpu=H100"}))
+
+	for name, cfg := range map[string]PoolConfig{
+		"no name":         {Selector: "gpu=H100"},
+		"duplicate":       {Name: "h100"},
+		"bad selector":    {Name: "a", Selector: "gpu in"},
+		"bad strategy":    {Name: "b", Strategy: "fastest"},
+		"negative weight": {Name: "c", Weights: map[string]float64{"tier=spot": -1}},
+		"bad weight":      {Name: "d", Weights: map[string]float64{"tier in": 2}},
+		"ignored weights": {Name: "e", Strategy: StrategyLeastConnections, Weights: map[string]float64{"tier=spot": 2}},
+		"global strategy": {Name: "f", Weights: map[string]float64{"tier=spot": 2}}, // round_robin ignores weights
+	} {
+		assert.Error(t, s.AddPool(cfg), name)
+	}
+	assert.Equal(t, []string{"h100"}, s.PoolNames())
+}
+
+func TestPoolRouting(t *testing.T) {
+	s := NewLoadBalancerService(StrategyRoundRobin)
+	require.NoError(t, s.AddPool(PoolConfig{Name: "h100", Selector: "gpu=H100", Strategy: StrategyLeastConnections}))
+	require.NoError(t, s.AddPool(PoolConfig{Name: "b200", Selector: "gpu=B200"}))
+	instances := poolInstances()
+
+	assert.Equal(t, StrategyLeastConnections, s.Pool("h100").GetStrategy())
+	assert.Equal(t, StrategyRoundRobin, s.Pool("b200").GetStrategy(), "unset strategies are inherited")
+	assert.Nil(t, s.Pool("missing"))
+
+	for i := 0; i < 10; i++ {
+		id := selectIn(t, s, "h100", instances)
+		assert.Contains(t, []string{"h100-0", "h100-1"}, id)
+		s.TrackConnection(id)
+	}
+	loads := s.Pool("h100").GetAllLoads()
+	assert.Equal(t, 5, loads["h100-0"].ActiveConnections, "least_connections spreads within the pool")
+	assert.Equal(t, 5, loads["h100-1"].ActiveConnections)
+
+	_, err := s.SelectInstance(WithPool(context.Background(), "missing"), instances)
+	assert.Error(t, err)
+	_, err = s.SelectInstance(WithPool(context.Background(), "b200"), instances)
+	assert.Error(t, err, "a pool with no members")
+
+	members, err := s.PoolMembers("h100", instances)
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+
+	// Without a pool the global strategy picks from every instance
+	assert.Equal(t, "h100-0", selectIn(t, s, "", instances))
+	assert.Equal(t, "a100-0", selectIn(t, s, "", instances))
+}
+
+func TestPoolTrackingFansOut(t *testing.T) {
+	s := NewLoadBalancerService(StrategyLeastConnections)
+	require.NoError(t, s.AddPool(PoolConfig{Name: "all"}))
+	instances := poolInstances()
+	selectIn(t, s, "", instances)
+	selectIn(t, s, "all", instances)
+
+	done := s.TrackRequest("a100-0")
+	assert.Equal(t, 1, s.GetInstanceLoad("a100-0").ActiveConnections)
+	assert.Equal(t, 1, s.Pool("all").GetInstanceLoad("a100-0").ActiveConnections)
+
+	done(100*time.Millisecond, nil)
+	assert.Equal(t, 0, s.Pool("all").GetInstanceLoad("a100-0").ActiveConnections)
+	assert.Equal(t, 100*time.Millisecond, s.Pool("all").GetInstanceLoad("a100-0").AvgResponseTime)
+}
+
+func TestStrategyChangeKeepsConnections(t *testing.T) {
+	s := NewLoadBalancerService(StrategyLeastConnections)
+	require.NoError(t, s.AddPool(PoolConfig{Name: "all"}))
+	instances := poolInstances()
+	selectIn(t, s, "all", instances)
+
+	open := s.TrackRequest("a100-0")
+	s.SetStrategy(StrategyRoundRobin)
+	s.Pool("all").SetStrategy(StrategyP2C)
+	assert.Equal(t, 1, s.GetInstanceLoad("a100-0").ActiveConnections)
+	assert.Equal(t, 1, s.Pool("all").GetInstanceLoad("a100-0").ActiveConnections)
+
+	// Requests racing with strategy changes are all counted out again
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				selected, err := s.SelectInstance(WithPool(context.Background(), "all"), instances)
+				if !assert.NoError(t, err) {
+					return
+				}
+				s.TrackRequest(selected.ID)(time.Millisecond, nil)
+			}
+		}()
+	}
+	strategies := []Strategy{StrategyLeastConnections, StrategyP2C, StrategyRoundRobin}
+	for i := 0; i < 30; i++ {
+		s.SetStrategy(strategies[i%len(strategies)])
+		s.Pool("all").SetAffinity(AffinityConfig{})
+		s.SetEWMADecay(time.Duration(i+1) * time.Second)
+	}
+	wg.Wait()
+
+	open(time.Millisecond, nil)
+	for id, load := range s.GetAllLoads() {
+		assert.Zero(t, load.ActiveConnections, id)
+	}
+	for id, load := range s.Pool("all").GetAllLoads() {
+		assert.Zero(t, load.ActiveConnections, id)
+	}
+}
+
+func TestPoolHealth(t *testing.T) {
+	s := NewLoadBalancerService(StrategyRoundRobin)
+	require.NoError(t, s.AddPool(PoolConfig{
+		Name:     "h100",
+		Selector: "gpu=H100",
+		Health:   &HealthConfig{Enabled: true, ConsecutiveFailures: 2},
+	}))
+	instances := poolInstances()
+
+	s.TrackFailure("h100-0", errors.New("boom"))
+	s.TrackFailure("h100-0", errors.New("boom"))
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, "h100-1", selectIn(t, s, "h100", instances), "the pool ejects failing members")
+	}
+	assert.False(t, s.Pool("h100").Health().Healthy("h100-0"))
+	assert.True(t, s.Health().Healthy("h100-0"), "health checks are off outside the pool")
+}
+
+func TestSmoothWeightedRoundRobin(t *testing.T) {
+	s := NewLoadBalancerService(StrategyRoundRobin)
+	require.NoError(t, s.AddPool(PoolConfig{
+		Name:     "h100",
+		Selector: "gpu=H100",
+		Strategy: StrategyWeightedRoundRobin,
+		Weights:  map[string]float64{"provider=vast.ai": 6, "tier=spot": 0.5},
+	}))
+	instances := poolInstances()
+
+	var picks []string
+	for i := 0; i < 8; i++ {
+		picks = append(picks, selectIn(t, s, "h100", instances))
+	}
+	// Weights multiply to 3:1, interleaved rather than in runs
+	assert.Equal(t, []string{"h100-0", "h100-0", "h100-1", "h100-0", "h100-0", "h100-0", "h100-1", "h100-0"}, picks)
+	assert.Equal(t, 3.0, s.Pool("h100").GetInstanceLoad("h100-0").Weight)
+	assert.NoError(t, s.Pool("h100").CheckStrategy(StrategyP2C))
+	assert.ErrorContains(t, s.Pool("h100").CheckStrategy(StrategyRoundRobin), "weights require")
+	assert.NoError(t, s.CheckStrategy(StrategyRoundRobin), "only pools have weights")
+
+	// A zero weight drains an instance
+	require.NoError(t, s.AddPool(PoolConfig{Name: "drain", Strategy: StrategyWeightedRoundRobin, Weights: map[string]float64{"gpu=A100": 0}}))
+	for i := 0; i < 8; i++ {
+		assert.Contains(t, []string{"h100-0", "h100-1"}, selectIn(t, s, "drain", instances))
+	}
+}
+
+func TestP2CWeights(t *testing.T) {
+	lb, _ := newTestP2C()
+	lb.weigh = func(instance *models.GPUInstance) float64 {
+		if instance.ID == "gpu-0" {
+			return 3
+		}
+		return 1
+	}
+	instances := testInstances(2)
+	selectID(t, lb, instances)
+	lb.RecordResponseTime("gpu-0", 200*time.Millisecond)
+	lb.RecordResponseTime("gpu-1", 100*time.Millisecond)
+
+	assert.Equal(t, "gpu-0", selectID(t, lb, instances), "200ms / 3 is cheaper than 100ms")
+}
+
+func TestP2CZeroWeightDrainsUnsampled(t *testing.T) {
+	s := NewLoadBalancerService(StrategyRoundRobin)
+	require.NoError(t, s.AddPool(PoolConfig{Name: "drain", Strategy: StrategyP2C, Weights: map[string]float64{"gpu=A100": 0}}))
+	instances := poolInstances()
+
+	for i := 0; i < 50; i++ {
+		assert.Contains(t, []string{"h100-0", "h100-1"}, selectIn(t, s, "drain", instances), "weight 0 is never selected")
+	}
+}
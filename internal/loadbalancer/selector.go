@@ -0,0 +1,235 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+)
+
+type selectorOp int
+
+const (
+	opEquals selectorOp = iota
+	opNotEquals
+	opIn
+	opNotIn
+	opExists
+	opNotExists
+)
+
+type requirement struct {
+	key    string
+	op     selectorOp
+	values []string
+}
+
+// Selector matches instances by label, in the style of Kubernetes label
+// selectors: comma-separated requirements that must all hold, each one of
+//
+//	key=value  key==value  key!=value
+//	key in (a, b)  key notin (a, b)
+//	key  !key
+//
+// An instance's labels are its Labels, then provider, gpu and region
+// standing for its Provider, GPUName and Location, then any
+// Specifications. Values match exactly.
+type Selector struct {
+	requirements []requirement
+}
+
+// ParseSelector parses a label selector; an empty selector matches every
+// instance
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	for _, part := range splitRequirements(s) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			if strings.TrimSpace(s) == "" {
+				break
+			}
+			return Selector{}, fmt.Errorf("empty requirement in selector %q", s)
+		}
+		req, err := parseRequirement(part)
+		if err != nil {
+			return Selector{}, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		sel.requirements = append(sel.requirements, req)
+	}
+	return sel, nil
+}
+
+// splitRequirements splits on commas outside parentheses
+func splitRequirements(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func parseRequirement(s string) (requirement, error) {
+	if key, ok := strings.CutPrefix(s, "!"); ok {
+		key = strings.TrimSpace(key)
+		if !validLabelKey(key) {
+			return requirement{}, fmt.Errorf("invalid label %q", key)
+		}
+		return requirement{key: key, op: opNotExists}, nil
+	}
+
+	if i := strings.Index(s, "("); i >= 0 {
+		fields := strings.Fields(s[:i])
+		if len(fields) != 2 || (fields[1] != "in" && fields[1] != "notin") {
+			return requirement{}, fmt.Errorf("%q: expected <label> in (...) or <label> notin (...)", s)
+		}
+		if !strings.HasSuffix(s, ")") {
+			return requirement{}, fmt.Errorf("%q: missing \")\"", s)
+		}
+		if !validLabelKey(fields[0]) {
+			return requirement{}, fmt.Errorf("invalid label %q", fields[0])
+		}
+		var values []string
+		for _, v := range strings.Split(s[i+1:len(s)-1], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			return requirement{}, fmt.Errorf("%q: empty list", s)
+		}
+		op := opIn
+		if fields[1] == "notin" {
+			op = opNotIn
+		}
+		return requirement{key: fields[0], op: op, values: values}, nil
+	}
+
+	for _, o := range []struct {
+		token string
+		op    selectorOp
+	}{{"!=", opNotEquals}, {"==", opEquals}, {"=", opEquals}} {
+		if key, value, ok := strings.Cut(s, o.token); ok {
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if !validLabelKey(key) {
+				return requirement{}, fmt.Errorf("invalid label %q", key)
+			}
+			return requirement{key: key, op: o.op, values: []string{value}}, nil
+		}
+	}
+
+	if !validLabelKey(s) {
+		return requirement{}, fmt.Errorf("invalid label %q", s)
+	}
+	return requirement{key: s, op: opExists}, nil
+}
+
+func validLabelKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_./", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches reports whether an instance satisfies every requirement
+func (s Selector) Matches(inst *models.GPUInstance) bool {
+	for _, req := range s.requirements {
+		value, ok := instanceLabel(inst, req.key)
+		switch req.op {
+		case opEquals:
+			if !ok || value != req.values[0] {
+				return false
+			}
+		case opNotEquals:
+			if ok && value == req.values[0] {
+				return false
+			}
+		case opIn:
+			if !ok || !contains(req.values, value) {
+				return false
+			}
+		case opNotIn:
+			if ok && contains(req.values, value) {
+				return false
+			}
+		case opExists:
+			if !ok {
+				return false
+			}
+		case opNotExists:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Empty reports whether the selector matches everything
+func (s Selector) Empty() bool {
+	return len(s.requirements) == 0
+}
+
+func (s Selector) String() string {
+	parts := make([]string, 0, len(s.requirements))
+	for _, req := range s.requirements {
+		switch req.op {
+		case opEquals:
+			parts = append(parts, req.key+"="+req.values[0])
+		case opNotEquals:
+			parts = append(parts, req.key+"!="+req.values[0])
+		case opIn:
+			parts = append(parts, req.key+" in ("+strings.Join(req.values, ",")+")")
+		case opNotIn:
+			parts = append(parts, req.key+" notin ("+strings.Join(req.values, ",")+")")
+		case opExists:
+			parts = append(parts, req.key)
+		case opNotExists:
+			parts = append(parts, "!"+req.key)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// instanceLabel looks up a label on an instance
+func instanceLabel(inst *models.GPUInstance, key string) (string, bool) {
+	if value, ok := inst.Labels[key]; ok {
+		return value, true
+	}
+	switch key {
+	case "provider":
+		return inst.Provider, inst.Provider != ""
+	case "gpu":
+		return inst.GPUName, inst.GPUName != ""
+	case "region":
+		return inst.Location, inst.Location != ""
+	}
+	if value, ok := inst.Specifications[key]; ok && value != nil {
+		return fmt.Sprint(value), true
+	}
+	return "", false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package loadbalancer
+
+import (
+	"testing"
+
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	inst := &models.GPUInstance{
+		ID:             "gpu-0",
+		Provider:       "vast.ai",
+		GPUName:        "H100",
+		Location:       "us-east",
+		Labels:         map[string]string{"model": "llama-3-70b", "tier": "spot"},
+		Specifications: map[string]interface{}{"cuda": 12.4, "provider": "ignored"},
+	}
+
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"model=llama-3-70b", true},
+		{"model==llama-3-70b", true},
+		{"model=mistral", false},
+		{"model!=mistral", true},
+		{"missing!=x", true},
+		{"gpu in (H100, A100)", true},
+		{"gpu notin (H100, A100)", false},
+		{"region notin (eu-west)", true},
+		{"missing in (a)", false},
+		{"tier", true},
+		{"!tier", false},
+		{"!interactive", true},
+		{"provider=vast.ai", true},
+		{"cuda=12.4", true},
+		{"model=llama-3-70b, gpu in (H100), !interactive", true},
+		{"model=llama-3-70b,tier=on-demand", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			sel, err := ParseSelector(tt.selector)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, sel.Matches(inst))
+		})
+	}
+}
+
+func TestSelectorLabelsOverridePseudoLabels(t *testing.T) {
+	sel, err := ParseSelector("gpu=h100-sxm")
+	require.NoError(t, err)
+	assert.True(t, sel.Matches(&models.GPUInstance{GPUName: "H100", Labels: map[string]string{"gpu": "h100-sxm"}}))
+	assert.False(t, sel.Matches(&models.GPUInstance{GPUName: "H100"}))
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	for _, s := range []string{
+		"a=b,,c=d",
+		"a=b,",
+		"=b",
+		"bad key=b",
+		"gpu in H100",
+		"gpu in (H100",
+		"gpu in ()",
+		"gpu among (H100)",
+		"!",
+	} {
+		_, err := ParseSelector(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestSelectorString(t *testing.T) {
+	sel, err := ParseSelector(" model = llama , gpu in (H100, A100), !spot ")
+	require.NoError(t, err)
+	assert.Equal(t, "model=llama,gpu in (H100,A100),!spot", sel.String())
+	assert.True(t, Selector{}.Empty())
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/aiserve/gpuproxy/internal/auth"
 	"github.com/aiserve/gpuproxy/internal/billing"
@@ -121,6 +122,10 @@ func (s *MCPServer) handleToolsList(ctx context.Context, req MCPRequest) ([]byte
 						"type":        "number",
 						"description": "Maximum price per hour in USD",
 					},
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Offer search, e.g. 'vram>=80 gpu in (H100, A100) price<2.5 region=us-* order by price_per_tflop limit 10'. Fields: " + strings.Join(gpu.OfferQueryFields(), ", "),
+					},
 				},
 			},
 		},
@@ -359,6 +364,7 @@ func (s *MCPServer) listGPUInstances(ctx context.Context, args json.RawMessage)
 		Provider string  `json:"provider"`
 		MinVRAM  int     `json:"min_vram"`
 		MaxPrice float64 `json:"max_price"`
+		Query    string  `json:"query"`
 	}
 
 	if len(args) > 0 {
@@ -371,6 +377,11 @@ func (s *MCPServer) listGPUInstances(ctx context.Context, args json.RawMessage)
 		params.Provider = "all"
 	}
 
+	query, err := gpu.ParseOfferQuery(params.Query)
+	if err != nil {
+		return nil, err
+	}
+
 	provider := gpu.Provider(params.Provider)
 	instances, err := s.gpuService.ListInstances(ctx, provider)
 	if err != nil {
@@ -386,8 +397,9 @@ func (s *MCPServer) listGPUInstances(ctx context.Context, args json.RawMessage)
 		filters["max_price"] = params.MaxPrice
 	}
 
-	if len(filters) > 0 {
-		instances = s.gpuService.FilterInstances(instances, filters)
+	query = query.And(gpu.FiltersQuery(filters))
+	if !query.Empty() {
+		instances = query.Apply(instances)
 	}
 
 	return instances, nil
@@ -0,0 +1,118 @@
+package mesh
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	headerNode      = "X-Mesh-Node"
+	headerTimestamp = "X-Mesh-Timestamp"
+	headerNonce     = "X-Mesh-Nonce"
+	headerSignature = "X-Mesh-Signature"
+	headerPath      = "X-Mesh-Path"
+
+	// maxClockSkew bounds how old a signed request may be
+	maxClockSkew = 5 * time.Minute
+)
+
+// signature computes the HMAC-SHA256 over a request's method, URL path,
+// timestamp, nonce, sender, forwarding path and body hash
+func signature(secret []byte, method, urlPath, timestamp, nonce, node, meshPath string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s\n%s\n%x", method, urlPath, timestamp, nonce, node, meshPath, bodyHash)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newNonce returns a random request nonce
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// sign adds the mesh authentication headers to an outgoing request. The
+// forwarding path header must already be set.
+func sign(req *http.Request, secret []byte, node string, body []byte, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	nonce := newNonce()
+	req.Header.Set(headerNode, node)
+	req.Header.Set(headerTimestamp, timestamp)
+	req.Header.Set(headerNonce, nonce)
+	req.Header.Set(headerSignature, signature(secret, req.Method, req.URL.Path, timestamp, nonce, node, req.Header.Get(headerPath), body))
+}
+
+// verify checks the mesh authentication headers of an incoming request,
+// rejects nonces already seen, and returns the sending node ID
+func verify(r *http.Request, secret []byte, body []byte, now time.Time, nonces *nonceCache) (string, error) {
+	node := r.Header.Get(headerNode)
+	timestamp := r.Header.Get(headerTimestamp)
+	nonce := r.Header.Get(headerNonce)
+	sig := r.Header.Get(headerSignature)
+	if node == "" || timestamp == "" || nonce == "" || sig == "" {
+		return "", fmt.Errorf("missing mesh authentication headers")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid mesh timestamp")
+	}
+	sent := time.Unix(unix, 0)
+	if skew := now.Sub(sent); skew > maxClockSkew || skew < -maxClockSkew {
+		return "", fmt.Errorf("mesh request timestamp outside the allowed window")
+	}
+
+	expected := signature(secret, r.Method, r.URL.Path, timestamp, nonce, node, r.Header.Get(headerPath), body)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return "", fmt.Errorf("invalid mesh signature")
+	}
+
+	// Only signed requests reach the cache, so it can't be filled by strangers
+	if !nonces.add(node+"/"+nonce, sent.Add(maxClockSkew), now) {
+		return "", fmt.Errorf("replayed mesh request")
+	}
+
+	return node, nil
+}
+
+// nonceCache remembers request nonces until their timestamp leaves the
+// clock-skew window, after which verify rejects them anyway
+type nonceCache struct {
+	mu        sync.Mutex
+	expires   map[string]time.Time
+	nextSweep time.Time
+}
+
+func newNonceCache() *nonceCache {
+	return &nonceCache{expires: make(map[string]time.Time)}
+}
+
+// add records a nonce and reports whether it was new
+func (c *nonceCache) add(nonce string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.After(c.nextSweep) {
+		for n, exp := range c.expires {
+			if now.After(exp) {
+				delete(c.expires, n)
+			}
+		}
+		c.nextSweep = now.Add(time.Minute)
+	}
+
+	if exp, ok := c.expires[nonce]; ok && !now.After(exp) {
+		return false
+	}
+	c.expires[nonce] = expires
+	return true
+}
@@ -0,0 +1,565 @@
+// Package mesh connects AIProxy nodes into a mesh.
+//
+// Nodes heartbeat their configured peers over HTTP, exchanging their own
+// provider health and load plus the freshest state they have heard about other
+// nodes (gossip). When share_load is enabled the mesh is exposed to the router
+// as the "mesh_peer" provider, which forwards requests to a healthy peer that
+// serves the model. Every mesh request is signed with the shared secret and a
+// single-use nonce, and forwarded requests carry the IDs of the nodes they
+// passed through so loops and overly long forwarding chains are rejected.
+package mesh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/providers"
+)
+
+const (
+	defaultHeartbeatInterval = 10 * time.Second
+	defaultPeerTimeout       = 5 * time.Second
+	defaultMaxHops           = 2
+
+	// missedHeartbeats is how many heartbeat intervals a peer may stay silent before it is considered down
+	missedHeartbeats = 3
+
+	// maxBodySize bounds mesh request bodies
+	maxBodySize = 32 << 20
+)
+
+// Backend is the local router as seen by the mesh
+type Backend interface {
+	// Predict serves a request forwarded by a peer
+	Predict(ctx context.Context, req *providers.PredictRequest) (*providers.PredictResponse, error)
+	// LocalState reports local provider health and load for heartbeats
+	LocalState(ctx context.Context) LocalState
+}
+
+// LocalState is a node's own provider health and load
+type LocalState struct {
+	Providers map[string]ProviderState `json:"providers"`
+	InFlight  int64                    `json:"in_flight"`
+}
+
+// ProviderState is the gossiped state of one provider on a node
+type ProviderState struct {
+	Available bool               `json:"available"`
+	Models    []string           `json:"models"`
+	Cost      map[string]float64 `json:"cost_per_1k_tokens,omitempty"` // by model
+}
+
+// NodeState is what a node announces about itself. Timestamp is set by the
+// originating node and orders gossip about it.
+type NodeState struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	Region    string    `json:"region,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	LocalState
+}
+
+// modelCost returns the cheapest available cost of a model on the node
+func (s *NodeState) modelCost(model string) (float64, bool) {
+	best, found := 0.0, false
+	for _, p := range s.Providers {
+		if !p.Available {
+			continue
+		}
+		for _, m := range p.Models {
+			if m != model {
+				continue
+			}
+			cost := p.Cost[model]
+			if !found || cost < best {
+				best, found = cost, true
+			}
+		}
+	}
+	return best, found
+}
+
+// heartbeat is exchanged between peers in both directions
+type heartbeat struct {
+	From   NodeState   `json:"from"`
+	Gossip []NodeState `json:"gossip,omitempty"`
+}
+
+// peer is a configured, directly reachable mesh peer
+type peer struct {
+	config   config.PeerConfig
+	lastSeen time.Time
+	latency  time.Duration
+	lastErr  string
+	state    *NodeState
+}
+
+// PeerStatus is a peer as reported by the mesh status endpoint
+type PeerStatus struct {
+	ID              string    `json:"id"`
+	Addr            string    `json:"addr"`
+	Priority        int       `json:"priority"`
+	Status          string    `json:"status"` // healthy, unreachable, unknown
+	LatencyMs       int       `json:"latency_ms"`
+	InFlight        int64     `json:"in_flight"`
+	ModelsAvailable int       `json:"models_available"`
+	LastSeen        time.Time `json:"last_seen,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// Status is a snapshot of the mesh as seen from this node
+type Status struct {
+	Node   NodeState    `json:"node"`
+	Peers  []PeerStatus `json:"peers"`
+	Gossip []NodeState  `json:"gossip"` // nodes known through peers, including indirect ones
+}
+
+// Node is this process's membership in the mesh
+type Node struct {
+	node    config.NodeConfig
+	secret  []byte
+	nonces  *nonceCache
+	backend Backend
+	client  *http.Client
+	now     func() time.Time
+
+	interval time.Duration
+	timeout  time.Duration
+	maxHops  int
+
+	mu     sync.RWMutex
+	peers  map[string]*peer
+	gossip map[string]NodeState // by node ID, excluding this node
+
+	server *http.Server
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewNode creates a mesh node from the node config
+func NewNode(cfg config.NodeConfig, backend Backend) (*Node, error) {
+	if cfg.ID == "" {
+		return nil, fmt.Errorf("node id is required")
+	}
+	if cfg.Mesh.SharedSecret == "" {
+		return nil, fmt.Errorf("mesh shared secret is required")
+	}
+	if backend == nil {
+		return nil, fmt.Errorf("mesh backend is required")
+	}
+
+	n := &Node{
+		node:     cfg,
+		secret:   []byte(cfg.Mesh.SharedSecret),
+		nonces:   newNonceCache(),
+		backend:  backend,
+		client:   &http.Client{},
+		now:      time.Now,
+		interval: cfg.Mesh.HeartbeatInterval,
+		timeout:  cfg.Mesh.PeerTimeout,
+		maxHops:  cfg.Mesh.MaxHops,
+		peers:    make(map[string]*peer),
+		gossip:   make(map[string]NodeState),
+	}
+	if n.interval <= 0 {
+		n.interval = defaultHeartbeatInterval
+	}
+	if n.timeout <= 0 {
+		n.timeout = defaultPeerTimeout
+	}
+	if n.maxHops <= 0 {
+		n.maxHops = defaultMaxHops
+	}
+
+	for _, pc := range cfg.Mesh.Peers {
+		if pc.ID == "" || pc.Addr == "" {
+			return nil, fmt.Errorf("mesh peers require id and addr")
+		}
+		n.peers[pc.ID] = &peer{config: pc}
+	}
+
+	return n, nil
+}
+
+// ID returns this node's ID
+func (n *Node) ID() string {
+	return n.node.ID
+}
+
+// Start listens on the configured mesh address and starts heartbeating peers
+func (n *Node) Start() error {
+	ln, err := net.Listen("tcp", n.node.Mesh.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", n.node.Mesh.ListenAddr, err)
+	}
+	n.Serve(ln)
+	return nil
+}
+
+// Serve accepts mesh traffic on a listener and starts heartbeating peers
+func (n *Node) Serve(ln net.Listener) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mesh/heartbeat", n.handleHeartbeat)
+	mux.HandleFunc("/mesh/predict", n.handlePredict)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.mu.Lock()
+	n.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	n.cancel = cancel
+	server := n.server
+	n.mu.Unlock()
+
+	n.wg.Add(2)
+	go func() {
+		defer n.wg.Done()
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Mesh server error: %v", err)
+		}
+	}()
+	go func() {
+		defer n.wg.Done()
+		n.heartbeatLoop(ctx)
+	}()
+}
+
+// Stop stops heartbeats and the mesh server
+func (n *Node) Stop() error {
+	n.mu.RLock()
+	server, cancel := n.server, n.cancel
+	n.mu.RUnlock()
+	if server == nil {
+		return nil
+	}
+
+	cancel()
+	ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+	err := server.Shutdown(ctx)
+	n.wg.Wait()
+	return err
+}
+
+// heartbeatLoop sends a heartbeat to every peer each interval
+func (n *Node) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+
+	for {
+		n.HeartbeatPeers(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// HeartbeatPeers sends one round of heartbeats to every peer concurrently
+func (n *Node) HeartbeatPeers(ctx context.Context) {
+	n.mu.RLock()
+	configs := make([]config.PeerConfig, 0, len(n.peers))
+	for _, p := range n.peers {
+		configs = append(configs, p.config)
+	}
+	n.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, pc := range configs {
+		wg.Add(1)
+		go func(pc config.PeerConfig) {
+			defer wg.Done()
+			n.heartbeatPeer(ctx, pc)
+		}(pc)
+	}
+	wg.Wait()
+}
+
+// heartbeatPeer exchanges state with one peer
+func (n *Node) heartbeatPeer(ctx context.Context, pc config.PeerConfig) {
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	start := n.now()
+	var reply heartbeat
+	err := n.call(ctx, pc.Addr, "/mesh/heartbeat", nil, n.heartbeatMessage(ctx), &reply)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	p := n.peers[pc.ID]
+	if err != nil {
+		p.lastErr = err.Error()
+		return
+	}
+	if reply.From.ID != pc.ID {
+		p.lastErr = fmt.Sprintf("peer at %s identified as %q", pc.Addr, reply.From.ID)
+		return
+	}
+
+	p.lastSeen = n.now()
+	p.latency = p.lastSeen.Sub(start)
+	p.lastErr = ""
+	n.mergeLocked(reply)
+}
+
+// heartbeatMessage builds this node's heartbeat
+func (n *Node) heartbeatMessage(ctx context.Context) heartbeat {
+	self := n.selfState(ctx)
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	gossip := make([]NodeState, 0, len(n.gossip))
+	for _, state := range n.gossip {
+		gossip = append(gossip, state)
+	}
+	return heartbeat{From: self, Gossip: gossip}
+}
+
+// selfState returns this node's current state
+func (n *Node) selfState(ctx context.Context) NodeState {
+	return NodeState{
+		ID:         n.node.ID,
+		Name:       n.node.Name,
+		Region:     n.node.Region,
+		Type:       n.node.Type,
+		Timestamp:  n.now(),
+		LocalState: n.backend.LocalState(ctx),
+	}
+}
+
+// mergeLocked records a peer's state and any fresher gossip it carried. n.mu must be held.
+func (n *Node) mergeLocked(hb heartbeat) {
+	from := hb.From
+	if p, ok := n.peers[from.ID]; ok {
+		p.state = &from
+	}
+
+	for _, state := range append(hb.Gossip, from) {
+		if state.ID == n.node.ID {
+			continue
+		}
+		if known, ok := n.gossip[state.ID]; ok && !state.Timestamp.After(known.Timestamp) {
+			continue
+		}
+		n.gossip[state.ID] = state
+	}
+}
+
+// handleHeartbeat serves POST /mesh/heartbeat
+func (n *Node) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	body, sender, ok := n.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var hb heartbeat
+	if err := json.Unmarshal(body, &hb); err != nil || hb.From.ID != sender {
+		http.Error(w, "invalid heartbeat", http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	if p, ok := n.peers[sender]; ok {
+		p.lastSeen = n.now()
+		p.lastErr = ""
+	}
+	n.mergeLocked(hb)
+	n.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, n.heartbeatMessage(r.Context()))
+}
+
+// handlePredict serves POST /mesh/predict, a request forwarded by a peer
+func (n *Node) handlePredict(w http.ResponseWriter, r *http.Request) {
+	body, sender, ok := n.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	path := parsePath(r.Header.Get(headerPath))
+	if len(path) == 0 || path[len(path)-1] != sender {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "mesh path must end with the sending node"})
+		return
+	}
+	for _, id := range path {
+		if id == n.node.ID {
+			writeJSON(w, http.StatusLoopDetected, map[string]string{"error": "mesh forwarding loop through " + n.node.ID})
+			return
+		}
+	}
+	if len(path) > n.maxHops {
+		writeJSON(w, http.StatusLoopDetected, map[string]string{"error": fmt.Sprintf("mesh hop limit of %d exceeded", n.maxHops)})
+		return
+	}
+
+	var req providers.PredictRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.Model == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	resp, err := n.backend.Predict(withPath(r.Context(), path), &req)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// authenticate reads and verifies a signed mesh request
+func (n *Node) authenticate(w http.ResponseWriter, r *http.Request) ([]byte, string, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, "", false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	sender, err := verify(r, n.secret, body, n.now(), n.nonces)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, "", false
+	}
+
+	return body, sender, true
+}
+
+// call sends a signed POST to a peer and decodes the JSON reply
+func (n *Node) call(ctx context.Context, addr, path string, headers map[string]string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	url := addr
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(url, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	sign(req, n.secret, n.node.ID, body, n.now())
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("peer returned status %d: %s", resp.StatusCode, apiErr.Error)
+		}
+		return fmt.Errorf("peer returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+// healthyLocked reports whether a peer has been heard from recently. n.mu must be held.
+func (n *Node) healthyLocked(p *peer) bool {
+	return !p.lastSeen.IsZero() && n.now().Sub(p.lastSeen) <= missedHeartbeats*n.interval
+}
+
+// Status returns this node's view of the mesh
+func (n *Node) Status(ctx context.Context) Status {
+	self := n.selfState(ctx)
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	status := Status{Node: self, Peers: []PeerStatus{}, Gossip: []NodeState{}}
+	for _, p := range n.peers {
+		ps := PeerStatus{
+			ID:        p.config.ID,
+			Addr:      p.config.Addr,
+			Priority:  p.config.Priority,
+			Status:    "unknown",
+			LatencyMs: int(p.latency.Milliseconds()),
+			LastSeen:  p.lastSeen,
+			Error:     p.lastErr,
+		}
+		switch {
+		case n.healthyLocked(p):
+			ps.Status = "healthy"
+		case p.lastErr != "" || !p.lastSeen.IsZero():
+			ps.Status = "unreachable"
+		}
+		if p.state != nil {
+			ps.InFlight = p.state.InFlight
+			ps.ModelsAvailable = len(availableModels(p.state))
+		}
+		status.Peers = append(status.Peers, ps)
+	}
+	sort.Slice(status.Peers, func(i, j int) bool {
+		return status.Peers[i].ID < status.Peers[j].ID
+	})
+
+	for _, state := range n.gossip {
+		status.Gossip = append(status.Gossip, state)
+	}
+	sort.Slice(status.Gossip, func(i, j int) bool {
+		return status.Gossip[i].ID < status.Gossip[j].ID
+	})
+
+	return status
+}
+
+// availableModels returns the models a node can currently serve
+func availableModels(state *NodeState) []string {
+	seen := make(map[string]bool)
+	var models []string
+	for _, p := range state.Providers {
+		if !p.Available {
+			continue
+		}
+		for _, m := range p.Models {
+			if !seen[m] {
+				seen[m] = true
+				models = append(models, m)
+			}
+		}
+	}
+	sort.Strings(models)
+	return models
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,293 @@
+package mesh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend stands in for a node's router: it serves its own models and,
+// like the router failing over to mesh_peer, forwards the rest to the mesh
+type fakeBackend struct {
+	id     string
+	models []string
+	node   *Node
+
+	mu     sync.Mutex
+	served int
+}
+
+func (b *fakeBackend) Predict(ctx context.Context, req *providers.PredictRequest) (*providers.PredictResponse, error) {
+	for _, m := range b.models {
+		if m == req.Model {
+			b.mu.Lock()
+			b.served++
+			b.mu.Unlock()
+			return &providers.PredictResponse{
+				Output:   "served by " + b.id,
+				Metadata: providers.ResponseMetadata{Provider: "local", Model: req.Model},
+			}, nil
+		}
+	}
+	return b.node.Provider().Predict(ctx, req)
+}
+
+func (b *fakeBackend) LocalState(ctx context.Context) LocalState {
+	cost := make(map[string]float64)
+	for _, m := range b.models {
+		cost[m] = 0
+	}
+	return LocalState{Providers: map[string]ProviderState{
+		"local": {Available: len(b.models) > 0, Models: b.models, Cost: cost},
+	}}
+}
+
+type testMesh struct {
+	nodes    map[string]*Node
+	backends map[string]*fakeBackend
+}
+
+// startMesh starts one node per id on loopback ports. links lists the peers of
+// each node; models lists the models each node serves itself.
+func startMesh(t *testing.T, links map[string][]string, models map[string][]string, tweak func(id string, cfg *config.NodeConfig)) *testMesh {
+	t.Helper()
+
+	listeners := make(map[string]net.Listener)
+	for id := range links {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		listeners[id] = ln
+	}
+
+	m := &testMesh{nodes: make(map[string]*Node), backends: make(map[string]*fakeBackend)}
+	for id, peers := range links {
+		cfg := config.NodeConfig{ID: id}
+		cfg.Mesh = config.MeshConfig{
+			Enabled:           true,
+			ShareLoad:         true,
+			SharedSecret:      "test-secret",
+			HeartbeatInterval: time.Hour, // heartbeats are driven by the test
+			PeerTimeout:       2 * time.Second,
+		}
+		for i, p := range peers {
+			cfg.Mesh.Peers = append(cfg.Mesh.Peers, config.PeerConfig{ID: p, Addr: listeners[p].Addr().String(), Priority: i + 1})
+		}
+		if tweak != nil {
+			tweak(id, &cfg)
+		}
+
+		backend := &fakeBackend{id: id, models: models[id]}
+		node, err := NewNode(cfg, backend)
+		require.NoError(t, err)
+		backend.node = node
+
+		m.nodes[id] = node
+		m.backends[id] = backend
+	}
+
+	for id, node := range m.nodes {
+		node.Serve(listeners[id])
+		t.Cleanup(func() { node.Stop() })
+	}
+
+	return m
+}
+
+// converge runs enough heartbeat rounds for gossip to cross the mesh
+func (m *testMesh) converge() {
+	for i := 0; i < len(m.nodes); i++ {
+		for _, node := range m.nodes {
+			node.HeartbeatPeers(context.Background())
+		}
+	}
+}
+
+func TestForwardsToHealthyPeer(t *testing.T) {
+	m := startMesh(t,
+		map[string][]string{"a": {"b", "c"}, "b": {"a", "c"}, "c": {"a", "b"}},
+		map[string][]string{"b": {"llama"}},
+		nil)
+	m.converge()
+
+	provider := m.nodes["a"].Provider()
+	assert.True(t, provider.IsAvailable(context.Background()))
+	assert.Equal(t, []string{"llama"}, provider.GetModels())
+
+	resp, err := provider.Predict(context.Background(), &providers.PredictRequest{Model: "llama", Input: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "served by b", resp.Output)
+	assert.Equal(t, "mesh_peer:b/local", resp.Metadata.Provider)
+
+	_, err = provider.Predict(context.Background(), &providers.PredictRequest{Model: "missing"})
+	assert.Error(t, err)
+
+	status := m.nodes["a"].Status(context.Background())
+	require.Len(t, status.Peers, 2)
+	for _, p := range status.Peers {
+		assert.Equal(t, "healthy", p.Status, p.ID)
+	}
+}
+
+func TestGossipReachesIndirectNodes(t *testing.T) {
+	// a - b - c: a learns about c only through b
+	m := startMesh(t,
+		map[string][]string{"a": {"b"}, "b": {"a", "c"}, "c": {"b"}},
+		map[string][]string{"c": {"llama"}},
+		nil)
+	m.converge()
+
+	var ids []string
+	for _, state := range m.nodes["a"].Status(context.Background()).Gossip {
+		ids = append(ids, state.ID)
+	}
+	assert.Equal(t, []string{"b", "c"}, ids)
+
+	// c is not a direct peer, so a cannot forward to it directly
+	assert.Empty(t, m.nodes["a"].Provider().GetModels())
+}
+
+func TestHopLimit(t *testing.T) {
+	links := map[string][]string{"a": {"b"}, "b": {"a", "c"}, "c": {"b"}}
+	// b advertises llama but cannot serve it itself, so it forwards on to c
+	models := map[string][]string{"b": {"llama"}, "c": {"llama"}}
+
+	for _, tc := range []struct {
+		maxHops int
+		ok      bool
+	}{{1, false}, {2, true}} {
+		t.Run(fmt.Sprintf("max_hops=%d", tc.maxHops), func(t *testing.T) {
+			m := startMesh(t, links, models, func(id string, cfg *config.NodeConfig) {
+				cfg.Mesh.MaxHops = tc.maxHops
+			})
+			m.converge()
+			m.backends["b"].models = nil
+
+			resp, err := m.nodes["a"].Provider().Predict(context.Background(), &providers.PredictRequest{Model: "llama"})
+			if !tc.ok {
+				assert.ErrorContains(t, err, "hop limit")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "served by c", resp.Output)
+			assert.Equal(t, "mesh_peer:b/mesh_peer:c/local", resp.Metadata.Provider)
+		})
+	}
+}
+
+func TestLoopPrevention(t *testing.T) {
+	m := startMesh(t,
+		map[string][]string{"a": {"b"}, "b": {"a"}},
+		map[string][]string{"a": {"llama"}, "b": {"llama"}},
+		nil)
+	m.converge()
+
+	// b no longer serves llama and must not bounce a's request back to a
+	m.backends["b"].models = nil
+	_, err := m.nodes["a"].Provider().Predict(context.Background(), &providers.PredictRequest{Model: "llama"})
+	assert.ErrorContains(t, err, "no healthy mesh peer")
+	assert.Zero(t, m.backends["a"].served)
+
+	// A request claiming to have passed through b already is rejected by b
+	var out providers.PredictResponse
+	err = m.nodes["a"].call(context.Background(), m.nodes["a"].peers["b"].config.Addr, "/mesh/predict",
+		map[string]string{headerPath: "b,a"}, &providers.PredictRequest{Model: "llama"}, &out)
+	assert.ErrorContains(t, err, "status 508")
+}
+
+func TestPeerAuthentication(t *testing.T) {
+	m := startMesh(t,
+		map[string][]string{"a": {"b"}, "b": {"a"}},
+		map[string][]string{"b": {"llama"}},
+		func(id string, cfg *config.NodeConfig) {
+			if id == "a" {
+				cfg.Mesh.SharedSecret = "wrong-secret"
+			}
+		})
+	m.converge()
+
+	assert.False(t, m.nodes["a"].Provider().IsAvailable(context.Background()))
+	status := m.nodes["a"].Status(context.Background())
+	require.Len(t, status.Peers, 1)
+	assert.Equal(t, "unreachable", status.Peers[0].Status)
+	assert.Contains(t, status.Peers[0].Error, "401")
+
+	// Unsigned requests are rejected
+	addr := m.nodes["a"].peers["b"].config.Addr
+	resp, err := http.Post("http://"+addr+"/mesh/predict", "application/json", bytes.NewReader([]byte(`{"model":"llama"}`)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestReplayedRequestsRejected(t *testing.T) {
+	m := startMesh(t,
+		map[string][]string{"a": {"b"}, "b": {"a"}},
+		map[string][]string{"b": {"llama"}},
+		nil)
+	addr := m.nodes["a"].peers["b"].config.Addr
+
+	body := []byte(`{"model":"llama"}`)
+	captured, err := http.NewRequest(http.MethodPost, "http://"+addr+"/mesh/predict", bytes.NewReader(body))
+	require.NoError(t, err)
+	captured.Header.Set(headerPath, "a")
+	sign(captured, m.nodes["a"].secret, "a", body, time.Now())
+
+	send := func(path string) int {
+		req, err := http.NewRequest(http.MethodPost, "http://"+addr+path, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header = captured.Header.Clone()
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Tampering with the forwarding path breaks the signature
+	captured.Header.Set(headerPath, "c,a")
+	assert.Equal(t, http.StatusUnauthorized, send("/mesh/predict"))
+	captured.Header.Set(headerPath, "a")
+
+	// Redirecting to another endpoint breaks the signature
+	assert.Equal(t, http.StatusUnauthorized, send("/mesh/heartbeat"))
+
+	assert.Equal(t, http.StatusOK, send("/mesh/predict"))
+	assert.Equal(t, http.StatusUnauthorized, send("/mesh/predict"), "nonces are single-use")
+}
+
+func TestNonceCacheExpiry(t *testing.T) {
+	c := newNonceCache()
+	now := time.Unix(1700000000, 0)
+
+	assert.True(t, c.add("a/1", now.Add(maxClockSkew), now))
+	assert.False(t, c.add("a/1", now.Add(maxClockSkew), now.Add(time.Minute)))
+	assert.True(t, c.add("b/1", now.Add(maxClockSkew), now), "nonces are per sender")
+
+	later := now.Add(maxClockSkew + 2*time.Minute)
+	assert.True(t, c.add("c/1", later.Add(maxClockSkew), later))
+	assert.Len(t, c.expires, 1, "expired nonces are swept")
+}
+
+func TestShareLoadDisabled(t *testing.T) {
+	m := startMesh(t,
+		map[string][]string{"a": {"b"}, "b": {"a"}},
+		map[string][]string{"b": {"llama"}},
+		func(id string, cfg *config.NodeConfig) {
+			cfg.Mesh.ShareLoad = false
+		})
+	m.converge()
+
+	provider := m.nodes["a"].Provider()
+	assert.False(t, provider.IsAvailable(context.Background()))
+	assert.True(t, strings.HasSuffix(provider.Health(context.Background()).Message, "load sharing disabled"))
+}
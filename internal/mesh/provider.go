@@ -0,0 +1,216 @@
+package mesh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/providers"
+)
+
+const (
+	// ProviderName is the name the mesh is registered under in the router
+	ProviderName = "mesh_peer"
+
+	// providerPriority keeps the mesh behind every local and cloud provider of equal cost
+	providerPriority = 100
+)
+
+// ErrHopLimit is returned when forwarding a request would exceed the mesh hop limit
+var ErrHopLimit = errors.New("mesh hop limit reached")
+
+type pathContextKey struct{}
+
+// withPath records the nodes a forwarded request has passed through
+func withPath(ctx context.Context, path []string) context.Context {
+	return context.WithValue(ctx, pathContextKey{}, path)
+}
+
+// pathFrom returns the nodes a request has passed through, empty for requests
+// that originated on this node
+func pathFrom(ctx context.Context) []string {
+	path, _ := ctx.Value(pathContextKey{}).([]string)
+	return path
+}
+
+// Forwarded reports whether a request context belongs to a request forwarded by a peer
+func Forwarded(ctx context.Context) bool {
+	return len(pathFrom(ctx)) > 0
+}
+
+func parsePath(header string) []string {
+	var path []string
+	for _, id := range strings.Split(header, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			path = append(path, id)
+		}
+	}
+	return path
+}
+
+// Provider exposes the mesh to the router as the mesh_peer provider
+type Provider struct {
+	node *Node
+}
+
+// Provider returns the router provider that forwards to peers. It is only
+// available when share_load is enabled and a healthy peer serves a model.
+func (n *Node) Provider() *Provider {
+	return &Provider{node: n}
+}
+
+// Name returns the provider name
+func (p *Provider) Name() string {
+	return ProviderName
+}
+
+// Type returns the provider type
+func (p *Provider) Type() string {
+	return "mesh"
+}
+
+// IsAvailable reports whether load sharing is enabled and any peer is healthy
+func (p *Provider) IsAvailable(ctx context.Context) bool {
+	return p.node.node.Mesh.ShareLoad && len(p.node.candidates("", nil)) > 0
+}
+
+// GetModels returns the models served by healthy peers
+func (p *Provider) GetModels() []string {
+	seen := make(map[string]bool)
+	var models []string
+	for _, c := range p.node.candidates("", nil) {
+		for _, m := range availableModels(c.state) {
+			if !seen[m] {
+				seen[m] = true
+				models = append(models, m)
+			}
+		}
+	}
+	sort.Strings(models)
+	return models
+}
+
+// Predict forwards a request to the best healthy peer serving the model,
+// trying the next peer if one fails
+func (p *Provider) Predict(ctx context.Context, req *providers.PredictRequest) (*providers.PredictResponse, error) {
+	n := p.node
+	path := append(append([]string(nil), pathFrom(ctx)...), n.node.ID)
+	if len(path) > n.maxHops {
+		return nil, fmt.Errorf("%w (%d)", ErrHopLimit, n.maxHops)
+	}
+
+	candidates := n.candidates(req.Model, path)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no healthy mesh peer serves model %s", req.Model)
+	}
+
+	headers := map[string]string{headerPath: strings.Join(path, ",")}
+	var lastErr error
+	for _, c := range candidates {
+		startTime := time.Now()
+		var resp providers.PredictResponse
+		if err := n.call(ctx, c.config.Addr, "/mesh/predict", headers, req, &resp); err != nil {
+			lastErr = fmt.Errorf("peer %s: %w", c.config.ID, err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		resp.Metadata.Provider = fmt.Sprintf("%s:%s/%s", ProviderName, c.config.ID, resp.Metadata.Provider)
+		resp.Metadata.LatencyMs = int(time.Since(startTime).Milliseconds())
+		return &resp, nil
+	}
+
+	return nil, lastErr
+}
+
+// PredictStream forwards a buffered prediction and emits it as a single chunk
+func (p *Provider) PredictStream(ctx context.Context, req *providers.PredictRequest) (<-chan providers.StreamChunk, error) {
+	return providers.StreamFromPredict(ctx, p, req)
+}
+
+// Health reports the number of healthy peers
+func (p *Provider) Health(ctx context.Context) providers.ProviderHealth {
+	healthy := len(p.node.candidates("", nil))
+	health := providers.ProviderHealth{
+		Provider:  ProviderName,
+		Healthy:   healthy > 0,
+		Available: p.IsAvailable(ctx),
+		Message:   fmt.Sprintf("%d healthy peers", healthy),
+	}
+	if !p.node.node.Mesh.ShareLoad {
+		health.Message += ", load sharing disabled"
+	}
+	return health
+}
+
+// Priority ranks the mesh after local and cloud providers
+func (p *Provider) Priority() int {
+	return providerPriority
+}
+
+// GetCostPer1kTokens returns the cheapest cost reported by a healthy peer serving the model
+func (p *Provider) GetCostPer1kTokens(model string) float64 {
+	candidates := p.node.candidates(model, nil)
+	if len(candidates) == 0 {
+		return 0
+	}
+	return candidates[0].cost
+}
+
+// candidate is a healthy peer that can take a request
+type candidate struct {
+	config config.PeerConfig
+	state  *NodeState
+	cost   float64
+}
+
+// candidates returns healthy peers not on the forwarding path, ordered by cost,
+// configured priority and load. An empty model matches any peer.
+func (n *Node) candidates(model string, path []string) []candidate {
+	excluded := make(map[string]bool, len(path))
+	for _, id := range path {
+		excluded[id] = true
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	var out []candidate
+	for id, p := range n.peers {
+		if excluded[id] || p.state == nil || !n.healthyLocked(p) {
+			continue
+		}
+		c := candidate{config: p.config, state: p.state}
+		if model != "" {
+			cost, ok := p.state.modelCost(model)
+			if !ok {
+				continue
+			}
+			c.cost = cost
+		} else if len(availableModels(p.state)) == 0 {
+			continue
+		}
+		out = append(out, c)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.cost != b.cost {
+			return a.cost < b.cost
+		}
+		if a.config.Priority != b.config.Priority {
+			return a.config.Priority < b.config.Priority
+		}
+		if a.state.InFlight != b.state.InFlight {
+			return a.state.InFlight < b.state.InFlight
+		}
+		return a.config.ID < b.config.ID
+	})
+	return out
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/aiserve/gpuproxy/internal/gpu"
 )
 
 type Metrics struct {
@@ -23,6 +27,7 @@ type Metrics struct {
 	totalGPUCost        float64
 	gpuRequestsTotal    int64
 	gpuRequestsFailed   int64
+	gpuTelemetry        GPUTelemetry
 
 	// Database metrics
 	dbQueryDuration     *Histogram
@@ -51,6 +56,12 @@ type Metrics struct {
 	startTime time.Time
 }
 
+// GPUTelemetry provides the latest per-device sample of local GPUs
+type GPUTelemetry interface {
+	Snapshot() []gpu.DeviceStats
+	Status() gpu.TelemetryStatus
+}
+
 type Histogram struct {
 	mu     sync.RWMutex
 	counts []int64
@@ -144,6 +155,19 @@ func (m *Metrics) SetActiveGPUInstances(count int64) {
 	atomic.StoreInt64(&m.activeGPUInstances, count)
 }
 
+// SetGPUTelemetry exports local GPU readings from t
+func (m *Metrics) SetGPUTelemetry(t GPUTelemetry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gpuTelemetry = t
+}
+
+func (m *Metrics) getGPUTelemetry() GPUTelemetry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.gpuTelemetry
+}
+
 // Database metrics
 func (m *Metrics) RecordDBQuery(duration time.Duration) {
 	m.dbQueryDuration.Observe(duration)
@@ -353,7 +377,85 @@ gpuproxy_gc_total %d
 		m.numGC,
 	)
 
-	return prometheus
+	return prometheus + m.gpuDevicesPrometheus()
+}
+
+// gpuDevicesPrometheus renders the latest local GPU sample, one series per
+// device and reading. Readings a device does not report are left out.
+func (m *Metrics) gpuDevicesPrometheus() string {
+	telemetry := m.getGPUTelemetry()
+	if telemetry == nil {
+		return ""
+	}
+	devices := telemetry.Snapshot()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `
+# HELP gpuproxy_gpu_devices Local GPU devices in the latest telemetry sample
+# TYPE gpuproxy_gpu_devices gauge
+gpuproxy_gpu_devices %d
+`, len(devices))
+	if len(devices) == 0 {
+		return b.String()
+	}
+
+	series := []struct {
+		name, help, kind string
+		value            func(d gpu.DeviceStats) (float64, bool)
+	}{
+		{"gpuproxy_gpu_device_utilization_percent", "GPU utilization", "gauge",
+			func(d gpu.DeviceStats) (float64, bool) { return d.UtilizationPct, true }},
+		{"gpuproxy_gpu_device_memory_used_mb", "GPU memory used in MB", "gauge",
+			func(d gpu.DeviceStats) (float64, bool) { return float64(d.MemoryUsedMB), true }},
+		{"gpuproxy_gpu_device_memory_total_mb", "GPU memory in MB", "gauge",
+			func(d gpu.DeviceStats) (float64, bool) { return float64(d.MemoryTotalMB), true }},
+		{"gpuproxy_gpu_device_temperature_celsius", "GPU temperature", "gauge",
+			func(d gpu.DeviceStats) (float64, bool) { return optionalFloat(d.TemperatureC) }},
+		{"gpuproxy_gpu_device_power_watts", "GPU power draw", "gauge",
+			func(d gpu.DeviceStats) (float64, bool) { return optionalFloat(d.PowerW) }},
+		{"gpuproxy_gpu_device_ecc_corrected_errors_total", "Corrected ECC errors since the driver loaded", "counter",
+			func(d gpu.DeviceStats) (float64, bool) { return optionalInt(d.ECCCorrected) }},
+		{"gpuproxy_gpu_device_ecc_uncorrected_errors_total", "Uncorrected ECC errors since the driver loaded", "counter",
+			func(d gpu.DeviceStats) (float64, bool) { return optionalInt(d.ECCUncorrected) }},
+	}
+
+	for _, s := range series {
+		var lines strings.Builder
+		for _, d := range devices {
+			if v, ok := s.value(d); ok {
+				fmt.Fprintf(&lines, "%s{%s} %s\n", s.name, deviceLabels(d), strconv.FormatFloat(v, 'f', -1, 64))
+			}
+		}
+		if lines.Len() == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n# HELP %s %s\n# TYPE %s %s\n%s", s.name, s.help, s.name, s.kind, lines.String())
+	}
+	return b.String()
+}
+
+func deviceLabels(d gpu.DeviceStats) string {
+	return fmt.Sprintf(`backend="%s",index="%d",uuid="%s",name="%s"`,
+		escapeLabel(string(d.Backend)), d.Index, escapeLabel(d.UUID), escapeLabel(d.Name))
+}
+
+// escapeLabel escapes a Prometheus label value
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func optionalFloat(v *float64) (float64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	return *v, true
+}
+
+func optionalInt(v *int64) (float64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	return float64(*v), true
 }
 
 // Export as JSON
@@ -379,6 +481,17 @@ func (m *Metrics) ToJSON() map[string]interface{} {
 		cacheHitRate = float64(cacheHits) / float64(cacheHits+cacheMisses) * 100
 	}
 
+	gpuStats := map[string]interface{}{
+		"active_instances": atomic.LoadInt64(&m.activeGPUInstances),
+		"total_cost_usd":   m.totalGPUCost,
+		"requests_total":   atomic.LoadInt64(&m.gpuRequestsTotal),
+		"requests_failed":  atomic.LoadInt64(&m.gpuRequestsFailed),
+	}
+	if telemetry := m.getGPUTelemetry(); telemetry != nil {
+		gpuStats["devices"] = telemetry.Snapshot()
+		gpuStats["telemetry"] = telemetry.Status()
+	}
+
 	return map[string]interface{}{
 		"uptime_seconds": uptime,
 		"requests": map[string]interface{}{
@@ -393,12 +506,7 @@ func (m *Metrics) ToJSON() map[string]interface{} {
 				"avg_ms": reqAvg,
 			},
 		},
-		"gpu": map[string]interface{}{
-			"active_instances": atomic.LoadInt64(&m.activeGPUInstances),
-			"total_cost_usd":   m.totalGPUCost,
-			"requests_total":   atomic.LoadInt64(&m.gpuRequestsTotal),
-			"requests_failed":  atomic.LoadInt64(&m.gpuRequestsFailed),
-		},
+		"gpu": gpuStats,
 		"database": map[string]interface{}{
 			"connections_active": atomic.LoadInt32(&m.dbConnectionsActive),
 			"connections_idle":   atomic.LoadInt32(&m.dbConnectionsIdle),
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/aiserve/gpuproxy/internal/gpu"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTelemetry struct {
+	stats  []gpu.DeviceStats
+	status gpu.TelemetryStatus
+}
+
+func (f *fakeTelemetry) Snapshot() []gpu.DeviceStats { return f.stats }
+func (f *fakeTelemetry) Status() gpu.TelemetryStatus { return f.status }
+
+func newTestMetrics() *Metrics {
+	return &Metrics{
+		requestDurationHist: NewHistogram(),
+		dbQueryDuration:     NewHistogram(),
+	}
+}
+
+func TestGPUDevicesPrometheus(t *testing.T) {
+	m := newTestMetrics()
+	assert.NotContains(t, m.ToPrometheus(), "gpuproxy_gpu_devices", "nothing is exported without a collector")
+
+	temp, power, ecc := 64.0, 312.45, int64(3)
+	m.SetGPUTelemetry(&fakeTelemetry{stats: []gpu.DeviceStats{
+		{
+			Device:         gpu.Device{Backend: gpu.BackendCUDA, Index: 0, UUID: "GPU-0", Name: "NVIDIA A100-SXM4-80GB"},
+			UtilizationPct: 87,
+			MemoryUsedMB:   61440,
+			MemoryTotalMB:  81920,
+			TemperatureC:   &temp,
+			PowerW:         &power,
+			ECCUncorrected: &ecc,
+		},
+		{
+			Device:         gpu.Device{Backend: gpu.BackendROCm, Index: 1, UUID: "0x2e7a", Name: `Card "B"`},
+			UtilizationPct: 12.5,
+		},
+	}})
+	out := m.ToPrometheus()
+
+	assert.Contains(t, out, "gpuproxy_gpu_devices 2\n")
+	assert.Contains(t, out, "# TYPE gpuproxy_gpu_device_utilization_percent gauge\n")
+	assert.Contains(t, out, `gpuproxy_gpu_device_utilization_percent{backend="cuda",index="0",uuid="GPU-0",name="NVIDIA A100-SXM4-80GB"} 87`+"\n")
+	assert.Contains(t, out, `gpuproxy_gpu_device_utilization_percent{backend="rocm",index="1",uuid="0x2e7a",name="Card \"B\""} 12.5`+"\n")
+	assert.Contains(t, out, `gpuproxy_gpu_device_memory_used_mb{backend="cuda",index="0",uuid="GPU-0",name="NVIDIA A100-SXM4-80GB"} 61440`+"\n")
+	assert.Contains(t, out, `gpuproxy_gpu_device_power_watts{backend="cuda",index="0",uuid="GPU-0",name="NVIDIA A100-SXM4-80GB"} 312.45`+"\n")
+	assert.Contains(t, out, "# TYPE gpuproxy_gpu_device_ecc_uncorrected_errors_total counter\n")
+	assert.NotContains(t, out, `gpuproxy_gpu_device_temperature_celsius{backend="rocm"`, "unreported readings are left out")
+	assert.NotContains(t, out, "gpuproxy_gpu_device_ecc_corrected_errors_total", "no device reports it")
+}
+
+func TestGPUDevicesJSON(t *testing.T) {
+	m := newTestMetrics()
+	telemetry := &fakeTelemetry{
+		stats:  []gpu.DeviceStats{{Device: gpu.Device{Backend: gpu.BackendCUDA}}},
+		status: gpu.TelemetryStatus{Devices: 1},
+	}
+	m.SetGPUTelemetry(telemetry)
+
+	stats := m.ToJSON()["gpu"].(map[string]interface{})
+	assert.Equal(t, telemetry.stats, stats["devices"])
+	assert.Equal(t, telemetry.status, stats["telemetry"])
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/aiserve/gpuproxy/internal/auth"
 	"github.com/aiserve/gpuproxy/internal/models"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 )
 
 type contextKey string
@@ -32,6 +33,14 @@ func NewAuthMiddleware(authService *auth.Service, jwtSecret string) *AuthMiddlew
 func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		apiKey := r.Header.Get("X-API-Key")
+		authHeader := r.Header.Get("Authorization")
+		if apiKey == "" && authHeader == "" && websocket.IsWebSocketUpgrade(r) {
+			// Browsers cannot set headers on WebSocket handshakes
+			apiKey = r.URL.Query().Get("api_key")
+			if token := r.URL.Query().Get("token"); token != "" {
+				authHeader = "Bearer " + token
+			}
+		}
 		if apiKey != "" {
 			user, err := m.authService.ValidateAPIKey(r.Context(), apiKey)
 			if err != nil {
@@ -44,7 +53,6 @@ func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "Missing authorization"})
 			return
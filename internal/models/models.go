@@ -109,4 +109,5 @@ type GPUInstance struct {
 	Location         string            `json:"location"`
 	Available        bool              `json:"available"`
 	Specifications   map[string]interface{} `json:"specifications,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"` // Matched by load balancer pool selectors
 }
@@ -44,6 +44,8 @@ type ServedModel struct {
 	GPURequired  bool                   `json:"gpu_required"`
 	GPUType      string                 `json:"gpu_type"`      // Preferred GPU (H100, A100, etc.)
 	MinVRAM      int                    `json:"min_vram"`      // Minimum VRAM in GB
+	DeviceAllocation string             `json:"device_allocation,omitempty"` // Local GPUs the model was given
+	Environment  map[string]string      `json:"environment,omitempty"` // e.g. CUDA_VISIBLE_DEVICES for the runtime
 	Metadata     map[string]interface{} `json:"metadata"`
 	Endpoint     string                 `json:"endpoint"`      // /serve/models/{id}/predict
 	Status       string                 `json:"status"`        // loading, ready, error
@@ -65,6 +67,15 @@ type ModelRegistry struct {
 	userModels  map[string][]string      // user_id -> []model_id
 	endpoints   map[string]string        // endpoint -> model_id
 	storageRoot string                   // Root directory for model storage
+	devices     DeviceAllocator          // Local GPUs for models that require one
+}
+
+// DeviceAllocator hands local GPUs to served models
+type DeviceAllocator interface {
+	// AllocateModelDevices gives a model one GPU, or a vramGB slice of one
+	// shared with other models when vramGB is set
+	AllocateModelDevices(modelID string, vramGB int) (id string, env map[string]string, err error)
+	Release(id string) error
 }
 
 var globalRegistry *ModelRegistry
@@ -100,6 +111,13 @@ func (r *ModelRegistry) GetStorageRoot() string {
 	return r.storageRoot
 }
 
+// SetDeviceAllocator sets where models that require a GPU get one
+func (r *ModelRegistry) SetDeviceAllocator(devices DeviceAllocator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.devices = devices
+}
+
 // RegisterModel registers a new model for serving
 func (r *ModelRegistry) RegisterModel(model *ServedModel) error {
 	r.mu.Lock()
@@ -207,6 +225,8 @@ func (r *ModelRegistry) DeleteModel(modelID, userID string) error {
 		}
 	}
 
+	r.releaseDevicesLocked(model)
+
 	// Clean up model files (optional - may want to keep for backup)
 	// os.Remove(model.FilePath)
 
@@ -275,6 +295,12 @@ func (r *ModelRegistry) loadModelWithContext(ctx context.Context, modelID string
 	runtime := determineRuntime(model.Format)
 	model.Runtime = runtime
 
+	if err := r.allocateDevices(model); err != nil {
+		fmt.Printf("Failed to allocate GPU for model %s: %v\n", modelID, err)
+		r.UpdateModelStatus(modelID, "error")
+		return
+	}
+
 	// TODO: Actual model loading logic would go here
 	// This would integrate with:
 	// - NVIDIA Triton Inference Server
@@ -292,10 +318,47 @@ func (r *ModelRegistry) loadModelWithContext(ctx context.Context, modelID string
 		// Context cancelled or timed out
 		fmt.Printf("Model loading cancelled/timed out for %s: %v\n", modelID, ctx.Err())
 		r.UpdateModelStatus(modelID, "error")
+		r.mu.Lock()
+		r.releaseDevicesLocked(model)
+		r.mu.Unlock()
 		return
 	}
 }
 
+// allocateDevices gives a model that requires a GPU its local devices. The
+// runtime is started with the returned environment so it only sees them.
+func (r *ModelRegistry) allocateDevices(model *ServedModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !model.GPURequired || r.devices == nil || model.DeviceAllocation != "" {
+		return nil
+	}
+	if r.models[model.ID] != model {
+		return fmt.Errorf("model was deleted while loading")
+	}
+
+	id, env, err := r.devices.AllocateModelDevices(model.ID, model.MinVRAM)
+	if err != nil {
+		return err
+	}
+	model.DeviceAllocation = id
+	model.Environment = env
+	return nil
+}
+
+// releaseDevicesLocked returns a model's devices. Callers hold r.mu.
+func (r *ModelRegistry) releaseDevicesLocked(model *ServedModel) {
+	if model.DeviceAllocation == "" || r.devices == nil {
+		return
+	}
+	if err := r.devices.Release(model.DeviceAllocation); err != nil {
+		fmt.Printf("Failed to release GPU of model %s: %v\n", model.ID, err)
+	}
+	model.DeviceAllocation = ""
+	model.Environment = nil
+}
+
 // isValidFormat checks if a model format is supported
 func isValidFormat(format ModelFormat) bool {
 	validFormats := []ModelFormat{
@@ -0,0 +1,434 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+)
+
+const (
+	// anthropicVersion is the Messages API version sent with every request
+	anthropicVersion = "2023-06-01"
+
+	// anthropicDefaultMaxTokens is used when the request does not set max_tokens,
+	// which the Messages API requires
+	anthropicDefaultMaxTokens = 1024
+)
+
+// AnthropicProvider implements the Provider interface for the Anthropic Messages API
+type AnthropicProvider struct {
+	config     *config.AnthropicProviderConfig
+	httpClient *http.Client
+	baseURL    string
+	health     healthCache
+}
+
+// AnthropicRequest represents a request to the Anthropic Messages API
+type AnthropicRequest struct {
+	Model         string    `json:"model"`
+	System        string    `json:"system,omitempty"`
+	Messages      []Message `json:"messages"`
+	MaxTokens     int       `json:"max_tokens"`
+	Temperature   float64   `json:"temperature,omitempty"`
+	TopP          float64   `json:"top_p,omitempty"`
+	StopSequences []string  `json:"stop_sequences,omitempty"`
+	Stream        bool      `json:"stream,omitempty"`
+}
+
+// AnthropicStreamEvent represents a server-sent event of a streamed Messages API response
+type AnthropicStreamEvent struct {
+	Type    string             `json:"type"`
+	Message *AnthropicResponse `json:"message,omitempty"` // message_start
+	Delta   *struct {
+		Type       string `json:"type"`
+		Text       string `json:"text,omitempty"`
+		StopReason string `json:"stop_reason,omitempty"`
+	} `json:"delta,omitempty"` // content_block_delta, message_delta
+	Usage *AnthropicUsage `json:"usage,omitempty"` // message_delta
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// AnthropicResponse represents a response from the Anthropic Messages API
+type AnthropicResponse struct {
+	ID         string                  `json:"id"`
+	Model      string                  `json:"model"`
+	Content    []AnthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      *AnthropicUsage         `json:"usage,omitempty"`
+}
+
+// AnthropicContentBlock represents a content block in a Messages API response
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+// AnthropicUsage represents token usage information
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// AnthropicErrorResponse represents an error returned by the Anthropic API
+type AnthropicErrorResponse struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewAnthropicProvider creates a new Anthropic provider
+func NewAnthropicProvider(cfg *config.AnthropicProviderConfig) (*AnthropicProvider, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("anthropic config is required")
+	}
+
+	if cfg.Credentials.APIKey == "" {
+		return nil, fmt.Errorf("anthropic API key is required")
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.anthropic.com/v1"
+	}
+
+	return &AnthropicProvider{
+		config: cfg,
+		httpClient: &http.Client{
+			Timeout: 120 * time.Second,
+		},
+		baseURL: strings.TrimRight(endpoint, "/"),
+	}, nil
+}
+
+// Name returns the provider name
+func (p *AnthropicProvider) Name() string {
+	return "anthropic"
+}
+
+// Type returns the provider type
+func (p *AnthropicProvider) Type() string {
+	return "anthropic"
+}
+
+// IsAvailable reports the availability seen by the last health probe
+func (p *AnthropicProvider) IsAvailable(ctx context.Context) bool {
+	return p.health.available()
+}
+
+// GetModels returns the list of available models
+func (p *AnthropicProvider) GetModels() []string {
+	models := make([]string, 0, len(p.config.Models))
+	for _, model := range p.config.Models {
+		models = append(models, model.Name)
+	}
+	return models
+}
+
+// Predict performs inference with a model
+func (p *AnthropicProvider) Predict(ctx context.Context, req *PredictRequest) (*PredictResponse, error) {
+	modelConfig := findModel(p.config.Models, req.Model)
+	if modelConfig == nil {
+		return nil, fmt.Errorf("model %s not found in anthropic provider configuration", req.Model)
+	}
+
+	if hasCapability(modelConfig.Capabilities, CapabilityEmbeddings) {
+		return nil, fmt.Errorf("anthropic does not support embeddings (model %s)", req.Model)
+	}
+
+	anReq, err := p.messagesRequest(req, modelConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	anResp, err := p.makeRequest(ctx, anReq)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic API request failed: %w", err)
+	}
+	latency := time.Since(startTime)
+
+	if len(anResp.Content) == 0 {
+		return nil, fmt.Errorf("no output in anthropic response")
+	}
+
+	var output strings.Builder
+	for _, block := range anResp.Content {
+		if block.Type == "text" {
+			output.WriteString(block.Text)
+		}
+	}
+
+	var inputTokens, outputTokens int
+	if anResp.Usage != nil {
+		inputTokens = anResp.Usage.InputTokens
+		outputTokens = anResp.Usage.OutputTokens
+	} else {
+		inputTokens = estimateTokens(req.Input)
+		outputTokens = estimateTokens(output.String())
+	}
+
+	totalTokens := inputTokens + outputTokens
+
+	return &PredictResponse{
+		Output: output.String(),
+		Metadata: ResponseMetadata{
+			Provider:     p.Name(),
+			Model:        req.Model,
+			LatencyMs:    int(latency.Milliseconds()),
+			InputTokens:  inputTokens,
+			OutputTokens: outputTokens,
+			TotalTokens:  totalTokens,
+			Cost:         float64(totalTokens) / 1000.0 * p.GetCostPer1kTokens(req.Model),
+			Currency:     "USD",
+			FinishReason: anResp.StopReason,
+		},
+	}, nil
+}
+
+// PredictStream performs a streamed Messages API request
+func (p *AnthropicProvider) PredictStream(ctx context.Context, req *PredictRequest) (<-chan StreamChunk, error) {
+	modelConfig := findModel(p.config.Models, req.Model)
+	if modelConfig == nil {
+		return nil, fmt.Errorf("model %s not found in anthropic provider configuration", req.Model)
+	}
+
+	if hasCapability(modelConfig.Capabilities, CapabilityEmbeddings) {
+		return nil, fmt.Errorf("anthropic does not support embeddings (model %s)", req.Model)
+	}
+
+	anReq, err := p.messagesRequest(req, modelConfig)
+	if err != nil {
+		return nil, err
+	}
+	anReq.Stream = true
+
+	startTime := time.Now()
+	resp, err := p.post(ctx, anReq)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic API request failed: %w", err)
+	}
+
+	sender, ch := newStreamSender(ctx)
+	go func() {
+		defer sender.close()
+		defer resp.Body.Close()
+
+		var output strings.Builder
+		var inputTokens, outputTokens int
+		haveUsage := false
+		stopReason := ""
+
+		err := readSSE(resp.Body, func(event, data string) error {
+			var ev AnthropicStreamEvent
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				return fmt.Errorf("failed to parse stream event: %w", err)
+			}
+
+			switch ev.Type {
+			case "message_start":
+				if ev.Message != nil && ev.Message.Usage != nil {
+					inputTokens = ev.Message.Usage.InputTokens
+					outputTokens = ev.Message.Usage.OutputTokens
+					haveUsage = true
+				}
+			case "content_block_delta":
+				if ev.Delta == nil || ev.Delta.Type != "text_delta" || ev.Delta.Text == "" {
+					return nil
+				}
+				output.WriteString(ev.Delta.Text)
+				if !sender.send(StreamChunk{Delta: ev.Delta.Text}) {
+					return ctx.Err()
+				}
+			case "message_delta":
+				if ev.Delta != nil && ev.Delta.StopReason != "" {
+					stopReason = ev.Delta.StopReason
+				}
+				if ev.Usage != nil {
+					outputTokens = ev.Usage.OutputTokens
+					haveUsage = true
+				}
+			case "message_stop":
+				return errStreamDone
+			case "error":
+				if ev.Error != nil {
+					return fmt.Errorf("upstream error: %s", ev.Error.Message)
+				}
+				return fmt.Errorf("upstream error")
+			}
+			return nil
+		})
+		if err != nil {
+			sender.fail(fmt.Errorf("anthropic stream failed: %w", err))
+			return
+		}
+
+		if !haveUsage {
+			inputTokens = estimateTokens(req.Input)
+			outputTokens = estimateTokens(output.String())
+		}
+		totalTokens := inputTokens + outputTokens
+
+		sender.send(StreamChunk{
+			Done:         true,
+			FinishReason: stopReason,
+			Metadata: &ResponseMetadata{
+				Provider:     p.Name(),
+				Model:        req.Model,
+				LatencyMs:    int(time.Since(startTime).Milliseconds()),
+				InputTokens:  inputTokens,
+				OutputTokens: outputTokens,
+				TotalTokens:  totalTokens,
+				Cost:         float64(totalTokens) / 1000.0 * p.GetCostPer1kTokens(req.Model),
+				Currency:     "USD",
+			},
+		})
+	}()
+
+	return ch, nil
+}
+
+// messagesRequest builds a Messages API request for a configured model
+func (p *AnthropicProvider) messagesRequest(req *PredictRequest, modelConfig *config.ModelConfig) (AnthropicRequest, error) {
+	messages, err := chatMessages(req.Input)
+	if err != nil {
+		return AnthropicRequest{}, err
+	}
+
+	anReq := AnthropicRequest{
+		Model:         p.upstreamModel(modelConfig),
+		MaxTokens:     req.MaxTokens,
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		StopSequences: req.Stop,
+	}
+	if anReq.MaxTokens <= 0 {
+		anReq.MaxTokens = anthropicDefaultMaxTokens
+	}
+
+	// The Messages API takes system prompts as a top-level field rather than a message role
+	var system []string
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			system = append(system, msg.Content)
+			continue
+		}
+		anReq.Messages = append(anReq.Messages, msg)
+	}
+	anReq.System = strings.Join(system, "\n\n")
+
+	if len(anReq.Messages) == 0 {
+		return AnthropicRequest{}, fmt.Errorf("at least one non-system message is required")
+	}
+
+	return anReq, nil
+}
+
+// upstreamModel returns the Anthropic model ID for a configured model
+func (p *AnthropicProvider) upstreamModel(modelConfig *config.ModelConfig) string {
+	if modelConfig.AnthropicModel != "" {
+		return modelConfig.AnthropicModel
+	}
+	return modelConfig.Name
+}
+
+// setHeaders sets the authentication and versioning headers
+func (p *AnthropicProvider) setHeaders(req *http.Request) {
+	req.Header.Set("x-api-key", p.config.Credentials.APIKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+}
+
+// makeRequest makes an HTTP request to the Anthropic Messages API
+func (p *AnthropicProvider) makeRequest(ctx context.Context, req AnthropicRequest) (*AnthropicResponse, error) {
+	resp, err := p.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var anResp AnthropicResponse
+	if err := json.Unmarshal(respBody, &anResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &anResp, nil
+}
+
+// post sends a request to the Messages API. Non-200 responses are returned as
+// errors; on success the caller owns the response body.
+func (p *AnthropicProvider) post(ctx context.Context, req AnthropicRequest) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+
+		var errResp AnthropicErrorResponse
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error.Message != "" {
+			return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, errResp.Error.Message)
+		}
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return resp, nil
+}
+
+// Health probes the Anthropic models endpoint and caches the result for IsAvailable
+func (p *AnthropicProvider) Health(ctx context.Context) ProviderHealth {
+	req, err := http.NewRequest("GET", p.baseURL+"/models", nil)
+	if err != nil {
+		health := ProviderHealth{Provider: p.Name(), Message: err.Error()}
+		p.health.store(health)
+		return health
+	}
+	p.setHeaders(req)
+
+	health := probeHealth(ctx, p.httpClient, p.Name(), req)
+	p.health.store(health)
+	return health
+}
+
+// Priority returns the provider priority
+func (p *AnthropicProvider) Priority() int {
+	return p.config.Priority
+}
+
+// GetCostPer1kTokens returns the cost per 1k tokens for a model
+func (p *AnthropicProvider) GetCostPer1kTokens(model string) float64 {
+	if m := findModel(p.config.Models, model); m != nil {
+		return m.CostPer1kTokens
+	}
+	return 0.0
+}
@@ -0,0 +1,185 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAnthropicProvider(t *testing.T, handler http.HandlerFunc) *AnthropicProvider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider, err := NewAnthropicProvider(&config.AnthropicProviderConfig{
+		Enabled:     true,
+		Priority:    4,
+		Credentials: config.AnthropicCredentials{APIKey: "ak-test"},
+		Endpoint:    server.URL + "/v1",
+		Models: []config.ModelConfig{
+			{Name: "claude", AnthropicModel: "claude-3-5-haiku-latest", Capabilities: []string{CapabilityTextGeneration}, CostPer1kTokens: 2},
+		},
+	})
+	require.NoError(t, err)
+	return provider
+}
+
+func TestAnthropicProvider_PredictChat(t *testing.T) {
+	var got AnthropicRequest
+	provider := newTestAnthropicProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "ak-test", r.Header.Get("x-api-key"))
+		assert.Equal(t, anthropicVersion, r.Header.Get("anthropic-version"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":          "msg_1",
+			"content":     []map[string]string{{"type": "text", "text": "Hello, "}, {"type": "text", "text": "world"}},
+			"stop_reason": "stop_sequence",
+			"usage":       map[string]int{"input_tokens": 300, "output_tokens": 200},
+		})
+	})
+
+	resp, err := provider.Predict(context.Background(), &PredictRequest{
+		Model: "claude",
+		Input: []interface{}{
+			map[string]interface{}{"role": "system", "content": "Be brief"},
+			map[string]interface{}{"role": "user", "content": "Hello"},
+		},
+		Stop: []string{"END"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "claude-3-5-haiku-latest", got.Model)
+	assert.Equal(t, "Be brief", got.System)
+	assert.Equal(t, []Message{{Role: "user", Content: "Hello"}}, got.Messages)
+	assert.Equal(t, anthropicDefaultMaxTokens, got.MaxTokens)
+	assert.Equal(t, []string{"END"}, got.StopSequences)
+
+	assert.Equal(t, "Hello, world", resp.Output)
+	assert.Equal(t, "anthropic", resp.Metadata.Provider)
+	assert.Equal(t, 300, resp.Metadata.InputTokens)
+	assert.Equal(t, 200, resp.Metadata.OutputTokens)
+	assert.Equal(t, 500, resp.Metadata.TotalTokens)
+	assert.InDelta(t, 1.0, resp.Metadata.Cost, 1e-9)
+	assert.Equal(t, "stop_sequence", resp.Metadata.FinishReason)
+}
+
+func TestAnthropicProvider_PredictPrompt(t *testing.T) {
+	var got AnthropicRequest
+	provider := newTestAnthropicProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"content": []map[string]string{{"type": "text", "text": "ok"}},
+		})
+	})
+
+	resp, err := provider.Predict(context.Background(), &PredictRequest{Model: "claude", Input: "Say ok", MaxTokens: 10})
+	require.NoError(t, err)
+
+	assert.Equal(t, []Message{{Role: "user", Content: "Say ok"}}, got.Messages)
+	assert.Equal(t, 10, got.MaxTokens)
+	assert.Empty(t, got.System)
+	assert.Equal(t, "ok", resp.Output)
+}
+
+func TestAnthropicProvider_PredictAPIError(t *testing.T) {
+	provider := newTestAnthropicProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(529)
+		w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+	})
+
+	_, err := provider.Predict(context.Background(), &PredictRequest{Model: "claude", Input: "Hello"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 529")
+	assert.Contains(t, err.Error(), "Overloaded")
+
+	_, err = provider.Predict(context.Background(), &PredictRequest{
+		Model: "claude",
+		Input: []interface{}{map[string]interface{}{"role": "system", "content": "only a system prompt"}},
+	})
+	assert.Error(t, err)
+}
+
+func TestAnthropicProvider_Health(t *testing.T) {
+	up := true
+	provider := newTestAnthropicProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path)
+		assert.Equal(t, "ak-test", r.Header.Get("x-api-key"))
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	})
+	ctx := context.Background()
+
+	health := provider.Health(ctx)
+	assert.True(t, health.Healthy)
+	assert.Equal(t, "anthropic", health.Provider)
+	assert.True(t, provider.IsAvailable(ctx))
+
+	up = false
+	health = provider.Health(ctx)
+	assert.False(t, health.Healthy)
+	assert.Contains(t, health.Message, "status 503")
+	assert.False(t, provider.IsAvailable(ctx))
+}
+
+func TestAnthropicProvider_PredictStream(t *testing.T) {
+	provider := newTestAnthropicProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var req AnthropicRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.True(t, req.Stream)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"content\":[],\"usage\":{\"input_tokens\":300,\"output_tokens\":1}}}\n\n"))
+		w.Write([]byte("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n"))
+		w.Write([]byte("event: ping\ndata: {\"type\":\"ping\"}\n\n"))
+		w.Write([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n"))
+		w.Write([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" world\"}}\n\n"))
+		w.Write([]byte("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n"))
+		w.Write([]byte("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":200}}\n\n"))
+		w.Write([]byte("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+	})
+
+	stream, err := provider.PredictStream(context.Background(), &PredictRequest{Model: "claude", Input: "Hi"})
+	require.NoError(t, err)
+
+	var chunks []StreamChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+
+	require.Len(t, chunks, 3)
+	assert.Equal(t, "Hello", chunks[0].Delta)
+	assert.Equal(t, " world", chunks[1].Delta)
+
+	last := chunks[2]
+	assert.True(t, last.Done)
+	assert.NoError(t, last.Err)
+	assert.Equal(t, "end_turn", last.FinishReason)
+	require.NotNil(t, last.Metadata)
+	assert.Equal(t, 300, last.Metadata.InputTokens)
+	assert.Equal(t, 200, last.Metadata.OutputTokens)
+	assert.InDelta(t, 1.0, last.Metadata.Cost, 1e-9)
+}
+
+func TestAnthropicProvider_PredictStreamError(t *testing.T) {
+	provider := newTestAnthropicProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"))
+	})
+
+	stream, err := provider.PredictStream(context.Background(), &PredictRequest{Model: "claude", Input: "Hi"})
+	require.NoError(t, err)
+
+	first := <-stream
+	assert.True(t, first.Done)
+	require.Error(t, first.Err)
+	assert.Contains(t, first.Err.Error(), "Overloaded")
+}
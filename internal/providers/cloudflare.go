@@ -7,9 +7,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
-	"aiserve-gpuproxyd/internal/config"
+	"github.com/aiserve/gpuproxy/internal/config"
 )
 
 // CloudflareProvider implements the Provider interface for Cloudflare Workers AI
@@ -23,6 +24,7 @@ type CloudflareProvider struct {
 type CloudflareRequest struct {
 	Prompt      string                 `json:"prompt,omitempty"`
 	Messages    []CloudflareMessage    `json:"messages,omitempty"`
+	Text        interface{}            `json:"text,omitempty"` // Embedding input: string or []string
 	MaxTokens   int                    `json:"max_tokens,omitempty"`
 	Temperature float64                `json:"temperature,omitempty"`
 	Stream      bool                   `json:"stream,omitempty"`
@@ -55,6 +57,7 @@ type CloudflareResult struct {
 	Response string                 `json:"response,omitempty"`
 	Text     string                 `json:"text,omitempty"`
 	Choices  []CloudflareChoice     `json:"choices,omitempty"`
+	Data     [][]float64            `json:"data,omitempty"` // Embedding vectors
 	Usage    *CloudflareUsage       `json:"usage,omitempty"`
 	Extra    map[string]interface{} `json:"-"`
 }
@@ -126,42 +129,19 @@ func (p *CloudflareProvider) GetModels() []string {
 // Predict performs inference with a model
 func (p *CloudflareProvider) Predict(ctx context.Context, req *PredictRequest) (*PredictResponse, error) {
 	// Find the model configuration
-	var modelConfig *config.CloudflareModelConfig
-	for i, m := range p.config.Models {
-		if m.Name == req.Model {
-			modelConfig = &p.config.Models[i]
-			break
-		}
-	}
-
+	modelConfig := p.findModel(req.Model)
 	if modelConfig == nil {
 		return nil, fmt.Errorf("model %s not found in cloudflare provider configuration", req.Model)
 	}
 
-	// Build the request
-	cfReq := CloudflareRequest{
-		MaxTokens:   req.MaxTokens,
-		Temperature: req.Temperature,
-		Stream:      false,
+	if hasCapability(modelConfig.Capabilities, CapabilityEmbeddings) {
+		return p.embed(ctx, req, modelConfig)
 	}
 
-	// Convert input format
-	if messages, ok := req.Input.([]interface{}); ok {
-		// Chat completion format
-		cfReq.Messages = make([]CloudflareMessage, 0, len(messages))
-		for _, msg := range messages {
-			if m, ok := msg.(map[string]interface{}); ok {
-				cfReq.Messages = append(cfReq.Messages, CloudflareMessage{
-					Role:    fmt.Sprintf("%v", m["role"]),
-					Content: fmt.Sprintf("%v", m["content"]),
-				})
-			}
-		}
-	} else if prompt, ok := req.Input.(string); ok {
-		// Simple prompt format
-		cfReq.Prompt = prompt
-	} else {
-		return nil, fmt.Errorf("unsupported input format")
+	// Build the request
+	cfReq, err := p.textRequest(req)
+	if err != nil {
+		return nil, err
 	}
 
 	// Make the API call
@@ -215,28 +195,195 @@ func (p *CloudflareProvider) Predict(ctx context.Context, req *PredictRequest) (
 	}, nil
 }
 
-// makeRequest makes an HTTP request to Cloudflare API
-func (p *CloudflareProvider) makeRequest(ctx context.Context, url string, req CloudflareRequest) (*CloudflareResponse, error) {
-	// Marshal request body
-	body, err := json.Marshal(req)
+// PredictStream performs a streamed text generation request
+func (p *CloudflareProvider) PredictStream(ctx context.Context, req *PredictRequest) (<-chan StreamChunk, error) {
+	modelConfig := p.findModel(req.Model)
+	if modelConfig == nil {
+		return nil, fmt.Errorf("model %s not found in cloudflare provider configuration", req.Model)
+	}
+
+	if hasCapability(modelConfig.Capabilities, CapabilityEmbeddings) {
+		return nil, fmt.Errorf("streaming is not supported for embedding model %s", req.Model)
+	}
+
+	cfReq, err := p.textRequest(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, err
 	}
+	cfReq.Stream = true
 
-	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	url := fmt.Sprintf("%s/%s", p.baseURL, modelConfig.CloudflareModel)
+
+	startTime := time.Now()
+	resp, err := p.post(ctx, url, cfReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("cloudflare API request failed: %w", err)
 	}
 
-	// Set headers
-	httpReq.Header.Set("Authorization", "Bearer "+p.config.Credentials.APIToken)
-	httpReq.Header.Set("Content-Type", "application/json")
+	sender, ch := newStreamSender(ctx)
+	go func() {
+		defer sender.close()
+		defer resp.Body.Close()
 
-	// Make request
-	resp, err := p.httpClient.Do(httpReq)
+		var output strings.Builder
+		var usage *CloudflareUsage
+
+		err := readSSE(resp.Body, func(event, data string) error {
+			if data == "[DONE]" {
+				return errStreamDone
+			}
+
+			var chunk CloudflareResult
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return fmt.Errorf("failed to parse stream chunk: %w", err)
+			}
+			if chunk.Usage != nil {
+				usage = chunk.Usage
+			}
+			if chunk.Response == "" {
+				return nil
+			}
+			output.WriteString(chunk.Response)
+			if !sender.send(StreamChunk{Delta: chunk.Response}) {
+				return ctx.Err()
+			}
+			return nil
+		})
+		if err != nil {
+			sender.fail(fmt.Errorf("cloudflare stream failed: %w", err))
+			return
+		}
+
+		var inputTokens, outputTokens int
+		if usage != nil {
+			inputTokens = usage.PromptTokens
+			outputTokens = usage.CompletionTokens
+		} else {
+			inputTokens = estimateTokens(req.Input)
+			outputTokens = estimateTokens(output.String())
+		}
+		totalTokens := inputTokens + outputTokens
+
+		sender.send(StreamChunk{
+			Done:         true,
+			FinishReason: "stop",
+			Metadata: &ResponseMetadata{
+				Provider:     p.Name(),
+				Model:        req.Model,
+				LatencyMs:    int(time.Since(startTime).Milliseconds()),
+				InputTokens:  inputTokens,
+				OutputTokens: outputTokens,
+				TotalTokens:  totalTokens,
+				Cost:         float64(totalTokens) / 1000.0 * modelConfig.CostPer1kTokens,
+				Currency:     "USD",
+			},
+		})
+	}()
+
+	return ch, nil
+}
+
+// textRequest converts a prediction request into a Workers AI text generation request
+func (p *CloudflareProvider) textRequest(req *PredictRequest) (CloudflareRequest, error) {
+	cfReq := CloudflareRequest{
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Stream:      false,
+	}
+
+	// Convert input format
+	if messages, ok := req.Input.([]interface{}); ok {
+		// Chat completion format
+		cfReq.Messages = make([]CloudflareMessage, 0, len(messages))
+		for _, msg := range messages {
+			if m, ok := msg.(map[string]interface{}); ok {
+				cfReq.Messages = append(cfReq.Messages, CloudflareMessage{
+					Role:    fmt.Sprintf("%v", m["role"]),
+					Content: fmt.Sprintf("%v", m["content"]),
+				})
+			}
+		}
+	} else if prompt, ok := req.Input.(string); ok {
+		// Simple prompt format
+		cfReq.Prompt = prompt
+	} else {
+		return CloudflareRequest{}, fmt.Errorf("unsupported input format")
+	}
+
+	return cfReq, nil
+}
+
+// findModel returns the Cloudflare model configuration for a model name
+func (p *CloudflareProvider) findModel(name string) *config.CloudflareModelConfig {
+	for i, m := range p.config.Models {
+		if m.Name == name {
+			return &p.config.Models[i]
+		}
+	}
+	return nil
+}
+
+// embed generates embeddings with a Cloudflare embedding model
+func (p *CloudflareProvider) embed(ctx context.Context, req *PredictRequest, modelConfig *config.CloudflareModelConfig) (*PredictResponse, error) {
+	cfReq := CloudflareRequest{}
+
+	switch input := req.Input.(type) {
+	case string:
+		cfReq.Text = input
+	case []interface{}:
+		texts := make([]string, 0, len(input))
+		for _, item := range input {
+			text, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("embedding input must be a string or a list of strings")
+			}
+			texts = append(texts, text)
+		}
+		cfReq.Text = texts
+	default:
+		return nil, fmt.Errorf("unsupported input format")
+	}
+
+	url := fmt.Sprintf("%s/%s", p.baseURL, modelConfig.CloudflareModel)
+
+	startTime := time.Now()
+	response, err := p.makeRequest(ctx, url, cfReq)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("cloudflare API request failed: %w", err)
+	}
+	latency := time.Since(startTime)
+
+	if len(response.Result.Data) == 0 {
+		return nil, fmt.Errorf("no embeddings in cloudflare response")
+	}
+
+	inputTokens := estimateTokens(req.Input)
+	if texts, ok := cfReq.Text.([]string); ok {
+		inputTokens = 0
+		for _, text := range texts {
+			inputTokens += estimateTokens(text)
+		}
+	}
+
+	return &PredictResponse{
+		Output: response.Result.Data,
+		Metadata: ResponseMetadata{
+			Provider:    p.Name(),
+			Model:       req.Model,
+			LatencyMs:   int(latency.Milliseconds()),
+			InputTokens: inputTokens,
+			TotalTokens: inputTokens,
+			Cost:        float64(inputTokens) / 1000.0 * modelConfig.CostPer1kTokens,
+			Currency:    "USD",
+		},
+	}, nil
+}
+
+// makeRequest makes an HTTP request to Cloudflare API
+func (p *CloudflareProvider) makeRequest(ctx context.Context, url string, req CloudflareRequest) (*CloudflareResponse, error) {
+	resp, err := p.post(ctx, url, req)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -246,11 +393,6 @@ func (p *CloudflareProvider) makeRequest(ctx context.Context, url string, req Cl
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
-	}
-
 	// Parse response
 	var cfResp CloudflareResponse
 	if err := json.Unmarshal(respBody, &cfResp); err != nil {
@@ -268,6 +410,41 @@ func (p *CloudflareProvider) makeRequest(ctx context.Context, url string, req Cl
 	return &cfResp, nil
 }
 
+// post sends a request to the Cloudflare API. Non-200 responses are returned as
+// errors; on success the caller owns the response body.
+func (p *CloudflareProvider) post(ctx context.Context, url string, req CloudflareRequest) (*http.Response, error) {
+	// Marshal request body
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	httpReq.Header.Set("Authorization", "Bearer "+p.config.Credentials.APIToken)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	// Make request
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return resp, nil
+}
+
 // Health returns the provider health status
 func (p *CloudflareProvider) Health(ctx context.Context) ProviderHealth {
 	health := ProviderHealth{
@@ -0,0 +1,68 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthCache remembers the result of the last health probe so that
+// IsAvailable stays cheap enough to be called on every routing decision
+type healthCache struct {
+	mu   sync.RWMutex
+	last *ProviderHealth
+}
+
+// available reports the availability seen by the last probe. Providers that
+// have never been probed are assumed to be available.
+func (c *healthCache) available() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.last == nil {
+		return true
+	}
+	return c.last.Available
+}
+
+// store records the result of a health probe
+func (c *healthCache) store(health ProviderHealth) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.last = &health
+}
+
+// probeHealth issues a GET request and converts the outcome into a ProviderHealth.
+// Any 2xx response is healthy; 401/403 mean the API is reachable but the
+// credentials are rejected, which makes the provider unusable.
+func probeHealth(ctx context.Context, client *http.Client, name string, req *http.Request) ProviderHealth {
+	health := ProviderHealth{
+		Provider: name,
+	}
+
+	startTime := time.Now()
+	resp, err := client.Do(req.WithContext(ctx))
+	health.Latency = int(time.Since(startTime).Milliseconds())
+	if err != nil {
+		health.Message = fmt.Sprintf("health probe failed: %v", err)
+		return health
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		health.Healthy = true
+		health.Available = true
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		health.Message = fmt.Sprintf("credentials rejected (status %d)", resp.StatusCode)
+	default:
+		health.Message = fmt.Sprintf("health probe returned status %d", resp.StatusCode)
+	}
+
+	return health
+}
@@ -0,0 +1,274 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/models"
+)
+
+// ErrLocalSaturated is returned by the local provider when MaxConcurrency
+// predictions are already running, so the router fails over to another provider
+var ErrLocalSaturated = errors.New("local provider is at max concurrency")
+
+// ModelRuntime is the subset of ml.RuntimeOrchestrator used by the local provider
+type ModelRuntime interface {
+	LoadModel(ctx context.Context, modelID string, format models.ModelFormat, filePath string, useGPU bool) error
+	Predict(ctx context.Context, modelID string, format models.ModelFormat, input map[string]interface{}) (map[string]interface{}, error)
+	GetRuntimeForFormat(format models.ModelFormat) string
+	HealthCheck(ctx context.Context) map[string]bool
+}
+
+// LocalProvider implements the Provider interface for models served on this
+// node through the ML runtime orchestrator
+type LocalProvider struct {
+	config  *config.LocalProviderConfig
+	runtime ModelRuntime
+	health  healthCache
+	slots   chan struct{} // One per running prediction; nil when unlimited
+
+	mu     sync.RWMutex
+	loaded map[string]localModel // model name -> loaded model
+}
+
+// localModel is a configured model that was loaded into a runtime
+type localModel struct {
+	format  models.ModelFormat
+	runtime string
+}
+
+// NewLocalProvider creates a local provider and loads every configured model.
+// Models that fail to load are logged and left out of GetModels so the router
+// sends their traffic to remote providers instead.
+func NewLocalProvider(ctx context.Context, cfg *config.LocalProviderConfig, runtime ModelRuntime) (*LocalProvider, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("local config is required")
+	}
+
+	if runtime == nil {
+		return nil, fmt.Errorf("model runtime is required")
+	}
+
+	p := &LocalProvider{
+		config:  cfg,
+		runtime: runtime,
+		loaded:  make(map[string]localModel),
+	}
+	if cfg.MaxConcurrency > 0 {
+		p.slots = make(chan struct{}, cfg.MaxConcurrency)
+	}
+
+	for _, model := range cfg.Models {
+		if err := p.loadModel(ctx, model); err != nil {
+			log.Printf("Warning: local model %s not loaded: %v", model.Name, err)
+		}
+	}
+
+	p.Health(ctx)
+
+	return p, nil
+}
+
+// loadModel loads a single configured model into its runtime
+func (p *LocalProvider) loadModel(ctx context.Context, model config.ModelConfig) error {
+	if model.Path == "" {
+		return fmt.Errorf("model path is required")
+	}
+
+	format := models.ModelFormat(model.Runtime)
+	if p.runtime.GetRuntimeForFormat(format) == "" {
+		format = models.DetectModelFormat(model.Path)
+	}
+	if format == "" {
+		return fmt.Errorf("cannot determine model format for %s", model.Path)
+	}
+
+	runtimeName := p.runtime.GetRuntimeForFormat(format)
+	if !p.runtimeEnabled(runtimeName) {
+		return fmt.Errorf("runtime %s is not enabled for the local provider", runtimeName)
+	}
+
+	if err := p.runtime.LoadModel(ctx, model.Name, format, model.Path, p.config.UseGPU); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.loaded[model.Name] = localModel{
+		format:  format,
+		runtime: runtimeName,
+	}
+	p.mu.Unlock()
+
+	return nil
+}
+
+// runtimeEnabled reports whether a runtime is in the configured runtimes list.
+// An empty list enables every runtime.
+func (p *LocalProvider) runtimeEnabled(runtime string) bool {
+	if len(p.config.Runtimes) == 0 {
+		return true
+	}
+	for _, r := range p.config.Runtimes {
+		if r == runtime {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the provider name
+func (p *LocalProvider) Name() string {
+	return "local"
+}
+
+// Type returns the provider type
+func (p *LocalProvider) Type() string {
+	return "local"
+}
+
+// IsAvailable reports whether local runtimes are healthy and have spare capacity.
+// A saturated provider is unavailable so the router spills over to remote providers.
+func (p *LocalProvider) IsAvailable(ctx context.Context) bool {
+	if p.slots != nil && len(p.slots) >= cap(p.slots) {
+		return false
+	}
+	return p.health.available()
+}
+
+// acquire takes a prediction slot without waiting, returning the function
+// that gives it back
+func (p *LocalProvider) acquire() (func(), error) {
+	if p.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return func() { <-p.slots }, nil
+	default:
+		return nil, ErrLocalSaturated
+	}
+}
+
+// GetModels returns the list of loaded models
+func (p *LocalProvider) GetModels() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	names := make([]string, 0, len(p.loaded))
+	for name := range p.loaded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Predict performs inference with a locally loaded model, failing with
+// ErrLocalSaturated rather than queueing beyond MaxConcurrency
+func (p *LocalProvider) Predict(ctx context.Context, req *PredictRequest) (*PredictResponse, error) {
+	p.mu.RLock()
+	model, ok := p.loaded[req.Model]
+	p.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("model %s is not loaded in local provider", req.Model)
+	}
+
+	// Runtimes take named tensors; anything else is passed as a single "input"
+	input, ok := req.Input.(map[string]interface{})
+	if !ok {
+		input = map[string]interface{}{"input": req.Input}
+	}
+
+	release, err := p.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	startTime := time.Now()
+	result, err := p.runtime.Predict(ctx, req.Model, model.format, input)
+	if err != nil {
+		return nil, fmt.Errorf("local %s runtime inference failed: %w", model.runtime, err)
+	}
+	latency := time.Since(startTime)
+
+	return &PredictResponse{
+		Output: result,
+		Metadata: ResponseMetadata{
+			Provider:  p.Name(),
+			Model:     req.Model,
+			LatencyMs: int(latency.Milliseconds()),
+			Cost:      0,
+			Currency:  "USD",
+		},
+	}, nil
+}
+
+// PredictStream runs a buffered prediction and emits it as a single chunk,
+// since the local runtimes do not generate tokens incrementally. The
+// prediction holds a slot like Predict, and a saturated provider fails
+// before the stream starts so the router can still fail over.
+func (p *LocalProvider) PredictStream(ctx context.Context, req *PredictRequest) (<-chan StreamChunk, error) {
+	return StreamFromPredict(ctx, p, req)
+}
+
+// Health checks the runtimes backing the loaded models and caches the result for IsAvailable
+func (p *LocalProvider) Health(ctx context.Context) ProviderHealth {
+	health := ProviderHealth{
+		Provider: p.Name(),
+	}
+
+	startTime := time.Now()
+	runtimeHealth := p.runtime.HealthCheck(ctx)
+	health.Latency = int(time.Since(startTime).Milliseconds())
+
+	p.mu.RLock()
+	used := make(map[string]bool)
+	for _, model := range p.loaded {
+		used[model.runtime] = true
+	}
+	p.mu.RUnlock()
+
+	var unhealthy []string
+	for runtime := range used {
+		if !runtimeHealth[runtime] {
+			unhealthy = append(unhealthy, runtime)
+		}
+	}
+	sort.Strings(unhealthy)
+
+	switch {
+	case len(used) == 0:
+		health.Message = "no local models loaded"
+	case len(unhealthy) > 0:
+		health.Message = fmt.Sprintf("unhealthy runtimes: %v", unhealthy)
+	default:
+		health.Healthy = true
+		health.Available = true
+	}
+
+	p.health.store(health)
+
+	if health.Available && !p.IsAvailable(ctx) {
+		health.Available = false
+		health.Message = "at max concurrency"
+	}
+
+	return health
+}
+
+// Priority returns the provider priority
+func (p *LocalProvider) Priority() int {
+	return p.config.Priority
+}
+
+// GetCostPer1kTokens returns zero: local inference has no marginal cost
+func (p *LocalProvider) GetCostPer1kTokens(model string) float64 {
+	return 0.0
+}
@@ -0,0 +1,193 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRuntime is a scripted ModelRuntime serving ONNX and pickle models
+type fakeRuntime struct {
+	mu      sync.Mutex
+	loaded  map[string]models.ModelFormat
+	loadErr map[string]error
+	healthy map[string]bool
+	inputs  []map[string]interface{}
+	block   chan struct{} // Predict waits on this when set
+	started chan struct{} // Predict signals here when it starts, if set
+}
+
+func newFakeRuntime() *fakeRuntime {
+	return &fakeRuntime{
+		loaded:  make(map[string]models.ModelFormat),
+		loadErr: make(map[string]error),
+		healthy: map[string]bool{"onnx": true, "python": true},
+	}
+}
+
+func (r *fakeRuntime) LoadModel(ctx context.Context, modelID string, format models.ModelFormat, filePath string, useGPU bool) error {
+	if err := r.loadErr[modelID]; err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loaded[modelID] = format
+	return nil
+}
+
+func (r *fakeRuntime) Predict(ctx context.Context, modelID string, format models.ModelFormat, input map[string]interface{}) (map[string]interface{}, error) {
+	r.mu.Lock()
+	r.inputs = append(r.inputs, input)
+	r.mu.Unlock()
+
+	if r.started != nil {
+		r.started <- struct{}{}
+	}
+	if r.block != nil {
+		<-r.block
+	}
+	if input["fail"] != nil {
+		return nil, errors.New("tensor shape mismatch")
+	}
+	return map[string]interface{}{"label": "cat", "model": modelID}, nil
+}
+
+func (r *fakeRuntime) GetRuntimeForFormat(format models.ModelFormat) string {
+	switch format {
+	case models.FormatONNX:
+		return "onnx"
+	case models.FormatPickle:
+		return "python"
+	}
+	return ""
+}
+
+func (r *fakeRuntime) HealthCheck(ctx context.Context) map[string]bool {
+	return r.healthy
+}
+
+func newTestLocalProvider(t *testing.T, rt *fakeRuntime, cfg config.LocalProviderConfig) *LocalProvider {
+	t.Helper()
+	if cfg.Models == nil {
+		cfg.Models = []config.ModelConfig{{Name: "resnet", Path: "/models/resnet.onnx"}}
+	}
+	p, err := NewLocalProvider(context.Background(), &cfg, rt)
+	require.NoError(t, err)
+	return p
+}
+
+func TestLocalProviderLoadsModels(t *testing.T) {
+	rt := newFakeRuntime()
+	rt.loadErr["broken"] = errors.New("corrupt file")
+	p := newTestLocalProvider(t, rt, config.LocalProviderConfig{
+		Runtimes: []string{"onnx"},
+		Models: []config.ModelConfig{
+			{Name: "resnet", Path: "/models/resnet.onnx"},
+			{Name: "explicit", Path: "/models/model.bin", Runtime: "onnx"},
+			{Name: "sklearn", Path: "/models/clf.pkl"},    // python runtime is not enabled
+			{Name: "broken", Path: "/models/broken.onnx"}, // fails to load
+			{Name: "nopath"},
+			{Name: "unknown", Path: "/models/model.xyz"},
+		},
+	})
+
+	assert.Equal(t, []string{"explicit", "resnet"}, p.GetModels())
+	assert.Equal(t, models.FormatONNX, rt.loaded["explicit"])
+
+	_, err := NewLocalProvider(context.Background(), nil, rt)
+	assert.Error(t, err)
+	_, err = NewLocalProvider(context.Background(), &config.LocalProviderConfig{}, nil)
+	assert.Error(t, err)
+}
+
+func TestLocalProviderPredict(t *testing.T) {
+	rt := newFakeRuntime()
+	p := newTestLocalProvider(t, rt, config.LocalProviderConfig{})
+
+	resp, err := p.Predict(context.Background(), &PredictRequest{Model: "resnet", Input: []float64{1, 2}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"label": "cat", "model": "resnet"}, resp.Output)
+	assert.Equal(t, "local", resp.Metadata.Provider)
+	assert.Equal(t, "resnet", resp.Metadata.Model)
+	assert.Zero(t, resp.Metadata.Cost)
+	assert.Equal(t, map[string]interface{}{"input": []float64{1, 2}}, rt.inputs[0], "non-tensor input is wrapped")
+
+	_, err = p.Predict(context.Background(), &PredictRequest{Model: "resnet", Input: map[string]interface{}{"pixels": []float64{0}}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"pixels": []float64{0}}, rt.inputs[1], "named tensors are passed through")
+
+	_, err = p.Predict(context.Background(), &PredictRequest{Model: "missing"})
+	assert.ErrorContains(t, err, "not loaded")
+	_, err = p.Predict(context.Background(), &PredictRequest{Model: "resnet", Input: map[string]interface{}{"fail": true}})
+	assert.ErrorContains(t, err, "local onnx runtime inference failed")
+
+	stream, err := p.PredictStream(context.Background(), &PredictRequest{Model: "resnet", Input: "x"})
+	require.NoError(t, err)
+	var chunks []StreamChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	require.Len(t, chunks, 2)
+	assert.JSONEq(t, `{"label":"cat","model":"resnet"}`, chunks[0].Delta)
+	assert.True(t, chunks[1].Done)
+}
+
+func TestLocalProviderMaxConcurrency(t *testing.T) {
+	rt := newFakeRuntime()
+	rt.block = make(chan struct{})
+	rt.started = make(chan struct{})
+	p := newTestLocalProvider(t, rt, config.LocalProviderConfig{MaxConcurrency: 2})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.Predict(ctx, &PredictRequest{Model: "resnet", Input: "x"})
+			assert.NoError(t, err)
+		}()
+		<-rt.started
+	}
+
+	assert.False(t, p.IsAvailable(ctx), "saturated providers are unavailable")
+	assert.False(t, p.Health(ctx).Available)
+	_, err := p.Predict(ctx, &PredictRequest{Model: "resnet", Input: "x"})
+	assert.ErrorIs(t, err, ErrLocalSaturated, "calls past the limit are rejected, not queued")
+	_, err = p.PredictStream(ctx, &PredictRequest{Model: "resnet", Input: "x"})
+	assert.ErrorIs(t, err, ErrLocalSaturated, "streams fail before starting")
+
+	close(rt.block)
+	wg.Wait()
+	rt.block, rt.started = nil, nil
+	assert.True(t, p.IsAvailable(ctx))
+	_, err = p.Predict(ctx, &PredictRequest{Model: "resnet", Input: "x"})
+	assert.NoError(t, err)
+}
+
+func TestLocalProviderHealth(t *testing.T) {
+	ctx := context.Background()
+
+	rt := newFakeRuntime()
+	p := newTestLocalProvider(t, rt, config.LocalProviderConfig{})
+	health := p.Health(ctx)
+	assert.True(t, health.Healthy)
+	assert.True(t, p.IsAvailable(ctx))
+
+	rt.healthy = map[string]bool{"onnx": false}
+	health = p.Health(ctx)
+	assert.False(t, health.Healthy)
+	assert.Equal(t, "unhealthy runtimes: [onnx]", health.Message)
+	assert.False(t, p.IsAvailable(ctx))
+
+	empty := newTestLocalProvider(t, newFakeRuntime(), config.LocalProviderConfig{Models: []config.ModelConfig{}})
+	health = empty.Health(ctx)
+	assert.False(t, health.Available)
+	assert.Equal(t, "no local models loaded", health.Message)
+}
@@ -0,0 +1,462 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+)
+
+// OpenAIProvider implements the Provider interface for the OpenAI API
+type OpenAIProvider struct {
+	config     *config.OpenAIProviderConfig
+	httpClient *http.Client
+	baseURL    string
+	health     healthCache
+}
+
+// OpenAIChatRequest represents a request to the OpenAI chat completions API
+type OpenAIChatRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Temperature float64   `json:"temperature,omitempty"`
+	TopP        float64   `json:"top_p,omitempty"`
+	Stop        []string  `json:"stop,omitempty"`
+
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *OpenAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+// OpenAIStreamOptions requests a final usage chunk on streamed completions
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// OpenAIStreamChunk represents a chat.completion.chunk server-sent event
+type OpenAIStreamChunk struct {
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *OpenAIUsage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// OpenAIChatResponse represents a response from the OpenAI chat completions API
+type OpenAIChatResponse struct {
+	ID      string         `json:"id"`
+	Model   string         `json:"model"`
+	Choices []OpenAIChoice `json:"choices"`
+	Usage   *OpenAIUsage   `json:"usage,omitempty"`
+}
+
+// OpenAIChoice represents a chat completion choice
+type OpenAIChoice struct {
+	Index        int     `json:"index"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+// OpenAIUsage represents token usage information
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// OpenAIEmbeddingRequest represents a request to the OpenAI embeddings API
+type OpenAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// OpenAIEmbeddingResponse represents a response from the OpenAI embeddings API
+type OpenAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+	Usage *OpenAIUsage `json:"usage,omitempty"`
+}
+
+// OpenAIErrorResponse represents an error returned by the OpenAI API
+type OpenAIErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
+// NewOpenAIProvider creates a new OpenAI provider
+func NewOpenAIProvider(cfg *config.OpenAIProviderConfig) (*OpenAIProvider, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("openai config is required")
+	}
+
+	if cfg.Credentials.APIKey == "" {
+		return nil, fmt.Errorf("openai API key is required")
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.openai.com/v1"
+	}
+
+	return &OpenAIProvider{
+		config: cfg,
+		httpClient: &http.Client{
+			Timeout: 120 * time.Second,
+		},
+		baseURL: strings.TrimRight(endpoint, "/"),
+	}, nil
+}
+
+// Name returns the provider name
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+// Type returns the provider type
+func (p *OpenAIProvider) Type() string {
+	return "openai"
+}
+
+// IsAvailable reports the availability seen by the last health probe
+func (p *OpenAIProvider) IsAvailable(ctx context.Context) bool {
+	return p.health.available()
+}
+
+// GetModels returns the list of available models
+func (p *OpenAIProvider) GetModels() []string {
+	models := make([]string, 0, len(p.config.Models))
+	for _, model := range p.config.Models {
+		models = append(models, model.Name)
+	}
+	return models
+}
+
+// Predict performs inference with a model
+func (p *OpenAIProvider) Predict(ctx context.Context, req *PredictRequest) (*PredictResponse, error) {
+	modelConfig := findModel(p.config.Models, req.Model)
+	if modelConfig == nil {
+		return nil, fmt.Errorf("model %s not found in openai provider configuration", req.Model)
+	}
+
+	if hasCapability(modelConfig.Capabilities, CapabilityEmbeddings) {
+		return p.embed(ctx, req, modelConfig)
+	}
+
+	oaReq, err := p.chatRequest(req, modelConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	var oaResp OpenAIChatResponse
+	if err := p.makeRequest(ctx, "/chat/completions", oaReq, &oaResp); err != nil {
+		return nil, fmt.Errorf("openai API request failed: %w", err)
+	}
+	latency := time.Since(startTime)
+
+	if len(oaResp.Choices) == 0 {
+		return nil, fmt.Errorf("no output in openai response")
+	}
+	output := oaResp.Choices[0].Message.Content
+
+	var inputTokens, outputTokens int
+	if oaResp.Usage != nil {
+		inputTokens = oaResp.Usage.PromptTokens
+		outputTokens = oaResp.Usage.CompletionTokens
+	} else {
+		inputTokens = estimateTokens(req.Input)
+		outputTokens = estimateTokens(output)
+	}
+
+	totalTokens := inputTokens + outputTokens
+
+	return &PredictResponse{
+		Output: output,
+		Metadata: ResponseMetadata{
+			Provider:     p.Name(),
+			Model:        req.Model,
+			LatencyMs:    int(latency.Milliseconds()),
+			InputTokens:  inputTokens,
+			OutputTokens: outputTokens,
+			TotalTokens:  totalTokens,
+			Cost:         float64(totalTokens) / 1000.0 * p.GetCostPer1kTokens(req.Model),
+			Currency:     "USD",
+			FinishReason: oaResp.Choices[0].FinishReason,
+		},
+	}, nil
+}
+
+// PredictStream performs a streamed chat completion
+func (p *OpenAIProvider) PredictStream(ctx context.Context, req *PredictRequest) (<-chan StreamChunk, error) {
+	modelConfig := findModel(p.config.Models, req.Model)
+	if modelConfig == nil {
+		return nil, fmt.Errorf("model %s not found in openai provider configuration", req.Model)
+	}
+
+	if hasCapability(modelConfig.Capabilities, CapabilityEmbeddings) {
+		return nil, fmt.Errorf("streaming is not supported for embedding model %s", req.Model)
+	}
+
+	oaReq, err := p.chatRequest(req, modelConfig)
+	if err != nil {
+		return nil, err
+	}
+	oaReq.Stream = true
+	oaReq.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+
+	startTime := time.Now()
+	resp, err := p.post(ctx, "/chat/completions", oaReq)
+	if err != nil {
+		return nil, fmt.Errorf("openai API request failed: %w", err)
+	}
+
+	sender, ch := newStreamSender(ctx)
+	go func() {
+		defer sender.close()
+		defer resp.Body.Close()
+
+		var output strings.Builder
+		var usage *OpenAIUsage
+		finishReason := ""
+
+		err := readSSE(resp.Body, func(event, data string) error {
+			if data == "[DONE]" {
+				return errStreamDone
+			}
+
+			var chunk OpenAIStreamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return fmt.Errorf("failed to parse stream chunk: %w", err)
+			}
+			if chunk.Error != nil {
+				return fmt.Errorf("upstream error: %s", chunk.Error.Message)
+			}
+			if chunk.Usage != nil {
+				usage = chunk.Usage
+			}
+			for _, choice := range chunk.Choices {
+				if choice.FinishReason != nil {
+					finishReason = *choice.FinishReason
+				}
+				if choice.Delta.Content == "" {
+					continue
+				}
+				output.WriteString(choice.Delta.Content)
+				if !sender.send(StreamChunk{Delta: choice.Delta.Content}) {
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			sender.fail(fmt.Errorf("openai stream failed: %w", err))
+			return
+		}
+
+		var inputTokens, outputTokens int
+		if usage != nil {
+			inputTokens = usage.PromptTokens
+			outputTokens = usage.CompletionTokens
+		} else {
+			inputTokens = estimateTokens(req.Input)
+			outputTokens = estimateTokens(output.String())
+		}
+		totalTokens := inputTokens + outputTokens
+
+		sender.send(StreamChunk{
+			Done:         true,
+			FinishReason: finishReason,
+			Metadata: &ResponseMetadata{
+				Provider:     p.Name(),
+				Model:        req.Model,
+				LatencyMs:    int(time.Since(startTime).Milliseconds()),
+				InputTokens:  inputTokens,
+				OutputTokens: outputTokens,
+				TotalTokens:  totalTokens,
+				Cost:         float64(totalTokens) / 1000.0 * p.GetCostPer1kTokens(req.Model),
+				Currency:     "USD",
+			},
+		})
+	}()
+
+	return ch, nil
+}
+
+// chatRequest builds a chat completions request for a configured model
+func (p *OpenAIProvider) chatRequest(req *PredictRequest, modelConfig *config.ModelConfig) (OpenAIChatRequest, error) {
+	messages, err := chatMessages(req.Input)
+	if err != nil {
+		return OpenAIChatRequest{}, err
+	}
+
+	return OpenAIChatRequest{
+		Model:       p.upstreamModel(modelConfig),
+		Messages:    messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+	}, nil
+}
+
+// embed generates embeddings with an OpenAI embedding model
+func (p *OpenAIProvider) embed(ctx context.Context, req *PredictRequest, modelConfig *config.ModelConfig) (*PredictResponse, error) {
+	texts, err := embeddingInputs(req.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	oaReq := OpenAIEmbeddingRequest{
+		Model: p.upstreamModel(modelConfig),
+		Input: texts,
+	}
+
+	startTime := time.Now()
+	var oaResp OpenAIEmbeddingResponse
+	if err := p.makeRequest(ctx, "/embeddings", oaReq, &oaResp); err != nil {
+		return nil, fmt.Errorf("openai API request failed: %w", err)
+	}
+	latency := time.Since(startTime)
+
+	if len(oaResp.Data) == 0 {
+		return nil, fmt.Errorf("no embeddings in openai response")
+	}
+
+	vectors := make([][]float64, len(oaResp.Data))
+	for _, d := range oaResp.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("openai returned embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+
+	var inputTokens int
+	if oaResp.Usage != nil {
+		inputTokens = oaResp.Usage.PromptTokens
+	} else {
+		for _, text := range texts {
+			inputTokens += estimateTokens(text)
+		}
+	}
+
+	return &PredictResponse{
+		Output: vectors,
+		Metadata: ResponseMetadata{
+			Provider:    p.Name(),
+			Model:       req.Model,
+			LatencyMs:   int(latency.Milliseconds()),
+			InputTokens: inputTokens,
+			TotalTokens: inputTokens,
+			Cost:        float64(inputTokens) / 1000.0 * p.GetCostPer1kTokens(req.Model),
+			Currency:    "USD",
+		},
+	}, nil
+}
+
+// upstreamModel returns the OpenAI model ID for a configured model
+func (p *OpenAIProvider) upstreamModel(modelConfig *config.ModelConfig) string {
+	if modelConfig.OpenAIModel != "" {
+		return modelConfig.OpenAIModel
+	}
+	return modelConfig.Name
+}
+
+// makeRequest makes an HTTP request to the OpenAI API and decodes the response into out
+func (p *OpenAIProvider) makeRequest(ctx context.Context, path string, req interface{}, out interface{}) error {
+	resp, err := p.post(ctx, path, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
+
+// post sends a JSON request to the OpenAI API. Non-200 responses are returned as
+// errors; on success the caller owns the response body.
+func (p *OpenAIProvider) post(ctx context.Context, path string, req interface{}) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+p.config.Credentials.APIKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+
+		var errResp OpenAIErrorResponse
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error.Message != "" {
+			return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, errResp.Error.Message)
+		}
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return resp, nil
+}
+
+// Health probes the OpenAI models endpoint and caches the result for IsAvailable
+func (p *OpenAIProvider) Health(ctx context.Context) ProviderHealth {
+	req, err := http.NewRequest("GET", p.baseURL+"/models", nil)
+	if err != nil {
+		health := ProviderHealth{Provider: p.Name(), Message: err.Error()}
+		p.health.store(health)
+		return health
+	}
+	req.Header.Set("Authorization", "Bearer "+p.config.Credentials.APIKey)
+
+	health := probeHealth(ctx, p.httpClient, p.Name(), req)
+	p.health.store(health)
+	return health
+}
+
+// Priority returns the provider priority
+func (p *OpenAIProvider) Priority() int {
+	return p.config.Priority
+}
+
+// GetCostPer1kTokens returns the cost per 1k tokens for a model
+func (p *OpenAIProvider) GetCostPer1kTokens(model string) float64 {
+	if m := findModel(p.config.Models, model); m != nil {
+		return m.CostPer1kTokens
+	}
+	return 0.0
+}
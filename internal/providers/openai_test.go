@@ -0,0 +1,236 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestOpenAIProvider(t *testing.T, handler http.HandlerFunc) *OpenAIProvider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider, err := NewOpenAIProvider(&config.OpenAIProviderConfig{
+		Enabled:     true,
+		Priority:    3,
+		Credentials: config.OpenAICredentials{APIKey: "sk-test"},
+		Endpoint:    server.URL + "/v1/",
+		Models: []config.ModelConfig{
+			{Name: "gpt-4o-mini", OpenAIModel: "gpt-4o-mini-2024-07-18", Capabilities: []string{CapabilityTextGeneration}, CostPer1kTokens: 0.5},
+			{Name: "text-embed", OpenAIModel: "text-embedding-3-small", Capabilities: []string{CapabilityEmbeddings}, CostPer1kTokens: 0.02},
+		},
+	})
+	require.NoError(t, err)
+	return provider
+}
+
+func TestOpenAIProvider_PredictChat(t *testing.T) {
+	var got OpenAIChatRequest
+	provider := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "chatcmpl-1",
+			"choices": []map[string]interface{}{{"index": 0, "message": map[string]string{"role": "assistant", "content": "Hi there"}, "finish_reason": "stop"}},
+			"usage":   map[string]int{"prompt_tokens": 1200, "completion_tokens": 800, "total_tokens": 2000},
+		})
+	})
+
+	resp, err := provider.Predict(context.Background(), &PredictRequest{
+		Model: "gpt-4o-mini",
+		Input: []interface{}{
+			map[string]interface{}{"role": "system", "content": "Be brief"},
+			map[string]interface{}{"role": "user", "content": "Hello"},
+		},
+		MaxTokens:   64,
+		Temperature: 0.2,
+		Stop:        []string{"\n\n", "END"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "gpt-4o-mini-2024-07-18", got.Model)
+	assert.Equal(t, []Message{{Role: "system", Content: "Be brief"}, {Role: "user", Content: "Hello"}}, got.Messages)
+	assert.Equal(t, 64, got.MaxTokens)
+	assert.Equal(t, []string{"\n\n", "END"}, got.Stop)
+
+	assert.Equal(t, "Hi there", resp.Output)
+	assert.Equal(t, "openai", resp.Metadata.Provider)
+	assert.Equal(t, "gpt-4o-mini", resp.Metadata.Model)
+	assert.Equal(t, 1200, resp.Metadata.InputTokens)
+	assert.Equal(t, 800, resp.Metadata.OutputTokens)
+	assert.Equal(t, 2000, resp.Metadata.TotalTokens)
+	assert.InDelta(t, 1.0, resp.Metadata.Cost, 1e-9)
+	assert.Equal(t, "USD", resp.Metadata.Currency)
+	assert.Equal(t, "stop", resp.Metadata.FinishReason)
+}
+
+func TestOpenAIProvider_PredictEmbeddings(t *testing.T) {
+	provider := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+
+		var req OpenAIEmbeddingRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "text-embedding-3-small", req.Model)
+		assert.Equal(t, []string{"a", "b"}, req.Input)
+
+		// Return out of order to check that vectors are placed by index
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": []map[string]interface{}{
+				{"index": 1, "embedding": []float64{0.3, 0.4}},
+				{"index": 0, "embedding": []float64{0.1, 0.2}},
+			},
+			"usage": map[string]int{"prompt_tokens": 1000, "total_tokens": 1000},
+		})
+	})
+
+	resp, err := provider.Predict(context.Background(), &PredictRequest{
+		Model: "text-embed",
+		Input: []interface{}{"a", "b"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]float64{{0.1, 0.2}, {0.3, 0.4}}, resp.Output)
+	assert.Equal(t, 1000, resp.Metadata.InputTokens)
+	assert.InDelta(t, 0.02, resp.Metadata.Cost, 1e-9)
+}
+
+func TestOpenAIProvider_PredictAPIError(t *testing.T) {
+	provider := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"Rate limit reached","type":"requests"}}`))
+	})
+
+	_, err := provider.Predict(context.Background(), &PredictRequest{Model: "gpt-4o-mini", Input: "Hello"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 429")
+	assert.Contains(t, err.Error(), "Rate limit reached")
+
+	_, err = provider.Predict(context.Background(), &PredictRequest{Model: "unknown", Input: "Hello"})
+	assert.Error(t, err)
+}
+
+func TestOpenAIProvider_Health(t *testing.T) {
+	status := http.StatusOK
+	provider := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path)
+		assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		w.WriteHeader(status)
+		w.Write([]byte(`{"data":[]}`))
+	})
+	ctx := context.Background()
+
+	// Never probed: assume available
+	assert.True(t, provider.IsAvailable(ctx))
+
+	health := provider.Health(ctx)
+	assert.True(t, health.Healthy)
+	assert.True(t, health.Available)
+	assert.True(t, provider.IsAvailable(ctx))
+
+	status = http.StatusUnauthorized
+	health = provider.Health(ctx)
+	assert.False(t, health.Healthy)
+	assert.Contains(t, health.Message, "credentials rejected")
+	assert.False(t, provider.IsAvailable(ctx))
+
+	status = http.StatusOK
+	provider.Health(ctx)
+	assert.True(t, provider.IsAvailable(ctx))
+}
+
+func TestOpenAIProvider_GetCostPer1kTokens(t *testing.T) {
+	provider := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	assert.Equal(t, 0.5, provider.GetCostPer1kTokens("gpt-4o-mini"))
+	assert.Equal(t, 0.0, provider.GetCostPer1kTokens("unknown"))
+	assert.ElementsMatch(t, []string{"gpt-4o-mini", "text-embed"}, provider.GetModels())
+	assert.Equal(t, 3, provider.Priority())
+}
+
+func TestNewOpenAIProvider_RequiresAPIKey(t *testing.T) {
+	_, err := NewOpenAIProvider(&config.OpenAIProviderConfig{Enabled: true})
+	assert.Error(t, err)
+
+	_, err = NewOpenAIProvider(nil)
+	assert.Error(t, err)
+}
+
+func TestOpenAIProvider_PredictStream(t *testing.T) {
+	provider := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAIChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.True(t, req.Stream)
+		require.NotNil(t, req.StreamOptions)
+		assert.True(t, req.StreamOptions.IncludeUsage)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"\"},\"finish_reason\":null}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"},\"finish_reason\":null}]}\n\n"))
+		w.Write([]byte(": keep-alive\n\n"))
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"},\"finish_reason\":null}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"length\"}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[],\"usage\":{\"prompt_tokens\":600,\"completion_tokens\":400,\"total_tokens\":1000}}\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	})
+
+	stream, err := provider.PredictStream(context.Background(), &PredictRequest{Model: "gpt-4o-mini", Input: "Hi"})
+	require.NoError(t, err)
+
+	var chunks []StreamChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+
+	require.Len(t, chunks, 3)
+	assert.Equal(t, "Hel", chunks[0].Delta)
+	assert.Equal(t, "lo", chunks[1].Delta)
+
+	last := chunks[2]
+	assert.True(t, last.Done)
+	assert.NoError(t, last.Err)
+	assert.Equal(t, "length", last.FinishReason)
+	require.NotNil(t, last.Metadata)
+	assert.Equal(t, 600, last.Metadata.InputTokens)
+	assert.Equal(t, 400, last.Metadata.OutputTokens)
+	assert.InDelta(t, 0.5, last.Metadata.Cost, 1e-9)
+}
+
+func TestOpenAIProvider_PredictStreamErrors(t *testing.T) {
+	provider := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	// Errors before the stream starts are returned directly
+	_, err := provider.PredictStream(context.Background(), &PredictRequest{Model: "gpt-4o-mini", Input: "Hi"})
+	assert.Error(t, err)
+
+	_, err = provider.PredictStream(context.Background(), &PredictRequest{Model: "text-embed", Input: "Hi"})
+	assert.Error(t, err)
+
+	provider = newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n"))
+		w.Write([]byte("data: {\"error\":{\"message\":\"server overloaded\"}}\n\n"))
+	})
+
+	stream, err := provider.PredictStream(context.Background(), &PredictRequest{Model: "gpt-4o-mini", Input: "Hi"})
+	require.NoError(t, err)
+
+	first := <-stream
+	assert.Equal(t, "Hi", first.Delta)
+
+	last := <-stream
+	assert.True(t, last.Done)
+	require.Error(t, last.Err)
+	assert.Contains(t, last.Err.Error(), "server overloaded")
+
+	_, ok := <-stream
+	assert.False(t, ok)
+}
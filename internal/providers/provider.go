@@ -2,6 +2,9 @@ package providers
 
 import (
 	"context"
+	"fmt"
+
+	"github.com/aiserve/gpuproxy/internal/config"
 )
 
 // Provider defines the interface that all AI providers must implement
@@ -21,6 +24,11 @@ type Provider interface {
 	// Predict performs inference with a model
 	Predict(ctx context.Context, req *PredictRequest) (*PredictResponse, error)
 
+	// PredictStream performs inference and streams token deltas. An error
+	// returned here, or a failed first chunk, means nothing was emitted yet.
+	// The channel is closed after the terminal chunk.
+	PredictStream(ctx context.Context, req *PredictRequest) (<-chan StreamChunk, error)
+
 	// Health returns the provider health status
 	Health(ctx context.Context) ProviderHealth
 
@@ -40,6 +48,8 @@ type PredictRequest struct {
 	TopP        float64     `json:"top_p,omitempty"`
 	Stop        []string    `json:"stop,omitempty"`
 	Stream      bool        `json:"stream,omitempty"`
+	Capability  string      `json:"capability,omitempty"` // text-generation, embeddings
+	UserTier    string      `json:"-"`                    // set from the authenticated API key
 }
 
 // PredictResponse represents a prediction response
@@ -59,6 +69,7 @@ type ResponseMetadata struct {
 	Cost         float64 `json:"cost"`
 	Currency     string  `json:"currency"`
 	Cached       bool    `json:"cached,omitempty"`
+	FinishReason string  `json:"finish_reason,omitempty"` // Why the provider stopped, e.g. stop or length; empty if it didn't say
 }
 
 // ProviderHealth represents the health status of a provider
@@ -76,3 +87,79 @@ type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
 }
+
+// Well-known model capabilities used in provider model configs
+const (
+	CapabilityTextGeneration = "text-generation"
+	CapabilityEmbeddings     = "embeddings"
+)
+
+// hasCapability reports whether a model capability list contains a capability
+func hasCapability(capabilities []string, capability string) bool {
+	for _, c := range capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// chatMessages converts a request input into chat messages. A plain string
+// prompt becomes a single user message.
+func chatMessages(input interface{}) ([]Message, error) {
+	switch v := input.(type) {
+	case string:
+		return []Message{{Role: "user", Content: v}}, nil
+	case []Message:
+		return v, nil
+	case []interface{}:
+		messages := make([]Message, 0, len(v))
+		for _, msg := range v {
+			m, ok := msg.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("unsupported message format")
+			}
+			role, _ := m["role"].(string)
+			content, _ := m["content"].(string)
+			if role == "" {
+				return nil, fmt.Errorf("message role is required")
+			}
+			messages = append(messages, Message{Role: role, Content: content})
+		}
+		return messages, nil
+	default:
+		return nil, fmt.Errorf("unsupported input format")
+	}
+}
+
+// embeddingInputs converts a request input into a list of texts to embed
+func embeddingInputs(input interface{}) ([]string, error) {
+	switch v := input.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		texts := make([]string, 0, len(v))
+		for _, item := range v {
+			text, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("embedding input must be a string or a list of strings")
+			}
+			texts = append(texts, text)
+		}
+		return texts, nil
+	default:
+		return nil, fmt.Errorf("unsupported input format")
+	}
+}
+
+// findModel returns the model config with the given name
+func findModel(models []config.ModelConfig, name string) *config.ModelConfig {
+	for i := range models {
+		if models[i].Name == name {
+			return &models[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package providers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamChunk is a single event of a streamed prediction. Every chunk but the
+// last carries a token delta; the terminal chunk has Done set and carries the
+// usage and cost metadata, or Err if the stream failed.
+type StreamChunk struct {
+	Delta        string            `json:"delta,omitempty"`
+	FinishReason string            `json:"finish_reason,omitempty"`
+	Done         bool              `json:"done,omitempty"`
+	Metadata     *ResponseMetadata `json:"metadata,omitempty"`
+	Err          error             `json:"-"`
+}
+
+// errStreamDone stops SSE parsing when the upstream signals the end of the stream
+var errStreamDone = errors.New("stream done")
+
+// streamSender delivers chunks to the consumer of a stream, giving up once the
+// request context is cancelled so producer goroutines never block forever
+type streamSender struct {
+	ctx context.Context
+	ch  chan StreamChunk
+}
+
+// newStreamSender creates a sender and the channel handed to the consumer
+func newStreamSender(ctx context.Context) (*streamSender, <-chan StreamChunk) {
+	ch := make(chan StreamChunk)
+	return &streamSender{ctx: ctx, ch: ch}, ch
+}
+
+// send delivers a chunk and reports whether the consumer is still listening
+func (s *streamSender) send(chunk StreamChunk) bool {
+	select {
+	case s.ch <- chunk:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// fail sends a terminal error chunk
+func (s *streamSender) fail(err error) {
+	s.send(StreamChunk{Done: true, Err: err})
+}
+
+// close ends the stream
+func (s *streamSender) close() {
+	close(s.ch)
+}
+
+// readSSE parses a text/event-stream body and calls fn for every event.
+// Returning errStreamDone from fn stops parsing without an error.
+func readSSE(r io.Reader, fn func(event, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var event string
+	var data []string
+
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		err := fn(event, strings.Join(data, "\n"))
+		event = ""
+		data = nil
+		return err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := dispatch(); err != nil {
+				if errors.Is(err, errStreamDone) {
+					return nil
+				}
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// Comment / keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if err := dispatch(); err != nil && !errors.Is(err, errStreamDone) {
+		return err
+	}
+	return nil
+}
+
+// StreamFromPredict adapts a buffered Predict call to the streaming interface
+// for providers whose runtimes cannot emit tokens incrementally. The whole
+// output is delivered as a single delta followed by the terminal chunk.
+func StreamFromPredict(ctx context.Context, provider Provider, req *PredictRequest) (<-chan StreamChunk, error) {
+	resp, err := provider.Predict(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var delta string
+	switch v := resp.Output.(type) {
+	case string:
+		delta = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode output: %w", err)
+		}
+		delta = string(data)
+	}
+
+	sender, ch := newStreamSender(ctx)
+	go func() {
+		defer sender.close()
+
+		if delta != "" && !sender.send(StreamChunk{Delta: delta}) {
+			return
+		}
+		meta := resp.Metadata
+		sender.send(StreamChunk{Done: true, FinishReason: "stop", Metadata: &meta})
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,129 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/aiserve/gpuproxy/internal/providers"
+	"github.com/aiserve/gpuproxy/internal/router/budget"
+)
+
+// enforceBudget checks a routing decision against the budget before dispatch.
+// Requests that would exceed the remaining budget, or any request while a
+// switch_to_cheapest alert is in effect, are downgraded to the cheapest eligible
+// provider; if none fits the request is rejected with ErrBudgetExceeded.
+func (r *Router) enforceBudget(ctx context.Context, req *providers.PredictRequest, decision *RoutingDecision) (*RoutingDecision, error) {
+	if r.budget == nil {
+		return decision, nil
+	}
+
+	remaining, mode, err := r.budget.Remaining(ctx)
+	if err != nil {
+		// Failing open keeps traffic flowing when the cost db is unavailable
+		log.Printf("Warning: budget check failed, routing without limits: %v", err)
+		return decision, nil
+	}
+
+	limit := remaining
+	if mode == budget.ModeBlock {
+		limit = 0
+	}
+
+	estimated := r.estimateCost(decision.Provider, req)
+	if mode != budget.ModeCheapest && estimated <= limit {
+		decision.EstimatedCost = estimated
+		decision.budgeted, decision.costLimit = true, limit
+		return decision, nil
+	}
+
+	// Downgrade within the providers the decision allows
+	names := append([]string{decision.Provider}, decision.Alternatives...)
+	if !decision.constrained {
+		names = names[:0]
+		for _, c := range r.routeCandidates(ctx, req.Model) {
+			names = append(names, c.name)
+		}
+	}
+
+	type providerCost struct {
+		name     string
+		cost     float64
+		priority int
+	}
+	var affordable []providerCost
+	for _, name := range names {
+		provider, ok := r.providers[name]
+		if !ok {
+			continue
+		}
+		if cost := r.estimateCost(name, req); cost <= limit {
+			affordable = append(affordable, providerCost{name: name, cost: cost, priority: provider.Priority()})
+		}
+	}
+
+	if len(affordable) == 0 {
+		if mode == budget.ModeBlock {
+			return nil, fmt.Errorf("%w: paid providers are blocked by a budget alert", ErrBudgetExceeded)
+		}
+		return nil, fmt.Errorf("%w: estimated $%.6f, remaining $%.6f", ErrBudgetExceeded, estimated, remaining)
+	}
+
+	sort.SliceStable(affordable, func(i, j int) bool {
+		if affordable[i].cost != affordable[j].cost {
+			return affordable[i].cost < affordable[j].cost
+		}
+		return affordable[i].priority < affordable[j].priority
+	})
+
+	alternatives := make([]string, 0, len(affordable)-1)
+	for _, c := range affordable[1:] {
+		alternatives = append(alternatives, c.name)
+	}
+
+	var reason string
+	switch mode {
+	case budget.ModeNormal:
+		reason = fmt.Sprintf("budget: downgraded from %s to fit remaining $%.6f", decision.Provider, remaining)
+	default:
+		reason = fmt.Sprintf("budget %s mode: cheapest eligible provider", mode)
+	}
+	if decision.Reason != "" {
+		reason += " (" + decision.Reason + ")"
+	}
+
+	return &RoutingDecision{
+		Provider:      affordable[0].name,
+		Model:         req.Model,
+		Reason:        reason,
+		Alternatives:  alternatives,
+		EstimatedCost: affordable[0].cost,
+		constrained:   true,
+		budgeted:      true,
+		costLimit:     limit,
+	}, nil
+}
+
+// estimateCost estimates what a request will cost on a provider
+func (r *Router) estimateCost(name string, req *providers.PredictRequest) float64 {
+	provider, ok := r.providers[name]
+	if !ok {
+		return 0
+	}
+	return provider.GetCostPer1kTokens(req.Model) * float64(estimateRequestTokens(req)) / 1000.0
+}
+
+// withinBudget reports whether a request may fail over to a provider without
+// exceeding the cost limit its decision was checked against
+func (r *Router) withinBudget(decision *RoutingDecision, name string, req *providers.PredictRequest) bool {
+	return !decision.budgeted || r.estimateCost(name, req) <= decision.costLimit
+}
+
+// BudgetStatus returns current spend against the budget, or nil when budgets are disabled
+func (r *Router) BudgetStatus(ctx context.Context) (*budget.Status, error) {
+	if r.budget == nil {
+		return nil, nil
+	}
+	return r.budget.Status(ctx)
+}
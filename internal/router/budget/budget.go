@@ -0,0 +1,265 @@
+// Package budget enforces the AIProxy daily and monthly spend limits.
+//
+// Spend is recorded per UTC day and month in a Store so it survives restarts.
+// Alerts fire once per period when spend crosses their threshold; the
+// switch_to_cheapest and block actions stay in effect until the period ends.
+package budget
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+)
+
+// Mode is the enforcement mode currently in effect
+type Mode string
+
+const (
+	// ModeNormal routes normally while there is budget left
+	ModeNormal Mode = "normal"
+	// ModeCheapest routes every request to the cheapest eligible provider
+	ModeCheapest Mode = "cheapest"
+	// ModeBlock only allows requests that cost nothing, such as local inference
+	ModeBlock Mode = "block"
+)
+
+// Manager tracks spend against the configured limits
+type Manager struct {
+	config     config.BudgetConfig
+	nodeID     string
+	store      Store
+	httpClient *http.Client
+	now        func() time.Time
+	mu         sync.Mutex
+}
+
+// Status is a snapshot of spend and limits. A zero limit is unlimited.
+type Status struct {
+	DailySpend   float64 `json:"daily_spend"`
+	DailyLimit   float64 `json:"daily_limit"`
+	MonthlySpend float64 `json:"monthly_spend"`
+	MonthlyLimit float64 `json:"monthly_limit"`
+	Mode         Mode    `json:"mode"`
+}
+
+// AlertEvent is the JSON body posted to webhook alerts
+type AlertEvent struct {
+	Event     string    `json:"event"`
+	NodeID    string    `json:"node_id"`
+	Period    string    `json:"period"`
+	Threshold int       `json:"threshold"`
+	Spend     float64   `json:"spend"`
+	Limit     float64   `json:"limit"`
+	Action    string    `json:"action"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewManager creates a budget manager over a store
+func NewManager(cfg config.BudgetConfig, nodeID string, store Store) (*Manager, error) {
+	if store == nil {
+		return nil, fmt.Errorf("budget store is required")
+	}
+
+	return &Manager{
+		config:     cfg,
+		nodeID:     nodeID,
+		store:      store,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+	}, nil
+}
+
+// Close closes the underlying store
+func (m *Manager) Close() error {
+	return m.store.Close()
+}
+
+// periods returns the day and month period keys for a time
+func periods(t time.Time) (day, month string) {
+	t = t.UTC()
+	return "day:" + t.Format("2006-01-02"), "month:" + t.Format("2006-01")
+}
+
+// Remaining returns how much can still be spent in the current day and month
+// (math.Inf(1) when unlimited) and the enforcement mode in effect
+func (m *Manager) Remaining(ctx context.Context) (float64, Mode, error) {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return 0, ModeNormal, err
+	}
+
+	remaining := math.Inf(1)
+	if status.DailyLimit > 0 {
+		remaining = math.Min(remaining, status.DailyLimit-status.DailySpend)
+	}
+	if status.MonthlyLimit > 0 {
+		remaining = math.Min(remaining, status.MonthlyLimit-status.MonthlySpend)
+	}
+	return math.Max(remaining, 0), status.Mode, nil
+}
+
+// Status returns current spend, limits and mode
+func (m *Manager) Status(ctx context.Context) (*Status, error) {
+	day, month := periods(m.now())
+
+	daySpend, err := m.store.Spend(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+	monthSpend, err := m.store.Spend(ctx, month)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := m.mode(ctx, day, month)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Status{
+		DailySpend:   daySpend,
+		DailyLimit:   m.config.DailyLimit,
+		MonthlySpend: monthSpend,
+		MonthlyLimit: m.config.MonthlyLimit,
+		Mode:         mode,
+	}, nil
+}
+
+// mode derives the enforcement mode from the alerts fired in the current periods
+func (m *Manager) mode(ctx context.Context, day, month string) (Mode, error) {
+	fired := make(map[int]bool)
+	for _, period := range []string{day, month} {
+		thresholds, err := m.store.Alerts(ctx, period)
+		if err != nil {
+			return ModeNormal, err
+		}
+		for _, t := range thresholds {
+			fired[t] = true
+		}
+	}
+
+	mode := ModeNormal
+	for _, alert := range m.config.Alerts {
+		if !fired[alert.Threshold] {
+			continue
+		}
+		switch alert.NormalizedAction() {
+		case config.BudgetActionBlock:
+			return ModeBlock, nil
+		case config.BudgetActionSwitchToCheapest:
+			mode = ModeCheapest
+		}
+	}
+	return mode, nil
+}
+
+// Record adds the cost of a completed request to the current periods and fires
+// any alerts whose threshold is now crossed
+func (m *Manager) Record(ctx context.Context, cost float64) error {
+	if cost <= 0 {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	day, month := periods(m.now())
+	if err := m.store.AddSpend(ctx, []string{day, month}, cost); err != nil {
+		return err
+	}
+
+	limits := []struct {
+		period string
+		limit  float64
+	}{
+		{day, m.config.DailyLimit},
+		{month, m.config.MonthlyLimit},
+	}
+
+	alerts := append([]config.BudgetAlert(nil), m.config.Alerts...)
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].Threshold < alerts[j].Threshold
+	})
+
+	for _, l := range limits {
+		if l.limit <= 0 {
+			continue
+		}
+		spend, err := m.store.Spend(ctx, l.period)
+		if err != nil {
+			return err
+		}
+		percent := spend / l.limit * 100
+
+		for _, alert := range alerts {
+			if percent < float64(alert.Threshold) {
+				break
+			}
+			fired, err := m.store.RecordAlert(ctx, l.period, alert.Threshold)
+			if err != nil {
+				return err
+			}
+			if fired {
+				m.fire(alert, l.period, spend, l.limit)
+			}
+		}
+	}
+
+	return nil
+}
+
+// fire runs an alert action for a crossed threshold
+func (m *Manager) fire(alert config.BudgetAlert, period string, spend, limit float64) {
+	action := alert.NormalizedAction()
+	log.Printf("Budget alert: %s spend $%.2f of $%.2f crossed %d%% (action: %s)", period, spend, limit, alert.Threshold, action)
+
+	switch action {
+	case config.BudgetActionSwitchToCheapest:
+		log.Printf("Budget: routing to the cheapest providers until %s ends", period)
+	case config.BudgetActionBlock:
+		log.Printf("Budget: blocking paid requests until %s ends", period)
+	case config.BudgetActionWebhook:
+		event := AlertEvent{
+			Event:     "budget_alert",
+			NodeID:    m.nodeID,
+			Period:    period,
+			Threshold: alert.Threshold,
+			Spend:     spend,
+			Limit:     limit,
+			Action:    action,
+			Timestamp: m.now().UTC(),
+		}
+		go func() {
+			if err := m.postWebhook(alert.Webhook, event); err != nil {
+				log.Printf("Warning: budget webhook %s failed: %v", alert.Webhook, err)
+			}
+		}()
+	}
+}
+
+// postWebhook delivers an alert event
+func (m *Manager) postWebhook(url string, event AlertEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := m.httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
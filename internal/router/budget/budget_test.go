@@ -0,0 +1,117 @@
+package budget
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T, cfg config.BudgetConfig, store Store, now time.Time) *Manager {
+	t.Helper()
+	m, err := NewManager(cfg, "node-1", store)
+	require.NoError(t, err)
+	m.now = func() time.Time { return now }
+	return m
+}
+
+func TestSpendSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	dsn := "sqlite://" + filepath.Join(t.TempDir(), "data", "costs.db")
+	now := time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)
+	cfg := config.BudgetConfig{
+		DailyLimit:   10,
+		MonthlyLimit: 100,
+		Alerts:       []config.BudgetAlert{{Threshold: 50, Action: "switch-to-cheapest"}},
+	}
+
+	store, err := OpenStore(dsn)
+	require.NoError(t, err)
+	m := newTestManager(t, cfg, store, now)
+	require.NoError(t, m.Record(ctx, 3))
+	require.NoError(t, m.Record(ctx, 3))
+	require.NoError(t, m.Close())
+
+	store, err = OpenStore(dsn)
+	require.NoError(t, err)
+	m = newTestManager(t, cfg, store, now)
+	defer m.Close()
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.InDelta(t, 6, status.DailySpend, 1e-9)
+	assert.InDelta(t, 6, status.MonthlySpend, 1e-9)
+	assert.Equal(t, ModeCheapest, status.Mode, "alert state is restored from the cost db")
+
+	remaining, _, err := m.Remaining(ctx)
+	require.NoError(t, err)
+	assert.InDelta(t, 4, remaining, 1e-9)
+
+	// A new day resets the daily period but not the month
+	m.now = func() time.Time { return now.Add(24 * time.Hour) }
+	status, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, status.DailySpend)
+	assert.InDelta(t, 6, status.MonthlySpend, 1e-9)
+	assert.Equal(t, ModeNormal, status.Mode)
+}
+
+func TestAlertsFireOncePerPeriod(t *testing.T) {
+	ctx := context.Background()
+	events := make(chan AlertEvent, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event AlertEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events <- event
+	}))
+	defer hook.Close()
+
+	cfg := config.BudgetConfig{
+		DailyLimit: 10,
+		Alerts: []config.BudgetAlert{
+			{Threshold: 80, Action: "webhook", Webhook: hook.URL},
+			{Threshold: 100, Action: "block"},
+		},
+	}
+	m := newTestManager(t, cfg, NewMemoryStore(), time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC))
+
+	require.NoError(t, m.Record(ctx, 8.5))
+	require.NoError(t, m.Record(ctx, 0.5))
+
+	select {
+	case event := <-events:
+		assert.Equal(t, "budget_alert", event.Event)
+		assert.Equal(t, "day:2025-03-14", event.Period)
+		assert.Equal(t, 80, event.Threshold)
+		assert.Equal(t, "node-1", event.NodeID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+
+	_, mode, err := m.Remaining(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ModeNormal, mode)
+
+	require.NoError(t, m.Record(ctx, 1))
+	_, mode, err = m.Remaining(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ModeBlock, mode)
+
+	select {
+	case event := <-events:
+		t.Fatalf("webhook fired twice: %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestOpenStoreRejectsUnknownScheme(t *testing.T) {
+	_, err := OpenStore("postgres://localhost/costs")
+	assert.Error(t, err)
+}
@@ -0,0 +1,223 @@
+package budget
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Store persists spend per budget period and the alerts fired in each period.
+// Periods are keys such as "day:2025-01-31" and "month:2025-01".
+type Store interface {
+	// AddSpend adds amount to every given period
+	AddSpend(ctx context.Context, periods []string, amount float64) error
+	// Spend returns the total spend recorded for a period
+	Spend(ctx context.Context, period string) (float64, error)
+	// RecordAlert marks a threshold as fired for a period. It returns false if it already was.
+	RecordAlert(ctx context.Context, period string, threshold int) (bool, error)
+	// Alerts returns the thresholds fired for a period
+	Alerts(ctx context.Context, period string) ([]int, error)
+	Close() error
+}
+
+// OpenStore opens the store for a budget.cost_db setting. An empty DSN keeps
+// spend in memory only. SQLite DSNs look like "sqlite:///data/costs.db".
+func OpenStore(dsn string) (Store, error) {
+	if dsn == "" {
+		return NewMemoryStore(), nil
+	}
+
+	path := dsn
+	if strings.Contains(dsn, "://") {
+		scheme, rest, _ := strings.Cut(dsn, "://")
+		if scheme != "sqlite" && scheme != "sqlite3" {
+			return nil, fmt.Errorf("unsupported cost_db scheme %q", scheme)
+		}
+		path = rest
+	}
+
+	return NewSQLiteStore(path)
+}
+
+// MemoryStore is a Store that does not survive restarts
+type MemoryStore struct {
+	mu     sync.Mutex
+	spend  map[string]float64
+	alerts map[string][]int
+}
+
+// NewMemoryStore creates an in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		spend:  make(map[string]float64),
+		alerts: make(map[string][]int),
+	}
+}
+
+// AddSpend adds amount to every given period
+func (s *MemoryStore) AddSpend(ctx context.Context, periods []string, amount float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, period := range periods {
+		s.spend[period] += amount
+	}
+	return nil
+}
+
+// Spend returns the total spend recorded for a period
+func (s *MemoryStore) Spend(ctx context.Context, period string) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spend[period], nil
+}
+
+// RecordAlert marks a threshold as fired for a period
+func (s *MemoryStore) RecordAlert(ctx context.Context, period string, threshold int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.alerts[period] {
+		if t == threshold {
+			return false, nil
+		}
+	}
+	s.alerts[period] = append(s.alerts[period], threshold)
+	return true, nil
+}
+
+// Alerts returns the thresholds fired for a period
+func (s *MemoryStore) Alerts(ctx context.Context, period string) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.alerts[period]...), nil
+}
+
+// Close is a no-op
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// SQLiteStore is a Store backed by a SQLite database file
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (and if needed creates) a SQLite cost database
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite path is required")
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cost db directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("unable to open cost db: %w", err)
+	}
+	// SQLite allows a single writer; serialize through one connection
+	db.SetMaxOpenConns(1)
+
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS aiproxy_spend (
+			period TEXT PRIMARY KEY,
+			amount REAL NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS aiproxy_budget_alerts (
+			period TEXT NOT NULL,
+			threshold INTEGER NOT NULL,
+			fired_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (period, threshold)
+		)`,
+	}
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to migrate cost db: %w", err)
+		}
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// AddSpend adds amount to every given period in a single transaction
+func (s *SQLiteStore) AddSpend(ctx context.Context, periods []string, amount float64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, period := range periods {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO aiproxy_spend (period, amount) VALUES (?, ?)
+			 ON CONFLICT(period) DO UPDATE SET amount = amount + excluded.amount, updated_at = CURRENT_TIMESTAMP`,
+			period, amount)
+		if err != nil {
+			return fmt.Errorf("failed to record spend: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Spend returns the total spend recorded for a period
+func (s *SQLiteStore) Spend(ctx context.Context, period string) (float64, error) {
+	var amount float64
+	err := s.db.QueryRowContext(ctx, `SELECT amount FROM aiproxy_spend WHERE period = ?`, period).Scan(&amount)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read spend: %w", err)
+	}
+	return amount, nil
+}
+
+// RecordAlert marks a threshold as fired for a period
+func (s *SQLiteStore) RecordAlert(ctx context.Context, period string, threshold int) (bool, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO aiproxy_budget_alerts (period, threshold) VALUES (?, ?)`,
+		period, threshold)
+	if err != nil {
+		return false, fmt.Errorf("failed to record alert: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Alerts returns the thresholds fired for a period
+func (s *SQLiteStore) Alerts(ctx context.Context, period string) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT threshold FROM aiproxy_budget_alerts WHERE period = ? ORDER BY threshold`, period)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alerts: %w", err)
+	}
+	defer rows.Close()
+
+	var thresholds []int
+	for rows.Next() {
+		var t int
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, rows.Err()
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,60 @@
+package router
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/aiserve/gpuproxy/internal/mesh"
+	"github.com/aiserve/gpuproxy/internal/providers"
+)
+
+// meshBackend serves forwarded requests and reports local state for the mesh
+type meshBackend struct {
+	router *Router
+}
+
+// Predict routes a request forwarded by a peer through this node's providers
+func (b meshBackend) Predict(ctx context.Context, req *providers.PredictRequest) (*providers.PredictResponse, error) {
+	resp, _, err := b.router.Predict(ctx, req)
+	return resp, err
+}
+
+// LocalState reports this node's own providers. The mesh provider is left out
+// so nodes only advertise models they serve themselves.
+func (b meshBackend) LocalState(ctx context.Context) mesh.LocalState {
+	state := mesh.LocalState{
+		Providers: make(map[string]mesh.ProviderState),
+		InFlight:  atomic.LoadInt64(&b.router.inflight),
+	}
+
+	for name, provider := range b.router.providers {
+		if name == mesh.ProviderName {
+			continue
+		}
+
+		models := provider.GetModels()
+		cost := make(map[string]float64, len(models))
+		for _, model := range models {
+			cost[model] = provider.GetCostPer1kTokens(model)
+		}
+
+		state.Providers[name] = mesh.ProviderState{
+			Available: provider.IsAvailable(ctx),
+			Models:    models,
+			Cost:      cost,
+		}
+	}
+
+	return state
+}
+
+// spillOver reports whether a provider should only be routed to when no local
+// provider can serve the request
+func spillOver(name string) bool {
+	return name == mesh.ProviderName
+}
+
+// Mesh returns the mesh node, or nil when mesh networking is disabled
+func (r *Router) Mesh() *mesh.Node {
+	return r.mesh
+}
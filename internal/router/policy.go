@@ -0,0 +1,280 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/providers"
+	"github.com/aiserve/gpuproxy/internal/router/policy"
+)
+
+// compiledPolicy is a routing policy with parsed rules
+type compiledPolicy struct {
+	name  string
+	rules []*policy.Rule
+}
+
+// compilePolicies parses the configured routing policies
+func compilePolicies(cfgs []config.RoutingPolicy) ([]compiledPolicy, error) {
+	compiled := make([]compiledPolicy, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		p := compiledPolicy{name: cfg.Name}
+		for j, rule := range cfg.Rules {
+			rule, err := policy.CompileRule(rule.If, rule.Then, rule.Else)
+			if err != nil {
+				return nil, fmt.Errorf("routing.policies[%d].rules[%d]: %w", i, j, err)
+			}
+			p.rules = append(p.rules, rule)
+		}
+		compiled = append(compiled, p)
+	}
+	return compiled, nil
+}
+
+// routeCandidate is an available provider that serves the requested model
+type routeCandidate struct {
+	name     string
+	provider providers.Provider
+	key      float64
+}
+
+// routeByPolicy applies the first policy rule that selects an action. It
+// returns false when no rule applies so the configured strategy is used.
+func (r *Router) routeByPolicy(ctx context.Context, req *providers.PredictRequest) (*RoutingDecision, bool, error) {
+	attrs := r.policyAttributes(ctx, req)
+
+	for _, p := range r.policies {
+		for i, rule := range p.rules {
+			action, branch, err := rule.Evaluate(attrs)
+			if err != nil {
+				log.Printf("Warning: routing policy %s rule %d skipped: %v", p.name, i+1, err)
+				continue
+			}
+			if action == nil {
+				continue
+			}
+
+			reason := fmt.Sprintf("policy %s rule %d: %s", p.name, i+1, action)
+			if rule.If != nil {
+				reason = fmt.Sprintf("policy %s rule %d: if %s %s %s", p.name, i+1, rule.If, branch, action)
+			}
+
+			decision, err := r.applyPolicyAction(ctx, req, action)
+			if err != nil {
+				return nil, true, fmt.Errorf("%w (%s)", err, reason)
+			}
+			if decision.Reason != "" {
+				reason += " -> " + decision.Reason
+			}
+			decision.Reason = reason
+			return decision, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// applyPolicyAction orders or filters the candidate providers as directed by a rule action
+func (r *Router) applyPolicyAction(ctx context.Context, req *providers.PredictRequest, action *policy.Action) (*RoutingDecision, error) {
+	if action.Kind == policy.ActionStrategy {
+		return r.routeByStrategy(ctx, req, action.Strategy)
+	}
+
+	candidates := r.routeCandidates(ctx, req.Model)
+
+	switch action.Kind {
+	case policy.ActionOrder:
+		for i := range candidates {
+			candidates[i].key = r.orderKey(candidates[i], req.Model, action.OrderBy)
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if si, sj := spillOver(candidates[i].name), spillOver(candidates[j].name); si != sj {
+				return sj
+			}
+			if action.Descending {
+				return candidates[i].key > candidates[j].key
+			}
+			return candidates[i].key < candidates[j].key
+		})
+
+	case policy.ActionPrefer:
+		rank := make(map[string]int, len(action.Providers))
+		for i, name := range action.Providers {
+			rank[name] = i + 1
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			ri, rj := rank[candidates[i].name], rank[candidates[j].name]
+			if ri == 0 || rj == 0 {
+				return ri != 0 && rj == 0
+			}
+			return ri < rj
+		})
+
+	case policy.ActionRequire, policy.ActionAvoid:
+		listed := make(map[string]bool, len(action.Providers))
+		for _, name := range action.Providers {
+			listed[name] = true
+		}
+		keep := action.Kind == policy.ActionRequire
+		filtered := candidates[:0]
+		for _, c := range candidates {
+			if listed[c.name] == keep {
+				filtered = append(filtered, c)
+			}
+		}
+		candidates = filtered
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w for model %s", ErrNoAvailableProvider, req.Model)
+	}
+
+	alternatives := make([]string, 0, len(candidates)-1)
+	for _, c := range candidates[1:] {
+		alternatives = append(alternatives, c.name)
+	}
+
+	best := candidates[0]
+	return &RoutingDecision{
+		Provider:      best.name,
+		Model:         req.Model,
+		Alternatives:  alternatives,
+		EstimatedCost: best.provider.GetCostPer1kTokens(req.Model) * float64(estimateRequestTokens(req)) / 1000.0,
+		constrained:   true,
+	}, nil
+}
+
+// routeCandidates returns the available providers serving a model, ordered by
+// priority then name with mesh peers last
+func (r *Router) routeCandidates(ctx context.Context, model string) []routeCandidate {
+	var candidates []routeCandidate
+	for name, provider := range r.providers {
+		if !provider.IsAvailable(ctx) {
+			continue
+		}
+		for _, m := range provider.GetModels() {
+			if m == model {
+				candidates = append(candidates, routeCandidate{name: name, provider: provider})
+				break
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if si, sj := spillOver(candidates[i].name), spillOver(candidates[j].name); si != sj {
+			return sj
+		}
+		pi, pj := candidates[i].provider.Priority(), candidates[j].provider.Priority()
+		if pi != pj {
+			return pi < pj
+		}
+		return candidates[i].name < candidates[j].name
+	})
+	return candidates
+}
+
+// orderKey returns the value an ActionOrder sorts a candidate by
+func (r *Router) orderKey(c routeCandidate, model, key string) float64 {
+	switch key {
+	case "priority":
+		return float64(c.provider.Priority())
+	case "cost_per_1k_tokens":
+		return c.provider.GetCostPer1kTokens(model)
+	case "latency_ms":
+		return r.averageLatency(c.name)
+	case "error_rate":
+		return r.errorRate(c.name)
+	}
+	return 0
+}
+
+// policyAttributes resolves the attributes available to routing policy conditions
+func (r *Router) policyAttributes(ctx context.Context, req *providers.PredictRequest) policy.Attributes {
+	now := r.now()
+
+	return func(name string) (interface{}, bool) {
+		switch name {
+		case "request.model":
+			return req.Model, true
+		case "request.tokens":
+			return float64(estimateRequestTokens(req)), true
+		case "request.max_tokens":
+			return float64(req.MaxTokens), true
+		case "request.user_tier":
+			return req.UserTier, true
+		case "request.capability":
+			return req.Capability, true
+		case "request.stream":
+			return req.Stream, true
+		case "time.hour":
+			return float64(now.Hour()), true
+		case "time.weekday":
+			return strings.ToLower(now.Weekday().String()[:3]), true
+		}
+
+		// provider.<name>.<attribute>
+		parts := strings.Split(name, ".")
+		if len(parts) != 3 || parts[0] != "provider" {
+			return nil, false
+		}
+		provider, ok := r.providers[parts[1]]
+		if !ok {
+			return nil, false
+		}
+
+		switch parts[2] {
+		case "healthy":
+			if health, ok := r.lastHealth(parts[1]); ok {
+				return health.Healthy, true
+			}
+			return provider.IsAvailable(ctx), true
+		case "available":
+			return provider.IsAvailable(ctx), true
+		case "error_rate":
+			return r.errorRate(parts[1]), true
+		case "latency_ms":
+			return r.averageLatency(parts[1]), true
+		}
+		return nil, false
+	}
+}
+
+// lastHealth returns the most recent health probe result for a provider
+func (r *Router) lastHealth(name string) (providers.ProviderHealth, bool) {
+	r.healthMu.RLock()
+	defer r.healthMu.RUnlock()
+	health, ok := r.health[name]
+	return health, ok
+}
+
+// averageLatency returns a provider's average observed latency, defaulting to one second
+func (r *Router) averageLatency(name string) float64 {
+	r.stats.mu.RLock()
+	defer r.stats.mu.RUnlock()
+
+	latencies := r.stats.ProviderLatency[name]
+	if len(latencies) == 0 {
+		return 1000.0
+	}
+	sum := 0
+	for _, l := range latencies {
+		sum += l
+	}
+	return float64(sum) / float64(len(latencies))
+}
+
+// errorRate returns the fraction of a provider's requests that failed
+func (r *Router) errorRate(name string) float64 {
+	r.stats.mu.RLock()
+	defer r.stats.mu.RUnlock()
+
+	requests := r.stats.ProviderRequests[name]
+	if requests == 0 {
+		return 0
+	}
+	return float64(r.stats.ProviderErrors[name]) / float64(requests)
+}
@@ -0,0 +1,183 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ActionKind identifies what a rule's "then" or "else" does
+type ActionKind int
+
+const (
+	// ActionOrder sorts candidate providers by an attribute: "provider.cost_per_1k_tokens ASC"
+	ActionOrder ActionKind = iota
+	// ActionPrefer tries providers in the given order first: "prefer local > cloudflare"
+	ActionPrefer
+	// ActionRequire restricts candidates to the listed providers: "require local, cloudflare"
+	ActionRequire
+	// ActionAvoid removes the listed providers from the candidates: "avoid openai"
+	ActionAvoid
+	// ActionStrategy routes with one of the built-in strategies: "strategy latency_optimized"
+	ActionStrategy
+)
+
+// OrderKeys are the provider attributes an ActionOrder can sort by
+var OrderKeys = map[string]bool{
+	"priority":           true,
+	"cost_per_1k_tokens": true,
+	"latency_ms":         true,
+	"error_rate":         true,
+}
+
+// Strategies are the built-in routing strategies an ActionStrategy can select
+var Strategies = map[string]bool{
+	"cost_optimized":    true,
+	"latency_optimized": true,
+	"availability":      true,
+	"round_robin":       true,
+}
+
+// Action is a parsed rule action
+type Action struct {
+	Kind       ActionKind
+	OrderBy    string   // ActionOrder
+	Descending bool     // ActionOrder
+	Providers  []string // ActionPrefer, ActionRequire, ActionAvoid
+	Strategy   string   // ActionStrategy
+
+	source string
+}
+
+// String returns the action source
+func (a *Action) String() string {
+	return a.source
+}
+
+// ParseAction parses a rule action
+func ParseAction(src string) (*Action, error) {
+	fields := strings.Fields(src)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("action is empty")
+	}
+
+	action := &Action{source: strings.Join(fields, " ")}
+
+	switch fields[0] {
+	case "prefer", "require", "avoid":
+		sep := ","
+		switch fields[0] {
+		case "prefer":
+			action.Kind = ActionPrefer
+			sep = ">"
+		case "require":
+			action.Kind = ActionRequire
+		case "avoid":
+			action.Kind = ActionAvoid
+		}
+
+		rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(src), fields[0]))
+		for _, name := range strings.Split(rest, sep) {
+			name = strings.TrimPrefix(strings.TrimSpace(name), "provider.")
+			if !validProviderName(name) {
+				return nil, fmt.Errorf("%s: invalid provider name %q", fields[0], name)
+			}
+			action.Providers = append(action.Providers, name)
+		}
+		return action, nil
+
+	case "strategy":
+		if len(fields) != 2 || !Strategies[fields[1]] {
+			return nil, fmt.Errorf("strategy must be one of cost_optimized, latency_optimized, availability or round_robin")
+		}
+		action.Kind = ActionStrategy
+		action.Strategy = fields[1]
+		return action, nil
+	}
+
+	// provider.<key> [ASC|DESC]
+	if len(fields) > 2 {
+		return nil, fmt.Errorf("unknown action %q", src)
+	}
+	key := strings.TrimPrefix(fields[0], "provider.")
+	if !OrderKeys[key] {
+		return nil, fmt.Errorf("unknown action %q: cannot order providers by %q", src, key)
+	}
+	action.Kind = ActionOrder
+	action.OrderBy = key
+	if len(fields) == 2 {
+		switch strings.ToUpper(fields[1]) {
+		case "ASC":
+		case "DESC":
+			action.Descending = true
+		default:
+			return nil, fmt.Errorf("sort direction must be ASC or DESC, got %q", fields[1])
+		}
+	}
+	return action, nil
+}
+
+func validProviderName(name string) bool {
+	if name == "" || !isIdentStart(name[0]) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isIdentPart(name[i]) || name[i] == '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// Rule is a compiled routing rule. A rule without a condition always applies
+// its Then action.
+type Rule struct {
+	If   *Condition
+	Then *Action
+	Else *Action
+}
+
+// CompileRule parses and validates a rule's if/then/else
+func CompileRule(ifSrc, thenSrc, elseSrc string) (*Rule, error) {
+	rule := &Rule{}
+	var err error
+
+	if strings.TrimSpace(ifSrc) != "" {
+		if rule.If, err = ParseCondition(ifSrc); err != nil {
+			return nil, fmt.Errorf("if: %w", err)
+		}
+	} else if elseSrc != "" {
+		return nil, fmt.Errorf("else requires an if condition")
+	}
+
+	if rule.Then, err = ParseAction(thenSrc); err != nil {
+		return nil, fmt.Errorf("then: %w", err)
+	}
+
+	if elseSrc != "" {
+		if rule.Else, err = ParseAction(elseSrc); err != nil {
+			return nil, fmt.Errorf("else: %w", err)
+		}
+	}
+
+	return rule, nil
+}
+
+// Evaluate returns the action selected by the rule, or nil when the condition
+// is false and there is no else branch
+func (r *Rule) Evaluate(attrs Attributes) (*Action, string, error) {
+	if r.If == nil {
+		return r.Then, "then", nil
+	}
+
+	matched, err := r.If.Eval(attrs)
+	if err != nil {
+		return nil, "", err
+	}
+	if matched {
+		return r.Then, "then", nil
+	}
+	if r.Else != nil {
+		return r.Else, "else", nil
+	}
+	return nil, "", nil
+}
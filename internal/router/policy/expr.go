@@ -0,0 +1,591 @@
+// Package policy implements the small expression language used by AIProxy
+// routing policies.
+//
+// A rule's "if" is a boolean condition over request attributes:
+//
+//	request.tokens >= 1000 && request.user_tier in ['free', 'trial']
+//	request.model matches 'llama-*' or not provider.local.healthy
+//
+// Conditions support ==, !=, <, <=, >, >=, matches (glob), in [list],
+// and/&&, or/||, not/! and parentheses. Attributes are type checked when the
+// rule is parsed, so malformed rules are rejected when the config is loaded.
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Type is the static type of an expression
+type Type int
+
+const (
+	TypeString Type = iota
+	TypeNumber
+	TypeBool
+	TypeList
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeNumber:
+		return "number"
+	case TypeBool:
+		return "bool"
+	case TypeList:
+		return "list"
+	default:
+		return "unknown"
+	}
+}
+
+// requestAttributes are the request and clock attributes available to conditions
+var requestAttributes = map[string]Type{
+	"request.model":      TypeString,
+	"request.tokens":     TypeNumber, // estimated input + output tokens
+	"request.max_tokens": TypeNumber,
+	"request.user_tier":  TypeString,
+	"request.capability": TypeString,
+	"request.stream":     TypeBool,
+	"time.hour":          TypeNumber, // 0-23, node local time
+	"time.weekday":       TypeString, // mon, tue, ...
+}
+
+// providerAttributes are available per provider as provider.<name>.<attribute>
+var providerAttributes = map[string]Type{
+	"healthy":    TypeBool,
+	"available":  TypeBool,
+	"error_rate": TypeNumber, // 0-1
+	"latency_ms": TypeNumber, // average observed latency
+}
+
+// attributeType returns the type of a known attribute name
+func attributeType(name string) (Type, error) {
+	if t, ok := requestAttributes[name]; ok {
+		return t, nil
+	}
+
+	if strings.HasPrefix(name, "provider.") {
+		parts := strings.Split(name, ".")
+		if len(parts) != 3 || parts[1] == "" {
+			return 0, fmt.Errorf("provider attributes must look like provider.<name>.<attribute>, got %q", name)
+		}
+		if t, ok := providerAttributes[parts[2]]; ok {
+			return t, nil
+		}
+		return 0, fmt.Errorf("unknown provider attribute %q", parts[2])
+	}
+
+	return 0, fmt.Errorf("unknown attribute %q", name)
+}
+
+// Attributes resolves attribute values during evaluation. Values must be
+// string, float64 or bool according to the attribute type.
+type Attributes func(name string) (interface{}, bool)
+
+// Condition is a parsed, type checked boolean expression
+type Condition struct {
+	source string
+	root   node
+}
+
+// String returns the condition source
+func (c *Condition) String() string {
+	return c.source
+}
+
+// Eval evaluates the condition against a set of attributes
+func (c *Condition) Eval(attrs Attributes) (bool, error) {
+	v, err := c.root.eval(attrs)
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+// ParseCondition parses and type checks a boolean condition
+func ParseCondition(src string) (*Condition, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+	}
+	if root.typ() != TypeBool {
+		return nil, fmt.Errorf("condition must be boolean, got %s", root.typ())
+	}
+
+	return &Condition{source: src, root: root}, nil
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{tokLBracket, "[", i})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{tokRBracket, "]", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokComma, ",", i})
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, token{tokString, src[i+1 : i+1+end], i})
+			i += end + 2
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			if _, err := strconv.ParseFloat(src[start:i], 64); err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", src[start:i], start)
+			}
+			tokens = append(tokens, token{tokNumber, src[start:i], start})
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && isIdentPart(src[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, src[start:i], start})
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!"} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(src)}), nil
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '.' || c == '-'
+}
+
+// Parser
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of the given operators or keywords
+func (p *parser) accept(words ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokOp && tok.kind != tokIdent {
+		return "", false
+	}
+	for _, w := range words {
+		if tok.text == w {
+			p.next()
+			return w, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = newLogical("or", left, right); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = newLogical("and", left, right); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.accept("!", "not"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ() != TypeBool {
+			return nil, fmt.Errorf("not requires a boolean operand, got %s", operand.typ())
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	if p.peek().kind == tokLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.kind != tokRParen {
+			return nil, fmt.Errorf("expected ) at position %d, got %s", tok.pos, tok)
+		}
+		return inner, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "matches", "in")
+	if !ok {
+		return left, nil
+	}
+
+	var right node
+	if op == "in" {
+		right, err = p.parseList()
+	} else {
+		right, err = p.parseOperand()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newComparison(op, left, right)
+}
+
+func (p *parser) parseOperand() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		f, _ := strconv.ParseFloat(tok.text, 64)
+		return &literalNode{value: f, t: TypeNumber}, nil
+	case tokString:
+		return &literalNode{value: tok.text, t: TypeString}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			return &literalNode{value: tok.text == "true", t: TypeBool}, nil
+		case "and", "or", "not", "matches", "in":
+			return nil, fmt.Errorf("unexpected keyword %q at position %d", tok.text, tok.pos)
+		}
+		t, err := attributeType(tok.text)
+		if err != nil {
+			return nil, err
+		}
+		return &attributeNode{name: tok.text, t: t}, nil
+	default:
+		return nil, fmt.Errorf("expected a value at position %d, got %s", tok.pos, tok)
+	}
+}
+
+func (p *parser) parseList() (node, error) {
+	if tok := p.next(); tok.kind != tokLBracket {
+		return nil, fmt.Errorf("expected [ after in at position %d, got %s", tok.pos, tok)
+	}
+
+	list := &listNode{}
+	for {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := item.(*literalNode); !ok {
+			return nil, fmt.Errorf("list items must be literals")
+		}
+		if len(list.items) > 0 && item.typ() != list.items[0].typ() {
+			return nil, fmt.Errorf("list items must all have the same type")
+		}
+		list.items = append(list.items, item.(*literalNode))
+
+		tok := p.next()
+		if tok.kind == tokRBracket {
+			return list, nil
+		}
+		if tok.kind != tokComma {
+			return nil, fmt.Errorf("expected , or ] at position %d, got %s", tok.pos, tok)
+		}
+	}
+}
+
+// AST
+
+type node interface {
+	typ() Type
+	eval(attrs Attributes) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+	t     Type
+}
+
+func (n *literalNode) typ() Type { return n.t }
+
+func (n *literalNode) eval(Attributes) (interface{}, error) { return n.value, nil }
+
+type attributeNode struct {
+	name string
+	t    Type
+}
+
+func (n *attributeNode) typ() Type { return n.t }
+
+func (n *attributeNode) eval(attrs Attributes) (interface{}, error) {
+	v, ok := attrs(n.name)
+	if !ok {
+		return nil, fmt.Errorf("attribute %s is not available", n.name)
+	}
+
+	switch n.t {
+	case TypeString:
+		if _, ok := v.(string); ok {
+			return v, nil
+		}
+	case TypeNumber:
+		switch num := v.(type) {
+		case float64:
+			return num, nil
+		case int:
+			return float64(num), nil
+		}
+	case TypeBool:
+		if _, ok := v.(bool); ok {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("attribute %s has type %T, expected %s", n.name, v, n.t)
+}
+
+type listNode struct {
+	items []*literalNode
+}
+
+func (n *listNode) typ() Type { return TypeList }
+
+func (n *listNode) eval(Attributes) (interface{}, error) {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		values[i] = item.value
+	}
+	return values, nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) typ() Type { return TypeBool }
+
+func (n *notNode) eval(attrs Attributes) (interface{}, error) {
+	v, err := n.operand.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	return !v.(bool), nil
+}
+
+type logicalNode struct {
+	op          string // and, or
+	left, right node
+}
+
+func newLogical(op string, left, right node) (node, error) {
+	if left.typ() != TypeBool || right.typ() != TypeBool {
+		return nil, fmt.Errorf("%s requires boolean operands, got %s and %s", op, left.typ(), right.typ())
+	}
+	return &logicalNode{op: op, left: left, right: right}, nil
+}
+
+func (n *logicalNode) typ() Type { return TypeBool }
+
+func (n *logicalNode) eval(attrs Attributes) (interface{}, error) {
+	l, err := n.left.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	// Short-circuit
+	if n.op == "and" && !l.(bool) {
+		return false, nil
+	}
+	if n.op == "or" && l.(bool) {
+		return true, nil
+	}
+	return n.right.eval(attrs)
+}
+
+type comparisonNode struct {
+	op          string
+	left, right node
+}
+
+func newComparison(op string, left, right node) (node, error) {
+	switch op {
+	case "==", "!=":
+		if left.typ() != right.typ() {
+			return nil, fmt.Errorf("cannot compare %s %s %s", left.typ(), op, right.typ())
+		}
+	case "<", "<=", ">", ">=":
+		if left.typ() != TypeNumber || right.typ() != TypeNumber {
+			return nil, fmt.Errorf("%s requires numbers, got %s and %s", op, left.typ(), right.typ())
+		}
+	case "matches":
+		if left.typ() != TypeString || right.typ() != TypeString {
+			return nil, fmt.Errorf("matches requires strings, got %s and %s", left.typ(), right.typ())
+		}
+		lit, ok := right.(*literalNode)
+		if !ok {
+			return nil, fmt.Errorf("matches requires a literal pattern")
+		}
+		if _, err := path.Match(lit.value.(string), ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", lit.value, err)
+		}
+	case "in":
+		list := right.(*listNode)
+		if list.items[0].typ() != left.typ() {
+			return nil, fmt.Errorf("cannot check %s in list of %s", left.typ(), list.items[0].typ())
+		}
+	}
+	return &comparisonNode{op: op, left: left, right: right}, nil
+}
+
+func (n *comparisonNode) typ() Type { return TypeBool }
+
+func (n *comparisonNode) eval(attrs Attributes) (interface{}, error) {
+	l, err := n.left.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return l == r, nil
+	case "!=":
+		return l != r, nil
+	case "<":
+		return l.(float64) < r.(float64), nil
+	case "<=":
+		return l.(float64) <= r.(float64), nil
+	case ">":
+		return l.(float64) > r.(float64), nil
+	case ">=":
+		return l.(float64) >= r.(float64), nil
+	case "matches":
+		matched, _ := path.Match(r.(string), l.(string))
+		return matched, nil
+	case "in":
+		for _, item := range r.([]interface{}) {
+			if item == l {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func attrs(values map[string]interface{}) Attributes {
+	return func(name string) (interface{}, bool) {
+		v, ok := values[name]
+		return v, ok
+	}
+}
+
+func TestParseConditionRejectsMalformedRules(t *testing.T) {
+	cases := map[string]string{
+		"unknown attribute":      "request.size > 10",
+		"type mismatch":          "request.tokens == 'many'",
+		"ordering on strings":    "request.model < 'b'",
+		"matches on numbers":     "request.tokens matches '1*'",
+		"not boolean":            "request.tokens",
+		"unbalanced parens":      "(request.tokens > 1",
+		"trailing tokens":        "request.tokens > 1 2",
+		"unterminated string":    "request.model == 'gpt",
+		"bad provider attribute": "provider.local.uptime > 1",
+		"bad glob":               "request.model matches '[a'",
+		"mixed list":             "request.user_tier in ['free', 1]",
+		"empty":                  "",
+	}
+
+	for name, src := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseCondition(src)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestConditionEval(t *testing.T) {
+	values := attrs(map[string]interface{}{
+		"request.model":          "llama-3-8b",
+		"request.tokens":         1500.0,
+		"request.user_tier":      "free",
+		"time.hour":              22.0,
+		"provider.local.healthy": false,
+	})
+
+	cases := map[string]bool{
+		"request.tokens >= 1000":                                            true,
+		"request.tokens < 1000":                                             false,
+		"request.model matches 'llama-*'":                                   true,
+		"request.user_tier in ['free', 'trial'] and time.hour > 20":         true,
+		"not provider.local.healthy || request.tokens < 10":                 true,
+		"!(request.model == 'llama-3-8b')":                                  false,
+		"request.user_tier != 'free' or (time.hour >= 9 && time.hour < 17)": false,
+	}
+
+	for src, want := range cases {
+		cond, err := ParseCondition(src)
+		require.NoError(t, err, src)
+
+		got, err := cond.Eval(values)
+		require.NoError(t, err, src)
+		assert.Equal(t, want, got, src)
+	}
+}
+
+func TestConditionEvalMissingAttribute(t *testing.T) {
+	cond, err := ParseCondition("provider.openai.healthy")
+	require.NoError(t, err)
+
+	_, err = cond.Eval(attrs(nil))
+	assert.Error(t, err)
+}
+
+func TestParseAction(t *testing.T) {
+	action, err := ParseAction("provider.cost_per_1k_tokens ASC")
+	require.NoError(t, err)
+	assert.Equal(t, ActionOrder, action.Kind)
+	assert.Equal(t, "cost_per_1k_tokens", action.OrderBy)
+	assert.False(t, action.Descending)
+
+	action, err = ParseAction("prefer provider.local > cloudflare")
+	require.NoError(t, err)
+	assert.Equal(t, ActionPrefer, action.Kind)
+	assert.Equal(t, []string{"local", "cloudflare"}, action.Providers)
+
+	action, err = ParseAction("require local, openai")
+	require.NoError(t, err)
+	assert.Equal(t, ActionRequire, action.Kind)
+	assert.Equal(t, []string{"local", "openai"}, action.Providers)
+
+	action, err = ParseAction("strategy latency_optimized")
+	require.NoError(t, err)
+	assert.Equal(t, ActionStrategy, action.Kind)
+
+	for _, src := range []string{"", "provider.size ASC", "provider.priority UP", "prefer", "prefer local >", "strategy fastest"} {
+		_, err := ParseAction(src)
+		assert.Error(t, err, src)
+	}
+}
+
+func TestRuleEvaluate(t *testing.T) {
+	rule, err := CompileRule("request.tokens < 1000", "provider.priority ASC", "avoid openai")
+	require.NoError(t, err)
+
+	action, branch, err := rule.Evaluate(attrs(map[string]interface{}{"request.tokens": 200.0}))
+	require.NoError(t, err)
+	assert.Equal(t, "then", branch)
+	assert.Equal(t, ActionOrder, action.Kind)
+
+	action, branch, err = rule.Evaluate(attrs(map[string]interface{}{"request.tokens": 5000.0}))
+	require.NoError(t, err)
+	assert.Equal(t, "else", branch)
+	assert.Equal(t, ActionAvoid, action.Kind)
+
+	_, err = CompileRule("", "provider.priority ASC", "avoid openai")
+	assert.Error(t, err, "else without if")
+}
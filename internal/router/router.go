@@ -2,21 +2,46 @@ package router
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"aiserve-gpuproxyd/internal/config"
-	"aiserve-gpuproxyd/internal/providers"
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/mesh"
+	"github.com/aiserve/gpuproxy/internal/ml"
+	"github.com/aiserve/gpuproxy/internal/providers"
+	"github.com/aiserve/gpuproxy/internal/router/budget"
+)
+
+var (
+	// ErrNoAvailableProvider is returned when no healthy provider serves the requested model
+	ErrNoAvailableProvider = errors.New("no available providers")
+
+	// ErrAllProvidersFailed is returned when every provider in the failover chain failed
+	ErrAllProvidersFailed = errors.New("all providers failed")
+
+	// ErrBudgetExceeded is returned when no eligible provider fits the remaining budget
+	ErrBudgetExceeded = errors.New("budget exceeded")
 )
 
 // Router handles intelligent routing of AI workloads across providers
 type Router struct {
 	config    *config.AIProxyConfig
 	providers map[string]providers.Provider
+	policies  []compiledPolicy
+	budget    *budget.Manager
+	mesh      *mesh.Node
+	inflight  int64 // requests currently being served, reported to mesh peers
 	stats     *RouterStats
+	now       func() time.Time
 	mu        sync.RWMutex
+
+	healthMu sync.RWMutex
+	health   map[string]providers.ProviderHealth // last CheckHealth results
 }
 
 // RouterStats tracks routing statistics
@@ -36,6 +61,12 @@ type RoutingDecision struct {
 	Reason       string
 	Alternatives []string
 	EstimatedCost float64
+
+	// constrained decisions come from a policy rule and only fail over to Alternatives
+	constrained bool
+	// budgeted decisions only fail over to providers estimated to cost at most costLimit
+	budgeted  bool
+	costLimit float64
 }
 
 // NewRouter creates a new router with configured providers
@@ -52,6 +83,28 @@ func NewRouter(cfg *config.AIProxyConfig) (*Router, error) {
 			ProviderErrors:   make(map[string]int64),
 			ProviderLatency:  make(map[string][]int),
 		},
+		now: time.Now,
+	}
+
+	policies, err := compilePolicies(cfg.Routing.Policies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid routing policy: %w", err)
+	}
+	r.policies = policies
+
+	if cfg.Budget.Enabled {
+		dsn := ""
+		if cfg.Budget.TrackCosts {
+			dsn = cfg.Budget.CostDB
+		}
+		store, err := budget.OpenStore(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open cost db: %w", err)
+		}
+		if r.budget, err = budget.NewManager(cfg.Budget, cfg.Node.ID, store); err != nil {
+			store.Close()
+			return nil, err
+		}
 	}
 
 	// Initialize providers
@@ -64,6 +117,17 @@ func NewRouter(cfg *config.AIProxyConfig) (*Router, error) {
 
 // initializeProviders initializes all configured providers
 func (r *Router) initializeProviders() error {
+	// Initialize local provider
+	if r.config.Providers.Local != nil && r.config.Providers.Local.Enabled {
+		localCfg := r.config.Providers.Local
+		orchestrator := ml.NewRuntimeOrchestrator(localCfg.UseGPU, localCfg.PythonBridgeURL)
+		localProvider, err := providers.NewLocalProvider(context.Background(), localCfg, orchestrator)
+		if err != nil {
+			return fmt.Errorf("failed to initialize local provider: %w", err)
+		}
+		r.providers["local"] = localProvider
+	}
+
 	// Initialize Cloudflare provider
 	if r.config.Providers.Cloudflare != nil && r.config.Providers.Cloudflare.Enabled {
 		cfProvider, err := providers.NewCloudflareProvider(r.config.Providers.Cloudflare)
@@ -73,7 +137,33 @@ func (r *Router) initializeProviders() error {
 		r.providers["cloudflare"] = cfProvider
 	}
 
-	// TODO: Initialize other providers (Local, OpenAI, Anthropic)
+	// Initialize OpenAI provider
+	if r.config.Providers.OpenAI != nil && r.config.Providers.OpenAI.Enabled {
+		oaProvider, err := providers.NewOpenAIProvider(r.config.Providers.OpenAI)
+		if err != nil {
+			return fmt.Errorf("failed to initialize openai provider: %w", err)
+		}
+		r.providers["openai"] = oaProvider
+	}
+
+	// Initialize Anthropic provider
+	if r.config.Providers.Anthropic != nil && r.config.Providers.Anthropic.Enabled {
+		anProvider, err := providers.NewAnthropicProvider(r.config.Providers.Anthropic)
+		if err != nil {
+			return fmt.Errorf("failed to initialize anthropic provider: %w", err)
+		}
+		r.providers["anthropic"] = anProvider
+	}
+
+	// Initialize mesh peers
+	if r.config.Node.Mesh.Enabled {
+		node, err := mesh.NewNode(r.config.Node, meshBackend{router: r})
+		if err != nil {
+			return fmt.Errorf("failed to initialize mesh: %w", err)
+		}
+		r.mesh = node
+		r.providers[mesh.ProviderName] = node.Provider()
+	}
 
 	if len(r.providers) == 0 {
 		return fmt.Errorf("no providers initialized")
@@ -82,13 +172,26 @@ func (r *Router) initializeProviders() error {
 	return nil
 }
 
-// Route selects the best provider for a request
+// Route selects the best provider for a request. Routing policies are evaluated
+// first; the configured strategy applies when no policy rule matches. The
+// decision is then checked against the budget.
 func (r *Router) Route(ctx context.Context, req *providers.PredictRequest) (*RoutingDecision, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	strategy := r.config.Routing.Strategy
+	decision, ok, err := r.routeByPolicy(ctx, req)
+	if !ok {
+		decision, err = r.routeByStrategy(ctx, req, r.config.Routing.Strategy)
+	}
+	if err != nil {
+		return nil, err
+	}
 
+	return r.enforceBudget(ctx, req, decision)
+}
+
+// routeByStrategy selects a provider using one of the built-in strategies
+func (r *Router) routeByStrategy(ctx context.Context, req *providers.PredictRequest, strategy string) (*RoutingDecision, error) {
 	switch strategy {
 	case "cost_optimized":
 		return r.routeByCost(ctx, req)
@@ -146,11 +249,14 @@ func (r *Router) routeByCost(ctx context.Context, req *providers.PredictRequest)
 	}
 
 	if len(candidates) == 0 {
-		return nil, fmt.Errorf("no available providers for model %s", req.Model)
+		return nil, fmt.Errorf("%w for model %s", ErrNoAvailableProvider, req.Model)
 	}
 
-	// Sort by cost, then by priority
+	// Sort by cost, then by priority, with mesh peers only as spill-over
 	sort.Slice(candidates, func(i, j int) bool {
+		if si, sj := spillOver(candidates[i].name), spillOver(candidates[j].name); si != sj {
+			return sj
+		}
 		if candidates[i].cost != candidates[j].cost {
 			return candidates[i].cost < candidates[j].cost
 		}
@@ -225,11 +331,14 @@ func (r *Router) routeByLatency(ctx context.Context, req *providers.PredictReque
 	}
 
 	if len(candidates) == 0 {
-		return nil, fmt.Errorf("no available providers for model %s", req.Model)
+		return nil, fmt.Errorf("%w for model %s", ErrNoAvailableProvider, req.Model)
 	}
 
-	// Sort by latency, then by priority
+	// Sort by latency, then by priority, with mesh peers only as spill-over
 	sort.Slice(candidates, func(i, j int) bool {
+		if si, sj := spillOver(candidates[i].name), spillOver(candidates[j].name); si != sj {
+			return sj
+		}
 		if candidates[i].avgLatency != candidates[j].avgLatency {
 			return candidates[i].avgLatency < candidates[j].avgLatency
 		}
@@ -288,11 +397,14 @@ func (r *Router) routeByAvailability(ctx context.Context, req *providers.Predict
 	}
 
 	if len(candidates) == 0 {
-		return nil, fmt.Errorf("no available providers for model %s", req.Model)
+		return nil, fmt.Errorf("%w for model %s", ErrNoAvailableProvider, req.Model)
 	}
 
-	// Sort by priority
+	// Sort by priority, with mesh peers only as spill-over
 	sort.Slice(candidates, func(i, j int) bool {
+		if si, sj := spillOver(candidates[i].name), spillOver(candidates[j].name); si != sj {
+			return sj
+		}
 		return candidates[i].priority < candidates[j].priority
 	})
 
@@ -316,7 +428,7 @@ func (r *Router) routeRoundRobin(ctx context.Context, req *providers.PredictRequ
 	r.stats.mu.RLock()
 	defer r.stats.mu.RUnlock()
 
-	var candidates []string
+	var candidates, spill []string
 	for name, provider := range r.providers {
 		if !provider.IsAvailable(ctx) {
 			continue
@@ -330,13 +442,23 @@ func (r *Router) routeRoundRobin(ctx context.Context, req *providers.PredictRequ
 				break
 			}
 		}
-		if hasModel {
+		if !hasModel {
+			continue
+		}
+		if spillOver(name) {
+			spill = append(spill, name)
+		} else {
 			candidates = append(candidates, name)
 		}
 	}
 
+	// Mesh peers only join the rotation when no local provider can serve the model
+	if len(candidates) == 0 {
+		candidates, spill = spill, nil
+	}
+
 	if len(candidates) == 0 {
-		return nil, fmt.Errorf("no available providers for model %s", req.Model)
+		return nil, fmt.Errorf("%w for model %s", ErrNoAvailableProvider, req.Model)
 	}
 
 	// Sort for deterministic ordering
@@ -352,6 +474,7 @@ func (r *Router) routeRoundRobin(ctx context.Context, req *providers.PredictRequ
 			alternatives = append(alternatives, c)
 		}
 	}
+	alternatives = append(alternatives, spill...)
 
 	return &RoutingDecision{
 		Provider:     selected,
@@ -363,24 +486,23 @@ func (r *Router) routeRoundRobin(ctx context.Context, req *providers.PredictRequ
 
 // Predict routes and executes a prediction request
 func (r *Router) Predict(ctx context.Context, req *providers.PredictRequest) (*providers.PredictResponse, *RoutingDecision, error) {
+	atomic.AddInt64(&r.inflight, 1)
+	defer atomic.AddInt64(&r.inflight, -1)
+
 	// Route the request
 	decision, err := r.Route(ctx, req)
 	if err != nil {
 		return nil, nil, fmt.Errorf("routing failed: %w", err)
 	}
 
-	// Get the provider
-	provider, ok := r.providers[decision.Provider]
-	if !ok {
+	// Make sure the selected provider exists
+	if _, ok := r.providers[decision.Provider]; !ok {
 		return nil, decision, fmt.Errorf("provider %s not found", decision.Provider)
 	}
 
-	// Execute with failover
+	// Execute with failover, always trying the routed provider first
 	var lastErr error
-	fallbackChain := r.config.Routing.Failover.FallbackChain
-	if !r.config.Routing.Failover.Enabled || len(fallbackChain) == 0 {
-		fallbackChain = []string{decision.Provider}
-	}
+	fallbackChain := r.failoverChain(decision, req)
 
 	for attempt := 0; attempt < r.config.Routing.Failover.MaxRetries; attempt++ {
 		for _, providerName := range fallbackChain {
@@ -416,7 +538,250 @@ func (r *Router) Predict(ctx context.Context, req *providers.PredictRequest) (*p
 		}
 	}
 
-	return nil, decision, fmt.Errorf("all providers failed, last error: %w", lastErr)
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no provider in the failover chain was available")
+	}
+
+	return nil, decision, fmt.Errorf("%w, last error: %w", ErrAllProvidersFailed, lastErr)
+}
+
+// PredictStream routes a request and streams token deltas from the selected provider.
+// Providers are failed over exactly like Predict until one of them produces its
+// first chunk; after that the stream is committed and errors end it.
+func (r *Router) PredictStream(ctx context.Context, req *providers.PredictRequest) (<-chan providers.StreamChunk, *RoutingDecision, error) {
+	// Released here on failure, or by forwardStream once a committed stream ends
+	atomic.AddInt64(&r.inflight, 1)
+	committed := false
+	defer func() {
+		if !committed {
+			atomic.AddInt64(&r.inflight, -1)
+		}
+	}()
+
+	decision, err := r.Route(ctx, req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("routing failed: %w", err)
+	}
+
+	if _, ok := r.providers[decision.Provider]; !ok {
+		return nil, decision, fmt.Errorf("provider %s not found", decision.Provider)
+	}
+
+	var lastErr error
+	fallbackChain := r.failoverChain(decision, req)
+
+	for attempt := 0; attempt < r.config.Routing.Failover.MaxRetries; attempt++ {
+		for _, providerName := range fallbackChain {
+			provider, ok := r.providers[providerName]
+			if !ok || !provider.IsAvailable(ctx) {
+				continue
+			}
+
+			r.recordRequest(providerName)
+
+			startTime := time.Now()
+			stream, err := provider.PredictStream(ctx, req)
+			if err != nil {
+				lastErr = err
+				r.recordError(providerName)
+				continue
+			}
+
+			// Nothing has reached the client until the first chunk is forwarded,
+			// so a failure here can still move on to the next provider
+			var first providers.StreamChunk
+			select {
+			case first, ok = <-stream:
+			case <-ctx.Done():
+				drain(stream)
+				return nil, decision, ctx.Err()
+			}
+			if !ok {
+				lastErr = fmt.Errorf("%s stream ended without output", providerName)
+				r.recordError(providerName)
+				continue
+			}
+			if first.Err != nil {
+				drain(stream)
+				lastErr = first.Err
+				r.recordError(providerName)
+				continue
+			}
+
+			committed = true
+			return r.forwardStream(ctx, providerName, startTime, first, stream), decision, nil
+		}
+
+		// Wait before retry
+		if attempt < r.config.Routing.Failover.MaxRetries-1 {
+			time.Sleep(r.config.Routing.Failover.RetryDelay)
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no provider in the failover chain was available")
+	}
+
+	return nil, decision, fmt.Errorf("%w, last error: %w", ErrAllProvidersFailed, lastErr)
+}
+
+// StreamEvent is the native wire format of a streamed prediction, shared by the
+// SSE, WebSocket and gRPC transports
+type StreamEvent struct {
+	Type         string                      `json:"type"` // delta, done or error
+	Delta        string                      `json:"delta,omitempty"`
+	FinishReason string                      `json:"finish_reason,omitempty"`
+	Metadata     *providers.ResponseMetadata `json:"metadata,omitempty"`
+	Routing      *RoutingDecision            `json:"routing,omitempty"`
+	Error        string                      `json:"error,omitempty"`
+}
+
+// EmitStreamEvents converts stream chunks into native events, ending after the
+// terminal chunk. It returns the first emit error, after which the remaining
+// chunks are abandoned.
+func EmitStreamEvents(stream <-chan providers.StreamChunk, decision *RoutingDecision, emit func(StreamEvent) error) error {
+	for chunk := range stream {
+		var event StreamEvent
+		switch {
+		case chunk.Err != nil:
+			event = StreamEvent{Type: "error", Error: chunk.Err.Error()}
+		case chunk.Done:
+			event = StreamEvent{
+				Type:         "done",
+				FinishReason: chunk.FinishReason,
+				Metadata:     chunk.Metadata,
+				Routing:      decision,
+			}
+		default:
+			event = StreamEvent{Type: "delta", Delta: chunk.Delta}
+		}
+
+		if err := emit(event); err != nil {
+			return err
+		}
+		if chunk.Done {
+			return nil
+		}
+	}
+	return nil
+}
+
+// forwardStream relays a committed provider stream to the caller and records
+// latency and cost from its terminal chunk
+func (r *Router) forwardStream(ctx context.Context, providerName string, startTime time.Time, first providers.StreamChunk, stream <-chan providers.StreamChunk) <-chan providers.StreamChunk {
+	out := make(chan providers.StreamChunk)
+
+	go func() {
+		defer close(out)
+		defer atomic.AddInt64(&r.inflight, -1)
+
+		chunk, ok := first, true
+		for ok {
+			if chunk.Done {
+				if chunk.Err != nil {
+					r.recordError(providerName)
+				} else {
+					r.recordLatency(providerName, int(time.Since(startTime).Milliseconds()))
+					if chunk.Metadata != nil {
+						r.recordCost(chunk.Metadata.Cost)
+					}
+				}
+			}
+
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				drain(stream)
+				return
+			}
+
+			chunk, ok = <-stream
+		}
+	}()
+
+	return out
+}
+
+// drain discards the rest of an abandoned provider stream in the
+// background, so that a provider blocked sending to it can finish
+func drain(stream <-chan providers.StreamChunk) {
+	go func() {
+		for range stream {
+		}
+	}()
+}
+
+// failoverChain builds the ordered list of providers to try for a request,
+// starting with the routed provider followed by the configured fallback chain.
+// Decisions constrained by a policy rule fall back to their alternatives instead,
+// and providers the budget can't afford are left out.
+func (r *Router) failoverChain(decision *RoutingDecision, req *providers.PredictRequest) []string {
+	selected := decision.Provider
+	chain := []string{selected}
+	if !r.config.Routing.Failover.Enabled {
+		return chain
+	}
+
+	fallbacks := r.config.Routing.Failover.FallbackChain
+	if decision.constrained {
+		fallbacks = decision.Alternatives
+	}
+
+	for _, name := range fallbacks {
+		if name != selected && r.withinBudget(decision, name, req) {
+			chain = append(chain, name)
+		}
+	}
+
+	return chain
+}
+
+// CheckHealth probes every provider once and returns the results by provider name
+func (r *Router) CheckHealth(ctx context.Context) map[string]providers.ProviderHealth {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	results := make(map[string]providers.ProviderHealth, len(r.providers))
+	for name, provider := range r.providers {
+		results[name] = provider.Health(ctx)
+	}
+
+	r.healthMu.Lock()
+	r.health = results
+	r.healthMu.Unlock()
+
+	return results
+}
+
+// MonitorHealth probes providers every interval until ctx is cancelled, so that
+// IsAvailable reflects upstream outages without probing on the request path
+func (r *Router) MonitorHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		probeCtx, cancel := context.WithTimeout(ctx, interval)
+		r.CheckHealth(probeCtx)
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close releases router resources such as the mesh listener and the cost database
+func (r *Router) Close() error {
+	var errs []error
+	if r.mesh != nil {
+		errs = append(errs, r.mesh.Stop())
+	}
+	if r.budget != nil {
+		errs = append(errs, r.budget.Close())
+	}
+	return errors.Join(errs...)
 }
 
 // GetProvider returns a provider by name
@@ -492,11 +857,18 @@ func (r *Router) recordLatency(provider string, latencyMs int) {
 	r.stats.ProviderLatency[provider] = latencies
 }
 
-// recordCost adds to total cost
+// recordCost adds to total cost and to the budget periods
 func (r *Router) recordCost(cost float64) {
 	r.stats.mu.Lock()
-	defer r.stats.mu.Unlock()
 	r.stats.TotalCost += cost
+	r.stats.mu.Unlock()
+
+	if r.budget != nil {
+		// Not tied to the request context: the cost was incurred even if the client went away
+		if err := r.budget.Record(context.Background(), cost); err != nil {
+			log.Printf("Warning: failed to record spend: %v", err)
+		}
+	}
 }
 
 // estimateRequestTokens estimates the number of tokens in a request
@@ -0,0 +1,367 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aiserve/gpuproxy/internal/config"
+	"github.com/aiserve/gpuproxy/internal/mesh"
+	"github.com/aiserve/gpuproxy/internal/providers"
+	"github.com/aiserve/gpuproxy/internal/router/budget"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider is a scripted providers.Provider for router tests
+type fakeProvider struct {
+	name      string
+	priority  int
+	cost      float64
+	models    []string
+	err       error                   // returned by Predict
+	streamErr error                   // returned by PredictStream before streaming
+	chunks    []providers.StreamChunk // emitted by PredictStream
+	calls     int
+}
+
+func (p *fakeProvider) Name() string                         { return p.name }
+func (p *fakeProvider) Type() string                         { return p.name }
+func (p *fakeProvider) IsAvailable(ctx context.Context) bool { return true }
+func (p *fakeProvider) GetModels() []string                  { return p.models }
+func (p *fakeProvider) Priority() int                        { return p.priority }
+func (p *fakeProvider) GetCostPer1kTokens(model string) float64 {
+	return p.cost
+}
+
+func (p *fakeProvider) Health(ctx context.Context) providers.ProviderHealth {
+	return providers.ProviderHealth{Provider: p.name, Healthy: true, Available: true}
+}
+
+func (p *fakeProvider) Predict(ctx context.Context, req *providers.PredictRequest) (*providers.PredictResponse, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &providers.PredictResponse{Output: "ok", Metadata: providers.ResponseMetadata{Provider: p.name}}, nil
+}
+
+func (p *fakeProvider) PredictStream(ctx context.Context, req *providers.PredictRequest) (<-chan providers.StreamChunk, error) {
+	p.calls++
+	if p.streamErr != nil {
+		return nil, p.streamErr
+	}
+	ch := make(chan providers.StreamChunk, len(p.chunks))
+	for _, chunk := range p.chunks {
+		ch <- chunk
+	}
+	close(ch)
+	return ch, nil
+}
+
+func newTestRouter(fallbackChain []string, ps ...*fakeProvider) *Router {
+	cfg := &config.AIProxyConfig{}
+	cfg.Routing.Strategy = "availability"
+	cfg.Routing.Failover.Enabled = true
+	cfg.Routing.Failover.MaxRetries = 1
+	cfg.Routing.Failover.FallbackChain = fallbackChain
+
+	r := &Router{
+		config:    cfg,
+		providers: make(map[string]providers.Provider),
+		stats: &RouterStats{
+			ProviderRequests: make(map[string]int64),
+			ProviderErrors:   make(map[string]int64),
+			ProviderLatency:  make(map[string][]int),
+		},
+		now: time.Now,
+	}
+	for _, p := range ps {
+		r.providers[p.name] = p
+	}
+	return r
+}
+
+func collect(stream <-chan providers.StreamChunk) []providers.StreamChunk {
+	var chunks []providers.StreamChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func TestRouter_PredictStreamFailsOverBeforeFirstToken(t *testing.T) {
+	primary := &fakeProvider{
+		name:     "cloudflare",
+		priority: 1,
+		models:   []string{"llama"},
+		chunks:   []providers.StreamChunk{{Done: true, Err: errors.New("upstream reset")}},
+	}
+	fallback := &fakeProvider{
+		name:     "openai",
+		priority: 2,
+		models:   []string{"llama"},
+		chunks: []providers.StreamChunk{
+			{Delta: "Hi"},
+			{Done: true, FinishReason: "stop", Metadata: &providers.ResponseMetadata{Provider: "openai", Cost: 0.25}},
+		},
+	}
+	r := newTestRouter([]string{"cloudflare", "openai"}, primary, fallback)
+
+	stream, decision, err := r.PredictStream(context.Background(), &providers.PredictRequest{Model: "llama", Input: "Hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "cloudflare", decision.Provider)
+
+	chunks := collect(stream)
+	require.Len(t, chunks, 2)
+	assert.Equal(t, "Hi", chunks[0].Delta)
+	assert.True(t, chunks[1].Done)
+	assert.Equal(t, "openai", chunks[1].Metadata.Provider)
+
+	stats := r.GetStats()
+	assert.Equal(t, int64(1), stats.ProviderErrors["cloudflare"])
+	assert.Equal(t, int64(1), stats.ProviderRequests["openai"])
+	assert.InDelta(t, 0.25, stats.TotalCost, 1e-9)
+}
+
+func TestRouter_PredictStreamDoesNotFailOverAfterFirstToken(t *testing.T) {
+	primary := &fakeProvider{
+		name:     "cloudflare",
+		priority: 1,
+		models:   []string{"llama"},
+		chunks: []providers.StreamChunk{
+			{Delta: "Hel"},
+			{Done: true, Err: errors.New("connection lost")},
+		},
+	}
+	fallback := &fakeProvider{name: "openai", priority: 2, models: []string{"llama"}}
+	r := newTestRouter([]string{"cloudflare", "openai"}, primary, fallback)
+
+	stream, _, err := r.PredictStream(context.Background(), &providers.PredictRequest{Model: "llama", Input: "Hi"})
+	require.NoError(t, err)
+
+	chunks := collect(stream)
+	require.Len(t, chunks, 2)
+	assert.Equal(t, "Hel", chunks[0].Delta)
+	assert.EqualError(t, chunks[1].Err, "connection lost")
+	assert.Equal(t, 0, fallback.calls)
+}
+
+func TestRouter_PredictStreamAllProvidersFailed(t *testing.T) {
+	primary := &fakeProvider{name: "cloudflare", priority: 1, models: []string{"llama"}, streamErr: errors.New("status 500")}
+	fallback := &fakeProvider{name: "openai", priority: 2, models: []string{"llama"}}
+	r := newTestRouter([]string{"cloudflare", "openai"}, primary, fallback)
+
+	_, _, err := r.PredictStream(context.Background(), &providers.PredictRequest{Model: "llama", Input: "Hi"})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrAllProvidersFailed)
+	assert.Equal(t, 1, primary.calls)
+	assert.Equal(t, 1, fallback.calls)
+
+	_, _, err = r.PredictStream(context.Background(), &providers.PredictRequest{Model: "unknown", Input: "Hi"})
+	assert.ErrorIs(t, err, ErrNoAvailableProvider)
+}
+
+func TestRouter_PredictFailsOverWhenLocalSaturated(t *testing.T) {
+	// The local provider passed IsAvailable but filled up before dispatch
+	local := &fakeProvider{name: "local", priority: 1, models: []string{"llama"}, err: providers.ErrLocalSaturated}
+	fallback := &fakeProvider{name: "openai", priority: 2, models: []string{"llama"}}
+	r := newTestRouter([]string{"openai"}, local, fallback)
+
+	resp, decision, err := r.Predict(context.Background(), &providers.PredictRequest{Model: "llama", Input: "Hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "local", decision.Provider)
+	assert.Equal(t, "openai", resp.Metadata.Provider)
+	assert.Equal(t, int64(1), r.GetStats().ProviderErrors["local"])
+}
+
+func TestRouteMeshPeersOnlySpillOver(t *testing.T) {
+	peer := &fakeProvider{name: mesh.ProviderName, priority: 0, cost: 0, models: []string{"m", "remote-only"}}
+	openai := &fakeProvider{name: "openai", priority: 2, cost: 1, models: []string{"m"}}
+	local := &fakeProvider{name: "local", priority: 1, models: []string{"m"}}
+	r := newTestRouter(nil, peer, openai, local)
+	ctx := context.Background()
+
+	for _, strategy := range []string{"cost_optimized", "latency_optimized", "availability", "round_robin"} {
+		decision, err := r.routeByStrategy(ctx, &providers.PredictRequest{Model: "m"}, strategy)
+		require.NoError(t, err, strategy)
+		assert.NotEqual(t, mesh.ProviderName, decision.Provider, strategy)
+		assert.Equal(t, mesh.ProviderName, decision.Alternatives[len(decision.Alternatives)-1], strategy)
+
+		decision, err = r.routeByStrategy(ctx, &providers.PredictRequest{Model: "remote-only"}, strategy)
+		require.NoError(t, err, strategy)
+		assert.Equal(t, mesh.ProviderName, decision.Provider, "peers take models no local provider serves")
+	}
+
+	candidates := r.routeCandidates(ctx, "m")
+	assert.Equal(t, mesh.ProviderName, candidates[len(candidates)-1].name)
+}
+
+func TestRoutePolicyRules(t *testing.T) {
+	local := &fakeProvider{name: "local", priority: 1, models: []string{"m"}}
+	cheap := &fakeProvider{name: "cloudflare", priority: 2, cost: 0.01, models: []string{"m"}}
+	pricey := &fakeProvider{name: "openai", priority: 3, cost: 0.5, models: []string{"m"}}
+	r := newTestRouter(nil, local, cheap, pricey)
+
+	policies, err := compilePolicies([]config.RoutingPolicy{{
+		Name: "tiers",
+		Rules: []config.RoutingRule{
+			{If: "request.user_tier == 'premium'", Then: "prefer openai > cloudflare"},
+			{If: "request.tokens >= 1000", Then: "provider.cost_per_1k_tokens DESC", Else: "avoid local"},
+		},
+	}})
+	require.NoError(t, err)
+	r.policies = policies
+
+	decision, err := r.Route(context.Background(), &providers.PredictRequest{Model: "m", UserTier: "premium"})
+	require.NoError(t, err)
+	assert.Equal(t, "openai", decision.Provider)
+	assert.Equal(t, []string{"cloudflare", "local"}, decision.Alternatives)
+	assert.Equal(t, "policy tiers rule 1: if request.user_tier == 'premium' then prefer openai > cloudflare", decision.Reason)
+
+	decision, err = r.Route(context.Background(), &providers.PredictRequest{Model: "m", MaxTokens: 2000})
+	require.NoError(t, err)
+	assert.Equal(t, "openai", decision.Provider)
+	assert.Contains(t, decision.Reason, "rule 2: if request.tokens >= 1000 then")
+
+	req := &providers.PredictRequest{Model: "m", MaxTokens: 10}
+	decision, err = r.Route(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "cloudflare", decision.Provider)
+	assert.Equal(t, []string{"openai"}, decision.Alternatives)
+	assert.Contains(t, decision.Reason, "else avoid local")
+	assert.Equal(t, []string{"cloudflare", "openai"}, r.failoverChain(decision, req))
+}
+
+func TestRoutePolicyFallsBackToStrategy(t *testing.T) {
+	local := &fakeProvider{name: "local", priority: 1, models: []string{"m"}}
+	r := newTestRouter(nil, local)
+
+	policies, err := compilePolicies([]config.RoutingPolicy{{
+		Name:  "night",
+		Rules: []config.RoutingRule{{If: "request.model matches 'llama-*'", Then: "require openai"}},
+	}})
+	require.NoError(t, err)
+	r.policies = policies
+
+	decision, err := r.Route(context.Background(), &providers.PredictRequest{Model: "m"})
+	require.NoError(t, err)
+	assert.Equal(t, "local", decision.Provider)
+	assert.Equal(t, "highest_priority_available", decision.Reason)
+
+	// A matching require rule with no eligible provider fails instead of ignoring the policy
+	local.models = []string{"llama-3"}
+	_, err = r.Route(context.Background(), &providers.PredictRequest{Model: "llama-3"})
+	assert.ErrorIs(t, err, ErrNoAvailableProvider)
+}
+
+func TestCompilePoliciesRejectsMalformedRules(t *testing.T) {
+	_, err := compilePolicies([]config.RoutingPolicy{{
+		Name:  "bad",
+		Rules: []config.RoutingRule{{If: "request.tokens >", Then: "provider.priority ASC"}},
+	}})
+	assert.Error(t, err)
+}
+
+func TestRouteBudgetDowngradesAndRejects(t *testing.T) {
+	local := &fakeProvider{name: "local", priority: 3, models: []string{"m"}}
+	openai := &fakeProvider{name: "openai", priority: 1, cost: 1, models: []string{"m"}}
+	r := newTestRouter(nil, local, openai)
+
+	cfg := config.BudgetConfig{Enabled: true, DailyLimit: 1}
+	var err error
+	r.budget, err = budget.NewManager(cfg, "node-1", budget.NewMemoryStore())
+	require.NoError(t, err)
+
+	// 100 + 500 estimated tokens at $1/1k fit the $1 budget
+	decision, err := r.Route(context.Background(), &providers.PredictRequest{Model: "m"})
+	require.NoError(t, err)
+	assert.Equal(t, "openai", decision.Provider)
+	assert.InDelta(t, 0.6, decision.EstimatedCost, 1e-9)
+
+	r.recordCost(0.6)
+
+	// The same request no longer fits and moves to the free local provider
+	req := &providers.PredictRequest{Model: "m"}
+	decision, err = r.Route(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "local", decision.Provider)
+	assert.Contains(t, decision.Reason, "budget: downgraded from openai")
+	assert.Equal(t, []string{"local"}, r.failoverChain(decision, req))
+
+	// Without a free provider the request is rejected
+	local.models = nil
+	_, err = r.Route(context.Background(), &providers.PredictRequest{Model: "m"})
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+}
+
+func TestPredictFailoverStaysWithinBudget(t *testing.T) {
+	cheap := &fakeProvider{name: "cheap", priority: 1, cost: 1, models: []string{"m"}, err: errors.New("status 503")}
+	pricey := &fakeProvider{name: "pricey", priority: 2, cost: 10, models: []string{"m"}}
+	free := &fakeProvider{name: "free", priority: 3, models: []string{"m"}}
+	r := newTestRouter([]string{"cheap", "pricey", "free"}, cheap, pricey, free)
+
+	var err error
+	r.budget, err = budget.NewManager(config.BudgetConfig{Enabled: true, DailyLimit: 1}, "node-1", budget.NewMemoryStore())
+	require.NoError(t, err)
+
+	// The routed provider fits the $1 budget but fails; the $6 failover doesn't
+	resp, decision, err := r.Predict(context.Background(), &providers.PredictRequest{Model: "m"})
+	require.NoError(t, err)
+	assert.Equal(t, "cheap", decision.Provider)
+	assert.Equal(t, "free", resp.Metadata.Provider)
+	assert.Equal(t, 0, pricey.calls)
+}
+
+// blockingProvider streams its chunks unbuffered, without watching ctx, and
+// closes done once it has sent them all
+type blockingProvider struct {
+	*fakeProvider
+	done chan struct{}
+}
+
+func (p *blockingProvider) PredictStream(ctx context.Context, req *providers.PredictRequest) (<-chan providers.StreamChunk, error) {
+	ch := make(chan providers.StreamChunk)
+	go func() {
+		defer close(p.done)
+		defer close(ch)
+		for _, chunk := range p.chunks {
+			ch <- chunk
+		}
+	}()
+	return ch, nil
+}
+
+func TestRouter_PredictStreamDrainsAbandonedStreams(t *testing.T) {
+	for name, chunks := range map[string][]providers.StreamChunk{
+		"cancelled": {{Delta: "Hel"}, {Delta: "lo"}, {Done: true}},
+		"failed":    {{Done: true, Err: errors.New("upstream reset")}, {Delta: "late"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			provider := &blockingProvider{
+				fakeProvider: &fakeProvider{name: "cloudflare", priority: 1, models: []string{"llama"}, chunks: chunks},
+				done:         make(chan struct{}),
+			}
+			r := newTestRouter(nil, provider.fakeProvider)
+			r.providers["cloudflare"] = provider
+
+			ctx, cancel := context.WithCancel(context.Background())
+			stream, _, err := r.PredictStream(ctx, &providers.PredictRequest{Model: "llama", Input: "Hi"})
+			if name == "cancelled" {
+				require.NoError(t, err)
+				<-stream
+				cancel()
+			} else {
+				assert.ErrorIs(t, err, ErrAllProvidersFailed)
+				cancel()
+			}
+
+			select {
+			case <-provider.done:
+			case <-time.After(time.Second):
+				t.Fatal("provider is still blocked sending to an abandoned stream")
+			}
+		})
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/aiserve/gpuproxy/internal/models"
@@ -16,8 +18,12 @@ const (
 	IONetAPIBaseURL = "https://api.io.net/v1"
 )
 
+// ErrInstanceNotFound is returned when io.net has no instance with the given ID
+var ErrInstanceNotFound = errors.New("instance not found")
+
 type Client struct {
 	apiKey     string
+	baseURL    string
 	httpClient *http.Client
 }
 
@@ -60,15 +66,22 @@ type ListDevicesResponse struct {
 
 func NewClient(apiKey string, timeout time.Duration) *Client {
 	return &Client{
-		apiKey: apiKey,
+		apiKey:  apiKey,
+		baseURL: IONetAPIBaseURL,
 		httpClient: &http.Client{
 			Timeout: timeout,
 		},
 	}
 }
 
+// SetBaseURL points the client at a different API endpoint, such as a
+// regional mirror or a test server
+func (c *Client) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
 func (c *Client) ListInstances(ctx context.Context) ([]models.GPUInstance, error) {
-	url := fmt.Sprintf("%s/devices", IONetAPIBaseURL)
+	url := fmt.Sprintf("%s/devices", c.baseURL)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -126,7 +139,7 @@ func (c *Client) ListInstances(ctx context.Context) ([]models.GPUInstance, error
 }
 
 func (c *Client) CreateInstance(ctx context.Context, deviceID string, config map[string]interface{}) (string, error) {
-	url := fmt.Sprintf("%s/instances", IONetAPIBaseURL)
+	url := fmt.Sprintf("%s/instances", c.baseURL)
 
 	payload := map[string]interface{}{
 		"device_id": deviceID,
@@ -174,7 +187,7 @@ func (c *Client) CreateInstance(ctx context.Context, deviceID string, config map
 }
 
 func (c *Client) DestroyInstance(ctx context.Context, instanceID string) error {
-	url := fmt.Sprintf("%s/instances/%s", IONetAPIBaseURL, instanceID)
+	url := fmt.Sprintf("%s/instances/%s", c.baseURL, instanceID)
 
 	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
 	if err != nil {
@@ -192,6 +205,9 @@ func (c *Client) DestroyInstance(ctx context.Context, instanceID string) error {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
@@ -201,7 +217,7 @@ func (c *Client) DestroyInstance(ctx context.Context, instanceID string) error {
 }
 
 func (c *Client) GetInstanceStatus(ctx context.Context, instanceID string) (string, error) {
-	url := fmt.Sprintf("%s/instances/%s", IONetAPIBaseURL, instanceID)
+	url := fmt.Sprintf("%s/instances/%s", c.baseURL, instanceID)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -219,6 +235,9 @@ func (c *Client) GetInstanceStatus(ctx context.Context, instanceID string) (stri
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
@@ -228,6 +247,9 @@ func (c *Client) GetInstanceStatus(ctx context.Context, instanceID string) (stri
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return "", fmt.Errorf("failed to decode response: %w", err)
 	}
+	if len(result) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
 
 	status, ok := result["status"].(string)
 	if !ok {
@@ -236,3 +258,45 @@ func (c *Client) GetInstanceStatus(ctx context.Context, instanceID string) (stri
 
 	return status, nil
 }
+
+// ListRentedInstances returns the IDs of every instance rented on the account
+func (c *Client) ListRentedInstances(ctx context.Context) ([]string, error) {
+	url := fmt.Sprintf("%s/instances", c.baseURL)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Instances []struct {
+			ID string `json:"instance_id"`
+		} `json:"instances"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	ids := make([]string, 0, len(result.Instances))
+	for _, inst := range result.Instances {
+		ids = append(ids, inst.ID)
+	}
+
+	return ids, nil
+}
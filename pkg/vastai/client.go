@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/aiserve/gpuproxy/internal/models"
@@ -16,8 +18,12 @@ const (
 	VastAPIBaseURL = "https://console.vast.ai/api/v0"
 )
 
+// ErrInstanceNotFound is returned when Vast.ai has no instance with the given contract ID
+var ErrInstanceNotFound = errors.New("instance not found")
+
 type Client struct {
 	apiKey     string
+	baseURL    string
 	httpClient *http.Client
 }
 
@@ -36,6 +42,7 @@ type Instance struct {
 	DirectPortCount int     `json:"direct_port_count"`
 	InternetSpeed   float64 `json:"inet_down"`
 	Score           float64 `json:"score"`
+	Reliability     float64 `json:"reliability2"`
 }
 
 type SearchResponse struct {
@@ -44,15 +51,22 @@ type SearchResponse struct {
 
 func NewClient(apiKey string, timeout time.Duration) *Client {
 	return &Client{
-		apiKey: apiKey,
+		apiKey:  apiKey,
+		baseURL: VastAPIBaseURL,
 		httpClient: &http.Client{
 			Timeout: timeout,
 		},
 	}
 }
 
+// SetBaseURL points the client at a different API endpoint, such as a
+// regional mirror or a test server
+func (c *Client) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
 func (c *Client) ListInstances(ctx context.Context) ([]models.GPUInstance, error) {
-	url := fmt.Sprintf("%s/bundles", VastAPIBaseURL)
+	url := fmt.Sprintf("%s/bundles", c.baseURL)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -103,6 +117,7 @@ func (c *Client) ListInstances(ctx context.Context) ([]models.GPUInstance, error
 				"direct_port_count": offer.DirectPortCount,
 				"internet_speed":    offer.InternetSpeed,
 				"score":             offer.Score,
+				"reliability":       offer.Reliability,
 			},
 		})
 	}
@@ -111,7 +126,7 @@ func (c *Client) ListInstances(ctx context.Context) ([]models.GPUInstance, error
 }
 
 func (c *Client) CreateInstance(ctx context.Context, instanceID string, imageURL string) (string, error) {
-	url := fmt.Sprintf("%s/asks/%s", VastAPIBaseURL, instanceID)
+	url := fmt.Sprintf("%s/asks/%s", c.baseURL, instanceID)
 
 	payload := map[string]interface{}{
 		"image":  imageURL,
@@ -160,7 +175,7 @@ func (c *Client) CreateInstance(ctx context.Context, instanceID string, imageURL
 }
 
 func (c *Client) DestroyInstance(ctx context.Context, contractID string) error {
-	url := fmt.Sprintf("%s/instances/%s", VastAPIBaseURL, contractID)
+	url := fmt.Sprintf("%s/instances/%s", c.baseURL, contractID)
 
 	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
 	if err != nil {
@@ -178,6 +193,9 @@ func (c *Client) DestroyInstance(ctx context.Context, contractID string) error {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, contractID)
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
@@ -185,3 +203,90 @@ func (c *Client) DestroyInstance(ctx context.Context, contractID string) error {
 
 	return nil
 }
+
+// GetInstanceStatus returns the actual status of a rented instance, such as
+// "loading", "running" or "exited"
+func (c *Client) GetInstanceStatus(ctx context.Context, contractID string) (string, error) {
+	url := fmt.Sprintf("%s/instances/%s", c.baseURL, contractID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s", ErrInstanceNotFound, contractID)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Instances *struct {
+			ActualStatus string `json:"actual_status"`
+		} `json:"instances"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.Instances == nil {
+		return "", fmt.Errorf("%w: %s", ErrInstanceNotFound, contractID)
+	}
+
+	return result.Instances.ActualStatus, nil
+}
+
+// ListRentedInstances returns the contract IDs of every instance rented on the
+// account, whatever its state
+func (c *Client) ListRentedInstances(ctx context.Context) ([]string, error) {
+	url := fmt.Sprintf("%s/instances?owner=me", c.baseURL)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Instances []struct {
+			ID int `json:"id"`
+		} `json:"instances"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	ids := make([]string, 0, len(result.Instances))
+	for _, inst := range result.Instances {
+		ids = append(ids, fmt.Sprintf("%d", inst.ID))
+	}
+
+	return ids, nil
+}
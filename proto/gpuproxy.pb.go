@@ -253,6 +253,7 @@ type ListGPUInstancesRequest struct {
 	MinVram       float64                `protobuf:"fixed64,2,opt,name=min_vram,json=minVram,proto3" json:"min_vram,omitempty"`
 	MaxPrice      float64                `protobuf:"fixed64,3,opt,name=max_price,json=maxPrice,proto3" json:"max_price,omitempty"`
 	GpuModel      string                 `protobuf:"bytes,4,opt,name=gpu_model,json=gpuModel,proto3" json:"gpu_model,omitempty"`
+	Query         string                 `protobuf:"bytes,5,opt,name=query,proto3" json:"query,omitempty"` // Offer search, e.g. "vram>=80 gpu in (H100,A100) order by price"
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -315,6 +316,13 @@ func (x *ListGPUInstancesRequest) GetGpuModel() string {
 	return ""
 }
 
+func (x *ListGPUInstancesRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
 type GPUInstance struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -822,12 +830,15 @@ func (x *GetGPUInstanceResponse) GetInstance() *GPUInstance {
 // Proxy Messages
 type ProxyRequestMessage struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Protocol      string                 `protobuf:"bytes,1,opt,name=protocol,proto3" json:"protocol,omitempty"` // "http", "https", "mcp", "openinference"
+	Protocol      string                 `protobuf:"bytes,1,opt,name=protocol,proto3" json:"protocol,omitempty"` // "http", "https", "mcp", "openinference", "aiproxy"
 	TargetUrl     string                 `protobuf:"bytes,2,opt,name=target_url,json=targetUrl,proto3" json:"target_url,omitempty"`
 	Method        string                 `protobuf:"bytes,3,opt,name=method,proto3" json:"method,omitempty"` // HTTP method
 	Headers       map[string]string      `protobuf:"bytes,4,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	Body          []byte                 `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
-	Timeout       int32                  `protobuf:"varint,6,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	Timeout       int32                  `protobuf:"varint,6,opt,name=timeout,proto3" json:"timeout,omitempty"`                                 // seconds
+	CorrelationId string                 `protobuf:"bytes,7,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"` // identifies a request on StreamProxyRequest; generated when empty
+	Cancel        bool                   `protobuf:"varint,8,opt,name=cancel,proto3" json:"cancel,omitempty"`                                   // StreamProxyRequest only: cancel the in-flight request with correlation_id
+	InstanceId    string                 `protobuf:"bytes,9,opt,name=instance_id,json=instanceId,proto3" json:"instance_id,omitempty"`          // Reserved instance behind target_url, for idleness and health tracking
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -904,12 +915,35 @@ func (x *ProxyRequestMessage) GetTimeout() int32 {
 	return 0
 }
 
+func (x *ProxyRequestMessage) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+func (x *ProxyRequestMessage) GetCancel() bool {
+	if x != nil {
+		return x.Cancel
+	}
+	return false
+}
+
+func (x *ProxyRequestMessage) GetInstanceId() string {
+	if x != nil {
+		return x.InstanceId
+	}
+	return ""
+}
+
 type ProxyResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	StatusCode    int32                  `protobuf:"varint,1,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
 	Headers       map[string]string      `protobuf:"bytes,2,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	Body          []byte                 `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
 	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	CorrelationId string                 `protobuf:"bytes,5,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"` // echoes the request's correlation_id
+	Done          bool                   `protobuf:"varint,6,opt,name=done,proto3" json:"done,omitempty"`                                       // StreamProxyRequest only: last response for correlation_id
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -972,6 +1006,20 @@ func (x *ProxyResponse) GetError() string {
 	return ""
 }
 
+func (x *ProxyResponse) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+func (x *ProxyResponse) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
 // Billing Messages
 type CreatePaymentRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -1585,7 +1633,9 @@ func (x *CheckSpendingLimitResponse) GetWouldExceed() []*SpendingWindow {
 // Load Balancing Messages
 type SetLoadBalancerStrategyRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Strategy      string                 `protobuf:"bytes,1,opt,name=strategy,proto3" json:"strategy,omitempty"` // "round_robin", "equal_weighted", "weighted_round_robin", "least_connections", "least_response_time"
+	Strategy      string                 `protobuf:"bytes,1,opt,name=strategy,proto3" json:"strategy,omitempty"`                          // "round_robin", "equal_weighted", "weighted_round_robin", "least_connections", "least_response_time", "consistent_hash", "sticky_session", "p2c"
+	AffinityKey   string                 `protobuf:"bytes,2,opt,name=affinity_key,json=affinityKey,proto3" json:"affinity_key,omitempty"` // Optional: "user", "api_key", "header:<name>" or "field:<name>"
+	Pool          string                 `protobuf:"bytes,3,opt,name=pool,proto3" json:"pool,omitempty"`                                  // Optional: change a named pool instead of the global strategy
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -1627,10 +1677,25 @@ func (x *SetLoadBalancerStrategyRequest) GetStrategy() string {
 	return ""
 }
 
+func (x *SetLoadBalancerStrategyRequest) GetAffinityKey() string {
+	if x != nil {
+		return x.AffinityKey
+	}
+	return ""
+}
+
+func (x *SetLoadBalancerStrategyRequest) GetPool() string {
+	if x != nil {
+		return x.Pool
+	}
+	return ""
+}
+
 type SetLoadBalancerStrategyResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Strategy      string                 `protobuf:"bytes,1,opt,name=strategy,proto3" json:"strategy,omitempty"`
 	Success       bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	AffinityKey   string                 `protobuf:"bytes,3,opt,name=affinity_key,json=affinityKey,proto3" json:"affinity_key,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -1679,9 +1744,17 @@ func (x *SetLoadBalancerStrategyResponse) GetSuccess() bool {
 	return false
 }
 
+func (x *SetLoadBalancerStrategyResponse) GetAffinityKey() string {
+	if x != nil {
+		return x.AffinityKey
+	}
+	return ""
+}
+
 type GetLoadInfoRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // "server", "provider", or "all"
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // "server", "provider", or "all" (the default)
+	Pool          string                 `protobuf:"bytes,2,opt,name=pool,proto3" json:"pool,omitempty"` // Optional: loads as seen by a named pool
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -1723,13 +1796,20 @@ func (x *GetLoadInfoRequest) GetType() string {
 	return ""
 }
 
+func (x *GetLoadInfoRequest) GetPool() string {
+	if x != nil {
+		return x.Pool
+	}
+	return ""
+}
+
 type LoadInfo struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	InstanceId        string                 `protobuf:"bytes,1,opt,name=instance_id,json=instanceId,proto3" json:"instance_id,omitempty"`
 	Provider          string                 `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
 	Connections       int32                  `protobuf:"varint,3,opt,name=connections,proto3" json:"connections,omitempty"`
 	AvgResponseTimeMs float64                `protobuf:"fixed64,4,opt,name=avg_response_time_ms,json=avgResponseTimeMs,proto3" json:"avg_response_time_ms,omitempty"`
-	LoadScore         float64                `protobuf:"fixed64,5,opt,name=load_score,json=loadScore,proto3" json:"load_score,omitempty"`
+	LoadScore         float64                `protobuf:"fixed64,5,opt,name=load_score,json=loadScore,proto3" json:"load_score,omitempty"` // Share of all active connections, from 0 to 1
 	unknownFields     protoimpl.UnknownFields
 	sizeCache         protoimpl.SizeCache
 }
@@ -1860,13 +1940,20 @@ func (x *GetLoadInfoResponse) GetCurrentStrategy() string {
 }
 
 type ReserveGPUsRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Count         int32                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"` // 1-16
-	Provider      string                 `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
-	MinVram       float64                `protobuf:"fixed64,3,opt,name=min_vram,json=minVram,proto3" json:"min_vram,omitempty"`
-	MaxPrice      float64                `protobuf:"fixed64,4,opt,name=max_price,json=maxPrice,proto3" json:"max_price,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Count               int32                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"` // 1-16
+	Provider            string                 `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
+	MinVram             float64                `protobuf:"fixed64,3,opt,name=min_vram,json=minVram,proto3" json:"min_vram,omitempty"`
+	MaxPrice            float64                `protobuf:"fixed64,4,opt,name=max_price,json=maxPrice,proto3" json:"max_price,omitempty"`
+	Gang                bool                   `protobuf:"varint,5,opt,name=gang,proto3" json:"gang,omitempty"` // All-or-nothing: roll back every instance if any member fails
+	SameProvider        bool                   `protobuf:"varint,6,opt,name=same_provider,json=sameProvider,proto3" json:"same_provider,omitempty"`
+	SameRegion          bool                   `protobuf:"varint,7,opt,name=same_region,json=sameRegion,proto3" json:"same_region,omitempty"`
+	SameDatacenter      bool                   `protobuf:"varint,8,opt,name=same_datacenter,json=sameDatacenter,proto3" json:"same_datacenter,omitempty"`
+	ReadyTimeoutSeconds int64                  `protobuf:"varint,9,opt,name=ready_timeout_seconds,json=readyTimeoutSeconds,proto3" json:"ready_timeout_seconds,omitempty"` // Gang members must be running within this; 0 skips the wait
+	AffinityKey         string                 `protobuf:"bytes,10,opt,name=affinity_key,json=affinityKey,proto3" json:"affinity_key,omitempty"`                           // Value of the affinity field when the balancer is keyed on "field:<name>"
+	Pool                string                 `protobuf:"bytes,11,opt,name=pool,proto3" json:"pool,omitempty"`                                                            // Optional: reserve from a named load balancer pool; not with gang
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
 }
 
 func (x *ReserveGPUsRequest) Reset() {
@@ -1927,6 +2014,55 @@ func (x *ReserveGPUsRequest) GetMaxPrice() float64 {
 	return 0
 }
 
+func (x *ReserveGPUsRequest) GetGang() bool {
+	if x != nil {
+		return x.Gang
+	}
+	return false
+}
+
+func (x *ReserveGPUsRequest) GetSameProvider() bool {
+	if x != nil {
+		return x.SameProvider
+	}
+	return false
+}
+
+func (x *ReserveGPUsRequest) GetSameRegion() bool {
+	if x != nil {
+		return x.SameRegion
+	}
+	return false
+}
+
+func (x *ReserveGPUsRequest) GetSameDatacenter() bool {
+	if x != nil {
+		return x.SameDatacenter
+	}
+	return false
+}
+
+func (x *ReserveGPUsRequest) GetReadyTimeoutSeconds() int64 {
+	if x != nil {
+		return x.ReadyTimeoutSeconds
+	}
+	return 0
+}
+
+func (x *ReserveGPUsRequest) GetAffinityKey() string {
+	if x != nil {
+		return x.AffinityKey
+	}
+	return ""
+}
+
+func (x *ReserveGPUsRequest) GetPool() string {
+	if x != nil {
+		return x.Pool
+	}
+	return ""
+}
+
 type ReserveGPUsResponse struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	ReservedInstances []*GPUInstance         `protobuf:"bytes,1,rep,name=reserved_instances,json=reservedInstances,proto3" json:"reserved_instances,omitempty"`
@@ -1987,38 +2123,41 @@ func (x *ReserveGPUsResponse) GetMessage() string {
 	return ""
 }
 
-// CUIC Protocol Messages
-type CUICMessageRequest struct {
-	state          protoimpl.MessageState `protogen:"open.v1"`
-	StreamId       string                 `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
-	MessageId      string                 `protobuf:"bytes,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
-	Version        string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
-	Sender         string                 `protobuf:"bytes,4,opt,name=sender,proto3" json:"sender,omitempty"`
-	Receiver       string                 `protobuf:"bytes,5,opt,name=receiver,proto3" json:"receiver,omitempty"`
-	MessageType    string                 `protobuf:"bytes,6,opt,name=message_type,json=messageType,proto3" json:"message_type,omitempty"` // "stream", "datagram", "request", "response", "control", "heartbeat"
-	Priority       int32                  `protobuf:"varint,7,opt,name=priority,proto3" json:"priority,omitempty"`                         // 0-255, higher = more urgent
-	Timestamp      int64                  `protobuf:"varint,8,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	Payload        []byte                 `protobuf:"bytes,9,opt,name=payload,proto3" json:"payload,omitempty"` // JSON-encoded payload
-	Metadata       map[string]string      `protobuf:"bytes,10,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	CongestionHint string                 `protobuf:"bytes,11,opt,name=congestion_hint,json=congestionHint,proto3" json:"congestion_hint,omitempty"` // "none", "moderate", "severe"
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+// Advance Booking Messages
+type Booking struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Id                 string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status             string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // "scheduled", "active", "completed", "canceled", "failed"
+	Provider           string                 `protobuf:"bytes,3,opt,name=provider,proto3" json:"provider,omitempty"`
+	ComputeType        string                 `protobuf:"bytes,4,opt,name=compute_type,json=computeType,proto3" json:"compute_type,omitempty"` // "gpu" or "tpu"
+	GpuModel           string                 `protobuf:"bytes,5,opt,name=gpu_model,json=gpuModel,proto3" json:"gpu_model,omitempty"`
+	Count              int32                  `protobuf:"varint,6,opt,name=count,proto3" json:"count,omitempty"`
+	StartTime          int64                  `protobuf:"varint,7,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"` // Unix seconds
+	EndTime            int64                  `protobuf:"varint,8,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	ProvisionAt        int64                  `protobuf:"varint,9,opt,name=provision_at,json=provisionAt,proto3" json:"provision_at,omitempty"` // When the reservation is made
+	CancellationPolicy string                 `protobuf:"bytes,10,opt,name=cancellation_policy,json=cancellationPolicy,proto3" json:"cancellation_policy,omitempty"`
+	EstimatedCost      float64                `protobuf:"fixed64,11,opt,name=estimated_cost,json=estimatedCost,proto3" json:"estimated_cost,omitempty"`
+	CancellationFee    float64                `protobuf:"fixed64,12,opt,name=cancellation_fee,json=cancellationFee,proto3" json:"cancellation_fee,omitempty"`
+	ReservationId      string                 `protobuf:"bytes,13,opt,name=reservation_id,json=reservationId,proto3" json:"reservation_id,omitempty"`
+	FailureReason      string                 `protobuf:"bytes,14,opt,name=failure_reason,json=failureReason,proto3" json:"failure_reason,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
-func (x *CUICMessageRequest) Reset() {
-	*x = CUICMessageRequest{}
+func (x *Booking) Reset() {
+	*x = Booking{}
 	mi := &file_proto_gpuproxy_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CUICMessageRequest) String() string {
+func (x *Booking) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CUICMessageRequest) ProtoMessage() {}
+func (*Booking) ProtoMessage() {}
 
-func (x *CUICMessageRequest) ProtoReflect() protoreflect.Message {
+func (x *Booking) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_gpuproxy_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -2030,119 +2169,141 @@ func (x *CUICMessageRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CUICMessageRequest.ProtoReflect.Descriptor instead.
-func (*CUICMessageRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use Booking.ProtoReflect.Descriptor instead.
+func (*Booking) Descriptor() ([]byte, []int) {
 	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{32}
 }
 
-func (x *CUICMessageRequest) GetStreamId() string {
+func (x *Booking) GetId() string {
 	if x != nil {
-		return x.StreamId
+		return x.Id
 	}
 	return ""
 }
 
-func (x *CUICMessageRequest) GetMessageId() string {
+func (x *Booking) GetStatus() string {
 	if x != nil {
-		return x.MessageId
+		return x.Status
 	}
 	return ""
 }
 
-func (x *CUICMessageRequest) GetVersion() string {
+func (x *Booking) GetProvider() string {
 	if x != nil {
-		return x.Version
+		return x.Provider
 	}
 	return ""
 }
 
-func (x *CUICMessageRequest) GetSender() string {
+func (x *Booking) GetComputeType() string {
 	if x != nil {
-		return x.Sender
+		return x.ComputeType
 	}
 	return ""
 }
 
-func (x *CUICMessageRequest) GetReceiver() string {
+func (x *Booking) GetGpuModel() string {
 	if x != nil {
-		return x.Receiver
+		return x.GpuModel
 	}
 	return ""
 }
 
-func (x *CUICMessageRequest) GetMessageType() string {
+func (x *Booking) GetCount() int32 {
 	if x != nil {
-		return x.MessageType
+		return x.Count
 	}
-	return ""
+	return 0
 }
 
-func (x *CUICMessageRequest) GetPriority() int32 {
+func (x *Booking) GetStartTime() int64 {
 	if x != nil {
-		return x.Priority
+		return x.StartTime
 	}
 	return 0
 }
 
-func (x *CUICMessageRequest) GetTimestamp() int64 {
+func (x *Booking) GetEndTime() int64 {
 	if x != nil {
-		return x.Timestamp
+		return x.EndTime
 	}
 	return 0
 }
 
-func (x *CUICMessageRequest) GetPayload() []byte {
+func (x *Booking) GetProvisionAt() int64 {
 	if x != nil {
-		return x.Payload
+		return x.ProvisionAt
 	}
-	return nil
+	return 0
 }
 
-func (x *CUICMessageRequest) GetMetadata() map[string]string {
+func (x *Booking) GetCancellationPolicy() string {
 	if x != nil {
-		return x.Metadata
+		return x.CancellationPolicy
 	}
-	return nil
+	return ""
 }
 
-func (x *CUICMessageRequest) GetCongestionHint() string {
+func (x *Booking) GetEstimatedCost() float64 {
 	if x != nil {
-		return x.CongestionHint
+		return x.EstimatedCost
+	}
+	return 0
+}
+
+func (x *Booking) GetCancellationFee() float64 {
+	if x != nil {
+		return x.CancellationFee
+	}
+	return 0
+}
+
+func (x *Booking) GetReservationId() string {
+	if x != nil {
+		return x.ReservationId
 	}
 	return ""
 }
 
-type CUICMessageResponse struct {
-	state          protoimpl.MessageState `protogen:"open.v1"`
-	StreamId       string                 `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
-	MessageId      string                 `protobuf:"bytes,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
-	InReplyTo      string                 `protobuf:"bytes,3,opt,name=in_reply_to,json=inReplyTo,proto3" json:"in_reply_to,omitempty"`
-	Version        string                 `protobuf:"bytes,4,opt,name=version,proto3" json:"version,omitempty"`
-	Sender         string                 `protobuf:"bytes,5,opt,name=sender,proto3" json:"sender,omitempty"`
-	Receiver       string                 `protobuf:"bytes,6,opt,name=receiver,proto3" json:"receiver,omitempty"`
-	Status         *CUICStatus            `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"`
-	Payload        []byte                 `protobuf:"bytes,8,opt,name=payload,proto3" json:"payload,omitempty"` // JSON-encoded payload
-	Timestamp      int64                  `protobuf:"varint,9,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	Metadata       map[string]string      `protobuf:"bytes,10,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	CongestionHint string                 `protobuf:"bytes,11,opt,name=congestion_hint,json=congestionHint,proto3" json:"congestion_hint,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+func (x *Booking) GetFailureReason() string {
+	if x != nil {
+		return x.FailureReason
+	}
+	return ""
 }
 
-func (x *CUICMessageResponse) Reset() {
-	*x = CUICMessageResponse{}
+type CreateBookingRequest struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Provider           string                 `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	ComputeType        string                 `protobuf:"bytes,2,opt,name=compute_type,json=computeType,proto3" json:"compute_type,omitempty"` // "gpu" (default) or "tpu"
+	GpuModel           string                 `protobuf:"bytes,3,opt,name=gpu_model,json=gpuModel,proto3" json:"gpu_model,omitempty"`
+	TpuVersion         string                 `protobuf:"bytes,4,opt,name=tpu_version,json=tpuVersion,proto3" json:"tpu_version,omitempty"`
+	Count              int32                  `protobuf:"varint,5,opt,name=count,proto3" json:"count,omitempty"`
+	StartTime          int64                  `protobuf:"varint,6,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"` // Unix seconds
+	EndTime            int64                  `protobuf:"varint,7,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	Region             string                 `protobuf:"bytes,8,opt,name=region,proto3" json:"region,omitempty"`
+	MinVram            int32                  `protobuf:"varint,9,opt,name=min_vram,json=minVram,proto3" json:"min_vram,omitempty"`
+	MaxCostPerHr       float64                `protobuf:"fixed64,10,opt,name=max_cost_per_hr,json=maxCostPerHr,proto3" json:"max_cost_per_hr,omitempty"`
+	CancellationPolicy string                 `protobuf:"bytes,11,opt,name=cancellation_policy,json=cancellationPolicy,proto3" json:"cancellation_policy,omitempty"` // "flexible", "moderate" or "strict"
+	WebhookUrl         string                 `protobuf:"bytes,12,opt,name=webhook_url,json=webhookUrl,proto3" json:"webhook_url,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *CreateBookingRequest) Reset() {
+	*x = CreateBookingRequest{}
 	mi := &file_proto_gpuproxy_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CUICMessageResponse) String() string {
+func (x *CreateBookingRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CUICMessageResponse) ProtoMessage() {}
+func (*CreateBookingRequest) ProtoMessage() {}
 
-func (x *CUICMessageResponse) ProtoReflect() protoreflect.Message {
+func (x *CreateBookingRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_gpuproxy_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -2154,112 +2315,116 @@ func (x *CUICMessageResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CUICMessageResponse.ProtoReflect.Descriptor instead.
-func (*CUICMessageResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use CreateBookingRequest.ProtoReflect.Descriptor instead.
+func (*CreateBookingRequest) Descriptor() ([]byte, []int) {
 	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{33}
 }
 
-func (x *CUICMessageResponse) GetStreamId() string {
+func (x *CreateBookingRequest) GetProvider() string {
 	if x != nil {
-		return x.StreamId
+		return x.Provider
 	}
 	return ""
 }
 
-func (x *CUICMessageResponse) GetMessageId() string {
+func (x *CreateBookingRequest) GetComputeType() string {
 	if x != nil {
-		return x.MessageId
+		return x.ComputeType
 	}
 	return ""
 }
 
-func (x *CUICMessageResponse) GetInReplyTo() string {
+func (x *CreateBookingRequest) GetGpuModel() string {
 	if x != nil {
-		return x.InReplyTo
+		return x.GpuModel
 	}
 	return ""
 }
 
-func (x *CUICMessageResponse) GetVersion() string {
+func (x *CreateBookingRequest) GetTpuVersion() string {
 	if x != nil {
-		return x.Version
+		return x.TpuVersion
 	}
 	return ""
 }
 
-func (x *CUICMessageResponse) GetSender() string {
+func (x *CreateBookingRequest) GetCount() int32 {
 	if x != nil {
-		return x.Sender
+		return x.Count
 	}
-	return ""
+	return 0
 }
 
-func (x *CUICMessageResponse) GetReceiver() string {
+func (x *CreateBookingRequest) GetStartTime() int64 {
 	if x != nil {
-		return x.Receiver
+		return x.StartTime
 	}
-	return ""
+	return 0
 }
 
-func (x *CUICMessageResponse) GetStatus() *CUICStatus {
+func (x *CreateBookingRequest) GetEndTime() int64 {
 	if x != nil {
-		return x.Status
+		return x.EndTime
 	}
-	return nil
+	return 0
 }
 
-func (x *CUICMessageResponse) GetPayload() []byte {
+func (x *CreateBookingRequest) GetRegion() string {
 	if x != nil {
-		return x.Payload
+		return x.Region
 	}
-	return nil
+	return ""
 }
 
-func (x *CUICMessageResponse) GetTimestamp() int64 {
+func (x *CreateBookingRequest) GetMinVram() int32 {
 	if x != nil {
-		return x.Timestamp
+		return x.MinVram
 	}
 	return 0
 }
 
-func (x *CUICMessageResponse) GetMetadata() map[string]string {
+func (x *CreateBookingRequest) GetMaxCostPerHr() float64 {
 	if x != nil {
-		return x.Metadata
+		return x.MaxCostPerHr
 	}
-	return nil
+	return 0
 }
 
-func (x *CUICMessageResponse) GetCongestionHint() string {
+func (x *CreateBookingRequest) GetCancellationPolicy() string {
 	if x != nil {
-		return x.CongestionHint
+		return x.CancellationPolicy
 	}
 	return ""
 }
 
-type CUICStatus struct {
+func (x *CreateBookingRequest) GetWebhookUrl() string {
+	if x != nil {
+		return x.WebhookUrl
+	}
+	return ""
+}
+
+type CreateBookingResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Code          int32                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
-	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
-	Success       bool                   `protobuf:"varint,3,opt,name=success,proto3" json:"success,omitempty"`
-	Retriable     bool                   `protobuf:"varint,4,opt,name=retriable,proto3" json:"retriable,omitempty"`
+	Booking       *Booking               `protobuf:"bytes,1,opt,name=booking,proto3" json:"booking,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *CUICStatus) Reset() {
-	*x = CUICStatus{}
+func (x *CreateBookingResponse) Reset() {
+	*x = CreateBookingResponse{}
 	mi := &file_proto_gpuproxy_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CUICStatus) String() string {
+func (x *CreateBookingResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CUICStatus) ProtoMessage() {}
+func (*CreateBookingResponse) ProtoMessage() {}
 
-func (x *CUICStatus) ProtoReflect() protoreflect.Message {
+func (x *CreateBookingResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_gpuproxy_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -2271,30 +2436,774 @@ func (x *CUICStatus) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CUICStatus.ProtoReflect.Descriptor instead.
-func (*CUICStatus) Descriptor() ([]byte, []int) {
+// Deprecated: Use CreateBookingResponse.ProtoReflect.Descriptor instead.
+func (*CreateBookingResponse) Descriptor() ([]byte, []int) {
 	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{34}
 }
 
-func (x *CUICStatus) GetCode() int32 {
+func (x *CreateBookingResponse) GetBooking() *Booking {
 	if x != nil {
-		return x.Code
+		return x.Booking
 	}
-	return 0
+	return nil
 }
 
-func (x *CUICStatus) GetMessage() string {
-	if x != nil {
-		return x.Message
-	}
-	return ""
+type ListBookingsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *CUICStatus) GetSuccess() bool {
-	if x != nil {
-		return x.Success
-	}
-	return false
+func (x *ListBookingsRequest) Reset() {
+	*x = ListBookingsRequest{}
+	mi := &file_proto_gpuproxy_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBookingsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBookingsRequest) ProtoMessage() {}
+
+func (x *ListBookingsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gpuproxy_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBookingsRequest.ProtoReflect.Descriptor instead.
+func (*ListBookingsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{35}
+}
+
+type ListBookingsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bookings      []*Booking             `protobuf:"bytes,1,rep,name=bookings,proto3" json:"bookings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListBookingsResponse) Reset() {
+	*x = ListBookingsResponse{}
+	mi := &file_proto_gpuproxy_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBookingsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBookingsResponse) ProtoMessage() {}
+
+func (x *ListBookingsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gpuproxy_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBookingsResponse.ProtoReflect.Descriptor instead.
+func (*ListBookingsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *ListBookingsResponse) GetBookings() []*Booking {
+	if x != nil {
+		return x.Bookings
+	}
+	return nil
+}
+
+type CancelBookingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BookingId     string                 `protobuf:"bytes,1,opt,name=booking_id,json=bookingId,proto3" json:"booking_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelBookingRequest) Reset() {
+	*x = CancelBookingRequest{}
+	mi := &file_proto_gpuproxy_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelBookingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelBookingRequest) ProtoMessage() {}
+
+func (x *CancelBookingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gpuproxy_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelBookingRequest.ProtoReflect.Descriptor instead.
+func (*CancelBookingRequest) Descriptor() ([]byte, []int) {
+	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *CancelBookingRequest) GetBookingId() string {
+	if x != nil {
+		return x.BookingId
+	}
+	return ""
+}
+
+type CancelBookingResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Booking       *Booking               `protobuf:"bytes,1,opt,name=booking,proto3" json:"booking,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelBookingResponse) Reset() {
+	*x = CancelBookingResponse{}
+	mi := &file_proto_gpuproxy_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelBookingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelBookingResponse) ProtoMessage() {}
+
+func (x *CancelBookingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gpuproxy_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelBookingResponse.ProtoReflect.Descriptor instead.
+func (*CancelBookingResponse) Descriptor() ([]byte, []int) {
+	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *CancelBookingResponse) GetBooking() *Booking {
+	if x != nil {
+		return x.Booking
+	}
+	return nil
+}
+
+type GetBookingCalendarRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ComputeType   string                 `protobuf:"bytes,1,opt,name=compute_type,json=computeType,proto3" json:"compute_type,omitempty"` // "gpu" (default) or "tpu"
+	From          int64                  `protobuf:"varint,2,opt,name=from,proto3" json:"from,omitempty"`                                 // Unix seconds; defaults to now
+	To            int64                  `protobuf:"varint,3,opt,name=to,proto3" json:"to,omitempty"`                                     // Defaults to a week after from
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetBookingCalendarRequest) Reset() {
+	*x = GetBookingCalendarRequest{}
+	mi := &file_proto_gpuproxy_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBookingCalendarRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBookingCalendarRequest) ProtoMessage() {}
+
+func (x *GetBookingCalendarRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gpuproxy_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBookingCalendarRequest.ProtoReflect.Descriptor instead.
+func (*GetBookingCalendarRequest) Descriptor() ([]byte, []int) {
+	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *GetBookingCalendarRequest) GetComputeType() string {
+	if x != nil {
+		return x.ComputeType
+	}
+	return ""
+}
+
+func (x *GetBookingCalendarRequest) GetFrom() int64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *GetBookingCalendarRequest) GetTo() int64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+type CalendarSlot struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         int64                  `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
+	End           int64                  `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
+	Booked        int32                  `protobuf:"varint,3,opt,name=booked,proto3" json:"booked,omitempty"`
+	Available     int32                  `protobuf:"varint,4,opt,name=available,proto3" json:"available,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CalendarSlot) Reset() {
+	*x = CalendarSlot{}
+	mi := &file_proto_gpuproxy_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CalendarSlot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CalendarSlot) ProtoMessage() {}
+
+func (x *CalendarSlot) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gpuproxy_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CalendarSlot.ProtoReflect.Descriptor instead.
+func (*CalendarSlot) Descriptor() ([]byte, []int) {
+	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *CalendarSlot) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *CalendarSlot) GetEnd() int64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *CalendarSlot) GetBooked() int32 {
+	if x != nil {
+		return x.Booked
+	}
+	return 0
+}
+
+func (x *CalendarSlot) GetAvailable() int32 {
+	if x != nil {
+		return x.Available
+	}
+	return 0
+}
+
+type CalendarEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BookingId     string                 `protobuf:"bytes,1,opt,name=booking_id,json=bookingId,proto3" json:"booking_id,omitempty"` // Only set on the caller's own bookings
+	Mine          bool                   `protobuf:"varint,2,opt,name=mine,proto3" json:"mine,omitempty"`
+	GpuModel      string                 `protobuf:"bytes,3,opt,name=gpu_model,json=gpuModel,proto3" json:"gpu_model,omitempty"`
+	Count         int32                  `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`
+	StartTime     int64                  `protobuf:"varint,5,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime       int64                  `protobuf:"varint,6,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	Status        string                 `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CalendarEntry) Reset() {
+	*x = CalendarEntry{}
+	mi := &file_proto_gpuproxy_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CalendarEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CalendarEntry) ProtoMessage() {}
+
+func (x *CalendarEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gpuproxy_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CalendarEntry.ProtoReflect.Descriptor instead.
+func (*CalendarEntry) Descriptor() ([]byte, []int) {
+	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *CalendarEntry) GetBookingId() string {
+	if x != nil {
+		return x.BookingId
+	}
+	return ""
+}
+
+func (x *CalendarEntry) GetMine() bool {
+	if x != nil {
+		return x.Mine
+	}
+	return false
+}
+
+func (x *CalendarEntry) GetGpuModel() string {
+	if x != nil {
+		return x.GpuModel
+	}
+	return ""
+}
+
+func (x *CalendarEntry) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *CalendarEntry) GetStartTime() int64 {
+	if x != nil {
+		return x.StartTime
+	}
+	return 0
+}
+
+func (x *CalendarEntry) GetEndTime() int64 {
+	if x != nil {
+		return x.EndTime
+	}
+	return 0
+}
+
+func (x *CalendarEntry) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type GetBookingCalendarResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Capacity      int32                  `protobuf:"varint,1,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	Slots         []*CalendarSlot        `protobuf:"bytes,2,rep,name=slots,proto3" json:"slots,omitempty"`
+	Bookings      []*CalendarEntry       `protobuf:"bytes,3,rep,name=bookings,proto3" json:"bookings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetBookingCalendarResponse) Reset() {
+	*x = GetBookingCalendarResponse{}
+	mi := &file_proto_gpuproxy_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBookingCalendarResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBookingCalendarResponse) ProtoMessage() {}
+
+func (x *GetBookingCalendarResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gpuproxy_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBookingCalendarResponse.ProtoReflect.Descriptor instead.
+func (*GetBookingCalendarResponse) Descriptor() ([]byte, []int) {
+	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *GetBookingCalendarResponse) GetCapacity() int32 {
+	if x != nil {
+		return x.Capacity
+	}
+	return 0
+}
+
+func (x *GetBookingCalendarResponse) GetSlots() []*CalendarSlot {
+	if x != nil {
+		return x.Slots
+	}
+	return nil
+}
+
+func (x *GetBookingCalendarResponse) GetBookings() []*CalendarEntry {
+	if x != nil {
+		return x.Bookings
+	}
+	return nil
+}
+
+// CUIC Protocol Messages
+type CUICMessageRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	StreamId       string                 `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	MessageId      string                 `protobuf:"bytes,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Version        string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	Sender         string                 `protobuf:"bytes,4,opt,name=sender,proto3" json:"sender,omitempty"`
+	Receiver       string                 `protobuf:"bytes,5,opt,name=receiver,proto3" json:"receiver,omitempty"`
+	MessageType    string                 `protobuf:"bytes,6,opt,name=message_type,json=messageType,proto3" json:"message_type,omitempty"` // "stream", "datagram", "request", "response", "control", "heartbeat"
+	Priority       int32                  `protobuf:"varint,7,opt,name=priority,proto3" json:"priority,omitempty"`                         // 0-255, higher = more urgent
+	Timestamp      int64                  `protobuf:"varint,8,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Payload        []byte                 `protobuf:"bytes,9,opt,name=payload,proto3" json:"payload,omitempty"` // JSON-encoded payload
+	Metadata       map[string]string      `protobuf:"bytes,10,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	CongestionHint string                 `protobuf:"bytes,11,opt,name=congestion_hint,json=congestionHint,proto3" json:"congestion_hint,omitempty"` // "none", "moderate", "severe"
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *CUICMessageRequest) Reset() {
+	*x = CUICMessageRequest{}
+	mi := &file_proto_gpuproxy_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CUICMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CUICMessageRequest) ProtoMessage() {}
+
+func (x *CUICMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gpuproxy_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CUICMessageRequest.ProtoReflect.Descriptor instead.
+func (*CUICMessageRequest) Descriptor() ([]byte, []int) {
+	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *CUICMessageRequest) GetStreamId() string {
+	if x != nil {
+		return x.StreamId
+	}
+	return ""
+}
+
+func (x *CUICMessageRequest) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *CUICMessageRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *CUICMessageRequest) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *CUICMessageRequest) GetReceiver() string {
+	if x != nil {
+		return x.Receiver
+	}
+	return ""
+}
+
+func (x *CUICMessageRequest) GetMessageType() string {
+	if x != nil {
+		return x.MessageType
+	}
+	return ""
+}
+
+func (x *CUICMessageRequest) GetPriority() int32 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+func (x *CUICMessageRequest) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *CUICMessageRequest) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *CUICMessageRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *CUICMessageRequest) GetCongestionHint() string {
+	if x != nil {
+		return x.CongestionHint
+	}
+	return ""
+}
+
+type CUICMessageResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	StreamId       string                 `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	MessageId      string                 `protobuf:"bytes,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	InReplyTo      string                 `protobuf:"bytes,3,opt,name=in_reply_to,json=inReplyTo,proto3" json:"in_reply_to,omitempty"`
+	Version        string                 `protobuf:"bytes,4,opt,name=version,proto3" json:"version,omitempty"`
+	Sender         string                 `protobuf:"bytes,5,opt,name=sender,proto3" json:"sender,omitempty"`
+	Receiver       string                 `protobuf:"bytes,6,opt,name=receiver,proto3" json:"receiver,omitempty"`
+	Status         *CUICStatus            `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"`
+	Payload        []byte                 `protobuf:"bytes,8,opt,name=payload,proto3" json:"payload,omitempty"` // JSON-encoded payload
+	Timestamp      int64                  `protobuf:"varint,9,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Metadata       map[string]string      `protobuf:"bytes,10,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	CongestionHint string                 `protobuf:"bytes,11,opt,name=congestion_hint,json=congestionHint,proto3" json:"congestion_hint,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *CUICMessageResponse) Reset() {
+	*x = CUICMessageResponse{}
+	mi := &file_proto_gpuproxy_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CUICMessageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CUICMessageResponse) ProtoMessage() {}
+
+func (x *CUICMessageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gpuproxy_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CUICMessageResponse.ProtoReflect.Descriptor instead.
+func (*CUICMessageResponse) Descriptor() ([]byte, []int) {
+	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *CUICMessageResponse) GetStreamId() string {
+	if x != nil {
+		return x.StreamId
+	}
+	return ""
+}
+
+func (x *CUICMessageResponse) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *CUICMessageResponse) GetInReplyTo() string {
+	if x != nil {
+		return x.InReplyTo
+	}
+	return ""
+}
+
+func (x *CUICMessageResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *CUICMessageResponse) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *CUICMessageResponse) GetReceiver() string {
+	if x != nil {
+		return x.Receiver
+	}
+	return ""
+}
+
+func (x *CUICMessageResponse) GetStatus() *CUICStatus {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *CUICMessageResponse) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *CUICMessageResponse) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *CUICMessageResponse) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *CUICMessageResponse) GetCongestionHint() string {
+	if x != nil {
+		return x.CongestionHint
+	}
+	return ""
+}
+
+type CUICStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          int32                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Success       bool                   `protobuf:"varint,3,opt,name=success,proto3" json:"success,omitempty"`
+	Retriable     bool                   `protobuf:"varint,4,opt,name=retriable,proto3" json:"retriable,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CUICStatus) Reset() {
+	*x = CUICStatus{}
+	mi := &file_proto_gpuproxy_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CUICStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CUICStatus) ProtoMessage() {}
+
+func (x *CUICStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gpuproxy_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CUICStatus.ProtoReflect.Descriptor instead.
+func (*CUICStatus) Descriptor() ([]byte, []int) {
+	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *CUICStatus) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *CUICStatus) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *CUICStatus) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
 }
 
 func (x *CUICStatus) GetRetriable() bool {
@@ -2313,7 +3222,7 @@ type HealthCheckRequest struct {
 
 func (x *HealthCheckRequest) Reset() {
 	*x = HealthCheckRequest{}
-	mi := &file_proto_gpuproxy_proto_msgTypes[35]
+	mi := &file_proto_gpuproxy_proto_msgTypes[46]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2325,7 +3234,7 @@ func (x *HealthCheckRequest) String() string {
 func (*HealthCheckRequest) ProtoMessage() {}
 
 func (x *HealthCheckRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gpuproxy_proto_msgTypes[35]
+	mi := &file_proto_gpuproxy_proto_msgTypes[46]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2338,7 +3247,7 @@ func (x *HealthCheckRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HealthCheckRequest.ProtoReflect.Descriptor instead.
 func (*HealthCheckRequest) Descriptor() ([]byte, []int) {
-	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{35}
+	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{46}
 }
 
 type HealthCheckResponse struct {
@@ -2352,7 +3261,7 @@ type HealthCheckResponse struct {
 
 func (x *HealthCheckResponse) Reset() {
 	*x = HealthCheckResponse{}
-	mi := &file_proto_gpuproxy_proto_msgTypes[36]
+	mi := &file_proto_gpuproxy_proto_msgTypes[47]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2364,7 +3273,7 @@ func (x *HealthCheckResponse) String() string {
 func (*HealthCheckResponse) ProtoMessage() {}
 
 func (x *HealthCheckResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_gpuproxy_proto_msgTypes[36]
+	mi := &file_proto_gpuproxy_proto_msgTypes[47]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2377,7 +3286,7 @@ func (x *HealthCheckResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HealthCheckResponse.ProtoReflect.Descriptor instead.
 func (*HealthCheckResponse) Descriptor() ([]byte, []int) {
-	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{36}
+	return file_proto_gpuproxy_proto_rawDescGZIP(), []int{47}
 }
 
 func (x *HealthCheckResponse) GetStatus() string {
@@ -2420,12 +3329,13 @@ const file_proto_gpuproxy_proto_rawDesc = "" +
 	"\aapi_key\x18\x01 \x01(\tR\x06apiKey\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1d\n" +
 	"\n" +
-	"created_at\x18\x03 \x01(\x03R\tcreatedAt\"\x8a\x01\n" +
+	"created_at\x18\x03 \x01(\x03R\tcreatedAt\"\xa0\x01\n" +
 	"\x17ListGPUInstancesRequest\x12\x1a\n" +
 	"\bprovider\x18\x01 \x01(\tR\bprovider\x12\x19\n" +
 	"\bmin_vram\x18\x02 \x01(\x01R\aminVram\x12\x1b\n" +
 	"\tmax_price\x18\x03 \x01(\x01R\bmaxPrice\x12\x1b\n" +
-	"\tgpu_model\x18\x04 \x01(\tR\bgpuModel\"\xe2\x02\n" +
+	"\tgpu_model\x18\x04 \x01(\tR\bgpuModel\x12\x14\n" +
+	"\x05query\x18\x05 \x01(\tR\x05query\"\xe2\x02\n" +
 	"\vGPUInstance\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
 	"\bprovider\x18\x02 \x01(\tR\bprovider\x12\x16\n" +
@@ -2471,7 +3381,7 @@ const file_proto_gpuproxy_proto_rawDesc = "" +
 	"\vinstance_id\x18\x02 \x01(\tR\n" +
 	"instanceId\"K\n" +
 	"\x16GetGPUInstanceResponse\x121\n" +
-	"\binstance\x18\x01 \x01(\v2\x15.gpuproxy.GPUInstanceR\binstance\"\x98\x02\n" +
+	"\binstance\x18\x01 \x01(\v2\x15.gpuproxy.GPUInstanceR\binstance\"\xf8\x02\n" +
 	"\x13ProxyRequestMessage\x12\x1a\n" +
 	"\bprotocol\x18\x01 \x01(\tR\bprotocol\x12\x1d\n" +
 	"\n" +
@@ -2479,16 +3389,22 @@ const file_proto_gpuproxy_proto_rawDesc = "" +
 	"\x06method\x18\x03 \x01(\tR\x06method\x12D\n" +
 	"\aheaders\x18\x04 \x03(\v2*.gpuproxy.ProxyRequestMessage.HeadersEntryR\aheaders\x12\x12\n" +
 	"\x04body\x18\x05 \x01(\fR\x04body\x12\x18\n" +
-	"\atimeout\x18\x06 \x01(\x05R\atimeout\x1a:\n" +
+	"\atimeout\x18\x06 \x01(\x05R\atimeout\x12%\n" +
+	"\x0ecorrelation_id\x18\a \x01(\tR\rcorrelationId\x12\x16\n" +
+	"\x06cancel\x18\b \x01(\bR\x06cancel\x12\x1f\n" +
+	"\vinstance_id\x18\t \x01(\tR\n" +
+	"instanceId\x1a:\n" +
 	"\fHeadersEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xd6\x01\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x91\x02\n" +
 	"\rProxyResponse\x12\x1f\n" +
 	"\vstatus_code\x18\x01 \x01(\x05R\n" +
 	"statusCode\x12>\n" +
 	"\aheaders\x18\x02 \x03(\v2$.gpuproxy.ProxyResponse.HeadersEntryR\aheaders\x12\x12\n" +
 	"\x04body\x18\x03 \x01(\fR\x04body\x12\x14\n" +
-	"\x05error\x18\x04 \x01(\tR\x05error\x1a:\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12%\n" +
+	"\x0ecorrelation_id\x18\x05 \x01(\tR\rcorrelationId\x12\x12\n" +
+	"\x04done\x18\x06 \x01(\bR\x04done\x1a:\n" +
 	"\fHeadersEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x8d\x01\n" +
@@ -2534,14 +3450,18 @@ const file_proto_gpuproxy_proto_rawDesc = "" +
 	"\x1aCheckSpendingLimitResponse\x12\x18\n" +
 	"\aallowed\x18\x01 \x01(\bR\aallowed\x12\x16\n" +
 	"\x06reason\x18\x02 \x01(\tR\x06reason\x12;\n" +
-	"\fwould_exceed\x18\x03 \x03(\v2\x18.gpuproxy.SpendingWindowR\vwouldExceed\"<\n" +
+	"\fwould_exceed\x18\x03 \x03(\v2\x18.gpuproxy.SpendingWindowR\vwouldExceed\"s\n" +
 	"\x1eSetLoadBalancerStrategyRequest\x12\x1a\n" +
-	"\bstrategy\x18\x01 \x01(\tR\bstrategy\"W\n" +
+	"\bstrategy\x18\x01 \x01(\tR\bstrategy\x12!\n" +
+	"\faffinity_key\x18\x02 \x01(\tR\vaffinityKey\x12\x12\n" +
+	"\x04pool\x18\x03 \x01(\tR\x04pool\"z\n" +
 	"\x1fSetLoadBalancerStrategyResponse\x12\x1a\n" +
 	"\bstrategy\x18\x01 \x01(\tR\bstrategy\x12\x18\n" +
-	"\asuccess\x18\x02 \x01(\bR\asuccess\"(\n" +
+	"\asuccess\x18\x02 \x01(\bR\asuccess\x12!\n" +
+	"\faffinity_key\x18\x03 \x01(\tR\vaffinityKey\"<\n" +
 	"\x12GetLoadInfoRequest\x12\x12\n" +
-	"\x04type\x18\x01 \x01(\tR\x04type\"\xb9\x01\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x12\n" +
+	"\x04pool\x18\x02 \x01(\tR\x04pool\"\xb9\x01\n" +
 	"\bLoadInfo\x12\x1f\n" +
 	"\vinstance_id\x18\x01 \x01(\tR\n" +
 	"instanceId\x12\x1a\n" +
@@ -2554,16 +3474,92 @@ const file_proto_gpuproxy_proto_rawDesc = "" +
 	"\vserver_load\x18\x01 \x03(\v2\x12.gpuproxy.LoadInfoR\n" +
 	"serverLoad\x127\n" +
 	"\rprovider_load\x18\x02 \x03(\v2\x12.gpuproxy.LoadInfoR\fproviderLoad\x12)\n" +
-	"\x10current_strategy\x18\x03 \x01(\tR\x0fcurrentStrategy\"~\n" +
+	"\x10current_strategy\x18\x03 \x01(\tR\x0fcurrentStrategy\"\xec\x02\n" +
 	"\x12ReserveGPUsRequest\x12\x14\n" +
 	"\x05count\x18\x01 \x01(\x05R\x05count\x12\x1a\n" +
 	"\bprovider\x18\x02 \x01(\tR\bprovider\x12\x19\n" +
 	"\bmin_vram\x18\x03 \x01(\x01R\aminVram\x12\x1b\n" +
-	"\tmax_price\x18\x04 \x01(\x01R\bmaxPrice\"\x9c\x01\n" +
+	"\tmax_price\x18\x04 \x01(\x01R\bmaxPrice\x12\x12\n" +
+	"\x04gang\x18\x05 \x01(\bR\x04gang\x12#\n" +
+	"\rsame_provider\x18\x06 \x01(\bR\fsameProvider\x12\x1f\n" +
+	"\vsame_region\x18\a \x01(\bR\n" +
+	"sameRegion\x12'\n" +
+	"\x0fsame_datacenter\x18\b \x01(\bR\x0esameDatacenter\x122\n" +
+	"\x15ready_timeout_seconds\x18\t \x01(\x03R\x13readyTimeoutSeconds\x12!\n" +
+	"\faffinity_key\x18\n" +
+	" \x01(\tR\vaffinityKey\x12\x12\n" +
+	"\x04pool\x18\v \x01(\tR\x04pool\"\x9c\x01\n" +
 	"\x13ReserveGPUsResponse\x12D\n" +
 	"\x12reserved_instances\x18\x01 \x03(\v2\x15.gpuproxy.GPUInstanceR\x11reservedInstances\x12%\n" +
 	"\x0ereserved_count\x18\x02 \x01(\x05R\rreservedCount\x12\x18\n" +
-	"\amessage\x18\x03 \x01(\tR\amessage\"\xc3\x03\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"\xd1\x03\n" +
+	"\aBooking\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x1a\n" +
+	"\bprovider\x18\x03 \x01(\tR\bprovider\x12!\n" +
+	"\fcompute_type\x18\x04 \x01(\tR\vcomputeType\x12\x1b\n" +
+	"\tgpu_model\x18\x05 \x01(\tR\bgpuModel\x12\x14\n" +
+	"\x05count\x18\x06 \x01(\x05R\x05count\x12\x1d\n" +
+	"\n" +
+	"start_time\x18\a \x01(\x03R\tstartTime\x12\x19\n" +
+	"\bend_time\x18\b \x01(\x03R\aendTime\x12!\n" +
+	"\fprovision_at\x18\t \x01(\x03R\vprovisionAt\x12/\n" +
+	"\x13cancellation_policy\x18\n" +
+	" \x01(\tR\x12cancellationPolicy\x12%\n" +
+	"\x0eestimated_cost\x18\v \x01(\x01R\restimatedCost\x12)\n" +
+	"\x10cancellation_fee\x18\f \x01(\x01R\x0fcancellationFee\x12%\n" +
+	"\x0ereservation_id\x18\r \x01(\tR\rreservationId\x12%\n" +
+	"\x0efailure_reason\x18\x0e \x01(\tR\rfailureReason\"\x8f\x03\n" +
+	"\x14CreateBookingRequest\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\x12!\n" +
+	"\fcompute_type\x18\x02 \x01(\tR\vcomputeType\x12\x1b\n" +
+	"\tgpu_model\x18\x03 \x01(\tR\bgpuModel\x12\x1f\n" +
+	"\vtpu_version\x18\x04 \x01(\tR\n" +
+	"tpuVersion\x12\x14\n" +
+	"\x05count\x18\x05 \x01(\x05R\x05count\x12\x1d\n" +
+	"\n" +
+	"start_time\x18\x06 \x01(\x03R\tstartTime\x12\x19\n" +
+	"\bend_time\x18\a \x01(\x03R\aendTime\x12\x16\n" +
+	"\x06region\x18\b \x01(\tR\x06region\x12\x19\n" +
+	"\bmin_vram\x18\t \x01(\x05R\aminVram\x12%\n" +
+	"\x0fmax_cost_per_hr\x18\n" +
+	" \x01(\x01R\fmaxCostPerHr\x12/\n" +
+	"\x13cancellation_policy\x18\v \x01(\tR\x12cancellationPolicy\x12\x1f\n" +
+	"\vwebhook_url\x18\f \x01(\tR\n" +
+	"webhookUrl\"D\n" +
+	"\x15CreateBookingResponse\x12+\n" +
+	"\abooking\x18\x01 \x01(\v2\x11.gpuproxy.BookingR\abooking\"\x15\n" +
+	"\x13ListBookingsRequest\"E\n" +
+	"\x14ListBookingsResponse\x12-\n" +
+	"\bbookings\x18\x01 \x03(\v2\x11.gpuproxy.BookingR\bbookings\"5\n" +
+	"\x14CancelBookingRequest\x12\x1d\n" +
+	"\n" +
+	"booking_id\x18\x01 \x01(\tR\tbookingId\"D\n" +
+	"\x15CancelBookingResponse\x12+\n" +
+	"\abooking\x18\x01 \x01(\v2\x11.gpuproxy.BookingR\abooking\"b\n" +
+	"\x19GetBookingCalendarRequest\x12!\n" +
+	"\fcompute_type\x18\x01 \x01(\tR\vcomputeType\x12\x12\n" +
+	"\x04from\x18\x02 \x01(\x03R\x04from\x12\x0e\n" +
+	"\x02to\x18\x03 \x01(\x03R\x02to\"l\n" +
+	"\fCalendarSlot\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\x03R\x05start\x12\x10\n" +
+	"\x03end\x18\x02 \x01(\x03R\x03end\x12\x16\n" +
+	"\x06booked\x18\x03 \x01(\x05R\x06booked\x12\x1c\n" +
+	"\tavailable\x18\x04 \x01(\x05R\tavailable\"\xc7\x01\n" +
+	"\rCalendarEntry\x12\x1d\n" +
+	"\n" +
+	"booking_id\x18\x01 \x01(\tR\tbookingId\x12\x12\n" +
+	"\x04mine\x18\x02 \x01(\bR\x04mine\x12\x1b\n" +
+	"\tgpu_model\x18\x03 \x01(\tR\bgpuModel\x12\x14\n" +
+	"\x05count\x18\x04 \x01(\x05R\x05count\x12\x1d\n" +
+	"\n" +
+	"start_time\x18\x05 \x01(\x03R\tstartTime\x12\x19\n" +
+	"\bend_time\x18\x06 \x01(\x03R\aendTime\x12\x16\n" +
+	"\x06status\x18\a \x01(\tR\x06status\"\x9b\x01\n" +
+	"\x1aGetBookingCalendarResponse\x12\x1a\n" +
+	"\bcapacity\x18\x01 \x01(\x05R\bcapacity\x12,\n" +
+	"\x05slots\x18\x02 \x03(\v2\x16.gpuproxy.CalendarSlotR\x05slots\x123\n" +
+	"\bbookings\x18\x03 \x03(\v2\x17.gpuproxy.CalendarEntryR\bbookings\"\xc3\x03\n" +
 	"\x12CUICMessageRequest\x12\x1b\n" +
 	"\tstream_id\x18\x01 \x01(\tR\bstreamId\x12\x1d\n" +
 	"\n" +
@@ -2611,7 +3607,7 @@ const file_proto_gpuproxy_proto_rawDesc = "" +
 	"\adetails\x18\x03 \x03(\v2*.gpuproxy.HealthCheckResponse.DetailsEntryR\adetails\x1a:\n" +
 	"\fDetailsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x012\x80\f\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x012\xd4\x0e\n" +
 	"\x0fGPUProxyService\x128\n" +
 	"\x05Login\x12\x16.gpuproxy.LoginRequest\x1a\x17.gpuproxy.LoginResponse\x12M\n" +
 	"\fCreateAPIKey\x12\x1d.gpuproxy.CreateAPIKeyRequest\x1a\x1e.gpuproxy.CreateAPIKeyResponse\x12Y\n" +
@@ -2627,7 +3623,11 @@ const file_proto_gpuproxy_proto_rawDesc = "" +
 	"\x12CheckSpendingLimit\x12#.gpuproxy.CheckSpendingLimitRequest\x1a$.gpuproxy.CheckSpendingLimitResponse\x12n\n" +
 	"\x17SetLoadBalancerStrategy\x12(.gpuproxy.SetLoadBalancerStrategyRequest\x1a).gpuproxy.SetLoadBalancerStrategyResponse\x12J\n" +
 	"\vGetLoadInfo\x12\x1c.gpuproxy.GetLoadInfoRequest\x1a\x1d.gpuproxy.GetLoadInfoResponse\x12J\n" +
-	"\vReserveGPUs\x12\x1c.gpuproxy.ReserveGPUsRequest\x1a\x1d.gpuproxy.ReserveGPUsResponse\x12N\n" +
+	"\vReserveGPUs\x12\x1c.gpuproxy.ReserveGPUsRequest\x1a\x1d.gpuproxy.ReserveGPUsResponse\x12P\n" +
+	"\rCreateBooking\x12\x1e.gpuproxy.CreateBookingRequest\x1a\x1f.gpuproxy.CreateBookingResponse\x12M\n" +
+	"\fListBookings\x12\x1d.gpuproxy.ListBookingsRequest\x1a\x1e.gpuproxy.ListBookingsResponse\x12P\n" +
+	"\rCancelBooking\x12\x1e.gpuproxy.CancelBookingRequest\x1a\x1f.gpuproxy.CancelBookingResponse\x12_\n" +
+	"\x12GetBookingCalendar\x12#.gpuproxy.GetBookingCalendarRequest\x1a$.gpuproxy.GetBookingCalendarResponse\x12N\n" +
 	"\x0fSendCUICMessage\x12\x1c.gpuproxy.CUICMessageRequest\x1a\x1d.gpuproxy.CUICMessageResponse\x12T\n" +
 	"\x11StreamCUICMessage\x12\x1c.gpuproxy.CUICMessageRequest\x1a\x1d.gpuproxy.CUICMessageResponse(\x010\x01\x12J\n" +
 	"\vHealthCheck\x12\x1c.gpuproxy.HealthCheckRequest\x1a\x1d.gpuproxy.HealthCheckResponseB)Z'github.com/aiserve/gpuproxy/pkg/grpc/pbb\x06proto3"
@@ -2644,7 +3644,7 @@ func file_proto_gpuproxy_proto_rawDescGZIP() []byte {
 	return file_proto_gpuproxy_proto_rawDescData
 }
 
-var file_proto_gpuproxy_proto_msgTypes = make([]protoimpl.MessageInfo, 44)
+var file_proto_gpuproxy_proto_msgTypes = make([]protoimpl.MessageInfo, 55)
 var file_proto_gpuproxy_proto_goTypes = []any{
 	(*LoginRequest)(nil),                    // 0: gpuproxy.LoginRequest
 	(*LoginResponse)(nil),                   // 1: gpuproxy.LoginResponse
@@ -2678,77 +3678,101 @@ var file_proto_gpuproxy_proto_goTypes = []any{
 	(*GetLoadInfoResponse)(nil),             // 29: gpuproxy.GetLoadInfoResponse
 	(*ReserveGPUsRequest)(nil),              // 30: gpuproxy.ReserveGPUsRequest
 	(*ReserveGPUsResponse)(nil),             // 31: gpuproxy.ReserveGPUsResponse
-	(*CUICMessageRequest)(nil),              // 32: gpuproxy.CUICMessageRequest
-	(*CUICMessageResponse)(nil),             // 33: gpuproxy.CUICMessageResponse
-	(*CUICStatus)(nil),                      // 34: gpuproxy.CUICStatus
-	(*HealthCheckRequest)(nil),              // 35: gpuproxy.HealthCheckRequest
-	(*HealthCheckResponse)(nil),             // 36: gpuproxy.HealthCheckResponse
-	nil,                                     // 37: gpuproxy.GPUInstance.MetadataEntry
-	nil,                                     // 38: gpuproxy.CreateGPUInstanceRequest.EnvEntry
-	nil,                                     // 39: gpuproxy.ProxyRequestMessage.HeadersEntry
-	nil,                                     // 40: gpuproxy.ProxyResponse.HeadersEntry
-	nil,                                     // 41: gpuproxy.CUICMessageRequest.MetadataEntry
-	nil,                                     // 42: gpuproxy.CUICMessageResponse.MetadataEntry
-	nil,                                     // 43: gpuproxy.HealthCheckResponse.DetailsEntry
+	(*Booking)(nil),                         // 32: gpuproxy.Booking
+	(*CreateBookingRequest)(nil),            // 33: gpuproxy.CreateBookingRequest
+	(*CreateBookingResponse)(nil),           // 34: gpuproxy.CreateBookingResponse
+	(*ListBookingsRequest)(nil),             // 35: gpuproxy.ListBookingsRequest
+	(*ListBookingsResponse)(nil),            // 36: gpuproxy.ListBookingsResponse
+	(*CancelBookingRequest)(nil),            // 37: gpuproxy.CancelBookingRequest
+	(*CancelBookingResponse)(nil),           // 38: gpuproxy.CancelBookingResponse
+	(*GetBookingCalendarRequest)(nil),       // 39: gpuproxy.GetBookingCalendarRequest
+	(*CalendarSlot)(nil),                    // 40: gpuproxy.CalendarSlot
+	(*CalendarEntry)(nil),                   // 41: gpuproxy.CalendarEntry
+	(*GetBookingCalendarResponse)(nil),      // 42: gpuproxy.GetBookingCalendarResponse
+	(*CUICMessageRequest)(nil),              // 43: gpuproxy.CUICMessageRequest
+	(*CUICMessageResponse)(nil),             // 44: gpuproxy.CUICMessageResponse
+	(*CUICStatus)(nil),                      // 45: gpuproxy.CUICStatus
+	(*HealthCheckRequest)(nil),              // 46: gpuproxy.HealthCheckRequest
+	(*HealthCheckResponse)(nil),             // 47: gpuproxy.HealthCheckResponse
+	nil,                                     // 48: gpuproxy.GPUInstance.MetadataEntry
+	nil,                                     // 49: gpuproxy.CreateGPUInstanceRequest.EnvEntry
+	nil,                                     // 50: gpuproxy.ProxyRequestMessage.HeadersEntry
+	nil,                                     // 51: gpuproxy.ProxyResponse.HeadersEntry
+	nil,                                     // 52: gpuproxy.CUICMessageRequest.MetadataEntry
+	nil,                                     // 53: gpuproxy.CUICMessageResponse.MetadataEntry
+	nil,                                     // 54: gpuproxy.HealthCheckResponse.DetailsEntry
 }
 var file_proto_gpuproxy_proto_depIdxs = []int32{
-	37, // 0: gpuproxy.GPUInstance.metadata:type_name -> gpuproxy.GPUInstance.MetadataEntry
+	48, // 0: gpuproxy.GPUInstance.metadata:type_name -> gpuproxy.GPUInstance.MetadataEntry
 	5,  // 1: gpuproxy.ListGPUInstancesResponse.instances:type_name -> gpuproxy.GPUInstance
-	38, // 2: gpuproxy.CreateGPUInstanceRequest.env:type_name -> gpuproxy.CreateGPUInstanceRequest.EnvEntry
+	49, // 2: gpuproxy.CreateGPUInstanceRequest.env:type_name -> gpuproxy.CreateGPUInstanceRequest.EnvEntry
 	5,  // 3: gpuproxy.GetGPUInstanceResponse.instance:type_name -> gpuproxy.GPUInstance
-	39, // 4: gpuproxy.ProxyRequestMessage.headers:type_name -> gpuproxy.ProxyRequestMessage.HeadersEntry
-	40, // 5: gpuproxy.ProxyResponse.headers:type_name -> gpuproxy.ProxyResponse.HeadersEntry
+	50, // 4: gpuproxy.ProxyRequestMessage.headers:type_name -> gpuproxy.ProxyRequestMessage.HeadersEntry
+	51, // 5: gpuproxy.ProxyResponse.headers:type_name -> gpuproxy.ProxyResponse.HeadersEntry
 	18, // 6: gpuproxy.GetTransactionsResponse.transactions:type_name -> gpuproxy.Transaction
 	21, // 7: gpuproxy.GetSpendingInfoResponse.windows:type_name -> gpuproxy.SpendingWindow
 	21, // 8: gpuproxy.CheckSpendingLimitResponse.would_exceed:type_name -> gpuproxy.SpendingWindow
 	28, // 9: gpuproxy.GetLoadInfoResponse.server_load:type_name -> gpuproxy.LoadInfo
 	28, // 10: gpuproxy.GetLoadInfoResponse.provider_load:type_name -> gpuproxy.LoadInfo
 	5,  // 11: gpuproxy.ReserveGPUsResponse.reserved_instances:type_name -> gpuproxy.GPUInstance
-	41, // 12: gpuproxy.CUICMessageRequest.metadata:type_name -> gpuproxy.CUICMessageRequest.MetadataEntry
-	34, // 13: gpuproxy.CUICMessageResponse.status:type_name -> gpuproxy.CUICStatus
-	42, // 14: gpuproxy.CUICMessageResponse.metadata:type_name -> gpuproxy.CUICMessageResponse.MetadataEntry
-	43, // 15: gpuproxy.HealthCheckResponse.details:type_name -> gpuproxy.HealthCheckResponse.DetailsEntry
-	0,  // 16: gpuproxy.GPUProxyService.Login:input_type -> gpuproxy.LoginRequest
-	2,  // 17: gpuproxy.GPUProxyService.CreateAPIKey:input_type -> gpuproxy.CreateAPIKeyRequest
-	4,  // 18: gpuproxy.GPUProxyService.ListGPUInstances:input_type -> gpuproxy.ListGPUInstancesRequest
-	7,  // 19: gpuproxy.GPUProxyService.CreateGPUInstance:input_type -> gpuproxy.CreateGPUInstanceRequest
-	9,  // 20: gpuproxy.GPUProxyService.DestroyGPUInstance:input_type -> gpuproxy.DestroyGPUInstanceRequest
-	11, // 21: gpuproxy.GPUProxyService.GetGPUInstance:input_type -> gpuproxy.GetGPUInstanceRequest
-	13, // 22: gpuproxy.GPUProxyService.ProxyRequest:input_type -> gpuproxy.ProxyRequestMessage
-	13, // 23: gpuproxy.GPUProxyService.StreamProxyRequest:input_type -> gpuproxy.ProxyRequestMessage
-	15, // 24: gpuproxy.GPUProxyService.CreatePayment:input_type -> gpuproxy.CreatePaymentRequest
-	17, // 25: gpuproxy.GPUProxyService.GetTransactions:input_type -> gpuproxy.GetTransactionsRequest
-	20, // 26: gpuproxy.GPUProxyService.GetSpendingInfo:input_type -> gpuproxy.GetSpendingInfoRequest
-	23, // 27: gpuproxy.GPUProxyService.CheckSpendingLimit:input_type -> gpuproxy.CheckSpendingLimitRequest
-	25, // 28: gpuproxy.GPUProxyService.SetLoadBalancerStrategy:input_type -> gpuproxy.SetLoadBalancerStrategyRequest
-	27, // 29: gpuproxy.GPUProxyService.GetLoadInfo:input_type -> gpuproxy.GetLoadInfoRequest
-	30, // 30: gpuproxy.GPUProxyService.ReserveGPUs:input_type -> gpuproxy.ReserveGPUsRequest
-	32, // 31: gpuproxy.GPUProxyService.SendCUICMessage:input_type -> gpuproxy.CUICMessageRequest
-	32, // 32: gpuproxy.GPUProxyService.StreamCUICMessage:input_type -> gpuproxy.CUICMessageRequest
-	35, // 33: gpuproxy.GPUProxyService.HealthCheck:input_type -> gpuproxy.HealthCheckRequest
-	1,  // 34: gpuproxy.GPUProxyService.Login:output_type -> gpuproxy.LoginResponse
-	3,  // 35: gpuproxy.GPUProxyService.CreateAPIKey:output_type -> gpuproxy.CreateAPIKeyResponse
-	6,  // 36: gpuproxy.GPUProxyService.ListGPUInstances:output_type -> gpuproxy.ListGPUInstancesResponse
-	8,  // 37: gpuproxy.GPUProxyService.CreateGPUInstance:output_type -> gpuproxy.CreateGPUInstanceResponse
-	10, // 38: gpuproxy.GPUProxyService.DestroyGPUInstance:output_type -> gpuproxy.DestroyGPUInstanceResponse
-	12, // 39: gpuproxy.GPUProxyService.GetGPUInstance:output_type -> gpuproxy.GetGPUInstanceResponse
-	14, // 40: gpuproxy.GPUProxyService.ProxyRequest:output_type -> gpuproxy.ProxyResponse
-	14, // 41: gpuproxy.GPUProxyService.StreamProxyRequest:output_type -> gpuproxy.ProxyResponse
-	16, // 42: gpuproxy.GPUProxyService.CreatePayment:output_type -> gpuproxy.CreatePaymentResponse
-	19, // 43: gpuproxy.GPUProxyService.GetTransactions:output_type -> gpuproxy.GetTransactionsResponse
-	22, // 44: gpuproxy.GPUProxyService.GetSpendingInfo:output_type -> gpuproxy.GetSpendingInfoResponse
-	24, // 45: gpuproxy.GPUProxyService.CheckSpendingLimit:output_type -> gpuproxy.CheckSpendingLimitResponse
-	26, // 46: gpuproxy.GPUProxyService.SetLoadBalancerStrategy:output_type -> gpuproxy.SetLoadBalancerStrategyResponse
-	29, // 47: gpuproxy.GPUProxyService.GetLoadInfo:output_type -> gpuproxy.GetLoadInfoResponse
-	31, // 48: gpuproxy.GPUProxyService.ReserveGPUs:output_type -> gpuproxy.ReserveGPUsResponse
-	33, // 49: gpuproxy.GPUProxyService.SendCUICMessage:output_type -> gpuproxy.CUICMessageResponse
-	33, // 50: gpuproxy.GPUProxyService.StreamCUICMessage:output_type -> gpuproxy.CUICMessageResponse
-	36, // 51: gpuproxy.GPUProxyService.HealthCheck:output_type -> gpuproxy.HealthCheckResponse
-	34, // [34:52] is the sub-list for method output_type
-	16, // [16:34] is the sub-list for method input_type
-	16, // [16:16] is the sub-list for extension type_name
-	16, // [16:16] is the sub-list for extension extendee
-	0,  // [0:16] is the sub-list for field type_name
+	32, // 12: gpuproxy.CreateBookingResponse.booking:type_name -> gpuproxy.Booking
+	32, // 13: gpuproxy.ListBookingsResponse.bookings:type_name -> gpuproxy.Booking
+	32, // 14: gpuproxy.CancelBookingResponse.booking:type_name -> gpuproxy.Booking
+	40, // 15: gpuproxy.GetBookingCalendarResponse.slots:type_name -> gpuproxy.CalendarSlot
+	41, // 16: gpuproxy.GetBookingCalendarResponse.bookings:type_name -> gpuproxy.CalendarEntry
+	52, // 17: gpuproxy.CUICMessageRequest.metadata:type_name -> gpuproxy.CUICMessageRequest.MetadataEntry
+	45, // 18: gpuproxy.CUICMessageResponse.status:type_name -> gpuproxy.CUICStatus
+	53, // 19: gpuproxy.CUICMessageResponse.metadata:type_name -> gpuproxy.CUICMessageResponse.MetadataEntry
+	54, // 20: gpuproxy.HealthCheckResponse.details:type_name -> gpuproxy.HealthCheckResponse.DetailsEntry
+	0,  // 21: gpuproxy.GPUProxyService.Login:input_type -> gpuproxy.LoginRequest
+	2,  // 22: gpuproxy.GPUProxyService.CreateAPIKey:input_type -> gpuproxy.CreateAPIKeyRequest
+	4,  // 23: gpuproxy.GPUProxyService.ListGPUInstances:input_type -> gpuproxy.ListGPUInstancesRequest
+	7,  // 24: gpuproxy.GPUProxyService.CreateGPUInstance:input_type -> gpuproxy.CreateGPUInstanceRequest
+	9,  // 25: gpuproxy.GPUProxyService.DestroyGPUInstance:input_type -> gpuproxy.DestroyGPUInstanceRequest
+	11, // 26: gpuproxy.GPUProxyService.GetGPUInstance:input_type -> gpuproxy.GetGPUInstanceRequest
+	13, // 27: gpuproxy.GPUProxyService.ProxyRequest:input_type -> gpuproxy.ProxyRequestMessage
+	13, // 28: gpuproxy.GPUProxyService.StreamProxyRequest:input_type -> gpuproxy.ProxyRequestMessage
+	15, // 29: gpuproxy.GPUProxyService.CreatePayment:input_type -> gpuproxy.CreatePaymentRequest
+	17, // 30: gpuproxy.GPUProxyService.GetTransactions:input_type -> gpuproxy.GetTransactionsRequest
+	20, // 31: gpuproxy.GPUProxyService.GetSpendingInfo:input_type -> gpuproxy.GetSpendingInfoRequest
+	23, // 32: gpuproxy.GPUProxyService.CheckSpendingLimit:input_type -> gpuproxy.CheckSpendingLimitRequest
+	25, // 33: gpuproxy.GPUProxyService.SetLoadBalancerStrategy:input_type -> gpuproxy.SetLoadBalancerStrategyRequest
+	27, // 34: gpuproxy.GPUProxyService.GetLoadInfo:input_type -> gpuproxy.GetLoadInfoRequest
+	30, // 35: gpuproxy.GPUProxyService.ReserveGPUs:input_type -> gpuproxy.ReserveGPUsRequest
+	33, // 36: gpuproxy.GPUProxyService.CreateBooking:input_type -> gpuproxy.CreateBookingRequest
+	35, // 37: gpuproxy.GPUProxyService.ListBookings:input_type -> gpuproxy.ListBookingsRequest
+	37, // 38: gpuproxy.GPUProxyService.CancelBooking:input_type -> gpuproxy.CancelBookingRequest
+	39, // 39: gpuproxy.GPUProxyService.GetBookingCalendar:input_type -> gpuproxy.GetBookingCalendarRequest
+	43, // 40: gpuproxy.GPUProxyService.SendCUICMessage:input_type -> gpuproxy.CUICMessageRequest
+	43, // 41: gpuproxy.GPUProxyService.StreamCUICMessage:input_type -> gpuproxy.CUICMessageRequest
+	46, // 42: gpuproxy.GPUProxyService.HealthCheck:input_type -> gpuproxy.HealthCheckRequest
+	1,  // 43: gpuproxy.GPUProxyService.Login:output_type -> gpuproxy.LoginResponse
+	3,  // 44: gpuproxy.GPUProxyService.CreateAPIKey:output_type -> gpuproxy.CreateAPIKeyResponse
+	6,  // 45: gpuproxy.GPUProxyService.ListGPUInstances:output_type -> gpuproxy.ListGPUInstancesResponse
+	8,  // 46: gpuproxy.GPUProxyService.CreateGPUInstance:output_type -> gpuproxy.CreateGPUInstanceResponse
+	10, // 47: gpuproxy.GPUProxyService.DestroyGPUInstance:output_type -> gpuproxy.DestroyGPUInstanceResponse
+	12, // 48: gpuproxy.GPUProxyService.GetGPUInstance:output_type -> gpuproxy.GetGPUInstanceResponse
+	14, // 49: gpuproxy.GPUProxyService.ProxyRequest:output_type -> gpuproxy.ProxyResponse
+	14, // 50: gpuproxy.GPUProxyService.StreamProxyRequest:output_type -> gpuproxy.ProxyResponse
+	16, // 51: gpuproxy.GPUProxyService.CreatePayment:output_type -> gpuproxy.CreatePaymentResponse
+	19, // 52: gpuproxy.GPUProxyService.GetTransactions:output_type -> gpuproxy.GetTransactionsResponse
+	22, // 53: gpuproxy.GPUProxyService.GetSpendingInfo:output_type -> gpuproxy.GetSpendingInfoResponse
+	24, // 54: gpuproxy.GPUProxyService.CheckSpendingLimit:output_type -> gpuproxy.CheckSpendingLimitResponse
+	26, // 55: gpuproxy.GPUProxyService.SetLoadBalancerStrategy:output_type -> gpuproxy.SetLoadBalancerStrategyResponse
+	29, // 56: gpuproxy.GPUProxyService.GetLoadInfo:output_type -> gpuproxy.GetLoadInfoResponse
+	31, // 57: gpuproxy.GPUProxyService.ReserveGPUs:output_type -> gpuproxy.ReserveGPUsResponse
+	34, // 58: gpuproxy.GPUProxyService.CreateBooking:output_type -> gpuproxy.CreateBookingResponse
+	36, // 59: gpuproxy.GPUProxyService.ListBookings:output_type -> gpuproxy.ListBookingsResponse
+	38, // 60: gpuproxy.GPUProxyService.CancelBooking:output_type -> gpuproxy.CancelBookingResponse
+	42, // 61: gpuproxy.GPUProxyService.GetBookingCalendar:output_type -> gpuproxy.GetBookingCalendarResponse
+	44, // 62: gpuproxy.GPUProxyService.SendCUICMessage:output_type -> gpuproxy.CUICMessageResponse
+	44, // 63: gpuproxy.GPUProxyService.StreamCUICMessage:output_type -> gpuproxy.CUICMessageResponse
+	47, // 64: gpuproxy.GPUProxyService.HealthCheck:output_type -> gpuproxy.HealthCheckResponse
+	43, // [43:65] is the sub-list for method output_type
+	21, // [21:43] is the sub-list for method input_type
+	21, // [21:21] is the sub-list for extension type_name
+	21, // [21:21] is the sub-list for extension extendee
+	0,  // [0:21] is the sub-list for field type_name
 }
 
 func init() { file_proto_gpuproxy_proto_init() }
@@ -2762,7 +3786,7 @@ func file_proto_gpuproxy_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_gpuproxy_proto_rawDesc), len(file_proto_gpuproxy_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   44,
+			NumMessages:   55,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
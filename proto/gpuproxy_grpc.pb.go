@@ -34,6 +34,10 @@ const (
 	GPUProxyService_SetLoadBalancerStrategy_FullMethodName = "/gpuproxy.GPUProxyService/SetLoadBalancerStrategy"
 	GPUProxyService_GetLoadInfo_FullMethodName             = "/gpuproxy.GPUProxyService/GetLoadInfo"
 	GPUProxyService_ReserveGPUs_FullMethodName             = "/gpuproxy.GPUProxyService/ReserveGPUs"
+	GPUProxyService_CreateBooking_FullMethodName           = "/gpuproxy.GPUProxyService/CreateBooking"
+	GPUProxyService_ListBookings_FullMethodName            = "/gpuproxy.GPUProxyService/ListBookings"
+	GPUProxyService_CancelBooking_FullMethodName           = "/gpuproxy.GPUProxyService/CancelBooking"
+	GPUProxyService_GetBookingCalendar_FullMethodName      = "/gpuproxy.GPUProxyService/GetBookingCalendar"
 	GPUProxyService_SendCUICMessage_FullMethodName         = "/gpuproxy.GPUProxyService/SendCUICMessage"
 	GPUProxyService_StreamCUICMessage_FullMethodName       = "/gpuproxy.GPUProxyService/StreamCUICMessage"
 	GPUProxyService_HealthCheck_FullMethodName             = "/gpuproxy.GPUProxyService/HealthCheck"
@@ -66,6 +70,11 @@ type GPUProxyServiceClient interface {
 	SetLoadBalancerStrategy(ctx context.Context, in *SetLoadBalancerStrategyRequest, opts ...grpc.CallOption) (*SetLoadBalancerStrategyResponse, error)
 	GetLoadInfo(ctx context.Context, in *GetLoadInfoRequest, opts ...grpc.CallOption) (*GetLoadInfoResponse, error)
 	ReserveGPUs(ctx context.Context, in *ReserveGPUsRequest, opts ...grpc.CallOption) (*ReserveGPUsResponse, error)
+	// Advance Bookings
+	CreateBooking(ctx context.Context, in *CreateBookingRequest, opts ...grpc.CallOption) (*CreateBookingResponse, error)
+	ListBookings(ctx context.Context, in *ListBookingsRequest, opts ...grpc.CallOption) (*ListBookingsResponse, error)
+	CancelBooking(ctx context.Context, in *CancelBookingRequest, opts ...grpc.CallOption) (*CancelBookingResponse, error)
+	GetBookingCalendar(ctx context.Context, in *GetBookingCalendarRequest, opts ...grpc.CallOption) (*GetBookingCalendarResponse, error)
 	// CUIC Protocol
 	SendCUICMessage(ctx context.Context, in *CUICMessageRequest, opts ...grpc.CallOption) (*CUICMessageResponse, error)
 	StreamCUICMessage(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[CUICMessageRequest, CUICMessageResponse], error)
@@ -234,6 +243,46 @@ func (c *gPUProxyServiceClient) ReserveGPUs(ctx context.Context, in *ReserveGPUs
 	return out, nil
 }
 
+func (c *gPUProxyServiceClient) CreateBooking(ctx context.Context, in *CreateBookingRequest, opts ...grpc.CallOption) (*CreateBookingResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateBookingResponse)
+	err := c.cc.Invoke(ctx, GPUProxyService_CreateBooking_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gPUProxyServiceClient) ListBookings(ctx context.Context, in *ListBookingsRequest, opts ...grpc.CallOption) (*ListBookingsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListBookingsResponse)
+	err := c.cc.Invoke(ctx, GPUProxyService_ListBookings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gPUProxyServiceClient) CancelBooking(ctx context.Context, in *CancelBookingRequest, opts ...grpc.CallOption) (*CancelBookingResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelBookingResponse)
+	err := c.cc.Invoke(ctx, GPUProxyService_CancelBooking_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gPUProxyServiceClient) GetBookingCalendar(ctx context.Context, in *GetBookingCalendarRequest, opts ...grpc.CallOption) (*GetBookingCalendarResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetBookingCalendarResponse)
+	err := c.cc.Invoke(ctx, GPUProxyService_GetBookingCalendar_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *gPUProxyServiceClient) SendCUICMessage(ctx context.Context, in *CUICMessageRequest, opts ...grpc.CallOption) (*CUICMessageResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(CUICMessageResponse)
@@ -294,6 +343,11 @@ type GPUProxyServiceServer interface {
 	SetLoadBalancerStrategy(context.Context, *SetLoadBalancerStrategyRequest) (*SetLoadBalancerStrategyResponse, error)
 	GetLoadInfo(context.Context, *GetLoadInfoRequest) (*GetLoadInfoResponse, error)
 	ReserveGPUs(context.Context, *ReserveGPUsRequest) (*ReserveGPUsResponse, error)
+	// Advance Bookings
+	CreateBooking(context.Context, *CreateBookingRequest) (*CreateBookingResponse, error)
+	ListBookings(context.Context, *ListBookingsRequest) (*ListBookingsResponse, error)
+	CancelBooking(context.Context, *CancelBookingRequest) (*CancelBookingResponse, error)
+	GetBookingCalendar(context.Context, *GetBookingCalendarRequest) (*GetBookingCalendarResponse, error)
 	// CUIC Protocol
 	SendCUICMessage(context.Context, *CUICMessageRequest) (*CUICMessageResponse, error)
 	StreamCUICMessage(grpc.BidiStreamingServer[CUICMessageRequest, CUICMessageResponse]) error
@@ -354,6 +408,18 @@ func (UnimplementedGPUProxyServiceServer) GetLoadInfo(context.Context, *GetLoadI
 func (UnimplementedGPUProxyServiceServer) ReserveGPUs(context.Context, *ReserveGPUsRequest) (*ReserveGPUsResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method ReserveGPUs not implemented")
 }
+func (UnimplementedGPUProxyServiceServer) CreateBooking(context.Context, *CreateBookingRequest) (*CreateBookingResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CreateBooking not implemented")
+}
+func (UnimplementedGPUProxyServiceServer) ListBookings(context.Context, *ListBookingsRequest) (*ListBookingsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListBookings not implemented")
+}
+func (UnimplementedGPUProxyServiceServer) CancelBooking(context.Context, *CancelBookingRequest) (*CancelBookingResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CancelBooking not implemented")
+}
+func (UnimplementedGPUProxyServiceServer) GetBookingCalendar(context.Context, *GetBookingCalendarRequest) (*GetBookingCalendarResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetBookingCalendar not implemented")
+}
 func (UnimplementedGPUProxyServiceServer) SendCUICMessage(context.Context, *CUICMessageRequest) (*CUICMessageResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method SendCUICMessage not implemented")
 }
@@ -643,6 +709,78 @@ func _GPUProxyService_ReserveGPUs_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _GPUProxyService_CreateBooking_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateBookingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GPUProxyServiceServer).CreateBooking(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GPUProxyService_CreateBooking_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GPUProxyServiceServer).CreateBooking(ctx, req.(*CreateBookingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GPUProxyService_ListBookings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBookingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GPUProxyServiceServer).ListBookings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GPUProxyService_ListBookings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GPUProxyServiceServer).ListBookings(ctx, req.(*ListBookingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GPUProxyService_CancelBooking_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelBookingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GPUProxyServiceServer).CancelBooking(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GPUProxyService_CancelBooking_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GPUProxyServiceServer).CancelBooking(ctx, req.(*CancelBookingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GPUProxyService_GetBookingCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBookingCalendarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GPUProxyServiceServer).GetBookingCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GPUProxyService_GetBookingCalendar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GPUProxyServiceServer).GetBookingCalendar(ctx, req.(*GetBookingCalendarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _GPUProxyService_SendCUICMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CUICMessageRequest)
 	if err := dec(in); err != nil {
@@ -749,6 +887,22 @@ var GPUProxyService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ReserveGPUs",
 			Handler:    _GPUProxyService_ReserveGPUs_Handler,
 		},
+		{
+			MethodName: "CreateBooking",
+			Handler:    _GPUProxyService_CreateBooking_Handler,
+		},
+		{
+			MethodName: "ListBookings",
+			Handler:    _GPUProxyService_ListBookings_Handler,
+		},
+		{
+			MethodName: "CancelBooking",
+			Handler:    _GPUProxyService_CancelBooking_Handler,
+		},
+		{
+			MethodName: "GetBookingCalendar",
+			Handler:    _GPUProxyService_GetBookingCalendar_Handler,
+		},
 		{
 			MethodName: "SendCUICMessage",
 			Handler:    _GPUProxyService_SendCUICMessage_Handler,
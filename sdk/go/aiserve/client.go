@@ -350,6 +350,14 @@ type ReserveRequest struct {
 	Count   int            `json:"count"`
 	Filters *GPUFilters    `json:"filters,omitempty"`
 	Config  *InstanceConfig `json:"config,omitempty"`
+	Pool    string         `json:"pool,omitempty"` // Reserve from a named load balancer pool
+
+	// Gang reserves all instances or none
+	Gang           bool   `json:"gang,omitempty"`
+	SameProvider   bool   `json:"same_provider,omitempty"`
+	SameRegion     bool   `json:"same_region,omitempty"`
+	SameDatacenter bool   `json:"same_datacenter,omitempty"`
+	ReadyTimeout   string `json:"ready_timeout,omitempty"` // e.g. "10m"
 }
 
 type ReservationInstance struct {
@@ -514,6 +522,9 @@ const (
 	StrategyWeightedRoundRobin = "weighted_round_robin"
 	StrategyLeastConnections   = "least_connections"
 	StrategyLeastResponseTime  = "least_response_time"
+	StrategyConsistentHash     = "consistent_hash"
+	StrategyStickySession      = "sticky_session"
+	StrategyP2C                = "p2c"
 )
 
 type GetStrategyResponse struct {
@@ -527,7 +538,9 @@ func (s *LoadBalancerService) GetStrategy(ctx context.Context) (string, error) {
 }
 
 type SetStrategyRequest struct {
-	Strategy string `json:"strategy"`
+	Strategy    string `json:"strategy"`
+	AffinityKey string `json:"affinity_key,omitempty"`
+	Pool        string `json:"pool,omitempty"`
 }
 
 func (s *LoadBalancerService) SetStrategy(ctx context.Context, strategy string) error {
@@ -535,13 +548,28 @@ func (s *LoadBalancerService) SetStrategy(ctx context.Context, strategy string)
 	return s.client.Request(ctx, "PUT", "/api/v1/loadbalancer/strategy", req, nil)
 }
 
+// SetAffinityStrategy selects consistent_hash or sticky_session, keyed on
+// affinityKey: "user", "api_key", "header:<name>" or "field:<name>"
+func (s *LoadBalancerService) SetAffinityStrategy(ctx context.Context, strategy, affinityKey string) error {
+	req := &SetStrategyRequest{Strategy: strategy, AffinityKey: affinityKey}
+	return s.client.Request(ctx, "PUT", "/api/v1/loadbalancer/strategy", req, nil)
+}
+
 type InstanceLoad struct {
 	Connections    int     `json:"connections"`
 	Load           float64 `json:"load"`
 	ResponseTimeMs float64 `json:"response_time_ms"`
 }
 
+// SetPoolStrategy changes the strategy of a named pool
+func (s *LoadBalancerService) SetPoolStrategy(ctx context.Context, pool, strategy string) error {
+	req := &SetStrategyRequest{Strategy: strategy, Pool: pool}
+	return s.client.Request(ctx, "PUT", "/api/v1/loadbalancer/strategy", req, nil)
+}
+
 type GetLoadsResponse struct {
+	Pool     string                  `json:"pool,omitempty"`
+	Pools    []string                `json:"pools"`
 	Strategy string                  `json:"strategy"`
 	Loads    map[string]InstanceLoad `json:"loads"`
 	Count    int                     `json:"count"`
@@ -553,6 +581,13 @@ func (s *LoadBalancerService) GetLoads(ctx context.Context) (*GetLoadsResponse,
 	return &resp, err
 }
 
+// GetPoolLoads returns the loads as seen by a named pool
+func (s *LoadBalancerService) GetPoolLoads(ctx context.Context, pool string) (*GetLoadsResponse, error) {
+	var resp GetLoadsResponse
+	err := s.client.RequestWithQuery(ctx, "GET", "/api/v1/loadbalancer/loads", url.Values{"pool": {pool}}, &resp)
+	return &resp, err
+}
+
 // QuotaService handles storage quota operations
 type QuotaService struct {
 	client *Client